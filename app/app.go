@@ -1,19 +1,28 @@
 package app
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"github.com/deepinbytes/go_voucher/domain/offer"
-	"github.com/deepinbytes/go_voucher/domain/voucher"
-	"github.com/deepinbytes/go_voucher/repositories/offerrepo"
-	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/common/cache"
+	"github.com/deepinbytes/go_voucher/common/health"
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/common/metrics"
+	"github.com/deepinbytes/go_voucher/common/notifications"
+	"github.com/deepinbytes/go_voucher/common/ratelimit"
+	"github.com/deepinbytes/go_voucher/common/rates"
+	"github.com/deepinbytes/go_voucher/common/worker"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/deepinbytes/go_voucher/configs"
-	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/notification"
+	"github.com/deepinbytes/go_voucher/domain/stats"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jinzhu/gorm"
 	"github.com/joho/godotenv"
 
 	swaggerFiles "github.com/swaggo/files"
@@ -22,15 +31,28 @@ import (
 	_ "github.com/deepinbytes/go_voucher/docs" // docs is generated by Swag CLI
 
 	"github.com/deepinbytes/go_voucher/controllers"
-	"github.com/deepinbytes/go_voucher/repositories/userrepo"
+	"github.com/deepinbytes/go_voucher/graphqlserver"
+	"github.com/deepinbytes/go_voucher/grpcserver"
+	"github.com/deepinbytes/go_voucher/middlewares"
+	"github.com/deepinbytes/go_voucher/services/giftcardservice"
+	"github.com/deepinbytes/go_voucher/services/lifecycleservice"
+	"github.com/deepinbytes/go_voucher/services/notificationservice"
 	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/printservice"
+	"github.com/deepinbytes/go_voucher/services/privacyservice"
+	"github.com/deepinbytes/go_voucher/services/redemptionservice"
+	"github.com/deepinbytes/go_voucher/services/referralservice"
+	"github.com/deepinbytes/go_voucher/services/statsservice"
+	"github.com/deepinbytes/go_voucher/services/tenantservice"
+	"github.com/deepinbytes/go_voucher/services/tokenservice"
+	"github.com/deepinbytes/go_voucher/services/triggerservice"
 	"github.com/deepinbytes/go_voucher/services/userservice"
 	"github.com/deepinbytes/go_voucher/services/voucherservice"
-	_ "github.com/lib/pq" // For Postgres setup
+	"github.com/deepinbytes/go_voucher/services/walletservice"
 )
 
 var (
-	router = gin.Default()
+	router = gin.New()
 )
 
 // @host localhost:3000
@@ -50,77 +72,418 @@ func Run() {
 	/*
 		====== Setup configs ============
 	*/
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
+	// A .env file is optional, real environment variables take precedence
+	if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
+		log.Fatalf("Error loading .env file: %v", err)
+	}
+	config, err := configs.GetConfig()
+	if err == flag.ErrHelp {
+		return
 	}
-	config := configs.GetConfig()
-
-	// Connects to PostgresDB
-	db, err := gorm.Open(
-		config.Postgres.Dialect(),
-		config.Postgres.GetPostgresConnectionInfo(),
-	)
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
 	}
 
-	// Migration
-	// db.DropTableIfExists(&user.User{})
-	db.AutoMigrate(&user.User{}, &offer.Offer{}, &voucher.Voucher{})
-	defer db.Close()
+	appLogger := logger.New(os.Stdout, logger.ParseLevel(config.LogLevel))
+	logger.SetDefault(appLogger)
+	appLogger.Info("starting", logger.Fields{
+		"env":      config.Env,
+		"port":     config.Port,
+		"storage":  config.Storage.Backend,
+		"database": config.Postgres.String(),
+	})
 
 	/*
 		====== Setup repositories =======
 	*/
-	userRepo := userrepo.NewUserRepo(db)
-	voucherRepo := voucherrepo.NewVoucherRepo(db)
-	offerRepo := offerrepo.NewOfferRepo(db)
+	store, err := openStorage(config, appLogger)
+	if err != nil {
+		appLogger.Error("connecting to database", logger.Fields{"error": err})
+		os.Exit(1)
+	}
+	if store.migrateErr != nil {
+		appLogger.Error("migrating database", logger.Fields{"error": store.migrateErr})
+	}
+	defer store.Close()
 
 	/*
 		====== Setup services ===========
 	*/
-	userService := userservice.NewUserService(userRepo)
-	voucherService := voucherservice.NewVoucherService(voucherRepo)
-	offerService := offerservice.NewOfferService(offerRepo)
+	tenantService := tenantservice.NewTenantService(store.tenants, store.apiKeys, tenantservice.KeyPolicy{
+		TTL:   config.Tenant.KeyTTL,
+		Grace: config.Tenant.KeyGrace,
+	})
+	// without a key of the default tenant no tenant can be created
+	if err := tenantService.BootstrapAdminKey(logger.NewContext(context.Background(), appLogger),
+		config.Tenant.AdminKey.Value()); err != nil {
+		appLogger.Error("storing TENANT_ADMIN_KEY", logger.Fields{"error": err})
+	}
+	offerService := offerservice.NewOfferService(store.offers)
+
+	// Cache errors fall back to the database, so the cache is not part of
+	// the readiness checks
+	cacheStore := newCacheStore(config.Cache)
+	if cacheStore != nil {
+		offerService = offerservice.NewCachedOfferService(offerService,
+			cache.New("offers", cacheStore, config.Cache.TTL))
+	}
+	// notifications read vouchers from the repository, the voucher service
+	// queues them once a voucher is issued
+	notificationService := notificationservice.NewNotificationService(store.notifications, store.users, offerService,
+		store.vouchers, newNotifier(config.Notify), notificationservice.Policy{
+			MaxAttempts: config.Notify.MaxAttempts,
+			RetryBase:   config.Notify.RetryBase,
+			Locale:      config.Notify.Locale,
+		})
+	voucherService := voucherservice.NewVoucherService(store.vouchers, notificationService.Issued)
+	// triggers read users from the repository, the user service runs them
+	// once a user is created
+	triggerService := triggerservice.NewTriggerService(store.triggers, store.users, offerService, voucherService)
+	userService := userservice.NewUserService(store.users, triggerService.UserCreated)
+	if cacheStore != nil {
+		userService = userservice.NewCachedUserService(userService,
+			cache.New("users", cacheStore, config.Cache.TTL))
+	}
+	// rewards are issued with the plain voucher service, redemptions
+	// anywhere else go through the rewarding one
+	referralService := referralservice.NewReferralService(store.referrals, userService, offerService, voucherService,
+		referralservice.Policy{
+			Offer:        config.Referral.Offer,
+			Validity:     config.Referral.VoucherValidity,
+			DomainLimit:  config.Referral.DomainLimit,
+			DomainWindow: config.Referral.DomainWindow,
+		})
+	voucherService = referralservice.NewRewardingVoucherService(voucherService, referralService)
+	lifecycle := lifecycleservice.NewLifecycleService(offerService, userService, voucherService)
+	privacyService := privacyservice.NewPrivacyService(userService, offerService, voucherService,
+		lifecycle, store.privacy, store.referrals, store.notifications)
+	statsService := statsservice.NewStatsService(offerService, store.vouchers, store.stats)
+	walletService := walletservice.NewWalletService(userService, offerService, voucherService)
+	rateProvider, err := newRateProvider(config.Rates)
+	if err != nil {
+		appLogger.Error("loading exchange rates", logger.Fields{"error": err})
+		os.Exit(1)
+	}
+	redemptionService := redemptionservice.NewRedemptionService(userService, offerService, voucherService, rateProvider)
+	giftCardService := giftcardservice.NewGiftCardService(store.giftCards)
+	ring, err := config.Signing.Ring()
+	if err != nil {
+		appLogger.Error("setting up signing", logger.Fields{"error": err})
+		os.Exit(1)
+	}
+	tokenService := tokenservice.NewTokenService(voucherService, store.offline, ring)
+	printService := printservice.NewPrintService(voucherService, offerService, tokenService)
 
 	/*
 		====== Setup controllers ========
 	*/
-	userCtl := controllers.NewUserController(userService)
+	userCtl := controllers.NewUserController(userService, lifecycle, referralService)
 	voucherCtl := controllers.NewVoucherController(voucherService, userService, offerService)
-	offerCtl := controllers.NewOfferController(offerService, userService, voucherService)
+	offerCtl := controllers.NewOfferController(offerService, userService, voucherService, lifecycle)
+	privacyCtl := controllers.NewPrivacyController(privacyService)
+	statsCtl := controllers.NewStatsController(statsService)
+	walletCtl := controllers.NewWalletController(walletService)
+	tenantCtl := controllers.NewTenantController(tenantService)
+	redemptionCtl := controllers.NewRedemptionController(redemptionService)
+	giftCardCtl := controllers.NewGiftCardController(giftCardService)
+	referralCtl := controllers.NewReferralController(referralService)
+	triggerCtl := controllers.NewTriggerController(triggerService)
+	notificationCtl := controllers.NewNotificationController(notificationService, voucherService)
+	printCtl := controllers.NewPrintController(printService)
+	tokenCtl := controllers.NewTokenController(tokenService)
 
 	/*
 		====== Setup middlewares ========
 	*/
-	router.Use(gin.Logger())
-	router.Use(gin.Recovery())
+	router.Use(middlewares.Logger(appLogger))
+	router.Use(middlewares.Recovery())
+	router.Use(middlewares.Metrics())
+
+	if store.db != nil {
+		if err := metrics.RegisterDB(store.db.DB(), store.dialect); err != nil {
+			panic(err)
+		}
+	}
+	metrics.Registry.MustRegister(metrics.NewExpiredCollector(func() (map[uint]int64, error) {
+		// offer ids are unique across tenants, so the counts merge
+		all := map[uint]int64{}
+		err := forEachTenant(context.Background(), tenantService, func(ctx context.Context, t *tenant.Tenant) error {
+			expired, err := voucherService.ExpiredByOffer(ctx)
+			for offerID, n := range expired {
+				all[offerID] = n
+			}
+			return err
+		})
+		return all, err
+	}))
+	tenantScope := middlewares.Tenant(tenantService, config.Tenant.BaseDomain)
+
+	rl := config.RateLimit
+	limitStore := ratelimit.NewMemoryStore()
+	if rl.Backend == "postgres" {
+		limitStore = ratelimit.NewPostgresStore(store.db)
+	}
+	limiter := ratelimit.NewLimiter(limitStore, ratelimit.LockoutPolicy{
+		MaxFailures:  rl.MaxFailures,
+		BaseCooldown: rl.LockoutBase,
+		MaxCooldown:  rl.LockoutMax,
+	})
+	limits := ratelimit.Limits{
+		IP:     ratelimit.Limit{Rate: rl.IPRate, Burst: rl.IPBurst},
+		APIKey: ratelimit.Limit{Rate: rl.APIKeyRate, Burst: rl.APIKeyBurst},
+		Email:  ratelimit.Limit{Rate: rl.EmailRate, Burst: rl.EmailBurst},
+	}
+	redeemGuard := ratelimit.NewGuard(limiter, limits)
+	redeemLimit := middlewares.RateLimit(limiter,
+		middlewares.Rule{Scope: "ip", Key: middlewares.ByIP, Limit: limits.IP},
+		middlewares.Rule{Scope: "api_key", Key: middlewares.ByAPIKey, Limit: limits.APIKey},
+		middlewares.Rule{Scope: "email", Key: middlewares.ByEmail, Limit: limits.Email},
+	)
+
+	/*
+		====== Setup workers ============
+	*/
+	workers := worker.NewGroup(
+		worker.New("ratelimit-cleanup", 10*time.Minute, func(ctx context.Context) error {
+			return limiter.Cleanup(rl.LockoutMax)
+		}),
+		worker.New("purge", config.Retention.PurgeInterval, func(ctx context.Context) error {
+			before := time.Now().Add(-config.Retention.Period)
+			return forEachTenant(ctx, tenantService, func(ctx context.Context, t *tenant.Tenant) error {
+				purged, err := lifecycle.Purge(ctx, before)
+				if purged != (lifecycleservice.Purged{}) {
+					appLogger.Info("purged deleted records", logger.Fields{
+						"tenant": t.Slug, "offers": purged.Offers, "users": purged.Users, "vouchers": purged.Vouchers,
+					})
+				}
+				return err
+			})
+		}),
+		// yesterday is refreshed too so it catches up on activity from just
+		// before midnight
+		worker.New("stats-rollup", 15*time.Minute, func(ctx context.Context) error {
+			today := stats.Day(time.Now())
+			return forEachTenant(ctx, tenantService, func(ctx context.Context, t *tenant.Tenant) error {
+				return statsService.Refresh(ctx, today.Add(-24*time.Hour), today.Add(24*time.Hour))
+			})
+		}),
+		// a run issues at most one voucher per user and year, so running
+		// several times a day only catches up on failures
+		worker.New("birthday-triggers", config.Trigger.Interval, func(ctx context.Context) error {
+			today := time.Now().UTC()
+			return forEachTenant(ctx, tenantService, func(ctx context.Context, t *tenant.Tenant) error {
+				issued, err := triggerService.Birthdays(ctx, today)
+				if issued > 0 {
+					appLogger.Info("issued birthday vouchers", logger.Fields{"tenant": t.Slug, "vouchers": issued})
+				}
+				return err
+			})
+		}),
+		worker.New("notifications", config.Notify.Interval, func(ctx context.Context) error {
+			return forEachTenant(ctx, tenantService, func(ctx context.Context, t *tenant.Tenant) error {
+				_, err := notificationService.Deliver(ctx)
+				return err
+			})
+		}),
+	)
+	// rotation is up to the operator, who is only reminded once the
+	// active key outlived SIGNING_ROTATE_AFTER without a successor
+	if ring != nil && config.Signing.RotateAfter > 0 {
+		workers.Add(worker.New("signing-rotation", time.Hour, func(ctx context.Context) error {
+			if k, due := ring.RotationDue(time.Now(), config.Signing.RotateAfter); due {
+				appLogger.Warn("signing key due for rotation", logger.Fields{
+					"key_id": k.ID, "activated_at": k.ActivateAt, "rotate_after": config.Signing.RotateAfter.String(),
+				})
+			}
+			return nil
+		}))
+	}
+
+	/*
+		====== Setup health checks ======
+	*/
+	checker := health.NewChecker()
+	if store.db != nil {
+		checker.Add("database", func(ctx context.Context) error { return store.db.DB().PingContext(ctx) })
+		checker.Add("migrations", func(ctx context.Context) error { return store.migrateErr })
+	}
+	checker.Add("workers", workers.Check)
+	healthCtl := controllers.NewHealthController(checker)
 
 	/*
 		====== Setup routes =============
 	*/
 	router.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	router.GET("/healthz", healthCtl.Liveness)
+	router.GET("/readyz", healthCtl.Readiness)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	api := router.Group("/api", tenantScope)
+
+	v1 := api.Group("/v1")
+
+	v1.GET("/tenants", tenantCtl.List)
+	v1.POST("/tenants", tenantCtl.Post)
+	v1.GET("/api-keys", tenantCtl.ListKeys)
+	v1.POST("/api-keys", tenantCtl.PostKey)
+	v1.POST("/api-keys/:id/rotate", tenantCtl.RotateKey)
+	v1.DELETE("/api-keys/:id", tenantCtl.DeleteKey)
+
+	v1.GET("/offers", offerCtl.List)
+	v1.POST("/offers", offerCtl.Post)
+	v1.GET("/offers/:id", offerCtl.GetByID)
+	v1.PATCH("/offers/:id", offerCtl.Patch)
+	v1.DELETE("/offers/:id", offerCtl.Delete)
+	v1.POST("/offers/:id/restore", offerCtl.Restore)
+	v1.POST("/offers/:id/vouchers", offerCtl.IssueVouchers)
+	v1.GET("/offers/:id/stats", statsCtl.Offer)
+	v1.GET("/offers/:id/stats/series", statsCtl.Series)
+	v1.GET("/offers/:id/sheet", printCtl.Sheet)
+	v1.GET("/stats/daily", statsCtl.Daily)
 
-	api := router.Group("/api")
+	v1.GET("/users", userCtl.List)
+	v1.POST("/users", userCtl.Post)
+	v1.GET("/users/:id", userCtl.GetByID)
+	v1.PATCH("/users/:id", userCtl.Patch)
+	v1.DELETE("/users/:id", userCtl.Delete)
+	v1.POST("/users/:id/restore", userCtl.Restore)
+	v1.GET("/users/:id/data-export", privacyCtl.Export)
+	v1.POST("/users/:id/erasure", privacyCtl.Erase)
+	v1.GET("/users/:id/privacy-requests", privacyCtl.Records)
+	v1.GET("/users/:id/wallet", walletCtl.Get)
+	v1.GET("/users/:id/referral", referralCtl.GetCode)
+	v1.GET("/referrals/stats", referralCtl.Stats)
 
-	api.GET("/offer/:id", offerCtl.GetByID)
-	api.POST("/offer/create", offerCtl.Create)
-	api.POST("/offer/update", offerCtl.Update)
-	api.POST("/offer/generate_vouchers", offerCtl.GenerateVouchers)
+	v1.GET("/triggers", triggerCtl.List)
+	v1.POST("/triggers", triggerCtl.Post)
+	v1.GET("/triggers/:id", triggerCtl.GetByID)
+	v1.PATCH("/triggers/:id", triggerCtl.Patch)
+	v1.DELETE("/triggers/:id", triggerCtl.Delete)
+	v1.GET("/notification-templates", notificationCtl.ListTemplates)
+	v1.POST("/notification-templates", notificationCtl.PostTemplate)
+	v1.GET("/notification-templates/:id", notificationCtl.GetTemplate)
+	v1.PATCH("/notification-templates/:id", notificationCtl.PatchTemplate)
+	v1.DELETE("/notification-templates/:id", notificationCtl.DeleteTemplate)
 
-	api.GET("/voucher/:id", voucherCtl.GetByID)
-	api.POST("/voucher/create", voucherCtl.Create)
-	api.POST("/voucher/redeem", voucherCtl.Redeem)
+	v1.POST("/vouchers", voucherCtl.Post)
+	// looking a code up tells whether it exists, so it is limited like
+	// redeeming one
+	lookupLockout := middlewares.LookupLockout(limiter)
+	v1.GET("/vouchers/:code", redeemLimit, lookupLockout, voucherCtl.GetByCode)
+	v1.GET("/vouchers/:code/deliveries", redeemLimit, lookupLockout, notificationCtl.Deliveries)
+	v1.GET("/vouchers/:code/qr.png", redeemLimit, lookupLockout, printCtl.QR)
+	v1.GET("/vouchers/:code/barcode.png", redeemLimit, lookupLockout, printCtl.Barcode)
+	v1.GET("/vouchers/:code/token", redeemLimit, lookupLockout, tokenCtl.Token)
+	v1.POST("/tokens/verify", tokenCtl.Verify)
+	v1.GET("/tokens/key", tokenCtl.Key)
+	v1.GET("/tokens/keys", tokenCtl.Keys)
+	v1.GET("/offline-redemptions", tokenCtl.ListOffline)
+	v1.POST("/offline-redemptions", tokenCtl.Reconcile)
+	v1.POST("/vouchers/:code/redeem", redeemLimit, middlewares.RedeemLockout(limiter), voucherCtl.RedeemCode)
+	v1.POST("/redemptions", redeemLimit, middlewares.RedeemLockout(limiter), redemptionCtl.Post)
+	api.POST("/vouchers/apply", redeemLimit, middlewares.RedeemLockout(limiter), redemptionCtl.Apply)
 
-	api.POST("/register", userCtl.Register)
-	api.GET("/list_users", userCtl.ListUsers)
+	// a card code is all it takes to spend a balance, so routes taking one
+	// are rate limited like redemptions
+	v1.POST("/giftcards", giftCardCtl.Post)
+	v1.GET("/giftcards/:code", redeemLimit, giftCardCtl.GetByCode)
+	v1.GET("/giftcards/:code/ledger", redeemLimit, giftCardCtl.Ledger)
+	v1.POST("/giftcards/:code/debit", redeemLimit, giftCardCtl.Debit)
+	v1.POST("/giftcards/:code/top-up", redeemLimit, giftCardCtl.TopUp)
+	v1.POST("/giftcards/:code/refund", redeemLimit, giftCardCtl.Refund)
+
+	// Legacy routes, kept until clients move to /api/v1
+	api.GET("/offer/:id", middlewares.Deprecated("/api/v1/offers/{id}"), offerCtl.GetByID)
+	api.POST("/offer/create", middlewares.Deprecated("/api/v1/offers"), offerCtl.Create)
+	api.POST("/offer/update", middlewares.Deprecated("/api/v1/offers/{id}"), offerCtl.Update)
+	api.POST("/offer/generate_vouchers", middlewares.Deprecated("/api/v1/offers/{id}/vouchers"), offerCtl.GenerateVouchers)
+
+	api.GET("/voucher/:id", middlewares.Deprecated("/api/v1/vouchers/{code}"), voucherCtl.GetByID)
+	api.POST("/voucher/create", middlewares.Deprecated("/api/v1/vouchers"), voucherCtl.Create)
+	api.POST("/voucher/redeem", middlewares.Deprecated("/api/v1/vouchers/{code}/redeem"),
+		redeemLimit, middlewares.RedeemLockout(limiter), voucherCtl.Redeem)
+
+	api.POST("/register", middlewares.Deprecated("/api/v1/users"), userCtl.Register)
+	api.GET("/list_users", middlewares.Deprecated("/api/v1/users"), userCtl.ListUsers)
 
 	user := api.Group("/user")
-	//user.GET("/:id", userCtl.GetByID)
-	user.GET("/:email", userCtl.GetByEmail)
+	user.GET("/:email", middlewares.Deprecated("/api/v1/users?email={email}"), userCtl.GetByEmail)
+
+	router.POST("/graphql", tenantScope, gin.WrapH(graphqlserver.New(graphqlserver.Services{
+		Offers:   offerService,
+		Vouchers: voucherService,
+		Users:    userService,
+		Guard:    redeemGuard,
+	})))
 
 	// Run
-	// port := fmt.Sprintf(":%s", viper.Get("APP_PORT"))
-	port := fmt.Sprintf(":%s", config.Port)
-	router.Run(port)
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%s", config.Port),
+		Handler:      router,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+	}
+
+	var rpc *rpcServer
+	if config.GRPC.Enabled() {
+		grpcSrv, grpcHealth := grpcserver.New(grpcserver.Services{
+			Offers:   offerService,
+			Vouchers: voucherService,
+			Users:    userService,
+			Tenants:  tenantService,
+			Guard:    redeemGuard,
+		}, config.GRPC.Keys(), appLogger)
+		rpc = &rpcServer{addr: fmt.Sprintf(":%s", config.GRPC.Port), srv: grpcSrv, health: grpcHealth}
+	}
+	serve(srv, rpc, checker, workers, config.ShutdownTimeout, appLogger)
+}
+
+// forEachTenant calls fn with a context scoped to every tenant in turn. It
+// goes on past failing tenants and returns the first error.
+func forEachTenant(ctx context.Context, tenants tenantservice.TenantService, fn func(context.Context, *tenant.Tenant) error) error {
+	all, err := tenants.List(ctx)
+	if err != nil {
+		return err
+	}
+	var first error
+	for _, t := range all {
+		if err := fn(tenant.NewContext(ctx, t.ID), t); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// newRateProvider returns the rates of RATES_SOURCE, nil when there are none
+func newRateProvider(config configs.RatesConfig) (rates.RateProvider, error) {
+	switch config.Source {
+	case configs.RatesFile:
+		return rates.LoadFile(config.File)
+	case configs.RatesHTTP:
+		return rates.NewHTTP(config.URL, config.TTL, config.Timeout), nil
+	}
+	return nil, nil
+}
+
+// newNotifier returns the notifier of every channel NOTIFY_EMAIL and
+// NOTIFY_SMS enable
+func newNotifier(config configs.NotifyConfig) notifications.Channels {
+	channels := notifications.Channels{}
+	for channel, backend := range map[string]string{
+		notification.ChannelEmail: config.Email,
+		notification.ChannelSMS:   config.SMS,
+	} {
+		switch backend {
+		case configs.NotifySMTP:
+			channels[channel] = notifications.NewSMTP(config.SMTPAddr, config.SMTPFrom,
+				config.SMTPUsername, config.SMTPPassword.Value(), config.Timeout)
+		case configs.NotifyHTTP:
+			channels[channel] = notifications.NewHTTP(config.HTTPURL, config.HTTPToken.Value(), config.Timeout)
+		case configs.NotifyFile:
+			channels[channel] = notifications.NewFile(config.File)
+		case configs.NotifyLog:
+			channels[channel] = notifications.NewLog()
+		}
+	}
+	return channels
 }
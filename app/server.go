@@ -0,0 +1,84 @@
+package app
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/health"
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/common/worker"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+)
+
+// rpcServer is the gRPC server served next to the REST API
+type rpcServer struct {
+	addr   string
+	srv    *grpc.Server
+	health *grpchealth.Server
+}
+
+// serve runs srv, rpc when not nil, and the background workers until SIGINT
+// or SIGTERM. On shutdown it fails readiness first, then drains in-flight
+// requests, such as redemptions, and stops the workers, waiting at most
+// timeout.
+func serve(srv *http.Server, rpc *rpcServer, checker *health.Checker, workers *worker.Group, timeout time.Duration, l *logger.Logger) {
+	workers.Start(logger.NewContext(context.Background(), l))
+
+	errc := make(chan error, 2)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	l.Info("listening", logger.Fields{"addr": srv.Addr})
+
+	if rpc != nil {
+		ln, err := net.Listen("tcp", rpc.addr)
+		if err != nil {
+			errc <- err
+		} else {
+			go func() {
+				errc <- rpc.srv.Serve(ln)
+			}()
+			l.Info("listening", logger.Fields{"addr": rpc.addr, "protocol": "grpc"})
+		}
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	select {
+	case sig := <-quit:
+		l.Info("shutting down", logger.Fields{"signal": sig.String()})
+	case err := <-errc:
+		l.Error("server stopped", logger.Fields{"error": err})
+	}
+
+	checker.Shutdown()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if rpc != nil {
+		rpc.health.Shutdown()
+		go func() {
+			<-ctx.Done()
+			rpc.srv.Stop()
+		}()
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		l.Error("draining requests", logger.Fields{"error": err})
+	}
+	if rpc != nil {
+		rpc.srv.GracefulStop()
+	}
+	if err := workers.Stop(ctx); err != nil {
+		l.Error("stopping workers", logger.Fields{"error": err})
+	}
+	l.Info("stopped")
+}
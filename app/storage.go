@@ -0,0 +1,217 @@
+package app
+
+import (
+	"github.com/deepinbytes/go_voucher/common/cache"
+	"github.com/deepinbytes/go_voucher/common/keys"
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/common/ratelimit"
+	"github.com/deepinbytes/go_voucher/configs"
+	"github.com/deepinbytes/go_voucher/domain/apikey"
+	"github.com/deepinbytes/go_voucher/domain/giftcard"
+	"github.com/deepinbytes/go_voucher/domain/notification"
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/offline"
+	"github.com/deepinbytes/go_voucher/domain/privacy"
+	"github.com/deepinbytes/go_voucher/domain/referral"
+	"github.com/deepinbytes/go_voucher/domain/stats"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/domain/trigger"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/apikeyrepo"
+	"github.com/deepinbytes/go_voucher/repositories/giftcardrepo"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+	"github.com/deepinbytes/go_voucher/repositories/notificationrepo"
+	"github.com/deepinbytes/go_voucher/repositories/offerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/offlinerepo"
+	"github.com/deepinbytes/go_voucher/repositories/privacyrepo"
+	"github.com/deepinbytes/go_voucher/repositories/referralrepo"
+	"github.com/deepinbytes/go_voucher/repositories/statsrepo"
+	"github.com/deepinbytes/go_voucher/repositories/tenantrepo"
+	"github.com/deepinbytes/go_voucher/repositories/triggerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/userrepo"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite" // For SQLite setup
+	_ "github.com/lib/pq"                      // For Postgres setup
+)
+
+// storage holds the repositories of the configured backend. db is nil for
+// the memory backend, which keeps everything in process.
+type storage struct {
+	db         *gorm.DB
+	dialect    string
+	migrateErr error
+
+	users    userrepo.Repo
+	offers   offerrepo.Repo
+	vouchers voucherrepo.Repo
+	privacy  privacyrepo.Repo
+	stats    statsrepo.Repo
+	tenants  tenantrepo.Repo
+	apiKeys  apikeyrepo.Repo
+
+	giftCards giftcardrepo.Repo
+	referrals referralrepo.Repo
+	triggers  triggerrepo.Repo
+
+	notifications notificationrepo.Repo
+	offline       offlinerepo.Repo
+}
+
+// openStorage connects to the backend selected by STORAGE_BACKEND and
+// migrates it. A failed migration is kept in migrateErr for the readiness
+// probe rather than returned.
+func openStorage(config configs.Config, l *logger.Logger) (*storage, error) {
+	if config.Storage.Backend == configs.StorageMemory {
+		db := memdb.New()
+		return &storage{
+			users:    userrepo.NewMemoryUserRepo(db),
+			offers:   offerrepo.NewMemoryOfferRepo(db),
+			vouchers: voucherrepo.NewMemoryVoucherRepo(db),
+			privacy:  privacyrepo.NewMemoryPrivacyRepo(db),
+			stats:    statsrepo.NewMemoryStatsRepo(db),
+			tenants:  tenantrepo.NewMemoryTenantRepo(db),
+			apiKeys:  apikeyrepo.NewMemoryAPIKeyRepo(db),
+
+			giftCards: giftcardrepo.NewMemoryGiftCardRepo(db),
+			referrals: referralrepo.NewMemoryReferralRepo(db),
+			triggers:  triggerrepo.NewMemoryTriggerRepo(db),
+
+			notifications: notificationrepo.NewMemoryNotificationRepo(db),
+			offline:       offlinerepo.NewMemoryOfflineRepo(db),
+		}, nil
+	}
+
+	dialect, args := config.Postgres.Dialect(), config.Postgres.GetPostgresConnectionInfo()
+	if config.Storage.Backend == configs.StorageSQLite {
+		dialect, args = "sqlite3", config.Storage.SQLitePath
+	}
+	db, err := gorm.Open(dialect, args)
+	if err != nil {
+		return nil, err
+	}
+	db.SetLogger(logger.NewGormLogger(l))
+	if config.Storage.Backend == configs.StoragePostgres {
+		db.DB().SetMaxOpenConns(config.Postgres.MaxOpenConns)
+		db.DB().SetMaxIdleConns(config.Postgres.MaxIdleConns)
+		db.DB().SetConnMaxLifetime(config.Postgres.ConnMaxLifetime)
+	}
+
+	// Migration
+	// db.DropTableIfExists(&user.User{})
+	migrateErr := migrate(db, dialect)
+
+	return &storage{
+		db:         db,
+		dialect:    dialect,
+		migrateErr: migrateErr,
+		users:      userrepo.NewUserRepo(db),
+		offers:     offerrepo.NewOfferRepo(db),
+		vouchers:   voucherrepo.NewVoucherRepo(db),
+		privacy:    privacyrepo.NewPrivacyRepo(db),
+		stats:      statsrepo.NewStatsRepo(db),
+		tenants:    tenantrepo.NewTenantRepo(db),
+		apiKeys:    apikeyrepo.NewAPIKeyRepo(db),
+		giftCards:  giftcardrepo.NewGiftCardRepo(db),
+		referrals:  referralrepo.NewReferralRepo(db),
+		triggers:   triggerrepo.NewTriggerRepo(db),
+
+		notifications: notificationrepo.NewNotificationRepo(db),
+		offline:       offlinerepo.NewOfflineRepo(db),
+	}, nil
+}
+
+// migrate creates the tables and the default tenant. Rows from before
+// tenants get the default tenant through the column default; the unique
+// indexes they had across all tenants are replaced by per tenant ones.
+// API keys kept in plain text with the tenants are moved to the hashed
+// ones.
+func migrate(db *gorm.DB, dialect string) error {
+	if err := db.AutoMigrate(&tenant.Tenant{}, &user.User{}, &offer.Offer{}, &voucher.Voucher{}, &privacy.Record{},
+		&stats.Daily{}, &ratelimit.BucketRecord{}, &ratelimit.LockoutRecord{}, &giftcard.Card{}, &giftcard.Entry{},
+		&referral.Code{}, &referral.Referral{}, &trigger.Trigger{}, &trigger.Issuance{},
+		&notification.Template{}, &notification.Delivery{}, &offline.Redemption{}, &apikey.Key{}).Error; err != nil {
+		return err
+	}
+	for table, index := range map[string]string{"users": "uix_users_email", "offers": "uix_offers_name",
+		"vouchers": "uix_vouchers_code", "tenants": "uix_tenants_api_key"} {
+		if !db.Dialect().HasIndex(table, index) {
+			continue
+		}
+		if err := db.Table(table).RemoveIndex(index).Error; err != nil {
+			return err
+		}
+	}
+	if err := hashLegacyKeys(db); err != nil {
+		return err
+	}
+
+	var count int
+	if err := db.Model(&tenant.Tenant{}).Where("id = ?", tenant.DefaultID).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+	// the default tenant has no API key, it is used without one
+	def := tenant.Tenant{Model: gorm.Model{ID: tenant.DefaultID}, Name: "Default", Slug: tenant.DefaultSlug}
+	if err := db.Create(&def).Error; err != nil {
+		return err
+	}
+	if dialect == "postgres" {
+		// the id was set by hand, move the sequence past it
+		return db.Exec("SELECT setval(pg_get_serial_sequence('tenants', 'id'), MAX(id)) FROM tenants").Error
+	}
+	return nil
+}
+
+// hashLegacyKeys moves the keys of the tenants to the API keys, hashed,
+// and empties their plain text column
+func hashLegacyKeys(db *gorm.DB) error {
+	var tenants []*tenant.Tenant
+	// deleted tenants too, no key is left in plain text
+	if err := db.Unscoped().Where("api_key <> ''").Find(&tenants).Error; err != nil {
+		return err
+	}
+	for _, t := range tenants {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			k := &apikey.Key{
+				TenantID: t.ID,
+				Name:     "legacy",
+				Prefix:   keys.Prefix(t.LegacyAPIKey),
+				Hash:     keys.Hash(t.LegacyAPIKey),
+			}
+			if err := tx.Create(k).Error; err != nil {
+				return err
+			}
+			return tx.Table("tenants").Where("id = ?", t.ID).UpdateColumn("api_key", "").Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close releases the database connection, if any
+func (s *storage) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// newCacheStore returns the store of CACHE_BACKEND, nil when caching is off
+func newCacheStore(config configs.CacheConfig) cache.Store {
+	switch config.Backend {
+	case configs.CacheMemory:
+		return cache.NewLRU(config.Size)
+	case configs.CacheRedis:
+		return cache.NewRedis(cache.RedisOptions{
+			Addr:     config.RedisAddr,
+			Password: config.RedisPassword.Value(),
+			DB:       config.RedisDB,
+			Timeout:  config.RedisTimeout,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Package barcodes renders codes as QR codes and linear barcodes for
+// scanners
+package barcodes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/ean"
+	"github.com/boombuler/barcode/qr"
+)
+
+// Formats of linear barcodes
+const (
+	Code128 = "code128"
+	EAN     = "ean"
+)
+
+// Formats lists the linear barcode formats
+var Formats = []string{Code128, EAN}
+
+var (
+	// ErrFormat is returned for an unknown linear barcode format
+	ErrFormat = fmt.Errorf("format must be one of %s", strings.Join(Formats, ", "))
+	// ErrEncode is returned, wrapped, for content a format cannot hold
+	ErrEncode = errors.New("cannot encode")
+	// ErrSize is returned, wrapped, for images too small for the content
+	ErrSize = errors.New("image too small")
+)
+
+const (
+	// qrQuietZone is the blank border around QR codes, in modules
+	qrQuietZone = 4
+	// linearQuietZone is the blank space left and right of linear
+	// barcodes, in modules
+	linearQuietZone = 10
+)
+
+// QR encodes content as a QR code PNG of at most size pixels square. Error
+// correction is medium, so about 15% of the code may be damaged.
+func QR(content string, size int) ([]byte, error) {
+	bc, err := qr.Encode(content, qr.M, qr.Auto)
+	if err != nil {
+		return nil, fmt.Errorf("%w qr: %v", ErrEncode, err)
+	}
+	modules := bc.Bounds().Dx() + 2*qrQuietZone
+	scale := size / modules
+	if scale < 1 {
+		return nil, fmt.Errorf("%w: the qr code needs %d pixels", ErrSize, modules)
+	}
+	return encode(draw(bc, scale, scale, qrQuietZone))
+}
+
+// Linear encodes content as a Code 128 or EAN barcode PNG of at most width
+// by height pixels. EAN holds 7, 8, 12 or 13 digits, the last of 8 and 13
+// being the check digit.
+func Linear(format, content string, width, height int) ([]byte, error) {
+	var bc barcode.Barcode
+	var err error
+	switch format {
+	case Code128:
+		bc, err = code128.Encode(content)
+	case EAN:
+		if strings.Trim(content, "0123456789") != "" {
+			err = errors.New("only digits")
+		} else {
+			bc, err = ean.Encode(content)
+		}
+	default:
+		return nil, ErrFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrEncode, format, err)
+	}
+
+	modules := bc.Bounds().Dx() + 2*linearQuietZone
+	scale := width / modules
+	if scale < 1 || height < 1 {
+		return nil, fmt.Errorf("%w: the barcode needs %d pixels", ErrSize, modules)
+	}
+	return encode(draw(bc, scale, height, linearQuietZone))
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// draw scales every module of bc to scaleX by scaleY pixels, leaving quiet
+// modules blank around it. Linear barcodes are one module high, so their
+// quiet zone is only left and right.
+func draw(bc barcode.Barcode, scaleX, scaleY, quiet int) *image.Gray {
+	b := bc.Bounds()
+	quietY := quiet
+	if b.Dy() == 1 {
+		quietY = 0
+	}
+	img := image.NewGray(image.Rect(0, 0, (b.Dx()+2*quiet)*scaleX, (b.Dy()+2*quietY)*scaleY))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			if color.GrayModel.Convert(bc.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y >= 0x80 {
+				continue
+			}
+			for py := 0; py < scaleY; py++ {
+				row := img.PixOffset((x+quiet)*scaleX, (y+quietY)*scaleY+py)
+				for px := 0; px < scaleX; px++ {
+					img.Pix[row+px] = 0
+				}
+			}
+		}
+	}
+	return img
+}
+
+func encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,85 @@
+package barcodes
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, b []byte) image.Image {
+	img, err := png.Decode(bytes.NewReader(b))
+	require.Nil(t, err)
+	return img
+}
+
+func black(img image.Image, x, y int) bool {
+	r, _, _, _ := img.At(x, y).RGBA()
+	return r < 0x8000
+}
+
+func TestQR(t *testing.T) {
+	b, err := QR("SPRING20", 256)
+	require.Nil(t, err)
+	img := decode(t, b)
+
+	// version 1 is 21 modules, 29 with the quiet zone, so 8 pixels each
+	assert.Equal(t, image.Rect(0, 0, 232, 232), img.Bounds())
+	want, err := qr.Encode("SPRING20", qr.M, qr.Auto)
+	require.Nil(t, err)
+	for y := 0; y < 29; y++ {
+		for x := 0; x < 29; x++ {
+			in := x >= 4 && x < 25 && y >= 4 && y < 25
+			assert.Equal(t, in && black(want, x-4, y-4), black(img, x*8+3, y*8+3), "module %d,%d", x, y)
+		}
+	}
+
+	_, err = QR("SPRING20", 28)
+	assert.True(t, errors.Is(err, ErrSize), "got %v", err)
+	assert.Equal(t, "image too small: the qr code needs 29 pixels", err.Error())
+}
+
+func TestLinear(t *testing.T) {
+	t.Run("Code 128", func(t *testing.T) {
+		b, err := Linear(Code128, "SPRING20", 400, 80)
+		require.Nil(t, err)
+		img := decode(t, b)
+
+		want, err := code128.Encode("SPRING20")
+		require.Nil(t, err)
+		modules := want.Bounds().Dx() + 20
+		scale := 400 / modules
+		assert.Equal(t, image.Rect(0, 0, modules*scale, 80), img.Bounds())
+		for x := 0; x < modules; x++ {
+			in := x >= 10 && x < modules-10
+			assert.Equal(t, in && black(want, x-10, 0), black(img, x*scale, 40), "module %d", x)
+		}
+	})
+
+	t.Run("EAN", func(t *testing.T) {
+		b, err := Linear(EAN, "400638133393", 300, 60)
+		require.Nil(t, err)
+		// 95 modules of EAN-13 and the quiet zone, 2 pixels each
+		assert.Equal(t, image.Rect(0, 0, 230, 60), decode(t, b).Bounds())
+
+		for _, code := range []string{"SPRING20", "4006381333932", "12345"} {
+			_, err = Linear(EAN, code, 300, 60)
+			assert.True(t, errors.Is(err, ErrEncode), "%s: got %v", code, err)
+		}
+	})
+
+	t.Run("Rejects unknown formats and small images", func(t *testing.T) {
+		_, err := Linear("upc", "SPRING20", 400, 80)
+		assert.Equal(t, ErrFormat, err)
+		_, err = Linear(Code128, "SPRING20", 40, 80)
+		assert.True(t, errors.Is(err, ErrSize), "got %v", err)
+		_, err = Linear(Code128, "", 400, 80)
+		assert.True(t, errors.Is(err, ErrEncode), "got %v", err)
+	})
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/common/metrics"
+)
+
+// Cache reads through a Store, encoding values as JSON. Concurrent misses
+// of the same key share one load so an expired hot key only hits the
+// database once per process, and TTLs are jittered so keys cached together
+// do not all expire together.
+type Cache struct {
+	name  string
+	store Store
+	ttl   time.Duration
+
+	mu     sync.Mutex
+	flight map[string]*call
+	// gen changes on every invalidation, loads started before it are not
+	// written back so they cannot resurrect stale values
+	gen uint64
+}
+
+type call struct {
+	done  chan struct{}
+	value []byte
+	err   error
+}
+
+// New will instantiate a Cache, name labels its metrics
+func New(name string, store Store, ttl time.Duration) *Cache {
+	return &Cache{
+		name:   name,
+		store:  store,
+		ttl:    ttl,
+		flight: make(map[string]*call),
+	}
+}
+
+// Fetch decodes the value of key into dst, calling load on a miss and
+// caching its result. Errors of load are returned as is and never cached;
+// errors of the store are logged and the value is loaded instead.
+func (c *Cache) Fetch(ctx context.Context, key string, dst interface{}, load func() (interface{}, error)) error {
+	value, ok, err := c.store.Get(ctx, key)
+	switch {
+	case err != nil:
+		c.record("error")
+		logger.FromContext(ctx).Warn("cache get failed", logger.Fields{"cache": c.name, "key": key, "error": err})
+	case ok:
+		if err := json.Unmarshal(value, dst); err == nil {
+			c.record("hit")
+			return nil
+		}
+		c.record("error")
+	default:
+		c.record("miss")
+	}
+
+	value, err = c.load(ctx, key, load)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(value, dst)
+}
+
+// Invalidate drops keys, call it after every write of the cached data
+func (c *Cache) Invalidate(ctx context.Context, keys ...string) {
+	c.mu.Lock()
+	c.gen++
+	for _, key := range keys {
+		delete(c.flight, key)
+	}
+	c.mu.Unlock()
+
+	if err := c.store.Delete(ctx, keys...); err != nil {
+		c.record("error")
+		logger.FromContext(ctx).Error("cache invalidation failed", logger.Fields{"cache": c.name, "keys": keys, "error": err})
+	}
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (c *Cache) load(ctx context.Context, key string, load func() (interface{}, error)) ([]byte, error) {
+	c.mu.Lock()
+	if f, ok := c.flight[key]; ok {
+		c.mu.Unlock()
+		<-f.done
+		return f.value, f.err
+	}
+	f := &call{done: make(chan struct{})}
+	c.flight[key] = f
+	gen := c.gen
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		if c.flight[key] == f {
+			delete(c.flight, key)
+		}
+		c.mu.Unlock()
+		close(f.done)
+	}()
+
+	v, err := load()
+	if err != nil {
+		f.err = err
+		return nil, err
+	}
+	if f.value, f.err = json.Marshal(v); f.err != nil {
+		return nil, f.err
+	}
+	c.mu.Lock()
+	stale := gen != c.gen
+	c.mu.Unlock()
+	if stale {
+		return f.value, nil
+	}
+	if err := c.store.Set(ctx, key, f.value, c.jitter()); err != nil {
+		c.record("error")
+		logger.FromContext(ctx).Warn("cache set failed", logger.Fields{"cache": c.name, "key": key, "error": err})
+	}
+	return f.value, nil
+}
+
+// jitter spreads the TTL by up to -10%
+func (c *Cache) jitter() time.Duration {
+	return c.ttl - time.Duration(rand.Int63n(int64(c.ttl)/10+1))
+}
+
+func (c *Cache) record(result string) {
+	metrics.CacheRequests.WithLabelValues(c.name, result).Inc()
+}
@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type item struct {
+	Name string
+}
+
+func count(name, result string) float64 {
+	return testutil.ToFloat64(metrics.CacheRequests.WithLabelValues(name, result))
+}
+
+func TestFetch(t *testing.T) {
+	t.Run("Loads on a miss and serves hits", func(t *testing.T) {
+		c := New("fetch", NewLRU(10), time.Minute)
+		hits, misses := count("fetch", "hit"), count("fetch", "miss")
+		loads := 0
+		load := func() (interface{}, error) {
+			loads++
+			return &item{Name: "a"}, nil
+		}
+
+		for i := 0; i < 3; i++ {
+			var got item
+			assert.Nil(t, c.Fetch(ctx, "k", &got, load))
+			assert.Equal(t, "a", got.Name)
+		}
+		assert.Equal(t, 1, loads)
+		assert.Equal(t, hits+2, count("fetch", "hit"))
+		assert.Equal(t, misses+1, count("fetch", "miss"))
+	})
+
+	t.Run("Does not cache errors", func(t *testing.T) {
+		c := New("errors", NewLRU(10), time.Minute)
+		expected := errors.New("record not found")
+		loads := 0
+		load := func() (interface{}, error) {
+			loads++
+			return nil, expected
+		}
+
+		var got item
+		assert.Equal(t, expected, c.Fetch(ctx, "k", &got, load))
+		assert.Equal(t, expected, c.Fetch(ctx, "k", &got, load))
+		assert.Equal(t, 2, loads)
+	})
+
+	t.Run("Shares one load between concurrent misses", func(t *testing.T) {
+		c := New("stampede", NewLRU(10), time.Minute)
+		var loads int32
+		release := make(chan struct{})
+		load := func() (interface{}, error) {
+			atomic.AddInt32(&loads, 1)
+			<-release
+			return &item{Name: "a"}, nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var got item
+				assert.Nil(t, c.Fetch(ctx, "k", &got, load))
+				assert.Equal(t, "a", got.Name)
+			}()
+		}
+		for {
+			c.mu.Lock()
+			_, waiting := c.flight["k"]
+			c.mu.Unlock()
+			if waiting {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	})
+
+	t.Run("Falls back to load when the store fails", func(t *testing.T) {
+		down := NewRedis(RedisOptions{Addr: "127.0.0.1:1", Timeout: 100 * time.Millisecond})
+		c := New("down", down, time.Minute)
+		errs := count("down", "error")
+
+		var got item
+		err := c.Fetch(ctx, "k", &got, func() (interface{}, error) { return &item{Name: "a"}, nil })
+
+		assert.Nil(t, err)
+		assert.Equal(t, "a", got.Name)
+		assert.Equal(t, errs+2, count("down", "error"))
+	})
+}
+
+func TestInvalidate(t *testing.T) {
+	t.Run("Drops cached values", func(t *testing.T) {
+		c := New("invalidate", NewLRU(10), time.Minute)
+		name := "a"
+		load := func() (interface{}, error) { return &item{Name: name}, nil }
+
+		var got item
+		c.Fetch(ctx, "k", &got, load)
+		name = "b"
+		c.Invalidate(ctx, "k")
+		c.Fetch(ctx, "k", &got, load)
+
+		assert.Equal(t, "b", got.Name)
+	})
+
+	t.Run("Does not write back loads started before", func(t *testing.T) {
+		c := New("race", NewLRU(10), time.Minute)
+		load := func() (interface{}, error) {
+			c.Invalidate(ctx, "k")
+			return &item{Name: "old"}, nil
+		}
+
+		var got item
+		c.Fetch(ctx, "k", &got, load)
+		_, ok, _ := c.store.Get(ctx, "k")
+
+		assert.Equal(t, "old", got.Name)
+		assert.False(t, ok)
+	})
+}
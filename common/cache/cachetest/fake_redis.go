@@ -0,0 +1,213 @@
+// Package cachetest provides an in-process Redis fake for tests of the
+// Redis cache store.
+package cachetest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeRedis serves the subset of Redis used by cache.Redis: PING, AUTH,
+// SELECT, GET, SET with PX and DEL. Every key lives in one database.
+type FakeRedis struct {
+	password string
+
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu       sync.Mutex
+	data     map[string]entry
+	commands map[string]int
+	conns    map[net.Conn]struct{}
+}
+
+type entry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// NewFakeRedis starts a FakeRedis on a random local port. A non-empty
+// password must be sent with AUTH before any other command.
+func NewFakeRedis(password string) (*FakeRedis, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	f := &FakeRedis{
+		password: password,
+		ln:       ln,
+		data:     make(map[string]entry),
+		commands: make(map[string]int),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	f.wg.Add(1)
+	go f.serve()
+	return f, nil
+}
+
+// Addr is the address to connect to
+func (f *FakeRedis) Addr() string {
+	return f.ln.Addr().String()
+}
+
+// Calls returns how often command was received
+func (f *FakeRedis) Calls(command string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.commands[strings.ToUpper(command)]
+}
+
+// Close stops the server and drops all connections
+func (f *FakeRedis) Close() {
+	f.ln.Close()
+	f.mu.Lock()
+	for c := range f.conns {
+		c.Close()
+	}
+	f.mu.Unlock()
+	f.wg.Wait()
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (f *FakeRedis) serve() {
+	defer f.wg.Done()
+	for {
+		c, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns[c] = struct{}{}
+		f.mu.Unlock()
+		f.wg.Add(1)
+		go f.handle(c)
+	}
+}
+
+func (f *FakeRedis) handle(c net.Conn) {
+	defer f.wg.Done()
+	defer func() {
+		f.mu.Lock()
+		delete(f.conns, c)
+		f.mu.Unlock()
+		c.Close()
+	}()
+
+	r, w := bufio.NewReader(c), bufio.NewWriter(c)
+	authed := f.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(w, "-ERR %v\r\n", err)
+				w.Flush()
+			}
+			return
+		}
+		cmd := strings.ToUpper(args[0])
+		if cmd != "AUTH" && !authed {
+			w.WriteString("-NOAUTH Authentication required.\r\n")
+		} else {
+			if cmd == "AUTH" {
+				authed = len(args) == 2 && args[1] == f.password
+			}
+			f.exec(w, cmd, args[1:], authed)
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (f *FakeRedis) exec(w *bufio.Writer, cmd string, args []string, authed bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands[cmd]++
+
+	switch {
+	case cmd == "AUTH" && !authed:
+		w.WriteString("-WRONGPASS invalid password\r\n")
+	case cmd == "AUTH" || cmd == "SELECT":
+		w.WriteString("+OK\r\n")
+	case cmd == "PING":
+		w.WriteString("+PONG\r\n")
+	case cmd == "GET" && len(args) == 1:
+		e, ok := f.data[args[0]]
+		if ok && !e.expiresAt.IsZero() && !time.Now().Before(e.expiresAt) {
+			delete(f.data, args[0])
+			ok = false
+		}
+		if !ok {
+			w.WriteString("$-1\r\n")
+			return
+		}
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(e.value), e.value)
+	case cmd == "SET" && (len(args) == 2 || len(args) == 4):
+		e := entry{value: args[1]}
+		if len(args) == 4 {
+			ms, err := strconv.Atoi(args[3])
+			if strings.ToUpper(args[2]) != "PX" || err != nil || ms <= 0 {
+				w.WriteString("-ERR syntax error\r\n")
+				return
+			}
+			e.expiresAt = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+		f.data[args[0]] = e
+		w.WriteString("+OK\r\n")
+	case cmd == "DEL" && len(args) > 0:
+		n := 0
+		for _, k := range args {
+			if _, ok := f.data[k]; ok {
+				delete(f.data, k)
+				n++
+			}
+		}
+		fmt.Fprintf(w, ":%d\r\n", n)
+	default:
+		fmt.Fprintf(w, "-ERR unknown command '%s'\r\n", cmd)
+	}
+}
+
+// readCommand reads a RESP array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("expected array, got %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid array length %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("expected bulk string, got %q", line)
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid bulk length %q", line)
+		}
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var ctx = context.Background()
+
+func TestLRU(t *testing.T) {
+	t.Run("Evicts the least recently used entry", func(t *testing.T) {
+		s := NewLRU(2)
+		s.Set(ctx, "a", []byte("1"), time.Minute)
+		s.Set(ctx, "b", []byte("2"), time.Minute)
+		s.Get(ctx, "a")
+		s.Set(ctx, "c", []byte("3"), time.Minute)
+
+		_, ok, _ := s.Get(ctx, "b")
+		assert.False(t, ok)
+		v, ok, _ := s.Get(ctx, "a")
+		assert.True(t, ok)
+		assert.Equal(t, []byte("1"), v)
+		_, ok, _ = s.Get(ctx, "c")
+		assert.True(t, ok)
+	})
+
+	t.Run("Expires entries after their TTL", func(t *testing.T) {
+		s := NewLRU(2).(*lruStore)
+		now := time.Now()
+		s.now = func() time.Time { return now }
+		s.Set(ctx, "a", []byte("1"), time.Minute)
+
+		now = now.Add(59 * time.Second)
+		_, ok, _ := s.Get(ctx, "a")
+		assert.True(t, ok)
+
+		now = now.Add(time.Second)
+		_, ok, _ = s.Get(ctx, "a")
+		assert.False(t, ok)
+		assert.Equal(t, 0, s.ll.Len())
+	})
+
+	t.Run("Deletes keys", func(t *testing.T) {
+		s := NewLRU(2)
+		s.Set(ctx, "a", []byte("1"), time.Minute)
+
+		assert.Nil(t, s.Delete(ctx, "a", "missing"))
+		_, ok, _ := s.Get(ctx, "a")
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/cache/cachetest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedis(t *testing.T) {
+	fake, err := cachetest.NewFakeRedis("secret")
+	require.Nil(t, err)
+	defer fake.Close()
+
+	r := NewRedis(RedisOptions{Addr: fake.Addr(), Password: "secret", DB: 2, PoolSize: 1})
+	defer r.Close()
+
+	t.Run("Sets, gets and deletes values", func(t *testing.T) {
+		assert.Nil(t, r.Ping(ctx))
+		assert.Nil(t, r.Set(ctx, "a", []byte("line\r\nbreak"), time.Minute))
+
+		v, ok, err := r.Get(ctx, "a")
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []byte("line\r\nbreak"), v)
+
+		assert.Nil(t, r.Delete(ctx, "a", "b"))
+		_, ok, err = r.Get(ctx, "a")
+		assert.Nil(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("Expires values", func(t *testing.T) {
+		assert.Nil(t, r.Set(ctx, "a", []byte("1"), time.Millisecond))
+		time.Sleep(5 * time.Millisecond)
+
+		_, ok, err := r.Get(ctx, "a")
+		assert.Nil(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("Reuses pooled connections", func(t *testing.T) {
+		auths := fake.Calls("AUTH")
+		for i := 0; i < 3; i++ {
+			r.Get(ctx, "a")
+		}
+		assert.Equal(t, auths, fake.Calls("AUTH"))
+	})
+
+	t.Run("Returns server errors", func(t *testing.T) {
+		bad := NewRedis(RedisOptions{Addr: fake.Addr(), Password: "wrong"})
+		defer bad.Close()
+
+		err := bad.Ping(ctx)
+		assert.EqualError(t, err, "redis: WRONGPASS invalid password")
+	})
+
+	t.Run("Returns network errors", func(t *testing.T) {
+		down := NewRedis(RedisOptions{Addr: "127.0.0.1:1", Timeout: 100 * time.Millisecond})
+
+		_, _, err := down.Get(ctx, "a")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Honours the context deadline", func(t *testing.T) {
+		c, cancel := context.WithTimeout(ctx, -time.Second)
+		defer cancel()
+
+		_, _, err := r.Get(c, "a")
+		assert.NotNil(t, err)
+		assert.Nil(t, r.Ping(ctx))
+	})
+}
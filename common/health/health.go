@@ -0,0 +1,70 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrShuttingDown is reported by every check once shutdown has started
+var ErrShuttingDown = errors.New("shutting down")
+
+// CheckFunc returns nil when the dependency it checks is usable
+type CheckFunc func(ctx context.Context) error
+
+// Checker runs named readiness checks
+type Checker struct {
+	mu           sync.RWMutex
+	names        []string
+	checks       map[string]CheckFunc
+	shuttingDown bool
+}
+
+// NewChecker will instantiate a Checker without checks
+func NewChecker() *Checker {
+	return &Checker{
+		checks: make(map[string]CheckFunc),
+	}
+}
+
+// Add registers a check under name, replacing any previous one
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = fn
+}
+
+// Shutdown marks the service as not ready so load balancers stop sending
+// traffic while in-flight requests drain
+func (c *Checker) Shutdown() {
+	c.mu.Lock()
+	c.shuttingDown = true
+	c.mu.Unlock()
+}
+
+// Check runs every check and returns the result of each, "ok" or the error
+// message, and whether all of them passed
+func (c *Checker) Check(ctx context.Context) (map[string]string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	results := make(map[string]string, len(c.names))
+	ready := true
+	for _, name := range c.names {
+		err := ErrShuttingDown
+		if !c.shuttingDown {
+			err = c.checks[name](ctx)
+		}
+		if err != nil {
+			results[name] = err.Error()
+			ready = false
+			continue
+		}
+		results[name] = "ok"
+	}
+	return results, ready
+}
@@ -0,0 +1,53 @@
+// Package keys manages the keys of the service: API keys, which are only
+// ever stored hashed, and the ring of signing keys, rotated on a schedule
+// and retired by ID.
+package keys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+)
+
+// HashPrefix marks hashed API keys in lists that may also hold plain
+// ones, such as GRPC_API_KEYS
+const HashPrefix = "sha256:"
+
+// PrefixSize is the length of the start of an API key kept in plain text
+// to tell keys apart
+const PrefixSize = 8
+
+// NewAPIKey returns a random API key, 48 hex digits
+func NewAPIKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Hash returns the hex SHA-256 of an API key. API keys are random, so
+// unlike passwords they need no salt or slow hash.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Prefix returns the start of key kept in plain text
+func Prefix(key string) string {
+	if len(key) < PrefixSize {
+		return key
+	}
+	return key[:PrefixSize]
+}
+
+// Matches reports whether key is stored, given either hashed with
+// HashPrefix or in plain text. The comparison takes constant time.
+func Matches(stored, key string) bool {
+	if strings.HasPrefix(stored, HashPrefix) {
+		stored, key = strings.ToLower(stored[len(HashPrefix):]), Hash(key)
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(key)) == 1
+}
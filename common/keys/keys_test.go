@@ -0,0 +1,182 @@
+package keys
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/signing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKey(t *testing.T) {
+	key, err := NewAPIKey()
+	require.Nil(t, err)
+	assert.Regexp(t, `^[0-9a-f]{48}$`, key)
+	other, err := NewAPIKey()
+	require.Nil(t, err)
+	assert.NotEqual(t, key, other)
+
+	assert.Equal(t, key[:PrefixSize], Prefix(key))
+	assert.Equal(t, "abc", Prefix("abc"))
+	// echo -n acme-key | sha256sum
+	assert.Equal(t, "afacab3575137afa4e00d9cbcafcb14c9ae25f779d964eb0ea5b2c4eb5dfd163", Hash("acme-key"))
+
+	for stored, want := range map[string]bool{
+		key:                                     true,
+		other:                                   false,
+		HashPrefix + Hash(key):                  true,
+		HashPrefix + strings.ToUpper(Hash(key)): true,
+		HashPrefix + Hash(other):                false,
+		Hash(key):                               false,
+		"":                                      false,
+	} {
+		assert.Equal(t, want, Matches(stored, key), stored)
+	}
+}
+
+func TestRing(t *testing.T) {
+	now := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	spec := func(id, algorithm string, activate, retire time.Time) Spec {
+		return Spec{
+			ID:         id,
+			Algorithm:  algorithm,
+			Key:        base64.StdEncoding.EncodeToString([]byte(strings.Repeat(id[:1], 32))),
+			ActivateAt: activate,
+			RetireAt:   retire,
+		}
+	}
+	var ks []*Key
+	for _, s := range []Spec{
+		spec("next", HMAC, now.Add(24*time.Hour), time.Time{}),
+		spec("current", Ed25519, now.Add(-30*24*time.Hour), time.Time{}),
+		spec("previous", HMAC, now.Add(-90*24*time.Hour), now.Add(time.Hour)),
+		spec("old", Ed25519, time.Time{}, now.Add(-time.Hour)),
+	} {
+		k, err := NewKey(s)
+		require.Nil(t, err)
+		ks = append(ks, k)
+	}
+	ring, err := NewRing(ks...)
+	require.Nil(t, err)
+
+	t.Run("Orders keys by activation", func(t *testing.T) {
+		var ids []string
+		for _, k := range ring.Keys() {
+			ids = append(ids, k.ID)
+		}
+		assert.Equal(t, []string{"old", "previous", "current", "next"}, ids)
+	})
+
+	t.Run("Signs with the last activated key", func(t *testing.T) {
+		for at, want := range map[time.Time]string{
+			now:                           "current",
+			now.Add(24 * time.Hour):       "next",
+			now.Add(-60 * 24 * time.Hour): "previous",
+		} {
+			k, err := ring.Active(at)
+			require.Nil(t, err)
+			assert.Equal(t, want, k.ID, at)
+		}
+	})
+
+	t.Run("Verifies with keys not retired", func(t *testing.T) {
+		for id, want := range map[string]bool{
+			"next":     true,
+			"current":  true,
+			"previous": true,
+			"old":      false,
+			"unknown":  false,
+			"":         false,
+		} {
+			_, ok := ring.Verifier(id, now)
+			assert.Equal(t, want, ok, id)
+		}
+		_, ok := ring.Verifier("old", now.Add(-2*time.Hour))
+		assert.True(t, ok)
+		_, ok = ring.Verifier("previous", now.Add(time.Hour))
+		assert.False(t, ok)
+
+		current, _ := ring.Active(now)
+		v, ok := ring.Verifier("current", now)
+		require.True(t, ok)
+		payload, err := v.Verify(current.Signer.Sign("SPRING20"))
+		assert.Nil(t, err)
+		assert.Equal(t, "SPRING20", payload)
+	})
+
+	t.Run("Returns the status of keys", func(t *testing.T) {
+		var statuses []string
+		for _, k := range ring.Keys() {
+			statuses = append(statuses, ring.Status(k, now))
+		}
+		assert.Equal(t, []string{StatusRetired, StatusVerifying, StatusActive, StatusPending}, statuses)
+	})
+
+	t.Run("Tells when a rotation is due", func(t *testing.T) {
+		_, due := ring.RotationDue(now, 7*24*time.Hour)
+		assert.False(t, due, "next is pending")
+
+		k, due := ring.RotationDue(now.Add(30*24*time.Hour), 7*24*time.Hour)
+		assert.True(t, due)
+		assert.Equal(t, "next", k.ID)
+
+		_, due = ring.RotationDue(now.Add(30*24*time.Hour), 60*24*time.Hour)
+		assert.False(t, due)
+	})
+
+	t.Run("Maps no key ID to the default key", func(t *testing.T) {
+		s, err := signing.NewHMAC([]byte(strings.Repeat("d", 32)))
+		require.Nil(t, err)
+		legacy, err := NewRing(&Key{ID: DefaultID, Signer: s})
+		require.Nil(t, err)
+
+		_, ok := legacy.Verifier("", now)
+		assert.True(t, ok)
+		_, due := legacy.RotationDue(now, time.Nanosecond)
+		assert.False(t, due, "keys active from the start have no age")
+	})
+
+	t.Run("Requires unique key IDs", func(t *testing.T) {
+		_, err := NewRing(ks[0], ks[0])
+		assert.Equal(t, ErrKeyID, err)
+		_, err = NewRing(&Key{})
+		assert.Equal(t, ErrKeyID, err)
+
+		_, err = NewRing()
+		assert.Nil(t, err)
+		_, err = (&Ring{}).Active(now)
+		assert.Equal(t, ErrNoActiveKey, err)
+	})
+}
+
+func TestParseSpecs(t *testing.T) {
+	specs, err := ParseSpecs(`[{"id":"2020-03","algorithm":"ed25519","key":"` +
+		base64.StdEncoding.EncodeToString(make([]byte, 32)) + `","activate_at":"2020-03-01T00:00:00Z"}]`)
+	require.Nil(t, err)
+	require.Len(t, specs, 1)
+	assert.Equal(t, "2020-03", specs[0].ID)
+	assert.Equal(t, time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), specs[0].ActivateAt)
+	assert.True(t, specs[0].RetireAt.IsZero())
+
+	k, err := NewKey(specs[0])
+	require.Nil(t, err)
+	assert.Equal(t, "Ed25519", k.Signer.Algorithm())
+
+	_, err = ParseSpecs(`{"id":"a"}`)
+	assert.NotNil(t, err)
+
+	for _, bad := range []Spec{
+		{ID: "a", Algorithm: HMAC, Key: "!"},
+		{ID: "a", Algorithm: HMAC, Key: "c2hvcnQ="},
+		{ID: "a", Algorithm: "rsa", Key: specs[0].Key},
+		{ID: "a", Algorithm: Ed25519, Key: "c2hvcnQ="},
+	} {
+		_, err := NewKey(bad)
+		assert.NotNil(t, err, bad.Algorithm)
+		assert.True(t, strings.HasPrefix(err.Error(), "key a: "), err.Error())
+	}
+}
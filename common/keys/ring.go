@@ -0,0 +1,182 @@
+package keys
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/signing"
+)
+
+// DefaultID is the ID of the key of SIGNING_SECRET or SIGNING_PRIVATE_KEY,
+// and the key of signed payloads naming none
+const DefaultID = "default"
+
+// Algorithms of signing keys
+const (
+	Ed25519 = "ed25519"
+	HMAC    = "hmac"
+)
+
+// Statuses of a signing key at a time
+const (
+	// StatusPending keys are published before they sign, so terminals
+	// know them by the time they do
+	StatusPending = "pending"
+	// StatusActive is the key signing, the last one activated
+	StatusActive = "active"
+	// StatusVerifying keys were replaced and only verify
+	StatusVerifying = "verifying"
+	// StatusRetired keys verify nothing signed after their retirement
+	StatusRetired = "retired"
+)
+
+var (
+	// ErrNoActiveKey is returned when no key of the ring is active
+	ErrNoActiveKey = errors.New("no signing key is active")
+	// ErrKeyID is returned for rings with a key ID twice or an empty one
+	ErrKeyID = errors.New("signing key IDs must be unique and not empty")
+)
+
+// Spec describes a signing key of SIGNING_KEYS
+type Spec struct {
+	ID string `json:"id"`
+	// Algorithm is ed25519 or hmac
+	Algorithm string `json:"algorithm"`
+	// Key is the base64 Ed25519 seed or private key, or HMAC secret
+	Key string `json:"key"`
+	// ActivateAt is when the key starts signing, zero for always
+	ActivateAt time.Time `json:"activate_at"`
+	// RetireAt is when the key stops verifying, zero for never
+	RetireAt time.Time `json:"retire_at"`
+}
+
+// ParseSpecs decodes a JSON array of specs
+func ParseSpecs(raw string) ([]Spec, error) {
+	var specs []Spec
+	if err := json.Unmarshal([]byte(raw), &specs); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+// Key is a signing key of a ring
+type Key struct {
+	ID         string
+	Signer     signing.Signer
+	ActivateAt time.Time
+	RetireAt   time.Time
+}
+
+// NewKey will instantiate the Key of spec
+func NewKey(spec Spec) (*Key, error) {
+	secret, err := base64.StdEncoding.DecodeString(spec.Key)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: key must be base64", spec.ID)
+	}
+	var s signing.Signer
+	switch strings.ToLower(spec.Algorithm) {
+	case Ed25519:
+		s, err = signing.NewEd25519(secret)
+	case HMAC:
+		s, err = signing.NewHMAC(secret)
+	default:
+		err = fmt.Errorf("algorithm must be %s or %s, got %q", Ed25519, HMAC, spec.Algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %v", spec.ID, err)
+	}
+	return &Key{ID: spec.ID, Signer: s, ActivateAt: spec.ActivateAt, RetireAt: spec.RetireAt}, nil
+}
+
+// Ring holds the signing keys, the active one signs and all of them verify
+// until they retire. It is safe for concurrent use.
+type Ring struct {
+	keys []*Key
+}
+
+// NewRing will instantiate a Ring of keys
+func NewRing(keys ...*Key) (*Ring, error) {
+	seen := map[string]bool{}
+	for _, k := range keys {
+		if k.ID == "" || seen[k.ID] {
+			return nil, ErrKeyID
+		}
+		seen[k.ID] = true
+	}
+	sorted := append([]*Key(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ActivateAt.Before(sorted[j].ActivateAt) })
+	return &Ring{keys: sorted}, nil
+}
+
+// Keys returns the keys of the ring in the order they activate
+func (r *Ring) Keys() []*Key {
+	return append([]*Key(nil), r.keys...)
+}
+
+// Active returns the key signing at now, the last one activated that is
+// not retired
+func (r *Ring) Active(now time.Time) (*Key, error) {
+	for i := len(r.keys) - 1; i >= 0; i-- {
+		if k := r.keys[i]; !k.ActivateAt.After(now) && !k.retired(now) {
+			return k, nil
+		}
+	}
+	return nil, ErrNoActiveKey
+}
+
+// Verifier returns the key with id unless it was retired at, DefaultID
+// when id is empty
+func (r *Ring) Verifier(id string, at time.Time) (signing.Verifier, bool) {
+	if id == "" {
+		id = DefaultID
+	}
+	for _, k := range r.keys {
+		if k.ID == id && !k.retired(at) {
+			return k.Signer, true
+		}
+	}
+	return nil, false
+}
+
+// Status returns the status of k at now
+func (r *Ring) Status(k *Key, now time.Time) string {
+	switch {
+	case k.retired(now):
+		return StatusRetired
+	case k.ActivateAt.After(now):
+		return StatusPending
+	}
+	if active, err := r.Active(now); err == nil && active.ID == k.ID {
+		return StatusActive
+	}
+	return StatusVerifying
+}
+
+// RotationDue returns the active key when it was activated more than
+// maxAge before now and no key is pending to replace it. Keys active from
+// the start have no age.
+func (r *Ring) RotationDue(now time.Time, maxAge time.Duration) (*Key, bool) {
+	active, err := r.Active(now)
+	if err != nil || active.ActivateAt.IsZero() || now.Sub(active.ActivateAt) <= maxAge {
+		return nil, false
+	}
+	for _, k := range r.keys {
+		if k.ActivateAt.After(now) && !k.retired(k.ActivateAt) {
+			return nil, false
+		}
+	}
+	return active, true
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (k *Key) retired(at time.Time) bool {
+	return !k.RetireAt.IsZero() && !at.Before(k.RetireAt)
+}
@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// gormLogger adapts Logger to gorm's logger interface. Query variables are
+// never logged since they may hold personal data.
+type gormLogger struct {
+	l *Logger
+}
+
+// NewGormLogger returns a gorm logger writing queries at debug level and
+// database errors at warn level to l
+func NewGormLogger(l *Logger) interface{ Print(v ...interface{}) } {
+	return gormLogger{l: l}
+}
+
+func (g gormLogger) Print(v ...interface{}) {
+	if len(v) == 0 {
+		return
+	}
+	switch v[0] {
+	case "sql":
+		if len(v) < 6 {
+			return
+		}
+		f := Fields{"source": v[1], "sql": v[3], "rows": v[5]}
+		if d, ok := v[2].(time.Duration); ok {
+			f["duration_ms"] = float64(d) / float64(time.Millisecond)
+		}
+		g.l.Debug("query", f)
+	case "log":
+		if len(v) > 2 {
+			g.l.Warn("database error", Fields{"source": v[1], "error": fmt.Sprint(v[2:]...)})
+		}
+	}
+}
+
+// DB returns db logging its queries to the logger of ctx when that logger
+// has debug enabled, and db unchanged otherwise
+func DB(ctx context.Context, db *gorm.DB) *gorm.DB {
+	l := FromContext(ctx)
+	if !l.Enabled(DebugLevel) {
+		return db
+	}
+	tx := db.New()
+	tx.SetLogger(gormLogger{l: l})
+	return tx.LogMode(true)
+}
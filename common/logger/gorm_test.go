@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDB(t *testing.T) {
+	sqlDB, mock, _ := sqlmock.New()
+	db, _ := gorm.Open("postgres", sqlDB)
+	defer db.Close()
+
+	t.Run("Logs queries at debug level without vars", func(t *testing.T) {
+		l, buf := newTestLogger(DebugLevel)
+		ctx := NewContext(context.Background(), l)
+		mock.ExpectQuery(`SELECT`).WithArgs("alice@cc.cc").
+			WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+
+		var n int
+		DB(ctx, db).Raw("SELECT 1 AS n WHERE $1 <> ''", "alice@cc.cc").Row().Scan(&n)
+
+		e := entries(buf)
+		assert.Len(t, e, 1)
+		assert.Equal(t, "query", e[0]["msg"])
+		assert.Equal(t, "debug", e[0]["level"])
+		assert.NotContains(t, buf.String(), "alice@cc.cc")
+	})
+
+	t.Run("Leaves db alone above debug level", func(t *testing.T) {
+		l, _ := newTestLogger(InfoLevel)
+		ctx := NewContext(context.Background(), l)
+
+		assert.Equal(t, db, DB(ctx, db))
+	})
+}
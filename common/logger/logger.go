@@ -0,0 +1,169 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log entry
+type Level int
+
+// Log levels, from most to least verbose
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var levelNames = map[Level]string{
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	WarnLevel:  "warn",
+	ErrorLevel: "error",
+}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel returns the level named s, defaulting to InfoLevel
+func ParseLevel(s string) Level {
+	for l, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return l
+		}
+	}
+	return InfoLevel
+}
+
+// Fields are the structured key/values attached to an entry
+type Fields map[string]interface{}
+
+// Logger writes one JSON object per entry
+type Logger struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	level  Level
+	fields Fields
+	now    func() time.Time
+}
+
+// New will instantiate a Logger writing entries of at least level to out
+func New(out io.Writer, level Level) *Logger {
+	return &Logger{
+		mu:     &sync.Mutex{},
+		out:    out,
+		level:  level,
+		fields: Fields{},
+		now:    time.Now,
+	}
+}
+
+var std = New(os.Stdout, InfoLevel)
+
+// Default returns the process wide logger
+func Default() *Logger {
+	return std
+}
+
+// SetDefault replaces the process wide logger
+func SetDefault(l *Logger) {
+	std = l
+}
+
+// With returns a child logger adding fields to every entry
+func (l *Logger) With(fields Fields) *Logger {
+	merged := make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	child := *l
+	child.fields = merged
+	return &child
+}
+
+// Enabled reports whether entries of level are written
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+// Debug logs msg at DebugLevel
+func (l *Logger) Debug(msg string, fields ...Fields) {
+	l.log(DebugLevel, msg, fields)
+}
+
+// Info logs msg at InfoLevel
+func (l *Logger) Info(msg string, fields ...Fields) {
+	l.log(InfoLevel, msg, fields)
+}
+
+// Warn logs msg at WarnLevel
+func (l *Logger) Warn(msg string, fields ...Fields) {
+	l.log(WarnLevel, msg, fields)
+}
+
+// Error logs msg at ErrorLevel
+func (l *Logger) Error(msg string, fields ...Fields) {
+	l.log(ErrorLevel, msg, fields)
+}
+
+func (l *Logger) log(level Level, msg string, extra []Fields) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	entry := make(Fields, len(l.fields)+4)
+	for k, v := range l.fields {
+		entry[k] = v
+	}
+	for _, f := range extra {
+		for k, v := range f {
+			entry[k] = v
+		}
+	}
+	for k, v := range entry {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		entry[k] = redactField(k, v)
+	}
+	entry["time"] = l.now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = Redact(msg)
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		b = []byte(fmt.Sprintf(`{"level":"error","msg":"logger: %s"}`, err))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(append(b, '\n'))
+}
+
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx carrying l
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx or the default logger
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(ctxKey{}).(*Logger); ok {
+			return l
+		}
+	}
+	return std
+}
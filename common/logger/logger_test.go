@@ -0,0 +1,124 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLogger(level Level) (*Logger, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	l := New(buf, level)
+	l.now = func() time.Time { return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC) }
+	return l, buf
+}
+
+func entries(buf *bytes.Buffer) []map[string]interface{} {
+	var out []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		e := map[string]interface{}{}
+		json.Unmarshal([]byte(line), &e)
+		out = append(out, e)
+	}
+	return out
+}
+
+func TestLogger(t *testing.T) {
+	t.Run("Writes JSON entries with fields", func(t *testing.T) {
+		l, buf := newTestLogger(DebugLevel)
+
+		l.With(Fields{"request_id": "abc"}).Info("hello", Fields{"status": 200, "error": errors.New("Nop")})
+
+		assert.EqualValues(t, []map[string]interface{}{{
+			"time":       "2020-01-01T00:00:00Z",
+			"level":      "info",
+			"msg":        "hello",
+			"request_id": "abc",
+			"status":     float64(200),
+			"error":      "Nop",
+		}}, entries(buf))
+	})
+
+	t.Run("Skips entries below level", func(t *testing.T) {
+		l, buf := newTestLogger(WarnLevel)
+
+		l.Debug("debug")
+		l.Info("info")
+		l.Warn("warn")
+		l.Error("error")
+
+		got := entries(buf)
+		assert.Len(t, got, 2)
+		assert.Equal(t, "warn", got[0]["level"])
+		assert.Equal(t, "error", got[1]["level"])
+	})
+
+	t.Run("With does not leak into parent", func(t *testing.T) {
+		l, buf := newTestLogger(InfoLevel)
+
+		l.With(Fields{"a": 1})
+		l.Info("parent")
+
+		_, ok := entries(buf)[0]["a"]
+		assert.False(t, ok)
+	})
+
+	t.Run("Parses levels", func(t *testing.T) {
+		assert.Equal(t, DebugLevel, ParseLevel("DEBUG"))
+		assert.Equal(t, ErrorLevel, ParseLevel("error"))
+		assert.Equal(t, InfoLevel, ParseLevel(""))
+	})
+}
+
+func TestContext(t *testing.T) {
+	t.Run("Carries the logger", func(t *testing.T) {
+		l, _ := newTestLogger(InfoLevel)
+		ctx := NewContext(context.Background(), l)
+
+		assert.Equal(t, l, FromContext(ctx))
+	})
+
+	t.Run("Falls back to default", func(t *testing.T) {
+		assert.Equal(t, Default(), FromContext(context.Background()))
+	})
+}
+
+func TestRedact(t *testing.T) {
+	t.Run("Masks secret fields", func(t *testing.T) {
+		l, buf := newTestLogger(InfoLevel)
+
+		l.Info("config", Fields{"DB_PASSWORD": "hunter2", "authorization": "Bearer x", "user": "dev",
+			"SIGNING_PRIVATE_KEY": "c2VlZA"})
+
+		e := entries(buf)[0]
+		assert.Equal(t, Redacted, e["DB_PASSWORD"])
+		assert.Equal(t, Redacted, e["SIGNING_PRIVATE_KEY"])
+		assert.Equal(t, Redacted, e["authorization"])
+		assert.Equal(t, "dev", e["user"])
+	})
+
+	t.Run("Masks secrets inside strings", func(t *testing.T) {
+		cases := map[string]string{
+			"host=pg user=dev password=hunter2 dbname=x": "host=pg user=dev password=[REDACTED] dbname=x",
+			"postgres://dev:hunter2@pg:5432/x":           "postgres://dev:[REDACTED]@pg:5432/x",
+			"token: abc123":                              "token: [REDACTED]",
+			"private_key=c2VlZA":                         "private_key=[REDACTED]",
+			"nothing to hide":                            "nothing to hide",
+			`password='it\'s secret' dbname=x`:           "password=[REDACTED] dbname=x",
+			`password='back\\slash' dbname=x`:            "password=[REDACTED] dbname=x",
+			`secret="say \"hi\"" user=dev`:               "secret=[REDACTED] user=dev",
+		}
+		for in, want := range cases {
+			assert.Equal(t, want, Redact(in))
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces secret values in log entries
+const Redacted = "[REDACTED]"
+
+var secretKeys = []string{"password", "secret", "token", "authorization", "api_key", "apikey", "private_key"}
+
+// secretPairs matches key=value and key: value pairs of secret keys, as found
+// in connection strings and DSNs. Quoted values may hold backslash escaped
+// quotes, as written by libpq style connection strings.
+var secretPairs = regexp.MustCompile(`(?i)((?:password|secret|token|api_key|apikey|private_key)\s*[=:]\s*)("(?:\\.|[^"\\])*"|'(?:\\.|[^'\\])*'|[^\s&;,]+)`)
+
+// secretURL matches the password of user:password@host URLs
+var secretURL = regexp.MustCompile(`(://[^:/@\s]+:)([^@\s]+)(@)`)
+
+// Redact masks secrets embedded in s
+func Redact(s string) string {
+	s = secretPairs.ReplaceAllString(s, "${1}"+Redacted)
+	return secretURL.ReplaceAllString(s, "${1}"+Redacted+"${3}")
+}
+
+// IsSecretKey reports whether a field or env var named key holds a secret
+func IsSecretKey(key string) bool {
+	k := strings.ToLower(key)
+	for _, s := range secretKeys {
+		if strings.Contains(k, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactField(key string, v interface{}) interface{} {
+	if IsSecretKey(key) {
+		return Redacted
+	}
+	if s, ok := v.(string); ok {
+		return Redact(s)
+	}
+	return v
+}
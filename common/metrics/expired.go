@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var expiredDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "vouchers_expired"),
+	"Unused vouchers past their expiry time, per offer.",
+	[]string{"offer_id"}, nil,
+)
+
+// ExpiredFunc returns the number of expired vouchers per offer
+type ExpiredFunc func() (map[uint]int64, error)
+
+type expiredCollector struct {
+	fn ExpiredFunc
+}
+
+// NewExpiredCollector will instantiate a collector querying expired vouchers
+// on every scrape
+func NewExpiredCollector(fn ExpiredFunc) prometheus.Collector {
+	return &expiredCollector{
+		fn: fn,
+	}
+}
+
+func (e *expiredCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- expiredDesc
+}
+
+func (e *expiredCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := e.fn()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(expiredDesc, err)
+		return
+	}
+	for offerID, n := range counts {
+		ch <- prometheus.MustNewConstMetric(expiredDesc, prometheus.GaugeValue,
+			float64(n), strconv.FormatUint(uint64(offerID), 10))
+	}
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiredCollector(t *testing.T) {
+	t.Run("Reports expired vouchers per offer", func(t *testing.T) {
+		c := NewExpiredCollector(func() (map[uint]int64, error) {
+			return map[uint]int64{1: 3, 2: 5}, nil
+		})
+
+		expected := `
+# HELP voucher_vouchers_expired Unused vouchers past their expiry time, per offer.
+# TYPE voucher_vouchers_expired gauge
+voucher_vouchers_expired{offer_id="1"} 3
+voucher_vouchers_expired{offer_id="2"} 5
+`
+		err := testutil.CollectAndCompare(c, strings.NewReader(expected))
+		assert.Nil(t, err)
+	})
+
+	t.Run("Reports query errors", func(t *testing.T) {
+		c := NewExpiredCollector(func() (map[uint]int64, error) {
+			return nil, errors.New("Nop")
+		})
+
+		_, err := testutil.CollectAndLint(c)
+		assert.NotNil(t, err)
+	})
+}
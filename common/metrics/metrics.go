@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "voucher"
+
+var (
+	// Registry holds every metric exposed on /metrics
+	Registry = prometheus.NewRegistry()
+
+	// HTTPRequestDuration observes request latency by route
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// VouchersIssued counts created vouchers per offer
+	VouchersIssued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "vouchers_issued_total",
+		Help:      "Vouchers issued per offer.",
+	}, []string{"offer_id"})
+
+	// VouchersRedeemed counts redeemed vouchers per offer
+	VouchersRedeemed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "vouchers_redeemed_total",
+		Help:      "Vouchers redeemed per offer.",
+	}, []string{"offer_id"})
+
+	// VouchersReversed counts redemptions undone per offer
+	VouchersReversed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "vouchers_reversed_total",
+		Help:      "Voucher redemptions reversed per offer.",
+	}, []string{"offer_id"})
+
+	// RedemptionFailures counts rejected redemptions by reason
+	RedemptionFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redemption_failures_total",
+		Help:      "Rejected voucher redemptions by reason.",
+	}, []string{"reason"})
+
+	// GenerationDuration observes how long bulk voucher generation takes
+	GenerationDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "generation_duration_seconds",
+		Help:      "Duration of voucher generation jobs.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 8),
+	})
+
+	// RateLimitBlocked counts requests rejected by rate limits or lockouts
+	RateLimitBlocked = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ratelimit_blocked_total",
+		Help:      "Requests blocked by rate limiting, by scope.",
+	}, []string{"scope"})
+
+	// CacheRequests counts cache lookups by cache and result (hit, miss, error)
+	CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups by cache and result.",
+	}, []string{"cache", "result"})
+
+	// Notifications counts delivery attempts by channel and result (sent,
+	// retry, failed, skipped)
+	Notifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_total",
+		Help:      "Voucher notification attempts by channel and result.",
+	}, []string{"channel", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		VouchersIssued,
+		VouchersRedeemed,
+		VouchersReversed,
+		RedemptionFailures,
+		GenerationDuration,
+		RateLimitBlocked,
+		CacheRequests,
+		Notifications,
+	)
+}
+
+// RegisterDB exposes connection pool stats of db
+func RegisterDB(db *sql.DB, name string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
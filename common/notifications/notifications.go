@@ -0,0 +1,114 @@
+// Package notifications sends messages to people, by email or SMS, through
+// interchangeable notifiers
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+)
+
+// ErrNoNotifier is returned for messages on a channel without a notifier
+var ErrNoNotifier = errors.New("no notifier for channel")
+
+// Message is one notification to one recipient. Channel is how it is sent,
+// e.g. "email" or "sms", To the address or phone number on it. SMS have no
+// Subject.
+type Message struct {
+	Channel string `json:"channel"`
+	To      string `json:"to"`
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body"`
+}
+
+// Notifier sends messages. Implementations must be safe for concurrent use.
+type Notifier interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// Channels sends every message with the notifier of its channel
+type Channels map[string]Notifier
+
+func (c Channels) Send(ctx context.Context, m Message) error {
+	n, ok := c[m.Channel]
+	if !ok {
+		return fmt.Errorf("%w %s", ErrNoNotifier, m.Channel)
+	}
+	return n.Send(ctx, m)
+}
+
+// Names lists the channels with a notifier, sorted
+func (c Channels) Names() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// File appends every message to a file as a line of JSON, for development
+// setups without a mail server
+type File struct {
+	path string
+	now  func() time.Time
+
+	mu sync.Mutex
+}
+
+// NewFile will instantiate a File notifier, the file is created on the
+// first message
+func NewFile(path string) *File {
+	return &File{
+		path: path,
+		now:  time.Now,
+	}
+}
+
+func (f *File) Send(ctx context.Context, m Message) error {
+	line, err := json.Marshal(struct {
+		Time time.Time `json:"time"`
+		Message
+	}{f.now().UTC(), m})
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Log writes every message to the log of the context instead of sending it,
+// for development setups
+type Log struct{}
+
+// NewLog will instantiate a Log notifier
+func NewLog() Log {
+	return Log{}
+}
+
+func (Log) Send(ctx context.Context, m Message) error {
+	logger.FromContext(ctx).Info("notification", logger.Fields{
+		"channel": m.Channel,
+		"to":      m.To,
+		"subject": m.Subject,
+		"body":    m.Body,
+	})
+	return nil
+}
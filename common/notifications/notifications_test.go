@@ -0,0 +1,86 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+type recorder struct {
+	sent []Message
+}
+
+func (r *recorder) Send(ctx context.Context, m Message) error {
+	r.sent = append(r.sent, m)
+	return nil
+}
+
+func TestChannels(t *testing.T) {
+	email, sms := &recorder{}, &recorder{}
+	channels := Channels{"email": email, "sms": sms}
+
+	require.Nil(t, channels.Send(ctx, Message{Channel: "sms", To: "+4915112345678", Body: "ABC"}))
+	assert.Empty(t, email.sent)
+	assert.Len(t, sms.sent, 1)
+	assert.Equal(t, []string{"email", "sms"}, channels.Names())
+
+	err := Channels{"email": email}.Send(ctx, Message{Channel: "sms"})
+	assert.True(t, errors.Is(err, ErrNoNotifier), "got %v", err)
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notifications")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "outbox.jsonl")
+
+	f := NewFile(path)
+	f.now = func() time.Time { return time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC) }
+	require.Nil(t, f.Send(ctx, Message{Channel: "email", To: "a@cc.cc", Subject: "Hi", Body: "ABC"}))
+	require.Nil(t, f.Send(ctx, Message{Channel: "sms", To: "+4915112345678", Body: "DEF"}))
+
+	b, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.JSONEq(t, `{"time":"2020-05-01T12:00:00Z","channel":"email","to":"a@cc.cc","subject":"Hi","body":"ABC"}`, lines[0])
+		assert.JSONEq(t, `{"time":"2020-05-01T12:00:00Z","channel":"sms","to":"+4915112345678","body":"DEF"}`, lines[1])
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	var got Message
+	var auth string
+	status := http.StatusAccepted
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	h := NewHTTP(srv.URL, "s3cret", time.Second)
+	m := Message{Channel: "sms", To: "+4915112345678", Body: "ABC"}
+	require.Nil(t, h.Send(ctx, m))
+	assert.Equal(t, m, got)
+	assert.Equal(t, "Bearer s3cret", auth)
+
+	status = http.StatusServiceUnavailable
+	err := h.Send(ctx, m)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "503")
+	}
+}
@@ -0,0 +1,242 @@
+// Package notificationstest provides an in-process SMTP server for tests of
+// the SMTP notifier.
+package notificationstest
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Mail is a message the FakeSMTP accepted
+type Mail struct {
+	From string
+	To   []string
+	// Data is the message as sent, headers and body
+	Data string
+}
+
+// FakeSMTP serves the subset of SMTP used by notifications.SMTP: EHLO,
+// HELO, AUTH PLAIN, MAIL, RCPT, DATA, RSET, NOOP and QUIT. It offers no
+// STARTTLS.
+type FakeSMTP struct {
+	username string
+	password string
+
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu       sync.Mutex
+	mails    []Mail
+	failures int
+	conns    map[net.Conn]struct{}
+}
+
+// NewFakeSMTP starts a FakeSMTP on a random local port. With a non-empty
+// username, mail is only accepted after AUTH PLAIN with the credentials.
+func NewFakeSMTP(username, password string) (*FakeSMTP, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	f := &FakeSMTP{
+		username: username,
+		password: password,
+		ln:       ln,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	f.wg.Add(1)
+	go f.serve()
+	return f, nil
+}
+
+// Addr is the address to connect to
+func (f *FakeSMTP) Addr() string {
+	return f.ln.Addr().String()
+}
+
+// Mails returns the messages accepted so far
+func (f *FakeSMTP) Mails() []Mail {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Mail(nil), f.mails...)
+}
+
+// Fail rejects the next n messages with a temporary error
+func (f *FakeSMTP) Fail(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = n
+}
+
+// Close stops the server and drops all connections
+func (f *FakeSMTP) Close() {
+	f.ln.Close()
+	f.mu.Lock()
+	for c := range f.conns {
+		c.Close()
+	}
+	f.mu.Unlock()
+	f.wg.Wait()
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (f *FakeSMTP) serve() {
+	defer f.wg.Done()
+	for {
+		c, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns[c] = struct{}{}
+		f.mu.Unlock()
+		f.wg.Add(1)
+		go f.handle(c)
+	}
+}
+
+func (f *FakeSMTP) handle(c net.Conn) {
+	defer f.wg.Done()
+	defer func() {
+		f.mu.Lock()
+		delete(f.conns, c)
+		f.mu.Unlock()
+		c.Close()
+	}()
+
+	r, w := bufio.NewReader(c), bufio.NewWriter(c)
+	reply := func(format string, args ...interface{}) bool {
+		fmt.Fprintf(w, format+"\r\n", args...)
+		return w.Flush() == nil
+	}
+	if !reply("220 fake ESMTP") {
+		return
+	}
+	authed := f.username == ""
+	var mail *Mail
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			verb, arg = line[:i], line[i+1:]
+		}
+
+		ok := true
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			ok = reply("250-fake") && reply("250 AUTH PLAIN")
+		case "HELO", "NOOP":
+			ok = reply("250 OK")
+		case "AUTH":
+			authed = f.auth(arg)
+			if authed {
+				ok = reply("235 Authentication succeeded")
+			} else {
+				ok = reply("535 Authentication failed")
+			}
+		case "MAIL":
+			if !authed {
+				ok = reply("530 Authentication required")
+				break
+			}
+			mail = &Mail{From: address(arg)}
+			ok = reply("250 OK")
+		case "RCPT":
+			if mail == nil {
+				ok = reply("503 MAIL first")
+				break
+			}
+			mail.To = append(mail.To, address(arg))
+			ok = reply("250 OK")
+		case "DATA":
+			if mail == nil || len(mail.To) == 0 {
+				ok = reply("503 RCPT first")
+				break
+			}
+			if !reply("354 End data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			data, err := readData(r)
+			if err != nil {
+				return
+			}
+			mail.Data = data
+			ok = f.accept(*mail, reply)
+			mail = nil
+		case "RSET":
+			mail = nil
+			ok = reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			ok = reply("502 Command not implemented")
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// auth checks the credentials of AUTH PLAIN
+func (f *FakeSMTP) auth(arg string) bool {
+	parts := strings.Fields(arg)
+	if len(parts) != 2 || strings.ToUpper(parts[0]) != "PLAIN" {
+		return false
+	}
+	b, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	creds := strings.Split(string(b), "\x00")
+	return len(creds) == 3 && creds[1] == f.username && creds[2] == f.password
+}
+
+func (f *FakeSMTP) accept(m Mail, reply func(string, ...interface{}) bool) bool {
+	f.mu.Lock()
+	if f.failures > 0 {
+		f.failures--
+		f.mu.Unlock()
+		return reply("451 Try again later")
+	}
+	f.mails = append(f.mails, m)
+	f.mu.Unlock()
+	return reply("250 OK queued")
+}
+
+// address returns the address of "FROM:<a@b.c>" and "TO:<a@b.c>"
+func address(arg string) string {
+	if i := strings.IndexByte(arg, '<'); i >= 0 {
+		if j := strings.IndexByte(arg[i:], '>'); j >= 0 {
+			return arg[i+1 : i+j]
+		}
+	}
+	return arg
+}
+
+// readData reads the lines of DATA up to the lone dot, undoing the dot
+// stuffing
+func readData(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if line == ".\r\n" {
+			return b.String(), nil
+		}
+		b.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
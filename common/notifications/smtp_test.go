@@ -0,0 +1,74 @@
+package notifications
+
+import (
+	"io/ioutil"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/notifications/notificationstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMTP(t *testing.T) {
+	srv, err := notificationstest.NewFakeSMTP("vouchers", "s3cret")
+	require.Nil(t, err)
+	defer srv.Close()
+
+	t.Run("Sends a plain text mail", func(t *testing.T) {
+		s := NewSMTP(srv.Addr(), "vouchers@example.com", "vouchers", "s3cret", time.Second)
+		require.Nil(t, s.Send(ctx, Message{
+			Channel: "email",
+			To:      "anna@example.com",
+			Subject: "Dein Gutschein für März",
+			Body:    "Hallo Anna,\nmit ABC12345 sparst du 20%.\n.\n",
+		}))
+
+		mails := srv.Mails()
+		require.Len(t, mails, 1)
+		assert.Equal(t, "vouchers@example.com", mails[0].From)
+		assert.Equal(t, []string{"anna@example.com"}, mails[0].To)
+
+		msg, err := mail.ReadMessage(strings.NewReader(mails[0].Data))
+		require.Nil(t, err)
+		subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+		require.Nil(t, err)
+		assert.Equal(t, "Dein Gutschein für März", subject)
+		assert.Equal(t, "text/plain; charset=utf-8", msg.Header.Get("Content-Type"))
+		body, err := ioutil.ReadAll(quotedprintable.NewReader(msg.Body))
+		require.Nil(t, err)
+		assert.Equal(t, "Hallo Anna,\r\nmit ABC12345 sparst du 20%.\r\n.\r\n", string(body))
+	})
+
+	t.Run("Fails on temporary errors and bad credentials", func(t *testing.T) {
+		m := Message{Channel: "email", To: "anna@example.com", Subject: "Hi", Body: "ABC"}
+		srv.Fail(1)
+		s := NewSMTP(srv.Addr(), "vouchers@example.com", "vouchers", "s3cret", time.Second)
+		err := s.Send(ctx, m)
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "451")
+		}
+		assert.Nil(t, s.Send(ctx, m))
+
+		s = NewSMTP(srv.Addr(), "vouchers@example.com", "vouchers", "wrong", time.Second)
+		assert.NotNil(t, s.Send(ctx, m))
+	})
+
+	t.Run("Keeps line breaks out of the headers", func(t *testing.T) {
+		s := NewSMTP(srv.Addr(), "vouchers@example.com", "vouchers", "s3cret", time.Second)
+		before := len(srv.Mails())
+		assert.NotNil(t, s.Send(ctx, Message{Channel: "email", To: "a@cc.cc\r\nBcc: b@cc.cc", Body: "ABC"}))
+
+		require.Nil(t, s.Send(ctx, Message{Channel: "email", To: "a@cc.cc", Subject: "Hi\r\nBcc: b@cc.cc", Body: "ABC"}))
+		mails := srv.Mails()
+		require.Len(t, mails, before+1)
+		msg, err := mail.ReadMessage(strings.NewReader(mails[before].Data))
+		require.Nil(t, err)
+		assert.Empty(t, msg.Header.Get("Bcc"))
+	})
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import "context"
+
+// Attempt records the outcome of one attempt guarded by a lockout, as told
+// by its handler. Attempts neither failed nor succeeded, e.g. malformed ones
+// or those failing on an outage, leave the failure history as it is.
+type Attempt struct {
+	failures  int
+	succeeded bool
+}
+
+// Failures returns the number of failures recorded, a request trying
+// several codes may fail once for each
+func (a *Attempt) Failures() int {
+	return a.failures
+}
+
+// Succeeded tells whether the attempt succeeded without any failure, which
+// clears the failure history
+func (a *Attempt) Succeeded() bool {
+	return a.succeeded && a.failures == 0
+}
+
+type attemptKey struct{}
+
+// NewAttemptContext returns a copy of ctx recording the outcome of an
+// attempt into the returned Attempt
+func NewAttemptContext(ctx context.Context) (context.Context, *Attempt) {
+	a := &Attempt{}
+	return context.WithValue(ctx, attemptKey{}, a), a
+}
+
+// FailAttempt records a failure of the attempt of ctx, it does nothing when
+// ctx records no attempt
+func FailAttempt(ctx context.Context) {
+	if a, ok := ctx.Value(attemptKey{}).(*Attempt); ok {
+		a.failures++
+	}
+}
+
+// SucceedAttempt records the attempt of ctx as succeeded, it does nothing
+// when ctx records no attempt
+func SucceedAttempt(ctx context.Context) {
+	if a, ok := ctx.Value(attemptKey{}).(*Attempt); ok {
+		a.succeeded = true
+	}
+}
+
+// RecordAttempt tells the lockout of ctx the outcome of a redemption that
+// ended with err: a success when err is nil, a failure when guessed tells
+// err signals guessing a code, nothing otherwise
+func RecordAttempt(ctx context.Context, err error, guessed func(error) bool) {
+	switch {
+	case err == nil:
+		SucceedAttempt(ctx)
+	case guessed(err):
+		FailAttempt(ctx)
+	}
+}
@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+)
+
+// Limits are the limits of redemption attempts by client IP, API key and
+// email. They use the same scopes as the REST middlewares, so a caller
+// shares its buckets whatever the API.
+type Limits struct {
+	IP     Limit
+	APIKey Limit
+	Email  Limit
+}
+
+// Caller identifies who attempts a redemption, empty keys are not limited
+type Caller struct {
+	IP     string
+	APIKey string
+	Email  string
+}
+
+// LimitedError is returned for attempts rejected by a limit or a lockout
+type LimitedError struct {
+	Msg        string
+	RetryAfter time.Duration
+}
+
+func (e *LimitedError) Error() string {
+	return e.Msg
+}
+
+// RetrySeconds is RetryAfter rounded up to whole seconds, at least one
+func (e *LimitedError) RetrySeconds() int {
+	secs := int(math.Ceil(e.RetryAfter.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	return secs
+}
+
+// Guard applies the limits and the lockout of the REST redeem routes to
+// the redemptions of the other APIs
+type Guard struct {
+	limiter *Limiter
+	limits  Limits
+}
+
+// NewGuard will instantiate a Guard
+func NewGuard(l *Limiter, limits Limits) *Guard {
+	return &Guard{
+		limiter: l,
+		limits:  limits,
+	}
+}
+
+// Attempt runs fn unless c is rate limited or its email locked out, in
+// which case it returns a *LimitedError. fn tells the outcome with
+// FailAttempt or SucceedAttempt on the context it is given, like the REST
+// handlers. Store errors are logged and let the attempt through.
+func (g *Guard) Attempt(ctx context.Context, c Caller, fn func(ctx context.Context) error) error {
+	log := logger.FromContext(ctx)
+	email := strings.ToLower(strings.TrimSpace(c.Email))
+	rules := []struct {
+		scope, key string
+		limit      Limit
+	}{
+		{"ip", c.IP, g.limits.IP},
+		{"api_key", c.APIKey, g.limits.APIKey},
+		{"email", email, g.limits.Email},
+	}
+	for _, r := range rules {
+		if r.key == "" {
+			continue
+		}
+		d, err := g.limiter.Allow(r.scope, r.key, r.limit)
+		if err != nil {
+			log.Error("ratelimit store failed", logger.Fields{"scope": r.scope, "error": err})
+			continue
+		}
+		if !d.Allowed {
+			log.Warn("rate limited", logger.Fields{"scope": r.scope})
+			return &LimitedError{Msg: "Too many requests", RetryAfter: d.RetryAfter}
+		}
+	}
+	if email == "" {
+		return fn(ctx)
+	}
+
+	locked, retry, err := g.limiter.Locked(email)
+	if err != nil {
+		log.Error("lockout store failed", logger.Fields{"error": err})
+	}
+	if locked {
+		log.Warn("redemption locked out", logger.Fields{"actor": email})
+		return &LimitedError{Msg: "Too many failed attempts", RetryAfter: retry}
+	}
+
+	ctx, attempt := NewAttemptContext(ctx)
+	fnErr := fn(ctx)
+	if err := g.limiter.Record(email, attempt); err != nil {
+		log.Error("lockout store failed", logger.Fields{"error": err})
+	}
+	return fnErr
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuard(t *testing.T) {
+	policy := LockoutPolicy{MaxFailures: 2, BaseCooldown: time.Minute}
+	limits := Limits{
+		IP:     Limit{Rate: 1, Burst: 100},
+		APIKey: Limit{Rate: 1, Burst: 100},
+		Email:  Limit{Rate: 1, Burst: 100},
+	}
+	alice := Caller{IP: "1.2.3.4", Email: "Alice@cc.cc"}
+	fail := func(ctx context.Context) error {
+		FailAttempt(ctx)
+		return errors.New("not found")
+	}
+
+	t.Run("Rate limits callers", func(t *testing.T) {
+		l, _ := newTestLimiter(policy)
+		strict := limits
+		strict.IP = Limit{Rate: 1, Burst: 1}
+		g := NewGuard(l, strict)
+		ok := func(ctx context.Context) error { return nil }
+
+		assert.Nil(t, g.Attempt(context.Background(), alice, ok))
+		err := g.Attempt(context.Background(), alice, ok)
+
+		if assert.IsType(t, &LimitedError{}, err) {
+			assert.Equal(t, 1, err.(*LimitedError).RetrySeconds())
+		}
+	})
+
+	t.Run("Locks out emails after failed attempts", func(t *testing.T) {
+		l, _ := newTestLimiter(policy)
+		g := NewGuard(l, limits)
+
+		g.Attempt(context.Background(), alice, fail)
+		g.Attempt(context.Background(), alice, fail)
+		called := false
+		err := g.Attempt(context.Background(), Caller{Email: "alice@cc.cc "}, func(ctx context.Context) error {
+			called = true
+			return nil
+		})
+
+		assert.False(t, called)
+		if assert.IsType(t, &LimitedError{}, err) {
+			assert.Equal(t, time.Minute, err.(*LimitedError).RetryAfter)
+		}
+	})
+
+	t.Run("Undecided attempts do not count", func(t *testing.T) {
+		l, _ := newTestLimiter(policy)
+		g := NewGuard(l, limits)
+		expected := errors.New("database is down")
+
+		for i := 0; i < 3; i++ {
+			err := g.Attempt(context.Background(), alice, func(ctx context.Context) error {
+				return expected
+			})
+			assert.Equal(t, expected, err)
+		}
+	})
+
+	t.Run("Success resets failures", func(t *testing.T) {
+		l, _ := newTestLimiter(policy)
+		g := NewGuard(l, limits)
+
+		g.Attempt(context.Background(), alice, fail)
+		g.Attempt(context.Background(), alice, func(ctx context.Context) error {
+			SucceedAttempt(ctx)
+			return nil
+		})
+		g.Attempt(context.Background(), alice, fail)
+
+		locked, _, _ := l.Locked("alice@cc.cc")
+		assert.False(t, locked)
+	})
+	t.Run("Records outcomes by the guessed classifier", func(t *testing.T) {
+		guessed := func(err error) bool { return err.Error() == "not found" }
+		ctx, attempt := NewAttemptContext(context.Background())
+
+		RecordAttempt(ctx, errors.New("database is down"), guessed)
+		assert.Equal(t, 0, attempt.Failures())
+		RecordAttempt(ctx, errors.New("not found"), guessed)
+		RecordAttempt(ctx, nil, guessed)
+
+		assert.Equal(t, 1, attempt.Failures())
+		assert.False(t, attempt.Succeeded())
+	})
+}
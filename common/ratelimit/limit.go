@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Limit describes a token bucket: Rate tokens are added per second up to Burst
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Decision is the outcome of taking a token from a bucket
+type Decision struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// bucket is the persisted state of a single token bucket
+type bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// newBucket returns a full bucket for the given limit
+func newBucket(limit Limit, now time.Time) *bucket {
+	return &bucket{Tokens: float64(limit.Burst), UpdatedAt: now}
+}
+
+// idle reports whether the bucket has not been used since before
+func (b *bucket) idle(before time.Time) bool {
+	return b.UpdatedAt.Before(before)
+}
+
+// take refills the bucket for the time elapsed since its last update and
+// tries to remove a single token from it
+func (b *bucket) take(limit Limit, now time.Time) Decision {
+	if elapsed := now.Sub(b.UpdatedAt).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(float64(limit.Burst), b.Tokens+elapsed*limit.Rate)
+	}
+	b.UpdatedAt = now
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		return Decision{Allowed: true, Remaining: int(b.Tokens)}
+	}
+
+	var retry time.Duration
+	if limit.Rate > 0 {
+		retry = time.Duration((1 - b.Tokens) / limit.Rate * float64(time.Second))
+	}
+	return Decision{Allowed: false, RetryAfter: retry}
+}
@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/metrics"
+)
+
+// Limiter applies token bucket limits and failure lockouts on top of a Store
+// and counts every blocked attempt in metrics.RateLimitBlocked
+type Limiter struct {
+	store  Store
+	policy LockoutPolicy
+	now    func() time.Time
+}
+
+// NewLimiter will instantiate a Limiter
+func NewLimiter(store Store, policy LockoutPolicy) *Limiter {
+	return &Limiter{
+		store:  store,
+		policy: policy,
+		now:    time.Now,
+	}
+}
+
+// Allow takes a token from the bucket identified by scope and key
+func (l *Limiter) Allow(scope, key string, limit Limit) (Decision, error) {
+	d, err := l.store.Take(scope+":"+key, limit, l.now())
+	if err != nil {
+		return Decision{}, err
+	}
+	if !d.Allowed {
+		l.block(scope)
+	}
+	return d, nil
+}
+
+// Locked reports whether key is locked out and for how much longer
+func (l *Limiter) Locked(key string) (bool, time.Duration, error) {
+	s, err := l.store.LockState("lockout:" + key)
+	if err != nil {
+		return false, 0, err
+	}
+	now := l.now()
+	if !s.Locked(now) {
+		return false, 0, nil
+	}
+	l.block("lockout")
+	return true, s.LockedUntil.Sub(now), nil
+}
+
+// Fail records a failed attempt for key
+func (l *Limiter) Fail(key string) (LockState, error) {
+	return l.store.RecordFailure("lockout:"+key, l.policy, l.now())
+}
+
+// Succeed clears the failure history of key
+func (l *Limiter) Succeed(key string) error {
+	return l.store.ResetFailures("lockout:" + key)
+}
+
+// Record tells the lockout key of the outcome of a: one failure for each
+// recorded, or else a success. It stops once key is locked out.
+func (l *Limiter) Record(key string, a *Attempt) error {
+	if a.Succeeded() {
+		return l.Succeed(key)
+	}
+	for i := 0; i < a.failures; i++ {
+		s, err := l.Fail(key)
+		if err != nil {
+			return err
+		}
+		if s.Locked(l.now()) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Cleanup drops buckets and failure histories idle for longer than maxIdle.
+// A bucket idle for at least Burst/Rate seconds is full, so dropping it does
+// not change any later decision.
+func (l *Limiter) Cleanup(maxIdle time.Duration) error {
+	return l.store.Cleanup(l.now().Add(-maxIdle))
+}
+
+func (l *Limiter) block(scope string) {
+	metrics.RateLimitBlocked.WithLabelValues(scope).Inc()
+}
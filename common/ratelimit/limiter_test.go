@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestLimiter(policy LockoutPolicy) (*Limiter, *time.Time) {
+	now := epoch
+	l := NewLimiter(NewMemoryStore(), policy)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestAllow(t *testing.T) {
+	limit := Limit{Rate: 1, Burst: 2}
+
+	t.Run("Allows up to burst then blocks", func(t *testing.T) {
+		l, _ := newTestLimiter(LockoutPolicy{})
+		before := testutil.ToFloat64(metrics.RateLimitBlocked.WithLabelValues("ip"))
+
+		d, _ := l.Allow("ip", "1.2.3.4", limit)
+		assert.True(t, d.Allowed)
+		assert.Equal(t, 1, d.Remaining)
+
+		d, _ = l.Allow("ip", "1.2.3.4", limit)
+		assert.True(t, d.Allowed)
+
+		d, _ = l.Allow("ip", "1.2.3.4", limit)
+		assert.False(t, d.Allowed)
+		assert.Equal(t, time.Second, d.RetryAfter)
+		assert.Equal(t, before+1, testutil.ToFloat64(metrics.RateLimitBlocked.WithLabelValues("ip")))
+	})
+
+	t.Run("Refills over time", func(t *testing.T) {
+		l, now := newTestLimiter(LockoutPolicy{})
+
+		l.Allow("ip", "1.2.3.4", limit)
+		l.Allow("ip", "1.2.3.4", limit)
+		*now = now.Add(time.Second)
+
+		d, _ := l.Allow("ip", "1.2.3.4", limit)
+		assert.True(t, d.Allowed)
+	})
+
+	t.Run("Keeps scopes and keys apart", func(t *testing.T) {
+		l, _ := newTestLimiter(LockoutPolicy{})
+		one := Limit{Rate: 1, Burst: 1}
+
+		l.Allow("ip", "a", one)
+		d, _ := l.Allow("ip", "b", one)
+		assert.True(t, d.Allowed)
+		d, _ = l.Allow("email", "a", one)
+		assert.True(t, d.Allowed)
+	})
+}
+
+func TestLockout(t *testing.T) {
+	policy := LockoutPolicy{MaxFailures: 2, BaseCooldown: time.Minute, MaxCooldown: 3 * time.Minute}
+
+	t.Run("Locks after max failures", func(t *testing.T) {
+		l, _ := newTestLimiter(policy)
+		before := testutil.ToFloat64(metrics.RateLimitBlocked.WithLabelValues("lockout"))
+
+		l.Fail("alice@cc.cc")
+		locked, _, _ := l.Locked("alice@cc.cc")
+		assert.False(t, locked)
+
+		l.Fail("alice@cc.cc")
+		locked, retry, _ := l.Locked("alice@cc.cc")
+		assert.True(t, locked)
+		assert.Equal(t, time.Minute, retry)
+		assert.Equal(t, before+1, testutil.ToFloat64(metrics.RateLimitBlocked.WithLabelValues("lockout")))
+	})
+
+	t.Run("Cooldown grows exponentially up to max", func(t *testing.T) {
+		l, now := newTestLimiter(policy)
+
+		var s LockState
+		for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+			l.Fail("alice@cc.cc")
+			s, _ = l.Fail("alice@cc.cc")
+			assert.Equal(t, want, s.LockedUntil.Sub(*now))
+			*now = s.LockedUntil
+		}
+	})
+
+	t.Run("Success resets failures", func(t *testing.T) {
+		l, _ := newTestLimiter(policy)
+
+		l.Fail("alice@cc.cc")
+		l.Succeed("alice@cc.cc")
+		s, _ := l.Fail("alice@cc.cc")
+
+		assert.Equal(t, 1, s.Failures)
+		assert.Equal(t, 0, s.Lockouts)
+	})
+
+	t.Run("Records every failure of an attempt up to a lockout", func(t *testing.T) {
+		l, _ := newTestLimiter(policy)
+		ctx, attempt := NewAttemptContext(context.Background())
+		for i := 0; i < 5; i++ {
+			FailAttempt(ctx)
+		}
+		SucceedAttempt(ctx)
+
+		assert.Nil(t, l.Record("alice@cc.cc", attempt))
+
+		s, _ := l.store.LockState("lockout:alice@cc.cc")
+		assert.Equal(t, 1, s.Lockouts)
+		assert.Equal(t, 0, s.Failures)
+	})
+}
+
+func TestCleanup(t *testing.T) {
+	t.Run("Drops idle state only", func(t *testing.T) {
+		policy := LockoutPolicy{MaxFailures: 1, BaseCooldown: 2 * time.Hour}
+		l, now := newTestLimiter(policy)
+		limit := Limit{Rate: 0.001, Burst: 1}
+
+		l.Allow("ip", "old", limit)
+		l.Fail("locked@cc.cc")
+		*now = now.Add(90 * time.Minute)
+		l.Allow("ip", "new", limit)
+
+		assert.Nil(t, l.Cleanup(time.Hour))
+
+		d, _ := l.Allow("ip", "old", limit)
+		assert.True(t, d.Allowed)
+		d, _ = l.Allow("ip", "new", limit)
+		assert.False(t, d.Allowed)
+		locked, _, _ := l.Locked("locked@cc.cc")
+		assert.True(t, locked)
+	})
+}
@@ -0,0 +1,59 @@
+package ratelimit
+
+import "time"
+
+// LockoutPolicy locks a key out after MaxFailures consecutive failures.
+// Every further lockout doubles the cooldown, starting at BaseCooldown and
+// capped at MaxCooldown.
+type LockoutPolicy struct {
+	MaxFailures  int
+	BaseCooldown time.Duration
+	MaxCooldown  time.Duration
+}
+
+// LockState is the persisted failure state of a single key
+type LockState struct {
+	Failures    int
+	Lockouts    int
+	LockedUntil time.Time
+	UpdatedAt   time.Time
+}
+
+// idle reports whether the state has not changed since before and holds no
+// active lockout
+func (s LockState) idle(before time.Time) bool {
+	return s.UpdatedAt.Before(before) && !s.Locked(before)
+}
+
+// Locked reports whether the key is locked out at the given time
+func (s LockState) Locked(now time.Time) bool {
+	return now.Before(s.LockedUntil)
+}
+
+// fail records a failed attempt and starts a new lockout once the policy
+// threshold has been reached
+func (p LockoutPolicy) fail(s *LockState, now time.Time) {
+	s.UpdatedAt = now
+	s.Failures++
+	if p.MaxFailures <= 0 || s.Failures < p.MaxFailures {
+		return
+	}
+	s.Failures = 0
+	s.Lockouts++
+	s.LockedUntil = now.Add(p.cooldown(s.Lockouts))
+}
+
+// cooldown returns the lockout duration for the n-th consecutive lockout
+func (p LockoutPolicy) cooldown(n int) time.Duration {
+	d := p.BaseCooldown
+	for i := 1; i < n; i++ {
+		d *= 2
+		if p.MaxCooldown > 0 && d >= p.MaxCooldown {
+			return p.MaxCooldown
+		}
+	}
+	if p.MaxCooldown > 0 && d > p.MaxCooldown {
+		return p.MaxCooldown
+	}
+	return d
+}
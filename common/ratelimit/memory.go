@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+type memoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	locks   map[string]*LockState
+}
+
+// NewMemoryStore will instantiate an in-process Store. State is lost on
+// restart and not shared between instances.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		buckets: make(map[string]*bucket),
+		locks:   make(map[string]*LockState),
+	}
+}
+
+func (m *memoryStore) Take(key string, limit Limit, now time.Time) (Decision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = newBucket(limit, now)
+		m.buckets[key] = b
+	}
+	return b.take(limit, now), nil
+}
+
+func (m *memoryStore) LockState(key string) (LockState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.locks[key]; ok {
+		return *s, nil
+	}
+	return LockState{}, nil
+}
+
+func (m *memoryStore) RecordFailure(key string, policy LockoutPolicy, now time.Time) (LockState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.locks[key]
+	if !ok {
+		s = &LockState{}
+		m.locks[key] = s
+	}
+	policy.fail(s, now)
+	return *s, nil
+}
+
+func (m *memoryStore) ResetFailures(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.locks, key)
+	return nil
+}
+
+func (m *memoryStore) Cleanup(before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, b := range m.buckets {
+		if b.idle(before) {
+			delete(m.buckets, key)
+		}
+	}
+	for key, s := range m.locks {
+		if s.idle(before) {
+			delete(m.locks, key)
+		}
+	}
+	return nil
+}
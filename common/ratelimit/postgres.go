@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// BucketRecord is the database row of a token bucket
+type BucketRecord struct {
+	BucketKey string `gorm:"primary_key"`
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// TableName sets the table name of BucketRecord
+func (BucketRecord) TableName() string {
+	return "rate_limit_buckets"
+}
+
+// LockoutRecord is the database row of a lockout state
+type LockoutRecord struct {
+	LockKey     string `gorm:"primary_key"`
+	Failures    int
+	Lockouts    int
+	LockedUntil time.Time
+	UpdatedAt   time.Time
+}
+
+// TableName sets the table name of LockoutRecord
+func (LockoutRecord) TableName() string {
+	return "rate_limit_lockouts"
+}
+
+type postgresStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresStore will instantiate a Store backed by Postgres. Missing
+// rows are inserted with ON CONFLICT DO NOTHING and then locked with
+// SELECT ... FOR UPDATE, so instances sharing the database see consistent
+// buckets, even when racing on the first request of a key.
+func NewPostgresStore(db *gorm.DB) Store {
+	return &postgresStore{
+		db: db,
+	}
+}
+
+func (p *postgresStore) Take(key string, limit Limit, now time.Time) (Decision, error) {
+	var d Decision
+	err := p.transaction(func(tx *gorm.DB) error {
+		b := newBucket(limit, now)
+		err := tx.Exec(`INSERT INTO rate_limit_buckets (bucket_key, tokens, updated_at)
+			VALUES (?, ?, ?) ON CONFLICT DO NOTHING`, key, b.Tokens, b.UpdatedAt).Error
+		if err != nil {
+			return err
+		}
+
+		var rec BucketRecord
+		err = tx.Set("gorm:query_option", "FOR UPDATE").
+			Where("bucket_key = ?", key).First(&rec).Error
+		if err != nil {
+			return err
+		}
+
+		b = &bucket{Tokens: rec.Tokens, UpdatedAt: rec.UpdatedAt}
+		d = b.take(limit, now)
+		rec = BucketRecord{BucketKey: key, Tokens: b.Tokens, UpdatedAt: b.UpdatedAt}
+		return tx.Save(&rec).Error
+	})
+	return d, err
+}
+
+func (p *postgresStore) LockState(key string) (LockState, error) {
+	var rec LockoutRecord
+	err := p.db.Where("lock_key = ?", key).First(&rec).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return LockState{}, nil
+	}
+	if err != nil {
+		return LockState{}, err
+	}
+	return recordToLockState(rec), nil
+}
+
+func (p *postgresStore) RecordFailure(key string, policy LockoutPolicy, now time.Time) (LockState, error) {
+	var s LockState
+	err := p.transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`INSERT INTO rate_limit_lockouts (lock_key, failures, lockouts, locked_until, updated_at)
+			VALUES (?, 0, 0, ?, ?) ON CONFLICT DO NOTHING`, key, time.Time{}, now).Error
+		if err != nil {
+			return err
+		}
+
+		var rec LockoutRecord
+		err = tx.Set("gorm:query_option", "FOR UPDATE").
+			Where("lock_key = ?", key).First(&rec).Error
+		if err != nil {
+			return err
+		}
+
+		s = recordToLockState(rec)
+		policy.fail(&s, now)
+		rec = LockoutRecord{
+			LockKey:     key,
+			Failures:    s.Failures,
+			Lockouts:    s.Lockouts,
+			LockedUntil: s.LockedUntil,
+		}
+		return tx.Save(&rec).Error
+	})
+	return s, err
+}
+
+func (p *postgresStore) ResetFailures(key string) error {
+	return p.db.Where("lock_key = ?", key).Delete(&LockoutRecord{}).Error
+}
+
+func (p *postgresStore) Cleanup(before time.Time) error {
+	if err := p.db.Where("updated_at < ?", before).Delete(&BucketRecord{}).Error; err != nil {
+		return err
+	}
+	return p.db.Where("updated_at < ? AND locked_until < ?", before, before).
+		Delete(&LockoutRecord{}).Error
+}
+
+func (p *postgresStore) transaction(fn func(tx *gorm.DB) error) error {
+	tx := p.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func recordToLockState(rec LockoutRecord) LockState {
+	return LockState{
+		Failures:    rec.Failures,
+		Lockouts:    rec.Lockouts,
+		LockedUntil: rec.LockedUntil,
+		UpdatedAt:   rec.UpdatedAt,
+	}
+}
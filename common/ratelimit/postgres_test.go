@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupDB() (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatalf("can't create sqlmock: %s", err)
+	}
+
+	gormDB, gerr := gorm.Open("postgres", db)
+	if gerr != nil {
+		log.Fatalf("can't open gorm connection: %s", err)
+	}
+	gormDB.LogMode(true)
+	return gormDB, mock
+}
+
+func TestPostgresTake(t *testing.T) {
+	gormDB, mock := setupDB()
+	defer gormDB.Close()
+
+	t.Run("Takes from a locked row", func(t *testing.T) {
+		s := NewPostgresStore(gormDB)
+
+		mock.ExpectBegin()
+		mock.
+			ExpectExec(regexp.QuoteMeta(`INSERT INTO rate_limit_buckets`)).
+			WithArgs("ip:1.2.3.4", 5.0, epoch).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.
+			ExpectQuery(
+				regexp.QuoteMeta(
+					`SELECT * FROM "rate_limit_buckets" WHERE (bucket_key = $1) ORDER BY "rate_limit_buckets"."bucket_key" ASC LIMIT 1 FOR UPDATE`)).
+			WithArgs("ip:1.2.3.4").
+			WillReturnRows(
+				sqlmock.NewRows([]string{"bucket_key", "tokens", "updated_at"}).
+					AddRow("ip:1.2.3.4", 1.5, epoch))
+		mock.
+			ExpectExec(regexp.QuoteMeta(`UPDATE "rate_limit_buckets"`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		d, err := s.Take("ip:1.2.3.4", Limit{Rate: 1, Burst: 5}, epoch)
+
+		assert.Nil(t, err)
+		assert.True(t, d.Allowed)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Rolls back on error", func(t *testing.T) {
+		expected := errors.New("Nop")
+		s := NewPostgresStore(gormDB)
+
+		mock.ExpectBegin()
+		mock.
+			ExpectExec(regexp.QuoteMeta(`INSERT INTO rate_limit_buckets`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.
+			ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "rate_limit_buckets"`)).
+			WillReturnError(expected)
+		mock.ExpectRollback()
+
+		_, err := s.Take("ip:1.2.3.4", Limit{Rate: 1, Burst: 5}, epoch)
+
+		assert.EqualValues(t, expected, err)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Creates the row of a new key without conflicting", func(t *testing.T) {
+		s := NewPostgresStore(gormDB)
+
+		mock.ExpectBegin()
+		mock.
+			ExpectExec(regexp.QuoteMeta(
+				`INSERT INTO rate_limit_buckets (bucket_key, tokens, updated_at)`)).
+			WithArgs("ip:1.2.3.4", 5.0, epoch).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.
+			ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "rate_limit_buckets"`)).
+			WithArgs("ip:1.2.3.4").
+			WillReturnRows(
+				sqlmock.NewRows([]string{"bucket_key", "tokens", "updated_at"}).
+					AddRow("ip:1.2.3.4", 5.0, epoch))
+		mock.
+			ExpectExec(regexp.QuoteMeta(`UPDATE "rate_limit_buckets"`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		d, err := s.Take("ip:1.2.3.4", Limit{Rate: 1, Burst: 5}, epoch)
+
+		assert.Nil(t, err)
+		assert.True(t, d.Allowed)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgresRecordFailure(t *testing.T) {
+	gormDB, mock := setupDB()
+	defer gormDB.Close()
+
+	t.Run("Counts on a row inserted without conflicting", func(t *testing.T) {
+		s := NewPostgresStore(gormDB)
+
+		mock.ExpectBegin()
+		mock.
+			ExpectExec(regexp.QuoteMeta(
+				`INSERT INTO rate_limit_lockouts (lock_key, failures, lockouts, locked_until, updated_at)`)).
+			WithArgs("lockout:alice@cc.cc", time.Time{}, epoch).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.
+			ExpectQuery(regexp.QuoteMeta(
+				`SELECT * FROM "rate_limit_lockouts" WHERE (lock_key = $1) ORDER BY "rate_limit_lockouts"."lock_key" ASC LIMIT 1 FOR UPDATE`)).
+			WithArgs("lockout:alice@cc.cc").
+			WillReturnRows(
+				sqlmock.NewRows([]string{"lock_key", "failures", "lockouts", "locked_until", "updated_at"}).
+					AddRow("lockout:alice@cc.cc", 1, 0, time.Time{}, epoch))
+		mock.
+			ExpectExec(regexp.QuoteMeta(`UPDATE "rate_limit_lockouts"`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		state, err := s.RecordFailure("lockout:alice@cc.cc", LockoutPolicy{MaxFailures: 5}, epoch)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, state.Failures)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgresLockState(t *testing.T) {
+	gormDB, mock := setupDB()
+	defer gormDB.Close()
+
+	t.Run("Missing row means unlocked", func(t *testing.T) {
+		s := NewPostgresStore(gormDB)
+
+		mock.
+			ExpectQuery(
+				regexp.QuoteMeta(
+					`SELECT * FROM "rate_limit_lockouts" WHERE (lock_key = $1)`)).
+			WithArgs("lockout:alice@cc.cc").
+			WillReturnRows(sqlmock.NewRows([]string{}))
+
+		state, err := s.LockState("lockout:alice@cc.cc")
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, LockState{}, state)
+	})
+}
@@ -0,0 +1,13 @@
+package ratelimit
+
+import "time"
+
+// Store persists token buckets and lockout state. Implementations must make
+// each call atomic so several instances can share the same store.
+type Store interface {
+	Take(key string, limit Limit, now time.Time) (Decision, error)
+	LockState(key string) (LockState, error)
+	RecordFailure(key string, policy LockoutPolicy, now time.Time) (LockState, error)
+	ResetFailures(key string) error
+	Cleanup(before time.Time) error
+}
@@ -0,0 +1,81 @@
+package rates
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HTTP is a RateProvider fetching a Table from a URL. The table is kept for
+// a TTL; when a refresh fails the last table keeps being used.
+type HTTP struct {
+	url    string
+	ttl    time.Duration
+	client *http.Client
+	now    func() time.Time
+
+	mu      sync.Mutex
+	table   *Table
+	fetched time.Time
+}
+
+// NewHTTP will instantiate an HTTP rate provider, the first table is
+// fetched on the first rate asked for
+func NewHTTP(url string, ttl, timeout time.Duration) *HTTP {
+	return &HTTP{
+		url:    url,
+		ttl:    ttl,
+		client: &http.Client{Timeout: timeout},
+		now:    time.Now,
+	}
+}
+
+func (h *HTTP) Rate(ctx context.Context, from, to string) (*big.Rat, error) {
+	t, err := h.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return t.Rate(ctx, from, to)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// current returns the table, refreshed once it is older than the TTL.
+// Callers wait for a refresh in progress rather than fetch in parallel.
+func (h *HTTP) current(ctx context.Context) (*Table, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.table != nil && h.now().Sub(h.fetched) < h.ttl {
+		return h.table, nil
+	}
+	t, err := h.fetch(ctx)
+	if err != nil {
+		if h.table != nil {
+			return h.table, nil
+		}
+		return nil, err
+	}
+	h.table, h.fetched = t, h.now()
+	return t, nil
+}
+
+func (h *HTTP) fetch(ctx context.Context) (*Table, error) {
+	req, err := http.NewRequest(http.MethodGet, h.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rates: %s answered %s", h.url, resp.Status)
+	}
+	return ParseTable(resp.Body)
+}
@@ -0,0 +1,92 @@
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+)
+
+// ErrNoRate is returned for pairs of currencies the source has no rate for
+var ErrNoRate = errors.New("no exchange rate")
+
+// RateProvider gives exchange rates. Implementations must be safe for
+// concurrent use.
+type RateProvider interface {
+	// Rate is the price of one major unit of from in major units of to
+	Rate(ctx context.Context, from, to string) (*big.Rat, error)
+}
+
+// Table holds the rates of every currency against Base, as they are read
+// from files and served over HTTP:
+//
+//	{"base": "EUR", "rates": {"GBP": 0.8575, "USD": 1.0832}}
+type Table struct {
+	Base  string                 `json:"base"`
+	Rates map[string]json.Number `json:"rates"`
+}
+
+// ParseTable reads a table in JSON
+func ParseTable(r io.Reader) (*Table, error) {
+	var t Table
+	if err := json.NewDecoder(r).Decode(&t); err != nil {
+		return nil, err
+	}
+	if t.Base == "" {
+		return nil, errors.New("rates: base is required")
+	}
+	for code, n := range t.Rates {
+		rate, ok := new(big.Rat).SetString(n.String())
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("rates: invalid rate %q of %s", n, code)
+		}
+	}
+	return &t, nil
+}
+
+// Rate crosses the rates of from and to against the base
+func (t *Table) Rate(ctx context.Context, from, to string) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+	f, err := t.against(from)
+	if err != nil {
+		return nil, err
+	}
+	r, err := t.against(to)
+	if err != nil {
+		return nil, err
+	}
+	return r.Quo(r, f), nil
+}
+
+// LoadFile reads a static table from a JSON file
+func LoadFile(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseTable(f)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// against returns the rate of code against the base
+func (t *Table) against(code string) (*big.Rat, error) {
+	if code == t.Base {
+		return big.NewRat(1, 1), nil
+	}
+	n, ok := t.Rates[code]
+	if !ok {
+		return nil, fmt.Errorf("%w for %s", ErrNoRate, code)
+	}
+	// validated by ParseTable
+	rate, _ := new(big.Rat).SetString(n.String())
+	return rate, nil
+}
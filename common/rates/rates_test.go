@@ -0,0 +1,110 @@
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+const table = `{"base": "EUR", "rates": {"GBP": 0.85, "USD": 1.25}}`
+
+func TestTable(t *testing.T) {
+	tbl, err := ParseTable(strings.NewReader(table))
+	require.Nil(t, err)
+
+	for _, tt := range []struct {
+		from, to string
+		want     *big.Rat
+	}{
+		{"EUR", "EUR", big.NewRat(1, 1)},
+		{"EUR", "GBP", big.NewRat(85, 100)},
+		{"GBP", "EUR", big.NewRat(100, 85)},
+		{"USD", "GBP", big.NewRat(85, 125)},
+	} {
+		rate, err := tbl.Rate(ctx, tt.from, tt.to)
+		require.Nil(t, err)
+		assert.Equal(t, tt.want.String(), rate.String(), tt.from+tt.to)
+	}
+	_, err = tbl.Rate(ctx, "EUR", "JPY")
+	assert.True(t, errors.Is(err, ErrNoRate))
+
+	for _, bad := range []string{`{"rates": {}}`, `{"base": "EUR", "rates": {"GBP": -1}}`, `[`} {
+		_, err := ParseTable(strings.NewReader(bad))
+		assert.NotNil(t, err, bad)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rates")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rates.json")
+	require.Nil(t, ioutil.WriteFile(path, []byte(table), 0600))
+
+	tbl, err := LoadFile(path)
+	require.Nil(t, err)
+	assert.Equal(t, "EUR", tbl.Base)
+	_, err = LoadFile(filepath.Join(dir, "missing.json"))
+	assert.NotNil(t, err)
+}
+
+func TestHTTP(t *testing.T) {
+	tbl, err := ParseTable(strings.NewReader(table))
+	require.Nil(t, err)
+	fetches, fail := 0, false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if fail {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		Handler(tbl).ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	h := NewHTTP(srv.URL, time.Hour, time.Second)
+	h.now = func() time.Time { return now }
+
+	rate, err := h.Rate(ctx, "EUR", "USD")
+	require.Nil(t, err)
+	assert.Equal(t, "5/4", rate.String())
+	_, err = h.Rate(ctx, "EUR", "GBP")
+	require.Nil(t, err)
+	assert.Equal(t, 1, fetches, "the table is kept for the TTL")
+
+	// a failed refresh keeps the last table
+	now = now.Add(2 * time.Hour)
+	fail = true
+	_, err = h.Rate(ctx, "EUR", "USD")
+	require.Nil(t, err)
+	assert.Equal(t, 2, fetches)
+
+	_, err = NewHTTP(srv.URL, time.Hour, time.Second).Rate(ctx, "EUR", "USD")
+	assert.NotNil(t, err)
+}
+
+func TestHandler(t *testing.T) {
+	tbl, err := ParseTable(strings.NewReader(table))
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	Handler(tbl).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	var got Table
+	require.Nil(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, *tbl, got)
+}
@@ -0,0 +1,166 @@
+// Package signing signs short payloads, such as voucher codes, so scanners
+// holding the key can check them without the database
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// MinKeySize is the smallest key NewHMAC accepts, in bytes
+const MinKeySize = 32
+
+// macSize is the part of the HMAC kept in signatures, 128 bits is plenty
+// for payloads nobody can try offline
+const macSize = 16
+
+var (
+	// ErrKeySize is returned for keys shorter than MinKeySize
+	ErrKeySize = errors.New("signing key must be at least 32 bytes")
+	// ErrSignature is returned for payloads not signed with the key
+	ErrSignature = errors.New("invalid signature")
+	// ErrEd25519Key is returned for Ed25519 keys of the wrong size
+	ErrEd25519Key = errors.New("ed25519 key must be a 32 bytes seed, a 64 bytes private key or a 32 bytes public key")
+)
+
+// Verifier verifies signed payloads. Implementations must be safe for
+// concurrent use.
+type Verifier interface {
+	// Verify returns the payload of signed, ErrSignature when it was not
+	// signed with the key
+	Verify(signed string) (string, error)
+}
+
+// Signer signs payloads and verifies signed ones. Implementations must be
+// safe for concurrent use.
+type Signer interface {
+	Verifier
+	// Sign returns the payload followed by a dot and its signature
+	Sign(payload string) string
+	// Algorithm names the signatures, "HS256" or "Ed25519"
+	Algorithm() string
+	// PublicKey returns the key verifying the signatures without signing,
+	// nil for symmetric ones
+	PublicKey() []byte
+}
+
+type hmacSigner struct {
+	key []byte
+}
+
+// NewHMAC will instantiate a Signer of HMAC-SHA256 signatures. A signed
+// payload is
+//
+//	payload + "." + base64url(HMAC-SHA256(key, payload)[:16])
+//
+// without base64 padding, so scanners can check it with the key alone.
+func NewHMAC(key []byte) (Signer, error) {
+	if len(key) < MinKeySize {
+		return nil, ErrKeySize
+	}
+	return &hmacSigner{key: append([]byte(nil), key...)}, nil
+}
+
+func (s *hmacSigner) Sign(payload string) string {
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.mac(payload))
+}
+
+func (s *hmacSigner) Verify(signed string) (string, error) {
+	payload, sig, ok := split(signed)
+	if !ok || !hmac.Equal(sig, s.mac(payload)) {
+		return "", ErrSignature
+	}
+	return payload, nil
+}
+
+func (s *hmacSigner) Algorithm() string {
+	return "HS256"
+}
+
+func (s *hmacSigner) PublicKey() []byte {
+	return nil
+}
+
+type ed25519Signer struct {
+	ed25519Verifier
+	key ed25519.PrivateKey
+}
+
+// NewEd25519 will instantiate a Signer of Ed25519 signatures from a 32 bytes
+// seed or a 64 bytes private key. Signed payloads look like the HMAC ones
+// with a longer signature, and scanners check them with the public key alone.
+func NewEd25519(key []byte) (Signer, error) {
+	var priv ed25519.PrivateKey
+	switch len(key) {
+	case ed25519.SeedSize:
+		priv = ed25519.NewKeyFromSeed(key)
+	case ed25519.PrivateKeySize:
+		priv = append(ed25519.PrivateKey(nil), key...)
+	default:
+		return nil, ErrEd25519Key
+	}
+	return &ed25519Signer{
+		ed25519Verifier: ed25519Verifier{key: priv.Public().(ed25519.PublicKey)},
+		key:             priv,
+	}, nil
+}
+
+func (s *ed25519Signer) Sign(payload string) string {
+	return payload + "." + base64.RawURLEncoding.EncodeToString(ed25519.Sign(s.key, []byte(payload)))
+}
+
+func (s *ed25519Signer) Algorithm() string {
+	return "Ed25519"
+}
+
+func (s *ed25519Signer) PublicKey() []byte {
+	return append([]byte(nil), s.ed25519Verifier.key...)
+}
+
+type ed25519Verifier struct {
+	key ed25519.PublicKey
+}
+
+// NewEd25519Verifier will instantiate a Verifier of the signatures of the
+// Ed25519 signer of the given 32 bytes public key
+func NewEd25519Verifier(key []byte) (Verifier, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, ErrEd25519Key
+	}
+	return &ed25519Verifier{key: append(ed25519.PublicKey(nil), key...)}, nil
+}
+
+func (v *ed25519Verifier) Verify(signed string) (string, error) {
+	payload, sig, ok := split(signed)
+	if !ok || !ed25519.Verify(v.key, []byte(payload), sig) {
+		return "", ErrSignature
+	}
+	return payload, nil
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// split cuts signed at its last dot into the payload and decoded signature
+func split(signed string) (string, []byte, bool) {
+	i := strings.LastIndexByte(signed, '.')
+	if i < 0 {
+		return "", nil, false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signed[i+1:])
+	if err != nil {
+		return "", nil, false
+	}
+	return signed[:i], sig, true
+}
+
+func (s *hmacSigner) mac(payload string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)[:macSize]
+}
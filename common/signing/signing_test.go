@@ -0,0 +1,101 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHMAC(t *testing.T) {
+	key := []byte(strings.Repeat("k", MinKeySize))
+	s, err := NewHMAC(key)
+	require.Nil(t, err)
+
+	t.Run("Signs and verifies", func(t *testing.T) {
+		signed := s.Sign("SPRING20")
+		// RFC 4648 base64url of the first 16 bytes of the HMAC
+		assert.Regexp(t, `^SPRING20\.[A-Za-z0-9_-]{22}$`, signed)
+		assert.Equal(t, signed, s.Sign("SPRING20"))
+
+		payload, err := s.Verify(signed)
+		assert.Nil(t, err)
+		assert.Equal(t, "SPRING20", payload)
+	})
+
+	t.Run("Rejects tampered payloads", func(t *testing.T) {
+		signed := s.Sign("SPRING20")
+		other, err := NewHMAC([]byte(strings.Repeat("o", MinKeySize)))
+		require.Nil(t, err)
+
+		for _, bad := range []string{
+			"SPRING20",
+			"SPRING21" + signed[8:],
+			signed[:len(signed)-1],
+			signed + "A",
+			"SPRING20.!",
+			other.Sign("SPRING20"),
+		} {
+			_, err := s.Verify(bad)
+			assert.Equal(t, ErrSignature, err, bad)
+		}
+	})
+
+	t.Run("Requires long keys", func(t *testing.T) {
+		_, err := NewHMAC(key[1:])
+		assert.Equal(t, ErrKeySize, err)
+	})
+}
+
+func TestEd25519(t *testing.T) {
+	seed := []byte(strings.Repeat("s", ed25519.SeedSize))
+	s, err := NewEd25519(seed)
+	require.Nil(t, err)
+
+	t.Run("Signs and verifies with the public key", func(t *testing.T) {
+		signed := s.Sign("SPRING20")
+		assert.Regexp(t, `^SPRING20\.[A-Za-z0-9_-]{86}$`, signed)
+		assert.Equal(t, "Ed25519", s.Algorithm())
+
+		v, err := NewEd25519Verifier(s.PublicKey())
+		require.Nil(t, err)
+		for _, verifier := range []Verifier{s, v} {
+			payload, err := verifier.Verify(signed)
+			assert.Nil(t, err)
+			assert.Equal(t, "SPRING20", payload)
+		}
+	})
+
+	t.Run("Accepts the expanded private key", func(t *testing.T) {
+		expanded, err := NewEd25519(ed25519.NewKeyFromSeed(seed))
+		require.Nil(t, err)
+		assert.Equal(t, s.PublicKey(), expanded.PublicKey())
+		assert.Equal(t, s.Sign("SPRING20"), expanded.Sign("SPRING20"))
+	})
+
+	t.Run("Rejects tampered payloads", func(t *testing.T) {
+		signed := s.Sign("SPRING20")
+		other, err := NewEd25519([]byte(strings.Repeat("o", ed25519.SeedSize)))
+		require.Nil(t, err)
+
+		for _, bad := range []string{
+			"SPRING20",
+			"SPRING21" + signed[8:],
+			signed[:len(signed)-1],
+			"SPRING20.!",
+			other.Sign("SPRING20"),
+		} {
+			_, err := s.Verify(bad)
+			assert.Equal(t, ErrSignature, err, bad)
+		}
+	})
+
+	t.Run("Requires keys of the right size", func(t *testing.T) {
+		_, err := NewEd25519(seed[1:])
+		assert.Equal(t, ErrEd25519Key, err)
+		_, err = NewEd25519Verifier(seed[1:])
+		assert.Equal(t, ErrEd25519Key, err)
+	})
+}
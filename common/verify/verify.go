@@ -0,0 +1,93 @@
+// Package verify issues and checks signed voucher tokens. A token carries
+// everything a scanner needs to accept a voucher, so terminals holding the
+// verification key can check it offline and upload the redemption later.
+package verify
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/signing"
+)
+
+// prefix versions the payload of tokens
+const prefix = "v1."
+
+var (
+	// ErrToken is returned for tokens not signed with the key or not
+	// holding claims
+	ErrToken = errors.New("invalid token")
+	// ErrExpired is returned for genuine tokens past their expiry
+	ErrExpired = errors.New("token expired")
+)
+
+// Claims is what a token vouches for
+type Claims struct {
+	// Tenant owns the voucher, tokens of other tenants are refused
+	Tenant uint   `json:"tenant"`
+	Code   string `json:"code"`
+	Offer  uint   `json:"offer"`
+	// User the voucher was issued to, 0 for anyone
+	User uint `json:"user,omitempty"`
+	// Expires is the expiry of the voucher in Unix seconds
+	Expires int64 `json:"exp"`
+	// KeyID names the key of the signature, tokens without one were
+	// signed with the default key
+	KeyID string `json:"kid,omitempty"`
+}
+
+// Keys finds the key of a signature
+type Keys interface {
+	// Verifier returns the key with id when it was trusted at, the
+	// default key when id is empty
+	Verifier(id string, at time.Time) (signing.Verifier, bool)
+}
+
+// ExpiresAt returns the expiry of the voucher
+func (c *Claims) ExpiresAt() time.Time {
+	return time.Unix(c.Expires, 0).UTC()
+}
+
+// Issue returns the token of c signed with s. A token is
+//
+//	"v1." + base64url(JSON claims) + "." + signature
+//
+// without base64 padding, the signature being the one of the signer.
+func Issue(s signing.Signer, c *Claims) string {
+	b, _ := json.Marshal(c)
+	return s.Sign(prefix + base64.RawURLEncoding.EncodeToString(b))
+}
+
+// Check returns the claims of token when it was signed with the key it
+// names among keys, trusted at now. A token expired at now still returns
+// its claims along with ErrExpired.
+func Check(keys Keys, token string, now time.Time) (*Claims, error) {
+	token = strings.TrimSpace(token)
+	// the claims name the key, they are only trusted once it verified them
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 || !strings.HasPrefix(token, prefix) {
+		return nil, ErrToken
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token[len(prefix):i])
+	if err != nil {
+		return nil, ErrToken
+	}
+	var c Claims
+	if err := json.Unmarshal(b, &c); err != nil || c.Code == "" {
+		return nil, ErrToken
+	}
+	v, ok := keys.Verifier(c.KeyID, now)
+	if !ok {
+		return nil, ErrToken
+	}
+	if _, err := v.Verify(token); err != nil {
+		return nil, ErrToken
+	}
+	if !now.Before(c.ExpiresAt()) {
+		return &c, ErrExpired
+	}
+	return &c, nil
+}
@@ -0,0 +1,81 @@
+package verify
+
+import (
+	"crypto/ed25519"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/signing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keyMap is the keys of a terminal, by ID
+type keyMap map[string]signing.Verifier
+
+func (m keyMap) Verifier(id string, at time.Time) (signing.Verifier, bool) {
+	if id == "" {
+		id = "default"
+	}
+	v, ok := m[id]
+	return v, ok
+}
+
+func TestCheck(t *testing.T) {
+	s, err := signing.NewEd25519([]byte(strings.Repeat("s", ed25519.SeedSize)))
+	require.Nil(t, err)
+	v, err := signing.NewEd25519Verifier(s.PublicKey())
+	require.Nil(t, err)
+	hmac, err := signing.NewHMAC([]byte(strings.Repeat("k", signing.MinKeySize)))
+	require.Nil(t, err)
+	keys := keyMap{"default": v, "2020-03": hmac}
+	now := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	claims := &Claims{Tenant: 1, Code: "SPRING20", Offer: 7, User: 3, Expires: now.Add(time.Hour).Unix()}
+
+	t.Run("Checks a token with the public key", func(t *testing.T) {
+		token := Issue(s, claims)
+		assert.True(t, strings.HasPrefix(token, "v1."))
+
+		got, err := Check(keys, token, now)
+		assert.Nil(t, err)
+		assert.Equal(t, claims, got)
+		assert.Equal(t, now.Add(time.Hour), got.ExpiresAt())
+	})
+
+	t.Run("Returns the claims of expired tokens", func(t *testing.T) {
+		got, err := Check(keys, Issue(s, claims), now.Add(time.Hour))
+		assert.Equal(t, ErrExpired, err)
+		assert.Equal(t, claims, got)
+	})
+
+	t.Run("Checks a token with the key it names", func(t *testing.T) {
+		rotated := *claims
+		rotated.KeyID = "2020-03"
+
+		got, err := Check(keys, Issue(hmac, &rotated), now)
+		assert.Nil(t, err)
+		assert.Equal(t, &rotated, got)
+	})
+
+	t.Run("Rejects forged tokens", func(t *testing.T) {
+		unknown, other := *claims, *claims
+		unknown.KeyID, other.KeyID = "2019-01", "2020-03"
+
+		for _, bad := range []string{
+			"",
+			"SPRING20",
+			s.Sign("SPRING20"),
+			s.Sign("v1.!"),
+			s.Sign("v1.e30"), // {} has no code
+			Issue(hmac, claims),
+			Issue(s, &other),
+			Issue(s, &unknown),
+		} {
+			got, err := Check(keys, bad, now)
+			assert.Equal(t, ErrToken, err, bad)
+			assert.Nil(t, got)
+		}
+	})
+}
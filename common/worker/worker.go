@@ -0,0 +1,190 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+)
+
+// Func is the job run by a Worker on every tick
+type Func func(ctx context.Context) error
+
+// Status describes the state of a Worker
+type Status struct {
+	Name      string    `json:"name"`
+	Running   bool      `json:"running"`
+	Runs      int       `json:"runs"`
+	LastRun   time.Time `json:"last_run"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Worker runs a job periodically until stopped
+type Worker struct {
+	name     string
+	interval time.Duration
+	fn       Func
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New will instantiate a Worker running fn every interval. An interval that
+// is not positive is rejected: the worker is never started and fails the
+// checks of its group.
+func New(name string, interval time.Duration, fn Func) *Worker {
+	w := &Worker{
+		name:     name,
+		interval: interval,
+		fn:       fn,
+		status:   Status{Name: name},
+	}
+	if interval <= 0 {
+		w.status.LastError = fmt.Sprintf("interval must be positive, got %s", interval)
+	}
+	return w
+}
+
+// Status returns a snapshot of the worker state
+func (w *Worker) Status() Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+func (w *Worker) run(ctx context.Context) {
+	w.setRunning(true)
+	defer w.setRunning(false)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.tick(ctx)
+		}
+	}
+}
+
+func (w *Worker) tick(ctx context.Context) {
+	l := logger.FromContext(ctx).With(logger.Fields{"worker": w.name})
+	err := w.safeRun(ctx)
+
+	w.mu.Lock()
+	w.status.Runs++
+	w.status.LastRun = time.Now()
+	w.status.LastError = ""
+	if err != nil {
+		w.status.LastError = err.Error()
+	}
+	w.mu.Unlock()
+
+	if err != nil {
+		l.Error("worker run failed", logger.Fields{"error": err})
+		return
+	}
+	l.Debug("worker run")
+}
+
+// safeRun keeps a panicking job from taking the process down
+func (w *Worker) safeRun(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return w.fn(ctx)
+}
+
+func (w *Worker) setRunning(running bool) {
+	w.mu.Lock()
+	w.status.Running = running
+	w.mu.Unlock()
+}
+
+// Group starts and stops workers together
+type Group struct {
+	workers []*Worker
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewGroup will instantiate a Group of workers
+func NewGroup(workers ...*Worker) *Group {
+	return &Group{
+		workers: workers,
+	}
+}
+
+// Add registers w with the group. Workers must be added before Start.
+func (g *Group) Add(w *Worker) {
+	g.workers = append(g.workers, w)
+}
+
+// Start runs every worker in its own goroutine, but those of a rejected
+// interval
+func (g *Group) Start(ctx context.Context) {
+	ctx, g.cancel = context.WithCancel(ctx)
+	for _, w := range g.workers {
+		if w.interval <= 0 {
+			logger.FromContext(ctx).Error("worker not started", logger.Fields{
+				"worker": w.name,
+				"error":  w.Status().LastError,
+			})
+			continue
+		}
+		w.setRunning(true)
+		g.wg.Add(1)
+		go func(w *Worker) {
+			defer g.wg.Done()
+			w.run(ctx)
+		}(w)
+	}
+}
+
+// Stop cancels every worker and waits for running jobs to return, or for
+// ctx to be done
+func (g *Group) Stop(ctx context.Context) error {
+	if g.cancel != nil {
+		g.cancel()
+	}
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Status returns the status of every worker
+func (g *Group) Status() []Status {
+	out := make([]Status, 0, len(g.workers))
+	for _, w := range g.workers {
+		out = append(out, w.Status())
+	}
+	return out
+}
+
+// Check fails when a worker of the group is not running, for readiness
+// probes
+func (g *Group) Check(ctx context.Context) error {
+	for _, w := range g.workers {
+		s := w.Status()
+		switch {
+		case w.interval <= 0:
+			return fmt.Errorf("worker %s is not running: %s", s.Name, s.LastError)
+		case !s.Running:
+			return fmt.Errorf("worker %s is not running", s.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup(t *testing.T) {
+	t.Run("Runs workers until stopped", func(t *testing.T) {
+		var runs int32
+		w := New("counter", time.Millisecond, func(ctx context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			return nil
+		})
+		g := NewGroup(w)
+
+		assert.NotNil(t, g.Check(context.Background()))
+		g.Start(context.Background())
+		assert.Nil(t, g.Check(context.Background()))
+
+		assert.Eventually(t, func() bool { return atomic.LoadInt32(&runs) >= 3 }, time.Second, time.Millisecond)
+
+		assert.Nil(t, g.Stop(context.Background()))
+		assert.False(t, w.Status().Running)
+		assert.NotNil(t, g.Check(context.Background()))
+	})
+
+	t.Run("Records errors and recovers panics", func(t *testing.T) {
+		calls := int32(0)
+		w := New("flaky", time.Millisecond, func(ctx context.Context) error {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				panic("boom")
+			}
+			return errors.New("Nop")
+		})
+		g := NewGroup(w)
+		g.Start(context.Background())
+		defer g.Stop(context.Background())
+
+		assert.Eventually(t, func() bool { return w.Status().Runs >= 2 }, time.Second, time.Millisecond)
+		assert.Equal(t, "Nop", w.Status().LastError)
+		assert.True(t, w.Status().Running)
+	})
+
+	t.Run("Stop gives up when ctx is done", func(t *testing.T) {
+		release := make(chan struct{})
+		w := New("stuck", time.Millisecond, func(ctx context.Context) error {
+			<-release
+			return nil
+		})
+		g := NewGroup(w)
+		g.Start(context.Background())
+		time.Sleep(5 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, context.DeadlineExceeded, g.Stop(ctx))
+		close(release)
+	})
+
+	t.Run("Rejects intervals that are not positive", func(t *testing.T) {
+		var runs int32
+		w := New("notifications", 0, func(ctx context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			return nil
+		})
+		g := NewGroup(New("counter", time.Millisecond, func(ctx context.Context) error { return nil }), w)
+
+		g.Start(context.Background())
+		defer g.Stop(context.Background())
+
+		assert.False(t, w.Status().Running)
+		assert.EqualError(t, g.Check(context.Background()),
+			"worker notifications is not running: interval must be positive, got 0s")
+		time.Sleep(5 * time.Millisecond)
+		assert.Zero(t, atomic.LoadInt32(&runs))
+	})
+}
@@ -0,0 +1,23 @@
+package configs
+
+import "time"
+
+// Cache backends
+const (
+	CacheNone   = "none"
+	CacheMemory = "memory"
+	CacheRedis  = "redis"
+)
+
+var cacheBackends = []string{CacheNone, CacheMemory, CacheRedis}
+
+// CacheConfig object
+type CacheConfig struct {
+	Backend       string        `config:"backend" env:"CACHE_BACKEND"`
+	TTL           time.Duration `config:"ttl" env:"CACHE_TTL"`
+	Size          int           `config:"size" env:"CACHE_SIZE"`
+	RedisAddr     string        `config:"redis_addr" env:"REDIS_ADDR"`
+	RedisPassword Secret        `config:"redis_password" env:"REDIS_PASSWORD"`
+	RedisDB       int           `config:"redis_db" env:"REDIS_DB"`
+	RedisTimeout  time.Duration `config:"redis_timeout" env:"REDIS_TIMEOUT"`
+}
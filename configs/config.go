@@ -1,17 +1,42 @@
 package configs
 
-import "os"
+import (
+	"flag"
+	"os"
+	"time"
+)
 
 const (
 	prod = "production"
+	dev  = "development"
+	test = "test"
 )
 
-// Config object
+// Config object. Values are layered: defaults, then the profile of Env,
+// then the config file, then environment variables or the files of
+// secrets, then command line flags. The config tag names the key in YAML/TOML files, the env tag the
+// environment variable; flags are named after the env tag, e.g. --db-host.
 type Config struct {
-	Env      string         `env:"ENV"`
-	Postgres PostgresConfig `json:"postgres"`
-	Host     string         `env:"APP_HOST"`
-	Port     string         `env:"APP_PORT"`
+	Env       string          `config:"env" env:"ENV"`
+	Storage   StorageConfig   `config:"storage" json:"storage"`
+	Postgres  PostgresConfig  `config:"postgres" json:"postgres"`
+	RateLimit RateLimitConfig `config:"rate_limit" json:"rate_limit"`
+	Cache     CacheConfig     `config:"cache" json:"cache"`
+	GRPC      GRPCConfig      `config:"grpc" json:"grpc"`
+	Retention RetentionConfig `config:"retention" json:"retention"`
+	Tenant    TenantConfig    `config:"tenant" json:"tenant"`
+	Rates     RatesConfig     `config:"rates" json:"rates"`
+	Referral  ReferralConfig  `config:"referral" json:"referral"`
+	Trigger   TriggerConfig   `config:"trigger" json:"trigger"`
+	Notify    NotifyConfig    `config:"notify" json:"notify"`
+	Signing   SigningConfig   `config:"signing" json:"signing"`
+	Host      string          `config:"host" env:"APP_HOST"`
+	Port      string          `config:"port" env:"APP_PORT"`
+	LogLevel  string          `config:"log_level" env:"LOG_LEVEL"`
+
+	ReadTimeout     time.Duration `config:"read_timeout" env:"APP_READ_TIMEOUT"`
+	WriteTimeout    time.Duration `config:"write_timeout" env:"APP_WRITE_TIMEOUT"`
+	ShutdownTimeout time.Duration `config:"shutdown_timeout" env:"APP_SHUTDOWN_TIMEOUT"`
 }
 
 // IsProd Checks if env is production
@@ -19,12 +44,139 @@ func (c Config) IsProd() bool {
 	return c.Env == prod
 }
 
-// GetConfig gets all config for the application
-func GetConfig() Config {
+// Default returns the config used when nothing else is set
+func Default() Config {
 	return Config{
-		Env:      os.Getenv("ENV"),
-		Postgres: GetPostgresConfig(),
-		Host:     os.Getenv("APP_HOST"),
-		Port:     os.Getenv("APP_PORT"),
+		Env: dev,
+		Storage: StorageConfig{
+			Backend:    StoragePostgres,
+			SQLitePath: "voucher.db",
+		},
+		Postgres: defaultPostgresConfig(),
+		RateLimit: RateLimitConfig{
+			Backend:     "memory",
+			IPRate:      1,
+			IPBurst:     10,
+			APIKeyRate:  5,
+			APIKeyBurst: 50,
+			EmailRate:   0.2,
+			EmailBurst:  5,
+			MaxFailures: 5,
+			LockoutBase: time.Minute,
+			LockoutMax:  24 * time.Hour,
+		},
+		Cache: CacheConfig{
+			Backend:      CacheMemory,
+			TTL:          30 * time.Second,
+			Size:         10000,
+			RedisAddr:    "localhost:6379",
+			RedisTimeout: 500 * time.Millisecond,
+		},
+		Retention: RetentionConfig{
+			Period:        30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Rates: RatesConfig{
+			Source:  RatesNone,
+			TTL:     time.Hour,
+			Timeout: 5 * time.Second,
+		},
+		Referral: ReferralConfig{
+			VoucherValidity: 30 * 24 * time.Hour,
+			DomainLimit:     3,
+			DomainWindow:    24 * time.Hour,
+		},
+		Tenant: TenantConfig{
+			KeyGrace: 24 * time.Hour,
+		},
+		Trigger: TriggerConfig{
+			Interval: time.Hour,
+		},
+		Notify: NotifyConfig{
+			Email:       NotifyNone,
+			SMS:         NotifyNone,
+			Timeout:     10 * time.Second,
+			Interval:    10 * time.Second,
+			MaxAttempts: 5,
+			RetryBase:   time.Minute,
+			Locale:      "en",
+		},
+		Host:            "http://localhost",
+		Port:            "3000",
+		LogLevel:        "info",
+		ReadTimeout:     10 * time.Second,
+		WriteTimeout:    30 * time.Second,
+		ShutdownTimeout: 30 * time.Second,
+	}
+}
+
+// profiles adjust the defaults per environment
+var profiles = map[string]func(c *Config){
+	dev: func(c *Config) {
+		c.LogLevel = "debug"
+		c.Postgres.SSLMode = "disable"
+	},
+	test: func(c *Config) {
+		c.LogLevel = "warn"
+		c.Postgres.SSLMode = "disable"
+	},
+	prod: func(c *Config) {
+		c.LogLevel = "info"
+		c.Postgres.SSLMode = "require"
+		c.RateLimit.Backend = "postgres"
+	},
+}
+
+// GetConfig gets all config for the application from the process
+// environment and command line
+func GetConfig() (Config, error) {
+	return Load(os.Args[1:], os.Getenv)
+}
+
+// Load builds the config from command line args and getenv, reporting every
+// invalid value at once
+func Load(args []string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("go_voucher", flag.ContinueOnError)
+	path := fs.String("config", getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	flags := defineFlags(fs, &cfg)
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	var file map[string]interface{}
+	if *path != "" {
+		var err error
+		if file, err = readFile(*path); err != nil {
+			return cfg, err
+		}
+	}
+
+	// The profile must be known before the layers are applied on top of it
+	env := dev
+	if v, ok := file["env"].(string); ok && v != "" {
+		env = v
+	}
+	if v := getenv("ENV"); v != "" {
+		env = v
+	}
+	if v, ok := flags["ENV"]; ok {
+		env = v
+	}
+	cfg.Env = env
+	if apply, ok := profiles[env]; ok {
+		apply(&cfg)
+	}
+
+	var problems []string
+	problems = append(problems, applyFile(&cfg, file)...)
+	problems = append(problems, applyEnv(&cfg, getenv)...)
+	problems = append(problems, applySecretFiles(&cfg, getenv)...)
+	problems = append(problems, applyFlags(&cfg, flags)...)
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return cfg, &ValidationError{Problems: problems}
 	}
+	return cfg, nil
 }
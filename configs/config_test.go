@@ -0,0 +1,502 @@
+package configs
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(k string) string { return vars[k] }
+}
+
+// writeFile writes a config file into a temp dir, remove it with os.RemoveAll
+// on the returned dir
+func writeFile(t *testing.T, name, content string) (string, string) {
+	dir, err := ioutil.TempDir("", "configs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path, dir
+}
+
+var minimalEnv = map[string]string{
+	"DB_USER": "dev",
+	"DB_NAME": "base_dev",
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("Uses defaults and the development profile", func(t *testing.T) {
+		cfg, err := Load(nil, env(minimalEnv))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "development", cfg.Env)
+		assert.Equal(t, "debug", cfg.LogLevel)
+		assert.Equal(t, "3000", cfg.Port)
+		assert.Equal(t, "localhost", cfg.Postgres.Host)
+		assert.Equal(t, 5432, cfg.Postgres.Port)
+		assert.Equal(t, "disable", cfg.Postgres.SSLMode)
+		assert.Equal(t, 10*time.Second, cfg.ReadTimeout)
+	})
+
+	t.Run("Layers file, env and flags in order", func(t *testing.T) {
+		path, dir := writeFile(t, "config.yaml", `
+log_level: warn
+port: "4000"
+read_timeout: 5s
+postgres:
+  host: file-host
+  user: file-user
+  name: file-db
+  max_open_conns: 50
+rate_limit:
+  email_rate: 0.5
+`)
+		defer os.RemoveAll(dir)
+		cfg, err := Load(
+			[]string{"--config", path, "--db-host", "flag-host"},
+			env(map[string]string{"DB_HOST": "env-host", "DB_USER": "env-user", "APP_PORT": "5000"}),
+		)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "warn", cfg.LogLevel)
+		assert.Equal(t, "5000", cfg.Port)
+		assert.Equal(t, 5*time.Second, cfg.ReadTimeout)
+		assert.Equal(t, "flag-host", cfg.Postgres.Host)
+		assert.Equal(t, "env-user", cfg.Postgres.User)
+		assert.Equal(t, "file-db", cfg.Postgres.Name)
+		assert.Equal(t, 50, cfg.Postgres.MaxOpenConns)
+		assert.Equal(t, 0.5, cfg.RateLimit.EmailRate)
+	})
+
+	t.Run("Reads TOML files", func(t *testing.T) {
+		path, dir := writeFile(t, "config.toml", `
+env = "test"
+[postgres]
+user = "dev"
+name = "base_test"
+connect_timeout = "2s"
+`)
+		defer os.RemoveAll(dir)
+		cfg, err := Load(nil, env(map[string]string{"CONFIG_FILE": path}))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "test", cfg.Env)
+		assert.Equal(t, "warn", cfg.LogLevel)
+		assert.Equal(t, 2*time.Second, cfg.Postgres.ConnectTimeout)
+	})
+
+	t.Run("Applies the production profile", func(t *testing.T) {
+		cfg, err := Load([]string{"--env", "production"}, env(minimalEnv))
+
+		assert.Nil(t, err)
+		assert.True(t, cfg.IsProd())
+		assert.Equal(t, "require", cfg.Postgres.SSLMode)
+		assert.Equal(t, "postgres", cfg.RateLimit.Backend)
+	})
+
+	t.Run("Accepts DATABASE_URL instead of fields", func(t *testing.T) {
+		cfg, err := Load(nil, env(map[string]string{
+			"DATABASE_URL": "postgres://dev:secret@pg:5432/base_dev",
+		}))
+
+		assert.Nil(t, err)
+		assert.Equal(t,
+			"postgres://dev:secret@pg:5432/base_dev?connect_timeout=5&sslmode=disable",
+			cfg.Postgres.GetPostgresConnectionInfo())
+		assert.Equal(t,
+			"postgres://dev:[REDACTED]@pg:5432/base_dev?connect_timeout=5&sslmode=disable",
+			cfg.Postgres.String())
+	})
+
+	t.Run("Skips postgres settings for other storage backends", func(t *testing.T) {
+		cfg, err := Load([]string{"--storage-backend", "memory"}, env(nil))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "memory", cfg.Storage.Backend)
+
+		_, err = Load([]string{"--sqlite-path", ""}, env(map[string]string{
+			"STORAGE_BACKEND":    "sqlite",
+			"RATE_LIMIT_BACKEND": "postgres",
+		}))
+		verr, ok := err.(*ValidationError)
+		if !assert.True(t, ok, "expected ValidationError, got %v", err) {
+			return
+		}
+		assert.EqualValues(t, []string{
+			"SQLITE_PATH is required",
+			`RATE_LIMIT_BACKEND=postgres requires STORAGE_BACKEND=postgres, got "sqlite"`,
+		}, verr.Problems)
+	})
+
+	t.Run("Validates the cache settings of its backend", func(t *testing.T) {
+		_, err := Load([]string{"--cache-backend", "redis", "--redis-addr", "", "--cache-ttl", "0s"}, env(minimalEnv))
+
+		verr, ok := err.(*ValidationError)
+		if !assert.True(t, ok, "expected ValidationError, got %v", err) {
+			return
+		}
+		assert.EqualValues(t, []string{
+			"CACHE_TTL must be positive",
+			"REDIS_ADDR is required",
+		}, verr.Problems)
+	})
+
+	t.Run("Requires a positive retention", func(t *testing.T) {
+		cfg, err := Load(nil, env(minimalEnv))
+		assert.Nil(t, err)
+		assert.Equal(t, 720*time.Hour, cfg.Retention.Period)
+
+		_, err = Load([]string{"--retention-period", "0s", "--purge-interval", "-1m"}, env(minimalEnv))
+		verr, ok := err.(*ValidationError)
+		if !assert.True(t, ok, "expected ValidationError, got %v", err) {
+			return
+		}
+		assert.EqualValues(t, []string{
+			"RETENTION_PERIOD must be positive",
+			"PURGE_INTERVAL must be positive",
+		}, verr.Problems)
+	})
+
+	t.Run("Validates the tenant base domain", func(t *testing.T) {
+		cfg, err := Load([]string{"--tenant-base-domain", "vouchers.example.com"}, env(minimalEnv))
+		assert.Nil(t, err)
+		assert.Equal(t, "vouchers.example.com", cfg.Tenant.BaseDomain)
+
+		_, err = Load([]string{"--tenant-base-domain", "https://example.com:8080"}, env(minimalEnv))
+		verr, ok := err.(*ValidationError)
+		if !assert.True(t, ok, "expected ValidationError, got %v", err) {
+			return
+		}
+		assert.EqualValues(t, []string{
+			`TENANT_BASE_DOMAIN must be a domain name without scheme or port, got "https://example.com:8080"`,
+		}, verr.Problems)
+	})
+
+	t.Run("Validates the API key schedule", func(t *testing.T) {
+		cfg, err := Load([]string{"--api-key-ttl", "2160h"}, env(minimalEnv))
+		assert.Nil(t, err)
+		assert.Equal(t, 90*24*time.Hour, cfg.Tenant.KeyTTL)
+		assert.Equal(t, 24*time.Hour, cfg.Tenant.KeyGrace)
+
+		_, err = Load([]string{"--api-key-ttl", "-1h", "--api-key-grace", "0s", "--tenant-admin-key", "short"},
+			env(minimalEnv))
+		verr, ok := err.(*ValidationError)
+		if !assert.True(t, ok, "expected ValidationError, got %v", err) {
+			return
+		}
+		assert.EqualValues(t, []string{
+			"API_KEY_TTL must not be negative",
+			"API_KEY_GRACE must be positive",
+			"TENANT_ADMIN_KEY must be at least 32 characters",
+		}, verr.Problems)
+	})
+
+	t.Run("Validates the rate source", func(t *testing.T) {
+		cfg, err := Load([]string{"--rates-source", "http", "--rates-url", "https://rates.example.com/eur.json"}, env(minimalEnv))
+		assert.Nil(t, err)
+		assert.Equal(t, time.Hour, cfg.Rates.TTL)
+
+		_, err = Load([]string{"--rates-source", "file"}, env(minimalEnv))
+		verr, ok := err.(*ValidationError)
+		if !assert.True(t, ok, "expected ValidationError, got %v", err) {
+			return
+		}
+		assert.EqualValues(t, []string{"RATES_FILE is required"}, verr.Problems)
+
+		_, err = Load([]string{"--rates-source", "http", "--rates-url", "rates.example.com", "--rates-ttl", "0s"}, env(minimalEnv))
+		verr, ok = err.(*ValidationError)
+		if !assert.True(t, ok, "expected ValidationError, got %v", err) {
+			return
+		}
+		assert.EqualValues(t, []string{
+			`RATES_URL must be an http(s) URL, got "rates.example.com"`,
+			"RATES_TTL must be positive",
+		}, verr.Problems)
+	})
+
+	t.Run("Validates the referral policy", func(t *testing.T) {
+		cfg, err := Load([]string{"--referral-offer", "referral"}, env(minimalEnv))
+		assert.Nil(t, err)
+		assert.Equal(t, "referral", cfg.Referral.Offer)
+		assert.Equal(t, 3, cfg.Referral.DomainLimit)
+
+		_, err = Load([]string{"--referral-domain-limit", "0"}, env(minimalEnv))
+		verr, ok := err.(*ValidationError)
+		if !assert.True(t, ok, "expected ValidationError, got %v", err) {
+			return
+		}
+		assert.EqualValues(t, []string{"REFERRAL_DOMAIN_LIMIT must be positive"}, verr.Problems)
+	})
+
+	t.Run("Validates the trigger interval", func(t *testing.T) {
+		cfg, err := Load(nil, env(minimalEnv))
+		assert.Nil(t, err)
+		assert.Equal(t, time.Hour, cfg.Trigger.Interval)
+
+		_, err = Load([]string{"--trigger-interval", "0s"}, env(minimalEnv))
+		verr, ok := err.(*ValidationError)
+		if !assert.True(t, ok, "expected ValidationError, got %v", err) {
+			return
+		}
+		assert.EqualValues(t, []string{"TRIGGER_INTERVAL must be positive"}, verr.Problems)
+	})
+
+	t.Run("Validates the notifiers", func(t *testing.T) {
+		cfg, err := Load([]string{"--notify-email", "smtp", "--smtp-addr", "mail:587", "--smtp-from", "Vouchers <vouchers@example.com>",
+			"--notify-sms", "file", "--notify-file", "/tmp/sms.jsonl"}, env(minimalEnv))
+		assert.Nil(t, err)
+		assert.Equal(t, "mail:587", cfg.Notify.SMTPAddr)
+		assert.Equal(t, 5, cfg.Notify.MaxAttempts)
+		assert.Equal(t, "en", cfg.Notify.Locale)
+
+		_, err = Load([]string{"--notify-email", "smtp", "--smtp-addr", "mail", "--smtp-password", "secret",
+			"--notify-sms", "smtp", "--notify-max-attempts", "0"}, env(minimalEnv))
+		verr, ok := err.(*ValidationError)
+		if !assert.True(t, ok, "expected ValidationError, got %v", err) {
+			return
+		}
+		assert.EqualValues(t, []string{
+			`NOTIFY_SMS must be one of none, http, file, log, got "smtp"`,
+			`SMTP_ADDR must be a host:port, got "mail"`,
+			`SMTP_FROM must be an email address, got ""`,
+			"SMTP_USERNAME is required with SMTP_PASSWORD",
+			"NOTIFY_MAX_ATTEMPTS must be positive",
+		}, verr.Problems)
+
+		_, err = Load([]string{"--notify-sms", "http"}, env(minimalEnv))
+		verr, ok = err.(*ValidationError)
+		if !assert.True(t, ok, "expected ValidationError, got %v", err) {
+			return
+		}
+		assert.EqualValues(t, []string{`NOTIFY_HTTP_URL must be an http(s) URL, got ""`}, verr.Problems)
+
+		// the notifications worker runs without notifiers too
+		_, err = Load([]string{"--notify-interval", "0s"}, env(minimalEnv))
+		verr, ok = err.(*ValidationError)
+		if !assert.True(t, ok, "expected ValidationError, got %v", err) {
+			return
+		}
+		assert.EqualValues(t, []string{"NOTIFY_INTERVAL must be positive"}, verr.Problems)
+	})
+
+	t.Run("Validates the signing secret", func(t *testing.T) {
+		cfg, err := Load(nil, env(minimalEnv))
+		assert.Nil(t, err)
+		assert.Equal(t, Secret(""), cfg.Signing.Secret)
+
+		_, err = Load(nil, env(map[string]string{"DB_USER": "dev", "DB_NAME": "base_dev", "SIGNING_SECRET": "short"}))
+		verr, ok := err.(*ValidationError)
+		if !assert.True(t, ok, "expected ValidationError, got %v", err) {
+			return
+		}
+		assert.EqualValues(t, []string{"SIGNING_SECRET must be at least 32 bytes, got 5"}, verr.Problems)
+	})
+
+	t.Run("Decodes the signing private key", func(t *testing.T) {
+		seed := strings.Repeat("s", 32)
+		for _, encoded := range []string{base64.StdEncoding.EncodeToString([]byte(seed)),
+			base64.RawURLEncoding.EncodeToString([]byte(seed))} {
+			cfg, err := Load(nil, env(map[string]string{"DB_USER": "dev", "DB_NAME": "base_dev",
+				"SIGNING_PRIVATE_KEY": encoded}))
+			if !assert.Nil(t, err) {
+				return
+			}
+			key, err := cfg.Signing.Ed25519Key()
+			assert.Nil(t, err)
+			assert.Equal(t, []byte(seed), key)
+		}
+
+		for value, problem := range map[string]string{
+			"not base64!": "SIGNING_PRIVATE_KEY must be base64",
+			"c2hvcnQ":     "SIGNING_PRIVATE_KEY must be a 32 bytes seed or a 64 bytes private key, got 5 bytes",
+		} {
+			_, err := Load(nil, env(map[string]string{"DB_USER": "dev", "DB_NAME": "base_dev",
+				"SIGNING_PRIVATE_KEY": value}))
+			verr, ok := err.(*ValidationError)
+			if !assert.True(t, ok, "expected ValidationError, got %v", err) {
+				return
+			}
+			assert.EqualValues(t, []string{problem}, verr.Problems)
+		}
+	})
+
+	t.Run("Builds the signing key ring", func(t *testing.T) {
+		seed := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("s", 32)))
+		specs := `[{"id":"2020-03","algorithm":"hmac","key":"` +
+			base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))) + `","activate_at":"2020-03-01T00:00:00Z"}]`
+		cfg, err := Load(nil, env(map[string]string{"DB_USER": "dev", "DB_NAME": "base_dev",
+			"SIGNING_PRIVATE_KEY": seed, "SIGNING_KEYS": specs, "SIGNING_ROTATE_AFTER": "2160h"}))
+		if !assert.Nil(t, err) {
+			return
+		}
+		ring, err := cfg.Signing.Ring()
+		assert.Nil(t, err)
+		var ids []string
+		for _, k := range ring.Keys() {
+			ids = append(ids, k.ID)
+		}
+		assert.Equal(t, []string{"default", "2020-03"}, ids)
+		assert.Equal(t, 90*24*time.Hour, cfg.Signing.RotateAfter)
+
+		ring, err = Default().Signing.Ring()
+		assert.Nil(t, err)
+		assert.Nil(t, ring)
+
+		for value, problem := range map[string]string{
+			`{}`:                             "SIGNING_KEYS must be a JSON array of keys: json: cannot unmarshal object into Go value of type []keys.Spec",
+			`[{"id":"a","algorithm":"rsa"}]`: `SIGNING_KEYS key a: algorithm must be ed25519 or hmac, got "rsa"`,
+			`[{"id":"a","algorithm":"ed25519","key":"` + seed + `"},{"id":"a","algorithm":"ed25519","key":"` + seed + `"}]`: `SIGNING_KEYS IDs must be unique and not empty, "default" is the one of SIGNING_SECRET or SIGNING_PRIVATE_KEY`,
+			`[{"id":"a","algorithm":"ed25519","key":"` + seed + `","activate_at":"2999-01-01T00:00:00Z"}]`:                  "SIGNING_KEYS must have a key active now",
+		} {
+			_, err := Load(nil, env(map[string]string{"DB_USER": "dev", "DB_NAME": "base_dev",
+				"SIGNING_KEYS": value}))
+			verr, ok := err.(*ValidationError)
+			if !assert.True(t, ok, "expected ValidationError, got %v", err) {
+				return
+			}
+			assert.EqualValues(t, []string{problem}, verr.Problems)
+		}
+	})
+
+	t.Run("Reads secrets from files", func(t *testing.T) {
+		path, dir := writeFile(t, "db_password", "hunter2\n")
+		defer os.RemoveAll(dir)
+
+		cfg, err := Load(nil, env(map[string]string{"DB_USER": "dev", "DB_NAME": "base_dev",
+			"DB_PASSWORD_FILE": path}))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, "hunter2", cfg.Postgres.Password.Value())
+		assert.Contains(t, cfg.Postgres.GetPostgresConnectionInfo(), "password=hunter2")
+
+		_, err = Load(nil, env(map[string]string{"DB_USER": "dev", "DB_NAME": "base_dev",
+			"DB_PASSWORD": "hunter2", "DB_PASSWORD_FILE": path, "REDIS_PASSWORD_FILE": "/nonexistent/redis"}))
+		verr, ok := err.(*ValidationError)
+		if !assert.True(t, ok, "expected ValidationError, got %v", err) {
+			return
+		}
+		assert.EqualValues(t, []string{
+			"DB_PASSWORD and DB_PASSWORD_FILE are both set, use one",
+			"REDIS_PASSWORD_FILE: open /nonexistent/redis: no such file or directory",
+		}, verr.Problems)
+	})
+
+	t.Run("Requires API keys for gRPC", func(t *testing.T) {
+		cfg, err := Load(nil, env(map[string]string{
+			"DB_USER":       "dev",
+			"DB_NAME":       "base_dev",
+			"GRPC_PORT":     "9090",
+			"GRPC_API_KEYS": "checkout, crm,",
+		}))
+
+		assert.Nil(t, err)
+		assert.True(t, cfg.GRPC.Enabled())
+		assert.Equal(t, []string{"checkout", "crm"}, cfg.GRPC.Keys())
+
+		_, err = Load([]string{"--grpc-port", "3000"}, env(minimalEnv))
+		verr, ok := err.(*ValidationError)
+		if !assert.True(t, ok, "expected ValidationError, got %v", err) {
+			return
+		}
+		assert.EqualValues(t, []string{
+			"GRPC_PORT must differ from APP_PORT, both are 3000",
+			"GRPC_API_KEYS is required with GRPC_PORT",
+		}, verr.Problems)
+	})
+
+	t.Run("Loads the example config", func(t *testing.T) {
+		cfg, err := Load([]string{"--config", "../config.example.yaml"}, env(nil))
+		assert.Nil(t, err)
+		assert.Equal(t, 24*time.Hour, cfg.Tenant.KeyGrace)
+	})
+
+	t.Run("Lists every problem", func(t *testing.T) {
+		path, dir := writeFile(t, "config.yaml", `
+postgres:
+  hots: typo
+`)
+		defer os.RemoveAll(dir)
+		_, err := Load([]string{"--config", path}, env(map[string]string{
+			"ENV":                 "production",
+			"APP_PORT":            "http",
+			"DB_PORT":             "abc",
+			"DB_SSLMODE":          "disable",
+			"DB_SSLCERT":          "/nonexistent/client.crt",
+			"DB_MAX_OPEN_CONNS":   "2",
+			"DB_MAX_IDLE_CONNS":   "5",
+			"RATE_LIMIT_BACKEND":  "redis",
+			"REDEEM_LOCKOUT_BASE": "1h",
+			"REDEEM_LOCKOUT_MAX":  "1m",
+		}))
+
+		verr, ok := err.(*ValidationError)
+		if !assert.True(t, ok, "expected ValidationError, got %v", err) {
+			return
+		}
+		assert.EqualValues(t, []string{
+			"config file postgres.hots: unknown key",
+			`DB_PORT: invalid integer "abc"`,
+			`APP_PORT must be a port number, got "http"`,
+			"DB_USER is required",
+			"DB_NAME is required",
+			`DB_SSLMODE must be one of require, verify-ca, verify-full in production, got "disable"`,
+			"DB_SSLCERT and DB_SSLKEY must be set together",
+			"DB_SSLCERT: stat /nonexistent/client.crt: no such file or directory",
+			"DB_MAX_IDLE_CONNS (5) must not exceed DB_MAX_OPEN_CONNS (2)",
+			`RATE_LIMIT_BACKEND must be one of memory, postgres, got "redis"`,
+			"REDEEM_LOCKOUT_MAX (1m0s) must not be below REDEEM_LOCKOUT_BASE (1h0m0s)",
+		}, verr.Problems)
+	})
+}
+
+func TestGetPostgresConnectionInfo(t *testing.T) {
+	t.Run("Quotes values and adds SSL settings", func(t *testing.T) {
+		c := PostgresConfig{
+			Host:        "pg",
+			Port:        5432,
+			User:        "dev",
+			Password:    "it's secret",
+			Name:        "base_dev",
+			SSLMode:     "verify-full",
+			SSLRootCert: "/certs/root.crt",
+		}
+
+		assert.Equal(t,
+			`host=pg port=5432 user=dev password='it\'s secret' dbname=base_dev sslmode=verify-full sslrootcert=/certs/root.crt`,
+			c.GetPostgresConnectionInfo())
+		assert.Equal(t,
+			`host=pg port=5432 user=dev password=[REDACTED] dbname=base_dev sslmode=verify-full sslrootcert=/certs/root.crt`,
+			c.String())
+	})
+
+	t.Run("Keeps parameters set in DATABASE_URL", func(t *testing.T) {
+		c := PostgresConfig{URL: "postgres://pg/base_dev?sslmode=require", SSLMode: "disable"}
+
+		assert.Equal(t, "postgres://pg/base_dev?sslmode=require", c.GetPostgresConnectionInfo())
+	})
+}
+
+func TestSecret(t *testing.T) {
+	c := NotifyConfig{SMTPUsername: "mailer", SMTPPassword: "hunter2"}
+
+	assert.Equal(t, "hunter2", c.SMTPPassword.Value())
+	assert.NotContains(t, fmt.Sprintf("%v %+v", c, c), "hunter2")
+	b, err := json.Marshal(c)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(b), "hunter2")
+	assert.Contains(t, string(b), `"SMTPPassword":"[REDACTED]","HTTPURL":"","HTTPToken":""`)
+}
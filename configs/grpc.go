@@ -0,0 +1,28 @@
+package configs
+
+import "strings"
+
+// GRPCConfig object
+type GRPCConfig struct {
+	// Port of the gRPC server, empty disables it
+	Port string `config:"port" env:"GRPC_PORT"`
+	// APIKeys is a comma separated list of keys clients must send, each in
+	// plain text or hashed as sha256:<hex digest>
+	APIKeys Secret `config:"api_keys" env:"GRPC_API_KEYS"`
+}
+
+// Enabled reports whether the gRPC server should run
+func (c GRPCConfig) Enabled() bool {
+	return c.Port != ""
+}
+
+// Keys returns the API keys accepted by the gRPC server
+func (c GRPCConfig) Keys() []string {
+	var keys []string
+	for _, k := range strings.Split(c.APIKeys.Value(), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
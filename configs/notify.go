@@ -0,0 +1,47 @@
+package configs
+
+import "time"
+
+// Notifier backends
+const (
+	NotifyNone = "none"
+	NotifySMTP = "smtp"
+	NotifyHTTP = "http"
+	NotifyFile = "file"
+	NotifyLog  = "log"
+)
+
+var (
+	emailNotifiers = []string{NotifyNone, NotifySMTP, NotifyHTTP, NotifyFile, NotifyLog}
+	smsNotifiers   = []string{NotifyNone, NotifyHTTP, NotifyFile, NotifyLog}
+)
+
+// NotifyConfig object
+type NotifyConfig struct {
+	// Email and SMS pick the notifier of each channel, none does not send
+	// on it
+	Email string `config:"email" env:"NOTIFY_EMAIL"`
+	SMS   string `config:"sms" env:"NOTIFY_SMS"`
+
+	SMTPAddr     string `config:"smtp_addr" env:"SMTP_ADDR"`
+	SMTPFrom     string `config:"smtp_from" env:"SMTP_FROM"`
+	SMTPUsername string `config:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword Secret `config:"smtp_password" env:"SMTP_PASSWORD"`
+
+	// HTTPURL is the provider messages of the http notifier are posted to,
+	// with HTTPToken as bearer token
+	HTTPURL   string `config:"http_url" env:"NOTIFY_HTTP_URL"`
+	HTTPToken Secret `config:"http_token" env:"NOTIFY_HTTP_TOKEN"`
+	// File is where the file notifier appends messages, one JSON per line
+	File    string        `config:"file" env:"NOTIFY_FILE"`
+	Timeout time.Duration `config:"timeout" env:"NOTIFY_TIMEOUT"`
+
+	// Interval is how often the deliveries due are sent
+	Interval time.Duration `config:"interval" env:"NOTIFY_INTERVAL"`
+	// MaxAttempts is the number of attempts after which a delivery fails,
+	// the wait between them starts at RetryBase and doubles
+	MaxAttempts int           `config:"max_attempts" env:"NOTIFY_MAX_ATTEMPTS"`
+	RetryBase   time.Duration `config:"retry_base" env:"NOTIFY_RETRY_BASE"`
+	// Locale is the language of users without one
+	Locale string `config:"locale" env:"NOTIFY_LOCALE"`
+}
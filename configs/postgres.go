@@ -2,17 +2,43 @@ package configs
 
 import (
 	"fmt"
-	"os"
-	"strconv"
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"net/url"
+	"strings"
+	"time"
 )
 
-// PostgresConfig object
+// PostgresConfig object. URL, from DATABASE_URL, takes precedence over the
+// individual connection fields.
 type PostgresConfig struct {
-	Host     string `env:"DB_HOST"`
-	Port     int    `env:"DB_PORT"`
-	User     string `env:"DB_USER"`
-	Password string `env:"DB_PASSWORD"`
-	Name     string `env:"DB_NAME"`
+	URL      string `config:"url" env:"DATABASE_URL"`
+	Host     string `config:"host" env:"DB_HOST"`
+	Port     int    `config:"port" env:"DB_PORT"`
+	User     string `config:"user" env:"DB_USER"`
+	Password Secret `config:"password" env:"DB_PASSWORD"`
+	Name     string `config:"name" env:"DB_NAME"`
+
+	SSLMode     string `config:"sslmode" env:"DB_SSLMODE"`
+	SSLCert     string `config:"sslcert" env:"DB_SSLCERT"`
+	SSLKey      string `config:"sslkey" env:"DB_SSLKEY"`
+	SSLRootCert string `config:"sslrootcert" env:"DB_SSLROOTCERT"`
+
+	ConnectTimeout  time.Duration `config:"connect_timeout" env:"DB_CONNECT_TIMEOUT"`
+	MaxOpenConns    int           `config:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `config:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `config:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+}
+
+func defaultPostgresConfig() PostgresConfig {
+	return PostgresConfig{
+		Host:            "localhost",
+		Port:            5432,
+		SSLMode:         "disable",
+		ConnectTimeout:  5 * time.Second,
+		MaxOpenConns:    25,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: 30 * time.Minute,
+	}
 }
 
 // Dialect returns "postgres"
@@ -20,30 +46,73 @@ func (c PostgresConfig) Dialect() string {
 	return "postgres"
 }
 
-// GetPostgresConnectionInfo returns Postgres URL string
+// GetPostgresConnectionInfo returns Postgres URL string. SSL and timeout
+// settings are added to DATABASE_URL unless it already sets them.
 func (c PostgresConfig) GetPostgresConnectionInfo() string {
-	if c.Password == "" {
-		return fmt.Sprintf(
-			"host=%s port=%d user=%s dbname=%s sslmode=disable",
-			c.Host, c.Port, c.User, c.Name)
+	if c.URL != "" {
+		return c.urlConnectionInfo()
+	}
+
+	pairs := []string{
+		"host=" + quoteValue(c.Host),
+		fmt.Sprintf("port=%d", c.Port),
+		"user=" + quoteValue(c.User),
+	}
+	if c.Password != "" {
+		pairs = append(pairs, "password="+quoteValue(c.Password.Value()))
+	}
+	pairs = append(pairs, "dbname="+quoteValue(c.Name))
+	for _, p := range c.params() {
+		pairs = append(pairs, p[0]+"="+quoteValue(p[1]))
 	}
-	return fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		c.Host, c.Port, c.User, c.Password, c.Name)
+	return strings.Join(pairs, " ")
 }
 
-// GetPostgresConfig returns PostgresConfig object
-func GetPostgresConfig() PostgresConfig {
-	port, err := strconv.Atoi(os.Getenv("DB_PORT"))
+// String returns the connection info with the password masked, so the
+// config can be printed or logged safely
+func (c PostgresConfig) String() string {
+	return logger.Redact(c.GetPostgresConnectionInfo())
+}
+
+func (c PostgresConfig) urlConnectionInfo() string {
+	u, err := url.Parse(c.URL)
 	if err != nil {
-		panic(err)
+		return c.URL
+	}
+	q := u.Query()
+	for _, p := range c.params() {
+		if q.Get(p[0]) == "" {
+			q.Set(p[0], p[1])
+		}
 	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
 
-	return PostgresConfig{
-		Host:     os.Getenv("DB_HOST"),
-		Port:     port,
-		User:     os.Getenv("DB_USER"),
-		Password: os.Getenv("DB_PASSWORD"),
-		Name:     os.Getenv("DB_NAME"),
+// params returns the optional connection parameters that are set
+func (c PostgresConfig) params() [][2]string {
+	var out [][2]string
+	add := func(k, v string) {
+		if v != "" {
+			out = append(out, [2]string{k, v})
+		}
+	}
+	add("sslmode", c.SSLMode)
+	add("sslcert", c.SSLCert)
+	add("sslkey", c.SSLKey)
+	add("sslrootcert", c.SSLRootCert)
+	if c.ConnectTimeout > 0 {
+		add("connect_timeout", fmt.Sprintf("%d", int(c.ConnectTimeout.Seconds())))
+	}
+	return out
+}
+
+// quoteValue quotes a key/value connection string value when needed
+func quoteValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
 	}
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, `'`, `\'`, -1)
+	return "'" + v + "'"
 }
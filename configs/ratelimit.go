@@ -0,0 +1,17 @@
+package configs
+
+import "time"
+
+// RateLimitConfig object
+type RateLimitConfig struct {
+	Backend     string        `config:"backend" env:"RATE_LIMIT_BACKEND"`
+	IPRate      float64       `config:"ip_rate" env:"RATE_LIMIT_IP_RATE"`
+	IPBurst     int           `config:"ip_burst" env:"RATE_LIMIT_IP_BURST"`
+	APIKeyRate  float64       `config:"api_key_rate" env:"RATE_LIMIT_API_KEY_RATE"`
+	APIKeyBurst int           `config:"api_key_burst" env:"RATE_LIMIT_API_KEY_BURST"`
+	EmailRate   float64       `config:"email_rate" env:"RATE_LIMIT_EMAIL_RATE"`
+	EmailBurst  int           `config:"email_burst" env:"RATE_LIMIT_EMAIL_BURST"`
+	MaxFailures int           `config:"max_failures" env:"REDEEM_MAX_FAILURES"`
+	LockoutBase time.Duration `config:"lockout_base" env:"REDEEM_LOCKOUT_BASE"`
+	LockoutMax  time.Duration `config:"lockout_max" env:"REDEEM_LOCKOUT_MAX"`
+}
@@ -0,0 +1,25 @@
+package configs
+
+import "time"
+
+// Sources of exchange rates
+const (
+	RatesNone = "none"
+	RatesFile = "file"
+	RatesHTTP = "http"
+)
+
+var ratesSources = []string{RatesNone, RatesFile, RatesHTTP}
+
+// RatesConfig object
+type RatesConfig struct {
+	// Source of the rates fixed discounts are converted with, none only
+	// applies them in the currencies they have an amount in
+	Source string `config:"source" env:"RATES_SOURCE"`
+	// File is a JSON rate table, read once at start
+	File string `config:"file" env:"RATES_FILE"`
+	// URL serves a JSON rate table, fetched again once TTL is over
+	URL     string        `config:"url" env:"RATES_URL"`
+	TTL     time.Duration `config:"ttl" env:"RATES_TTL"`
+	Timeout time.Duration `config:"timeout" env:"RATES_TIMEOUT"`
+}
@@ -0,0 +1,16 @@
+package configs
+
+import "time"
+
+// ReferralConfig object
+type ReferralConfig struct {
+	// Offer names the offer referrer and referee get a voucher of once the
+	// referee redeems a first voucher, empty leaves referrals unrewarded
+	Offer string `config:"offer" env:"REFERRAL_OFFER"`
+	// VoucherValidity is how long the reward vouchers are valid for
+	VoucherValidity time.Duration `config:"voucher_validity" env:"REFERRAL_VOUCHER_VALIDITY"`
+	// DomainLimit referees of one email domain are accepted per referrer
+	// within DomainWindow, the next ones are rejected
+	DomainLimit  int           `config:"domain_limit" env:"REFERRAL_DOMAIN_LIMIT"`
+	DomainWindow time.Duration `config:"domain_window" env:"REFERRAL_DOMAIN_WINDOW"`
+}
@@ -0,0 +1,12 @@
+package configs
+
+import "time"
+
+// RetentionConfig object
+type RetentionConfig struct {
+	// Period soft deleted users, offers and vouchers are kept for before
+	// being purged for good
+	Period time.Duration `config:"period" env:"RETENTION_PERIOD"`
+	// PurgeInterval is how often the purge job runs
+	PurgeInterval time.Duration `config:"purge_interval" env:"PURGE_INTERVAL"`
+}
@@ -0,0 +1,68 @@
+package configs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+)
+
+// fileSuffix is appended to the env var of a secret to name the file
+// holding it, e.g. DB_PASSWORD_FILE
+const fileSuffix = "_FILE"
+
+var secretType = reflect.TypeOf(Secret(""))
+
+// Secret is a config value that is never printed: String and MarshalJSON
+// redact it, Value returns it. Besides the usual layers, a secret is read
+// from the file named by its env var with _FILE appended, the way Docker
+// and Kubernetes mount secrets.
+type Secret string
+
+// Value returns the secret itself
+func (s Secret) Value() string {
+	return string(s)
+}
+
+// String returns the secret redacted, empty when it is not set
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return logger.Redacted
+}
+
+// MarshalJSON encodes the secret redacted
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// applySecretFiles reads the secrets named by the _FILE env vars. A secret
+// given both ways is reported rather than picked.
+func applySecretFiles(cfg *Config, getenv func(string) string) []string {
+	var problems []string
+	for _, f := range fields(cfg) {
+		if f.env == "" || f.value.Type() != secretType {
+			continue
+		}
+		path := getenv(f.env + fileSuffix)
+		if path == "" {
+			continue
+		}
+		if getenv(f.env) != "" {
+			problems = append(problems, fmt.Sprintf("%s and %s%s are both set, use one", f.env, f.env, fileSuffix))
+			continue
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s%s: %v", f.env, fileSuffix, err))
+			continue
+		}
+		// files usually end with a newline that is not part of the secret
+		f.value.SetString(strings.TrimRight(string(b), "\r\n"))
+	}
+	return problems
+}
@@ -0,0 +1,93 @@
+package configs
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/keys"
+	"github.com/deepinbytes/go_voucher/common/signing"
+)
+
+// SigningConfig object
+type SigningConfig struct {
+	// Secret is the HMAC key of the signed payloads of printed codes, empty
+	// disables signing
+	Secret Secret `config:"secret" env:"SIGNING_SECRET"`
+	// PrivateKey is a base64 Ed25519 seed or private key, preferred over
+	// Secret so terminals check tokens with the public key alone
+	PrivateKey Secret `config:"private_key" env:"SIGNING_PRIVATE_KEY"`
+	// Keys is a JSON array of key specs with IDs and a rotation schedule,
+	// alongside the default key of Secret or PrivateKey
+	Keys Secret `config:"keys" env:"SIGNING_KEYS"`
+	// RotateAfter is the age past which the active key is due for
+	// rotation, 0 never warns
+	RotateAfter time.Duration `config:"rotate_after" env:"SIGNING_ROTATE_AFTER"`
+}
+
+// Ed25519Key decodes PrivateKey, standard or URL base64 with or without
+// padding
+func (c SigningConfig) Ed25519Key() ([]byte, error) {
+	s := strings.TrimRight(strings.TrimSpace(c.PrivateKey.Value()), "=")
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+// Ring returns the signing keys, nil when signing is disabled. The key of
+// PrivateKey, or else Secret, has ID keys.DefaultID.
+func (c SigningConfig) Ring() (*keys.Ring, error) {
+	var ks []*keys.Key
+	if s, err := c.defaultSigner(); err != nil {
+		return nil, err
+	} else if s != nil {
+		ks = append(ks, &keys.Key{ID: keys.DefaultID, Signer: s})
+	}
+	if c.Keys != "" {
+		specs, err := keys.ParseSpecs(c.Keys.Value())
+		if err != nil {
+			return nil, fmt.Errorf("SIGNING_KEYS must be a JSON array of keys: %v", err)
+		}
+		for _, spec := range specs {
+			k, err := keys.NewKey(spec)
+			if err != nil {
+				return nil, fmt.Errorf("SIGNING_KEYS %v", err)
+			}
+			ks = append(ks, k)
+		}
+	}
+	if len(ks) == 0 {
+		return nil, nil
+	}
+	ring, err := keys.NewRing(ks...)
+	if err == keys.ErrKeyID {
+		return nil, fmt.Errorf("SIGNING_KEYS IDs must be unique and not empty, %q is the one of SIGNING_SECRET or SIGNING_PRIVATE_KEY", keys.DefaultID)
+	}
+	return ring, err
+}
+
+// defaultSigner returns the signer of PrivateKey or Secret, nil when
+// neither is set
+func (c SigningConfig) defaultSigner() (signing.Signer, error) {
+	if c.PrivateKey != "" {
+		key, err := c.Ed25519Key()
+		if err != nil {
+			return nil, fmt.Errorf("SIGNING_PRIVATE_KEY must be base64")
+		}
+		s, err := signing.NewEd25519(key)
+		if err != nil {
+			return nil, fmt.Errorf("SIGNING_PRIVATE_KEY must be a 32 bytes seed or a 64 bytes private key, got %d bytes", len(key))
+		}
+		return s, nil
+	}
+	if c.Secret != "" {
+		s, err := signing.NewHMAC([]byte(c.Secret.Value()))
+		if err != nil {
+			return nil, fmt.Errorf("SIGNING_SECRET must be at least %d bytes, got %d", signing.MinKeySize, len(c.Secret))
+		}
+		return s, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,239 @@
+package configs
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// field is a settable leaf of the config struct
+type field struct {
+	path  string // dotted config file key, e.g. postgres.host
+	env   string
+	value reflect.Value
+}
+
+// fields walks cfg and returns every leaf with its file key and env var
+func fields(cfg *Config) []field {
+	var out []field
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			key := sf.Tag.Get("config")
+			if key == "" {
+				continue
+			}
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			fv := v.Field(i)
+			if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+				walk(fv, key)
+				continue
+			}
+			out = append(out, field{path: key, env: sf.Tag.Get("env"), value: fv})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return out
+}
+
+// set parses raw into the field according to its type
+func (f field) set(raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch {
+	case f.value.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		f.value.SetInt(int64(d))
+	case f.value.Kind() == reflect.String:
+		f.value.SetString(raw)
+	case f.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		f.value.SetInt(int64(n))
+	case f.value.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		f.value.SetFloat(n)
+	case f.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		f.value.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", f.value.Type())
+	}
+	return nil
+}
+
+// readFile decodes a YAML or TOML file, picked by extension, into a tree of
+// maps
+func readFile(path string) (map[string]interface{}, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var raw map[interface{}]interface{}
+		if err := yaml.Unmarshal(b, &raw); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		out = normalizeYAML(raw)
+	case ".toml":
+		if _, err := toml.Decode(string(b), &out); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported config file type, use .yaml, .yml or .toml", path)
+	}
+	return out, nil
+}
+
+// normalizeYAML converts the map[interface{}]interface{} yaml.v2 produces
+// for nested mappings into map[string]interface{}
+func normalizeYAML(in map[interface{}]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(in))
+	for k, v := range in {
+		if m, ok := v.(map[interface{}]interface{}); ok {
+			v = normalizeYAML(m)
+		}
+		out[fmt.Sprint(k)] = v
+	}
+	return out
+}
+
+// flatten turns a tree of maps into dotted keys
+func flatten(in map[string]interface{}, prefix string, out map[string]interface{}) {
+	for k, v := range in {
+		if prefix != "" {
+			k = prefix + "." + k
+		}
+		if m, ok := v.(map[string]interface{}); ok {
+			flatten(m, k, out)
+			continue
+		}
+		if v == nil {
+			// a section holding only comments
+			continue
+		}
+		out[k] = v
+	}
+}
+
+func applyFile(cfg *Config, file map[string]interface{}) []string {
+	values := map[string]interface{}{}
+	flatten(file, "", values)
+
+	var problems []string
+	for _, f := range fields(cfg) {
+		v, ok := values[f.path]
+		if !ok {
+			continue
+		}
+		delete(values, f.path)
+		if err := f.set(fmt.Sprint(v)); err != nil {
+			problems = append(problems, fmt.Sprintf("config file %s: %v", f.path, err))
+		}
+	}
+
+	var unknown []string
+	for k := range values {
+		unknown = append(unknown, k)
+	}
+	sort.Strings(unknown)
+	for _, k := range unknown {
+		problems = append(problems, fmt.Sprintf("config file %s: unknown key", k))
+	}
+	return problems
+}
+
+func applyEnv(cfg *Config, getenv func(string) string) []string {
+	var problems []string
+	for _, f := range fields(cfg) {
+		if f.env == "" {
+			continue
+		}
+		v := getenv(f.env)
+		if v == "" {
+			continue
+		}
+		if err := f.set(v); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", f.env, err))
+		}
+	}
+	return problems
+}
+
+// flagName derives the flag of an env var, DB_HOST becomes db-host
+func flagName(env string) string {
+	return strings.ToLower(strings.Replace(env, "_", "-", -1))
+}
+
+// defineFlags registers a string flag per env var. The returned map is
+// filled by fs.Parse with the flags that were given, keyed by env var.
+func defineFlags(fs *flag.FlagSet, cfg *Config) map[string]string {
+	given := map[string]string{}
+	for _, f := range fields(cfg) {
+		if f.env == "" {
+			continue
+		}
+		fs.Var(&flagValue{env: f.env, given: given}, flagName(f.env), "overrides "+f.env)
+	}
+	return given
+}
+
+// flagValue records a flag under its env var when set
+type flagValue struct {
+	env   string
+	given map[string]string
+}
+
+func (v *flagValue) String() string {
+	if v.given == nil {
+		return ""
+	}
+	return v.given[v.env]
+}
+
+func (v *flagValue) Set(s string) error {
+	v.given[v.env] = s
+	return nil
+}
+
+func applyFlags(cfg *Config, given map[string]string) []string {
+	var problems []string
+	for _, f := range fields(cfg) {
+		v, ok := given[f.env]
+		if !ok {
+			continue
+		}
+		if err := f.set(v); err != nil {
+			problems = append(problems, fmt.Sprintf("--%s: %v", flagName(f.env), err))
+		}
+	}
+	return problems
+}
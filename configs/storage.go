@@ -0,0 +1,16 @@
+package configs
+
+// Storage backends
+const (
+	StoragePostgres = "postgres"
+	StorageSQLite   = "sqlite"
+	StorageMemory   = "memory"
+)
+
+var storageBackends = []string{StoragePostgres, StorageSQLite, StorageMemory}
+
+// StorageConfig object
+type StorageConfig struct {
+	Backend    string `config:"backend" env:"STORAGE_BACKEND"`
+	SQLitePath string `config:"sqlite_path" env:"SQLITE_PATH"`
+}
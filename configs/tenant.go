@@ -0,0 +1,22 @@
+package configs
+
+import "time"
+
+// minAdminKey is the shortest TENANT_ADMIN_KEY accepted, generated keys are
+// 48 characters
+const minAdminKey = 32
+
+// TenantConfig object
+type TenantConfig struct {
+	// BaseDomain serves a tenant per subdomain, e.g. acme.vouchers.example.com
+	// for the tenant acme under vouchers.example.com. Empty resolves tenants
+	// by header only.
+	BaseDomain string `config:"base_domain" env:"TENANT_BASE_DOMAIN"`
+	// KeyTTL is how long new API keys authenticate, 0 for ever
+	KeyTTL time.Duration `config:"key_ttl" env:"API_KEY_TTL"`
+	// KeyGrace is how long a rotated API key keeps authenticating
+	KeyGrace time.Duration `config:"key_grace" env:"API_KEY_GRACE"`
+	// AdminKey is stored as the API key of the default tenant while it has
+	// none, it is needed to create and list tenants
+	AdminKey Secret `config:"admin_key" env:"TENANT_ADMIN_KEY"`
+}
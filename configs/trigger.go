@@ -0,0 +1,10 @@
+package configs
+
+import "time"
+
+// TriggerConfig object
+type TriggerConfig struct {
+	// Interval is how often the birthday triggers are checked, a user gets
+	// one voucher per birthday however often they run
+	Interval time.Duration `config:"interval" env:"TRIGGER_INTERVAL"`
+}
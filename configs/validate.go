@@ -0,0 +1,305 @@
+package configs
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	logLevels = []string{"debug", "info", "warn", "error"}
+	sslModes  = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	// prodSSLModes are the modes that never fall back to plain text
+	prodSSLModes = []string{"require", "verify-ca", "verify-full"}
+)
+
+// ValidationError lists every invalid config value
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+func (c Config) validate() []string {
+	var p problems
+
+	if _, ok := profiles[c.Env]; !ok {
+		p.add("ENV must be one of %s, got %q", oneOf(profileNames()), c.Env)
+	}
+	if n, err := strconv.Atoi(c.Port); err != nil || n < 1 || n > 65535 {
+		p.add("APP_PORT must be a port number, got %q", c.Port)
+	}
+	if !contains(logLevels, c.LogLevel) {
+		p.add("LOG_LEVEL must be one of %s, got %q", oneOf(logLevels), c.LogLevel)
+	}
+	p.positive("APP_READ_TIMEOUT", int64(c.ReadTimeout))
+	p.positive("APP_WRITE_TIMEOUT", int64(c.WriteTimeout))
+	p.positive("APP_SHUTDOWN_TIMEOUT", int64(c.ShutdownTimeout))
+
+	c.Storage.validate(&p)
+	if c.Storage.Backend == StoragePostgres {
+		c.Postgres.validate(&p, c.IsProd())
+	} else if c.RateLimit.Backend == "postgres" {
+		p.add("RATE_LIMIT_BACKEND=postgres requires STORAGE_BACKEND=postgres, got %q", c.Storage.Backend)
+	}
+	c.RateLimit.validate(&p)
+	c.Cache.validate(&p)
+	c.GRPC.validate(&p, c.Port)
+	p.positive("RETENTION_PERIOD", int64(c.Retention.Period))
+	p.positive("PURGE_INTERVAL", int64(c.Retention.PurgeInterval))
+	c.Tenant.validate(&p)
+	c.Rates.validate(&p)
+	p.positive("REFERRAL_VOUCHER_VALIDITY", int64(c.Referral.VoucherValidity))
+	p.positive("REFERRAL_DOMAIN_LIMIT", int64(c.Referral.DomainLimit))
+	p.positive("REFERRAL_DOMAIN_WINDOW", int64(c.Referral.DomainWindow))
+	p.positive("TRIGGER_INTERVAL", int64(c.Trigger.Interval))
+	c.Notify.validate(&p)
+	c.Signing.validate(&p)
+	return p
+}
+
+func (c SigningConfig) validate(p *problems) {
+	ring, err := c.Ring()
+	if err != nil {
+		p.add("%v", err)
+	} else if ring != nil {
+		if _, err := ring.Active(time.Now()); err != nil {
+			p.add("SIGNING_KEYS must have a key active now")
+		}
+	}
+	p.nonNegative("SIGNING_ROTATE_AFTER", int64(c.RotateAfter))
+}
+
+func (c StorageConfig) validate(p *problems) {
+	if !contains(storageBackends, c.Backend) {
+		p.add("STORAGE_BACKEND must be one of %s, got %q", oneOf(storageBackends), c.Backend)
+	}
+	if c.Backend == StorageSQLite {
+		p.required("SQLITE_PATH", c.SQLitePath)
+	}
+}
+
+func (c PostgresConfig) validate(p *problems, isProd bool) {
+	if c.URL != "" {
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") || u.Host == "" {
+			p.add("DATABASE_URL must be a postgres:// URL")
+		}
+	} else {
+		p.required("DB_HOST", c.Host)
+		p.required("DB_USER", c.User)
+		p.required("DB_NAME", c.Name)
+		if c.Port < 1 || c.Port > 65535 {
+			p.add("DB_PORT must be a port number, got %d", c.Port)
+		}
+	}
+
+	if !contains(sslModes, c.SSLMode) {
+		p.add("DB_SSLMODE must be one of %s, got %q", oneOf(sslModes), c.SSLMode)
+	} else if isProd && !contains(prodSSLModes, c.SSLMode) {
+		p.add("DB_SSLMODE must be one of %s in production, got %q", oneOf(prodSSLModes), c.SSLMode)
+	}
+	if (c.SSLMode == "verify-ca" || c.SSLMode == "verify-full") && c.SSLRootCert == "" {
+		p.add("DB_SSLROOTCERT is required with DB_SSLMODE=%s", c.SSLMode)
+	}
+	if (c.SSLCert == "") != (c.SSLKey == "") {
+		p.add("DB_SSLCERT and DB_SSLKEY must be set together")
+	}
+	p.file("DB_SSLCERT", c.SSLCert)
+	p.file("DB_SSLKEY", c.SSLKey)
+	p.file("DB_SSLROOTCERT", c.SSLRootCert)
+
+	p.nonNegative("DB_CONNECT_TIMEOUT", int64(c.ConnectTimeout))
+	p.nonNegative("DB_MAX_OPEN_CONNS", int64(c.MaxOpenConns))
+	p.nonNegative("DB_MAX_IDLE_CONNS", int64(c.MaxIdleConns))
+	p.nonNegative("DB_CONN_MAX_LIFETIME", int64(c.ConnMaxLifetime))
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		p.add("DB_MAX_IDLE_CONNS (%d) must not exceed DB_MAX_OPEN_CONNS (%d)", c.MaxIdleConns, c.MaxOpenConns)
+	}
+}
+
+func (c RateLimitConfig) validate(p *problems) {
+	if c.Backend != "memory" && c.Backend != "postgres" {
+		p.add("RATE_LIMIT_BACKEND must be one of memory, postgres, got %q", c.Backend)
+	}
+	p.positiveFloat("RATE_LIMIT_IP_RATE", c.IPRate)
+	p.positiveFloat("RATE_LIMIT_API_KEY_RATE", c.APIKeyRate)
+	p.positiveFloat("RATE_LIMIT_EMAIL_RATE", c.EmailRate)
+	p.positive("RATE_LIMIT_IP_BURST", int64(c.IPBurst))
+	p.positive("RATE_LIMIT_API_KEY_BURST", int64(c.APIKeyBurst))
+	p.positive("RATE_LIMIT_EMAIL_BURST", int64(c.EmailBurst))
+	p.nonNegative("REDEEM_MAX_FAILURES", int64(c.MaxFailures))
+	p.positive("REDEEM_LOCKOUT_BASE", int64(c.LockoutBase))
+	if c.LockoutMax < c.LockoutBase {
+		p.add("REDEEM_LOCKOUT_MAX (%s) must not be below REDEEM_LOCKOUT_BASE (%s)", c.LockoutMax, c.LockoutBase)
+	}
+}
+
+func (c CacheConfig) validate(p *problems) {
+	if !contains(cacheBackends, c.Backend) {
+		p.add("CACHE_BACKEND must be one of %s, got %q", oneOf(cacheBackends), c.Backend)
+		return
+	}
+	if c.Backend == CacheNone {
+		return
+	}
+	p.positive("CACHE_TTL", int64(c.TTL))
+	if c.Backend == CacheMemory {
+		p.positive("CACHE_SIZE", int64(c.Size))
+		return
+	}
+	p.required("REDIS_ADDR", c.RedisAddr)
+	p.nonNegative("REDIS_DB", int64(c.RedisDB))
+	p.positive("REDIS_TIMEOUT", int64(c.RedisTimeout))
+}
+
+func (c GRPCConfig) validate(p *problems, httpPort string) {
+	if !c.Enabled() {
+		return
+	}
+	if n, err := strconv.Atoi(c.Port); err != nil || n < 1 || n > 65535 {
+		p.add("GRPC_PORT must be a port number, got %q", c.Port)
+	} else if c.Port == httpPort {
+		p.add("GRPC_PORT must differ from APP_PORT, both are %s", c.Port)
+	}
+	if len(c.Keys()) == 0 {
+		p.add("GRPC_API_KEYS is required with GRPC_PORT")
+	}
+}
+
+func (c TenantConfig) validate(p *problems) {
+	p.nonNegative("API_KEY_TTL", int64(c.KeyTTL))
+	p.positive("API_KEY_GRACE", int64(c.KeyGrace))
+	if n := len(c.AdminKey); n > 0 && n < minAdminKey {
+		p.add("TENANT_ADMIN_KEY must be at least %d characters", minAdminKey)
+	}
+	if c.BaseDomain == "" {
+		return
+	}
+	if u, err := url.Parse("//" + c.BaseDomain); err != nil || u.Host != c.BaseDomain ||
+		u.Port() != "" || !strings.Contains(c.BaseDomain, ".") {
+		p.add("TENANT_BASE_DOMAIN must be a domain name without scheme or port, got %q", c.BaseDomain)
+	}
+}
+
+func (c RatesConfig) validate(p *problems) {
+	switch c.Source {
+	case RatesNone:
+		return
+	case RatesFile:
+		p.required("RATES_FILE", c.File)
+		p.file("RATES_FILE", c.File)
+	case RatesHTTP:
+		if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			p.add("RATES_URL must be an http(s) URL, got %q", c.URL)
+		}
+		p.positive("RATES_TTL", int64(c.TTL))
+		p.positive("RATES_TIMEOUT", int64(c.Timeout))
+	default:
+		p.add("RATES_SOURCE must be one of %s, got %q", oneOf(ratesSources), c.Source)
+	}
+}
+
+func (c NotifyConfig) validate(p *problems) {
+	if !contains(emailNotifiers, c.Email) {
+		p.add("NOTIFY_EMAIL must be one of %s, got %q", oneOf(emailNotifiers), c.Email)
+	}
+	if !contains(smsNotifiers, c.SMS) {
+		p.add("NOTIFY_SMS must be one of %s, got %q", oneOf(smsNotifiers), c.SMS)
+	}
+	// the worker delivering notifications runs without notifiers too
+	p.positive("NOTIFY_INTERVAL", int64(c.Interval))
+	if c.Email == NotifyNone && c.SMS == NotifyNone {
+		return
+	}
+	if c.Email == NotifySMTP {
+		if _, port, err := net.SplitHostPort(c.SMTPAddr); err != nil || port == "" {
+			p.add("SMTP_ADDR must be a host:port, got %q", c.SMTPAddr)
+		}
+		if _, err := mail.ParseAddress(c.SMTPFrom); err != nil {
+			p.add("SMTP_FROM must be an email address, got %q", c.SMTPFrom)
+		}
+		if c.SMTPPassword != "" && c.SMTPUsername == "" {
+			p.add("SMTP_USERNAME is required with SMTP_PASSWORD")
+		}
+	}
+	if c.Email == NotifyHTTP || c.SMS == NotifyHTTP {
+		if u, err := url.Parse(c.HTTPURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			p.add("NOTIFY_HTTP_URL must be an http(s) URL, got %q", c.HTTPURL)
+		}
+	}
+	if c.Email == NotifyFile || c.SMS == NotifyFile {
+		p.required("NOTIFY_FILE", c.File)
+	}
+	p.positive("NOTIFY_TIMEOUT", int64(c.Timeout))
+	p.positive("NOTIFY_MAX_ATTEMPTS", int64(c.MaxAttempts))
+	p.positive("NOTIFY_RETRY_BASE", int64(c.RetryBase))
+	p.required("NOTIFY_LOCALE", c.Locale)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+type problems []string
+
+func (p *problems) add(format string, args ...interface{}) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+func (p *problems) required(name, v string) {
+	if v == "" {
+		p.add("%s is required", name)
+	}
+}
+
+func (p *problems) positive(name string, v int64) {
+	if v <= 0 {
+		p.add("%s must be positive", name)
+	}
+}
+
+func (p *problems) positiveFloat(name string, v float64) {
+	if v <= 0 {
+		p.add("%s must be positive", name)
+	}
+}
+
+func (p *problems) nonNegative(name string, v int64) {
+	if v < 0 {
+		p.add("%s must not be negative", name)
+	}
+}
+
+func (p *problems) file(name, path string) {
+	if path == "" {
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		p.add("%s: %v", name, err)
+	}
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func oneOf(list []string) string {
+	return strings.Join(list, ", ")
+}
+
+func profileNames() []string {
+	return []string{dev, test, prod}
+}
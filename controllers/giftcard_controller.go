@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/giftcard"
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/repositories"
+	"github.com/deepinbytes/go_voucher/services/giftcardservice"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GiftCardInput represents issuing or topping up a gift card
+type GiftCardInput struct {
+	Amount   int64  `json:"amount" binding:"required,min=1"`
+	Currency string `json:"currency" binding:"required,currency"`
+}
+
+// GiftCardOrderInput represents debiting or refunding a gift card for an
+// order
+type GiftCardOrderInput struct {
+	Amount    int64  `json:"amount" binding:"required,min=1"`
+	Currency  string `json:"currency" binding:"required,currency"`
+	Reference string `json:"reference" binding:"required,max=64"`
+}
+
+// GiftCardOutput represents returning gift card, amounts are in minor
+// units of its currency
+type GiftCardOutput struct {
+	Code           string    `json:"code"`
+	Currency       string    `json:"currency"`
+	InitialBalance int64     `json:"initial_balance"`
+	Balance        int64     `json:"balance"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (in *GiftCardInput) normalize() {
+	in.Currency = normalizeCurrency(in.Currency)
+}
+
+func (in *GiftCardOrderInput) normalize() {
+	in.Currency = normalizeCurrency(in.Currency)
+	in.Reference = strings.TrimSpace(in.Reference)
+}
+
+// GiftCardController interface
+type GiftCardController interface {
+	Post(*gin.Context)
+	GetByCode(*gin.Context)
+	Ledger(*gin.Context)
+	Debit(*gin.Context)
+	TopUp(*gin.Context)
+	Refund(*gin.Context)
+}
+
+type giftCardController struct {
+	giftCardSvc giftcardservice.GiftCardService
+}
+
+// NewGiftCardController instantiates Gift Card Controller
+func NewGiftCardController(giftCardSvc giftcardservice.GiftCardService) GiftCardController {
+	return &giftCardController{
+		giftCardSvc: giftCardSvc,
+	}
+}
+
+// @Summary Issue a gift card holding an amount
+// @Produce  json
+// @Param amount body int true "Amount in minor units"
+// @Param currency body string true "EUR, GBP or USD"
+// @Success 201 {object} Response
+// @Failure 400 {object} Response
+// @Failure 422 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/giftcards [post]
+func (ctl *giftCardController) Post(c *gin.Context) {
+	var input GiftCardInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	card, err := ctl.giftCardSvc.Issue(c.Request.Context(), money.New(input.Amount, input.Currency))
+	if err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusCreated, "ok", ctl.mapToGiftCardOutput(card))
+}
+
+// @Summary Balance of a gift card
+// @Produce  json
+// @Param code path string true "Code"
+// @Success 200 {object} Response
+// @Failure 404 {object} Response
+// @Failure 429 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/giftcards/{code} [get]
+func (ctl *giftCardController) GetByCode(c *gin.Context) {
+	card, err := ctl.giftCardSvc.GetByCode(c.Request.Context(), normalizeCode(c.Param("code")))
+	if err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", ctl.mapToGiftCardOutput(card))
+}
+
+// @Summary Ledger of a gift card, oldest entry first. Every transaction has an entry on the card and one on the funding or orders account, summing to zero
+// @Produce  json
+// @Param code path string true "Code"
+// @Success 200 {object} Response
+// @Failure 404 {object} Response
+// @Failure 429 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/giftcards/{code}/ledger [get]
+func (ctl *giftCardController) Ledger(c *gin.Context) {
+	entries, err := ctl.giftCardSvc.Ledger(c.Request.Context(), normalizeCode(c.Param("code")))
+	if err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	if entries == nil {
+		entries = []*giftcard.Entry{}
+	}
+	HTTPRes(c, http.StatusOK, "ok", entries)
+}
+
+// @Summary Debit a gift card for an order, at most its balance
+// @Produce  json
+// @Param code path string true "Code"
+// @Param amount body int true "Amount in minor units"
+// @Param currency body string true "Currency of the card"
+// @Param reference body string true "Order reference"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 409 {object} Response
+// @Failure 422 {object} Response
+// @Failure 429 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/giftcards/{code}/debit [post]
+func (ctl *giftCardController) Debit(c *gin.Context) {
+	var input GiftCardOrderInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	card, err := ctl.giftCardSvc.Debit(c.Request.Context(), normalizeCode(c.Param("code")),
+		money.New(input.Amount, input.Currency), input.Reference)
+	if err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", ctl.mapToGiftCardOutput(card))
+}
+
+// @Summary Top up a gift card
+// @Produce  json
+// @Param code path string true "Code"
+// @Param amount body int true "Amount in minor units"
+// @Param currency body string true "Currency of the card"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 422 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/giftcards/{code}/top-up [post]
+func (ctl *giftCardController) TopUp(c *gin.Context) {
+	var input GiftCardInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	card, err := ctl.giftCardSvc.TopUp(c.Request.Context(), normalizeCode(c.Param("code")),
+		money.New(input.Amount, input.Currency))
+	if err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", ctl.mapToGiftCardOutput(card))
+}
+
+// @Summary Refund an order back to a gift card, at most what was debited for it and not refunded yet
+// @Produce  json
+// @Param code path string true "Code"
+// @Param amount body int true "Amount in minor units"
+// @Param currency body string true "Currency of the card"
+// @Param reference body string true "Order reference of the debit"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 409 {object} Response
+// @Failure 422 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/giftcards/{code}/refund [post]
+func (ctl *giftCardController) Refund(c *gin.Context) {
+	var input GiftCardOrderInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	card, err := ctl.giftCardSvc.Refund(c.Request.Context(), normalizeCode(c.Param("code")),
+		money.New(input.Amount, input.Currency), input.Reference)
+	if err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", ctl.mapToGiftCardOutput(card))
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (ctl *giftCardController) mapToGiftCardOutput(card *giftcard.Card) *GiftCardOutput {
+	return &GiftCardOutput{
+		Code:           card.Code,
+		Currency:       card.Currency,
+		InitialBalance: card.InitialBalance,
+		Balance:        card.Balance,
+		CreatedAt:      card.CreatedAt,
+		UpdatedAt:      card.UpdatedAt,
+	}
+}
+
+// errStatus answers 409 to a card still changed by other transactions
+// after the retries
+func (ctl *giftCardController) errStatus(err error) int {
+	switch err {
+	case giftcardservice.ErrAmount, giftcardservice.ErrCurrencyMismatch, money.ErrCurrency:
+		return http.StatusBadRequest
+	case giftcard.ErrInsufficientBalance, giftcardservice.ErrRefund, repositories.ErrStale:
+		return http.StatusConflict
+	}
+	return errStatus(err)
+}
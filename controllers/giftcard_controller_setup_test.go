@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/deepinbytes/go_voucher/domain/giftcard"
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/services/giftcardservice"
+
+	"github.com/jinzhu/gorm"
+)
+
+// giftCardSvc holds a single EUR card, GIFT0001
+type giftCardSvc struct {
+	card      giftcard.Card
+	reference string
+}
+
+func newGiftCardSvc() *giftCardSvc {
+	return &giftCardSvc{card: giftcard.Card{Code: "GIFT0001", Currency: "EUR", InitialBalance: 5000, Balance: 5000}}
+}
+
+func (gs *giftCardSvc) Issue(ctx context.Context, amount money.Money) (*giftcard.Card, error) {
+	if amount.Amount == 666 {
+		return nil, errors.New("oops")
+	}
+	return &giftcard.Card{Code: "GIFT0002", Currency: amount.Currency, InitialBalance: amount.Amount, Balance: amount.Amount}, nil
+}
+
+func (gs *giftCardSvc) GetByCode(ctx context.Context, code string) (*giftcard.Card, error) {
+	if code != gs.card.Code {
+		return nil, gorm.ErrRecordNotFound
+	}
+	card := gs.card
+	return &card, nil
+}
+
+func (gs *giftCardSvc) Debit(ctx context.Context, code string, amount money.Money, reference string) (*giftcard.Card, error) {
+	gs.reference = reference
+	return gs.post(code, amount, -amount.Amount)
+}
+
+func (gs *giftCardSvc) TopUp(ctx context.Context, code string, amount money.Money) (*giftcard.Card, error) {
+	return gs.post(code, amount, amount.Amount)
+}
+
+func (gs *giftCardSvc) Refund(ctx context.Context, code string, amount money.Money, reference string) (*giftcard.Card, error) {
+	gs.reference = reference
+	if reference != "order-1" {
+		return nil, giftcardservice.ErrRefund
+	}
+	return gs.post(code, amount, amount.Amount)
+}
+
+func (gs *giftCardSvc) Ledger(ctx context.Context, code string) ([]*giftcard.Entry, error) {
+	if _, err := gs.GetByCode(ctx, code); err != nil {
+		return nil, err
+	}
+	return []*giftcard.Entry{
+		{ID: 1, TxID: "t1", Kind: giftcard.KindIssue, Account: giftcard.AccountCard, Amount: 5000, Currency: "EUR"},
+		{ID: 2, TxID: "t1", Kind: giftcard.KindIssue, Account: giftcard.AccountFunding, Amount: -5000, Currency: "EUR"},
+	}, nil
+}
+
+func (gs *giftCardSvc) post(code string, amount money.Money, onCard int64) (*giftcard.Card, error) {
+	if code != gs.card.Code {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if amount.Currency != gs.card.Currency {
+		return nil, giftcardservice.ErrCurrencyMismatch
+	}
+	if gs.card.Balance+onCard < 0 {
+		return nil, giftcard.ErrInsufficientBalance
+	}
+	gs.card.Balance += onCard
+	card := gs.card
+	return &card, nil
+}
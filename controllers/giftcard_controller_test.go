@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/deepinbytes/go_voucher/domain/giftcard"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// NOTE: Mocked services are in './giftcard_controller_setup_test.go'
+
+func TestGiftCardController(t *testing.T) {
+
+	// Setup router + gift card controller
+	gs := newGiftCardSvc()
+	giftCardCtl := NewGiftCardController(gs)
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.POST("/api/v1/giftcards", giftCardCtl.Post)
+	router.GET("/api/v1/giftcards/:code", giftCardCtl.GetByCode)
+	router.GET("/api/v1/giftcards/:code/ledger", giftCardCtl.Ledger)
+	router.POST("/api/v1/giftcards/:code/debit", giftCardCtl.Debit)
+	router.POST("/api/v1/giftcards/:code/top-up", giftCardCtl.TopUp)
+	router.POST("/api/v1/giftcards/:code/refund", giftCardCtl.Refund)
+
+	balance := func(t *testing.T, w interface{ Bytes() []byte }) int64 {
+		resBody := struct {
+			Data GiftCardOutput `json:"data"`
+		}{}
+		assert.Nil(t, json.Unmarshal(w.Bytes(), &resBody))
+		return resBody.Data.Balance
+	}
+
+	t.Run("Issues a card", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/giftcards", map[string]interface{}{
+			"amount": 2500, "currency": "gbp",
+		}, nil)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		resBody := struct {
+			Data GiftCardOutput `json:"data"`
+		}{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, GiftCardOutput{Code: "GIFT0002", Currency: "GBP", InitialBalance: 2500, Balance: 2500}, resBody.Data)
+	})
+
+	t.Run("Tells the balance", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/giftcards/gift0001")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, int64(5000), balance(t, w.Body))
+
+		w = performRequest(router, "GET", "/api/v1/giftcards/NOPE1234")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Debits, tops up and refunds", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/giftcards/GIFT0001/debit", map[string]interface{}{
+			"amount": 1999, "currency": "EUR", "reference": " order-1 ",
+		}, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, int64(3001), balance(t, w.Body))
+		assert.Equal(t, "order-1", gs.reference)
+
+		w = performJSONRequest(router, "POST", "/api/v1/giftcards/GIFT0001/top-up", map[string]interface{}{
+			"amount": 1000, "currency": "EUR",
+		}, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, int64(4001), balance(t, w.Body))
+
+		w = performJSONRequest(router, "POST", "/api/v1/giftcards/GIFT0001/refund", map[string]interface{}{
+			"amount": 999, "currency": "EUR", "reference": "order-1",
+		}, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, int64(5000), balance(t, w.Body))
+	})
+
+	t.Run("Lists the ledger", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/giftcards/GIFT0001/ledger")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		resBody := struct {
+			Data []giftcard.Entry `json:"data"`
+		}{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Len(t, resBody.Data, 2)
+		assert.Equal(t, giftcard.AccountFunding, resBody.Data[1].Account)
+	})
+
+	t.Run("Conflicts", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/giftcards/GIFT0001/debit", map[string]interface{}{
+			"amount": 5001, "currency": "EUR", "reference": "order-2",
+		}, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = performJSONRequest(router, "POST", "/api/v1/giftcards/GIFT0001/refund", map[string]interface{}{
+			"amount": 100, "currency": "EUR", "reference": "order-2",
+		}, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = performJSONRequest(router, "POST", "/api/v1/giftcards/GIFT0001/debit", map[string]interface{}{
+			"amount": 100, "currency": "USD", "reference": "order-2",
+		}, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Invalid bodies", func(t *testing.T) {
+		for _, body := range []map[string]interface{}{
+			{"currency": "EUR"},
+			{"amount": -1, "currency": "EUR"},
+			{"amount": 100, "currency": "JPY"},
+			{"amount": 100},
+		} {
+			w := performJSONRequest(router, "POST", "/api/v1/giftcards", body, nil)
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code, body)
+		}
+		w := performJSONRequest(router, "POST", "/api/v1/giftcards/GIFT0001/debit", map[string]interface{}{
+			"amount": 100, "currency": "EUR",
+		}, nil)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/giftcards", map[string]interface{}{
+			"amount": 666, "currency": "EUR",
+		}, nil)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
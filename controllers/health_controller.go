@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+const readinessTimeout = 2 * time.Second
+
+// HealthController interface
+type HealthController interface {
+	Liveness(*gin.Context)
+	Readiness(*gin.Context)
+}
+
+type healthController struct {
+	checker *health.Checker
+}
+
+// NewHealthController instantiates Health Controller
+func NewHealthController(checker *health.Checker) HealthController {
+	return &healthController{
+		checker: checker,
+	}
+}
+
+// @Summary Liveness probe
+// @Produce  json
+// @Success 200 {object} Response
+// @Router /healthz [get]
+func (ctl *healthController) Liveness(c *gin.Context) {
+	HTTPRes(c, http.StatusOK, "ok", nil)
+}
+
+// @Summary Readiness probe checking the database, migrations and background workers
+// @Produce  json
+// @Success 200 {object} Response
+// @Failure 503 {object} Response
+// @Router /readyz [get]
+func (ctl *healthController) Readiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	results, ready := ctl.checker.Check(ctx)
+	if !ready {
+		HTTPRes(c, http.StatusServiceUnavailable, "not ready", results)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", results)
+}
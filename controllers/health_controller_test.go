@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/deepinbytes/go_voucher/common/health"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dbErr := errors.New("connection refused")
+	checker := health.NewChecker()
+	checker.Add("migrations", func(ctx context.Context) error { return nil })
+	checker.Add("database", func(ctx context.Context) error { return dbErr })
+
+	healthCtl := NewHealthController(checker)
+	router := gin.New()
+	router.GET("/healthz", healthCtl.Liveness)
+	router.GET("/readyz", healthCtl.Readiness)
+
+	t.Run("Liveness", func(t *testing.T) {
+		w := performRequest(router, "GET", "/healthz")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Not ready when a check fails", func(t *testing.T) {
+		w := performRequest(router, "GET", "/readyz")
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+		resBody := failedOutput{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+
+		expectedResBody := failedOutput{
+			Code: http.StatusServiceUnavailable,
+			Msg:  "not ready",
+			Data: map[string]interface{}{"migrations": "ok", "database": "connection refused"},
+		}
+		assert.EqualValues(t, expectedResBody, resBody)
+	})
+
+	t.Run("Ready once every check passes", func(t *testing.T) {
+		dbErr = nil
+		checker.Add("database", func(ctx context.Context) error { return dbErr })
+
+		w := performRequest(router, "GET", "/readyz")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Not ready while shutting down", func(t *testing.T) {
+		checker.Shutdown()
+
+		w := performRequest(router, "GET", "/readyz")
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+}
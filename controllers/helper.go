@@ -1,8 +1,24 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/deepinbytes/go_voucher/common/ratelimit"
+	"github.com/deepinbytes/go_voucher/repositories"
+
 	"github.com/gin-gonic/gin"
-	"math/rand"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// defaultPageSize is the page size of list routes without limit
+	defaultPageSize = 20
+	// maxPageSize bounds the limit of list routes
+	maxPageSize = 100
 )
 
 // Response object as HTTP response
@@ -12,8 +28,12 @@ type Response struct {
 	Data interface{} `json:"data"`
 }
 
-// HTTPRes normalize HTTP Response format
+// HTTPRes normalize HTTP Response format. Error messages are also attached
+// to the context so they reach the request log.
 func HTTPRes(c *gin.Context, httpCode int, msg string, data interface{}) {
+	if httpCode >= http.StatusBadRequest && msg != "" {
+		c.Error(errors.New(msg))
+	}
 	c.JSON(httpCode, Response{
 		Code: httpCode,
 		Msg:  msg,
@@ -22,12 +42,83 @@ func HTTPRes(c *gin.Context, httpCode int, msg string, data interface{}) {
 	return
 }
 
-var letters = []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890")
+// ETag is the entity tag of a resource at version
+func ETag(version uint) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// ifMatch checks the If-Match header against the current version of a
+// resource and returns the version to update from. It responds 428 when
+// the header is missing and 412 when no tag matches.
+func ifMatch(c *gin.Context, current uint) (uint, bool) {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		HTTPRes(c, http.StatusPreconditionRequired, "If-Match header is required", nil)
+		return 0, false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == ETag(current) {
+			return current, true
+		}
+	}
+	HTTPRes(c, http.StatusPreconditionFailed, "Resource was modified, fetch it again", nil)
+	return 0, false
+}
+
+// errStatus is the status of a service error
+func errStatus(err error) int {
+	switch {
+	case err == repositories.ErrStale:
+		return http.StatusPreconditionFailed
+	case strings.Contains(err.Error(), "not found"):
+		return http.StatusNotFound
+	case strings.HasSuffix(err.Error(), "is required"):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// recordLookup tells the lookup lockout about codes that do not exist.
+// Found codes do not clear the failures, looking a known code up in
+// between guesses would keep the lockout off.
+func recordLookup(c *gin.Context, err error) {
+	if err != nil && gorm.IsRecordNotFoundError(err) {
+		ratelimit.FailAttempt(c.Request.Context())
+	}
+}
 
-func randSeq(n int) string {
-	b := make([]rune, n)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
+// pageParams reads the after and limit query parameters of list routes
+func pageParams(c *gin.Context) (uint, int, error) {
+	var after uint64
+	if s := c.Query("after"); s != "" {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0, 0, errors.New("after should be a number")
+		}
+		after = n
+	}
+	limit := defaultPageSize
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxPageSize {
+			return 0, 0, fmt.Errorf("limit should be a number between 1 and %d", maxPageSize)
+		}
+		limit = n
 	}
-	return string(b)
+	return uint(after), limit, nil
+}
+
+// setNextLink links the page listed after last with a Link header
+func setNextLink(c *gin.Context, last uint, limit int) {
+	u := *c.Request.URL
+	q := u.Query()
+	q.Set("after", strconv.FormatUint(uint64(last), 10))
+	q.Set("limit", strconv.Itoa(limit))
+	u.RawQuery = q.Encode()
+	c.Header("Link", fmt.Sprintf(`<%s>; rel="next"`, u.RequestURI()))
 }
@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/deepinbytes/go_voucher/domain/notification"
+	"github.com/deepinbytes/go_voucher/services/notificationservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TemplateInput represents creating a notification template, an offer_id
+// of 0 makes it the template of every offer
+type TemplateInput struct {
+	OfferID uint   `json:"offer_id"`
+	Channel string `json:"channel" binding:"required,oneof=email sms"`
+	Locale  string `json:"locale" binding:"required,locale"`
+	Subject string `json:"subject" binding:"max=255"`
+	Body    string `json:"body" binding:"required"`
+}
+
+// TemplatePatchInput represents the fields a PATCH sets, absent ones are
+// kept
+type TemplatePatchInput struct {
+	Subject *string `json:"subject" binding:"omitempty,max=255"`
+	Body    *string `json:"body" binding:"omitempty,min=1"`
+}
+
+func (in *TemplateInput) normalize() {
+	in.Channel = strings.ToLower(strings.TrimSpace(in.Channel))
+	in.Locale = notification.NormalizeLocale(in.Locale)
+}
+
+// NotificationController interface
+type NotificationController interface {
+	ListTemplates(*gin.Context)
+	PostTemplate(*gin.Context)
+	GetTemplate(*gin.Context)
+	PatchTemplate(*gin.Context)
+	DeleteTemplate(*gin.Context)
+	Deliveries(*gin.Context)
+}
+
+type notificationController struct {
+	notifications notificationservice.NotificationService
+	vouchers      voucherservice.VoucherService
+}
+
+// NewNotificationController instantiates Notification Controller
+func NewNotificationController(notifications notificationservice.NotificationService,
+	vouchers voucherservice.VoucherService) NotificationController {
+	return &notificationController{
+		notifications: notifications,
+		vouchers:      vouchers,
+	}
+}
+
+// @Summary List the templates of the notifications sent with vouchers
+// @Produce  json
+// @Success 200 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/notification-templates [get]
+func (ctl *notificationController) ListTemplates(c *gin.Context) {
+	ts, err := ctl.notifications.ListTemplates(c.Request.Context())
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", ts)
+}
+
+// @Summary Create the template of an offer, or of every offer, for a channel and locale
+// @Produce  json
+// @Param offer_id body int false "Offer of the template, none for every offer"
+// @Param channel body string true "email or sms"
+// @Param locale body string true "Language tag, e.g. de or de-CH"
+// @Param subject body string false "Subject of emails, a Go text/template"
+// @Param body body string true "Go text/template of the message"
+// @Success 201 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 409 {object} Response
+// @Failure 422 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/notification-templates [post]
+func (ctl *notificationController) PostTemplate(c *gin.Context) {
+	var in TemplateInput
+	if !bindJSON(c, &in) {
+		return
+	}
+	t := &notification.Template{OfferID: in.OfferID, Channel: in.Channel, Locale: in.Locale,
+		Subject: in.Subject, Body: in.Body}
+
+	if err := ctl.notifications.CreateTemplate(c.Request.Context(), t); err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	c.Header("Location", fmt.Sprintf("/api/v1/notification-templates/%d", t.ID))
+	HTTPRes(c, http.StatusCreated, "ok", t)
+}
+
+// @Summary Get a notification template
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/notification-templates/{id} [get]
+func (ctl *notificationController) GetTemplate(c *gin.Context) {
+	id, err := ctl.getID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	t, err := ctl.notifications.GetTemplate(c.Request.Context(), id)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", t)
+}
+
+// @Summary Update the subject or body of a notification template
+// @Produce  json
+// @Param id path int true "ID"
+// @Param subject body string false "Subject of emails, a Go text/template"
+// @Param body body string false "Go text/template of the message"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 422 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/notification-templates/{id} [patch]
+func (ctl *notificationController) PatchTemplate(c *gin.Context) {
+	id, err := ctl.getID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	var in TemplatePatchInput
+	if !bindJSON(c, &in) {
+		return
+	}
+
+	t, err := ctl.notifications.GetTemplate(c.Request.Context(), id)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	if in.Subject != nil {
+		t.Subject = *in.Subject
+	}
+	if in.Body != nil {
+		t.Body = *in.Body
+	}
+	if err := ctl.notifications.UpdateTemplate(c.Request.Context(), t); err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", t)
+}
+
+// @Summary Delete a notification template, messages fall back to the next one
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 204
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/notification-templates/{id} [delete]
+func (ctl *notificationController) DeleteTemplate(c *gin.Context) {
+	id, err := ctl.getID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if err := ctl.notifications.DeleteTemplate(c.Request.Context(), id); err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary List the email and SMS deliveries of a voucher with their status
+// @Produce  json
+// @Param code path string true "Code"
+// @Success 200 {object} Response
+// @Failure 404 {object} Response
+// @Failure 429 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/vouchers/{code}/deliveries [get]
+func (ctl *notificationController) Deliveries(c *gin.Context) {
+	v, err := ctl.vouchers.UseCode(c.Request.Context(), normalizeCode(c.Param("code")))
+	if err != nil {
+		recordLookup(c, err)
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+
+	ds, err := ctl.notifications.Deliveries(c.Request.Context(), v.ID)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", ds)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (ctl *notificationController) getID(param string) (uint, error) {
+	id, err := strconv.Atoi(param)
+	if err != nil || id <= 0 {
+		return 0, errors.New("template id should be a positive number")
+	}
+	return uint(id), nil
+}
+
+func (ctl *notificationController) errStatus(err error) int {
+	switch {
+	case err == notificationservice.ErrChannel, errors.Is(err, notificationservice.ErrTemplate):
+		return http.StatusBadRequest
+	case err == notificationservice.ErrTemplateTaken:
+		return http.StatusConflict
+	}
+	return errStatus(err)
+}
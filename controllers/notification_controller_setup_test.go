@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/deepinbytes/go_voucher/domain/notification"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/services/notificationservice"
+)
+
+type notificationSvc struct {
+	saved *notification.Template
+}
+
+func (ns *notificationSvc) CreateTemplate(ctx context.Context, t *notification.Template) error {
+	if t.OfferID >= uint(10) {
+		return errors.New("record not found")
+	}
+	if t.Locale == "fr" {
+		return notificationservice.ErrTemplateTaken
+	}
+	if t.Body == "{{" {
+		return fmt.Errorf("%w: unclosed action", notificationservice.ErrTemplate)
+	}
+	t.ID = 1
+	ns.saved = t
+	return nil
+}
+
+func (ns *notificationSvc) GetTemplate(ctx context.Context, id uint) (*notification.Template, error) {
+	if id >= uint(10) {
+		return nil, errors.New("record not found")
+	}
+	return &notification.Template{ID: id, Channel: notification.ChannelEmail, Locale: "de",
+		Subject: "Ihr Gutschein", Body: "{{.Code}}"}, nil
+}
+
+func (ns *notificationSvc) ListTemplates(ctx context.Context) ([]*notification.Template, error) {
+	t, _ := ns.GetTemplate(ctx, 1)
+	return []*notification.Template{t}, nil
+}
+
+func (ns *notificationSvc) UpdateTemplate(ctx context.Context, t *notification.Template) error {
+	ns.saved = t
+	return nil
+}
+
+func (ns *notificationSvc) DeleteTemplate(ctx context.Context, id uint) error {
+	if id >= uint(10) {
+		return errors.New("record not found")
+	}
+	return nil
+}
+
+func (ns *notificationSvc) Issued(ctx context.Context, v *voucher.Voucher) error {
+	return nil
+}
+
+func (ns *notificationSvc) Deliver(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func (ns *notificationSvc) Deliveries(ctx context.Context, voucherID uint) ([]*notification.Delivery, error) {
+	return []*notification.Delivery{
+		{ID: 1, VoucherID: voucherID, Channel: notification.ChannelEmail, Status: notification.StatusSent, Attempts: 1},
+	}, nil
+}
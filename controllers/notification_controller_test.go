@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/deepinbytes/go_voucher/domain/notification"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// NOTE: Mocked services are in './notification_controller_setup_test.go'
+
+func TestNotificationController(t *testing.T) {
+
+	// Setup router + notification controller
+	ns := &notificationSvc{}
+	notificationCtl := NewNotificationController(ns, &voucherSvc{})
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/api/v1/notification-templates", notificationCtl.ListTemplates)
+	router.POST("/api/v1/notification-templates", notificationCtl.PostTemplate)
+	router.GET("/api/v1/notification-templates/:id", notificationCtl.GetTemplate)
+	router.PATCH("/api/v1/notification-templates/:id", notificationCtl.PatchTemplate)
+	router.DELETE("/api/v1/notification-templates/:id", notificationCtl.DeleteTemplate)
+	router.GET("/api/v1/vouchers/:code/deliveries", notificationCtl.Deliveries)
+
+	t.Run("Post", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/notification-templates",
+			map[string]interface{}{"offer_id": 2, "channel": " SMS", "locale": "de_CH", "body": "{{.Code}}"}, nil)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "/api/v1/notification-templates/1", w.Header().Get("Location"))
+		assert.Equal(t, &notification.Template{ID: 1, OfferID: 2, Channel: notification.ChannelSMS, Locale: "de-ch",
+			Body: "{{.Code}}"}, ns.saved)
+	})
+
+	t.Run("Post validates", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/notification-templates",
+			map[string]interface{}{"channel": "fax", "locale": "Deutsch!"}, nil)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		resBody := outputInvalid{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, []FieldError{
+			{Field: "channel", Code: CodeInvalid, Message: "channel is invalid"},
+			{Field: "locale", Code: CodeInvalidFormat, Message: "locale must be a language tag, e.g. de or de-CH"},
+			{Field: "body", Code: CodeRequired, Message: "body is required"},
+		}, resBody.Data)
+
+		w = performJSONRequest(router, "POST", "/api/v1/notification-templates",
+			map[string]interface{}{"channel": "sms", "locale": "en", "body": "{{"}, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = performJSONRequest(router, "POST", "/api/v1/notification-templates",
+			map[string]interface{}{"channel": "sms", "locale": "fr", "body": "x"}, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		w = performJSONRequest(router, "POST", "/api/v1/notification-templates",
+			map[string]interface{}{"offer_id": 20, "channel": "sms", "locale": "en", "body": "x"}, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/notification-templates/1")
+		assert.Equal(t, http.StatusOK, w.Code)
+		resBody := struct {
+			Data notification.Template `json:"data"`
+		}{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, "Ihr Gutschein", resBody.Data.Subject)
+
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/api/v1/notification-templates").Code)
+		assert.Equal(t, http.StatusNotFound, performRequest(router, "GET", "/api/v1/notification-templates/10").Code)
+		assert.Equal(t, http.StatusBadRequest, performRequest(router, "GET", "/api/v1/notification-templates/x").Code)
+	})
+
+	t.Run("Patch", func(t *testing.T) {
+		w := performJSONRequest(router, "PATCH", "/api/v1/notification-templates/1",
+			map[string]interface{}{"body": "Code {{.Code}}"}, nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, &notification.Template{ID: 1, Channel: notification.ChannelEmail, Locale: "de",
+			Subject: "Ihr Gutschein", Body: "Code {{.Code}}"}, ns.saved)
+
+		w = performJSONRequest(router, "PATCH", "/api/v1/notification-templates/1",
+			map[string]interface{}{"body": ""}, nil)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, performRequest(router, "DELETE", "/api/v1/notification-templates/1").Code)
+		assert.Equal(t, http.StatusNotFound, performRequest(router, "DELETE", "/api/v1/notification-templates/10").Code)
+	})
+
+	t.Run("Deliveries", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/vouchers/test1/deliveries")
+		assert.Equal(t, http.StatusOK, w.Code)
+		resBody := struct {
+			Data []notification.Delivery `json:"data"`
+		}{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		if assert.Len(t, resBody.Data, 1) {
+			assert.Equal(t, uint(1), resBody.Data[0].VoucherID)
+			assert.Equal(t, notification.StatusSent, resBody.Data[0].Status)
+		}
+	})
+}
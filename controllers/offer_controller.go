@@ -2,15 +2,16 @@ package controllers
 
 import (
 	"errors"
-	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"fmt"
+	"github.com/deepinbytes/go_voucher/services/lifecycleservice"
 	"github.com/deepinbytes/go_voucher/services/userservice"
 	"github.com/deepinbytes/go_voucher/services/voucherservice"
-	"github.com/jinzhu/gorm"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/deepinbytes/go_voucher/domain/money"
 	"github.com/deepinbytes/go_voucher/domain/offer"
 	"github.com/deepinbytes/go_voucher/services/offerservice"
 
@@ -19,26 +20,84 @@ import (
 
 // UserInput represents login/register request body format
 type OfferInput struct {
-	Name               string `json:"name"`
-	DiscountPercentage uint   `json:"discount_percentage"`
+	Name               string           `json:"name" binding:"required,max=255"`
+	DiscountPercentage uint             `json:"discount_percentage" binding:"required_without=DiscountAmount,max=100"`
+	DiscountAmount     int64            `json:"discount_amount" binding:"omitempty,min=1"`
+	DiscountCurrency   string           `json:"discount_currency" binding:"required_with=DiscountAmount,omitempty,currency"`
+	DiscountAmounts    map[string]int64 `json:"discount_amounts" binding:"omitempty,dive,keys,currency,endkeys,min=1"`
+	Stackable          bool             `json:"stackable"`
+	ExclusivityGroup   string           `json:"exclusivity_group" binding:"max=64"`
+	Priority           int              `json:"priority" binding:"min=0,max=100"`
 }
 
 type OfferGenerateVoucherInput struct {
-	Name       string `json:"name"`
-	ExpiryTime uint   `json:"expiry_time"`
+	Name       string `json:"name" binding:"required,max=255"`
+	ExpiryTime uint   `json:"expiry_time" binding:"required,min=1,max=365"`
 }
 
 // UserOutput represents returning user
 type OfferOutput struct {
-	ID                 uint   `json:"id"`
-	Name               string `json:"name"`
-	DiscountPercentage uint   `json:"discount_percentage"`
+	ID                 uint             `json:"id"`
+	Name               string           `json:"name"`
+	DiscountPercentage uint             `json:"discount_percentage"`
+	DiscountAmount     int64            `json:"discount_amount,omitempty"`
+	DiscountCurrency   string           `json:"discount_currency,omitempty"`
+	DiscountAmounts    map[string]int64 `json:"discount_amounts,omitempty"`
+	Stackable          bool             `json:"stackable,omitempty"`
+	ExclusivityGroup   string           `json:"exclusivity_group,omitempty"`
+	Priority           int              `json:"priority,omitempty"`
 }
 
 // UserUpdateInput represents updating profile request body format
 type OfferUpdateInput struct {
-	DiscountPercentage uint   `json:"discount_percentage"`
-	Name               string `json:"name"`
+	DiscountPercentage uint   `json:"discount_percentage" binding:"required,min=1,max=100"`
+	Name               string `json:"name" binding:"required,max=255"`
+}
+
+// OfferPatchInput represents the fields a PATCH sets, absent ones are kept
+type OfferPatchInput struct {
+	Name               *string `json:"name" binding:"omitempty,min=1,max=255"`
+	DiscountPercentage *uint   `json:"discount_percentage" binding:"omitempty,min=1,max=100"`
+	DiscountAmount     *int64  `json:"discount_amount" binding:"omitempty,min=1"`
+	DiscountCurrency   *string `json:"discount_currency" binding:"omitempty,currency"`
+	// DiscountAmounts replaces all the amounts, {} clears them
+	DiscountAmounts  map[string]int64 `json:"discount_amounts" binding:"omitempty,dive,keys,currency,endkeys,min=1"`
+	Stackable        *bool            `json:"stackable"`
+	ExclusivityGroup *string          `json:"exclusivity_group" binding:"omitempty,max=64"`
+	Priority         *int             `json:"priority" binding:"omitempty,min=0,max=100"`
+}
+
+// IssueVouchersInput represents issuing vouchers of an offer to every user
+type IssueVouchersInput struct {
+	ExpiryDays uint `json:"expiry_days" binding:"required,min=1,max=365"`
+}
+
+func (in *OfferInput) normalize() {
+	in.Name = strings.TrimSpace(in.Name)
+	in.ExclusivityGroup = strings.TrimSpace(in.ExclusivityGroup)
+	in.DiscountCurrency = normalizeCurrency(in.DiscountCurrency)
+	in.DiscountAmounts = normalizeAmounts(in.DiscountAmounts)
+}
+
+func (in *OfferGenerateVoucherInput) normalize() {
+	in.Name = strings.TrimSpace(in.Name)
+}
+
+func (in *OfferUpdateInput) normalize() {
+	in.Name = strings.TrimSpace(in.Name)
+}
+
+func (in *OfferPatchInput) normalize() {
+	if in.Name != nil {
+		*in.Name = strings.TrimSpace(*in.Name)
+	}
+	if in.ExclusivityGroup != nil {
+		*in.ExclusivityGroup = strings.TrimSpace(*in.ExclusivityGroup)
+	}
+	if in.DiscountCurrency != nil {
+		*in.DiscountCurrency = normalizeCurrency(*in.DiscountCurrency)
+	}
+	in.DiscountAmounts = normalizeAmounts(in.DiscountAmounts)
 }
 
 // UserController interface
@@ -47,12 +106,19 @@ type OfferController interface {
 	GetByID(*gin.Context)
 	Update(*gin.Context)
 	GenerateVouchers(*gin.Context)
+	List(*gin.Context)
+	Post(*gin.Context)
+	Patch(*gin.Context)
+	Delete(*gin.Context)
+	Restore(*gin.Context)
+	IssueVouchers(*gin.Context)
 }
 
 type offerController struct {
-	offerSvc offerservice.OfferService
-	usrSvc   userservice.UserService
-	vouchSvc voucherservice.VoucherService
+	offerSvc  offerservice.OfferService
+	usrSvc    userservice.UserService
+	vouchSvc  voucherservice.VoucherService
+	lifecycle lifecycleservice.LifecycleService
 }
 
 // @Summary Generates vouchers for all the users given offer name
@@ -60,36 +126,33 @@ type offerController struct {
 // @Param name body string true "Name"
 // @Success 200 {object} Response
 // @Failure 400 {object} Response
+// @Failure 422 {object} Response
 // @Failure 500 {object} Response
+// @Deprecated
 // @Router /api/offer/generate_vouchers [post]
 func (ctl *offerController) GenerateVouchers(c *gin.Context) {
 
 	var generateVoucherInput OfferGenerateVoucherInput
-	if err := c.ShouldBindJSON(&generateVoucherInput); err != nil {
-		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+	if !bindJSON(c, &generateVoucherInput) {
 		return
 	}
-	offer, err := ctl.offerSvc.GetByName(generateVoucherInput.Name)
+	offer, err := ctl.offerSvc.GetByName(c.Request.Context(), generateVoucherInput.Name)
 	if err != nil {
 		HTTPRes(c, http.StatusNotFound, err.Error(), "Offer not found")
 		return
 	}
 
-	users, _ := ctl.usrSvc.ListAll()
-	//var v []*voucher.Voucher
-	for _, t := range users {
-		t := &voucher.Voucher{
-			Model:      gorm.Model{},
-			UsedAt:     time.Time{},
-			IsUsed:     false,
-			Code:       randSeq(8),
-			OfferID:    offer.ID,
-			UserID:     t.ID,
-			ExpireTime: time.Now().Add(time.Hour * (time.Duration(generateVoucherInput.ExpiryTime) * 24)),
-		}
-		ctl.vouchSvc.Create(t)
+	users, err := ctl.usrSvc.ListAll(c.Request.Context())
+	if err != nil {
+		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	expireTime := time.Now().Add(time.Hour * (time.Duration(generateVoucherInput.ExpiryTime) * 24))
+	if _, err := ctl.vouchSvc.Generate(c.Request.Context(), offer.ID, users, expireTime); err != nil {
+		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
+		return
 	}
-	//p := ctl.vouchSvc.BulkCreate(v)
 	HTTPRes(c, http.StatusOK, "ok", "Generated")
 
 }
@@ -98,11 +161,13 @@ func (ctl *offerController) GenerateVouchers(c *gin.Context) {
 func NewOfferController(
 	us offerservice.OfferService,
 	usrSvc userservice.UserService,
-	voucherSvc voucherservice.VoucherService) OfferController {
+	voucherSvc voucherservice.VoucherService,
+	lifecycle lifecycleservice.LifecycleService) OfferController {
 	return &offerController{
-		offerSvc: us,
-		usrSvc:   usrSvc,
-		vouchSvc: voucherSvc,
+		offerSvc:  us,
+		usrSvc:    usrSvc,
+		vouchSvc:  voucherSvc,
+		lifecycle: lifecycle,
 	}
 }
 
@@ -112,32 +177,34 @@ func NewOfferController(
 // @Param discount_percentage body string true "DiscountPercentage"
 // @Success 200 {object} Response
 // @Failure 400 {object} Response
+// @Failure 422 {object} Response
 // @Failure 500 {object} Response
+// @Deprecated
 // @Router /api/offer/create [post]
 func (ctl *offerController) Create(c *gin.Context) {
 	// Read user input
 	var offerInput OfferInput
-	if err := c.ShouldBindJSON(&offerInput); err != nil {
-		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+	if !bindJSON(c, &offerInput) {
 		return
 	}
 	u := ctl.inputToUser(offerInput)
 
 	// Create user
-	if err := ctl.offerSvc.Create(&u); err != nil {
+	if err := ctl.offerSvc.Create(c.Request.Context(), &u); err != nil {
 		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
 		return
 	}
 
 }
 
-// @Summary Get offer info of given id
+// @Summary Get offer info of given id, its version is in the ETag header
 // @Produce  json
 // @Param id path int true "ID"
 // @Success 200 {object} Response
 // @Failure 400 {object} Response
+// @Failure 404 {object} Response
 // @Failure 500 {object} Response
-// @Router /api/offer/{id} [get]
+// @Router /api/v1/offers/{id} [get]
 func (ctl *offerController) GetByID(c *gin.Context) {
 	id, err := ctl.getOfferID(c.Param(("id")))
 	if err != nil {
@@ -145,7 +212,7 @@ func (ctl *offerController) GetByID(c *gin.Context) {
 		return
 	}
 
-	offer, err := ctl.offerSvc.GetByID(id)
+	offer, err := ctl.offerSvc.GetByID(c.Request.Context(), id)
 	if err != nil {
 		es := err.Error()
 		if strings.Contains(es, "not found") {
@@ -156,6 +223,7 @@ func (ctl *offerController) GetByID(c *gin.Context) {
 		return
 	}
 	offerOutput := ctl.mapToOfferOutput(offer)
+	c.Header("ETag", ETag(offer.Version))
 	HTTPRes(c, http.StatusOK, "ok", offerOutput)
 }
 
@@ -166,7 +234,9 @@ func (ctl *offerController) GetByID(c *gin.Context) {
 // @Param discount_percentage body string false "DiscountPercentage"
 // @Success 200 {object} Response
 // @Failure 400 {object} Response
+// @Failure 422 {object} Response
 // @Failure 500 {object} Response
+// @Deprecated
 // @Router /api/offer/update [post]
 func (ctl *offerController) Update(c *gin.Context) {
 	// Get user id from context
@@ -177,7 +247,7 @@ func (ctl *offerController) Update(c *gin.Context) {
 	}
 
 	// Retrieve offer given id
-	offer, err := ctl.offerSvc.GetByID(id.(uint))
+	offer, err := ctl.offerSvc.GetByID(c.Request.Context(), id.(uint))
 	if err != nil {
 		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
 		return
@@ -185,8 +255,7 @@ func (ctl *offerController) Update(c *gin.Context) {
 
 	// Read offer input
 	var offerInput OfferUpdateInput
-	if err := c.ShouldBindJSON(&offerInput); err != nil {
-		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+	if !bindJSON(c, &offerInput) {
 		return
 	}
 
@@ -200,7 +269,7 @@ func (ctl *offerController) Update(c *gin.Context) {
 	offer.DiscountPercentage = offerInput.DiscountPercentage
 	offer.Name = offerInput.Name
 
-	if err := ctl.offerSvc.Update(offer); err != nil {
+	if err := ctl.offerSvc.Update(c.Request.Context(), offer); err != nil {
 		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
 		return
 	}
@@ -210,6 +279,229 @@ func (ctl *offerController) Update(c *gin.Context) {
 	HTTPRes(c, http.StatusOK, "ok", offerOutput)
 }
 
+// @Summary List offers by ID, the Link header points to the next page
+// @Produce  json
+// @Param after query int false "List offers after this ID"
+// @Param limit query int false "Page size, 20 by default and 100 at most"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/offers [get]
+func (ctl *offerController) List(c *gin.Context) {
+	after, limit, err := pageParams(c)
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	// one more than asked tells whether there is a next page
+	offers, err := ctl.offerSvc.ListPage(c.Request.Context(), after, limit+1)
+	if err != nil {
+		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	if len(offers) > limit {
+		offers = offers[:limit]
+		setNextLink(c, offers[limit-1].ID, limit)
+	}
+
+	out := make([]*OfferOutput, 0, len(offers))
+	for _, o := range offers {
+		out = append(out, ctl.mapToOfferOutput(o))
+	}
+	HTTPRes(c, http.StatusOK, "ok", out)
+}
+
+// @Summary Creates new offer
+// @Produce  json
+// @Param name body string true "Name"
+// @Param discount_percentage body string true "DiscountPercentage"
+// @Param stackable body bool false "Combines with other stackable offers"
+// @Param exclusivity_group body string false "At most one offer of the group per order"
+// @Param priority body int false "Priority, the highest applies first"
+// @Success 201 {object} Response
+// @Failure 400 {object} Response
+// @Failure 422 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/offers [post]
+func (ctl *offerController) Post(c *gin.Context) {
+	var offerInput OfferInput
+	if !bindJSON(c, &offerInput) {
+		return
+	}
+	o := ctl.inputToUser(offerInput)
+
+	if err := ctl.offerSvc.Create(c.Request.Context(), &o); err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	c.Header("Location", fmt.Sprintf("/api/v1/offers/%d", o.ID))
+	c.Header("ETag", ETag(o.Version))
+	HTTPRes(c, http.StatusCreated, "ok", ctl.mapToOfferOutput(&o))
+}
+
+// @Summary Update the given fields of an offer
+// @Produce  json
+// @Param id path int true "ID"
+// @Param If-Match header string true "ETag of the offer as last read"
+// @Param name body string false "Name"
+// @Param discount_percentage body string false "DiscountPercentage"
+// @Param stackable body bool false "Combines with other stackable offers"
+// @Param exclusivity_group body string false "At most one offer of the group per order"
+// @Param priority body int false "Priority, the highest applies first"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 422 {object} Response
+// @Failure 404 {object} Response
+// @Failure 412 {object} Response
+// @Failure 428 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/offers/{id} [patch]
+func (ctl *offerController) Patch(c *gin.Context) {
+	id, err := ctl.getOfferID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	offer, err := ctl.offerSvc.GetByID(c.Request.Context(), id)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	version, ok := ifMatch(c, offer.Version)
+	if !ok {
+		return
+	}
+
+	var offerInput OfferPatchInput
+	if !bindJSON(c, &offerInput) {
+		return
+	}
+	if offerInput.Name != nil {
+		offer.Name = *offerInput.Name
+	}
+	// a discount of the other kind replaces the current one
+	if offerInput.DiscountPercentage != nil {
+		offer.DiscountPercentage = *offerInput.DiscountPercentage
+		offer.DiscountAmount, offer.DiscountCurrency, offer.DiscountAmounts = 0, "", nil
+	}
+	if offerInput.DiscountAmount != nil {
+		offer.DiscountAmount = *offerInput.DiscountAmount
+		offer.DiscountPercentage = 0
+	}
+	if offerInput.DiscountCurrency != nil {
+		offer.DiscountCurrency = *offerInput.DiscountCurrency
+	}
+	if offerInput.DiscountAmounts != nil {
+		offer.DiscountAmounts = offerInput.DiscountAmounts
+	}
+	if offerInput.Stackable != nil {
+		offer.Stackable = *offerInput.Stackable
+	}
+	if offerInput.ExclusivityGroup != nil {
+		offer.ExclusivityGroup = *offerInput.ExclusivityGroup
+	}
+	if offerInput.Priority != nil {
+		offer.Priority = *offerInput.Priority
+	}
+
+	// a concurrent update between the read and here still fails with 412
+	if err := ctl.offerSvc.UpdateIfVersion(c.Request.Context(), offer, version); err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	c.Header("ETag", ETag(offer.Version))
+	HTTPRes(c, http.StatusOK, "ok", ctl.mapToOfferOutput(offer))
+}
+
+// @Summary Delete an offer and revoke its unused vouchers
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 204
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/offers/{id} [delete]
+func (ctl *offerController) Delete(c *gin.Context) {
+	id, err := ctl.getOfferID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if err := ctl.lifecycle.DeleteOffer(c.Request.Context(), id); err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Restore a deleted offer and the vouchers revoked with it
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/offers/{id}/restore [post]
+func (ctl *offerController) Restore(c *gin.Context) {
+	id, err := ctl.getOfferID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	offer, err := ctl.lifecycle.RestoreOffer(c.Request.Context(), id)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	c.Header("ETag", ETag(offer.Version))
+	HTTPRes(c, http.StatusOK, "ok", ctl.mapToOfferOutput(offer))
+}
+
+// @Summary Issues a voucher of the offer to every user
+// @Produce  json
+// @Param id path int true "ID"
+// @Param expiry_days body int true "Days until the vouchers expire"
+// @Success 201 {object} Response
+// @Failure 400 {object} Response
+// @Failure 422 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/offers/{id}/vouchers [post]
+func (ctl *offerController) IssueVouchers(c *gin.Context) {
+	id, err := ctl.getOfferID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	var input IssueVouchersInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	offer, err := ctl.offerSvc.GetByID(c.Request.Context(), id)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	users, err := ctl.usrSvc.ListAll(c.Request.Context())
+	if err != nil {
+		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	expireTime := time.Now().Add(24 * time.Hour * time.Duration(input.ExpiryDays))
+	n, err := ctl.vouchSvc.Generate(c.Request.Context(), offer.ID, users, expireTime)
+	if err != nil {
+		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusCreated, "ok", gin.H{"issued": n})
+}
+
 /*******************************/
 //       PRIVATE METHODS
 /*******************************/
@@ -222,10 +514,25 @@ func (ctl *offerController) getOfferID(offerIDParam string) (uint, error) {
 	return uint(offerID), nil
 }
 
+// errStatus answers invalid discounts with 400
+func (ctl *offerController) errStatus(err error) int {
+	switch err {
+	case offerservice.ErrDiscount, offerservice.ErrAmounts, money.ErrCurrency:
+		return http.StatusBadRequest
+	}
+	return errStatus(err)
+}
+
 func (ctl *offerController) inputToUser(input OfferInput) offer.Offer {
 	return offer.Offer{
 		Name:               input.Name,
 		DiscountPercentage: input.DiscountPercentage,
+		DiscountAmount:     input.DiscountAmount,
+		DiscountCurrency:   input.DiscountCurrency,
+		DiscountAmounts:    input.DiscountAmounts,
+		Stackable:          input.Stackable,
+		ExclusivityGroup:   input.ExclusivityGroup,
+		Priority:           input.Priority,
 	}
 }
 
@@ -234,5 +541,11 @@ func (ctl *offerController) mapToOfferOutput(u *offer.Offer) *OfferOutput {
 		ID:                 u.ID,
 		Name:               u.Name,
 		DiscountPercentage: u.DiscountPercentage,
+		DiscountAmount:     u.DiscountAmount,
+		DiscountCurrency:   u.DiscountCurrency,
+		DiscountAmounts:    u.DiscountAmounts,
+		Stackable:          u.Stackable,
+		ExclusivityGroup:   u.ExclusivityGroup,
+		Priority:           u.Priority,
 	}
 }
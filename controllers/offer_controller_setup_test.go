@@ -1,8 +1,12 @@
 package controllers
 
 import (
+	"context"
 	"errors"
+	"time"
+
 	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/repositories"
 	"github.com/jinzhu/gorm"
 )
 
@@ -20,7 +24,7 @@ var of2 = &offer.Offer{
 	DiscountPercentage: 27,
 }
 
-func (os *offerSvc) GetByID(id uint) (*offer.Offer, error) {
+func (os *offerSvc) GetByID(ctx context.Context, id uint) (*offer.Offer, error) {
 	if id >= uint(100) {
 		return nil, errors.New("Ugh")
 	}
@@ -30,10 +34,12 @@ func (os *offerSvc) GetByID(id uint) (*offer.Offer, error) {
 	if id >= uint(10) {
 		return nil, errors.New("Record not found")
 	}
-	return of1, nil
+	// a copy, so handlers changing it leave the fixture as is
+	o := *of1
+	return &o, nil
 }
 
-func (os *offerSvc) GetByName(name string) (*offer.Offer, error) {
+func (os *offerSvc) GetByName(ctx context.Context, name string) (*offer.Offer, error) {
 	if name == "non_existent_offer" {
 		return nil, errors.New("Nop")
 	}
@@ -43,22 +49,63 @@ func (os *offerSvc) GetByName(name string) (*offer.Offer, error) {
 	return of1, nil
 }
 
-func (os *offerSvc) Create(offer *offer.Offer) error {
+func (os *offerSvc) Create(ctx context.Context, offer *offer.Offer) error {
 	if offer.Name == "new_offer" {
 		return errors.New("Nop")
 	}
 	return nil
 }
 
-func (os *offerSvc) Update(offer *offer.Offer) error {
+func (os *offerSvc) Update(ctx context.Context, offer *offer.Offer) error {
 	if offer.Name == "non_existing_offer" {
 		return errors.New("Nop")
 	}
 	return nil
 }
 
-func (os *offerSvc) ListAll() ([]*offer.Offer, error) {
+func (os *offerSvc) ListAll(ctx context.Context) ([]*offer.Offer, error) {
 	var x []*offer.Offer
 	return x, nil
 
 }
+
+func (os *offerSvc) GetByIDs(ctx context.Context, ids []uint) ([]*offer.Offer, error) {
+	return []*offer.Offer{of1}, nil
+}
+
+func (os *offerSvc) ListPage(ctx context.Context, after uint, limit int) ([]*offer.Offer, error) {
+	return []*offer.Offer{of1, of2}, nil
+}
+
+func (os *offerSvc) UpdateIfVersion(ctx context.Context, offer *offer.Offer, version uint) error {
+	if offer.Name == "raced_offer" {
+		return repositories.ErrStale
+	}
+	offer.Version = version + 1
+	return nil
+}
+
+func (os *offerSvc) Delete(ctx context.Context, id uint, at time.Time) error {
+	if id >= uint(10) {
+		return errors.New("record not found")
+	}
+	return nil
+}
+
+func (os *offerSvc) GetDeleted(ctx context.Context, id uint) (*offer.Offer, error) {
+	if id >= uint(10) {
+		return nil, errors.New("record not found")
+	}
+	o := *of1
+	deletedAt := time.Now()
+	o.DeletedAt = &deletedAt
+	return &o, nil
+}
+
+func (os *offerSvc) Restore(ctx context.Context, id uint) error {
+	return nil
+}
+
+func (os *offerSvc) Purge(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
@@ -8,6 +8,8 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/deepinbytes/go_voucher/services/lifecycleservice"
+
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
@@ -27,7 +29,8 @@ func TestOfferController(t *testing.T) {
 	os := &offerSvc{}
 	us := &userSvc{}
 	vs := &voucherSvc{}
-	offerCtl := NewOfferController(os, us, vs)
+	lc := lifecycleservice.NewLifecycleService(os, us, vs)
+	offerCtl := NewOfferController(os, us, vs, lc)
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
 	router.GET("/offer/:id", offerCtl.GetByID)
@@ -301,3 +304,179 @@ func TestOfferController(t *testing.T) {
 	})
 
 }
+
+func TestOfferControllerV1(t *testing.T) {
+
+	// Setup router + offer controller
+	os := &offerSvc{}
+	us := &userSvc{}
+	vs := &voucherSvc{}
+	lc := lifecycleservice.NewLifecycleService(os, us, vs)
+	offerCtl := NewOfferController(os, us, vs, lc)
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/api/v1/offers", offerCtl.List)
+	router.POST("/api/v1/offers", offerCtl.Post)
+	router.GET("/api/v1/offers/:id", offerCtl.GetByID)
+	router.PATCH("/api/v1/offers/:id", offerCtl.Patch)
+	router.DELETE("/api/v1/offers/:id", offerCtl.Delete)
+	router.POST("/api/v1/offers/:id/restore", offerCtl.Restore)
+
+	t.Run("List", func(t *testing.T) {
+		t.Run("Links the next page", func(t *testing.T) {
+			w := performRequest(router, "GET", "/api/v1/offers?limit=1")
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, `</api/v1/offers?after=1&limit=1>; rel="next"`, w.Header().Get("Link"))
+
+			resBody := Response{}
+			json.NewDecoder(w.Body).Decode(&resBody)
+			assert.Len(t, resBody.Data, 1)
+		})
+
+		t.Run("Last page has no link", func(t *testing.T) {
+			w := performRequest(router, "GET", "/api/v1/offers")
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Empty(t, w.Header().Get("Link"))
+		})
+
+		t.Run("Invalid limit", func(t *testing.T) {
+			w := performRequest(router, "GET", "/api/v1/offers?limit=1000")
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	})
+
+	t.Run("Post", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			w := performJSONRequest(router, "POST", "/api/v1/offers",
+				map[string]interface{}{"name": "offer3", "discount_percentage": 10}, nil)
+
+			assert.Equal(t, http.StatusCreated, w.Code)
+			assert.Equal(t, "/api/v1/offers/0", w.Header().Get("Location"))
+			assert.NotEmpty(t, w.Header().Get("ETag"))
+		})
+
+		t.Run("Fixed discount", func(t *testing.T) {
+			w := performJSONRequest(router, "POST", "/api/v1/offers", map[string]interface{}{
+				"name": "offer4", "discount_amount": 500, "discount_currency": "eur",
+				"discount_amounts": map[string]int64{"gbp": 450},
+			}, nil)
+
+			assert.Equal(t, http.StatusCreated, w.Code)
+			resBody := struct {
+				Data OfferOutput `json:"data"`
+			}{}
+			json.NewDecoder(w.Body).Decode(&resBody)
+			assert.Equal(t, OfferOutput{
+				Name: "offer4", DiscountAmount: 500, DiscountCurrency: "EUR",
+				DiscountAmounts: map[string]int64{"GBP": 450},
+			}, resBody.Data)
+		})
+
+		t.Run("Fails to create offer", func(t *testing.T) {
+			w := performJSONRequest(router, "POST", "/api/v1/offers",
+				map[string]interface{}{"name": "new_offer", "discount_percentage": 10}, nil)
+
+			assert.Equal(t, http.StatusInternalServerError, w.Code)
+		})
+	})
+
+	t.Run("Patch", func(t *testing.T) {
+		etag := performRequest(router, "GET", "/api/v1/offers/1").Header().Get("ETag")
+
+		t.Run("Success", func(t *testing.T) {
+			w := performJSONRequest(router, "PATCH", "/api/v1/offers/1",
+				map[string]interface{}{"discount_percentage": 45}, map[string]string{"If-Match": etag})
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+			resBody := Response{}
+			json.NewDecoder(w.Body).Decode(&resBody)
+			assert.EqualValues(t, map[string]interface{}{
+				"id":                  float64(1),
+				"name":                "offer1",
+				"discount_percentage": float64(45),
+			}, resBody.Data)
+		})
+
+		t.Run("Sets stacking rules", func(t *testing.T) {
+			etag := performRequest(router, "GET", "/api/v1/offers/1").Header().Get("ETag")
+			w := performJSONRequest(router, "PATCH", "/api/v1/offers/1",
+				map[string]interface{}{"stackable": true, "exclusivity_group": " seasonal ", "priority": 5},
+				map[string]string{"If-Match": etag})
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			resBody := struct {
+				Data OfferOutput `json:"data"`
+			}{}
+			json.NewDecoder(w.Body).Decode(&resBody)
+			assert.True(t, resBody.Data.Stackable)
+			assert.Equal(t, "seasonal", resBody.Data.ExclusivityGroup)
+			assert.Equal(t, 5, resBody.Data.Priority)
+		})
+
+		t.Run("Requires If-Match", func(t *testing.T) {
+			w := performJSONRequest(router, "PATCH", "/api/v1/offers/1",
+				map[string]interface{}{"discount_percentage": 45}, nil)
+
+			assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+		})
+
+		t.Run("Rejects a stale ETag", func(t *testing.T) {
+			w := performJSONRequest(router, "PATCH", "/api/v1/offers/1",
+				map[string]interface{}{"discount_percentage": 45}, map[string]string{"If-Match": `"41"`})
+
+			assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		})
+
+		t.Run("Rejects a concurrent update", func(t *testing.T) {
+			w := performJSONRequest(router, "PATCH", "/api/v1/offers/1",
+				map[string]interface{}{"name": "raced_offer"}, map[string]string{"If-Match": "*"})
+
+			assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		})
+
+		t.Run("Not found", func(t *testing.T) {
+			w := performJSONRequest(router, "PATCH", "/api/v1/offers/10",
+				map[string]interface{}{"discount_percentage": 45}, map[string]string{"If-Match": "*"})
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			w := performRequest(router, "DELETE", "/api/v1/offers/1")
+
+			assert.Equal(t, http.StatusNoContent, w.Code)
+		})
+
+		t.Run("Not found", func(t *testing.T) {
+			w := performRequest(router, "DELETE", "/api/v1/offers/10")
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			w := performRequest(router, "POST", "/api/v1/offers/1/restore")
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.NotEmpty(t, w.Header().Get("ETag"))
+
+			resBody := outputOffer{}
+			json.NewDecoder(w.Body).Decode(&resBody)
+			assert.Equal(t, of1.Name, resBody.Data.Name)
+		})
+
+		t.Run("Not deleted", func(t *testing.T) {
+			w := performRequest(router, "POST", "/api/v1/offers/10/restore")
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+	})
+}
@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/deepinbytes/go_voucher/common/barcodes"
+	"github.com/deepinbytes/go_voucher/common/keys"
+	"github.com/deepinbytes/go_voucher/services/printservice"
+	"github.com/deepinbytes/go_voucher/services/tokenservice"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrintController interface
+type PrintController interface {
+	QR(*gin.Context)
+	Barcode(*gin.Context)
+	Sheet(*gin.Context)
+}
+
+type printController struct {
+	printSvc printservice.PrintService
+}
+
+// NewPrintController instantiates Print Controller
+func NewPrintController(printSvc printservice.PrintService) PrintController {
+	return &printController{
+		printSvc: printSvc,
+	}
+}
+
+// @Summary Render a voucher code as a QR code PNG
+// @Produce  png
+// @Param code path string true "Code"
+// @Param size query int false "Width and height in pixels, 64 to 2048, defaults to 256"
+// @Param signed query bool false "Encode the signed token of the voucher for offline checks"
+// @Success 200
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 429 {object} Response
+// @Failure 500 {object} Response
+// @Failure 501 {object} Response
+// @Router /api/v1/vouchers/{code}/qr.png [get]
+func (ctl *printController) QR(c *gin.Context) {
+	size, err := ctl.intQuery(c, "size", 256, 64, 2048)
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	signed, err := ctl.signed(c)
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	b, err := ctl.printSvc.QR(c.Request.Context(), normalizeCode(c.Param("code")), size, signed)
+	if err != nil {
+		recordLookup(c, err)
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	c.Data(http.StatusOK, "image/png", b)
+}
+
+// @Summary Render a voucher code as a Code 128 or EAN barcode PNG
+// @Produce  png
+// @Param code path string true "Code"
+// @Param format query string false "code128 or ean, defaults to code128; ean only holds digits"
+// @Param width query int false "Width in pixels, 100 to 4000, defaults to 400"
+// @Param height query int false "Height in pixels, 20 to 1000, defaults to 120"
+// @Param signed query bool false "Encode the signed token of the voucher for offline checks"
+// @Success 200
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 429 {object} Response
+// @Failure 500 {object} Response
+// @Failure 501 {object} Response
+// @Router /api/v1/vouchers/{code}/barcode.png [get]
+func (ctl *printController) Barcode(c *gin.Context) {
+	width, err := ctl.intQuery(c, "width", 400, 100, 4000)
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	height, err := ctl.intQuery(c, "height", 120, 20, 1000)
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	signed, err := ctl.signed(c)
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	b, err := ctl.printSvc.Barcode(c.Request.Context(), normalizeCode(c.Param("code")),
+		c.DefaultQuery("format", barcodes.Code128), width, height, signed)
+	if err != nil {
+		recordLookup(c, err)
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	c.Data(http.StatusOK, "image/png", b)
+}
+
+// @Summary Print sheet of the unused, unexpired vouchers of an offer, the Link header points to the next page
+// @Produce  html
+// @Param id path int true "ID"
+// @Param after query int false "List vouchers after this ID"
+// @Param limit query int false "Page size, at most 100"
+// @Param signed query bool false "Encode the signed tokens of the vouchers for offline checks"
+// @Success 200
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Failure 501 {object} Response
+// @Router /api/v1/offers/{id}/sheet [get]
+func (ctl *printController) Sheet(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		HTTPRes(c, http.StatusBadRequest, "offer id should be a positive number", nil)
+		return
+	}
+	after, limit, err := pageParams(c)
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	signed, err := ctl.signed(c)
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	sheet, err := ctl.printSvc.Sheet(c.Request.Context(), uint(id), after, limit, signed)
+	if err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	var html bytes.Buffer
+	if err := sheet.WriteHTML(&html); err != nil {
+		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	if len(sheet.Labels) == limit {
+		setNextLink(c, sheet.Last, limit)
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", html.Bytes())
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// intQuery reads the query parameter name, def when it is absent
+func (ctl *printController) intQuery(c *gin.Context, name string, def, min, max int) (int, error) {
+	s := c.Query(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%s should be a number between %d and %d", name, min, max)
+	}
+	return n, nil
+}
+
+func (ctl *printController) signed(c *gin.Context) (bool, error) {
+	s := c.Query("signed")
+	if s == "" {
+		return false, nil
+	}
+	signed, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, errors.New("signed should be true or false")
+	}
+	return signed, nil
+}
+
+func (ctl *printController) errStatus(err error) int {
+	switch {
+	case err == tokenservice.ErrNoSigner:
+		return http.StatusNotImplemented
+	case err == keys.ErrNoActiveKey:
+		return http.StatusServiceUnavailable
+	case err == barcodes.ErrFormat, errors.Is(err, barcodes.ErrEncode), errors.Is(err, barcodes.ErrSize):
+		return http.StatusBadRequest
+	}
+	return errStatus(err)
+}
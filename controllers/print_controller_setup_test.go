@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/deepinbytes/go_voucher/common/barcodes"
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/services/printservice"
+	"github.com/deepinbytes/go_voucher/services/tokenservice"
+
+	"github.com/jinzhu/gorm"
+)
+
+type printSvc struct {
+	signed bool
+}
+
+func (ps *printSvc) QR(ctx context.Context, code string, size int, signed bool) ([]byte, error) {
+	return ps.render(code, signed, fmt.Sprintf("qr %d", size))
+}
+
+func (ps *printSvc) Barcode(ctx context.Context, code, format string, width, height int, signed bool) ([]byte, error) {
+	if format != barcodes.Code128 && format != barcodes.EAN {
+		return nil, barcodes.ErrFormat
+	}
+	if format == barcodes.EAN {
+		return nil, fmt.Errorf("%w ean: only digits", barcodes.ErrEncode)
+	}
+	return ps.render(code, signed, fmt.Sprintf("%s %dx%d", format, width, height))
+}
+
+func (ps *printSvc) Sheet(ctx context.Context, offerID uint, after uint, limit int, signed bool) (*printservice.Sheet, error) {
+	if offerID >= uint(10) {
+		return nil, errors.New("record not found")
+	}
+	if signed && !ps.signed {
+		return nil, tokenservice.ErrNoSigner
+	}
+	sheet := &printservice.Sheet{Offer: &offer.Offer{Model: gorm.Model{ID: offerID}, Name: "Summer"}, Discount: "20%"}
+	for i := after + 1; i <= 3 && len(sheet.Labels) < limit; i++ {
+		sheet.Labels = append(sheet.Labels, &printservice.Label{Code: fmt.Sprintf("CODE%04d", i), Expires: "2020-12-31"})
+		sheet.Last = i
+	}
+	return sheet, nil
+}
+
+// render fakes images with a description of what was asked for
+func (ps *printSvc) render(code string, signed bool, what string) ([]byte, error) {
+	if code == "NON_EXISTENT_CODE" {
+		return nil, errors.New("record not found")
+	}
+	if signed {
+		if !ps.signed {
+			return nil, tokenservice.ErrNoSigner
+		}
+		code = "v1." + code + ".sig"
+	}
+	return []byte(what + " " + code), nil
+}
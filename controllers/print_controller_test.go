@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// NOTE: Mocked services are in './print_controller_setup_test.go'
+
+func TestPrintController(t *testing.T) {
+
+	// Setup router + print controller
+	ps := &printSvc{signed: true}
+	printCtl := NewPrintController(ps)
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/api/v1/vouchers/:code/qr.png", printCtl.QR)
+	router.GET("/api/v1/vouchers/:code/barcode.png", printCtl.Barcode)
+	router.GET("/api/v1/offers/:id/sheet", printCtl.Sheet)
+
+	t.Run("QR", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/vouchers/test1/qr.png")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+		assert.Equal(t, "qr 256 TEST1", w.Body.String())
+
+		w = performRequest(router, "GET", "/api/v1/vouchers/test1/qr.png?size=512&signed=true")
+		assert.Equal(t, "qr 512 v1.TEST1.sig", w.Body.String())
+
+		for _, path := range []string{"qr.png?size=32", "qr.png?size=x", "qr.png?signed=maybe"} {
+			assert.Equal(t, http.StatusBadRequest, performRequest(router, "GET", "/api/v1/vouchers/test1/"+path).Code, path)
+		}
+		assert.Equal(t, http.StatusNotFound, performRequest(router, "GET", "/api/v1/vouchers/non_existent_code/qr.png").Code)
+	})
+
+	t.Run("Barcode", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/vouchers/test1/barcode.png?height=60")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "code128 400x60 TEST1", w.Body.String())
+
+		assert.Equal(t, http.StatusBadRequest, performRequest(router, "GET", "/api/v1/vouchers/test1/barcode.png?format=ean").Code)
+		assert.Equal(t, http.StatusBadRequest, performRequest(router, "GET", "/api/v1/vouchers/test1/barcode.png?format=upc").Code)
+		assert.Equal(t, http.StatusBadRequest, performRequest(router, "GET", "/api/v1/vouchers/test1/barcode.png?width=10").Code)
+	})
+
+	t.Run("Sheet", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/offers/1/sheet?limit=2")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), `<div class="code">CODE0002</div>`)
+		assert.Equal(t, `</api/v1/offers/1/sheet?after=2&limit=2>; rel="next"`, w.Header().Get("Link"))
+
+		w = performRequest(router, "GET", "/api/v1/offers/1/sheet?after=2&limit=2")
+		assert.Contains(t, w.Body.String(), "CODE0003")
+		assert.Empty(t, w.Header().Get("Link"))
+
+		assert.Equal(t, http.StatusNotFound, performRequest(router, "GET", "/api/v1/offers/10/sheet").Code)
+		assert.Equal(t, http.StatusBadRequest, performRequest(router, "GET", "/api/v1/offers/x/sheet").Code)
+	})
+
+	t.Run("Signing needs a key", func(t *testing.T) {
+		ps.signed = false
+		defer func() { ps.signed = true }()
+		assert.Equal(t, http.StatusNotImplemented, performRequest(router, "GET", "/api/v1/vouchers/test1/qr.png?signed=1").Code)
+		assert.Equal(t, http.StatusNotImplemented, performRequest(router, "GET", "/api/v1/offers/1/sheet?signed=true").Code)
+	})
+}
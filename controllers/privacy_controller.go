@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/deepinbytes/go_voucher/services/privacyservice"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrivacyController interface
+type PrivacyController interface {
+	Export(*gin.Context)
+	Erase(*gin.Context)
+	Records(*gin.Context)
+}
+
+type privacyController struct {
+	privacySvc privacyservice.PrivacyService
+}
+
+// NewPrivacyController instantiates Privacy Controller
+func NewPrivacyController(privacySvc privacyservice.PrivacyService) PrivacyController {
+	return &privacyController{
+		privacySvc: privacySvc,
+	}
+}
+
+// @Summary Export the personal data, vouchers and redemptions of a user
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/users/{id}/data-export [get]
+func (ctl *privacyController) Export(c *gin.Context) {
+	id, err := ctl.getUserID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	export, err := ctl.privacySvc.Export(c.Request.Context(), id)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	// personal data must not linger in shared caches
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d-export.json"`, id))
+	HTTPRes(c, http.StatusOK, "ok", export)
+}
+
+// @Summary Erase a user, pseudonymizing its personal data but keeping its redemptions
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/users/{id}/erasure [post]
+func (ctl *privacyController) Erase(c *gin.Context) {
+	id, err := ctl.getUserID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	record, err := ctl.privacySvc.Erase(c.Request.Context(), id)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", record)
+}
+
+// @Summary List the data exports and erasures of a user
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/users/{id}/privacy-requests [get]
+func (ctl *privacyController) Records(c *gin.Context) {
+	id, err := ctl.getUserID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	records, err := ctl.privacySvc.Records(c.Request.Context(), id)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", records)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (ctl *privacyController) getUserID(userIDParam string) (uint, error) {
+	userID, err := strconv.Atoi(userIDParam)
+	if err != nil {
+		return 0, errors.New("user id should be a number")
+	}
+	return uint(userID), nil
+}
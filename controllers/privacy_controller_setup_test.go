@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/privacy"
+)
+
+type privacySvc struct{}
+
+var exportedAt = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func (ps *privacySvc) Export(ctx context.Context, userID uint) (*privacy.Export, error) {
+	if userID >= uint(10) {
+		return nil, errors.New("record not found")
+	}
+	return &privacy.Export{
+		ExportedAt:  exportedAt,
+		Profile:     privacy.Profile{ID: userID, Email: alice.Email, FirstName: alice.FirstName},
+		Vouchers:    []privacy.Voucher{{Code: "TEST1", OfferID: 1, IsUsed: true}},
+		Redemptions: []privacy.Redemption{{Code: "TEST1", OfferID: 1, UsedAt: exportedAt}},
+		Requests:    []privacy.Record{{ID: 1, UserID: userID, Action: privacy.ActionExport, CreatedAt: exportedAt}},
+	}, nil
+}
+
+func (ps *privacySvc) Erase(ctx context.Context, userID uint) (*privacy.Record, error) {
+	if userID >= uint(10) {
+		return nil, errors.New("record not found")
+	}
+	return &privacy.Record{ID: 2, UserID: userID, Action: privacy.ActionErasure, CreatedAt: exportedAt}, nil
+}
+
+func (ps *privacySvc) Records(ctx context.Context, userID uint) ([]*privacy.Record, error) {
+	return []*privacy.Record{}, nil
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/deepinbytes/go_voucher/domain/privacy"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// NOTE: Mocked services are in './privacy_controller_setup_test.go'
+
+func TestPrivacyController(t *testing.T) {
+
+	// Setup router + privacy controller
+	privacyCtl := NewPrivacyController(&privacySvc{})
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/api/v1/users/:id/data-export", privacyCtl.Export)
+	router.POST("/api/v1/users/:id/erasure", privacyCtl.Erase)
+	router.GET("/api/v1/users/:id/privacy-requests", privacyCtl.Records)
+
+	t.Run("Export", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			w := performRequest(router, "GET", "/api/v1/users/1/data-export")
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+			assert.Equal(t, `attachment; filename="user-1-export.json"`, w.Header().Get("Content-Disposition"))
+
+			resBody := struct {
+				Data privacy.Export `json:"data"`
+			}{}
+			json.NewDecoder(w.Body).Decode(&resBody)
+			assert.Equal(t, alice.Email, resBody.Data.Profile.Email)
+			assert.Len(t, resBody.Data.Redemptions, 1)
+		})
+
+		t.Run("Not found", func(t *testing.T) {
+			w := performRequest(router, "GET", "/api/v1/users/10/data-export")
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+
+		t.Run("Bad id", func(t *testing.T) {
+			w := performRequest(router, "GET", "/api/v1/users/alice/data-export")
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	})
+
+	t.Run("Erase", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			w := performRequest(router, "POST", "/api/v1/users/1/erasure")
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			resBody := struct {
+				Data privacy.Record `json:"data"`
+			}{}
+			json.NewDecoder(w.Body).Decode(&resBody)
+			assert.Equal(t, privacy.ActionErasure, resBody.Data.Action)
+		})
+
+		t.Run("Not found", func(t *testing.T) {
+			w := performRequest(router, "POST", "/api/v1/users/10/erasure")
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+	})
+
+	t.Run("Records", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/users/10/privacy-requests")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
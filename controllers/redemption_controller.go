@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/deepinbytes/go_voucher/common/ratelimit"
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/domain/redemption"
+	"github.com/deepinbytes/go_voucher/services/redemptionservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RedemptionInput represents redeeming several vouchers for one order
+type RedemptionInput struct {
+	Codes       []string `json:"codes" binding:"required,min=1,max=10,dive,vouchercode"`
+	Email       string   `json:"email" binding:"required,email"`
+	MaxDiscount uint     `json:"max_discount" binding:"omitempty,min=1,max=100"`
+	OrderTotal  *Money   `json:"order_total"`
+	DryRun      bool     `json:"dry_run"`
+}
+
+// Money represents an amount in the minor units of its currency, e.g. cents
+type Money struct {
+	Amount   int64  `json:"amount" binding:"required,min=1"`
+	Currency string `json:"currency" binding:"required,currency"`
+}
+
+func (in *RedemptionInput) normalize() {
+	for i, code := range in.Codes {
+		in.Codes[i] = normalizeCode(code)
+	}
+	in.Email = normalizeEmail(in.Email)
+	if in.OrderTotal != nil {
+		in.OrderTotal.Currency = normalizeCurrency(in.OrderTotal.Currency)
+	}
+}
+
+// RedemptionController interface
+type RedemptionController interface {
+	Post(*gin.Context)
+	Apply(*gin.Context)
+}
+
+type redemptionController struct {
+	redemptionSvc redemptionservice.RedemptionService
+}
+
+// NewRedemptionController instantiates Redemption Controller
+func NewRedemptionController(redemptionSvc redemptionservice.RedemptionService) RedemptionController {
+	return &redemptionController{
+		redemptionSvc: redemptionSvc,
+	}
+}
+
+// @Summary Redeems several vouchers for one order following the stacking rules of their offers, tells which codes were applied and why the others were rejected. Answers 409 when none applies
+// @Produce  json
+// @Param codes body []string true "Up to 10 codes"
+// @Param email body string true "Email of the owner of the vouchers"
+// @Param max_discount body int false "Cap of the combined discount, 100 by default"
+// @Param order_total body object false "Amount in minor units and currency, turns discounts into amounts"
+// @Param dry_run body bool false "Only work out the result"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 409 {object} Response
+// @Failure 422 {object} Response
+// @Failure 429 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/redemptions [post]
+func (ctl *redemptionController) Post(c *gin.Context) {
+	var input RedemptionInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	req := redemption.Request{
+		Codes:       input.Codes,
+		Email:       input.Email,
+		MaxDiscount: input.MaxDiscount,
+		DryRun:      input.DryRun,
+	}
+	if t := input.OrderTotal; t != nil {
+		req.OrderTotal = &money.Money{Amount: t.Amount, Currency: t.Currency}
+	}
+	res, err := ctl.redemptionSvc.Apply(c.Request.Context(), req)
+	if err != nil {
+		ratelimit.RecordAttempt(c.Request.Context(), err, voucherservice.Guessed)
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	// every guessed code counts as a failed attempt, in dry runs too as
+	// they tell the same about the codes
+	for i := 0; i < ctl.guessed(res); i++ {
+		ratelimit.FailAttempt(c.Request.Context())
+	}
+	if len(res.Applied) > 0 {
+		ratelimit.SucceedAttempt(c.Request.Context())
+	}
+	if len(res.Applied) == 0 {
+		HTTPRes(c, http.StatusConflict, "no voucher applied", res)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", res)
+}
+
+// @Summary Same as POST /api/v1/redemptions, under the path it was first asked for
+// @Produce  json
+// @Param codes body []string true "Up to 10 codes"
+// @Param email body string true "Email of the owner of the vouchers"
+// @Param max_discount body int false "Cap of the combined discount, 100 by default"
+// @Param order_total body object false "Amount in minor units and currency, turns discounts into amounts"
+// @Param dry_run body bool false "Only work out the result"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 409 {object} Response
+// @Failure 422 {object} Response
+// @Failure 429 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/vouchers/apply [post]
+func (ctl *redemptionController) Apply(c *gin.Context) {
+	ctl.Post(c)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// guessed counts the codes res rejects the way guessing codes would
+func (ctl *redemptionController) guessed(res *redemption.Result) int {
+	n := 0
+	for _, r := range res.Rejected {
+		switch r.Reason {
+		case redemption.ReasonNotFound, redemption.ReasonWrongUser,
+			redemption.ReasonUsed, redemption.ReasonExpired:
+			n++
+		}
+	}
+	return n
+}
+
+// errStatus also covers a code redeemed concurrently, between the checks
+// and the redemption
+func (ctl *redemptionController) errStatus(err error) int {
+	switch err {
+	case redemptionservice.ErrMaxDiscount, money.ErrCurrency:
+		return http.StatusBadRequest
+	case voucherservice.ErrWrongUser:
+		return http.StatusForbidden
+	case voucherservice.ErrUsed, voucherservice.ErrExpired:
+		return http.StatusConflict
+	}
+	return errStatus(err)
+}
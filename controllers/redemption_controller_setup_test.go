@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/deepinbytes/go_voucher/domain/redemption"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+)
+
+type redemptionSvc struct {
+	req redemption.Request
+}
+
+func (rs *redemptionSvc) Apply(ctx context.Context, req redemption.Request) (*redemption.Result, error) {
+	rs.req = req
+	res := &redemption.Result{Applied: []redemption.Applied{}, Rejected: []redemption.Rejected{}, MaxDiscount: 100}
+	for _, code := range req.Codes {
+		switch code {
+		case "RACE":
+			return nil, voucherservice.ErrUsed
+		case "FAIL":
+			return nil, errors.New("oops")
+		case "NOPE":
+			res.Rejected = append(res.Rejected, redemption.Rejected{Code: code, Reason: redemption.ReasonNotFound})
+		default:
+			res.Applied = append(res.Applied, redemption.Applied{Code: code, DiscountPercentage: 10, Discount: 10})
+			res.TotalDiscount += 10
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/deepinbytes/go_voucher/common/ratelimit"
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/domain/redemption"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// NOTE: Mocked services are in './redemption_controller_setup_test.go'
+
+func TestRedemptionController(t *testing.T) {
+
+	// Setup router + redemption controller
+	rs := &redemptionSvc{}
+	redemptionCtl := NewRedemptionController(rs)
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.POST("/api/v1/redemptions", redemptionCtl.Post)
+
+	t.Run("Applies the codes", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/redemptions", map[string]interface{}{
+			"codes": []string{" abcd1234 ", "NOPE"}, "email": "Alice@CC.cc", "max_discount": 50, "dry_run": true,
+			"order_total": map[string]interface{}{"amount": 4999, "currency": "gbp"},
+		}, nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, redemption.Request{
+			Codes: []string{"ABCD1234", "NOPE"}, Email: "alice@cc.cc", MaxDiscount: 50, DryRun: true,
+			OrderTotal: &money.Money{Amount: 4999, Currency: "GBP"},
+		}, rs.req)
+		resBody := struct {
+			Data redemption.Result `json:"data"`
+		}{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, uint(10), resBody.Data.TotalDiscount)
+		assert.Equal(t, redemption.ReasonNotFound, resBody.Data.Rejected[0].Reason)
+	})
+
+	t.Run("Nothing applied", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/redemptions", map[string]interface{}{
+			"codes": []string{"NOPE"}, "email": "alice@cc.cc",
+		}, nil)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), redemption.ReasonNotFound)
+	})
+
+	t.Run("Counts every guessed code for the lockout, in dry runs too", func(t *testing.T) {
+		var attempt *ratelimit.Attempt
+		router := gin.New()
+		router.POST("/api/vouchers/apply", func(c *gin.Context) {
+			var ctx context.Context
+			ctx, attempt = ratelimit.NewAttemptContext(c.Request.Context())
+			c.Request = c.Request.WithContext(ctx)
+			c.Next()
+		}, redemptionCtl.Apply)
+
+		performJSONRequest(router, "POST", "/api/vouchers/apply", map[string]interface{}{
+			"codes": []string{"NOPE", "ABCD1234", "NOPE"}, "email": "alice@cc.cc", "dry_run": true,
+		}, nil)
+
+		assert.Equal(t, 2, attempt.Failures())
+		assert.False(t, attempt.Succeeded())
+	})
+
+	t.Run("Invalid bodies", func(t *testing.T) {
+		for _, body := range []map[string]interface{}{
+			{"email": "alice@cc.cc"},
+			{"codes": []string{}, "email": "alice@cc.cc"},
+			{"codes": []string{"AB"}, "email": "alice@cc.cc"},
+			{"codes": []string{"ABCD1234"}},
+			{"codes": []string{"ABCD1234"}, "email": "alice@cc.cc", "max_discount": 101},
+			{"codes": []string{"ABCD1234"}, "email": "alice@cc.cc", "order_total": map[string]interface{}{"amount": 100, "currency": "JPY"}},
+			{"codes": []string{"ABCD1234"}, "email": "alice@cc.cc", "order_total": map[string]interface{}{"currency": "EUR"}},
+		} {
+			w := performJSONRequest(router, "POST", "/api/v1/redemptions", body, nil)
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code, body)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/redemptions", map[string]interface{}{
+			"codes": []string{"RACE"}, "email": "alice@cc.cc",
+		}, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = performJSONRequest(router, "POST", "/api/v1/redemptions", map[string]interface{}{
+			"codes": []string{"FAIL"}, "email": "alice@cc.cc",
+		}, nil)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
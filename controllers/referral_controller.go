@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/deepinbytes/go_voucher/domain/referral"
+	"github.com/deepinbytes/go_voucher/services/referralservice"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReferralCodeOutput represents the referral code of a user with the
+// referrals made with it
+type ReferralCodeOutput struct {
+	UserID uint            `json:"user_id"`
+	Code   string          `json:"code"`
+	Stats  *referral.Stats `json:"stats"`
+}
+
+// ReferralController interface
+type ReferralController interface {
+	GetCode(*gin.Context)
+	Stats(*gin.Context)
+}
+
+type referralController struct {
+	referrals referralservice.ReferralService
+}
+
+// NewReferralController instantiates Referral Controller
+func NewReferralController(referrals referralservice.ReferralService) ReferralController {
+	return &referralController{
+		referrals: referrals,
+	}
+}
+
+// @Summary Get the referral code of a user, created on first use, with the referrals made with it
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/users/{id}/referral [get]
+func (ctl *referralController) GetCode(c *gin.Context) {
+	id, err := ctl.getID(c.Param("id"), "user")
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	code, err := ctl.referrals.Code(c.Request.Context(), id)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	stats, err := ctl.referrals.Stats(c.Request.Context(), id)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", &ReferralCodeOutput{UserID: code.UserID, Code: code.Code, Stats: stats})
+}
+
+// @Summary Sum up referrals by status and rejection reason
+// @Produce  json
+// @Param referrer_id query int false "Only the referrals of this user, all when empty"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/referrals/stats [get]
+func (ctl *referralController) Stats(c *gin.Context) {
+	var referrerID uint
+	if s := c.Query("referrer_id"); s != "" {
+		id, err := ctl.getID(s, "referrer")
+		if err != nil {
+			HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		referrerID = id
+	}
+
+	stats, err := ctl.referrals.Stats(c.Request.Context(), referrerID)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", stats)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (ctl *referralController) getID(param, name string) (uint, error) {
+	id, err := strconv.Atoi(param)
+	if err != nil || id <= 0 {
+		return 0, errors.New(name + " id should be a positive number")
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/deepinbytes/go_voucher/domain/referral"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/services/referralservice"
+)
+
+type referralSvc struct {
+	referred []uint
+}
+
+func (rs *referralSvc) Code(ctx context.Context, userID uint) (*referral.Code, error) {
+	if userID >= uint(10) {
+		return nil, errors.New("record not found")
+	}
+	return &referral.Code{UserID: userID, Code: "REFALICE"}, nil
+}
+
+func (rs *referralSvc) Lookup(ctx context.Context, code string) (*referral.Code, error) {
+	if code != "REFALICE" {
+		return nil, referralservice.ErrCode
+	}
+	return &referral.Code{UserID: alice.ID, Code: code}, nil
+}
+
+func (rs *referralSvc) Refer(ctx context.Context, referee *user.User, code *referral.Code) (*referral.Referral, error) {
+	rs.referred = append(rs.referred, code.UserID)
+	if referee.Email == "alice+1@cc.cc" {
+		return &referral.Referral{Status: referral.StatusRejected, Reason: referral.ReasonSelfReferral}, nil
+	}
+	return &referral.Referral{Status: referral.StatusPending}, nil
+}
+
+func (rs *referralSvc) Redeemed(ctx context.Context, userID uint) error {
+	return nil
+}
+
+func (rs *referralSvc) Stats(ctx context.Context, referrerID uint) (*referral.Stats, error) {
+	stats := &referral.Stats{Reasons: map[string]int64{}}
+	stats.Add(referral.StatusPending, "", 2)
+	if referrerID == 0 {
+		stats.Add(referral.StatusRewarded, "", 1)
+		stats.Add(referral.StatusRejected, referral.ReasonDomainThrottled, 1)
+	}
+	return stats, nil
+}
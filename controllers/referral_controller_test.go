@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/deepinbytes/go_voucher/domain/referral"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// NOTE: Mocked services are in './referral_controller_setup_test.go'
+
+func TestReferralController(t *testing.T) {
+
+	// Setup router + referral controller
+	referralCtl := NewReferralController(&referralSvc{})
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/api/v1/users/:id/referral", referralCtl.GetCode)
+	router.GET("/api/v1/referrals/stats", referralCtl.Stats)
+
+	t.Run("GetCode", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/users/1/referral")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		resBody := struct {
+			Data ReferralCodeOutput `json:"data"`
+		}{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, "REFALICE", resBody.Data.Code)
+		assert.Equal(t, int64(2), resBody.Data.Stats.Pending)
+
+		assert.Equal(t, http.StatusBadRequest, performRequest(router, "GET", "/api/v1/users/x/referral").Code)
+		assert.Equal(t, http.StatusNotFound, performRequest(router, "GET", "/api/v1/users/10/referral").Code)
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/referrals/stats")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		resBody := struct {
+			Data referral.Stats `json:"data"`
+		}{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, referral.Stats{
+			Total:          4,
+			Pending:        2,
+			Rewarded:       1,
+			Rejected:       1,
+			Reasons:        map[string]int64{referral.ReasonDomainThrottled: 1},
+			VouchersIssued: 2,
+		}, resBody.Data)
+
+		w = performRequest(router, "GET", "/api/v1/referrals/stats?referrer_id=1")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusBadRequest, performRequest(router, "GET", "/api/v1/referrals/stats?referrer_id=0").Code)
+	})
+}
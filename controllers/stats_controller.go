@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/stats"
+	"github.com/deepinbytes/go_voucher/services/statsservice"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultStatsPoints is the number of buckets listed without from
+const defaultStatsPoints = 30
+
+// StatsController interface
+type StatsController interface {
+	Offer(*gin.Context)
+	Series(*gin.Context)
+	Daily(*gin.Context)
+}
+
+type statsController struct {
+	statsSvc statsservice.StatsService
+}
+
+// NewStatsController instantiates Stats Controller
+func NewStatsController(statsSvc statsservice.StatsService) StatsController {
+	return &statsController{
+		statsSvc: statsSvc,
+	}
+}
+
+// @Summary Sum up the vouchers of an offer
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/offers/{id}/stats [get]
+func (ctl *statsController) Offer(c *gin.Context) {
+	id, err := ctl.getOfferID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	st, err := ctl.statsSvc.OfferStats(c.Request.Context(), id)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", st)
+}
+
+// @Summary Count the vouchers of an offer issued and redeemed per hour or day
+// @Produce  json
+// @Param id path int true "ID"
+// @Param bucket query string false "hour or day, defaults to day"
+// @Param from query string false "RFC 3339 time or date, defaults to 30 buckets before to"
+// @Param to query string false "RFC 3339 time or date, defaults to now"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/offers/{id}/stats/series [get]
+func (ctl *statsController) Series(c *gin.Context) {
+	id, err := ctl.getOfferID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	bucket := c.DefaultQuery("bucket", stats.BucketDay)
+	from, to, err := ctl.timeRange(c, stats.BucketSize(bucket))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	series, err := ctl.statsSvc.Series(c.Request.Context(), id, bucket, from, to)
+	if err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", series)
+}
+
+// @Summary Daily rollup of the vouchers, refreshed in the background
+// @Produce  json
+// @Param offer_id query int false "Only this offer"
+// @Param from query string false "RFC 3339 time or date, defaults to 30 days before to"
+// @Param to query string false "RFC 3339 time or date, defaults to now"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/stats/daily [get]
+func (ctl *statsController) Daily(c *gin.Context) {
+	var offerID uint
+	if s := c.Query("offer_id"); s != "" {
+		id, err := ctl.getOfferID(s)
+		if err != nil {
+			HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		offerID = id
+	}
+	from, to, err := ctl.timeRange(c, 24*time.Hour)
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	daily, err := ctl.statsSvc.Daily(c.Request.Context(), offerID, from, to)
+	if err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	if daily == nil {
+		daily = []*stats.Daily{}
+	}
+	HTTPRes(c, http.StatusOK, "ok", daily)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (ctl *statsController) getOfferID(offerIDParam string) (uint, error) {
+	offerID, err := strconv.Atoi(offerIDParam)
+	if err != nil {
+		return 0, errors.New("offer id should be a number")
+	}
+	return uint(offerID), nil
+}
+
+// timeRange reads the from and to query parameters, from defaults to
+// defaultStatsPoints buckets of size before to
+func (ctl *statsController) timeRange(c *gin.Context, size time.Duration) (time.Time, time.Time, error) {
+	to := time.Now().UTC()
+	if s := c.Query("to"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return to, to, fmt.Errorf("to %v", err)
+		}
+		to = t
+	}
+	from := to.Add(-defaultStatsPoints * size)
+	if s := c.Query("from"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return from, to, fmt.Errorf("from %v", err)
+		}
+		from = t
+	}
+	return from, to, nil
+}
+
+func (ctl *statsController) errStatus(err error) int {
+	if err == statsservice.ErrBucket || err == statsservice.ErrRange {
+		return http.StatusBadRequest
+	}
+	return errStatus(err)
+}
+
+// parseTime reads an RFC 3339 time or a date, taken as UTC midnight
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return t, errors.New("should be an RFC 3339 time or a date")
+	}
+	return t, nil
+}
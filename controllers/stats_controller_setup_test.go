@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/stats"
+	"github.com/deepinbytes/go_voucher/services/statsservice"
+)
+
+type statsSvc struct{}
+
+func (ss *statsSvc) OfferStats(ctx context.Context, offerID uint) (*stats.Offer, error) {
+	if offerID >= uint(10) {
+		return nil, errors.New("record not found")
+	}
+	return &stats.Offer{OfferID: offerID, Issued: 4, Redeemed: 1, RedemptionRate: 0.25}, nil
+}
+
+func (ss *statsSvc) Series(ctx context.Context, offerID uint, bucket string, from, to time.Time) ([]stats.Point, error) {
+	if stats.BucketSize(bucket) == 0 {
+		return nil, statsservice.ErrBucket
+	}
+	return []stats.Point{{Start: from, Issued: 1}, {Start: to, Redeemed: 1}}, nil
+}
+
+func (ss *statsSvc) Daily(ctx context.Context, offerID uint, from, to time.Time) ([]*stats.Daily, error) {
+	if offerID != 0 {
+		return nil, nil
+	}
+	return []*stats.Daily{{Day: from, OfferID: 1, Issued: 2}}, nil
+}
+
+func (ss *statsSvc) Refresh(ctx context.Context, from, to time.Time) error {
+	return nil
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/stats"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// NOTE: Mocked services are in './stats_controller_setup_test.go'
+
+func TestStatsController(t *testing.T) {
+
+	// Setup router + stats controller
+	statsCtl := NewStatsController(&statsSvc{})
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/api/v1/offers/:id/stats", statsCtl.Offer)
+	router.GET("/api/v1/offers/:id/stats/series", statsCtl.Series)
+	router.GET("/api/v1/stats/daily", statsCtl.Daily)
+
+	t.Run("Offer", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			w := performRequest(router, "GET", "/api/v1/offers/1/stats")
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			resBody := struct {
+				Data stats.Offer `json:"data"`
+			}{}
+			json.NewDecoder(w.Body).Decode(&resBody)
+			assert.Equal(t, 0.25, resBody.Data.RedemptionRate)
+		})
+
+		t.Run("Not found", func(t *testing.T) {
+			w := performRequest(router, "GET", "/api/v1/offers/10/stats")
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+	})
+
+	t.Run("Series", func(t *testing.T) {
+		t.Run("Reads the range", func(t *testing.T) {
+			w := performRequest(router, "GET",
+				"/api/v1/offers/1/stats/series?bucket=hour&from=2020-06-01&to=2020-06-01T06:00:00%2B02:00")
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			resBody := struct {
+				Data []stats.Point `json:"data"`
+			}{}
+			json.NewDecoder(w.Body).Decode(&resBody)
+			assert.Equal(t, []stats.Point{
+				{Start: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), Issued: 1},
+				{Start: time.Date(2020, 6, 1, 4, 0, 0, 0, time.UTC), Redeemed: 1},
+			}, resBody.Data)
+		})
+
+		t.Run("Bad bucket", func(t *testing.T) {
+			w := performRequest(router, "GET", "/api/v1/offers/1/stats/series?bucket=week")
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+
+		t.Run("Bad time", func(t *testing.T) {
+			w := performRequest(router, "GET", "/api/v1/offers/1/stats/series?from=yesterday")
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	})
+
+	t.Run("Daily", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/stats/daily?from=2020-06-01&to=2020-06-08")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = performRequest(router, "GET", "/api/v1/stats/daily?offer_id=2")
+		assert.Equal(t, http.StatusOK, w.Code)
+		resBody := Response{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, []interface{}{}, resBody.Data)
+	})
+}
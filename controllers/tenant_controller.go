@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/services/tenantservice"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errNotDefaultTenant is answered to tenants managing tenants
+var errNotDefaultTenant = errors.New("only the default tenant manages tenants")
+
+// TenantInput represents create tenant request body format
+type TenantInput struct {
+	Name string `json:"name" binding:"required,max=100"`
+	Slug string `json:"slug" binding:"required,max=63"`
+}
+
+// APIKeyInput represents create API key request body format
+type APIKeyInput struct {
+	Name string `json:"name" binding:"required,max=64"`
+}
+
+// TenantOutput represents tenant response format, the API key is only
+// returned once, when the tenant is created
+type TenantOutput struct {
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	Slug   string `json:"slug"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+// TenantController interface
+type TenantController interface {
+	Post(*gin.Context)
+	List(*gin.Context)
+	PostKey(*gin.Context)
+	ListKeys(*gin.Context)
+	RotateKey(*gin.Context)
+	DeleteKey(*gin.Context)
+}
+
+type tenantController struct {
+	tenantSvc tenantservice.TenantService
+}
+
+// NewTenantController instantiates Tenant Controller
+func NewTenantController(tenantSvc tenantservice.TenantService) TenantController {
+	return &tenantController{
+		tenantSvc: tenantSvc,
+	}
+}
+
+// @Summary Creates a tenant and returns its API key, which is not shown again. Needs an API key of the default tenant
+// @Produce  json
+// @Param name body string true "Name"
+// @Param slug body string true "Subdomain of the tenant"
+// @Success 201 {object} Response
+// @Failure 400 {object} Response
+// @Failure 401 {object} Response
+// @Failure 403 {object} Response
+// @Failure 409 {object} Response
+// @Failure 422 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/tenants [post]
+func (ctl *tenantController) Post(c *gin.Context) {
+	if !ctl.authorize(c, true) {
+		return
+	}
+	var input TenantInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	t := tenant.Tenant{Name: input.Name, Slug: input.Slug}
+
+	if err := ctl.tenantSvc.Create(c.Request.Context(), &t); err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	out := ctl.mapToTenantOutput(&t)
+	out.APIKey = t.APIKey
+	HTTPRes(c, http.StatusCreated, "ok", out)
+}
+
+// @Summary List the tenants. Needs an API key of the default tenant
+// @Produce  json
+// @Success 200 {object} Response
+// @Failure 401 {object} Response
+// @Failure 403 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/tenants [get]
+func (ctl *tenantController) List(c *gin.Context) {
+	if !ctl.authorize(c, true) {
+		return
+	}
+	tenants, err := ctl.tenantSvc.List(c.Request.Context())
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	out := make([]TenantOutput, 0, len(tenants))
+	for _, t := range tenants {
+		out = append(out, ctl.mapToTenantOutput(t))
+	}
+	HTTPRes(c, http.StatusOK, "ok", out)
+}
+
+// @Summary Creates an API key of the tenant and returns it, it is not shown again. Only its hash is stored. Needs a key of the tenant
+// @Produce  json
+// @Param name body string true "Name, e.g. the client using the key"
+// @Success 201 {object} Response
+// @Failure 401 {object} Response
+// @Failure 422 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/api-keys [post]
+func (ctl *tenantController) PostKey(c *gin.Context) {
+	if !ctl.authorize(c, false) {
+		return
+	}
+	var input APIKeyInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	k, err := ctl.tenantSvc.CreateKey(c.Request.Context(), input.Name)
+	if err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusCreated, "ok", k)
+}
+
+// @Summary List the API keys of the tenant by prefix, expired ones too. Needs a key of the tenant
+// @Produce  json
+// @Success 200 {object} Response
+// @Failure 401 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/api-keys [get]
+func (ctl *tenantController) ListKeys(c *gin.Context) {
+	if !ctl.authorize(c, false) {
+		return
+	}
+	ks, err := ctl.tenantSvc.ListKeys(c.Request.Context())
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", ks)
+}
+
+// @Summary Replaces an API key with a new one, returned once. The old key keeps working for API_KEY_GRACE. Needs a key of the tenant
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 201 {object} Response
+// @Failure 400 {object} Response
+// @Failure 401 {object} Response
+// @Failure 404 {object} Response
+// @Failure 409 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/api-keys/{id}/rotate [post]
+func (ctl *tenantController) RotateKey(c *gin.Context) {
+	if !ctl.authorize(c, false) {
+		return
+	}
+	id, err := ctl.getKeyID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	k, err := ctl.tenantSvc.RotateKey(c.Request.Context(), id)
+	if err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusCreated, "ok", k)
+}
+
+// @Summary Retires an API key, it stops working at once. Needs a key of the tenant
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 204
+// @Failure 400 {object} Response
+// @Failure 401 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/api-keys/{id} [delete]
+func (ctl *tenantController) DeleteKey(c *gin.Context) {
+	if !ctl.authorize(c, false) {
+		return
+	}
+	id, err := ctl.getKeyID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if _, err := ctl.tenantSvc.RetireKey(c.Request.Context(), id); err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// authorize answers 401 unless the request was authenticated by an API key
+// of its tenant and, for admin, 403 unless that is the default tenant
+func (ctl *tenantController) authorize(c *gin.Context, admin bool) bool {
+	ctx := c.Request.Context()
+	if tenant.KeyFromContext(ctx) == 0 {
+		HTTPRes(c, http.StatusUnauthorized, tenantservice.ErrAPIKeyRequired.Error(), nil)
+		return false
+	}
+	if admin && tenant.FromContext(ctx) != tenant.DefaultID {
+		HTTPRes(c, http.StatusForbidden, errNotDefaultTenant.Error(), nil)
+		return false
+	}
+	return true
+}
+
+func (ctl *tenantController) errStatus(err error) int {
+	switch err {
+	case tenantservice.ErrSlug:
+		return http.StatusBadRequest
+	case tenantservice.ErrSlugTaken, tenantservice.ErrKeyExpired:
+		return http.StatusConflict
+	}
+	return errStatus(err)
+}
+
+func (ctl *tenantController) getKeyID(param string) (uint, error) {
+	id, err := strconv.Atoi(param)
+	if err != nil || id <= 0 {
+		return 0, errors.New("API key id should be a positive number")
+	}
+	return uint(id), nil
+}
+
+func (ctl *tenantController) mapToTenantOutput(t *tenant.Tenant) TenantOutput {
+	return TenantOutput{
+		ID:   t.ID,
+		Name: t.Name,
+		Slug: t.Slug,
+	}
+}
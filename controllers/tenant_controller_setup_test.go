@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/apikey"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/services/tenantservice"
+
+	"github.com/jinzhu/gorm"
+)
+
+type tenantSvc struct{}
+
+func (ts *tenantSvc) Create(ctx context.Context, t *tenant.Tenant) error {
+	switch t.Slug {
+	case "acme":
+		return tenantservice.ErrSlugTaken
+	case "Bad":
+		return tenantservice.ErrSlug
+	}
+	t.ID = 2
+	t.APIKey = "secret"
+	return nil
+}
+
+func (ts *tenantSvc) List(ctx context.Context) ([]*tenant.Tenant, error) {
+	return []*tenant.Tenant{
+		{Model: gorm.Model{ID: tenant.DefaultID}, Name: "Default", Slug: tenant.DefaultSlug},
+		{Model: gorm.Model{ID: 2}, Name: "Acme", Slug: "acme", APIKey: "secret"},
+	}, nil
+}
+
+func (ts *tenantSvc) Resolve(ctx context.Context, slug, apiKey string) (*tenant.Tenant, error) {
+	return nil, tenantservice.ErrUnknownTenant
+}
+
+func (ts *tenantSvc) BootstrapAdminKey(ctx context.Context, key string) error {
+	return nil
+}
+
+func (ts *tenantSvc) CreateKey(ctx context.Context, name string) (*apikey.Key, error) {
+	return &apikey.Key{ID: 3, TenantID: tenant.FromContext(ctx), Name: name, Prefix: "0a1b2c3d",
+		Hash: "hash", Plain: "0a1b2c3d4e5f"}, nil
+}
+
+func (ts *tenantSvc) ListKeys(ctx context.Context) ([]*apikey.Key, error) {
+	expired := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	return []*apikey.Key{
+		{ID: 1, TenantID: tenant.FromContext(ctx), Name: "initial", Prefix: "9f8e7d6c", Hash: "hash", ExpiresAt: &expired},
+		{ID: 3, TenantID: tenant.FromContext(ctx), Name: "ci", Prefix: "0a1b2c3d", Hash: "hash"},
+	}, nil
+}
+
+func (ts *tenantSvc) RotateKey(ctx context.Context, id uint) (*apikey.Key, error) {
+	switch id {
+	case 1:
+		return nil, tenantservice.ErrKeyExpired
+	case 3:
+		return &apikey.Key{ID: 4, Name: "ci", Prefix: "5a6b7c8d", Hash: "hash", Plain: "5a6b7c8d9e0f"}, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (ts *tenantSvc) RetireKey(ctx context.Context, id uint) (*apikey.Key, error) {
+	if id != 3 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	now := time.Now()
+	return &apikey.Key{ID: 3, Name: "ci", Prefix: "0a1b2c3d", Hash: "hash", ExpiresAt: &now}, nil
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// NOTE: Mocked services are in './tenant_controller_setup_test.go'
+
+func TestTenantController(t *testing.T) {
+
+	// Setup router + tenant controller, X-Tenant and X-API-Key stand in for
+	// the tenant middleware
+	tenantCtl := NewTenantController(&tenantSvc{})
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if c.GetHeader("X-Tenant") == "acme" {
+			ctx = tenant.NewContext(ctx, 2)
+		}
+		if c.GetHeader("X-API-Key") != "" {
+			ctx = tenant.NewKeyContext(ctx, 9)
+		}
+		c.Request = c.Request.WithContext(ctx)
+	})
+	admin := map[string]string{"X-API-Key": "admin"}
+	router.POST("/api/v1/tenants", tenantCtl.Post)
+	router.GET("/api/v1/tenants", tenantCtl.List)
+	router.POST("/api/v1/api-keys", tenantCtl.PostKey)
+	router.GET("/api/v1/api-keys", tenantCtl.ListKeys)
+	router.POST("/api/v1/api-keys/:id/rotate", tenantCtl.RotateKey)
+	router.DELETE("/api/v1/api-keys/:id", tenantCtl.DeleteKey)
+
+	t.Run("Creates and returns the API key", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/tenants", TenantInput{Name: "Globex", Slug: "globex"}, admin)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		resBody := struct {
+			Data TenantOutput `json:"data"`
+		}{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, TenantOutput{ID: 2, Name: "Globex", Slug: "globex", APIKey: "secret"}, resBody.Data)
+	})
+
+	t.Run("Bad slugs", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/tenants", TenantInput{Name: "Acme", Slug: "acme"}, admin)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = performJSONRequest(router, "POST", "/api/v1/tenants", TenantInput{Name: "Bad", Slug: "Bad"}, admin)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = performJSONRequest(router, "POST", "/api/v1/tenants", TenantInput{Name: "Globex"}, admin)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("Lists without API keys", func(t *testing.T) {
+		w := performJSONRequest(router, "GET", "/api/v1/tenants", nil, admin)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "secret")
+		resBody := struct {
+			Data []TenantOutput `json:"data"`
+		}{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Len(t, resBody.Data, 2)
+	})
+
+	t.Run("Anonymous calls are unauthorized", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/tenants")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = performJSONRequest(router, "POST", "/api/v1/tenants", TenantInput{Name: "Globex", Slug: "globex"}, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Other tenants are forbidden", func(t *testing.T) {
+		header := map[string]string{"X-Tenant": "acme", "X-API-Key": "acme-key"}
+		w := performJSONRequest(router, "GET", "/api/v1/tenants", nil, header)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = performJSONRequest(router, "POST", "/api/v1/tenants", TenantInput{Name: "Globex", Slug: "globex"}, header)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Creates API keys, returned once", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/api-keys", APIKeyInput{Name: "ci"}, admin)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"key":"0a1b2c3d4e5f"`)
+		assert.NotContains(t, w.Body.String(), "hash")
+
+		w = performJSONRequest(router, "POST", "/api/v1/api-keys", map[string]string{}, admin)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("Lists API keys without them", func(t *testing.T) {
+		w := performJSONRequest(router, "GET", "/api/v1/api-keys", nil, admin)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), `"key"`)
+		assert.NotContains(t, w.Body.String(), "hash")
+		assert.Contains(t, w.Body.String(), `"prefix":"9f8e7d6c"`)
+		assert.Contains(t, w.Body.String(), `"expires_at":"2020-06-01T12:00:00Z"`)
+	})
+
+	t.Run("Rotates API keys", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/api-keys/3/rotate", nil, admin)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"key":"5a6b7c8d9e0f"`)
+
+		for path, code := range map[string]int{
+			"/api/v1/api-keys/1/rotate":   http.StatusConflict,
+			"/api/v1/api-keys/2/rotate":   http.StatusNotFound,
+			"/api/v1/api-keys/abc/rotate": http.StatusBadRequest,
+		} {
+			assert.Equal(t, code, performJSONRequest(router, "POST", path, nil, admin).Code, path)
+		}
+	})
+
+	t.Run("Retires API keys", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, performJSONRequest(router, "DELETE", "/api/v1/api-keys/3", nil, admin).Code)
+		assert.Equal(t, http.StatusNotFound, performJSONRequest(router, "DELETE", "/api/v1/api-keys/2", nil, admin).Code)
+		assert.Equal(t, http.StatusBadRequest, performJSONRequest(router, "DELETE", "/api/v1/api-keys/0", nil, admin).Code)
+	})
+
+	t.Run("API keys need one of the tenant", func(t *testing.T) {
+		for _, r := range []struct{ method, path string }{
+			{"POST", "/api/v1/api-keys"},
+			{"GET", "/api/v1/api-keys"},
+			{"POST", "/api/v1/api-keys/3/rotate"},
+			{"DELETE", "/api/v1/api-keys/3"},
+		} {
+			w := performJSONRequest(router, r.method, r.path, APIKeyInput{Name: "ci"}, map[string]string{"X-Tenant": "acme"})
+			assert.Equal(t, http.StatusUnauthorized, w.Code, r.path)
+		}
+	})
+}
@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/keys"
+	"github.com/deepinbytes/go_voucher/domain/offline"
+	"github.com/deepinbytes/go_voucher/services/tokenservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TokenInput represents a token to verify
+type TokenInput struct {
+	Token string `json:"token" binding:"required,max=2048"`
+}
+
+// OfflineInput represents the redemptions a terminal accepted offline
+type OfflineInput struct {
+	Terminal    string                   `json:"terminal" binding:"required,max=64"`
+	Redemptions []OfflineRedemptionInput `json:"redemptions" binding:"required,min=1,max=100,dive"`
+}
+
+// OfflineRedemptionInput represents a redemption accepted offline, reference
+// identifies it on the terminal
+type OfflineRedemptionInput struct {
+	Reference  string    `json:"reference" binding:"required,max=64"`
+	Token      string    `json:"token" binding:"required,max=2048"`
+	RedeemedAt time.Time `json:"redeemed_at" binding:"required"`
+}
+
+func (in *OfflineInput) normalize() {
+	in.Terminal = strings.TrimSpace(in.Terminal)
+	for i := range in.Redemptions {
+		in.Redemptions[i].Reference = strings.TrimSpace(in.Redemptions[i].Reference)
+	}
+}
+
+// TokenController interface
+type TokenController interface {
+	Token(*gin.Context)
+	Verify(*gin.Context)
+	Key(*gin.Context)
+	Keys(*gin.Context)
+	Reconcile(*gin.Context)
+	ListOffline(*gin.Context)
+}
+
+type tokenController struct {
+	tokenSvc tokenservice.TokenService
+}
+
+// NewTokenController instantiates Token Controller
+func NewTokenController(tokenSvc tokenservice.TokenService) TokenController {
+	return &tokenController{
+		tokenSvc: tokenSvc,
+	}
+}
+
+// @Summary Get a signed token of a voucher that terminals check offline, used and expired vouchers have none
+// @Produce  json
+// @Param code path string true "Code"
+// @Success 200 {object} Response
+// @Failure 404 {object} Response
+// @Failure 409 {object} Response
+// @Failure 429 {object} Response
+// @Failure 500 {object} Response
+// @Failure 501 {object} Response
+// @Router /api/v1/vouchers/{code}/token [get]
+func (ctl *tokenController) Token(c *gin.Context) {
+	token, err := ctl.tokenSvc.Issue(c.Request.Context(), normalizeCode(c.Param("code")))
+	if err != nil {
+		recordLookup(c, err)
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", gin.H{"token": token})
+}
+
+// @Summary Check a voucher token as a terminal would, without the database, so revoked or used vouchers still pass
+// @Produce  json
+// @Param token body string true "Token"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 422 {object} Response
+// @Failure 500 {object} Response
+// @Failure 501 {object} Response
+// @Router /api/v1/tokens/verify [post]
+func (ctl *tokenController) Verify(c *gin.Context) {
+	var in TokenInput
+	if !bindJSON(c, &in) {
+		return
+	}
+
+	res, err := ctl.tokenSvc.Verify(c.Request.Context(), in.Token)
+	if err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", res)
+}
+
+// @Summary Get the ID, algorithm and public key of the key signing tokens
+// @Produce  json
+// @Success 200 {object} Response
+// @Failure 501 {object} Response
+// @Failure 503 {object} Response
+// @Router /api/v1/tokens/key [get]
+func (ctl *tokenController) Key(c *gin.Context) {
+	key, err := ctl.tokenSvc.Key()
+	if err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", key)
+}
+
+// @Summary List the signing keys with their status and schedule. Terminals trust the ones not retired, pending ones sign next
+// @Produce  json
+// @Success 200 {object} Response
+// @Failure 501 {object} Response
+// @Router /api/v1/tokens/keys [get]
+func (ctl *tokenController) Keys(c *gin.Context) {
+	ks, err := ctl.tokenSvc.Keys()
+	if err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", ks)
+}
+
+// @Summary Upload the redemptions a terminal accepted offline. Each is redeemed at its time or recorded as a double use or rejection, uploading a reference again returns its first outcome
+// @Produce  json
+// @Param terminal body string true "ID of the terminal"
+// @Param redemptions body []object true "Up to 100 of reference, token and redeemed_at"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 422 {object} Response
+// @Failure 500 {object} Response
+// @Failure 501 {object} Response
+// @Router /api/v1/offline-redemptions [post]
+func (ctl *tokenController) Reconcile(c *gin.Context) {
+	var in OfflineInput
+	if !bindJSON(c, &in) {
+		return
+	}
+	uploads := make([]offline.Upload, 0, len(in.Redemptions))
+	for _, r := range in.Redemptions {
+		uploads = append(uploads, offline.Upload{Reference: r.Reference, Token: r.Token, RedeemedAt: r.RedeemedAt})
+	}
+
+	rs, err := ctl.tokenSvc.Reconcile(c.Request.Context(), in.Terminal, uploads)
+	if err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", rs)
+}
+
+// @Summary List uploaded offline redemptions, the Link header points to the next page
+// @Produce  json
+// @Param status query string false "accepted, double_use, rejected or pending"
+// @Param after query int false "List redemptions after this ID"
+// @Param limit query int false "Page size, at most 100"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/offline-redemptions [get]
+func (ctl *tokenController) ListOffline(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", offline.StatusAccepted, offline.StatusDoubleUse, offline.StatusRejected, offline.StatusPending:
+	default:
+		HTTPRes(c, http.StatusBadRequest, "status should be accepted, double_use, rejected or pending", nil)
+		return
+	}
+	after, limit, err := pageParams(c)
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	rs, err := ctl.tokenSvc.List(c.Request.Context(), status, after, limit)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	if len(rs) == limit {
+		setNextLink(c, rs[len(rs)-1].ID, limit)
+	}
+	HTTPRes(c, http.StatusOK, "ok", rs)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (ctl *tokenController) errStatus(err error) int {
+	switch err {
+	case tokenservice.ErrNoSigner:
+		return http.StatusNotImplemented
+	case keys.ErrNoActiveKey:
+		return http.StatusServiceUnavailable
+	case voucherservice.ErrUsed, voucherservice.ErrExpired:
+		return http.StatusConflict
+	}
+	return errStatus(err)
+}
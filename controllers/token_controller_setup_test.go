@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/deepinbytes/go_voucher/common/verify"
+	"github.com/deepinbytes/go_voucher/domain/offline"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/services/tokenservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+)
+
+type tokenSvc struct {
+	unsigned bool
+	terminal string
+	uploads  []offline.Upload
+	status   string
+}
+
+func (ts *tokenSvc) Sign(ctx context.Context, v *voucher.Voucher) (string, error) {
+	if ts.unsigned {
+		return "", tokenservice.ErrNoSigner
+	}
+	return "v1." + v.Code + ".sig", nil
+}
+
+func (ts *tokenSvc) Issue(ctx context.Context, code string) (string, error) {
+	switch code {
+	case "NON_EXISTENT_CODE":
+		return "", errors.New("record not found")
+	case "USED":
+		return "", voucherservice.ErrUsed
+	}
+	return ts.Sign(ctx, &voucher.Voucher{Code: code})
+}
+
+func (ts *tokenSvc) Verify(ctx context.Context, token string) (*tokenservice.Verification, error) {
+	if ts.unsigned {
+		return nil, tokenservice.ErrNoSigner
+	}
+	if token != "v1.TEST1.sig" {
+		return &tokenservice.Verification{Reason: offline.ReasonInvalidToken}, nil
+	}
+	return &tokenservice.Verification{Valid: true, Claims: &verify.Claims{Tenant: 1, Code: "TEST1"}}, nil
+}
+
+func (ts *tokenSvc) Key() (*tokenservice.Key, error) {
+	if ts.unsigned {
+		return nil, tokenservice.ErrNoSigner
+	}
+	return &tokenservice.Key{ID: "default", Algorithm: "Ed25519", PublicKey: "cHVibGlj", Status: "active"}, nil
+}
+
+func (ts *tokenSvc) Keys() ([]*tokenservice.Key, error) {
+	if ts.unsigned {
+		return nil, tokenservice.ErrNoSigner
+	}
+	key, _ := ts.Key()
+	return []*tokenservice.Key{key, {ID: "2020-07", Algorithm: "HS256", Status: "pending"}}, nil
+}
+
+func (ts *tokenSvc) Reconcile(ctx context.Context, terminal string, uploads []offline.Upload) ([]*offline.Redemption, error) {
+	ts.terminal, ts.uploads = terminal, uploads
+	rs := make([]*offline.Redemption, 0, len(uploads))
+	for i, u := range uploads {
+		rs = append(rs, &offline.Redemption{ID: uint(i + 1), Terminal: terminal, Reference: u.Reference,
+			RedeemedAt: u.RedeemedAt, Status: offline.StatusAccepted})
+	}
+	return rs, nil
+}
+
+func (ts *tokenSvc) List(ctx context.Context, status string, after uint, limit int) ([]*offline.Redemption, error) {
+	ts.status = status
+	var rs []*offline.Redemption
+	for i := after + 1; i <= 3 && len(rs) < limit; i++ {
+		rs = append(rs, &offline.Redemption{ID: i, Status: offline.StatusDoubleUse})
+	}
+	return rs, nil
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/offline"
+	"github.com/deepinbytes/go_voucher/services/tokenservice"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// NOTE: Mocked services are in './token_controller_setup_test.go'
+
+func TestTokenController(t *testing.T) {
+
+	// Setup router + token controller
+	ts := &tokenSvc{}
+	tokenCtl := NewTokenController(ts)
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/api/v1/vouchers/:code/token", tokenCtl.Token)
+	router.POST("/api/v1/tokens/verify", tokenCtl.Verify)
+	router.GET("/api/v1/tokens/key", tokenCtl.Key)
+	router.GET("/api/v1/tokens/keys", tokenCtl.Keys)
+	router.POST("/api/v1/offline-redemptions", tokenCtl.Reconcile)
+	router.GET("/api/v1/offline-redemptions", tokenCtl.ListOffline)
+
+	t.Run("Token", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/vouchers/test1/token")
+		assert.Equal(t, http.StatusOK, w.Code)
+		resBody := struct {
+			Data struct {
+				Token string `json:"token"`
+			} `json:"data"`
+		}{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, "v1.TEST1.sig", resBody.Data.Token)
+
+		assert.Equal(t, http.StatusConflict, performRequest(router, "GET", "/api/v1/vouchers/used/token").Code)
+		assert.Equal(t, http.StatusNotFound, performRequest(router, "GET", "/api/v1/vouchers/non_existent_code/token").Code)
+	})
+
+	t.Run("Verify", func(t *testing.T) {
+		for token, valid := range map[string]bool{"v1.TEST1.sig": true, "v1.TEST2.sig": false} {
+			w := performJSONRequest(router, "POST", "/api/v1/tokens/verify", map[string]string{"token": token}, nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+			resBody := struct {
+				Data tokenservice.Verification `json:"data"`
+			}{}
+			json.NewDecoder(w.Body).Decode(&resBody)
+			assert.Equal(t, valid, resBody.Data.Valid, token)
+			assert.Equal(t, valid, resBody.Data.Reason == "", token)
+		}
+
+		w := performJSONRequest(router, "POST", "/api/v1/tokens/verify", map[string]string{}, nil)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("Key", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/tokens/key")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"algorithm":"Ed25519"`)
+		assert.Contains(t, w.Body.String(), `"id":"default"`)
+	})
+
+	t.Run("Keys", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/tokens/keys")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"id":"2020-07","algorithm":"HS256","status":"pending"`)
+	})
+
+	t.Run("Reconcile", func(t *testing.T) {
+		at := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+		w := performJSONRequest(router, "POST", "/api/v1/offline-redemptions", map[string]interface{}{
+			"terminal": " till-1 ",
+			"redemptions": []map[string]interface{}{
+				{"reference": "r1", "token": "v1.TEST1.sig", "redeemed_at": at},
+			},
+		}, nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "till-1", ts.terminal)
+		assert.Equal(t, []offline.Upload{{Reference: "r1", Token: "v1.TEST1.sig", RedeemedAt: at}}, ts.uploads)
+	})
+
+	t.Run("Reconcile validates", func(t *testing.T) {
+		for _, body := range []map[string]interface{}{
+			{"redemptions": []map[string]interface{}{{"reference": "r1", "token": "t", "redeemed_at": time.Now()}}},
+			{"terminal": "till-1"},
+			{"terminal": "till-1", "redemptions": []map[string]interface{}{{"reference": "r1", "token": "t"}}},
+			{"terminal": strings.Repeat("t", 65), "redemptions": []map[string]interface{}{
+				{"reference": "r1", "token": "t", "redeemed_at": time.Now()}}},
+		} {
+			w := performJSONRequest(router, "POST", "/api/v1/offline-redemptions", body, nil)
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code, "%v", body)
+		}
+	})
+
+	t.Run("ListOffline", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/offline-redemptions?status=double_use&limit=2")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, offline.StatusDoubleUse, ts.status)
+		assert.Equal(t, `</api/v1/offline-redemptions?after=2&limit=2&status=double_use>; rel="next"`, w.Header().Get("Link"))
+
+		assert.Equal(t, http.StatusBadRequest, performRequest(router, "GET", "/api/v1/offline-redemptions?status=used").Code)
+		assert.Equal(t, http.StatusBadRequest, performRequest(router, "GET", "/api/v1/offline-redemptions?limit=0").Code)
+	})
+
+	t.Run("Tokens need a key", func(t *testing.T) {
+		ts.unsigned = true
+		defer func() { ts.unsigned = false }()
+		assert.Equal(t, http.StatusNotImplemented, performRequest(router, "GET", "/api/v1/vouchers/test1/token").Code)
+		assert.Equal(t, http.StatusNotImplemented, performRequest(router, "GET", "/api/v1/tokens/key").Code)
+		assert.Equal(t, http.StatusNotImplemented, performRequest(router, "GET", "/api/v1/tokens/keys").Code)
+	})
+}
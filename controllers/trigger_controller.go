@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/deepinbytes/go_voucher/domain/trigger"
+	"github.com/deepinbytes/go_voucher/services/triggerservice"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TriggerInput represents creating a trigger, it is active unless active
+// is false
+type TriggerInput struct {
+	Event        string `json:"event" binding:"required,oneof=user_registered birthday"`
+	OfferID      uint   `json:"offer_id" binding:"required"`
+	ValidityDays int    `json:"validity_days" binding:"required,min=1,max=3650"`
+	Active       *bool  `json:"active"`
+}
+
+// TriggerPatchInput represents the fields a PATCH sets, absent ones are kept
+type TriggerPatchInput struct {
+	OfferID      *uint `json:"offer_id" binding:"omitempty,min=1"`
+	ValidityDays *int  `json:"validity_days" binding:"omitempty,min=1,max=3650"`
+	Active       *bool `json:"active"`
+}
+
+func (in *TriggerInput) normalize() {
+	in.Event = strings.ToLower(strings.TrimSpace(in.Event))
+}
+
+// TriggerController interface
+type TriggerController interface {
+	List(*gin.Context)
+	Post(*gin.Context)
+	GetByID(*gin.Context)
+	Patch(*gin.Context)
+	Delete(*gin.Context)
+}
+
+type triggerController struct {
+	triggers triggerservice.TriggerService
+}
+
+// NewTriggerController instantiates Trigger Controller
+func NewTriggerController(triggers triggerservice.TriggerService) TriggerController {
+	return &triggerController{
+		triggers: triggers,
+	}
+}
+
+// @Summary List the triggers issuing vouchers on events
+// @Produce  json
+// @Success 200 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/triggers [get]
+func (ctl *triggerController) List(c *gin.Context) {
+	ts, err := ctl.triggers.List(c.Request.Context())
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", ts)
+}
+
+// @Summary Create a trigger issuing a voucher of an offer to a user on registration or on its birthday
+// @Produce  json
+// @Param event body string true "user_registered or birthday"
+// @Param offer_id body int true "Offer of the vouchers issued"
+// @Param validity_days body int true "Days the vouchers issued are valid for"
+// @Param active body bool false "False creates the trigger paused"
+// @Success 201 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 422 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/triggers [post]
+func (ctl *triggerController) Post(c *gin.Context) {
+	var in TriggerInput
+	if !bindJSON(c, &in) {
+		return
+	}
+	t := &trigger.Trigger{Event: in.Event, OfferID: in.OfferID, ValidityDays: in.ValidityDays, Active: true}
+	if in.Active != nil {
+		t.Active = *in.Active
+	}
+
+	if err := ctl.triggers.Create(c.Request.Context(), t); err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	c.Header("Location", fmt.Sprintf("/api/v1/triggers/%d", t.ID))
+	HTTPRes(c, http.StatusCreated, "ok", t)
+}
+
+// @Summary Get a trigger
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/triggers/{id} [get]
+func (ctl *triggerController) GetByID(c *gin.Context) {
+	id, err := ctl.getID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	t, err := ctl.triggers.GetByID(c.Request.Context(), id)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", t)
+}
+
+// @Summary Update the given fields of a trigger, its event is fixed
+// @Produce  json
+// @Param id path int true "ID"
+// @Param offer_id body int false "Offer of the vouchers issued"
+// @Param validity_days body int false "Days the vouchers issued are valid for"
+// @Param active body bool false "False pauses the trigger"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 422 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/triggers/{id} [patch]
+func (ctl *triggerController) Patch(c *gin.Context) {
+	id, err := ctl.getID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	var in TriggerPatchInput
+	if !bindJSON(c, &in) {
+		return
+	}
+
+	t, err := ctl.triggers.GetByID(c.Request.Context(), id)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	if in.OfferID != nil {
+		t.OfferID = *in.OfferID
+	}
+	if in.ValidityDays != nil {
+		t.ValidityDays = *in.ValidityDays
+	}
+	if in.Active != nil {
+		t.Active = *in.Active
+	}
+	if err := ctl.triggers.Update(c.Request.Context(), t); err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	HTTPRes(c, http.StatusOK, "ok", t)
+}
+
+// @Summary Delete a trigger, the vouchers it issued are kept
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 204
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/triggers/{id} [delete]
+func (ctl *triggerController) Delete(c *gin.Context) {
+	id, err := ctl.getID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if err := ctl.triggers.Delete(c.Request.Context(), id); err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (ctl *triggerController) getID(param string) (uint, error) {
+	id, err := strconv.Atoi(param)
+	if err != nil || id <= 0 {
+		return 0, errors.New("trigger id should be a positive number")
+	}
+	return uint(id), nil
+}
+
+func (ctl *triggerController) errStatus(err error) int {
+	if err == triggerservice.ErrEvent || err == triggerservice.ErrValidity {
+		return http.StatusBadRequest
+	}
+	return errStatus(err)
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/trigger"
+	"github.com/deepinbytes/go_voucher/domain/user"
+)
+
+type triggerSvc struct {
+	saved *trigger.Trigger
+}
+
+func (ts *triggerSvc) Create(ctx context.Context, t *trigger.Trigger) error {
+	if t.OfferID >= uint(10) {
+		return errors.New("record not found")
+	}
+	t.ID = 1
+	ts.saved = t
+	return nil
+}
+
+func (ts *triggerSvc) GetByID(ctx context.Context, id uint) (*trigger.Trigger, error) {
+	if id >= uint(10) {
+		return nil, errors.New("record not found")
+	}
+	return &trigger.Trigger{ID: id, Event: trigger.EventBirthday, OfferID: 2, ValidityDays: 7, Active: true}, nil
+}
+
+func (ts *triggerSvc) List(ctx context.Context) ([]*trigger.Trigger, error) {
+	t, _ := ts.GetByID(ctx, 1)
+	return []*trigger.Trigger{t}, nil
+}
+
+func (ts *triggerSvc) Update(ctx context.Context, t *trigger.Trigger) error {
+	ts.saved = t
+	return nil
+}
+
+func (ts *triggerSvc) Delete(ctx context.Context, id uint) error {
+	if id >= uint(10) {
+		return errors.New("record not found")
+	}
+	return nil
+}
+
+func (ts *triggerSvc) UserCreated(ctx context.Context, u *user.User) error {
+	return nil
+}
+
+func (ts *triggerSvc) Birthdays(ctx context.Context, day time.Time) (int, error) {
+	return 0, nil
+}
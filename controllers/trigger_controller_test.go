@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/deepinbytes/go_voucher/domain/trigger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// NOTE: Mocked services are in './trigger_controller_setup_test.go'
+
+func TestTriggerController(t *testing.T) {
+
+	// Setup router + trigger controller
+	ts := &triggerSvc{}
+	triggerCtl := NewTriggerController(ts)
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/api/v1/triggers", triggerCtl.List)
+	router.POST("/api/v1/triggers", triggerCtl.Post)
+	router.GET("/api/v1/triggers/:id", triggerCtl.GetByID)
+	router.PATCH("/api/v1/triggers/:id", triggerCtl.Patch)
+	router.DELETE("/api/v1/triggers/:id", triggerCtl.Delete)
+
+	t.Run("Post", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/triggers",
+			map[string]interface{}{"event": " User_Registered ", "offer_id": 2, "validity_days": 14}, nil)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "/api/v1/triggers/1", w.Header().Get("Location"))
+		assert.Equal(t, &trigger.Trigger{ID: 1, Event: trigger.EventUserRegistered, OfferID: 2, ValidityDays: 14, Active: true}, ts.saved)
+
+		w = performJSONRequest(router, "POST", "/api/v1/triggers",
+			map[string]interface{}{"event": "birthday", "offer_id": 2, "validity_days": 7, "active": false}, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.False(t, ts.saved.Active)
+	})
+
+	t.Run("Post validates", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/triggers",
+			map[string]interface{}{"event": "login", "offer_id": 2, "validity_days": 0}, nil)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		resBody := outputInvalid{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, []FieldError{
+			{Field: "event", Code: CodeInvalid, Message: "event is invalid"},
+			{Field: "validity_days", Code: CodeRequired, Message: "validity_days is required"},
+		}, resBody.Data)
+
+		w = performJSONRequest(router, "POST", "/api/v1/triggers",
+			map[string]interface{}{"event": "birthday", "offer_id": 20, "validity_days": 7}, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/triggers/1")
+		assert.Equal(t, http.StatusOK, w.Code)
+		resBody := struct {
+			Data trigger.Trigger `json:"data"`
+		}{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, trigger.EventBirthday, resBody.Data.Event)
+
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/api/v1/triggers").Code)
+		assert.Equal(t, http.StatusNotFound, performRequest(router, "GET", "/api/v1/triggers/10").Code)
+		assert.Equal(t, http.StatusBadRequest, performRequest(router, "GET", "/api/v1/triggers/x").Code)
+	})
+
+	t.Run("Patch", func(t *testing.T) {
+		w := performJSONRequest(router, "PATCH", "/api/v1/triggers/1",
+			map[string]interface{}{"active": false}, nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, &trigger.Trigger{ID: 1, Event: trigger.EventBirthday, OfferID: 2, ValidityDays: 7}, ts.saved)
+
+		w = performJSONRequest(router, "PATCH", "/api/v1/triggers/1",
+			map[string]interface{}{"validity_days": 0}, nil)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, performRequest(router, "DELETE", "/api/v1/triggers/1").Code)
+		assert.Equal(t, http.StatusNotFound, performRequest(router, "DELETE", "/api/v1/triggers/10").Code)
+	})
+}
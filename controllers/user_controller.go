@@ -2,12 +2,18 @@ package controllers
 
 import (
 	"errors"
+	"fmt"
 	"github.com/deepinbytes/go_voucher/domain/voucher"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/notification"
+	"github.com/deepinbytes/go_voucher/domain/referral"
 	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/services/lifecycleservice"
+	"github.com/deepinbytes/go_voucher/services/referralservice"
 	"github.com/deepinbytes/go_voucher/services/userservice"
 
 	"github.com/gin-gonic/gin"
@@ -15,25 +21,91 @@ import (
 
 // UserInput represents register request body format
 type UserInput struct {
-	Email     string `json:"email"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
+	Email     string `json:"email" binding:"required,email,max=255"`
+	FirstName string `json:"firstName" binding:"max=100"`
+	LastName  string `json:"lastName" binding:"max=100"`
+	// ReferralCode is the code of the user who referred this one
+	ReferralCode string `json:"referral_code" binding:"omitempty,vouchercode"`
+	Birthday     string `json:"birthday" binding:"omitempty,date"`
+	// Phone and Locale are where and in which language vouchers are sent
+	Phone  string `json:"phone" binding:"omitempty,phone"`
+	Locale string `json:"locale" binding:"omitempty,locale"`
 }
 
 // UserOutput represents returning user
 type UserOutput struct {
-	ID        uint              `json:"id"`
-	FirstName string            `json:"firstName"`
-	LastName  string            `json:"lastName"`
-	Email     string            `json:"email"`
-	Vouchers  []voucher.Voucher `json:"vouchers"`
+	ID        uint            `json:"id"`
+	FirstName string          `json:"firstName"`
+	LastName  string          `json:"lastName"`
+	Email     string          `json:"email"`
+	Vouchers  []VoucherOutput `json:"vouchers"`
+	Birthday  string          `json:"birthday,omitempty"`
+	Phone     string          `json:"phone,omitempty"`
+	Locale    string          `json:"locale,omitempty"`
+	// Referral is set on registration with a referral code
+	Referral *ReferralOutput `json:"referral,omitempty"`
+}
+
+// ReferralOutput represents the referral a user registered with
+type ReferralOutput struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
 }
 
 // UserUpdateInput represents updating profile request body format
 type UserUpdateInput struct {
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	Email     string `json:"email"`
+	FirstName string `json:"firstName" binding:"max=100"`
+	LastName  string `json:"lastName" binding:"max=100"`
+	Email     string `json:"email" binding:"required,email,max=255"`
+}
+
+// UserPatchInput represents the fields a PATCH sets, absent ones are kept
+type UserPatchInput struct {
+	FirstName *string `json:"firstName" binding:"omitempty,max=100"`
+	LastName  *string `json:"lastName" binding:"omitempty,max=100"`
+	Email     *string `json:"email" binding:"omitempty,email,max=255"`
+	// Birthday is cleared by an empty string
+	Birthday *string `json:"birthday" binding:"omitempty,date"`
+	// Phone and Locale are cleared by an empty string too
+	Phone  *string `json:"phone" binding:"omitempty,phone"`
+	Locale *string `json:"locale" binding:"omitempty,locale"`
+}
+
+func (in *UserInput) normalize() {
+	in.Email = normalizeEmail(in.Email)
+	in.FirstName = strings.TrimSpace(in.FirstName)
+	in.LastName = strings.TrimSpace(in.LastName)
+	in.ReferralCode = normalizeCode(in.ReferralCode)
+	in.Birthday = strings.TrimSpace(in.Birthday)
+	in.Phone = normalizePhone(in.Phone)
+	in.Locale = notification.NormalizeLocale(in.Locale)
+}
+
+func (in *UserUpdateInput) normalize() {
+	in.Email = normalizeEmail(in.Email)
+	in.FirstName = strings.TrimSpace(in.FirstName)
+	in.LastName = strings.TrimSpace(in.LastName)
+}
+
+func (in *UserPatchInput) normalize() {
+	if in.Email != nil {
+		*in.Email = normalizeEmail(*in.Email)
+	}
+	if in.FirstName != nil {
+		*in.FirstName = strings.TrimSpace(*in.FirstName)
+	}
+	if in.LastName != nil {
+		*in.LastName = strings.TrimSpace(*in.LastName)
+	}
+	if in.Birthday != nil {
+		*in.Birthday = strings.TrimSpace(*in.Birthday)
+	}
+	if in.Phone != nil {
+		*in.Phone = normalizePhone(*in.Phone)
+	}
+	if in.Locale != nil {
+		*in.Locale = notification.NormalizeLocale(*in.Locale)
+	}
 }
 
 // UserController interface
@@ -43,17 +115,28 @@ type UserController interface {
 	GetByEmail(*gin.Context)
 	ListUsers(*gin.Context)
 	Update(*gin.Context)
+	List(*gin.Context)
+	Post(*gin.Context)
+	Patch(*gin.Context)
+	Delete(*gin.Context)
+	Restore(*gin.Context)
 }
 
 type userController struct {
-	us userservice.UserService
+	us        userservice.UserService
+	lifecycle lifecycleservice.LifecycleService
+	referrals referralservice.ReferralService
 }
 
 // NewUserController instantiates User Controller
 func NewUserController(
-	us userservice.UserService) UserController {
+	us userservice.UserService,
+	lifecycle lifecycleservice.LifecycleService,
+	referrals referralservice.ReferralService) UserController {
 	return &userController{
-		us: us,
+		us:        us,
+		lifecycle: lifecycle,
+		referrals: referrals,
 	}
 }
 
@@ -62,35 +145,47 @@ func NewUserController(
 // @Param email body string true "Email"
 // @Param firstName body string true "FirstName"
 // @Param lastName body string true "LastName"
+// @Param birthday body string false "Birthday as YYYY-MM-DD"
+// @Param phone body string false "Phone number as +4915112345678, vouchers are sent to it by SMS"
+// @Param locale body string false "Language vouchers are sent in, e.g. de or de-CH"
+// @Param referral_code body string false "Referral code of the user who referred this one"
 // @Success 200 {object} Response
 // @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 422 {object} Response
 // @Failure 500 {object} Response
+// @Deprecated
 // @Router /api/register [post]
 func (ctl *userController) Register(c *gin.Context) {
 	// Read user input
 	var userInput UserInput
-	if err := c.ShouldBindJSON(&userInput); err != nil {
-		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+	if !bindJSON(c, &userInput) {
 		return
 	}
 	u := ctl.inputToUser(userInput)
+	code, ok := ctl.referralCode(c, userInput.ReferralCode)
+	if !ok {
+		return
+	}
 
 	// Create user
-	if err := ctl.us.Create(&u); err != nil {
+	if err := ctl.us.Create(c.Request.Context(), &u); err != nil {
 		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
 		return
 	}
 	userOutput := ctl.mapToUserOutput(&u)
+	userOutput.Referral = ctl.refer(c, &u, code)
 	HTTPRes(c, http.StatusOK, "ok", userOutput)
 }
 
-// @Summary Get user info of given id
+// @Summary Get user info of given id, its version is in the ETag header
 // @Produce  json
 // @Param id path int true "ID"
 // @Success 200 {object} Response
 // @Failure 400 {object} Response
+// @Failure 404 {object} Response
 // @Failure 500 {object} Response
-// @Router /api/user/{id} [get]
+// @Router /api/v1/users/{id} [get]
 func (ctl *userController) GetByID(c *gin.Context) {
 	id, err := ctl.getUserID(c.Param(("id")))
 	if err != nil {
@@ -98,7 +193,7 @@ func (ctl *userController) GetByID(c *gin.Context) {
 		return
 	}
 
-	user, err := ctl.us.GetByID(id)
+	user, err := ctl.us.GetByID(c.Request.Context(), id)
 	if err != nil {
 		es := err.Error()
 		if strings.Contains(es, "not found") {
@@ -109,6 +204,7 @@ func (ctl *userController) GetByID(c *gin.Context) {
 		return
 	}
 	userOutput := ctl.mapToUserOutput(user)
+	c.Header("ETag", ETag(user.Version))
 	HTTPRes(c, http.StatusOK, "ok", userOutput)
 }
 
@@ -118,10 +214,11 @@ func (ctl *userController) GetByID(c *gin.Context) {
 // @Success 200 {object} Response
 // @Failure 400 {object} Response
 // @Failure 500 {object} Response
+// @Deprecated
 // @Router /api/user/{email} [get]
 func (ctl *userController) GetByEmail(c *gin.Context) {
-	email := c.Param(("email"))
-	user, err := ctl.us.GetByEmail(email)
+	email := normalizeEmail(c.Param("email"))
+	user, err := ctl.us.GetByEmail(c.Request.Context(), email)
 	if err != nil {
 		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
 		return
@@ -135,10 +232,11 @@ func (ctl *userController) GetByEmail(c *gin.Context) {
 // @Success 200 {object} Response
 // @Failure 400 {object} Response
 // @Failure 500 {object} Response
+// @Deprecated
 // @Router /api/list_users [get]
 func (ctl *userController) ListUsers(c *gin.Context) {
 
-	users, err := ctl.us.ListAll()
+	users, err := ctl.us.ListAll(c.Request.Context())
 	if err != nil {
 		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
 		return
@@ -146,16 +244,8 @@ func (ctl *userController) ListUsers(c *gin.Context) {
 	HTTPRes(c, http.StatusOK, "ok", users)
 }
 
-// @Summary Update account info
-// @Produce  json
-// @Param user_id body string true "ID"
-// @Param email body string true "Email"
-// @Param firstName body string false "First Name"
-// @Param lastName body string false "Last Name"
-// @Success 200 {object} Response
-// @Failure 400 {object} Response
-// @Failure 500 {object} Response
-// @Router /api/user/update [put]
+// Update updates the user set as user_id on the context. It is not routed,
+// the API updates users with PATCH /api/v1/users/{id}.
 func (ctl *userController) Update(c *gin.Context) {
 	// Get user id from context
 	id, exists := c.Get("user_id")
@@ -165,7 +255,7 @@ func (ctl *userController) Update(c *gin.Context) {
 	}
 
 	// Retrieve user given id
-	user, err := ctl.us.GetByID(id.(uint))
+	user, err := ctl.us.GetByID(c.Request.Context(), id.(uint))
 	if err != nil {
 		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
 		return
@@ -173,8 +263,7 @@ func (ctl *userController) Update(c *gin.Context) {
 
 	// Read user input
 	var userInput UserUpdateInput
-	if err := c.ShouldBindJSON(&userInput); err != nil {
-		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+	if !bindJSON(c, &userInput) {
 		return
 	}
 
@@ -188,7 +277,7 @@ func (ctl *userController) Update(c *gin.Context) {
 	user.FirstName = userInput.FirstName
 	user.LastName = userInput.LastName
 	user.Email = userInput.Email
-	if err := ctl.us.Update(user); err != nil {
+	if err := ctl.us.Update(c.Request.Context(), user); err != nil {
 		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
 		return
 	}
@@ -198,6 +287,204 @@ func (ctl *userController) Update(c *gin.Context) {
 	HTTPRes(c, http.StatusOK, "ok", userOutput)
 }
 
+// @Summary List users by ID, the Link header points to the next page
+// @Produce  json
+// @Param email query string false "Only list the user with this email"
+// @Param after query int false "List users after this ID"
+// @Param limit query int false "Page size, 20 by default and 100 at most"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/users [get]
+func (ctl *userController) List(c *gin.Context) {
+	if email := normalizeEmail(c.Query("email")); email != "" {
+		out := []*UserOutput{}
+		user, err := ctl.us.GetByEmail(c.Request.Context(), email)
+		switch {
+		case err == nil:
+			out = append(out, ctl.mapToUserOutput(user))
+		case errStatus(err) != http.StatusNotFound:
+			HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
+			return
+		}
+		HTTPRes(c, http.StatusOK, "ok", out)
+		return
+	}
+
+	after, limit, err := pageParams(c)
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	// one more than asked tells whether there is a next page
+	users, err := ctl.us.ListPage(c.Request.Context(), after, limit+1)
+	if err != nil {
+		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	if len(users) > limit {
+		users = users[:limit]
+		setNextLink(c, users[limit-1].ID, limit)
+	}
+
+	out := make([]*UserOutput, 0, len(users))
+	for _, u := range users {
+		out = append(out, ctl.mapToUserOutput(u))
+	}
+	HTTPRes(c, http.StatusOK, "ok", out)
+}
+
+// @Summary Register new user
+// @Produce  json
+// @Param email body string true "Email"
+// @Param firstName body string true "FirstName"
+// @Param lastName body string true "LastName"
+// @Param birthday body string false "Birthday as YYYY-MM-DD"
+// @Param phone body string false "Phone number as +4915112345678, vouchers are sent to it by SMS"
+// @Param locale body string false "Language vouchers are sent in, e.g. de or de-CH"
+// @Param referral_code body string false "Referral code of the user who referred this one"
+// @Success 201 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 422 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/users [post]
+func (ctl *userController) Post(c *gin.Context) {
+	var userInput UserInput
+	if !bindJSON(c, &userInput) {
+		return
+	}
+	u := ctl.inputToUser(userInput)
+	code, ok := ctl.referralCode(c, userInput.ReferralCode)
+	if !ok {
+		return
+	}
+
+	if err := ctl.us.Create(c.Request.Context(), &u); err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	out := ctl.mapToUserOutput(&u)
+	out.Referral = ctl.refer(c, &u, code)
+	c.Header("Location", fmt.Sprintf("/api/v1/users/%d", u.ID))
+	c.Header("ETag", ETag(u.Version))
+	HTTPRes(c, http.StatusCreated, "ok", out)
+}
+
+// @Summary Update the given fields of a user
+// @Produce  json
+// @Param id path int true "ID"
+// @Param If-Match header string true "ETag of the user as last read"
+// @Param email body string false "Email"
+// @Param firstName body string false "First Name"
+// @Param lastName body string false "Last Name"
+// @Param birthday body string false "Birthday as YYYY-MM-DD, empty clears it"
+// @Param phone body string false "Phone number as +4915112345678, empty clears it"
+// @Param locale body string false "Language vouchers are sent in, empty clears it"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 422 {object} Response
+// @Failure 404 {object} Response
+// @Failure 412 {object} Response
+// @Failure 428 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/users/{id} [patch]
+func (ctl *userController) Patch(c *gin.Context) {
+	id, err := ctl.getUserID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	user, err := ctl.us.GetByID(c.Request.Context(), id)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	version, ok := ifMatch(c, user.Version)
+	if !ok {
+		return
+	}
+
+	var userInput UserPatchInput
+	if !bindJSON(c, &userInput) {
+		return
+	}
+	if userInput.FirstName != nil {
+		user.FirstName = *userInput.FirstName
+	}
+	if userInput.LastName != nil {
+		user.LastName = *userInput.LastName
+	}
+	if userInput.Email != nil {
+		user.Email = *userInput.Email
+	}
+	if userInput.Birthday != nil {
+		user.Birthday = *userInput.Birthday
+	}
+	if userInput.Phone != nil {
+		user.Phone = *userInput.Phone
+	}
+	if userInput.Locale != nil {
+		user.Locale = *userInput.Locale
+	}
+
+	// a concurrent update between the read and here still fails with 412
+	if err := ctl.us.UpdateIfVersion(c.Request.Context(), user, version); err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	c.Header("ETag", ETag(user.Version))
+	HTTPRes(c, http.StatusOK, "ok", ctl.mapToUserOutput(user))
+}
+
+// @Summary Delete a user, anonymizing it and revoking its unused vouchers
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 204
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/users/{id} [delete]
+func (ctl *userController) Delete(c *gin.Context) {
+	id, err := ctl.getUserID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if err := ctl.lifecycle.DeleteUser(c.Request.Context(), id); err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Restore a deleted user and the vouchers revoked with it, still anonymized
+// @Produce  json
+// @Param id path int true "ID"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/users/{id}/restore [post]
+func (ctl *userController) Restore(c *gin.Context) {
+	id, err := ctl.getUserID(c.Param("id"))
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	user, err := ctl.lifecycle.RestoreUser(c.Request.Context(), id)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	c.Header("ETag", ETag(user.Version))
+	HTTPRes(c, http.StatusOK, "ok", ctl.mapToUserOutput(user))
+}
+
 /*******************************/
 //       PRIVATE METHODS
 /*******************************/
@@ -215,6 +502,9 @@ func (ctl *userController) inputToUser(input UserInput) user.User {
 		FirstName: input.FirstName,
 		LastName:  input.LastName,
 		Email:     input.Email,
+		Birthday:  input.Birthday,
+		Phone:     input.Phone,
+		Locale:    input.Locale,
 	}
 }
 
@@ -224,8 +514,59 @@ func (ctl *userController) mapToUserOutput(u *user.User) *UserOutput {
 		Email:     u.Email,
 		FirstName: u.FirstName,
 		LastName:  u.LastName,
-		Vouchers:  u.Voucher,
+		Vouchers:  ctl.mapToVoucherOutputs(u.Voucher),
+		Birthday:  u.Birthday,
+		Phone:     u.Phone,
+		Locale:    u.Locale,
+	}
+}
+
+// mapToVoucherOutputs keeps vouchers nil when there are none
+func (ctl *userController) mapToVoucherOutputs(vs []voucher.Voucher) []VoucherOutput {
+	var out []VoucherOutput
+	for _, v := range vs {
+		out = append(out, VoucherOutput{
+			ID:         v.ID,
+			Code:       v.Code,
+			IsUsed:     v.IsUsed,
+			UsedAt:     v.UsedAt,
+			ExpireTime: v.ExpireTime,
+			UserID:     v.UserID,
+			OfferID:    v.OfferID,
+		})
+	}
+	return out
+}
+
+// referralCode looks up the code a user registers with, answering when it
+// does not exist. It is nil without one.
+func (ctl *userController) referralCode(c *gin.Context, code string) (*referral.Code, bool) {
+	if code == "" {
+		return nil, true
+	}
+	rc, err := ctl.referrals.Lookup(c.Request.Context(), code)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return nil, false
+	}
+	return rc, true
+}
+
+// refer records that u registered with code. The user exists by then, so
+// a failure is logged rather than failing the registration.
+func (ctl *userController) refer(c *gin.Context, u *user.User, code *referral.Code) *ReferralOutput {
+	if code == nil {
+		return nil
+	}
+	r, err := ctl.referrals.Refer(c.Request.Context(), u, code)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("recording referral failed", logger.Fields{
+			"user_id": u.ID,
+			"error":   err,
+		})
+		return nil
 	}
+	return &ReferralOutput{Status: r.Status, Reason: r.Reason}
 }
 
 // Issue token and return user
@@ -1,7 +1,9 @@
 package controllers
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"github.com/deepinbytes/go_voucher/domain/user"
 	"github.com/jinzhu/gorm"
@@ -23,7 +25,7 @@ var david = &user.User{
 	LastName:  "",
 }
 
-func (us *userSvc) GetByID(id uint) (*user.User, error) {
+func (us *userSvc) GetByID(ctx context.Context, id uint) (*user.User, error) {
 	if id >= uint(100) {
 		return nil, errors.New("Ugh")
 	}
@@ -33,10 +35,12 @@ func (us *userSvc) GetByID(id uint) (*user.User, error) {
 	if id >= uint(10) {
 		return nil, errors.New("Record not found")
 	}
-	return alice, nil
+	// a copy, so handlers changing it leave the fixture as is
+	u := *alice
+	return &u, nil
 }
 
-func (us *userSvc) GetByEmail(email string) (*user.User, error) {
+func (us *userSvc) GetByEmail(ctx context.Context, email string) (*user.User, error) {
 	if email == "bob@cc.cc" {
 		return nil, errors.New("Nop")
 	}
@@ -46,22 +50,64 @@ func (us *userSvc) GetByEmail(email string) (*user.User, error) {
 	return alice, nil
 }
 
-func (us *userSvc) Create(user *user.User) error {
+func (us *userSvc) Create(ctx context.Context, user *user.User) error {
 	if user.Email == "bob@cc.cc" {
 		return errors.New("Nop")
 	}
 	return nil
 }
 
-func (us *userSvc) Update(user *user.User) error {
+func (us *userSvc) Update(ctx context.Context, user *user.User) error {
 	if user.Email == "bob@cc.cc" {
 		return errors.New("Nop")
 	}
 	return nil
 }
 
-func (us *userSvc) ListAll() ([]*user.User, error) {
+func (us *userSvc) ListAll(ctx context.Context) ([]*user.User, error) {
 	var x []*user.User
 	return x, nil
 
 }
+
+func (us *userSvc) GetByIDs(ctx context.Context, ids []uint) ([]*user.User, error) {
+	return []*user.User{}, nil
+}
+
+func (us *userSvc) ListPage(ctx context.Context, after uint, limit int) ([]*user.User, error) {
+	return []*user.User{}, nil
+}
+
+func (us *userSvc) UpdateIfVersion(ctx context.Context, user *user.User, version uint) error {
+	if user.Email == "bob@cc.cc" {
+		return errors.New("Nop")
+	}
+	user.Version = version + 1
+	return nil
+}
+
+func (us *userSvc) Delete(ctx context.Context, id uint, at time.Time) error {
+	if id >= uint(10) {
+		return errors.New("record not found")
+	}
+	return nil
+}
+
+func (us *userSvc) GetDeleted(ctx context.Context, id uint) (*user.User, error) {
+	if id >= uint(10) {
+		return nil, errors.New("record not found")
+	}
+	u := *alice
+	deletedAt := time.Now()
+	u.DeletedAt = &deletedAt
+	u.FirstName, u.LastName, u.Email = "", "", user.AnonymousEmail(id)
+	return &u, nil
+}
+
+func (us *userSvc) Restore(ctx context.Context, id uint) error {
+	return nil
+}
+
+func (us *userSvc) Purge(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
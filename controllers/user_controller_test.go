@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/services/lifecycleservice"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -35,11 +36,24 @@ func performRequest(r http.Handler, method, path string) *httptest.ResponseRecor
 	return w
 }
 
+func performJSONRequest(r http.Handler, method, path string, body interface{}, header map[string]string) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
 func TestUserController(t *testing.T) {
 
 	// Setup router + user controller
 	us := &userSvc{}
-	userCtl := NewUserController(us)
+	lc := lifecycleservice.NewLifecycleService(&offerSvc{}, us, &voucherSvc{})
+	userCtl := NewUserController(us, lc, &referralSvc{})
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
 	router.GET("/users/:id", userCtl.GetByID)
@@ -357,3 +371,118 @@ func TestUserController(t *testing.T) {
 	})
 
 }
+
+func TestUserControllerV1(t *testing.T) {
+
+	// Setup router + user controller
+	us := &userSvc{}
+	rs := &referralSvc{}
+	lc := lifecycleservice.NewLifecycleService(&offerSvc{}, us, &voucherSvc{})
+	userCtl := NewUserController(us, lc, rs)
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/api/v1/users", userCtl.List)
+	router.POST("/api/v1/users", userCtl.Post)
+	router.GET("/api/v1/users/:id", userCtl.GetByID)
+	router.PATCH("/api/v1/users/:id", userCtl.Patch)
+	router.DELETE("/api/v1/users/:id", userCtl.Delete)
+	router.POST("/api/v1/users/:id/restore", userCtl.Restore)
+
+	t.Run("List by email", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/users?email=david@cc.cc")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		resBody := Response{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		users := resBody.Data.([]interface{})
+		assert.Len(t, users, 1)
+		assert.Equal(t, "david@cc.cc", users[0].(map[string]interface{})["email"])
+	})
+
+	t.Run("Post", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/users",
+			map[string]interface{}{"email": "carol@cc.cc"}, nil)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "/api/v1/users/0", w.Header().Get("Location"))
+	})
+
+	t.Run("Post with a referral code", func(t *testing.T) {
+		rs.referred = nil
+		for _, tc := range []struct {
+			email, code string
+			status      int
+			referral    *ReferralOutput
+		}{
+			{"carol@cc.cc", " refalice ", http.StatusCreated, &ReferralOutput{Status: "pending"}},
+			{"alice+1@cc.cc", "REFALICE", http.StatusCreated, &ReferralOutput{Status: "rejected", Reason: "self_referral"}},
+			{"carol@cc.cc", "UNKNOWN1", http.StatusNotFound, nil},
+		} {
+			w := performJSONRequest(router, "POST", "/api/v1/users",
+				map[string]interface{}{"email": tc.email, "referral_code": tc.code}, nil)
+
+			assert.Equal(t, tc.status, w.Code, tc.code)
+			resBody := struct {
+				Data UserOutput `json:"data"`
+			}{}
+			json.NewDecoder(w.Body).Decode(&resBody)
+			assert.Equal(t, tc.referral, resBody.Data.Referral, tc.code)
+		}
+		assert.Equal(t, []uint{alice.ID, alice.ID}, rs.referred)
+	})
+
+	t.Run("Patch", func(t *testing.T) {
+		etag := performRequest(router, "GET", "/api/v1/users/1").Header().Get("ETag")
+
+		t.Run("Success", func(t *testing.T) {
+			w := performJSONRequest(router, "PATCH", "/api/v1/users/1",
+				map[string]interface{}{"firstName": "Alice"}, map[string]string{"If-Match": etag})
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.NotEqual(t, etag, w.Header().Get("ETag"))
+		})
+
+		t.Run("Requires If-Match", func(t *testing.T) {
+			w := performJSONRequest(router, "PATCH", "/api/v1/users/1",
+				map[string]interface{}{"firstName": "Alice"}, nil)
+
+			assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+		})
+
+		t.Run("Rejects a stale ETag", func(t *testing.T) {
+			w := performJSONRequest(router, "PATCH", "/api/v1/users/1",
+				map[string]interface{}{"firstName": "Alice"}, map[string]string{"If-Match": `"41"`})
+
+			assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		})
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			w := performRequest(router, "DELETE", "/api/v1/users/1")
+
+			assert.Equal(t, http.StatusNoContent, w.Code)
+		})
+
+		t.Run("Not found", func(t *testing.T) {
+			w := performRequest(router, "DELETE", "/api/v1/users/10")
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			w := performRequest(router, "POST", "/api/v1/users/1/restore")
+
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+
+		t.Run("Not deleted", func(t *testing.T) {
+			w := performRequest(router, "POST", "/api/v1/users/10/restore")
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+	})
+}
@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// Codes of the field errors of a 422 response
+const (
+	CodeRequired      = "required"
+	CodeInvalidEmail  = "invalid_email"
+	CodeInvalidFormat = "invalid_format"
+	CodeOutOfRange    = "out_of_range"
+	CodeTooLong       = "too_long"
+	CodeInvalid       = "invalid"
+)
+
+// voucherCode matches voucher codes once normalized, generated ones are 8
+// characters long
+var voucherCode = regexp.MustCompile(`^[A-Z0-9]{4,32}$`)
+
+// phoneNumber matches E.164 phone numbers once normalized
+var phoneNumber = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// locale matches language tags once normalized, e.g. "de" or "de-ch"
+var locale = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// FieldError is an invalid field of a request body, named after its JSON key
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// normalizer is implemented by inputs that clean up their fields, e.g. the
+// casing of emails, before they are validated
+type normalizer interface {
+	normalize()
+}
+
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		panic("controllers: gin validator is not go-playground/validator.v9")
+	}
+	// report fields by the key clients send them with
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	if err := v.RegisterValidation("currency", func(fl validator.FieldLevel) bool {
+		_, err := money.Lookup(fl.Field().String())
+		return err == nil
+	}); err != nil {
+		panic(err)
+	}
+	if err := v.RegisterValidation("vouchercode", func(fl validator.FieldLevel) bool {
+		return voucherCode.MatchString(fl.Field().String())
+	}); err != nil {
+		panic(err)
+	}
+	// an empty date is no date, so PATCH can clear one
+	if err := v.RegisterValidation("date", func(fl validator.FieldLevel) bool {
+		s := fl.Field().String()
+		_, err := time.Parse(user.DateLayout, s)
+		return s == "" || err == nil
+	}); err != nil {
+		panic(err)
+	}
+	// empty phones and locales clear them on PATCH, as dates do
+	if err := v.RegisterValidation("phone", func(fl validator.FieldLevel) bool {
+		s := fl.Field().String()
+		return s == "" || phoneNumber.MatchString(s)
+	}); err != nil {
+		panic(err)
+	}
+	if err := v.RegisterValidation("locale", func(fl validator.FieldLevel) bool {
+		s := fl.Field().String()
+		return s == "" || (len(s) <= 16 && locale.MatchString(s))
+	}); err != nil {
+		panic(err)
+	}
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// bindJSON decodes the request body into obj, normalizes and validates it.
+// It responds 400 when the body is not JSON and 422 with the list of
+// invalid fields when a rule fails.
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	if c.Request == nil || c.Request.Body == nil {
+		HTTPRes(c, http.StatusBadRequest, "invalid request", nil)
+		return false
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(obj); err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return false
+	}
+	if n, ok := obj.(normalizer); ok {
+		n.normalize()
+	}
+
+	err := binding.Validator.ValidateStruct(obj)
+	if err == nil {
+		return true
+	}
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return false
+	}
+	fields := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		fields = append(fields, fieldError(fe))
+	}
+	HTTPRes(c, http.StatusUnprocessableEntity, "Validation failed", fields)
+	return false
+}
+
+func fieldError(fe validator.FieldError) FieldError {
+	f := FieldError{Field: fe.Field()}
+	switch fe.Tag() {
+	case "required", "required_with", "required_without":
+		f.Code = CodeRequired
+		f.Message = fmt.Sprintf("%s is required", f.Field)
+	case "email":
+		f.Code = CodeInvalidEmail
+		f.Message = fmt.Sprintf("%s must be a valid email address", f.Field)
+	case "currency":
+		f.Code = CodeInvalidFormat
+		f.Message = fmt.Sprintf("%s must be one of %s", f.Field, strings.Join(money.Codes(), ", "))
+	case "vouchercode":
+		f.Code = CodeInvalidFormat
+		f.Message = fmt.Sprintf("%s must be 4 to 32 letters or digits", f.Field)
+	case "date":
+		f.Code = CodeInvalidFormat
+		f.Message = fmt.Sprintf("%s must be a date as YYYY-MM-DD", f.Field)
+	case "phone":
+		f.Code = CodeInvalidFormat
+		f.Message = fmt.Sprintf("%s must be a phone number with country code, e.g. +4915112345678", f.Field)
+	case "locale":
+		f.Code = CodeInvalidFormat
+		f.Message = fmt.Sprintf("%s must be a language tag, e.g. de or de-CH", f.Field)
+	case "min":
+		if fe.Kind() == reflect.String {
+			f.Code = CodeRequired
+			f.Message = fmt.Sprintf("%s must not be empty", f.Field)
+			break
+		}
+		f.Code = CodeOutOfRange
+		f.Message = fmt.Sprintf("%s must be at least %s", f.Field, fe.Param())
+	case "max":
+		if fe.Kind() == reflect.String {
+			f.Code = CodeTooLong
+			f.Message = fmt.Sprintf("%s must be at most %s characters", f.Field, fe.Param())
+			break
+		}
+		f.Code = CodeOutOfRange
+		f.Message = fmt.Sprintf("%s must be at most %s", f.Field, fe.Param())
+	default:
+		f.Code = CodeInvalid
+		f.Message = fmt.Sprintf("%s is invalid", f.Field)
+	}
+	return f
+}
+
+// normalizeEmail trims and lower-cases an email, emails are compared that way
+func normalizeEmail(email string) string {
+	return user.NormalizeEmail(email)
+}
+
+// normalizePhone drops the spaces, dashes, dots and parentheses phone
+// numbers are often written with
+func normalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(" -.()", r) {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(phone))
+}
+
+// normalizeCurrency trims and upper-cases an ISO currency code
+func normalizeCurrency(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// normalizeAmounts normalizes the currencies keying amounts
+func normalizeAmounts(amounts map[string]int64) map[string]int64 {
+	if amounts == nil {
+		return nil
+	}
+	out := make(map[string]int64, len(amounts))
+	for code, amount := range amounts {
+		out[normalizeCurrency(code)] = amount
+	}
+	return out
+}
+
+// normalizeCode trims and upper-cases a voucher code, codes are generated
+// that way
+func normalizeCode(code string) string {
+	return voucher.NormalizeCode(code)
+}
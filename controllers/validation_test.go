@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/deepinbytes/go_voucher/services/lifecycleservice"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Output of a 422 response
+type outputInvalid struct {
+	Code int          `json:"code"`
+	Msg  string       `json:"msg"`
+	Data []FieldError `json:"data"`
+}
+
+func TestValidation(t *testing.T) {
+
+	// Setup router + controllers
+	os := &offerSvc{}
+	us := &userSvc{}
+	vs := &voucherSvc{}
+	lc := lifecycleservice.NewLifecycleService(os, us, vs)
+	offerCtl := NewOfferController(os, us, vs, lc)
+	userCtl := NewUserController(us, lc, &referralSvc{})
+	voucherCtl := NewVoucherController(vs, us, os)
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.POST("/api/v1/offers", offerCtl.Post)
+	router.PATCH("/api/v1/offers/:id", offerCtl.Patch)
+	router.POST("/api/v1/users", userCtl.Post)
+	router.PATCH("/api/v1/users/:id", userCtl.Patch)
+	router.POST("/api/voucher/redeem", voucherCtl.Redeem)
+	router.POST("/api/v1/vouchers/:code/redeem", voucherCtl.RedeemCode)
+
+	t.Run("Lists every invalid field", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/offers",
+			map[string]interface{}{"name": "  ", "discount_percentage": 500}, nil)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		resBody := outputInvalid{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, outputInvalid{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "Validation failed",
+			Data: []FieldError{
+				{Field: "name", Code: CodeRequired, Message: "name is required"},
+				{Field: "discount_percentage", Code: CodeOutOfRange, Message: "discount_percentage must be at most 100"},
+			},
+		}, resBody)
+	})
+
+	t.Run("Checks fixed discounts", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/offers",
+			map[string]interface{}{"name": "Fixed"}, nil)
+
+		resBody := outputInvalid{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, []FieldError{
+			{Field: "discount_percentage", Code: CodeRequired, Message: "discount_percentage is required"},
+		}, resBody.Data)
+
+		w = performJSONRequest(router, "POST", "/api/v1/offers", map[string]interface{}{
+			"name": "Fixed", "discount_amount": 500, "discount_amounts": map[string]int64{"jpy": 100, "gbp": 0},
+		}, nil)
+
+		resBody = outputInvalid{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.ElementsMatch(t, []FieldError{
+			{Field: "discount_currency", Code: CodeRequired, Message: "discount_currency is required"},
+			{Field: "discount_amounts[JPY]", Code: CodeInvalidFormat, Message: "discount_amounts[JPY] must be one of EUR, GBP, USD"},
+			{Field: "discount_amounts[GBP]", Code: CodeOutOfRange, Message: "discount_amounts[GBP] must be at least 1"},
+		}, resBody.Data)
+	})
+
+	t.Run("Rejects invalid emails", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/users",
+			map[string]interface{}{"email": "alice.cc.cc"}, nil)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		resBody := outputInvalid{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, []FieldError{
+			{Field: "email", Code: CodeInvalidEmail, Message: "email must be a valid email address"},
+		}, resBody.Data)
+	})
+
+	t.Run("Lower-cases emails", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/users",
+			map[string]interface{}{"email": " Carol@CC.cc "}, nil)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		resBody := Response{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, "carol@cc.cc", resBody.Data.(map[string]interface{})["email"])
+	})
+
+	t.Run("Rejects badly formatted dates", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/users",
+			map[string]interface{}{"email": "carol@cc.cc", "birthday": "1990-02-30"}, nil)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		resBody := outputInvalid{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, []FieldError{
+			{Field: "birthday", Code: CodeInvalidFormat, Message: "birthday must be a date as YYYY-MM-DD"},
+		}, resBody.Data)
+
+		w = performJSONRequest(router, "POST", "/api/v1/users",
+			map[string]interface{}{"email": "carol@cc.cc", "birthday": " 1990-02-28 "}, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		w = performJSONRequest(router, "PATCH", "/api/v1/users/1",
+			map[string]interface{}{"birthday": ""}, map[string]string{"If-Match": "*"})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Rejects badly formatted phones and locales", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/users",
+			map[string]interface{}{"email": "carol@cc.cc", "phone": "0151 1234567", "locale": "german"}, nil)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		resBody := outputInvalid{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, []FieldError{
+			{Field: "phone", Code: CodeInvalidFormat, Message: "phone must be a phone number with country code, e.g. +4915112345678"},
+			{Field: "locale", Code: CodeInvalidFormat, Message: "locale must be a language tag, e.g. de or de-CH"},
+		}, resBody.Data)
+
+		w = performJSONRequest(router, "POST", "/api/v1/users",
+			map[string]interface{}{"email": "carol@cc.cc", "phone": "+49 (151) 1234-5678", "locale": "de_CH"}, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		out := struct {
+			Data UserOutput `json:"data"`
+		}{}
+		json.NewDecoder(w.Body).Decode(&out)
+		assert.Equal(t, "+4915112345678", out.Data.Phone)
+		assert.Equal(t, "de-ch", out.Data.Locale)
+		w = performJSONRequest(router, "PATCH", "/api/v1/users/1",
+			map[string]interface{}{"phone": "", "locale": ""}, map[string]string{"If-Match": "*"})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Rejects badly formatted codes", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/voucher/redeem",
+			map[string]interface{}{"code": "TE$T1", "email": "alice@cc.cc"}, nil)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		resBody := outputInvalid{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, []FieldError{
+			{Field: "code", Code: CodeInvalidFormat, Message: "code must be 4 to 32 letters or digits"},
+		}, resBody.Data)
+	})
+
+	t.Run("Upper-cases codes", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/vouchers/test2/redeem",
+			map[string]interface{}{"email": "Alice@cc.cc"}, nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		resBody := Response{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, "TEST2", resBody.Data.(map[string]interface{})["Code"])
+	})
+
+	t.Run("Patch validates the given fields only", func(t *testing.T) {
+		w := performJSONRequest(router, "PATCH", "/api/v1/users/1",
+			map[string]interface{}{"firstName": "Alice"}, map[string]string{"If-Match": "*"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = performJSONRequest(router, "PATCH", "/api/v1/offers/1",
+			map[string]interface{}{"name": ""}, map[string]string{"If-Match": "*"})
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		resBody := outputInvalid{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, []FieldError{
+			{Field: "name", Code: CodeRequired, Message: "name must not be empty"},
+		}, resBody.Data)
+	})
+
+	t.Run("Malformed JSON is a bad request", func(t *testing.T) {
+		w := performJSONRequest(router, "POST", "/api/v1/offers", "not an offer", nil)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
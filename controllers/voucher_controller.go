@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/deepinbytes/go_voucher/common/ratelimit"
 	"github.com/deepinbytes/go_voucher/domain/voucher"
 	"github.com/deepinbytes/go_voucher/services/voucherservice"
 
@@ -17,9 +18,9 @@ import (
 
 // UserInput represents login/register request body format
 type VoucherInput struct {
-	Code    string `json:"code"`
-	UserID  uint   `json:"user_id"`
-	OfferID uint   `json:"offer_id"`
+	Code    string `json:"code" binding:"required,vouchercode"`
+	UserID  uint   `json:"user_id" binding:"required"`
+	OfferID uint   `json:"offer_id" binding:"required"`
 }
 
 // VoucherOutput represents returning user
@@ -35,8 +36,33 @@ type VoucherOutput struct {
 }
 
 type RedeemVoucherInput struct {
-	Code  string `json:"code"`
-	Email string `json:"email"`
+	Code  string `json:"code" binding:"required,vouchercode"`
+	Email string `json:"email" binding:"required,email"`
+}
+
+// IssueVoucherInput represents issuing a voucher request body format
+type IssueVoucherInput struct {
+	OfferID    uint `json:"offer_id" binding:"required"`
+	UserID     uint `json:"user_id" binding:"required"`
+	ExpiryDays uint `json:"expiry_days" binding:"required,min=1,max=365"`
+}
+
+// RedeemCodeInput represents redeeming a voucher whose code is in the path
+type RedeemCodeInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+func (in *VoucherInput) normalize() {
+	in.Code = normalizeCode(in.Code)
+}
+
+func (in *RedeemVoucherInput) normalize() {
+	in.Code = normalizeCode(in.Code)
+	in.Email = normalizeEmail(in.Email)
+}
+
+func (in *RedeemCodeInput) normalize() {
+	in.Email = normalizeEmail(in.Email)
 }
 
 // UserController interface
@@ -44,7 +70,9 @@ type VoucherController interface {
 	Create(*gin.Context)
 	Redeem(*gin.Context)
 	GetByID(*gin.Context)
-	Update(*gin.Context)
+	GetByCode(*gin.Context)
+	Post(*gin.Context)
+	RedeemCode(*gin.Context)
 }
 
 type voucherController struct {
@@ -72,19 +100,20 @@ func NewVoucherController(
 // @Param offer_id body string true "OfferID"
 // @Success 200 {object} Response
 // @Failure 400 {object} Response
+// @Failure 422 {object} Response
 // @Failure 500 {object} Response
+// @Deprecated
 // @Router /api/voucher/create [post]
 func (ctl *voucherController) Create(c *gin.Context) {
 	// Read user input
 	var voucherGenerateInput VoucherInput
-	if err := c.ShouldBindJSON(&voucherGenerateInput); err != nil {
-		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+	if !bindJSON(c, &voucherGenerateInput) {
 		return
 	}
 	u := ctl.inputToVoucher(voucherGenerateInput)
 
 	// Create voucher
-	if err := ctl.voucherSvc.Create(&u); err != nil {
+	if err := ctl.voucherSvc.Create(c.Request.Context(), &u); err != nil {
 		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
 		return
 	}
@@ -97,6 +126,7 @@ func (ctl *voucherController) Create(c *gin.Context) {
 // @Success 200 {object} Response
 // @Failure 400 {object} Response
 // @Failure 500 {object} Response
+// @Deprecated
 // @Router /api/voucher/{id} [get]
 func (ctl *voucherController) GetByID(c *gin.Context) {
 	id, err := ctl.getVoucherID(c.Param(("id")))
@@ -105,7 +135,7 @@ func (ctl *voucherController) GetByID(c *gin.Context) {
 		return
 	}
 
-	voucher, err := ctl.voucherSvc.GetByID(id)
+	voucher, err := ctl.voucherSvc.GetByID(c.Request.Context(), id)
 	if err != nil {
 		es := err.Error()
 		if strings.Contains(es, "not found") {
@@ -125,113 +155,169 @@ func (ctl *voucherController) GetByID(c *gin.Context) {
 // @Param code body string false "Code"
 // @Success 200 {object} Response
 // @Failure 400 {object} Response
+// @Failure 422 {object} Response
 // @Failure 500 {object} Response
+// @Deprecated
 // @Router /api/voucher/redeem [post]
 func (ctl *voucherController) Redeem(c *gin.Context) {
 
 	var redeemVoucherInput RedeemVoucherInput
-	if err := c.ShouldBindJSON(&redeemVoucherInput); err != nil {
-		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+	if !bindJSON(c, &redeemVoucherInput) {
 		return
 	}
 
 	// Retrieve voucher given the code
-	voucher, err := ctl.voucherSvc.UseCode(redeemVoucherInput.Code)
+	voucher, err := ctl.voucherSvc.UseCode(c.Request.Context(), redeemVoucherInput.Code)
 	if err != nil {
+		ratelimit.RecordAttempt(c.Request.Context(), err, voucherservice.Guessed)
 		HTTPRes(c, http.StatusInternalServerError, err.Error(), "Invalid Voucher")
 		return
 	}
-	offer, err := ctl.offerSvc.GetByID(voucher.OfferID)
+	offer, err := ctl.offerSvc.GetByID(c.Request.Context(), voucher.OfferID)
 	if err != nil {
 		HTTPRes(c, http.StatusInternalServerError, err.Error(), "Offer Not Available Anymore")
 		return
 	}
 
 	// Retrieve user for given voucher
-	user, err := ctl.usrSvc.GetByID(voucher.UserID)
+	user, err := ctl.usrSvc.GetByID(c.Request.Context(), voucher.UserID)
 	if err != nil {
 		HTTPRes(c, http.StatusInternalServerError, err.Error(), "Invalid User")
 		return
 	}
-	if redeemVoucherInput.Email != user.Email {
-		HTTPRes(c, http.StatusInternalServerError, "", "Code not valid for this user")
+
+	// Redeem voucher
+	err = ctl.voucherSvc.Redeem(c.Request.Context(), voucher, user, redeemVoucherInput.Email)
+	ratelimit.RecordAttempt(c.Request.Context(), err, voucherservice.Guessed)
+	if err != nil {
+		switch err {
+		case voucherservice.ErrWrongUser:
+			HTTPRes(c, http.StatusInternalServerError, "", "Code not valid for this user")
+		case voucherservice.ErrUsed:
+			HTTPRes(c, http.StatusOK, "Used Voucher", "")
+		case voucherservice.ErrExpired:
+			HTTPRes(c, http.StatusOK, "Expired Voucher", "")
+		default:
+			HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
+		}
 		return
 	}
 
-	if voucher.IsUsed {
-		HTTPRes(c, http.StatusOK, "Used Voucher", "")
+	// Response
+	voucherOutput := ctl.mapToVoucherOutput(voucher)
+	voucherOutput.DiscountPercentage = offer.DiscountPercentage
+	HTTPRes(c, http.StatusOK, "ok", voucherOutput)
+}
+
+// @Summary Get voucher info of given code
+// @Produce  json
+// @Param code path string true "Code"
+// @Success 200 {object} Response
+// @Failure 404 {object} Response
+// @Failure 429 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/vouchers/{code} [get]
+func (ctl *voucherController) GetByCode(c *gin.Context) {
+	voucher, err := ctl.voucherSvc.UseCode(c.Request.Context(), normalizeCode(c.Param("code")))
+	if err != nil {
+		recordLookup(c, err)
+		HTTPRes(c, errStatus(err), err.Error(), nil)
 		return
 	}
+	HTTPRes(c, http.StatusOK, "ok", ctl.mapToVoucherOutput(voucher))
+}
 
-	if time.Now().After(voucher.ExpireTime) {
-		HTTPRes(c, http.StatusOK, "Expired Voucher", "")
+// @Summary Issue a voucher of an offer to a user
+// @Produce  json
+// @Param offer_id body int true "OfferID"
+// @Param user_id body int true "UserID"
+// @Param expiry_days body int true "Days until the voucher expires"
+// @Success 201 {object} Response
+// @Failure 400 {object} Response
+// @Failure 422 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/vouchers [post]
+func (ctl *voucherController) Post(c *gin.Context) {
+	var input IssueVoucherInput
+	if !bindJSON(c, &input) {
 		return
 	}
 
-	// Updates voucher record
-	voucher.UsedAt = time.Now()
-	voucher.IsUsed = true
+	offer, err := ctl.offerSvc.GetByID(c.Request.Context(), input.OfferID)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
+	user, err := ctl.usrSvc.GetByID(c.Request.Context(), input.UserID)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
+		return
+	}
 
-	if err := ctl.voucherSvc.Update(voucher); err != nil {
+	expireTime := time.Now().Add(24 * time.Hour * time.Duration(input.ExpiryDays))
+	voucher, err := ctl.voucherSvc.Issue(c.Request.Context(), offer.ID, user.ID, expireTime)
+	if err != nil {
 		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
 		return
 	}
-
-	// Response
 	voucherOutput := ctl.mapToVoucherOutput(voucher)
 	voucherOutput.DiscountPercentage = offer.DiscountPercentage
-	HTTPRes(c, http.StatusOK, "ok", voucherOutput)
+	c.Header("Location", "/api/v1/vouchers/"+voucher.Code)
+	HTTPRes(c, http.StatusCreated, "ok", voucherOutput)
 }
 
-// @Summary Update voucher info
+// @Summary Redeem the voucher of given code
 // @Produce  json
-// @Param voucher_id body string true "ID"
-// @Param code body string false "Code"
-// @Param user_id body string false "UserID"
-// @Param offer_id body string false "OfferID"
+// @Param code path string true "Code"
+// @Param email body string true "Email"
 // @Success 200 {object} Response
 // @Failure 400 {object} Response
+// @Failure 422 {object} Response
+// @Failure 403 {object} Response
+// @Failure 404 {object} Response
+// @Failure 409 {object} Response
 // @Failure 500 {object} Response
-// @Router /api/voucher/update [post]
-func (ctl *voucherController) Update(c *gin.Context) {
-	// Get voucher id from context
-	id, exists := c.Get("voucher_id")
-	if exists == false {
-		HTTPRes(c, http.StatusBadRequest, "Invalid Voucher ID", nil)
+// @Router /api/v1/vouchers/{code}/redeem [post]
+func (ctl *voucherController) RedeemCode(c *gin.Context) {
+	var input RedeemCodeInput
+	if !bindJSON(c, &input) {
 		return
 	}
 
-	// Retrieve voucher given id
-	voucher, err := ctl.voucherSvc.GetByID(id.(uint))
+	voucher, err := ctl.voucherSvc.UseCode(c.Request.Context(), normalizeCode(c.Param("code")))
 	if err != nil {
-		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
+		ratelimit.RecordAttempt(c.Request.Context(), err, voucherservice.Guessed)
+		HTTPRes(c, errStatus(err), err.Error(), nil)
 		return
 	}
-
-	// Read voucher input
-	var voucherInput VoucherInput
-	if err := c.ShouldBindJSON(&voucherInput); err != nil {
-		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+	offer, err := ctl.offerSvc.GetByID(c.Request.Context(), voucher.OfferID)
+	if err != nil {
+		HTTPRes(c, errStatus(err), "Offer not available anymore", nil)
 		return
 	}
-
-	// Check voucher
-	if voucher.ID != id {
-		HTTPRes(c, http.StatusUnauthorized, "Unauthorized", nil)
+	user, err := ctl.usrSvc.GetByID(c.Request.Context(), voucher.UserID)
+	if err != nil {
+		HTTPRes(c, errStatus(err), err.Error(), nil)
 		return
 	}
 
-	// Update voucher record
-	voucher.UsedAt = time.Now()
-	voucher.IsUsed = true
-
-	if err := ctl.voucherSvc.Update(voucher); err != nil {
-		HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
+	err = ctl.voucherSvc.Redeem(c.Request.Context(), voucher, user, input.Email)
+	ratelimit.RecordAttempt(c.Request.Context(), err, voucherservice.Guessed)
+	if err != nil {
+		switch err {
+		case voucherservice.ErrWrongUser:
+			HTTPRes(c, http.StatusForbidden, err.Error(), nil)
+		case voucherservice.ErrUsed, voucherservice.ErrExpired:
+			HTTPRes(c, http.StatusConflict, err.Error(), nil)
+		default:
+			HTTPRes(c, http.StatusInternalServerError, err.Error(), nil)
+		}
 		return
 	}
 
-	// Response
 	voucherOutput := ctl.mapToVoucherOutput(voucher)
+	voucherOutput.DiscountPercentage = offer.DiscountPercentage
 	HTTPRes(c, http.StatusOK, "ok", voucherOutput)
 }
 
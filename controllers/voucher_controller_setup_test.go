@@ -1,9 +1,14 @@
 package controllers
 
 import (
+	"context"
 	"errors"
+	"time"
 
+	"github.com/deepinbytes/go_voucher/domain/user"
 	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
 	"github.com/jinzhu/gorm"
 )
 
@@ -21,7 +26,7 @@ var voucher2 = &voucher.Voucher{
 	OfferID: 1,
 }
 
-func (vs *voucherSvc) GetByID(id uint) (*voucher.Voucher, error) {
+func (vs *voucherSvc) GetByID(ctx context.Context, id uint) (*voucher.Voucher, error) {
 	if id >= uint(100) {
 		return nil, errors.New("Ugh")
 	}
@@ -34,7 +39,7 @@ func (vs *voucherSvc) GetByID(id uint) (*voucher.Voucher, error) {
 	return voucher1, nil
 }
 
-func (vs *voucherSvc) UseCode(code string) (*voucher.Voucher, error) {
+func (vs *voucherSvc) UseCode(ctx context.Context, code string) (*voucher.Voucher, error) {
 	if code == "non_existent_code" {
 		return nil, errors.New("Nop")
 	}
@@ -44,16 +49,80 @@ func (vs *voucherSvc) UseCode(code string) (*voucher.Voucher, error) {
 	return voucher2, nil
 }
 
-func (vs *voucherSvc) Create(voucher *voucher.Voucher) error {
-	if voucher.Code == "existing_code" {
+func (vs *voucherSvc) Create(ctx context.Context, voucher *voucher.Voucher) error {
+	if voucher.Code == "EXISTING1" {
 		return errors.New("Nop")
 	}
 	return nil
 }
 
-func (vs *voucherSvc) Update(voucher *voucher.Voucher) error {
+func (vs *voucherSvc) Update(ctx context.Context, voucher *voucher.Voucher) error {
 	if voucher.Code == "non_existing_code" {
 		return errors.New("Nop")
 	}
 	return nil
 }
+
+func (vs *voucherSvc) Redeem(ctx context.Context, v *voucher.Voucher, owner *user.User, email string) error {
+	if v.Code == "non_existing_code" {
+		return errors.New("Nop")
+	}
+	if email == "eve@cc.cc" {
+		return voucherservice.ErrWrongUser
+	}
+	if email == "used@cc.cc" {
+		return voucherservice.ErrUsed
+	}
+	return nil
+}
+
+func (vs *voucherSvc) RedeemAt(ctx context.Context, v *voucher.Voucher, at time.Time) error {
+	return nil
+}
+
+func (vs *voucherSvc) Reverse(ctx context.Context, v *voucher.Voucher) error {
+	return nil
+}
+
+func (vs *voucherSvc) Issue(ctx context.Context, offerID, userID uint, expireTime time.Time) (*voucher.Voucher, error) {
+	return voucher2, nil
+}
+
+func (vs *voucherSvc) Generate(ctx context.Context, offerID uint, users []*user.User, expireTime time.Time) (int, error) {
+	if offerID >= uint(100) {
+		return 0, errors.New("Nop")
+	}
+	return len(users), nil
+}
+
+func (vs *voucherSvc) ExpiredByOffer(ctx context.Context) (map[uint]int64, error) {
+	return map[uint]int64{}, nil
+}
+
+func (vs *voucherSvc) ListByOffers(ctx context.Context, offerIDs []uint, opts voucherrepo.ListOptions) ([]*voucher.Voucher, error) {
+	return []*voucher.Voucher{}, nil
+}
+
+func (vs *voucherSvc) ListByUsers(ctx context.Context, userIDs []uint, opts voucherrepo.ListOptions) ([]*voucher.Voucher, error) {
+	return []*voucher.Voucher{}, nil
+}
+
+func (vs *voucherSvc) RevokeUnusedByOffer(ctx context.Context, offerID uint, at time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (vs *voucherSvc) RevokeUnusedByUser(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (vs *voucherSvc) RestoreByOffer(ctx context.Context, offerID uint, at time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (vs *voucherSvc) RestoreByUser(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (vs *voucherSvc) Purge(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
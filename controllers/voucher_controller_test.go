@@ -2,7 +2,9 @@ package controllers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"github.com/deepinbytes/go_voucher/common/ratelimit"
 	"github.com/deepinbytes/go_voucher/domain/voucher"
 	"net/http"
 	"net/http/httptest"
@@ -175,7 +177,7 @@ func TestVoucherController(t *testing.T) {
 
 		t.Run("Fails to create voucher", func(t *testing.T) {
 			reqBody := map[string]interface{}{
-				"code":     "existing_code",
+				"code":     "EXISTING1",
 				"user_id":  45,
 				"offer_id": 12,
 			}
@@ -208,7 +210,8 @@ func TestVoucherController(t *testing.T) {
 	t.Run("Redeem", func(t *testing.T) {
 		t.Run("Offer not available", func(t *testing.T) {
 			reqBody := map[string]interface{}{
-				"code": "TEST1",
+				"code":  "TEST1",
+				"email": "alice@cc.cc",
 			}
 
 			w := httptest.NewRecorder()
@@ -233,3 +236,90 @@ func TestVoucherController(t *testing.T) {
 	})
 
 }
+
+func TestVoucherControllerV1(t *testing.T) {
+
+	// Setup router + voucher controller
+	os := &offerSvc{}
+	us := &userSvc{}
+	vs := &voucherSvc{}
+	voucherCtl := NewVoucherController(vs, us, os)
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.POST("/api/v1/vouchers", voucherCtl.Post)
+	router.GET("/api/v1/vouchers/:code", voucherCtl.GetByCode)
+	router.POST("/api/v1/vouchers/:code/redeem", voucherCtl.RedeemCode)
+
+	t.Run("Post", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			w := performJSONRequest(router, "POST", "/api/v1/vouchers",
+				map[string]interface{}{"offer_id": 1, "user_id": 1, "expiry_days": 7}, nil)
+
+			assert.Equal(t, http.StatusCreated, w.Code)
+			assert.Equal(t, "/api/v1/vouchers/TEST2", w.Header().Get("Location"))
+		})
+
+		t.Run("Unknown offer", func(t *testing.T) {
+			w := performJSONRequest(router, "POST", "/api/v1/vouchers",
+				map[string]interface{}{"offer_id": 10, "user_id": 1, "expiry_days": 7}, nil)
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+	})
+
+	t.Run("GetByCode", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/vouchers/TEST2")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("RedeemCode", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			w := performJSONRequest(router, "POST", "/api/v1/vouchers/TEST2/redeem",
+				map[string]interface{}{"email": "alice@cc.cc"}, nil)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+
+		t.Run("Someone else's voucher", func(t *testing.T) {
+			w := performJSONRequest(router, "POST", "/api/v1/vouchers/TEST2/redeem",
+				map[string]interface{}{"email": "eve@cc.cc"}, nil)
+
+			assert.Equal(t, http.StatusForbidden, w.Code)
+		})
+
+		t.Run("Already used", func(t *testing.T) {
+			w := performJSONRequest(router, "POST", "/api/v1/vouchers/TEST2/redeem",
+				map[string]interface{}{"email": "used@cc.cc"}, nil)
+
+			assert.Equal(t, http.StatusConflict, w.Code)
+		})
+
+		t.Run("Tells the lockout the outcome", func(t *testing.T) {
+			var attempt *ratelimit.Attempt
+			router := gin.New()
+			router.POST("/api/v1/vouchers/:code/redeem", func(c *gin.Context) {
+				var ctx context.Context
+				ctx, attempt = ratelimit.NewAttemptContext(c.Request.Context())
+				c.Request = c.Request.WithContext(ctx)
+				c.Next()
+			}, voucherCtl.RedeemCode)
+
+			for email, want := range map[string]struct {
+				failures  int
+				succeeded bool
+			}{
+				"alice@cc.cc":  {0, true},
+				"eve@cc.cc":    {1, false},
+				"used@cc.cc":   {1, false},
+				"not-an-email": {0, false},
+			} {
+				performJSONRequest(router, "POST", "/api/v1/vouchers/TEST2/redeem",
+					map[string]interface{}{"email": email}, nil)
+
+				assert.Equal(t, want.failures, attempt.Failures(), email)
+				assert.Equal(t, want.succeeded, attempt.Succeeded(), email)
+			}
+		})
+	})
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/deepinbytes/go_voucher/domain/wallet"
+	"github.com/deepinbytes/go_voucher/services/walletservice"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WalletController interface
+type WalletController interface {
+	Get(*gin.Context)
+}
+
+type walletController struct {
+	walletSvc walletservice.WalletService
+}
+
+// NewWalletController instantiates Wallet Controller
+func NewWalletController(walletSvc walletservice.WalletService) WalletController {
+	return &walletController{
+		walletSvc: walletSvc,
+	}
+}
+
+// @Summary List the vouchers of a user grouped by status, with a status the Link header points to the next page
+// @Produce  json
+// @Param id path int true "ID"
+// @Param status query string false "active, used or expired, all groups when empty"
+// @Param offer_id query int false "Only the vouchers of this offer"
+// @Param after query int false "List vouchers after this ID, with status only"
+// @Param limit query int false "Page size of every group"
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/v1/users/{id}/wallet [get]
+func (ctl *walletController) Get(c *gin.Context) {
+	id, err := ctl.getID(c.Param("id"), "user")
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	after, limit, err := pageParams(c)
+	if err != nil {
+		HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	filter := walletservice.Filter{Status: c.Query("status"), After: after, Limit: limit}
+	if s := c.Query("offer_id"); s != "" {
+		if filter.OfferID, err = ctl.getID(s, "offer"); err != nil {
+			HTTPRes(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+	}
+
+	w, err := ctl.walletSvc.Get(c.Request.Context(), id, filter)
+	if err != nil {
+		HTTPRes(c, ctl.errStatus(err), err.Error(), nil)
+		return
+	}
+	if g := ctl.group(w, filter.Status); g != nil && g.NextAfter != 0 {
+		setNextLink(c, g.NextAfter, limit)
+	}
+	HTTPRes(c, http.StatusOK, "ok", w)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (ctl *walletController) getID(param, name string) (uint, error) {
+	id, err := strconv.Atoi(param)
+	if err != nil {
+		return 0, errors.New(name + " id should be a number")
+	}
+	return uint(id), nil
+}
+
+// group is the only group listed with a status, nil without one
+func (ctl *walletController) group(w *wallet.Wallet, status string) *wallet.Group {
+	switch status {
+	case wallet.StatusActive:
+		return w.Active
+	case wallet.StatusUsed:
+		return w.Used
+	case wallet.StatusExpired:
+		return w.Expired
+	}
+	return nil
+}
+
+func (ctl *walletController) errStatus(err error) int {
+	if err == walletservice.ErrStatus {
+		return http.StatusBadRequest
+	}
+	return errStatus(err)
+}
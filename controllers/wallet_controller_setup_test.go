@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/deepinbytes/go_voucher/domain/wallet"
+	"github.com/deepinbytes/go_voucher/services/walletservice"
+)
+
+type walletSvc struct {
+	filter walletservice.Filter
+}
+
+func (ws *walletSvc) Get(ctx context.Context, userID uint, filter walletservice.Filter) (*wallet.Wallet, error) {
+	ws.filter = filter
+	if userID >= uint(10) {
+		return nil, errors.New("record not found")
+	}
+	if filter.Status != "" && filter.Status != wallet.StatusActive {
+		return nil, walletservice.ErrStatus
+	}
+	active := &wallet.Group{Vouchers: []wallet.Item{{ID: 3, Code: "ACTIVE01", Status: wallet.StatusActive}}}
+	if filter.Status == "" {
+		return &wallet.Wallet{UserID: userID, Active: active, Used: &wallet.Group{}, Expired: &wallet.Group{}}, nil
+	}
+	active.NextAfter = 3
+	return &wallet.Wallet{UserID: userID, Active: active}, nil
+}
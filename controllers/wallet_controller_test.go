@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/deepinbytes/go_voucher/domain/wallet"
+	"github.com/deepinbytes/go_voucher/services/walletservice"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// NOTE: Mocked services are in './wallet_controller_setup_test.go'
+
+func TestWalletController(t *testing.T) {
+
+	// Setup router + wallet controller
+	ws := &walletSvc{}
+	walletCtl := NewWalletController(ws)
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/api/v1/users/:id/wallet", walletCtl.Get)
+
+	t.Run("Lists every group", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/users/1/wallet?offer_id=2")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "", w.Header().Get("Link"))
+		assert.Equal(t, walletservice.Filter{OfferID: 2, Limit: defaultPageSize}, ws.filter)
+
+		resBody := struct {
+			Data wallet.Wallet `json:"data"`
+		}{}
+		json.NewDecoder(w.Body).Decode(&resBody)
+		assert.Equal(t, "ACTIVE01", resBody.Data.Active.Vouchers[0].Code)
+		assert.NotNil(t, resBody.Data.Expired)
+	})
+
+	t.Run("Links the next page of a status", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/users/1/wallet?status=active&limit=1")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `</api/v1/users/1/wallet?after=3&limit=1&status=active>; rel="next"`, w.Header().Get("Link"))
+	})
+
+	t.Run("Bad params", func(t *testing.T) {
+		for _, url := range []string{
+			"/api/v1/users/x/wallet",
+			"/api/v1/users/1/wallet?offer_id=x",
+			"/api/v1/users/1/wallet?limit=0",
+			"/api/v1/users/1/wallet?status=lost",
+		} {
+			w := performRequest(router, "GET", url)
+			assert.Equal(t, http.StatusBadRequest, w.Code, url)
+		}
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		w := performRequest(router, "GET", "/api/v1/users/10/wallet")
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,31 @@
+package apikey
+
+import "time"
+
+// Key is an API key of a tenant, sent in the X-API-Key header. Only its
+// hash is stored, the key itself is known once, when it is created.
+type Key struct {
+	ID       uint   `gorm:"primary_key" json:"id"`
+	TenantID uint   `gorm:"NOT NULL; INDEX" json:"-"`
+	Name     string `gorm:"NOT NULL; size:64" json:"name"`
+	// Prefix is the start of the key, to tell keys apart
+	Prefix string `gorm:"NOT NULL; size:8" json:"prefix"`
+	// Hash is the hex SHA-256 of the key
+	Hash      string    `gorm:"NOT NULL; size:64; UNIQUE_INDEX" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is when the key stops authenticating, nil for never.
+	// Rotated keys expire after a grace period, retired ones at once.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Plain is the key itself, only set on keys just created
+	Plain string `gorm:"-" json:"key,omitempty"`
+}
+
+// TableName is api_keys rather than the keys gorm derives from Key
+func (Key) TableName() string {
+	return "api_keys"
+}
+
+// Active reports whether the key authenticates at now
+func (k *Key) Active(now time.Time) bool {
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
@@ -0,0 +1,98 @@
+package giftcard
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Accounts of the ledger. Every transaction moves an amount between the
+// card and one of the other accounts, so its entries sum to zero.
+const (
+	// AccountCard is the balance held on the card
+	AccountCard = "card"
+	// AccountFunding is the money paid in for the card and its top-ups
+	AccountFunding = "funding"
+	// AccountOrders is the money spent from the card on orders
+	AccountOrders = "orders"
+)
+
+// Kinds of ledger transactions
+const (
+	KindIssue  = "issue"
+	KindTopUp  = "top_up"
+	KindDebit  = "debit"
+	KindRefund = "refund"
+)
+
+var (
+	// ErrInsufficientBalance is returned when a debit exceeds the balance
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	// ErrUnbalanced is returned when the entries of a transaction do not
+	// sum to zero
+	ErrUnbalanced = errors.New("ledger entries do not balance")
+)
+
+// Card domain model, a stored-value code debited across orders. Balance is
+// the sum of the card entries of its ledger.
+type Card struct {
+	gorm.Model
+	// TenantID owns the card, codes are unique per tenant
+	TenantID uint   `gorm:"NOT NULL; DEFAULT:1; UNIQUE_INDEX:uix_gift_cards_tenant_code" json:"-"`
+	Code     string `gorm:"NOT NULL; UNIQUE_INDEX:uix_gift_cards_tenant_code" json:"code"`
+	Currency string `gorm:"NOT NULL; size:3" json:"currency"`
+	// InitialBalance and Balance are in minor units of Currency
+	InitialBalance int64 `gorm:"NOT NULL" json:"initial_balance"`
+	Balance        int64 `gorm:"NOT NULL" json:"balance"`
+	// Version counts the transactions of the card, they are posted only
+	// against the version they were worked out from
+	Version uint `gorm:"NOT NULL; DEFAULT:1" json:"version"`
+}
+
+// TableName keeps the cards apart from other tables named after "Card"
+func (Card) TableName() string {
+	return "gift_cards"
+}
+
+// Entry is one line of the ledger of a card. A positive amount credits the
+// account, a negative one debits it.
+type Entry struct {
+	ID       uint `gorm:"primary_key" json:"id"`
+	TenantID uint `gorm:"NOT NULL; DEFAULT:1; INDEX" json:"-"`
+	CardID   uint `gorm:"NOT NULL; INDEX" json:"card_id"`
+	// TxID groups the entries of one transaction
+	TxID     string `gorm:"NOT NULL; size:32; INDEX" json:"tx_id"`
+	Kind     string `gorm:"NOT NULL; size:16" json:"kind"`
+	Account  string `gorm:"NOT NULL; size:16" json:"account"`
+	Amount   int64  `gorm:"NOT NULL" json:"amount"`
+	Currency string `gorm:"NOT NULL; size:3" json:"currency"`
+	// Reference is the order of debits and refunds
+	Reference string    `gorm:"size:64; INDEX" json:"reference,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName keeps the entries apart from other tables named after "Entry"
+func (Entry) TableName() string {
+	return "gift_card_entries"
+}
+
+// Balanced tells whether entries sum to zero
+func Balanced(entries []Entry) bool {
+	var sum int64
+	for _, e := range entries {
+		sum += e.Amount
+	}
+	return len(entries) > 0 && sum == 0
+}
+
+// CardAmount is what entries add to the balance of the card
+func CardAmount(entries []Entry) int64 {
+	var sum int64
+	for _, e := range entries {
+		if e.Account == AccountCard {
+			sum += e.Amount
+		}
+	}
+	return sum
+}
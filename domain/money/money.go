@@ -0,0 +1,167 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// ErrCurrency is returned for currencies that are not sold in
+var ErrCurrency = errors.New("currency must be one of " + strings.Join(Codes(), ", "))
+
+// Rounding rules of the currencies, applied whenever an amount falls
+// between two minor units
+const (
+	// RoundHalfUp rounds halves away from zero
+	RoundHalfUp Rounding = iota
+	// RoundHalfEven rounds halves to the even minor unit
+	RoundHalfEven
+	// RoundDown drops the fraction, in favour of the merchant
+	RoundDown
+)
+
+// Rounding tells how a currency rounds fractions of its minor unit
+type Rounding int
+
+// Currency is an ISO 4217 currency as it is sold in
+type Currency struct {
+	Code string
+	// Exponent is the number of decimals of the minor unit, 2 for cents
+	Exponent int
+	Rounding Rounding
+}
+
+var currencies = map[string]Currency{
+	"EUR": {Code: "EUR", Exponent: 2, Rounding: RoundHalfEven},
+	"GBP": {Code: "GBP", Exponent: 2, Rounding: RoundHalfUp},
+	"USD": {Code: "USD", Exponent: 2, Rounding: RoundHalfUp},
+}
+
+// Lookup returns the currency of an upper-case ISO code
+func Lookup(code string) (Currency, error) {
+	c, ok := currencies[code]
+	if !ok {
+		return Currency{}, ErrCurrency
+	}
+	return c, nil
+}
+
+// Codes lists the currencies sold in, sorted
+func Codes() []string {
+	codes := make([]string, 0, len(currencies))
+	for code := range currencies {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Round rounds r, in minor units, to a whole minor unit
+func (c Currency) Round(r *big.Rat) int64 {
+	num, den := r.Num(), r.Denom()
+	q, m := new(big.Int).QuoRem(num, den, new(big.Int))
+	if m.Sign() == 0 || c.Rounding == RoundDown {
+		return q.Int64()
+	}
+	// compare twice the remainder to the denominator to find halves
+	cmp := new(big.Int).Abs(new(big.Int).Mul(m, big.NewInt(2))).Cmp(den)
+	away := cmp > 0 || cmp == 0 && (c.Rounding == RoundHalfUp || q.Bit(0) == 1)
+	if away {
+		q.Add(q, big.NewInt(int64(num.Sign())))
+	}
+	return q.Int64()
+}
+
+// Money is an amount in the minor units of its currency, e.g. cents
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// New returns amount minor units of currency
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// String formats m in major units, e.g. "12.50 EUR"
+func (m Money) String() string {
+	c, err := Lookup(m.Currency)
+	if err != nil || c.Exponent == 0 {
+		return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+	}
+	return new(big.Rat).SetFrac64(m.Amount, pow10(c.Exponent)).FloatString(c.Exponent) + " " + m.Currency
+}
+
+// Percent returns pct percent of m, rounded by the rules of its currency
+func Percent(m Money, pct uint) (Money, error) {
+	c, err := Lookup(m.Currency)
+	if err != nil {
+		return Money{}, err
+	}
+	r := new(big.Rat).SetFrac64(m.Amount*int64(pct), 100)
+	return Money{Amount: c.Round(r), Currency: m.Currency}, nil
+}
+
+// Convert returns m in the currency to at rate, the price of one major
+// unit of m in major units of to, rounded by the rules of to
+func Convert(m Money, to string, rate *big.Rat) (Money, error) {
+	from, err := Lookup(m.Currency)
+	if err != nil {
+		return Money{}, err
+	}
+	c, err := Lookup(to)
+	if err != nil {
+		return Money{}, err
+	}
+	r := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), rate)
+	r.Mul(r, new(big.Rat).SetFrac64(pow10(c.Exponent), pow10(from.Exponent)))
+	return Money{Amount: c.Round(r), Currency: to}, nil
+}
+
+// Amounts maps currency codes to amounts in their minor units. It is
+// stored as a JSON object in a text column.
+type Amounts map[string]int64
+
+// Value implements driver.Valuer
+func (a Amounts) Value() (driver.Value, error) {
+	if len(a) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(a)
+	return string(b), err
+}
+
+// Scan implements sql.Scanner
+func (a *Amounts) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("money: cannot scan %T into Amounts", src)
+	}
+	*a = nil
+	if len(b) == 0 {
+		return nil
+	}
+	return json.Unmarshal(b, a)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
@@ -0,0 +1,66 @@
+package money
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		rounding Rounding
+		in       *big.Rat
+		want     int64
+	}{
+		{RoundHalfUp, big.NewRat(5, 2), 3},
+		{RoundHalfUp, big.NewRat(-5, 2), -3},
+		{RoundHalfUp, big.NewRat(12, 10), 1},
+		{RoundHalfEven, big.NewRat(5, 2), 2},
+		{RoundHalfEven, big.NewRat(7, 2), 4},
+		{RoundHalfEven, big.NewRat(26, 10), 3},
+		{RoundDown, big.NewRat(29, 10), 2},
+		{RoundDown, big.NewRat(4, 1), 4},
+	}
+	for _, tt := range tests {
+		c := Currency{Code: "XXX", Exponent: 2, Rounding: tt.rounding}
+		assert.Equal(t, tt.want, c.Round(tt.in), tt.in.String())
+	}
+}
+
+func TestPercent(t *testing.T) {
+	// 15% of 12.30 is 1.845, a half cent
+	m, err := Percent(New(1230, "EUR"), 15)
+	require.Nil(t, err)
+	assert.Equal(t, New(184, "EUR"), m)
+	m, err = Percent(New(1230, "USD"), 15)
+	require.Nil(t, err)
+	assert.Equal(t, New(185, "USD"), m)
+
+	_, err = Percent(New(100, "XXX"), 10)
+	assert.Equal(t, ErrCurrency, err)
+}
+
+func TestConvert(t *testing.T) {
+	m, err := Convert(New(1000, "EUR"), "GBP", big.NewRat(8575, 10000))
+	require.Nil(t, err)
+	assert.Equal(t, New(858, "GBP"), m)
+	assert.Equal(t, "8.58 GBP", m.String())
+
+	_, err = Convert(New(1000, "EUR"), "JPY", big.NewRat(1, 1))
+	assert.Equal(t, ErrCurrency, err)
+}
+
+func TestAmounts(t *testing.T) {
+	v, err := Amounts{"GBP": 450, "USD": 550}.Value()
+	require.Nil(t, err)
+	assert.Equal(t, `{"GBP":450,"USD":550}`, v)
+
+	var a Amounts
+	require.Nil(t, a.Scan([]byte(v.(string))))
+	assert.Equal(t, Amounts{"GBP": 450, "USD": 550}, a)
+	require.Nil(t, a.Scan(""))
+	assert.Nil(t, a)
+	assert.NotNil(t, a.Scan(42))
+}
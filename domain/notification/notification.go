@@ -0,0 +1,121 @@
+package notification
+
+import (
+	"strings"
+	"time"
+)
+
+// Channels a voucher is sent over
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+// Channels lists the channels a template may be for
+var Channels = []string{ChannelEmail, ChannelSMS}
+
+// Statuses of a delivery
+const (
+	// StatusPending deliveries are sent at NextAttemptAt
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	// StatusFailed deliveries failed every attempt, they are not retried
+	StatusFailed = "failed"
+	// StatusSkipped deliveries had nothing to send to, e.g. a user without
+	// a phone number, or nothing to send, e.g. a revoked voucher
+	StatusSkipped = "skipped"
+)
+
+// Template renders the message sending a voucher over a channel to users
+// of a locale. OfferID 0 is the template of every offer without its own.
+// Subject and Body are Go text templates, see Data.
+type Template struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	TenantID  uint      `gorm:"NOT NULL; DEFAULT:1; UNIQUE_INDEX:uix_notification_templates_key" json:"-"`
+	OfferID   uint      `gorm:"NOT NULL; UNIQUE_INDEX:uix_notification_templates_key" json:"offer_id"`
+	Channel   string    `gorm:"NOT NULL; size:8; UNIQUE_INDEX:uix_notification_templates_key" json:"channel"`
+	Locale    string    `gorm:"NOT NULL; size:16; UNIQUE_INDEX:uix_notification_templates_key" json:"locale"`
+	Subject   string    `gorm:"NOT NULL; size:255" json:"subject,omitempty"`
+	Body      string    `gorm:"NOT NULL; type:text" json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName keeps the templates apart from other tables named after
+// "Template"
+func (Template) TableName() string {
+	return "notification_templates"
+}
+
+// Data is what templates are rendered with, e.g. "{{.Code}} gives you
+// {{.Discount}} until {{.Expires}}"
+type Data struct {
+	FirstName string
+	LastName  string
+	Code      string
+	Offer     string
+	// Discount describes the discount, e.g. "20%" or "5.00 EUR"
+	Discount string
+	// Expires is the expiry date as YYYY-MM-DD
+	Expires string
+}
+
+// Delivery tracks sending a voucher over a channel. There is one per
+// voucher and channel, retried until it is sent or MaxAttempts failed.
+// The recipient is read from the user when sending, so deliveries hold no
+// personal data.
+type Delivery struct {
+	ID        uint   `gorm:"primary_key" json:"id"`
+	TenantID  uint   `gorm:"NOT NULL; DEFAULT:1; UNIQUE_INDEX:uix_notification_deliveries_key" json:"-"`
+	VoucherID uint   `gorm:"NOT NULL; UNIQUE_INDEX:uix_notification_deliveries_key" json:"voucher_id"`
+	Channel   string `gorm:"NOT NULL; size:8; UNIQUE_INDEX:uix_notification_deliveries_key" json:"channel"`
+	UserID    uint   `gorm:"NOT NULL" json:"user_id"`
+	Status    string `gorm:"NOT NULL; size:16" json:"status"`
+	Attempts  int    `gorm:"NOT NULL" json:"attempts"`
+	// LastError is why the last attempt failed or the delivery was skipped
+	LastError     string     `gorm:"NOT NULL; size:512" json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `gorm:"INDEX" json:"next_attempt_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// TableName keeps the deliveries apart from other tables named after
+// "Delivery"
+func (Delivery) TableName() string {
+	return "notification_deliveries"
+}
+
+// Locales returns the locales to look a template up in for a user of
+// locale, most specific first and ending with fallback: "de-CH" gives
+// "de-ch", "de" and then fallback
+func Locales(locale, fallback string) []string {
+	var ls []string
+	add := func(l string) {
+		for _, seen := range ls {
+			if seen == l {
+				return
+			}
+		}
+		if l != "" {
+			ls = append(ls, l)
+		}
+	}
+	locale = NormalizeLocale(locale)
+	for l := locale; l != ""; {
+		add(l)
+		i := strings.LastIndex(l, "-")
+		if i < 0 {
+			break
+		}
+		l = l[:i]
+	}
+	add(NormalizeLocale(fallback))
+	return ls
+}
+
+// NormalizeLocale lower-cases a locale and separates its parts with
+// hyphens, "de_CH" and "de-ch" are both "de-ch"
+func NormalizeLocale(locale string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(locale), "_", "-", -1))
+}
@@ -1,12 +1,37 @@
 package offer
 
 import (
+	"github.com/deepinbytes/go_voucher/domain/money"
+
 	"github.com/jinzhu/gorm"
 )
 
 // User domain model
 type Offer struct {
 	gorm.Model
-	Name               string `gorm:"NOT NULL; UNIQUE_INDEX" json:"name"`
+	// TenantID owns the offer, names are unique per tenant
+	TenantID           uint   `gorm:"NOT NULL; DEFAULT:1; UNIQUE_INDEX:uix_offers_tenant_name" json:"tenant_id"`
+	Name               string `gorm:"NOT NULL; UNIQUE_INDEX:uix_offers_tenant_name" json:"name"`
 	DiscountPercentage uint   `json:"discount_percentage"`
+	// DiscountAmount is a fixed discount in the minor units of
+	// DiscountCurrency, offers have either a percentage or an amount
+	DiscountAmount   int64  `gorm:"NOT NULL; DEFAULT:0" json:"discount_amount,omitempty"`
+	DiscountCurrency string `gorm:"NOT NULL; DEFAULT:''; size:3" json:"discount_currency,omitempty"`
+	// DiscountAmounts sets the fixed discount in other currencies, the
+	// others are converted from DiscountAmount
+	DiscountAmounts money.Amounts `gorm:"type:text; DEFAULT:''" json:"discount_amounts,omitempty"`
+	// Stackable offers combine with other stackable offers on one order,
+	// others are only applied alone
+	Stackable bool `gorm:"NOT NULL; DEFAULT:false" json:"stackable"`
+	// ExclusivityGroup names offers of which an order gets at most one
+	ExclusivityGroup string `gorm:"NOT NULL; DEFAULT:''; size:64" json:"exclusivity_group"`
+	// Priority orders the vouchers of an order, the highest is applied first
+	Priority int `gorm:"NOT NULL; DEFAULT:0" json:"priority"`
+	// Version counts the updates of the offer, it backs the ETag
+	Version uint `gorm:"NOT NULL; DEFAULT:1" json:"version"`
+}
+
+// Fixed tells whether the discount is an amount rather than a percentage
+func (o *Offer) Fixed() bool {
+	return o.DiscountAmount > 0
 }
@@ -0,0 +1,61 @@
+package offline
+
+import "time"
+
+// Statuses of an offline redemption
+const (
+	// StatusAccepted redeemed the voucher at the time of the terminal
+	StatusAccepted = "accepted"
+	// StatusDoubleUse is a voucher already used, online or by another
+	// terminal, when the redemption was uploaded
+	StatusDoubleUse = "double_use"
+	// StatusRejected is a token that should not have been accepted
+	StatusRejected = "rejected"
+	// StatusPending is claimed by an upload still being reconciled, or by
+	// one that failed half way; uploading it again finishes it
+	StatusPending = "pending"
+)
+
+// Reasons a redemption is rejected for
+const (
+	ReasonInvalidToken = "invalid_token"
+	ReasonOtherTenant  = "other_tenant"
+	// ReasonExpired is a voucher expired when the terminal redeemed it
+	ReasonExpired = "expired"
+	// ReasonRevoked is a voucher deleted since the token was issued
+	ReasonRevoked = "revoked"
+	// ReasonFuture is a redemption time ahead of the server clock
+	ReasonFuture = "future"
+)
+
+// Upload is a redemption a terminal accepted offline, identified by a
+// reference of the terminal so uploading it again is harmless
+type Upload struct {
+	Reference  string
+	Token      string
+	RedeemedAt time.Time
+}
+
+// Redemption is the outcome of an uploaded redemption, one per terminal
+// and reference
+type Redemption struct {
+	ID        uint   `gorm:"primary_key" json:"id"`
+	TenantID  uint   `gorm:"NOT NULL; DEFAULT:1; UNIQUE_INDEX:uix_offline_redemptions_key" json:"-"`
+	Terminal  string `gorm:"NOT NULL; size:64; UNIQUE_INDEX:uix_offline_redemptions_key" json:"terminal"`
+	Reference string `gorm:"NOT NULL; size:64; UNIQUE_INDEX:uix_offline_redemptions_key" json:"reference"`
+	// Code and VoucherID are empty for tokens that could not be read
+	Code       string    `gorm:"NOT NULL; size:64" json:"code,omitempty"`
+	VoucherID  uint      `json:"voucher_id,omitempty"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+	Status     string    `gorm:"NOT NULL; size:16; INDEX" json:"status"`
+	Reason     string    `gorm:"NOT NULL; size:32" json:"reason,omitempty"`
+	// FirstUsedAt is when the voucher of a double use was used first
+	FirstUsedAt *time.Time `json:"first_used_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// TableName keeps the redemptions apart from other tables named after
+// "Redemption"
+func (Redemption) TableName() string {
+	return "offline_redemptions"
+}
@@ -0,0 +1,95 @@
+package privacy
+
+import "time"
+
+// Actions of data subject requests
+const (
+	ActionExport  = "export"
+	ActionErasure = "erasure"
+)
+
+// Record logs a data subject request for compliance. It holds no personal
+// data so it outlives the erasure and purge of the user.
+type Record struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	TenantID  uint      `gorm:"NOT NULL; DEFAULT:1; INDEX" json:"-"`
+	UserID    uint      `gorm:"NOT NULL; INDEX" json:"user_id"`
+	Action    string    `gorm:"size:32; NOT NULL" json:"action"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName keeps the records apart from other tables named after "Record"
+func (Record) TableName() string {
+	return "privacy_records"
+}
+
+// Export bundles the personal data held on a user
+type Export struct {
+	ExportedAt  time.Time    `json:"exported_at"`
+	Profile     Profile      `json:"profile"`
+	Vouchers    []Voucher    `json:"vouchers"`
+	Redemptions []Redemption `json:"redemptions"`
+	// ReferralCode is the code the user refers others with, if any
+	ReferralCode string     `json:"referral_code,omitempty"`
+	Referrals    []Referral `json:"referrals"`
+	Deliveries   []Delivery `json:"deliveries"`
+	Requests     []Record   `json:"privacy_requests"`
+}
+
+// Profile is the personal data of the user record itself
+type Profile struct {
+	ID        uint      `json:"id"`
+	Email     string    `json:"email"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Birthday  string    `json:"birthday,omitempty"`
+	Phone     string    `json:"phone,omitempty"`
+	Locale    string    `json:"locale,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Voucher is a voucher issued to the user
+type Voucher struct {
+	Code               string    `json:"code"`
+	OfferID            uint      `json:"offer_id"`
+	OfferName          string    `json:"offer_name"`
+	DiscountPercentage uint      `json:"discount_percentage"`
+	IssuedAt           time.Time `json:"issued_at"`
+	ExpireTime         time.Time `json:"expire_time"`
+	IsUsed             bool      `json:"is_used"`
+	// RevokedAt is when the voucher was revoked, e.g. with its offer
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Redemption is a voucher the user redeemed
+type Redemption struct {
+	Code      string    `json:"code"`
+	OfferID   uint      `json:"offer_id"`
+	OfferName string    `json:"offer_name"`
+	UsedAt    time.Time `json:"used_at"`
+}
+
+// Roles of the user in a referral
+const (
+	RoleReferrer = "referrer"
+	RoleReferee  = "referee"
+)
+
+// Referral is a referral the user made or was referred by. The other user
+// is left out, it is not the personal data of the user.
+type Referral struct {
+	Role       string     `json:"role"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RewardedAt *time.Time `json:"rewarded_at,omitempty"`
+}
+
+// Delivery is a voucher sent to the user over a channel
+type Delivery struct {
+	Code      string     `json:"code"`
+	Channel   string     `json:"channel"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	SentAt    *time.Time `json:"sent_at,omitempty"`
+}
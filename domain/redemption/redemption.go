@@ -0,0 +1,74 @@
+package redemption
+
+import "github.com/deepinbytes/go_voucher/domain/money"
+
+// MaxDiscount caps the combined discount of an order, in percent
+const MaxDiscount = 100
+
+// Reasons a code of an order is rejected
+const (
+	ReasonNotFound         = "not_found"
+	ReasonDuplicate        = "duplicate"
+	ReasonWrongUser        = "wrong_user"
+	ReasonUsed             = "used"
+	ReasonExpired          = "expired"
+	ReasonOfferUnavailable = "offer_unavailable"
+	ReasonNotStackable     = "not_stackable"
+	ReasonExclusive        = "exclusive"
+	ReasonCapReached       = "cap_reached"
+	ReasonOrderTotal       = "order_total_required"
+	ReasonCurrency         = "currency"
+)
+
+// Request redeems several codes of one user together, for one order
+type Request struct {
+	Codes []string
+	// Email of the user the vouchers were issued to
+	Email string
+	// MaxDiscount lowers the cap of the combined discount, MaxDiscount when 0
+	MaxDiscount uint
+	// OrderTotal turns the discounts into amounts of its currency, fixed
+	// discounts need it
+	OrderTotal *money.Money
+	// DryRun works out the result without redeeming anything
+	DryRun bool
+}
+
+// Result tells which codes of an order were applied, in the order they
+// were applied, and why the others were rejected. Without an order total
+// discounts are percentages, with one they are amounts.
+type Result struct {
+	Applied  []Applied  `json:"applied"`
+	Rejected []Rejected `json:"rejected"`
+	// TotalDiscount sums the discounts applied, in percent
+	TotalDiscount uint `json:"total_discount"`
+	MaxDiscount   uint `json:"max_discount"`
+	// DiscountAmount sums the amounts applied, capped at MaxDiscount
+	// percent of the order total
+	DiscountAmount *money.Money `json:"discount_amount,omitempty"`
+	// Payable is the order total less DiscountAmount
+	Payable *money.Money `json:"payable,omitempty"`
+	DryRun  bool         `json:"dry_run"`
+}
+
+// Applied is a code redeemed for the order
+type Applied struct {
+	Code               string `json:"code"`
+	VoucherID          uint   `json:"voucher_id"`
+	OfferID            uint   `json:"offer_id"`
+	Offer              string `json:"offer"`
+	Priority           int    `json:"priority"`
+	DiscountPercentage uint   `json:"discount_percentage"`
+	// Discount is the part of DiscountPercentage that fit under the cap
+	Discount uint `json:"discount"`
+	// Amount is the discount in the currency of the order total, the part
+	// that fit under the cap
+	Amount *money.Money `json:"amount,omitempty"`
+}
+
+// Rejected is a code left out of the order
+type Rejected struct {
+	Code    string `json:"code"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
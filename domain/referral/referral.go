@@ -0,0 +1,118 @@
+package referral
+
+import (
+	"strings"
+	"time"
+)
+
+// Statuses of a referral
+const (
+	// StatusPending waits for the first redemption of the referee
+	StatusPending = "pending"
+	// StatusRewarded issued a voucher to both sides
+	StatusRewarded = "rewarded"
+	// StatusRejected failed a fraud check, it is never rewarded
+	StatusRejected = "rejected"
+)
+
+// Reasons a referral is rejected for
+const (
+	// ReasonSelfReferral is a referee whose email is the referrer's under
+	// another spelling, e.g. with a +tag
+	ReasonSelfReferral = "self_referral"
+	// ReasonDomainThrottled is a referrer bringing too many referees of
+	// the same email domain in a short time
+	ReasonDomainThrottled = "domain_throttled"
+)
+
+// Code is the referral code of a user, created the first time it is asked
+// for
+type Code struct {
+	ID        uint      `gorm:"primary_key" json:"-"`
+	TenantID  uint      `gorm:"NOT NULL; DEFAULT:1; UNIQUE_INDEX:uix_referral_codes_tenant_code,uix_referral_codes_tenant_user" json:"-"`
+	UserID    uint      `gorm:"NOT NULL; UNIQUE_INDEX:uix_referral_codes_tenant_user" json:"user_id"`
+	Code      string    `gorm:"NOT NULL; size:32; UNIQUE_INDEX:uix_referral_codes_tenant_code" json:"code"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName keeps the codes apart from other tables named after "Code"
+func (Code) TableName() string {
+	return "referral_codes"
+}
+
+// Referral records a user registered with the code of another. Users are
+// referred once at most.
+type Referral struct {
+	ID         uint `gorm:"primary_key" json:"id"`
+	TenantID   uint `gorm:"NOT NULL; DEFAULT:1; INDEX; UNIQUE_INDEX:uix_referrals_tenant_referee" json:"-"`
+	ReferrerID uint `gorm:"NOT NULL; INDEX" json:"referrer_id"`
+	RefereeID  uint `gorm:"NOT NULL; UNIQUE_INDEX:uix_referrals_tenant_referee" json:"referee_id"`
+	// RefereeDomain is the email domain of the referee, for throttling
+	RefereeDomain string `gorm:"NOT NULL; size:255" json:"referee_domain"`
+	Status        string `gorm:"NOT NULL; size:16" json:"status"`
+	// Reason tells why a referral was rejected
+	Reason string `gorm:"size:32" json:"reason,omitempty"`
+	// ReferrerVoucherID and RefereeVoucherID are the rewards
+	ReferrerVoucherID uint       `json:"referrer_voucher_id,omitempty"`
+	RefereeVoucherID  uint       `json:"referee_voucher_id,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	RewardedAt        *time.Time `json:"rewarded_at,omitempty"`
+}
+
+// Stats sums up referrals, of one referrer or all of them
+type Stats struct {
+	Total    int64 `json:"total"`
+	Pending  int64 `json:"pending"`
+	Rewarded int64 `json:"rewarded"`
+	Rejected int64 `json:"rejected"`
+	// Reasons counts the rejected referrals per reason
+	Reasons map[string]int64 `json:"reasons"`
+	// VouchersIssued counts the rewards, two per rewarded referral
+	VouchersIssued int64 `json:"vouchers_issued"`
+}
+
+// Add counts n referrals of status, rejected for reason
+func (s *Stats) Add(status, reason string, n int64) {
+	s.Total += n
+	switch status {
+	case StatusPending:
+		s.Pending += n
+	case StatusRewarded:
+		s.Rewarded += n
+		s.VouchersIssued += 2 * n
+	case StatusRejected:
+		s.Rejected += n
+		s.Reasons[reason] += n
+	}
+}
+
+// Domain returns the domain of email, lower-cased
+func Domain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(email[at+1:])
+}
+
+// Canonical returns email without what mail providers ignore: case, a
+// +tag and, for Gmail, dots in the local part. Two emails with the same
+// canonical form reach the same mailbox.
+func Canonical(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	local, domain := email[:at], email[at+1:]
+	if plus := strings.Index(local, "+"); plus >= 0 {
+		local = local[:plus]
+	}
+	if domain == "googlemail.com" {
+		domain = "gmail.com"
+	}
+	if domain == "gmail.com" {
+		local = strings.Replace(local, ".", "", -1)
+	}
+	return local + "@" + domain
+}
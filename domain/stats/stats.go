@@ -0,0 +1,83 @@
+package stats
+
+import (
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/money"
+)
+
+// Buckets of a time series
+const (
+	BucketHour = "hour"
+	BucketDay  = "day"
+)
+
+// BucketSize returns the length of bucket, 0 for unknown buckets
+func BucketSize(bucket string) time.Duration {
+	switch bucket {
+	case BucketHour:
+		return time.Hour
+	case BucketDay:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// Offer sums up how the vouchers of an offer perform
+type Offer struct {
+	OfferID  uint  `json:"offer_id"`
+	Issued   int64 `json:"issued"`
+	Redeemed int64 `json:"redeemed"`
+	// Expired counts the vouchers that expired unused
+	Expired int64 `json:"expired"`
+	// RedemptionRate is Redeemed over Issued, 0 without vouchers
+	RedemptionRate float64 `json:"redemption_rate"`
+	// TimeToRedeem is the time from issue to redemption, in seconds
+	TimeToRedeem Percentiles `json:"time_to_redeem"`
+	// DiscountGiven sums the discount percentages of the redemptions. There
+	// is no order amount to turn it into money, so it is in percentage
+	// points. Fixed discounts are in DiscountAmounts instead.
+	DiscountGiven int64 `json:"discount_given"`
+	// DiscountAmounts sums the discounts applied to orders by currency, in
+	// minor units, as recorded when the vouchers were redeemed. Fixed
+	// discounts redeemed without an order total count at the amount of the
+	// offer.
+	DiscountAmounts money.Amounts `json:"discount_amounts,omitempty"`
+}
+
+// Percentiles of a distribution, nearest rank
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// Point is a bucket of a time series
+type Point struct {
+	Start    time.Time `json:"start"`
+	Issued   int64     `json:"issued"`
+	Redeemed int64     `json:"redeemed"`
+}
+
+// Daily is the rollup of the vouchers of an offer over a UTC day
+type Daily struct {
+	Day           time.Time `gorm:"primary_key" json:"day"`
+	OfferID       uint      `gorm:"primary_key; auto_increment:false" json:"offer_id"`
+	TenantID      uint      `gorm:"NOT NULL; DEFAULT:1; INDEX" json:"-"`
+	Issued        int64     `json:"issued"`
+	Redeemed      int64     `json:"redeemed"`
+	Expired       int64     `json:"expired"`
+	DiscountGiven int64     `json:"discount_given"`
+	// DiscountAmounts sums discounts by currency as in Offer
+	DiscountAmounts money.Amounts `gorm:"type:text; DEFAULT:''" json:"discount_amounts,omitempty"`
+}
+
+// TableName of the daily rollup
+func (Daily) TableName() string {
+	return "voucher_daily_stats"
+}
+
+// Day truncates t to the start of its UTC day
+func Day(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
@@ -0,0 +1,68 @@
+package tenant
+
+import (
+	"context"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// DefaultID is the tenant of calls naming none. It owns every row
+	// created before there were tenants and manages the other tenants.
+	DefaultID uint = 1
+	// DefaultSlug is the slug of the default tenant
+	DefaultSlug = "default"
+)
+
+// Tenant domain model, a merchant sharing the deployment. Offers, users
+// and vouchers all belong to one.
+type Tenant struct {
+	gorm.Model
+	Name string `gorm:"NOT NULL" json:"name"`
+	// Slug names the tenant in the X-Tenant header and is its subdomain
+	Slug string `gorm:"size:63; NOT NULL; UNIQUE_INDEX" json:"slug"`
+	// APIKey is the first API key of a tenant just created. Keys are
+	// stored hashed with the API keys, the default tenant has none.
+	APIKey string `gorm:"-" json:"-"`
+	// LegacyAPIKey is the column keys were kept in plain text in, emptied
+	// once they are hashed. SQLite cannot drop it.
+	LegacyAPIKey string `gorm:"column:api_key" json:"-"`
+	// KeyID is the API key the tenant was resolved by, 0 when none
+	KeyID uint `gorm:"-" json:"-"`
+}
+
+type (
+	ctxKey    struct{}
+	ctxKeyKey struct{}
+)
+
+// NewContext returns a copy of ctx scoped to the tenant id
+func NewContext(ctx context.Context, id uint) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the tenant ctx is scoped to, DefaultID when none
+func FromContext(ctx context.Context) uint {
+	if ctx != nil {
+		if id, ok := ctx.Value(ctxKey{}).(uint); ok {
+			return id
+		}
+	}
+	return DefaultID
+}
+
+// NewKeyContext returns a copy of ctx authenticated by the API key id
+func NewKeyContext(ctx context.Context, keyID uint) context.Context {
+	return context.WithValue(ctx, ctxKeyKey{}, keyID)
+}
+
+// KeyFromContext returns the API key ctx was authenticated by, 0 when it
+// was not
+func KeyFromContext(ctx context.Context) uint {
+	if ctx != nil {
+		if id, ok := ctx.Value(ctxKeyKey{}).(uint); ok {
+			return id
+		}
+	}
+	return 0
+}
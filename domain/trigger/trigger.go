@@ -0,0 +1,63 @@
+package trigger
+
+import (
+	"strconv"
+	"time"
+)
+
+// Events a trigger fires on
+const (
+	// EventUserRegistered fires once a user is created
+	EventUserRegistered = "user_registered"
+	// EventBirthday fires on the birthday of a user, once a year
+	EventBirthday = "birthday"
+)
+
+// Events lists the events a trigger may fire on
+var Events = []string{EventUserRegistered, EventBirthday}
+
+// Trigger is a rule issuing a voucher of an offer to a user when event
+// happens, e.g. a welcome voucher valid 14 days on registration
+type Trigger struct {
+	ID       uint   `gorm:"primary_key" json:"id"`
+	TenantID uint   `gorm:"NOT NULL; DEFAULT:1; INDEX" json:"-"`
+	Event    string `gorm:"NOT NULL; size:32" json:"event"`
+	OfferID  uint   `gorm:"NOT NULL" json:"offer_id"`
+	// ValidityDays is how long the vouchers issued are valid for
+	ValidityDays int `gorm:"NOT NULL" json:"validity_days"`
+	// Active triggers fire, inactive ones are kept for later
+	Active    bool      `gorm:"NOT NULL" json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Issuance records a voucher issued by a trigger. There is one per
+// trigger, user and occurrence, which keeps triggers from issuing twice.
+type Issuance struct {
+	ID        uint `gorm:"primary_key" json:"id"`
+	TenantID  uint `gorm:"NOT NULL; DEFAULT:1; UNIQUE_INDEX:uix_trigger_issuances_key" json:"-"`
+	TriggerID uint `gorm:"NOT NULL; UNIQUE_INDEX:uix_trigger_issuances_key" json:"trigger_id"`
+	UserID    uint `gorm:"NOT NULL; UNIQUE_INDEX:uix_trigger_issuances_key" json:"user_id"`
+	// Occurrence tells apart the times a trigger fires for a user, see
+	// Occurrence
+	Occurrence string `gorm:"NOT NULL; size:16; UNIQUE_INDEX:uix_trigger_issuances_key" json:"occurrence"`
+	// VoucherID is 0 while the voucher is being issued
+	VoucherID uint      `json:"voucher_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName keeps the issuances apart from other tables named after
+// "Issuance"
+func (Issuance) TableName() string {
+	return "trigger_issuances"
+}
+
+// Occurrence returns the occurrence of event on day: the year for
+// birthdays, so they are celebrated once a year, and "once" for the events
+// happening once per user
+func Occurrence(event string, day time.Time) string {
+	if event == EventBirthday {
+		return strconv.Itoa(day.Year())
+	}
+	return "once"
+}
@@ -1,6 +1,10 @@
 package user
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/deepinbytes/go_voucher/domain/voucher"
 	"github.com/jinzhu/gorm"
 )
@@ -8,8 +12,48 @@ import (
 // User domain model
 type User struct {
 	gorm.Model
+	// TenantID owns the user, emails are unique per tenant
+	TenantID  uint              `gorm:"NOT NULL; DEFAULT:1; UNIQUE_INDEX:uix_users_tenant_email"`
 	FirstName string            `gorm:"size:255" `
 	LastName  string            `gorm:"size:255"`
-	Email     string            `gorm:"NOT NULL; UNIQUE_INDEX"`
+	Email     string            `gorm:"NOT NULL; UNIQUE_INDEX:uix_users_tenant_email"`
 	Voucher   []voucher.Voucher `gorm:"foreignKey:UserID"`
+	// Version counts the updates of the user, it backs the ETag
+	Version uint `gorm:"NOT NULL; DEFAULT:1"`
+	// Birthday is a date as YYYY-MM-DD, empty when unknown
+	Birthday string `gorm:"size:10"`
+	// Phone is a number in E.164 format, e.g. +4915112345678, vouchers are
+	// sent to it by SMS. Empty when unknown.
+	Phone string `gorm:"size:16"`
+	// Locale is the language vouchers are sent in, e.g. "de" or "de-CH".
+	// Empty uses the default locale.
+	Locale string `gorm:"size:16"`
+}
+
+// AnonymousEmail is the email a deleted user is left with, unique per user
+// so it never blocks the unique index and under the reserved .invalid TLD
+// so nothing is ever sent to it
+func AnonymousEmail(id uint) string {
+	return fmt.Sprintf("deleted-user-%d@anonymous.invalid", id)
+}
+
+// DateLayout is the layout of Birthday
+const DateLayout = "2006-01-02"
+
+// BirthdaySuffixes returns the "-MM-DD" endings of the birthdays falling
+// on day. Users born on February 29 have their birthday on February 28 of
+// other years.
+func BirthdaySuffixes(day time.Time) []string {
+	suffixes := []string{day.Format("-01-02")}
+	if day.Month() == time.February && day.Day() == 28 &&
+		time.Date(day.Year(), time.February, 29, 0, 0, 0, 0, time.UTC).Month() != time.February {
+		suffixes = append(suffixes, "-02-29")
+	}
+	return suffixes
+}
+
+// NormalizeEmail trims and lower-cases an email, emails are compared that
+// way
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
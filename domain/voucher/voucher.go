@@ -3,17 +3,31 @@ package voucher
 import (
 	"github.com/deepinbytes/go_voucher/domain/offer"
 	"github.com/jinzhu/gorm"
+	"strings"
 	"time"
 )
 
 // User domain model
 type Voucher struct {
 	gorm.Model
+	// TenantID owns the voucher, codes are unique per tenant
+	TenantID   uint         `gorm:"NOT NULL; DEFAULT:1; UNIQUE_INDEX:uix_vouchers_tenant_code" json:"tenant_id"`
 	UsedAt     time.Time    `json:"used_at"`
 	IsUsed     bool         `gorm:"default:false" json:"is_used"`
-	Code       string       `gorm:"NOT NULL; UNIQUE_INDEX " json:"code"`
+	Code       string       `gorm:"NOT NULL; UNIQUE_INDEX:uix_vouchers_tenant_code" json:"code"`
 	OfferID    uint         `gorm:"foreignKey:OfferID" json:"offer_id"`
-	UserID     uint         `json:"user_id "`
+	UserID     uint         `json:"user_id"`
 	ExpireTime time.Time    `json:"expiry_time"`
 	Offer      *offer.Offer `gorm:"foreignKey:OfferID" json:"offer"`
+	// DiscountAmount is the discount applied to the order the voucher was
+	// redeemed for, in minor units of DiscountCurrency. It is 0 when it was
+	// redeemed without an order total.
+	DiscountAmount   int64  `gorm:"NOT NULL; DEFAULT:0" json:"discount_amount,omitempty"`
+	DiscountCurrency string `gorm:"NOT NULL; DEFAULT:''; size:3" json:"discount_currency,omitempty"`
+}
+
+// NormalizeCode trims and upper-cases a voucher code, codes are generated
+// that way
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
 }
@@ -0,0 +1,52 @@
+package wallet
+
+import "time"
+
+// Statuses of the vouchers in a wallet
+const (
+	StatusActive  = "active"
+	StatusUsed    = "used"
+	StatusExpired = "expired"
+)
+
+// Statuses lists every status, in the order of the wallet groups
+var Statuses = []string{StatusActive, StatusUsed, StatusExpired}
+
+// Wallet groups the vouchers of a user by status. Groups not asked for
+// are nil.
+type Wallet struct {
+	UserID  uint   `json:"user_id"`
+	Active  *Group `json:"active,omitempty"`
+	Used    *Group `json:"used,omitempty"`
+	Expired *Group `json:"expired,omitempty"`
+}
+
+// Group is a page of the vouchers of a status, by ID
+type Group struct {
+	Vouchers []Item `json:"vouchers"`
+	// NextAfter is the after of the next page, 0 on the last one
+	NextAfter uint `json:"next_after,omitempty"`
+}
+
+// Item is a voucher as shown to its owner
+type Item struct {
+	ID         uint       `json:"id"`
+	Code       string     `json:"code"`
+	Status     string     `json:"status"`
+	Offer      *Offer     `json:"offer,omitempty"`
+	IssuedAt   time.Time  `json:"issued_at"`
+	ExpireTime time.Time  `json:"expire_time"`
+	UsedAt     *time.Time `json:"used_at,omitempty"`
+	// DaysToExpiry counts the days left to use an active voucher, 0 on
+	// its last day
+	DaysToExpiry *int `json:"days_to_expiry,omitempty"`
+}
+
+// Offer details of a voucher, missing once the offer is deleted
+type Offer struct {
+	ID                 uint   `json:"id"`
+	Name               string `json:"name"`
+	DiscountPercentage uint   `json:"discount_percentage"`
+	// Discount describes the discount to the user, e.g. "20% off"
+	Discount string `json:"discount"`
+}
@@ -0,0 +1,85 @@
+package graphqlserver
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+const (
+	// defaultPageSize is the page size when first is not given
+	defaultPageSize = 20
+	// maxPageSize bounds first, and the keys of one batched fetch
+	maxPageSize = 100
+)
+
+// pageArgs are the arguments of every connection field
+type pageArgs struct {
+	First *int32
+	After *string
+}
+
+// page returns the ID to list after and the page size. The cursor must be
+// one of kind, e.g. an offer cursor for offers.
+func (a pageArgs) page(kind string) (uint, int, error) {
+	limit := defaultPageSize
+	if a.First != nil {
+		if *a.First < 0 || *a.First > maxPageSize {
+			return 0, 0, invalid(fmt.Sprintf("first must be between 0 and %d", maxPageSize))
+		}
+		limit = int(*a.First)
+	}
+	if a.After == nil {
+		return 0, limit, nil
+	}
+	after, err := decodeCursor(kind, *a.After)
+	if err != nil {
+		return 0, 0, err
+	}
+	return after, limit, nil
+}
+
+type pageInfoResolver struct {
+	hasNext bool
+	end     *string
+}
+
+func (r *pageInfoResolver) HasNextPage() bool {
+	return r.hasNext
+}
+
+func (r *pageInfoResolver) EndCursor() *string {
+	return r.end
+}
+
+// cursor is the opaque cursor of the row of kind with id
+func cursor(kind string, id uint) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%d", kind, id)))
+}
+
+func decodeCursor(kind, c string) (uint, error) {
+	raw, err := base64.StdEncoding.DecodeString(c)
+	if err != nil || !strings.HasPrefix(string(raw), kind+":") {
+		return 0, invalid("after is not a valid " + kind + " cursor")
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(string(raw), kind+":"), 10, 64)
+	if err != nil {
+		return 0, invalid("after is not a valid " + kind + " cursor")
+	}
+	return uint(id), nil
+}
+
+func toID(id uint) graphql.ID {
+	return graphql.ID(strconv.FormatUint(uint64(id), 10))
+}
+
+func fromID(id graphql.ID) (uint, error) {
+	n, err := strconv.ParseUint(string(id), 10, 64)
+	if err != nil || n == 0 {
+		return 0, invalid(fmt.Sprintf("invalid id %q", string(id)))
+	}
+	return uint(n), nil
+}
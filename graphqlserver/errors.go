@@ -0,0 +1,102 @@
+package graphqlserver
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/deepinbytes/go_voucher/common/ratelimit"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// Error codes set in the extensions of errors
+const (
+	CodeNotFound           = "NOT_FOUND"
+	CodeUnauthenticated    = "UNAUTHENTICATED"
+	CodeForbidden          = "FORBIDDEN"
+	CodeFailedPrecondition = "FAILED_PRECONDITION"
+	CodeAlreadyExists      = "ALREADY_EXISTS"
+	CodeInvalidArgument    = "INVALID_ARGUMENT"
+	CodeResourceExhausted  = "RESOURCE_EXHAUSTED"
+	CodeInternal           = "INTERNAL"
+)
+
+// Error is a resolver error with a machine readable code in its extensions
+type Error struct {
+	Code    string
+	Message string
+	// RetryAfter is the number of seconds to wait before trying again, set
+	// with CodeResourceExhausted
+	RetryAfter int
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Extensions adds the code to the error in the response
+func (e *Error) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{"code": e.Code}
+	if e.RetryAfter > 0 {
+		ext["retry_after"] = e.RetryAfter
+	}
+	return ext
+}
+
+// toError maps errors of the services to coded errors
+func toError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	if le, ok := err.(*ratelimit.LimitedError); ok {
+		return &Error{Code: CodeResourceExhausted, Message: le.Msg, RetryAfter: le.RetrySeconds()}
+	}
+	code := CodeInternal
+	switch {
+	case gorm.IsRecordNotFoundError(err):
+		code = CodeNotFound
+	case err == voucherservice.ErrWrongUser:
+		code = CodeForbidden
+	case err == voucherservice.ErrUsed,
+		err == voucherservice.ErrExpired,
+		err == voucherservice.ErrNotUsed:
+		code = CodeFailedPrecondition
+	case isUniqueViolation(err):
+		code = CodeAlreadyExists
+	case isValidation(err):
+		code = CodeInvalidArgument
+	}
+	return &Error{Code: code, Message: err.Error()}
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// isUniqueViolation recognises unique index violations of every storage
+// backend
+func isUniqueViolation(err error) bool {
+	if errors.Is(err, memdb.ErrUniqueViolation) {
+		return true
+	}
+	if pqErr, ok := err.(*pq.Error); ok {
+		return pqErr.Code == "23505"
+	}
+	return strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// isValidation recognises the argument checks of the services, e.g.
+// "id param is required"
+func isValidation(err error) bool {
+	return strings.HasSuffix(err.Error(), "is required")
+}
+
+func invalid(msg string) error {
+	return &Error{Code: CodeInvalidArgument, Message: msg}
+}
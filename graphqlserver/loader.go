@@ -0,0 +1,220 @@
+package graphqlserver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+)
+
+// loader batches the keys loaded within wait of each other into a single
+// fetch, so resolving a field on every item of a list costs one repository
+// call instead of one per item. Results are kept for the whole request.
+type loader struct {
+	wait  time.Duration
+	max   int
+	fetch func(keys []uint) (map[uint]interface{}, error)
+
+	mu   sync.Mutex
+	cur  *batch
+	seen map[uint]*batch
+}
+
+type batch struct {
+	keys []uint
+	done chan struct{}
+	vals map[uint]interface{}
+	err  error
+}
+
+func newLoader(wait time.Duration, max int, fetch func(keys []uint) (map[uint]interface{}, error)) *loader {
+	return &loader{
+		wait:  wait,
+		max:   max,
+		fetch: fetch,
+		seen:  make(map[uint]*batch),
+	}
+}
+
+// load returns the value of key, nil when the fetch found none
+func (l *loader) load(ctx context.Context, key uint) (interface{}, error) {
+	l.mu.Lock()
+	b, ok := l.seen[key]
+	if !ok {
+		if l.cur == nil {
+			next := &batch{done: make(chan struct{})}
+			l.cur = next
+			time.AfterFunc(l.wait, func() { l.dispatch(next) })
+		}
+		b = l.cur
+		b.keys = append(b.keys, key)
+		l.seen[key] = b
+		if len(b.keys) >= l.max {
+			l.cur = nil
+			go l.run(b)
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-b.done:
+		return b.vals[key], b.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// dispatch runs b unless it already ran for being full
+func (l *loader) dispatch(b *batch) {
+	l.mu.Lock()
+	if l.cur != b {
+		l.mu.Unlock()
+		return
+	}
+	l.cur = nil
+	l.mu.Unlock()
+	l.run(b)
+}
+
+func (l *loader) run(b *batch) {
+	b.vals, b.err = l.fetch(b.keys)
+	close(b.done)
+}
+
+// loaders are the loaders of one request
+type loaders struct {
+	ctx  context.Context
+	svc  Services
+	wait time.Duration
+
+	offers *loader
+	users  *loader
+
+	mu       sync.Mutex
+	vouchers map[voucherPage]*loader
+}
+
+// voucherPage is a page of the vouchers of several offers or users. Pages
+// with other arguments are batched apart.
+type voucherPage struct {
+	byUser bool
+	after  uint
+	limit  int
+	used   string
+}
+
+type loadersKey struct{}
+
+func newLoaders(ctx context.Context, svc Services, wait time.Duration) *loaders {
+	ls := &loaders{
+		ctx:      ctx,
+		svc:      svc,
+		wait:     wait,
+		vouchers: make(map[voucherPage]*loader),
+	}
+	ls.offers = newLoader(wait, maxPageSize, func(ids []uint) (map[uint]interface{}, error) {
+		offers, err := svc.Offers.GetByIDs(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		vals := make(map[uint]interface{}, len(offers))
+		for _, o := range offers {
+			vals[o.ID] = o
+		}
+		return vals, nil
+	})
+	ls.users = newLoader(wait, maxPageSize, func(ids []uint) (map[uint]interface{}, error) {
+		users, err := svc.Users.GetByIDs(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		vals := make(map[uint]interface{}, len(users))
+		for _, u := range users {
+			vals[u.ID] = u
+		}
+		return vals, nil
+	})
+	return ls
+}
+
+func withLoaders(ctx context.Context, ls *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, ls)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+// offer returns the offer with id, nil when there is none
+func (ls *loaders) offer(ctx context.Context, id uint) (*offer.Offer, error) {
+	v, err := ls.offers.load(ctx, id)
+	if v == nil || err != nil {
+		return nil, err
+	}
+	return v.(*offer.Offer), nil
+}
+
+// user returns the user with id, nil when there is none
+func (ls *loaders) user(ctx context.Context, id uint) (*user.User, error) {
+	v, err := ls.users.load(ctx, id)
+	if v == nil || err != nil {
+		return nil, err
+	}
+	return v.(*user.User), nil
+}
+
+// voucherPage returns the page of the vouchers of the offer, or of the user
+// when byUser is set
+func (ls *loaders) voucherPage(ctx context.Context, byUser bool, id uint, opts voucherrepo.ListOptions) ([]*voucher.Voucher, error) {
+	key := voucherPage{byUser: byUser, after: opts.After, limit: opts.Limit}
+	if opts.IsUsed != nil {
+		key.used = "false"
+		if *opts.IsUsed {
+			key.used = "true"
+		}
+	}
+
+	ls.mu.Lock()
+	l, ok := ls.vouchers[key]
+	if !ok {
+		list := ls.svc.Vouchers.ListByOffers
+		if byUser {
+			list = ls.svc.Vouchers.ListByUsers
+		}
+		l = newLoader(ls.wait, maxPageSize, func(ids []uint) (map[uint]interface{}, error) {
+			vouchers, err := list(ls.ctx, ids, opts)
+			if err != nil {
+				return nil, err
+			}
+			pages := make(map[uint][]*voucher.Voucher, len(ids))
+			for _, v := range vouchers {
+				parent := v.OfferID
+				if byUser {
+					parent = v.UserID
+				}
+				pages[parent] = append(pages[parent], v)
+			}
+			vals := make(map[uint]interface{}, len(pages))
+			for id, page := range pages {
+				vals[id] = page
+			}
+			return vals, nil
+		})
+		ls.vouchers[key] = l
+	}
+	ls.mu.Unlock()
+
+	v, err := l.load(ctx, id)
+	if v == nil || err != nil {
+		return nil, err
+	}
+	return v.([]*voucher.Voucher), nil
+}
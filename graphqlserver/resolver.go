@@ -0,0 +1,371 @@
+package graphqlserver
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/deepinbytes/go_voucher/common/ratelimit"
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/jinzhu/gorm"
+)
+
+// resolver resolves the fields of Query and Mutation
+type resolver struct {
+	svc Services
+}
+
+func (r *resolver) Offer(ctx context.Context, args struct {
+	ID   *graphql.ID
+	Name *string
+}) (*offerResolver, error) {
+	var (
+		o   *offer.Offer
+		err error
+	)
+	switch {
+	case args.ID != nil:
+		id, idErr := fromID(*args.ID)
+		if idErr != nil {
+			return nil, idErr
+		}
+		o, err = r.svc.Offers.GetByID(ctx, id)
+	case args.Name != nil:
+		o, err = r.svc.Offers.GetByName(ctx, *args.Name)
+	default:
+		return nil, invalid("id or name is required")
+	}
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, toError(err)
+	}
+	return &offerResolver{o}, nil
+}
+
+func (r *resolver) Offers(ctx context.Context, args pageArgs) (*offerConnection, error) {
+	after, limit, err := args.page("offer")
+	if err != nil {
+		return nil, err
+	}
+	offers, err := r.svc.Offers.ListPage(ctx, after, limit+1)
+	if err != nil {
+		return nil, toError(err)
+	}
+	c := &offerConnection{offers: offers}
+	if len(offers) > limit {
+		c.offers, c.hasNext = offers[:limit], true
+	}
+	return c, nil
+}
+
+func (r *resolver) Voucher(ctx context.Context, args struct {
+	ID   *graphql.ID
+	Code *string
+}) (*voucherResolver, error) {
+	var (
+		v   *voucher.Voucher
+		err error
+	)
+	switch {
+	case args.ID != nil:
+		id, idErr := fromID(*args.ID)
+		if idErr != nil {
+			return nil, idErr
+		}
+		v, err = r.svc.Vouchers.GetByID(ctx, id)
+	case args.Code != nil:
+		err = r.guarded(ctx, "", func(ctx context.Context) error {
+			var err error
+			v, err = r.svc.Vouchers.UseCode(ctx, voucher.NormalizeCode(*args.Code))
+			ratelimit.RecordAttempt(ctx, err, voucherservice.Guessed)
+			return err
+		})
+	default:
+		return nil, invalid("id or code is required")
+	}
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, toError(err)
+	}
+	return &voucherResolver{v}, nil
+}
+
+func (r *resolver) User(ctx context.Context, args struct {
+	ID    *graphql.ID
+	Email *string
+}) (*userResolver, error) {
+	var (
+		u   *user.User
+		err error
+	)
+	switch {
+	case args.ID != nil:
+		id, idErr := fromID(*args.ID)
+		if idErr != nil {
+			return nil, idErr
+		}
+		u, err = r.svc.Users.GetByID(ctx, id)
+	case args.Email != nil:
+		u, err = r.svc.Users.GetByEmail(ctx, user.NormalizeEmail(*args.Email))
+	default:
+		return nil, invalid("id or email is required")
+	}
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, toError(err)
+	}
+	return &userResolver{u}, nil
+}
+
+func (r *resolver) Users(ctx context.Context, args pageArgs) (*userConnection, error) {
+	after, limit, err := args.page("user")
+	if err != nil {
+		return nil, err
+	}
+	users, err := r.svc.Users.ListPage(ctx, after, limit+1)
+	if err != nil {
+		return nil, toError(err)
+	}
+	c := &userConnection{users: users}
+	if len(users) > limit {
+		c.users, c.hasNext = users[:limit], true
+	}
+	return c, nil
+}
+
+func (r *resolver) CreateOffer(ctx context.Context, args struct {
+	Name               string
+	DiscountPercentage int32
+}) (*offerResolver, error) {
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+	if args.Name == "" {
+		return nil, invalid("name is required")
+	}
+	if args.DiscountPercentage < 0 {
+		return nil, invalid("discountPercentage must not be negative")
+	}
+	o := &offer.Offer{
+		Name:               args.Name,
+		DiscountPercentage: uint(args.DiscountPercentage),
+	}
+	if err := r.svc.Offers.Create(ctx, o); err != nil {
+		return nil, toError(err)
+	}
+	return &offerResolver{o}, nil
+}
+
+func (r *resolver) UpdateOffer(ctx context.Context, args struct {
+	ID                 graphql.ID
+	Name               *string
+	DiscountPercentage *int32
+}) (*offerResolver, error) {
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+	id, err := fromID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	o, err := r.svc.Offers.GetByID(ctx, id)
+	if err != nil {
+		return nil, toError(err)
+	}
+	if args.Name != nil {
+		if *args.Name == "" {
+			return nil, invalid("name must not be empty")
+		}
+		o.Name = *args.Name
+	}
+	if args.DiscountPercentage != nil {
+		if *args.DiscountPercentage < 0 {
+			return nil, invalid("discountPercentage must not be negative")
+		}
+		o.DiscountPercentage = uint(*args.DiscountPercentage)
+	}
+	if err := r.svc.Offers.Update(ctx, o); err != nil {
+		return nil, toError(err)
+	}
+	return &offerResolver{o}, nil
+}
+
+func (r *resolver) CreateUser(ctx context.Context, args struct {
+	FirstName *string
+	LastName  *string
+	Email     string
+}) (*userResolver, error) {
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+	email := user.NormalizeEmail(args.Email)
+	if email == "" {
+		return nil, invalid("email is required")
+	}
+	u := &user.User{Email: email}
+	if args.FirstName != nil {
+		u.FirstName = *args.FirstName
+	}
+	if args.LastName != nil {
+		u.LastName = *args.LastName
+	}
+	if err := r.svc.Users.Create(ctx, u); err != nil {
+		return nil, toError(err)
+	}
+	return &userResolver{u}, nil
+}
+
+func (r *resolver) IssueVouchers(ctx context.Context, args struct {
+	OfferID    graphql.ID
+	ExpireTime graphql.Time
+}) (int32, error) {
+	if err := authorize(ctx); err != nil {
+		return 0, err
+	}
+	id, err := fromID(args.OfferID)
+	if err != nil {
+		return 0, err
+	}
+	o, err := r.svc.Offers.GetByID(ctx, id)
+	if err != nil {
+		return 0, toError(err)
+	}
+	users, err := r.svc.Users.ListAll(ctx)
+	if err != nil {
+		return 0, toError(err)
+	}
+	n, err := r.svc.Vouchers.Generate(ctx, o.ID, users, args.ExpireTime.Time)
+	if err != nil {
+		return 0, toError(err)
+	}
+	return int32(n), nil
+}
+
+func (r *resolver) IssueVoucher(ctx context.Context, args struct {
+	OfferID    graphql.ID
+	UserID     graphql.ID
+	ExpireTime graphql.Time
+}) (*voucherResolver, error) {
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+	offerID, err := fromID(args.OfferID)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := fromID(args.UserID)
+	if err != nil {
+		return nil, err
+	}
+	o, err := r.svc.Offers.GetByID(ctx, offerID)
+	if err != nil {
+		return nil, toError(err)
+	}
+	u, err := r.svc.Users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, toError(err)
+	}
+	v, err := r.svc.Vouchers.Issue(ctx, o.ID, u.ID, args.ExpireTime.Time)
+	if err != nil {
+		return nil, toError(err)
+	}
+	return &voucherResolver{v}, nil
+}
+
+// RedeemVoucher follows the REST redeem flow: look the code up, check its
+// offer and owner still exist, then redeem it for the given email. It is
+// rate limited and locked out like the REST redeem routes, and codes and
+// emails are normalized the same way.
+func (r *resolver) RedeemVoucher(ctx context.Context, args struct {
+	Code  string
+	Email string
+}) (*voucherResolver, error) {
+	var res *voucherResolver
+	err := r.guarded(ctx, args.Email, func(ctx context.Context) error {
+		var err error
+		res, err = r.redeem(ctx, voucher.NormalizeCode(args.Code), user.NormalizeEmail(args.Email))
+		return err
+	})
+	if err != nil {
+		return nil, toError(err)
+	}
+	return res, nil
+}
+
+func (r *resolver) ReverseRedemption(ctx context.Context, args struct {
+	Code string
+}) (*voucherResolver, error) {
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+	v, err := r.svc.Vouchers.UseCode(ctx, voucher.NormalizeCode(args.Code))
+	if err != nil {
+		return nil, toError(err)
+	}
+	if err := r.svc.Vouchers.Reverse(ctx, v); err != nil {
+		return nil, toError(err)
+	}
+	return &voucherResolver{v}, nil
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// guarded runs fn, which looks a voucher code up, within the limits and
+// the lockout of the REST redeem routes. A request looks up at most
+// maxCodes codes, however many aliases it asks for.
+func (r *resolver) guarded(ctx context.Context, email string, fn func(ctx context.Context) error) error {
+	c := callerFromContext(ctx)
+	if atomic.AddInt32(&c.codes, 1) > maxCodes {
+		return &Error{Code: CodeResourceExhausted, Message: fmt.Sprintf("at most %d codes per request", maxCodes)}
+	}
+	if r.svc.Guard == nil {
+		return fn(ctx)
+	}
+	rc := c.Caller
+	rc.Email = email
+	return r.svc.Guard.Attempt(ctx, rc, fn)
+}
+
+// authorize rejects calls without an API key of the tenant, every mutation
+// but redeemVoucher needs one
+func authorize(ctx context.Context) error {
+	if tenant.KeyFromContext(ctx) == 0 {
+		return &Error{Code: CodeUnauthenticated, Message: "API key required"}
+	}
+	return nil
+}
+
+func (r *resolver) redeem(ctx context.Context, code, email string) (*voucherResolver, error) {
+	v, err := r.svc.Vouchers.UseCode(ctx, code)
+	if err != nil {
+		ratelimit.RecordAttempt(ctx, err, voucherservice.Guessed)
+		return nil, toError(err)
+	}
+	if _, err := r.svc.Offers.GetByID(ctx, v.OfferID); err != nil {
+		return nil, toError(err)
+	}
+	u, err := r.svc.Users.GetByID(ctx, v.UserID)
+	if err != nil {
+		return nil, toError(err)
+	}
+	err = r.svc.Vouchers.Redeem(ctx, v, u, email)
+	ratelimit.RecordAttempt(ctx, err, voucherservice.Guessed)
+	if err != nil {
+		return nil, toError(err)
+	}
+	return &voucherResolver{v}, nil
+}
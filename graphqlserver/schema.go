@@ -0,0 +1,99 @@
+package graphqlserver
+
+// schema is served at /graphql. Lists are Relay style connections paged
+// with opaque cursors.
+const schema = `
+schema {
+	query: Query
+	mutation: Mutation
+}
+
+scalar Time
+
+type Query {
+	# offer by id or name, null when there is none
+	offer(id: ID, name: String): Offer
+	offers(first: Int, after: String): OfferConnection!
+	# voucher by id or code, null when there is none
+	voucher(id: ID, code: String): Voucher
+	# user by id or email, null when there is none
+	user(id: ID, email: String): User
+	users(first: Int, after: String): UserConnection!
+}
+
+type Mutation {
+	createOffer(name: String!, discountPercentage: Int!): Offer!
+	updateOffer(id: ID!, name: String, discountPercentage: Int): Offer!
+	createUser(firstName: String, lastName: String, email: String!): User!
+	# issues a voucher of the offer to every user, returns how many
+	issueVouchers(offerId: ID!, expireTime: Time!): Int!
+	issueVoucher(offerId: ID!, userId: ID!, expireTime: Time!): Voucher!
+	redeemVoucher(code: String!, email: String!): Voucher!
+	reverseRedemption(code: String!): Voucher!
+}
+
+type Offer {
+	id: ID!
+	name: String!
+	discountPercentage: Int!
+	createdAt: Time!
+	updatedAt: Time!
+	# used filters on redemption when set
+	vouchers(first: Int, after: String, used: Boolean): VoucherConnection!
+}
+
+type Voucher {
+	id: ID!
+	code: String!
+	isUsed: Boolean!
+	usedAt: Time
+	expireTime: Time!
+	offer: Offer
+	user: User
+}
+
+type User {
+	id: ID!
+	firstName: String!
+	lastName: String!
+	email: String!
+	vouchers(first: Int, after: String, used: Boolean): VoucherConnection!
+	# the vouchers the user redeemed
+	redemptions(first: Int, after: String): VoucherConnection!
+}
+
+type PageInfo {
+	hasNextPage: Boolean!
+	endCursor: String
+}
+
+type OfferConnection {
+	edges: [OfferEdge!]!
+	pageInfo: PageInfo!
+}
+
+type OfferEdge {
+	cursor: String!
+	node: Offer!
+}
+
+type VoucherConnection {
+	edges: [VoucherEdge!]!
+	pageInfo: PageInfo!
+}
+
+type VoucherEdge {
+	cursor: String!
+	node: Voucher!
+}
+
+type UserConnection {
+	edges: [UserEdge!]!
+	pageInfo: PageInfo!
+}
+
+type UserEdge {
+	cursor: String!
+	node: User!
+}
+`
@@ -0,0 +1,130 @@
+package graphqlserver
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/ratelimit"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/userservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+const (
+	// batchWait is how long a loader waits for sibling fields before
+	// fetching. The resolvers of a list run concurrently, so siblings
+	// arrive well within it.
+	batchWait = 2 * time.Millisecond
+	// maxDepth bounds the nesting of queries, deep enough for
+	// offer > vouchers > user > redemptions as each connection takes two
+	// levels
+	maxDepth = 12
+	// maxQueryLength bounds the size of queries, and so how many aliased
+	// fields one can ask for
+	maxQueryLength = 8 << 10
+	// maxCodes bounds the voucher codes a request looks up or redeems, as
+	// many as an order redeems at once
+	maxCodes = 10
+)
+
+// Services are the services the GraphQL API is served from, the same ones
+// as the REST API
+type Services struct {
+	Offers   offerservice.OfferService
+	Vouchers voucherservice.VoucherService
+	Users    userservice.UserService
+	// Guard limits the redeemVoucher mutation like the REST redeem routes,
+	// it is not limited when nil
+	Guard *ratelimit.Guard
+}
+
+// Handler serves GraphQL queries posted as JSON
+type Handler struct {
+	schema *graphql.Schema
+	svc    Services
+	wait   time.Duration
+}
+
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// New parses the schema against the resolvers, it panics when they do not
+// match
+func New(svc Services) *Handler {
+	return &Handler{
+		schema: graphql.MustParseSchema(schema, &resolver{svc: svc},
+			graphql.MaxDepth(maxDepth),
+			// as many resolvers as the largest page so a page loads its
+			// fields in one batch
+			graphql.MaxParallelism(maxPageSize),
+		),
+		svc:  svc,
+		wait: batchWait,
+	}
+}
+
+// ServeHTTP executes the query with loaders of its own, so repository reads
+// are only batched and cached within a request
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "body must be a JSON GraphQL request", http.StatusBadRequest)
+		return
+	}
+	if len(req.Query) > maxQueryLength {
+		http.Error(w, "query is too long", http.StatusBadRequest)
+		return
+	}
+	ctx := withLoaders(r.Context(), newLoaders(r.Context(), h.svc, h.wait))
+	c := &caller{Caller: ratelimit.Caller{IP: clientIP(r)}}
+	if id := tenant.KeyFromContext(ctx); id != 0 {
+		c.APIKey = strconv.FormatUint(uint64(id), 10)
+	}
+	ctx = context.WithValue(ctx, callerKey{}, c)
+	resp := h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+type callerKey struct{}
+
+// caller is who sends the request, as limited by the redeem guard, with
+// the number of codes it looked up so far
+type caller struct {
+	ratelimit.Caller
+	codes int32
+}
+
+func callerFromContext(ctx context.Context) *caller {
+	if c, ok := ctx.Value(callerKey{}).(*caller); ok {
+		return c
+	}
+	return &caller{}
+}
+
+// clientIP is the IP of the connection, like the REST rate limits it
+// ignores forwarding headers as clients could set them
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		return ""
+	}
+	return host
+}
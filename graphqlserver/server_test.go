@@ -0,0 +1,380 @@
+package graphqlserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/ratelimit"
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+	"github.com/deepinbytes/go_voucher/repositories/offerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/userrepo"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/userservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// calls counts the repository calls by method
+type calls struct {
+	sync.Mutex
+	n map[string]int
+}
+
+func (c *calls) add(method string) {
+	c.Lock()
+	defer c.Unlock()
+	c.n[method]++
+}
+
+func (c *calls) get(method string) int {
+	c.Lock()
+	defer c.Unlock()
+	return c.n[method]
+}
+
+type countingOffers struct {
+	offerrepo.Repo
+	calls *calls
+}
+
+func (r *countingOffers) GetByID(ctx context.Context, id uint) (*offer.Offer, error) {
+	r.calls.add("Offers.GetByID")
+	return r.Repo.GetByID(ctx, id)
+}
+
+func (r *countingOffers) GetByIDs(ctx context.Context, ids []uint) ([]*offer.Offer, error) {
+	r.calls.add("Offers.GetByIDs")
+	return r.Repo.GetByIDs(ctx, ids)
+}
+
+type countingUsers struct {
+	userrepo.Repo
+	calls *calls
+}
+
+func (r *countingUsers) GetByID(ctx context.Context, id uint) (*user.User, error) {
+	r.calls.add("Users.GetByID")
+	return r.Repo.GetByID(ctx, id)
+}
+
+func (r *countingUsers) GetByIDs(ctx context.Context, ids []uint) ([]*user.User, error) {
+	r.calls.add("Users.GetByIDs")
+	return r.Repo.GetByIDs(ctx, ids)
+}
+
+type countingVouchers struct {
+	voucherrepo.Repo
+	calls *calls
+}
+
+func (r *countingVouchers) ListByOffers(ctx context.Context, ids []uint, opts voucherrepo.ListOptions) ([]*voucher.Voucher, error) {
+	r.calls.add("Vouchers.ListByOffers")
+	return r.Repo.ListByOffers(ctx, ids, opts)
+}
+
+func (r *countingVouchers) ListByUsers(ctx context.Context, ids []uint, opts voucherrepo.ListOptions) ([]*voucher.Voucher, error) {
+	r.calls.add("Vouchers.ListByUsers")
+	return r.Repo.ListByUsers(ctx, ids, opts)
+}
+
+type fixture struct {
+	handler *Handler
+	svc     Services
+	calls   *calls
+}
+
+// setup serves the API from services over counted memory repositories
+func setup() *fixture {
+	db := memdb.New()
+	c := &calls{n: make(map[string]int)}
+	svc := Services{
+		Offers:   offerservice.NewOfferService(&countingOffers{offerrepo.NewMemoryOfferRepo(db), c}),
+		Vouchers: voucherservice.NewVoucherService(&countingVouchers{voucherrepo.NewMemoryVoucherRepo(db), c}),
+		Users:    userservice.NewUserService(&countingUsers{userrepo.NewMemoryUserRepo(db), c}),
+		Guard: ratelimit.NewGuard(
+			ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.LockoutPolicy{MaxFailures: 3, BaseCooldown: time.Minute}),
+			ratelimit.Limits{
+				IP:     ratelimit.Limit{Rate: 1, Burst: 100},
+				APIKey: ratelimit.Limit{Rate: 1, Burst: 100},
+				Email:  ratelimit.Limit{Rate: 1, Burst: 100},
+			}),
+	}
+	return &fixture{handler: New(svc), svc: svc, calls: c}
+}
+
+// seed adds offers and users with a voucher of every offer for every user
+func (f *fixture) seed(t *testing.T, offers, users int) {
+	ctx := context.Background()
+	var us []*user.User
+	for i := 1; i <= users; i++ {
+		u := &user.User{FirstName: "User", LastName: fmt.Sprint(i), Email: fmt.Sprintf("user%d@cc.cc", i)}
+		require.Nil(t, f.svc.Users.Create(ctx, u))
+		us = append(us, u)
+	}
+	for i := 1; i <= offers; i++ {
+		o := &offer.Offer{Name: fmt.Sprintf("offer%d", i), DiscountPercentage: uint(10 * i)}
+		require.Nil(t, f.svc.Offers.Create(ctx, o))
+		_, err := f.svc.Vouchers.Generate(ctx, o.ID, us, time.Now().Add(time.Hour))
+		require.Nil(t, err)
+	}
+}
+
+type response struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// do sends the query with an API key of the tenant
+func (f *fixture) do(t *testing.T, query string, vars map[string]interface{}) response {
+	return f.doWith(t, tenant.NewKeyContext(context.Background(), 1), query, vars)
+}
+
+func (f *fixture) doWith(t *testing.T, ctx context.Context, query string, vars map[string]interface{}) response {
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": vars})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	f.handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp response
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestQuery(t *testing.T) {
+	t.Run("Batches nested reads", func(t *testing.T) {
+		f := setup()
+		f.seed(t, 3, 4)
+
+		resp := f.do(t, `{
+			offers {
+				edges { node {
+					name
+					vouchers { edges { node {
+						code
+						offer { name }
+						user { email redemptions { edges { node { id } } } }
+					} } }
+				} }
+			}
+		}`, nil)
+
+		require.Empty(t, resp.Errors)
+		offers := resp.Data["offers"].(map[string]interface{})["edges"].([]interface{})
+		assert.Len(t, offers, 3)
+		vouchers := offers[2].(map[string]interface{})["node"].(map[string]interface{})["vouchers"].(map[string]interface{})["edges"].([]interface{})
+		assert.Len(t, vouchers, 4)
+		node := vouchers[0].(map[string]interface{})["node"].(map[string]interface{})
+		assert.Equal(t, "offer3", node["offer"].(map[string]interface{})["name"])
+		assert.Equal(t, "user1@cc.cc", node["user"].(map[string]interface{})["email"])
+
+		// 12 vouchers, each with an offer, a user and its redemptions
+		assert.Equal(t, 1, f.calls.get("Vouchers.ListByOffers"))
+		assert.Equal(t, 1, f.calls.get("Offers.GetByIDs"))
+		assert.Equal(t, 1, f.calls.get("Users.GetByIDs"))
+		assert.Equal(t, 1, f.calls.get("Vouchers.ListByUsers"))
+		assert.Equal(t, 0, f.calls.get("Offers.GetByID"))
+		assert.Equal(t, 0, f.calls.get("Users.GetByID"))
+	})
+
+	t.Run("Pages with cursors", func(t *testing.T) {
+		f := setup()
+		f.seed(t, 0, 5)
+		query := `query($after: String) {
+			users(first: 2, after: $after) {
+				edges { cursor node { email } }
+				pageInfo { hasNextPage endCursor }
+			}
+		}`
+
+		var emails []string
+		vars := map[string]interface{}{}
+		for pages := 0; pages < 5; pages++ {
+			resp := f.do(t, query, vars)
+			require.Empty(t, resp.Errors)
+			users := resp.Data["users"].(map[string]interface{})
+			for _, e := range users["edges"].([]interface{}) {
+				emails = append(emails, e.(map[string]interface{})["node"].(map[string]interface{})["email"].(string))
+			}
+			info := users["pageInfo"].(map[string]interface{})
+			if !info["hasNextPage"].(bool) {
+				break
+			}
+			vars["after"] = info["endCursor"]
+		}
+
+		assert.Equal(t, []string{
+			"user1@cc.cc", "user2@cc.cc", "user3@cc.cc", "user4@cc.cc", "user5@cc.cc",
+		}, emails)
+	})
+
+	t.Run("Rejects cursors of other types", func(t *testing.T) {
+		f := setup()
+
+		resp := f.do(t, `{ offers(after: "`+cursor("user", 1)+`") { edges { cursor } } }`, nil)
+
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "after is not a valid offer cursor", resp.Errors[0].Message)
+		assert.Equal(t, CodeInvalidArgument, resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("Looks up at most maxCodes codes", func(t *testing.T) {
+		f := setup()
+		query := "{"
+		for i := 0; i <= maxCodes; i++ {
+			query += fmt.Sprintf(` v%d: voucher(code: "NOPE%04d") { id }`, i, i)
+		}
+		resp := f.do(t, query+" }", nil)
+
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, CodeResourceExhausted, resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("Returns null for unknown rows", func(t *testing.T) {
+		f := setup()
+
+		resp := f.do(t, `{ offer(id: "42") { name } user(email: "nobody@cc.cc") { email } }`, nil)
+
+		assert.Empty(t, resp.Errors)
+		assert.Nil(t, resp.Data["offer"])
+		assert.Nil(t, resp.Data["user"])
+	})
+}
+
+func TestMutation(t *testing.T) {
+	t.Run("Issues, redeems and reverses a voucher", func(t *testing.T) {
+		f := setup()
+		resp := f.do(t, `mutation {
+			createOffer(name: "summer", discountPercentage: 15) { id }
+			createUser(firstName: "Alice", email: "alice@cc.cc") { id }
+		}`, nil)
+		require.Empty(t, resp.Errors)
+		offerID := resp.Data["createOffer"].(map[string]interface{})["id"]
+		userID := resp.Data["createUser"].(map[string]interface{})["id"]
+
+		resp = f.do(t, `mutation($offer: ID!, $user: ID!, $expire: Time!) {
+			issueVoucher(offerId: $offer, userId: $user, expireTime: $expire) { code isUsed }
+		}`, map[string]interface{}{
+			"offer":  offerID,
+			"user":   userID,
+			"expire": time.Now().Add(time.Hour).Format(time.RFC3339),
+		})
+		require.Empty(t, resp.Errors)
+		code := resp.Data["issueVoucher"].(map[string]interface{})["code"]
+
+		resp = f.do(t, `mutation($code: String!) {
+			redeemVoucher(code: $code, email: "bob@cc.cc") { isUsed }
+		}`, map[string]interface{}{"code": code})
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, CodeForbidden, resp.Errors[0].Extensions["code"])
+
+		resp = f.do(t, `mutation($code: String!) {
+			redeemVoucher(code: $code, email: " Alice@cc.cc") { isUsed usedAt offer { name } }
+		}`, map[string]interface{}{"code": strings.ToLower(code.(string))})
+		require.Empty(t, resp.Errors)
+		redeemed := resp.Data["redeemVoucher"].(map[string]interface{})
+		assert.Equal(t, true, redeemed["isUsed"])
+		assert.NotNil(t, redeemed["usedAt"])
+		assert.Equal(t, "summer", redeemed["offer"].(map[string]interface{})["name"])
+
+		resp = f.do(t, `mutation($code: String!) {
+			reverseRedemption(code: $code) { isUsed usedAt }
+		}`, map[string]interface{}{"code": code})
+		require.Empty(t, resp.Errors)
+		assert.Equal(t, false, resp.Data["reverseRedemption"].(map[string]interface{})["isUsed"])
+		assert.Nil(t, resp.Data["reverseRedemption"].(map[string]interface{})["usedAt"])
+	})
+
+	t.Run("Locks out guessing redemptions", func(t *testing.T) {
+		f := setup()
+		redeem := func(email string) response {
+			return f.do(t, `mutation($email: String!) {
+				redeemVoucher(code: "NOPE0000", email: $email) { isUsed }
+			}`, map[string]interface{}{"email": email})
+		}
+
+		for i := 0; i < 3; i++ {
+			resp := redeem("mallory@cc.cc")
+			require.Len(t, resp.Errors, 1)
+			require.Equal(t, CodeNotFound, resp.Errors[0].Extensions["code"])
+		}
+
+		resp := redeem("Mallory@cc.cc")
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, CodeResourceExhausted, resp.Errors[0].Extensions["code"])
+		assert.Equal(t, float64(60), resp.Errors[0].Extensions["retry_after"])
+
+		resp = redeem("alice@cc.cc")
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, CodeNotFound, resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("Needs an API key but to redeem", func(t *testing.T) {
+		f := setup()
+		f.seed(t, 1, 1)
+		ctx := context.Background()
+		vs, err := f.svc.Vouchers.ListByUsers(ctx, []uint{1}, voucherrepo.ListOptions{})
+		require.Nil(t, err)
+		code := vs[0].Code
+
+		for _, query := range []string{
+			`mutation { createOffer(name: "winter", discountPercentage: 5) { id } }`,
+			`mutation { reverseRedemption(code: "` + code + `") { isUsed } }`,
+		} {
+			resp := f.doWith(t, ctx, query, nil)
+			require.Len(t, resp.Errors, 1, query)
+			assert.Equal(t, CodeUnauthenticated, resp.Errors[0].Extensions["code"], query)
+		}
+
+		resp := f.doWith(t, ctx, `mutation { redeemVoucher(code: "`+code+`", email: "user1@cc.cc") { isUsed } }`, nil)
+		require.Empty(t, resp.Errors)
+	})
+
+	t.Run("Updates the given fields only", func(t *testing.T) {
+		f := setup()
+		f.seed(t, 1, 0)
+
+		resp := f.do(t, `mutation { updateOffer(id: "1", discountPercentage: 50) { name discountPercentage } }`, nil)
+
+		require.Empty(t, resp.Errors)
+		assert.Equal(t, map[string]interface{}{"name": "offer1", "discountPercentage": float64(50)},
+			resp.Data["updateOffer"])
+	})
+
+	t.Run("Reports duplicates", func(t *testing.T) {
+		f := setup()
+		f.seed(t, 0, 1)
+
+		resp := f.do(t, `mutation { createUser(email: "user1@cc.cc") { id } }`, nil)
+
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, CodeAlreadyExists, resp.Errors[0].Extensions["code"])
+	})
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.RemoteAddr = "10.0.0.7:4321"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	req.Header.Set("X-Real-Ip", "5.6.7.8")
+
+	assert.Equal(t, "10.0.0.7", clientIP(req))
+}
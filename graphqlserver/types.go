@@ -0,0 +1,242 @@
+package graphqlserver
+
+import (
+	"context"
+
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+type offerResolver struct {
+	o *offer.Offer
+}
+
+func (r *offerResolver) ID() graphql.ID {
+	return toID(r.o.ID)
+}
+
+func (r *offerResolver) Name() string {
+	return r.o.Name
+}
+
+func (r *offerResolver) DiscountPercentage() int32 {
+	return int32(r.o.DiscountPercentage)
+}
+
+func (r *offerResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.o.CreatedAt}
+}
+
+func (r *offerResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: r.o.UpdatedAt}
+}
+
+func (r *offerResolver) Vouchers(ctx context.Context, args voucherArgs) (*voucherConnection, error) {
+	return vouchersOf(ctx, false, r.o.ID, args.pageArgs, args.Used)
+}
+
+type voucherResolver struct {
+	v *voucher.Voucher
+}
+
+func (r *voucherResolver) ID() graphql.ID {
+	return toID(r.v.ID)
+}
+
+func (r *voucherResolver) Code() string {
+	return r.v.Code
+}
+
+func (r *voucherResolver) IsUsed() bool {
+	return r.v.IsUsed
+}
+
+func (r *voucherResolver) UsedAt() *graphql.Time {
+	if r.v.UsedAt.IsZero() {
+		return nil
+	}
+	return &graphql.Time{Time: r.v.UsedAt}
+}
+
+func (r *voucherResolver) ExpireTime() graphql.Time {
+	return graphql.Time{Time: r.v.ExpireTime}
+}
+
+func (r *voucherResolver) Offer(ctx context.Context) (*offerResolver, error) {
+	o, err := loadersFrom(ctx).offer(ctx, r.v.OfferID)
+	if o == nil || err != nil {
+		return nil, toError(err)
+	}
+	return &offerResolver{o}, nil
+}
+
+func (r *voucherResolver) User(ctx context.Context) (*userResolver, error) {
+	u, err := loadersFrom(ctx).user(ctx, r.v.UserID)
+	if u == nil || err != nil {
+		return nil, toError(err)
+	}
+	return &userResolver{u}, nil
+}
+
+type userResolver struct {
+	u *user.User
+}
+
+func (r *userResolver) ID() graphql.ID {
+	return toID(r.u.ID)
+}
+
+func (r *userResolver) FirstName() string {
+	return r.u.FirstName
+}
+
+func (r *userResolver) LastName() string {
+	return r.u.LastName
+}
+
+func (r *userResolver) Email() string {
+	return r.u.Email
+}
+
+func (r *userResolver) Vouchers(ctx context.Context, args voucherArgs) (*voucherConnection, error) {
+	return vouchersOf(ctx, true, r.u.ID, args.pageArgs, args.Used)
+}
+
+func (r *userResolver) Redemptions(ctx context.Context, args pageArgs) (*voucherConnection, error) {
+	used := true
+	return vouchersOf(ctx, true, r.u.ID, args, &used)
+}
+
+type voucherArgs struct {
+	pageArgs
+	Used *bool
+}
+
+type offerConnection struct {
+	offers  []*offer.Offer
+	hasNext bool
+}
+
+func (c *offerConnection) Edges() []*offerEdge {
+	edges := make([]*offerEdge, len(c.offers))
+	for i, o := range c.offers {
+		edges[i] = &offerEdge{o}
+	}
+	return edges
+}
+
+func (c *offerConnection) PageInfo() *pageInfoResolver {
+	info := &pageInfoResolver{hasNext: c.hasNext}
+	if n := len(c.offers); n > 0 {
+		end := cursor("offer", c.offers[n-1].ID)
+		info.end = &end
+	}
+	return info
+}
+
+type offerEdge struct {
+	o *offer.Offer
+}
+
+func (e *offerEdge) Cursor() string {
+	return cursor("offer", e.o.ID)
+}
+
+func (e *offerEdge) Node() *offerResolver {
+	return &offerResolver{e.o}
+}
+
+type voucherConnection struct {
+	vouchers []*voucher.Voucher
+	hasNext  bool
+}
+
+func (c *voucherConnection) Edges() []*voucherEdge {
+	edges := make([]*voucherEdge, len(c.vouchers))
+	for i, v := range c.vouchers {
+		edges[i] = &voucherEdge{v}
+	}
+	return edges
+}
+
+func (c *voucherConnection) PageInfo() *pageInfoResolver {
+	info := &pageInfoResolver{hasNext: c.hasNext}
+	if n := len(c.vouchers); n > 0 {
+		end := cursor("voucher", c.vouchers[n-1].ID)
+		info.end = &end
+	}
+	return info
+}
+
+type voucherEdge struct {
+	v *voucher.Voucher
+}
+
+func (e *voucherEdge) Cursor() string {
+	return cursor("voucher", e.v.ID)
+}
+
+func (e *voucherEdge) Node() *voucherResolver {
+	return &voucherResolver{e.v}
+}
+
+type userConnection struct {
+	users   []*user.User
+	hasNext bool
+}
+
+func (c *userConnection) Edges() []*userEdge {
+	edges := make([]*userEdge, len(c.users))
+	for i, u := range c.users {
+		edges[i] = &userEdge{u}
+	}
+	return edges
+}
+
+func (c *userConnection) PageInfo() *pageInfoResolver {
+	info := &pageInfoResolver{hasNext: c.hasNext}
+	if n := len(c.users); n > 0 {
+		end := cursor("user", c.users[n-1].ID)
+		info.end = &end
+	}
+	return info
+}
+
+type userEdge struct {
+	u *user.User
+}
+
+func (e *userEdge) Cursor() string {
+	return cursor("user", e.u.ID)
+}
+
+func (e *userEdge) Node() *userResolver {
+	return &userResolver{e.u}
+}
+
+// vouchersOf pages the vouchers of the offer, or of the user when byUser is
+// set, batched with the same page of its siblings
+func vouchersOf(ctx context.Context, byUser bool, id uint, args pageArgs, used *bool) (*voucherConnection, error) {
+	after, limit, err := args.page("voucher")
+	if err != nil {
+		return nil, err
+	}
+	// one more than asked tells whether there is a next page
+	vouchers, err := loadersFrom(ctx).voucherPage(ctx, byUser, id, voucherrepo.ListOptions{
+		After:  after,
+		Limit:  limit + 1,
+		IsUsed: used,
+	})
+	if err != nil {
+		return nil, toError(err)
+	}
+	c := &voucherConnection{vouchers: vouchers}
+	if len(vouchers) > limit {
+		c.vouchers, c.hasNext = vouchers[:limit], true
+	}
+	return c, nil
+}
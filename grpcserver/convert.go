@@ -0,0 +1,59 @@
+package grpcserver
+
+import (
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/proto/voucherpb"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func toOffer(o *offer.Offer) *voucherpb.Offer {
+	return &voucherpb.Offer{
+		Id:                 uint64(o.ID),
+		Name:               o.Name,
+		DiscountPercentage: uint32(o.DiscountPercentage),
+		CreatedAt:          timestamp(o.CreatedAt),
+		UpdatedAt:          timestamp(o.UpdatedAt),
+	}
+}
+
+func toVoucher(v *voucher.Voucher) *voucherpb.Voucher {
+	out := &voucherpb.Voucher{
+		Id:         uint64(v.ID),
+		Code:       v.Code,
+		OfferId:    uint64(v.OfferID),
+		UserId:     uint64(v.UserID),
+		IsUsed:     v.IsUsed,
+		UsedAt:     timestamp(v.UsedAt),
+		ExpireTime: timestamp(v.ExpireTime),
+	}
+	if v.Offer != nil {
+		out.DiscountPercentage = uint32(v.Offer.DiscountPercentage)
+	}
+	return out
+}
+
+func toUser(u *user.User) *voucherpb.User {
+	out := &voucherpb.User{
+		Id:        uint64(u.ID),
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Email:     u.Email,
+	}
+	for i := range u.Voucher {
+		out.Vouchers = append(out.Vouchers, toVoucher(&u.Voucher[i]))
+	}
+	return out
+}
+
+// timestamp leaves zero times unset
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
@@ -0,0 +1,65 @@
+package grpcserver
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toStatus maps errors of the services to gRPC status errors
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case gorm.IsRecordNotFoundError(err):
+		return status.Error(codes.NotFound, err.Error())
+	case err == voucherservice.ErrWrongUser:
+		return status.Error(codes.PermissionDenied, err.Error())
+	case err == voucherservice.ErrUsed,
+		err == voucherservice.ErrExpired,
+		err == voucherservice.ErrNotUsed:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case isUniqueViolation(err):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case isValidation(err):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// isUniqueViolation recognises unique index violations of every storage
+// backend
+func isUniqueViolation(err error) bool {
+	if errors.Is(err, memdb.ErrUniqueViolation) {
+		return true
+	}
+	if pqErr, ok := err.(*pq.Error); ok {
+		return pqErr.Code == "23505"
+	}
+	return strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// isValidation recognises the argument checks of the services, e.g.
+// "id param is required"
+func isValidation(err error) bool {
+	return strings.HasSuffix(err.Error(), "is required")
+}
+
+func invalid(msg string) error {
+	return status.Error(codes.InvalidArgument, msg)
+}
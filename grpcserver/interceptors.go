@@ -0,0 +1,223 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/keys"
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/common/ratelimit"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/proto/voucherpb"
+	"github.com/deepinbytes/go_voucher/services/tenantservice"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// RequestIDKey is the metadata key of the request ID, like the
+	// X-Request-ID header of the REST API
+	RequestIDKey = "x-request-id"
+	// AuthorizationKey is the metadata key of the API key, optionally
+	// prefixed with "Bearer "
+	AuthorizationKey = "authorization"
+	// TenantKey is the metadata key of the tenant slug, like the X-Tenant
+	// header of the REST API
+	TenantKey = "x-tenant"
+	// TenantAPIKey is the metadata key of the API key of a tenant, like
+	// the X-API-Key header of the REST API
+	TenantAPIKey = "x-api-key"
+	// RetryAfterKey is the header metadata key of the seconds to wait
+	// after a ResourceExhausted call, like the Retry-After header of the
+	// REST API
+	RetryAfterKey = "retry-after"
+
+	healthService = "/grpc.health.v1.Health/"
+)
+
+// Logging tags every call with a request ID, taken from the x-request-id
+// metadata or generated, puts a call scoped logger into the context and
+// writes one access log entry when the call completes
+func Logging(base *logger.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+
+		id := first(ctx, RequestIDKey)
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+		grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, id))
+
+		l := base.With(logger.Fields{
+			"request_id": id,
+			"method":     info.FullMethod,
+		})
+		ctx = logger.NewContext(ctx, l)
+
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err)
+		f := logger.Fields{
+			"code":       code.String(),
+			"latency_ms": float64(time.Since(start)) / float64(time.Millisecond),
+		}
+		if p, ok := peer.FromContext(ctx); ok {
+			f["client_ip"] = p.Addr.String()
+		}
+		if key := apiKey(ctx); key != "" {
+			sum := sha256.Sum256([]byte(key))
+			f["actor"] = "key:" + hex.EncodeToString(sum[:4])
+		}
+		if err != nil {
+			f["error"] = status.Convert(err).Message()
+		}
+
+		switch code {
+		case codes.OK:
+			l.Info("call", f)
+		case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+			l.Error("call", f)
+		default:
+			l.Warn("call", f)
+		}
+		return resp, err
+	}
+}
+
+// Recovery turns panics into an Internal error and logs them with the stack
+func Recovery() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.FromContext(ctx).Error("panic", logger.Fields{
+					"panic": fmt.Sprint(r),
+					"stack": string(debug.Stack()),
+				})
+				err = status.Error(codes.Internal, "Internal Server Error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// Auth rejects calls without one of allowed in the authorization metadata
+// with Unauthenticated. Allowed keys may be given hashed, as "sha256:"
+// followed by their hex SHA-256. Health checks need no key.
+func Auth(allowed []string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if strings.HasPrefix(info.FullMethod, healthService) {
+			return handler(ctx, req)
+		}
+		key := apiKey(ctx)
+		if key == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing API key")
+		}
+		for _, k := range allowed {
+			if keys.Matches(k, key) {
+				return handler(ctx, req)
+			}
+		}
+		return nil, status.Error(codes.Unauthenticated, "invalid API key")
+	}
+}
+
+// Tenant scopes the call context to the tenant of the x-api-key metadata,
+// which the x-tenant metadata has to match, and notes the key in it. Calls
+// without a key are for the default tenant. Health checks are not scoped.
+func Tenant(tenants tenantservice.TenantService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if strings.HasPrefix(info.FullMethod, healthService) {
+			return handler(ctx, req)
+		}
+		t, err := tenants.Resolve(ctx, first(ctx, TenantKey), first(ctx, TenantAPIKey))
+		switch err {
+		case nil:
+		case tenantservice.ErrUnknownTenant:
+			return nil, status.Error(codes.NotFound, err.Error())
+		case tenantservice.ErrInvalidAPIKey, tenantservice.ErrAPIKeyRequired:
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		case tenantservice.ErrTenantMismatch:
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		default:
+			return nil, toStatus(err)
+		}
+		ctx = tenant.NewKeyContext(tenant.NewContext(ctx, t.ID), t.KeyID)
+		ctx = logger.NewContext(ctx, logger.FromContext(ctx).With(logger.Fields{"tenant": t.Slug}))
+		return handler(ctx, req)
+	}
+}
+
+// RedeemGuard rate limits RedeemVoucher calls and locks their email out
+// after repeated guesses, like the REST redeem routes. Rejected calls fail
+// with ResourceExhausted and a retry-after header in seconds.
+func RedeemGuard(g *ratelimit.Guard) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		r, ok := req.(*voucherpb.RedeemVoucherRequest)
+		if !ok {
+			return handler(ctx, req)
+		}
+		c := ratelimit.Caller{IP: peerIP(ctx), Email: r.Email}
+		if id := tenant.KeyFromContext(ctx); id != 0 {
+			c.APIKey = strconv.FormatUint(uint64(id), 10)
+		}
+		var resp interface{}
+		err := g.Attempt(ctx, c, func(ctx context.Context) error {
+			var err error
+			resp, err = handler(ctx, req)
+			return err
+		})
+		if le, ok := err.(*ratelimit.LimitedError); ok {
+			grpc.SetHeader(ctx, metadata.Pairs(RetryAfterKey, strconv.Itoa(le.RetrySeconds())))
+			return nil, status.Error(codes.ResourceExhausted, le.Msg)
+		}
+		return resp, err
+	}
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func first(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+func apiKey(ctx context.Context) string {
+	return strings.TrimPrefix(first(ctx, AuthorizationKey), "Bearer ")
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
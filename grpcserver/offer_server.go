@@ -0,0 +1,83 @@
+package grpcserver
+
+import (
+	"context"
+
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/proto/voucherpb"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/userservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+)
+
+type offerServer struct {
+	voucherpb.UnimplementedOfferServiceServer
+
+	offerSvc   offerservice.OfferService
+	usrSvc     userservice.UserService
+	voucherSvc voucherservice.VoucherService
+}
+
+func (s *offerServer) CreateOffer(ctx context.Context, req *voucherpb.CreateOfferRequest) (*voucherpb.Offer, error) {
+	if req.Name == "" {
+		return nil, invalid("name is required")
+	}
+	o := &offer.Offer{
+		Name:               req.Name,
+		DiscountPercentage: uint(req.DiscountPercentage),
+	}
+	if err := s.offerSvc.Create(ctx, o); err != nil {
+		return nil, toStatus(err)
+	}
+	return toOffer(o), nil
+}
+
+func (s *offerServer) GetOffer(ctx context.Context, req *voucherpb.GetOfferRequest) (*voucherpb.Offer, error) {
+	var (
+		o   *offer.Offer
+		err error
+	)
+	switch key := req.Key.(type) {
+	case *voucherpb.GetOfferRequest_Id:
+		o, err = s.offerSvc.GetByID(ctx, uint(key.Id))
+	case *voucherpb.GetOfferRequest_Name:
+		o, err = s.offerSvc.GetByName(ctx, key.Name)
+	default:
+		return nil, invalid("id or name is required")
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toOffer(o), nil
+}
+
+func (s *offerServer) ListOffers(ctx context.Context, req *voucherpb.ListOffersRequest) (*voucherpb.ListOffersResponse, error) {
+	offers, err := s.offerSvc.ListAll(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &voucherpb.ListOffersResponse{Offers: make([]*voucherpb.Offer, 0, len(offers))}
+	for _, o := range offers {
+		resp.Offers = append(resp.Offers, toOffer(o))
+	}
+	return resp, nil
+}
+
+func (s *offerServer) IssueVouchers(ctx context.Context, req *voucherpb.IssueVouchersRequest) (*voucherpb.IssueVouchersResponse, error) {
+	if req.ExpireTime == nil {
+		return nil, invalid("expire_time is required")
+	}
+	o, err := s.offerSvc.GetByID(ctx, uint(req.OfferId))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	users, err := s.usrSvc.ListAll(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	n, err := s.voucherSvc.Generate(ctx, o.ID, users, req.ExpireTime.AsTime())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &voucherpb.IssueVouchersResponse{Issued: int32(n)}, nil
+}
@@ -0,0 +1,66 @@
+package grpcserver
+
+import (
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/common/ratelimit"
+	"github.com/deepinbytes/go_voucher/proto/voucherpb"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/tenantservice"
+	"github.com/deepinbytes/go_voucher/services/userservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Services are the services the gRPC API is served from, the same ones
+// as the REST API
+type Services struct {
+	Offers   offerservice.OfferService
+	Vouchers voucherservice.VoucherService
+	Users    userservice.UserService
+	// Tenants resolves the tenant of every call, all calls go to the
+	// default tenant when nil
+	Tenants tenantservice.TenantService
+	// Guard limits RedeemVoucher calls like the REST redeem routes, they
+	// are not limited when nil
+	Guard *ratelimit.Guard
+}
+
+// New returns a server with the offer, voucher and user services and the
+// standard health service registered. Every call except health checks
+// needs one of apiKeys and is scoped to its tenant; calls are logged with
+// base. RedeemVoucher calls are limited by the guard of svc.
+func New(svc Services, apiKeys []string, base *logger.Logger) (*grpc.Server, *health.Server) {
+	interceptors := []grpc.UnaryServerInterceptor{
+		Logging(base),
+		Recovery(),
+		Auth(apiKeys),
+	}
+	if svc.Tenants != nil {
+		interceptors = append(interceptors, Tenant(svc.Tenants))
+	}
+	if svc.Guard != nil {
+		interceptors = append(interceptors, RedeemGuard(svc.Guard))
+	}
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+
+	voucherpb.RegisterOfferServiceServer(srv, &offerServer{
+		offerSvc:   svc.Offers,
+		usrSvc:     svc.Users,
+		voucherSvc: svc.Vouchers,
+	})
+	voucherpb.RegisterVoucherServiceServer(srv, &voucherServer{
+		voucherSvc: svc.Vouchers,
+		usrSvc:     svc.Users,
+		offerSvc:   svc.Offers,
+	})
+	voucherpb.RegisterUserServiceServer(srv, &userServer{
+		usrSvc: svc.Users,
+	})
+
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	return srv, hs
+}
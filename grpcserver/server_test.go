@@ -0,0 +1,314 @@
+package grpcserver
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/keys"
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/common/ratelimit"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/proto/voucherpb"
+	"github.com/deepinbytes/go_voucher/repositories/apikeyrepo"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+	"github.com/deepinbytes/go_voucher/repositories/offerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/tenantrepo"
+	"github.com/deepinbytes/go_voucher/repositories/userrepo"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/tenantservice"
+	"github.com/deepinbytes/go_voucher/services/userservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const testKey = "test-key"
+
+type clients struct {
+	offers   voucherpb.OfferServiceClient
+	vouchers voucherpb.VoucherServiceClient
+	users    voucherpb.UserServiceClient
+	health   healthpb.HealthClient
+	logs     *bytes.Buffer
+	acme     *tenant.Tenant
+}
+
+// setup serves the API from services over the memory repositories
+func setup(t *testing.T) (*clients, func()) {
+	db := memdb.New()
+	logs := &bytes.Buffer{}
+	tenants := tenantservice.NewTenantService(tenantrepo.NewMemoryTenantRepo(db), apikeyrepo.NewMemoryAPIKeyRepo(db),
+		tenantservice.KeyPolicy{Grace: time.Hour})
+	acme := &tenant.Tenant{Name: "Acme", Slug: "acme"}
+	require.Nil(t, tenants.Create(context.Background(), acme))
+	srv, _ := New(Services{
+		Offers:   offerservice.NewOfferService(offerrepo.NewMemoryOfferRepo(db)),
+		Vouchers: voucherservice.NewVoucherService(voucherrepo.NewMemoryVoucherRepo(db)),
+		Users:    userservice.NewUserService(userrepo.NewMemoryUserRepo(db)),
+		Tenants:  tenants,
+		Guard: ratelimit.NewGuard(
+			ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.LockoutPolicy{MaxFailures: 3, BaseCooldown: time.Minute}),
+			ratelimit.Limits{
+				IP:     ratelimit.Limit{Rate: 1, Burst: 100},
+				APIKey: ratelimit.Limit{Rate: 1, Burst: 100},
+				Email:  ratelimit.Limit{Rate: 1, Burst: 100},
+			}),
+	}, []string{keys.HashPrefix + keys.Hash("hashed-key"), testKey}, logger.New(logs, logger.InfoLevel))
+
+	ln := bufconn.Listen(1 << 20)
+	go srv.Serve(ln)
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.Dial()
+		}))
+	require.Nil(t, err)
+
+	return &clients{
+		offers:   voucherpb.NewOfferServiceClient(conn),
+		vouchers: voucherpb.NewVoucherServiceClient(conn),
+		users:    voucherpb.NewUserServiceClient(conn),
+		health:   healthpb.NewHealthClient(conn),
+		logs:     logs,
+		acme:     acme,
+	}, func() {
+		conn.Close()
+		srv.Stop()
+	}
+}
+
+func authed(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), AuthorizationKey, "Bearer "+key)
+}
+
+func TestAuth(t *testing.T) {
+	c, stop := setup(t)
+	defer stop()
+
+	t.Run("Rejects calls without a key", func(t *testing.T) {
+		_, err := c.offers.ListOffers(context.Background(), &voucherpb.ListOffersRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Rejects unknown keys", func(t *testing.T) {
+		_, err := c.offers.ListOffers(authed("nope"), &voucherpb.ListOffersRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Accepts configured keys", func(t *testing.T) {
+		_, err := c.offers.ListOffers(authed(testKey), &voucherpb.ListOffersRequest{})
+		assert.Nil(t, err)
+	})
+
+	t.Run("Accepts hashed keys", func(t *testing.T) {
+		_, err := c.offers.ListOffers(authed("hashed-key"), &voucherpb.ListOffersRequest{})
+		assert.Nil(t, err)
+	})
+
+	t.Run("Serves health checks without a key", func(t *testing.T) {
+		resp, err := c.health.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		assert.Nil(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+	})
+}
+
+func TestRedemption(t *testing.T) {
+	c, stop := setup(t)
+	defer stop()
+	ctx := authed(testKey)
+
+	o, err := c.offers.CreateOffer(ctx, &voucherpb.CreateOfferRequest{Name: "Summer", DiscountPercentage: 15})
+	require.Nil(t, err)
+	u, err := c.users.CreateUser(ctx, &voucherpb.CreateUserRequest{Email: "alice@cc.cc"})
+	require.Nil(t, err)
+	v, err := c.vouchers.IssueVoucher(ctx, &voucherpb.IssueVoucherRequest{
+		OfferId:    o.Id,
+		UserId:     u.Id,
+		ExpireTime: timestamppb.New(time.Now().Add(time.Hour)),
+	})
+	require.Nil(t, err)
+	assert.Len(t, v.Code, 8)
+
+	t.Run("Denies redemption by another user", func(t *testing.T) {
+		_, err := c.vouchers.RedeemVoucher(ctx, &voucherpb.RedeemVoucherRequest{Code: v.Code, Email: "bob@cc.cc"})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("Finds codes and emails as REST does", func(t *testing.T) {
+		got, err := c.vouchers.GetVoucher(ctx, &voucherpb.GetVoucherRequest{
+			Key: &voucherpb.GetVoucherRequest_Code{Code: " " + strings.ToLower(v.Code) + " "}})
+		require.Nil(t, err)
+		assert.Equal(t, v.Id, got.Id)
+
+		user, err := c.users.GetUser(ctx, &voucherpb.GetUserRequest{Key: &voucherpb.GetUserRequest_Email{Email: " Alice@CC.cc"}})
+		require.Nil(t, err)
+		assert.Equal(t, u.Id, user.Id)
+	})
+
+	t.Run("Redeems once", func(t *testing.T) {
+		redeemed, err := c.vouchers.RedeemVoucher(ctx, &voucherpb.RedeemVoucherRequest{
+			Code: strings.ToLower(v.Code), Email: "Alice@cc.cc"})
+		require.Nil(t, err)
+		assert.True(t, redeemed.IsUsed)
+		assert.NotNil(t, redeemed.UsedAt)
+		assert.Equal(t, uint32(15), redeemed.DiscountPercentage)
+
+		_, err = c.vouchers.RedeemVoucher(ctx, &voucherpb.RedeemVoucherRequest{Code: v.Code, Email: "alice@cc.cc"})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("Reverses a redemption once", func(t *testing.T) {
+		reversed, err := c.vouchers.ReverseRedemption(ctx, &voucherpb.ReverseRedemptionRequest{Code: v.Code})
+		require.Nil(t, err)
+		assert.False(t, reversed.IsUsed)
+		assert.Nil(t, reversed.UsedAt)
+
+		_, err = c.vouchers.ReverseRedemption(ctx, &voucherpb.ReverseRedemptionRequest{Code: v.Code})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("Lists unused vouchers of a user", func(t *testing.T) {
+		got, err := c.users.GetUser(ctx, &voucherpb.GetUserRequest{Key: &voucherpb.GetUserRequest_Email{Email: "alice@cc.cc"}})
+		require.Nil(t, err)
+		if assert.Len(t, got.Vouchers, 1) {
+			assert.Equal(t, v.Code, got.Vouchers[0].Code)
+			assert.Equal(t, uint32(15), got.Vouchers[0].DiscountPercentage)
+		}
+	})
+
+	t.Run("Issues vouchers of an offer to every user", func(t *testing.T) {
+		_, err := c.users.CreateUser(ctx, &voucherpb.CreateUserRequest{Email: "bob@cc.cc"})
+		require.Nil(t, err)
+
+		resp, err := c.offers.IssueVouchers(ctx, &voucherpb.IssueVouchersRequest{
+			OfferId:    o.Id,
+			ExpireTime: timestamppb.New(time.Now().Add(time.Hour)),
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, int32(2), resp.Issued)
+	})
+}
+
+func TestTenant(t *testing.T) {
+	c, stop := setup(t)
+	defer stop()
+	acme := metadata.AppendToOutgoingContext(authed(testKey), TenantKey, "acme", TenantAPIKey, c.acme.APIKey)
+
+	_, err := c.offers.CreateOffer(acme, &voucherpb.CreateOfferRequest{Name: "Summer", DiscountPercentage: 10})
+	require.Nil(t, err)
+	bySlug := metadata.AppendToOutgoingContext(authed(testKey), TenantKey, "acme")
+	_, err = c.offers.ListOffers(bySlug, &voucherpb.ListOffersRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "a slug alone does not authenticate")
+
+	resp, err := c.offers.ListOffers(authed(testKey), &voucherpb.ListOffersRequest{})
+	require.Nil(t, err)
+	assert.Empty(t, resp.Offers)
+	byKey := metadata.AppendToOutgoingContext(authed(testKey), TenantAPIKey, c.acme.APIKey)
+	resp, err = c.offers.ListOffers(byKey, &voucherpb.ListOffersRequest{})
+	require.Nil(t, err)
+	assert.Len(t, resp.Offers, 1)
+
+	unknown := metadata.AppendToOutgoingContext(authed(testKey), TenantKey, "globex")
+	_, err = c.offers.ListOffers(unknown, &voucherpb.ListOffersRequest{})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	badKey := metadata.AppendToOutgoingContext(authed(testKey), TenantAPIKey, "nope")
+	_, err = c.offers.ListOffers(badKey, &voucherpb.ListOffersRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestErrors(t *testing.T) {
+	c, stop := setup(t)
+	defer stop()
+	ctx := authed(testKey)
+
+	cases := []struct {
+		name string
+		call func() error
+		code codes.Code
+	}{
+		{"Missing key", func() error {
+			_, err := c.offers.GetOffer(ctx, &voucherpb.GetOfferRequest{})
+			return err
+		}, codes.InvalidArgument},
+		{"Zero id", func() error {
+			_, err := c.users.GetUser(ctx, &voucherpb.GetUserRequest{Key: &voucherpb.GetUserRequest_Id{}})
+			return err
+		}, codes.InvalidArgument},
+		{"Unknown offer", func() error {
+			_, err := c.offers.GetOffer(ctx, &voucherpb.GetOfferRequest{Key: &voucherpb.GetOfferRequest_Name{Name: "Nope"}})
+			return err
+		}, codes.NotFound},
+		{"Unknown code", func() error {
+			_, err := c.vouchers.RedeemVoucher(ctx, &voucherpb.RedeemVoucherRequest{Code: "NOPE0000", Email: "alice@cc.cc"})
+			return err
+		}, codes.NotFound},
+		{"Duplicate email", func() error {
+			c.users.CreateUser(ctx, &voucherpb.CreateUserRequest{Email: "alice@cc.cc"})
+			_, err := c.users.CreateUser(ctx, &voucherpb.CreateUserRequest{Email: "alice@cc.cc"})
+			return err
+		}, codes.AlreadyExists},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.code, status.Code(tc.call()))
+		})
+	}
+}
+
+func TestRedeemGuard(t *testing.T) {
+	c, stop := setup(t)
+	defer stop()
+	ctx := authed(testKey)
+
+	for i := 0; i < 3; i++ {
+		_, err := c.vouchers.RedeemVoucher(ctx, &voucherpb.RedeemVoucherRequest{Code: "NOPE0000", Email: "mallory@cc.cc"})
+		require.Equal(t, codes.NotFound, status.Code(err))
+	}
+
+	var header metadata.MD
+	_, err := c.vouchers.RedeemVoucher(ctx, &voucherpb.RedeemVoucherRequest{Code: "NOPE0000", Email: "Mallory@cc.cc"},
+		grpc.Header(&header))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"60"}, header.Get(RetryAfterKey))
+
+	_, err = c.vouchers.RedeemVoucher(ctx, &voucherpb.RedeemVoucherRequest{Code: "NOPE0000", Email: "alice@cc.cc"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestLogging(t *testing.T) {
+	c, stop := setup(t)
+	defer stop()
+
+	var header metadata.MD
+	ctx := metadata.AppendToOutgoingContext(authed(testKey), RequestIDKey, "req-1")
+	c.offers.ListOffers(ctx, &voucherpb.ListOffersRequest{}, grpc.Header(&header))
+
+	assert.Equal(t, []string{"req-1"}, header.Get(RequestIDKey))
+	assert.Contains(t, c.logs.String(), `"request_id":"req-1"`)
+	assert.Contains(t, c.logs.String(), `"method":"/voucher.v1.OfferService/ListOffers"`)
+	assert.Contains(t, c.logs.String(), `"code":"OK"`)
+	assert.NotContains(t, c.logs.String(), testKey)
+}
+
+func TestRecovery(t *testing.T) {
+	interceptor := Recovery()
+	info := &grpc.UnaryServerInfo{FullMethod: "/test"}
+
+	_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("boom")
+	})
+
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
@@ -0,0 +1,62 @@
+package grpcserver
+
+import (
+	"context"
+
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/proto/voucherpb"
+	"github.com/deepinbytes/go_voucher/services/userservice"
+)
+
+type userServer struct {
+	voucherpb.UnimplementedUserServiceServer
+
+	usrSvc userservice.UserService
+}
+
+func (s *userServer) CreateUser(ctx context.Context, req *voucherpb.CreateUserRequest) (*voucherpb.User, error) {
+	email := user.NormalizeEmail(req.Email)
+	if email == "" {
+		return nil, invalid("email is required")
+	}
+	u := &user.User{
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Email:     email,
+	}
+	if err := s.usrSvc.Create(ctx, u); err != nil {
+		return nil, toStatus(err)
+	}
+	return toUser(u), nil
+}
+
+func (s *userServer) GetUser(ctx context.Context, req *voucherpb.GetUserRequest) (*voucherpb.User, error) {
+	var (
+		u   *user.User
+		err error
+	)
+	switch key := req.Key.(type) {
+	case *voucherpb.GetUserRequest_Id:
+		u, err = s.usrSvc.GetByID(ctx, uint(key.Id))
+	case *voucherpb.GetUserRequest_Email:
+		u, err = s.usrSvc.GetByEmail(ctx, user.NormalizeEmail(key.Email))
+	default:
+		return nil, invalid("id or email is required")
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toUser(u), nil
+}
+
+func (s *userServer) ListUsers(ctx context.Context, req *voucherpb.ListUsersRequest) (*voucherpb.ListUsersResponse, error) {
+	users, err := s.usrSvc.ListAll(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &voucherpb.ListUsersResponse{Users: make([]*voucherpb.User, 0, len(users))}
+	for _, u := range users {
+		resp.Users = append(resp.Users, toUser(u))
+	}
+	return resp, nil
+}
@@ -0,0 +1,96 @@
+package grpcserver
+
+import (
+	"context"
+
+	"github.com/deepinbytes/go_voucher/common/ratelimit"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/proto/voucherpb"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/userservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+)
+
+type voucherServer struct {
+	voucherpb.UnimplementedVoucherServiceServer
+
+	voucherSvc voucherservice.VoucherService
+	usrSvc     userservice.UserService
+	offerSvc   offerservice.OfferService
+}
+
+func (s *voucherServer) GetVoucher(ctx context.Context, req *voucherpb.GetVoucherRequest) (*voucherpb.Voucher, error) {
+	var (
+		v   *voucher.Voucher
+		err error
+	)
+	switch key := req.Key.(type) {
+	case *voucherpb.GetVoucherRequest_Id:
+		v, err = s.voucherSvc.GetByID(ctx, uint(key.Id))
+	case *voucherpb.GetVoucherRequest_Code:
+		v, err = s.voucherSvc.UseCode(ctx, voucher.NormalizeCode(key.Code))
+	default:
+		return nil, invalid("id or code is required")
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toVoucher(v), nil
+}
+
+func (s *voucherServer) IssueVoucher(ctx context.Context, req *voucherpb.IssueVoucherRequest) (*voucherpb.Voucher, error) {
+	if req.ExpireTime == nil {
+		return nil, invalid("expire_time is required")
+	}
+	o, err := s.offerSvc.GetByID(ctx, uint(req.OfferId))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	u, err := s.usrSvc.GetByID(ctx, uint(req.UserId))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	v, err := s.voucherSvc.Issue(ctx, o.ID, u.ID, req.ExpireTime.AsTime())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toVoucher(v), nil
+}
+
+// RedeemVoucher follows the REST redeem flow: look the code up, check its
+// offer and owner still exist, then redeem it for the given email. Codes
+// and emails are normalized like REST does.
+func (s *voucherServer) RedeemVoucher(ctx context.Context, req *voucherpb.RedeemVoucherRequest) (*voucherpb.Voucher, error) {
+	v, err := s.voucherSvc.UseCode(ctx, voucher.NormalizeCode(req.Code))
+	if err != nil {
+		ratelimit.RecordAttempt(ctx, err, voucherservice.Guessed)
+		return nil, toStatus(err)
+	}
+	o, err := s.offerSvc.GetByID(ctx, v.OfferID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	u, err := s.usrSvc.GetByID(ctx, v.UserID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	err = s.voucherSvc.Redeem(ctx, v, u, user.NormalizeEmail(req.Email))
+	ratelimit.RecordAttempt(ctx, err, voucherservice.Guessed)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	v.Offer = o
+	return toVoucher(v), nil
+}
+
+func (s *voucherServer) ReverseRedemption(ctx context.Context, req *voucherpb.ReverseRedemptionRequest) (*voucherpb.Voucher, error) {
+	v, err := s.voucherSvc.UseCode(ctx, voucher.NormalizeCode(req.Code))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if err := s.voucherSvc.Reverse(ctx, v); err != nil {
+		return nil, toStatus(err)
+	}
+	return toVoucher(v), nil
+}
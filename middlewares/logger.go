@@ -0,0 +1,122 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// RequestIDHeader carries the request ID in and out
+	RequestIDHeader = "X-Request-ID"
+	// ActorKey is the gin context key holding who performs the request
+	ActorKey = "actor"
+)
+
+// Logger tags every request with an ID, taken from X-Request-ID or
+// generated, puts a request scoped logger into the request context and
+// writes one access log entry when the request completes
+func Logger(base *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+
+		l := base.With(logger.Fields{
+			"request_id": id,
+			"method":     c.Request.Method,
+			"route":      route(c),
+		})
+		c.Request = c.Request.WithContext(logger.NewContext(c.Request.Context(), l))
+
+		c.Next()
+
+		f := logger.Fields{
+			"status":     c.Writer.Status(),
+			"latency_ms": float64(time.Since(start)) / float64(time.Millisecond),
+			"client_ip":  c.ClientIP(),
+			"path":       c.Request.URL.Path,
+		}
+		if actor := actor(c); actor != "" {
+			f["actor"] = actor
+		}
+		if len(c.Errors) > 0 {
+			f["error"] = c.Errors.String()
+		}
+
+		l = logger.FromContext(c.Request.Context())
+		switch status := c.Writer.Status(); {
+		case status >= http.StatusInternalServerError:
+			l.Error("request", f)
+		case status >= http.StatusBadRequest:
+			l.Warn("request", f)
+		default:
+			l.Info("request", f)
+		}
+	}
+}
+
+// Recovery turns panics into a 500 response and logs them with the stack
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.FromContext(c.Request.Context()).Error("panic", logger.Fields{
+					"panic": fmt.Sprint(r),
+					"stack": string(debug.Stack()),
+				})
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"code": http.StatusInternalServerError,
+					"msg":  "Internal Server Error",
+					"data": nil,
+				})
+			}
+		}()
+		c.Next()
+	}
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+func route(c *gin.Context) string {
+	if r := c.FullPath(); r != "" {
+		return r
+	}
+	return "unmatched"
+}
+
+// actor returns who performs the request: the value set under ActorKey by
+// an auth middleware, or else a fingerprint of the API key so the key
+// itself never reaches the logs
+func actor(c *gin.Context) string {
+	if a := c.GetString(ActorKey); a != "" {
+		return a
+	}
+	if key := c.GetHeader("Authorization"); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:4])
+	}
+	return ""
+}
@@ -0,0 +1,100 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func lastEntry(buf *bytes.Buffer) map[string]interface{} {
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	e := map[string]interface{}{}
+	json.Unmarshal([]byte(lines[len(lines)-1]), &e)
+	return e
+}
+
+func TestLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Propagates the request ID", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		router := gin.New()
+		router.Use(Logger(logger.New(buf, logger.InfoLevel)))
+		router.GET("/offer/:id", func(c *gin.Context) {
+			logger.FromContext(c.Request.Context()).Info("inside")
+			c.String(http.StatusOK, "ok")
+		})
+
+		req, _ := http.NewRequest("GET", "/offer/1", nil)
+		req.Header.Set(RequestIDHeader, "req-1")
+		w := performRequestWith(router, req)
+
+		assert.Equal(t, "req-1", w.Header().Get(RequestIDHeader))
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, 2)
+		for _, line := range lines {
+			assert.Contains(t, line, `"request_id":"req-1"`)
+		}
+
+		e := lastEntry(buf)
+		assert.Equal(t, "request", e["msg"])
+		assert.Equal(t, "/offer/:id", e["route"])
+		assert.Equal(t, float64(http.StatusOK), e["status"])
+		assert.Contains(t, e, "latency_ms")
+	})
+
+	t.Run("Generates a request ID", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		router := gin.New()
+		router.Use(Logger(logger.New(buf, logger.InfoLevel)))
+		router.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+
+		w := performRequest(router, "GET", "/ping", "")
+
+		assert.Len(t, w.Header().Get(RequestIDHeader), 32)
+		assert.Equal(t, w.Header().Get(RequestIDHeader), lastEntry(buf)["request_id"])
+	})
+
+	t.Run("Logs errors and a fingerprint of the API key", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		router := gin.New()
+		router.Use(Logger(logger.New(buf, logger.InfoLevel)))
+		router.GET("/fail", func(c *gin.Context) {
+			c.Error(http.ErrBodyNotAllowed)
+			c.String(http.StatusInternalServerError, "")
+		})
+
+		req, _ := http.NewRequest("GET", "/fail", nil)
+		req.Header.Set("Authorization", "secret-key")
+		performRequestWith(router, req)
+
+		e := lastEntry(buf)
+		assert.Equal(t, "error", e["level"])
+		assert.Contains(t, e["error"], http.ErrBodyNotAllowed.Error())
+		assert.Regexp(t, `^key:[0-9a-f]{8}$`, e["actor"])
+		assert.NotContains(t, buf.String(), "secret-key")
+	})
+}
+
+func TestRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Turns panics into 500", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		router := gin.New()
+		router.Use(Logger(logger.New(buf, logger.InfoLevel)), Recovery())
+		router.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+		w := performRequest(router, "GET", "/panic", "")
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, buf.String(), `"panic":"boom"`)
+	})
+}
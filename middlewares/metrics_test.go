@@ -0,0 +1,41 @@
+package middlewares
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/deepinbytes/go_voucher/common/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Observes latency by route template", func(t *testing.T) {
+		router := gin.New()
+		router.Use(Metrics())
+		router.GET("/offer/:id", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+		performRequest(router, "GET", "/offer/1", "")
+		performRequest(router, "GET", "/offer/2", "")
+		performRequest(router, "GET", "/nowhere", "")
+
+		families, _ := metrics.Registry.Gather()
+		counts := map[string]uint64{}
+		for _, f := range families {
+			if f.GetName() != "voucher_http_request_duration_seconds" {
+				continue
+			}
+			for _, m := range f.GetMetric() {
+				for _, l := range m.GetLabel() {
+					if l.GetName() == "route" {
+						counts[l.GetValue()] += m.GetHistogram().GetSampleCount()
+					}
+				}
+			}
+		}
+		assert.EqualValues(t, map[string]uint64{"/offer/:id": 2, "unmatched": 1}, counts)
+	})
+}
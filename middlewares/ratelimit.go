@@ -0,0 +1,160 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/common/ratelimit"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/domain/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyFunc extracts the rate limiting key from a request. An empty key skips
+// the rule.
+type KeyFunc func(c *gin.Context) string
+
+// Rule limits requests sharing the same key
+type Rule struct {
+	Scope string
+	Key   KeyFunc
+	Limit ratelimit.Limit
+}
+
+// ByIP keys requests by the IP of the connection. X-Forwarded-For and
+// X-Real-Ip are ignored, clients could set them to a new key each time.
+func ByIP(c *gin.Context) string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(c.Request.RemoteAddr))
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// ByAPIKey keys requests by the tenant API key they were authenticated
+// with, requests without one are not limited by key
+func ByAPIKey(c *gin.Context) string {
+	id := tenant.KeyFromContext(c.Request.Context())
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// ByEmail keys requests by the email field of the JSON body
+func ByEmail(c *gin.Context) string {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(peekBody(c), &body); err != nil {
+		return ""
+	}
+	return user.NormalizeEmail(body.Email)
+}
+
+// RateLimit rejects requests with 429 once any of the rules runs out of
+// tokens. Store errors are logged and the request is let through.
+func RateLimit(l *ratelimit.Limiter, rules ...Rule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, r := range rules {
+			key := r.Key(c)
+			if key == "" {
+				continue
+			}
+			d, err := l.Allow(r.Scope, key, r.Limit)
+			if err != nil {
+				logger.FromContext(c.Request.Context()).Error("ratelimit store failed", logger.Fields{
+					"scope": r.Scope,
+					"error": err,
+				})
+				continue
+			}
+			if !d.Allowed {
+				logger.FromContext(c.Request.Context()).Warn("rate limited", logger.Fields{"scope": r.Scope})
+				tooManyRequests(c, &ratelimit.LimitedError{Msg: "Too many requests", RetryAfter: d.RetryAfter})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RedeemLockout locks an email out after repeated failed redemptions. The
+// handler tells the outcome with ratelimit.FailAttempt, once for every
+// rejection that signals guessing a code, or ratelimit.SucceedAttempt,
+// which clears the failure history. Other responses, e.g. invalid bodies
+// or server errors, do not count whatever their status.
+func RedeemLockout(l *ratelimit.Limiter) gin.HandlerFunc {
+	return lockout(l, ByEmail)
+}
+
+// LookupLockout locks a client IP out after repeated lookups of codes that
+// do not exist, which the handler tells with ratelimit.FailAttempt. Routes
+// answering whether a code exists would otherwise let codes be guessed
+// without redeeming them.
+func LookupLockout(l *ratelimit.Limiter) gin.HandlerFunc {
+	return lockout(l, ByIP)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// lockout locks the key of requests out after repeated failed attempts
+func lockout(l *ratelimit.Limiter, keyOf KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := keyOf(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		log := logger.FromContext(c.Request.Context())
+		locked, retry, err := l.Locked(key)
+		if err != nil {
+			log.Error("lockout store failed", logger.Fields{"error": err})
+		}
+		if locked {
+			log.Warn("locked out", logger.Fields{"actor": key})
+			tooManyRequests(c, &ratelimit.LimitedError{Msg: "Too many failed attempts", RetryAfter: retry})
+			return
+		}
+
+		ctx, attempt := ratelimit.NewAttemptContext(c.Request.Context())
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		if err := l.Record(key, attempt); err != nil {
+			log.Error("lockout store failed", logger.Fields{"error": err})
+		}
+	}
+}
+
+func tooManyRequests(c *gin.Context, e *ratelimit.LimitedError) {
+	c.Header("Retry-After", strconv.Itoa(e.RetrySeconds()))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"code": http.StatusTooManyRequests,
+		"msg":  e.Msg,
+		"data": nil,
+	})
+}
+
+// peekBody reads the request body and puts it back for the next handlers
+func peekBody(c *gin.Context) []byte {
+	if c.Request.Body == nil {
+		return nil
+	}
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil
+	}
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body
+}
@@ -0,0 +1,169 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/ratelimit"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func performRequest(r http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return performRequestWith(r, req)
+}
+
+func performRequestWith(r http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Blocks once the email bucket is empty", func(t *testing.T) {
+		l := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.LockoutPolicy{})
+		router := gin.New()
+		router.POST("/redeem",
+			RateLimit(l, Rule{Scope: "email", Key: ByEmail, Limit: ratelimit.Limit{Rate: 0.5, Burst: 1}}),
+			func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+		w := performRequest(router, "POST", "/redeem", `{"email":"Alice@cc.cc"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = performRequest(router, "POST", "/redeem", `{"email":"alice@cc.cc "}`)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "2", w.Header().Get("Retry-After"))
+
+		w = performRequest(router, "POST", "/redeem", `{"email":"bob@cc.cc"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Keeps the body readable for the handler", func(t *testing.T) {
+		l := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.LockoutPolicy{})
+		router := gin.New()
+		router.POST("/redeem",
+			RateLimit(l, Rule{Scope: "email", Key: ByEmail, Limit: ratelimit.Limit{Rate: 1, Burst: 1}}),
+			func(c *gin.Context) {
+				var in struct {
+					Email string `json:"email"`
+				}
+				c.ShouldBindJSON(&in)
+				c.String(http.StatusOK, in.Email)
+			})
+
+		w := performRequest(router, "POST", "/redeem", `{"email":"alice@cc.cc"}`)
+		assert.Equal(t, "alice@cc.cc", w.Body.String())
+	})
+}
+
+func TestKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("IP ignores forwarding headers", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/redeem", nil)
+		c.Request.RemoteAddr = "10.0.0.7:4321"
+		c.Request.Header.Set("X-Forwarded-For", "1.2.3.4")
+		c.Request.Header.Set("X-Real-Ip", "5.6.7.8")
+
+		assert.Equal(t, "10.0.0.7", ByIP(c))
+	})
+
+	t.Run("API key is the authenticated one", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/redeem", nil)
+		c.Request.Header.Set("Authorization", "made-up")
+		assert.Equal(t, "", ByAPIKey(c))
+
+		c.Request = c.Request.WithContext(tenant.NewKeyContext(c.Request.Context(), 7))
+		assert.Equal(t, "7", ByAPIKey(c))
+	})
+}
+
+func TestRedeemLockout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	policy := ratelimit.LockoutPolicy{MaxFailures: 2, BaseCooldown: time.Minute}
+
+	t.Run("Locks an email out after failed attempts", func(t *testing.T) {
+		l := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), policy)
+		router := gin.New()
+		router.POST("/redeem", RedeemLockout(l), func(c *gin.Context) {
+			ratelimit.FailAttempt(c.Request.Context())
+			c.String(http.StatusNotFound, "Invalid Voucher")
+		})
+
+		performRequest(router, "POST", "/redeem", `{"email":"alice@cc.cc"}`)
+		w := performRequest(router, "POST", "/redeem", `{"email":"alice@cc.cc"}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = performRequest(router, "POST", "/redeem", `{"email":"alice@cc.cc"}`)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("Successful redemption clears failures", func(t *testing.T) {
+		l := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), policy)
+		status := http.StatusNotFound
+		router := gin.New()
+		router.POST("/redeem", RedeemLockout(l), func(c *gin.Context) {
+			if status == http.StatusOK {
+				ratelimit.SucceedAttempt(c.Request.Context())
+			} else {
+				ratelimit.FailAttempt(c.Request.Context())
+			}
+			c.String(status, "")
+		})
+
+		performRequest(router, "POST", "/redeem", `{"email":"alice@cc.cc"}`)
+		status = http.StatusOK
+		performRequest(router, "POST", "/redeem", `{"email":"alice@cc.cc"}`)
+		status = http.StatusNotFound
+		performRequest(router, "POST", "/redeem", `{"email":"alice@cc.cc"}`)
+
+		w := performRequest(router, "POST", "/redeem", `{"email":"alice@cc.cc"}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Other errors do not count", func(t *testing.T) {
+		l := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), policy)
+		router := gin.New()
+		router.POST("/redeem", RedeemLockout(l), func(c *gin.Context) {
+			c.String(http.StatusInternalServerError, "database is down")
+		})
+
+		for i := 0; i < 3; i++ {
+			w := performRequest(router, "POST", "/redeem", `{"email":"alice@cc.cc"}`)
+			assert.Equal(t, http.StatusInternalServerError, w.Code)
+		}
+	})
+}
+
+func TestLookupLockout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := ratelimit.NewLimiter(ratelimit.NewMemoryStore(),
+		ratelimit.LockoutPolicy{MaxFailures: 2, BaseCooldown: time.Minute})
+	router := gin.New()
+	router.GET("/vouchers/:code", LookupLockout(l), func(c *gin.Context) {
+		ratelimit.FailAttempt(c.Request.Context())
+		c.String(http.StatusNotFound, "record not found")
+	})
+	lookup := func(ip string) int {
+		req, _ := http.NewRequest("GET", "/vouchers/NOPE0000", nil)
+		req.RemoteAddr = ip + ":1234"
+		return performRequestWith(router, req).Code
+	}
+
+	assert.Equal(t, http.StatusNotFound, lookup("1.2.3.4"))
+	assert.Equal(t, http.StatusNotFound, lookup("1.2.3.4"))
+	assert.Equal(t, http.StatusTooManyRequests, lookup("1.2.3.4"))
+	assert.Equal(t, http.StatusNotFound, lookup("5.6.7.8"))
+}
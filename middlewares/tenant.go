@@ -0,0 +1,79 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/services/tenantservice"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// TenantHeader names the tenant of a request by slug
+	TenantHeader = "X-Tenant"
+	// APIKeyHeader authenticates the tenant of a request
+	APIKeyHeader = "X-API-Key"
+)
+
+// Tenant scopes the request context to the tenant of the X-API-Key header
+// and notes the key in it. The X-Tenant header, or else the subdomain of
+// the host under baseDomain, may name the tenant too but has to match the
+// key. Requests without a key belong to the default tenant. Unknown slugs
+// are answered with 404, unknown keys and slugs without a key with 401 and
+// keys of another tenant than the slug with 403.
+func Tenant(tenants tenantservice.TenantService, baseDomain string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		slug := c.GetHeader(TenantHeader)
+		if slug == "" {
+			slug = subdomain(c.Request.Host, baseDomain)
+		}
+		t, err := tenants.Resolve(c.Request.Context(), slug, c.GetHeader(APIKeyHeader))
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch err {
+			case tenantservice.ErrUnknownTenant:
+				status = http.StatusNotFound
+			case tenantservice.ErrInvalidAPIKey, tenantservice.ErrAPIKeyRequired:
+				status = http.StatusUnauthorized
+			case tenantservice.ErrTenantMismatch:
+				status = http.StatusForbidden
+			}
+			c.AbortWithStatusJSON(status, gin.H{
+				"code": status,
+				"msg":  err.Error(),
+				"data": nil,
+			})
+			return
+		}
+
+		ctx := tenant.NewKeyContext(tenant.NewContext(c.Request.Context(), t.ID), t.KeyID)
+		ctx = logger.NewContext(ctx, logger.FromContext(ctx).With(logger.Fields{"tenant": t.Slug}))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// subdomain returns the label of host right under baseDomain, empty when
+// host is not a direct subdomain of it
+func subdomain(host, baseDomain string) string {
+	if baseDomain == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	label := strings.TrimSuffix(host, "."+strings.ToLower(baseDomain))
+	if label == host || strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
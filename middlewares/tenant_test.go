@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories/apikeyrepo"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+	"github.com/deepinbytes/go_voucher/repositories/tenantrepo"
+	"github.com/deepinbytes/go_voucher/services/tenantservice"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := memdb.New()
+	tenants := tenantservice.NewTenantService(tenantrepo.NewMemoryTenantRepo(db), apikeyrepo.NewMemoryAPIKeyRepo(db),
+		tenantservice.KeyPolicy{Grace: time.Hour})
+	acme := &tenant.Tenant{Name: "Acme", Slug: "acme"}
+	require.Nil(t, tenants.Create(context.Background(), acme))
+
+	router := gin.New()
+	router.GET("/whoami", Tenant(tenants, "vouchers.example.com"), func(c *gin.Context) {
+		c.String(http.StatusOK, fmt.Sprint(tenant.FromContext(c.Request.Context())))
+	})
+
+	for _, tt := range []struct {
+		name    string
+		host    string
+		headers map[string]string
+		code    int
+		body    string
+	}{
+		{"Default tenant", "localhost:3000", nil, http.StatusOK, "1"},
+		{"Tenant header without key", "localhost", map[string]string{TenantHeader: "acme"}, http.StatusUnauthorized, ""},
+		{"Tenant header", "localhost", map[string]string{TenantHeader: "acme", APIKeyHeader: acme.APIKey},
+			http.StatusOK, fmt.Sprint(acme.ID)},
+		{"API key", "localhost", map[string]string{APIKeyHeader: acme.APIKey}, http.StatusOK, fmt.Sprint(acme.ID)},
+		{"Subdomain without key", "ACME.vouchers.example.com:443", nil, http.StatusUnauthorized, ""},
+		{"Subdomain", "ACME.vouchers.example.com:443", map[string]string{APIKeyHeader: acme.APIKey},
+			http.StatusOK, fmt.Sprint(acme.ID)},
+		{"Nested subdomain", "www.acme.vouchers.example.com", nil, http.StatusOK, "1"},
+		{"Header wins over subdomain", "globex.vouchers.example.com",
+			map[string]string{TenantHeader: "acme", APIKeyHeader: acme.APIKey}, http.StatusOK, fmt.Sprint(acme.ID)},
+		{"Unknown tenant", "globex.vouchers.example.com", nil, http.StatusNotFound, ""},
+		{"Unknown key", "localhost", map[string]string{APIKeyHeader: "nope"}, http.StatusUnauthorized, ""},
+		{"Key of another tenant", "localhost", map[string]string{APIKeyHeader: acme.APIKey, TenantHeader: "default"},
+			http.StatusForbidden, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/whoami", nil)
+			req.Host = tt.host
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			w := performRequestWith(router, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == http.StatusOK {
+				assert.Equal(t, tt.body, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package apikeyrepo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/apikey"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+
+	"github.com/jinzhu/gorm"
+)
+
+type memoryAPIKeyRepo struct {
+	db *memdb.DB
+}
+
+// NewMemoryAPIKeyRepo will instantiate API Key Repository backed by memdb
+func NewMemoryAPIKeyRepo(db *memdb.DB) Repo {
+	return &memoryAPIKeyRepo{
+		db: db,
+	}
+}
+
+func (m *memoryAPIKeyRepo) Create(ctx context.Context, k *apikey.Key) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	for id, other := range m.db.APIKeys {
+		if id == k.ID {
+			return memdb.Unique("api_keys_pkey", true)
+		}
+		if other.Hash == k.Hash {
+			return memdb.Unique("uix_api_keys_hash", true)
+		}
+	}
+	if k.ID == 0 {
+		k.ID = m.db.NextID("api_keys", func(id uint) bool { _, ok := m.db.APIKeys[id]; return ok })
+	}
+	if k.CreatedAt.IsZero() {
+		k.CreatedAt = m.db.Now()
+	}
+	stored := *k
+	stored.Plain = ""
+	m.db.APIKeys[k.ID] = stored
+	return nil
+}
+
+func (m *memoryAPIKeyRepo) Get(ctx context.Context, tenantID, id uint) (*apikey.Key, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	if k, ok := m.db.APIKeys[id]; ok && k.TenantID == tenantID {
+		return &k, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryAPIKeyRepo) GetByHash(ctx context.Context, hash string) (*apikey.Key, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	for _, k := range m.db.APIKeys {
+		if k.Hash == hash {
+			return &k, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryAPIKeyRepo) List(ctx context.Context, tenantID uint) ([]*apikey.Key, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	ks := []*apikey.Key{}
+	for _, k := range m.db.APIKeys {
+		k := k
+		if k.TenantID == tenantID {
+			ks = append(ks, &k)
+		}
+	}
+	sort.Slice(ks, func(i, j int) bool { return ks[i].ID < ks[j].ID })
+	return ks, nil
+}
+
+func (m *memoryAPIKeyRepo) Expire(ctx context.Context, k *apikey.Key, at time.Time) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	stored, ok := m.db.APIKeys[k.ID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	stored.ExpiresAt = &at
+	m.db.APIKeys[k.ID] = stored
+	expires := at
+	k.ExpiresAt = &expires
+	return nil
+}
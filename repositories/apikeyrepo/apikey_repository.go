@@ -0,0 +1,71 @@
+package apikeyrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/apikey"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Repo interface. Keys are not scoped to the tenant of the context, they
+// are what tells it.
+type Repo interface {
+	// Create fails with a unique violation when the hash is taken
+	Create(ctx context.Context, k *apikey.Key) error
+	Get(ctx context.Context, tenantID, id uint) (*apikey.Key, error)
+	GetByHash(ctx context.Context, hash string) (*apikey.Key, error)
+	// List returns the keys of the tenant, expired ones too
+	List(ctx context.Context, tenantID uint) ([]*apikey.Key, error)
+	// Expire sets the expiry of the key
+	Expire(ctx context.Context, k *apikey.Key, at time.Time) error
+}
+
+type apiKeyRepo struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepo will instantiate API Key Repository
+func NewAPIKeyRepo(db *gorm.DB) Repo {
+	return &apiKeyRepo{
+		db: db,
+	}
+}
+
+func (r *apiKeyRepo) Create(ctx context.Context, k *apikey.Key) error {
+	return logger.DB(ctx, r.db).Create(k).Error
+}
+
+func (r *apiKeyRepo) Get(ctx context.Context, tenantID, id uint) (*apikey.Key, error) {
+	var k apikey.Key
+	if err := logger.DB(ctx, r.db).First(&k, "tenant_id = ? AND id = ?", tenantID, id).Error; err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *apiKeyRepo) GetByHash(ctx context.Context, hash string) (*apikey.Key, error) {
+	var k apikey.Key
+	if err := logger.DB(ctx, r.db).First(&k, "hash = ?", hash).Error; err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *apiKeyRepo) List(ctx context.Context, tenantID uint) ([]*apikey.Key, error) {
+	var ks []*apikey.Key
+	if err := logger.DB(ctx, r.db).Where("tenant_id = ?", tenantID).Order("id").Find(&ks).Error; err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+func (r *apiKeyRepo) Expire(ctx context.Context, k *apikey.Key, at time.Time) error {
+	if err := logger.DB(ctx, r.db).Model(k).Update("expires_at", at).Error; err != nil {
+		return err
+	}
+	k.ExpiresAt = &at
+	return nil
+}
@@ -0,0 +1,61 @@
+package apikeyrepo
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupDB() (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatalf("can't create sqlmock: %s", err)
+	}
+
+	gormDB, gerr := gorm.Open("postgres", db)
+	if gerr != nil {
+		log.Fatalf("can't open gorm connection: %s", err)
+	}
+	gormDB.LogMode(true)
+	return gormDB, mock
+}
+
+func TestGetByHash(t *testing.T) {
+	gormDB, mock := setupDB()
+	defer gormDB.Close()
+
+	sqlStr := `SELECT * FROM "api_keys" WHERE (hash = $1) ORDER BY "api_keys"."id" ASC LIMIT 1`
+
+	t.Run("Finds the key of the hash", func(t *testing.T) {
+		mock.
+			ExpectQuery(regexp.QuoteMeta(sqlStr)).
+			WithArgs("abc123").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id", "prefix"}).AddRow(3, 2, "0a1b2c3d"))
+
+		got, err := NewAPIKeyRepo(gormDB).GetByHash(context.Background(), "abc123")
+
+		assert.Nil(t, err)
+		assert.Equal(t, uint(3), got.ID)
+		assert.Equal(t, uint(2), got.TenantID)
+		assert.Equal(t, "0a1b2c3d", got.Prefix)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown hash", func(t *testing.T) {
+		mock.
+			ExpectQuery(regexp.QuoteMeta(sqlStr)).
+			WithArgs("nope").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		_, err := NewAPIKeyRepo(gormDB).GetByHash(context.Background(), "nope")
+
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
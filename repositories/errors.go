@@ -0,0 +1,9 @@
+// Package repositories holds what the repositories of every storage backend
+// have in common
+package repositories
+
+import "errors"
+
+// ErrStale is returned by conditional updates when the row changed since
+// the caller read it
+var ErrStale = errors.New("record was modified since it was read")
@@ -0,0 +1,132 @@
+package giftcardrepo
+
+import (
+	"context"
+	"sort"
+
+	"github.com/deepinbytes/go_voucher/domain/giftcard"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+
+	"github.com/jinzhu/gorm"
+)
+
+type memoryGiftCardRepo struct {
+	db *memdb.DB
+}
+
+// NewMemoryGiftCardRepo will instantiate Gift Card Repository backed by memdb
+func NewMemoryGiftCardRepo(db *memdb.DB) Repo {
+	return &memoryGiftCardRepo{
+		db: db,
+	}
+}
+
+func (m *memoryGiftCardRepo) Create(ctx context.Context, card *giftcard.Card, entries []giftcard.Entry) error {
+	if !giftcard.Balanced(entries) {
+		return giftcard.ErrUnbalanced
+	}
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	card.TenantID = tenant.FromContext(ctx)
+	card.Balance = giftcard.CardAmount(entries)
+	if card.Balance < 0 {
+		return giftcard.ErrInsufficientBalance
+	}
+	for id, other := range m.db.GiftCards {
+		if id == card.ID {
+			return memdb.Unique("gift_cards_pkey", true)
+		}
+		if other.TenantID == card.TenantID && other.Code == card.Code {
+			return memdb.Unique("gift_cards.tenant_id, gift_cards.code", true)
+		}
+	}
+	if card.ID == 0 {
+		card.ID = m.db.NextID("gift_cards", func(id uint) bool { _, ok := m.db.GiftCards[id]; return ok })
+	}
+	now := m.db.Now()
+	if card.CreatedAt.IsZero() {
+		card.CreatedAt = now
+	}
+	card.UpdatedAt = now
+	if card.Version == 0 {
+		card.Version = 1
+	}
+	m.db.GiftCards[card.ID] = *card
+	m.insert(card, entries)
+	return nil
+}
+
+func (m *memoryGiftCardRepo) GetByCode(ctx context.Context, code string) (*giftcard.Card, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	for _, card := range m.db.GiftCards {
+		if card.Code == code && card.TenantID == tenant.FromContext(ctx) && card.DeletedAt == nil {
+			return &card, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryGiftCardRepo) Post(ctx context.Context, card *giftcard.Card, entries []giftcard.Entry) error {
+	if !giftcard.Balanced(entries) {
+		return giftcard.ErrUnbalanced
+	}
+	balance := card.Balance + giftcard.CardAmount(entries)
+	if balance < 0 {
+		return giftcard.ErrInsufficientBalance
+	}
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	stored, ok := m.db.GiftCards[card.ID]
+	if !ok || stored.TenantID != tenant.FromContext(ctx) || stored.DeletedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+	if stored.Version != card.Version {
+		return repositories.ErrStale
+	}
+	stored.Balance = balance
+	stored.Version++
+	stored.UpdatedAt = m.db.Now()
+	m.db.GiftCards[card.ID] = stored
+	m.insert(&stored, entries)
+	*card = stored
+	return nil
+}
+
+func (m *memoryGiftCardRepo) ListEntries(ctx context.Context, cardID uint) ([]*giftcard.Entry, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	var entries []*giftcard.Entry
+	for _, e := range m.db.GiftCardEntries {
+		if e.CardID == cardID && e.TenantID == tenant.FromContext(ctx) {
+			e := e
+			entries = append(entries, &e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// insert adds the entries to the ledger of card, the lock must be held
+func (m *memoryGiftCardRepo) insert(card *giftcard.Card, entries []giftcard.Entry) {
+	now := m.db.Now()
+	for _, e := range entries {
+		e.ID = m.db.NextID("gift_card_entries", func(id uint) bool { _, ok := m.db.GiftCardEntries[id]; return ok })
+		e.TenantID = card.TenantID
+		e.CardID = card.ID
+		if e.CreatedAt.IsZero() {
+			e.CreatedAt = now
+		}
+		m.db.GiftCardEntries[e.ID] = e
+	}
+}
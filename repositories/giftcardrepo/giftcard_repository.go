@@ -0,0 +1,142 @@
+package giftcardrepo
+
+import (
+	"context"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/giftcard"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Repo interface
+type Repo interface {
+	// Create stores the card with the entries issuing it, its balance is
+	// the card amount of the entries
+	Create(ctx context.Context, card *giftcard.Card, entries []giftcard.Entry) error
+	GetByCode(ctx context.Context, code string) (*giftcard.Card, error)
+	// Post adds the entries of one transaction to the ledger of the card
+	// and its card amount to the balance, only while the card is still at
+	// card.Version. The entries must balance and leave the balance
+	// positive or zero. card is then set to the card as stored.
+	Post(ctx context.Context, card *giftcard.Card, entries []giftcard.Entry) error
+	// ListEntries returns the ledger of the card, oldest first
+	ListEntries(ctx context.Context, cardID uint) ([]*giftcard.Entry, error)
+}
+
+type giftCardRepo struct {
+	db *gorm.DB
+}
+
+// NewGiftCardRepo will instantiate Gift Card Repository
+func NewGiftCardRepo(db *gorm.DB) Repo {
+	return &giftCardRepo{
+		db: db,
+	}
+}
+
+func (g *giftCardRepo) Create(ctx context.Context, card *giftcard.Card, entries []giftcard.Entry) error {
+	if !giftcard.Balanced(entries) {
+		return giftcard.ErrUnbalanced
+	}
+	card.TenantID = tenant.FromContext(ctx)
+	card.Balance = giftcard.CardAmount(entries)
+	if card.Balance < 0 {
+		return giftcard.ErrInsufficientBalance
+	}
+
+	tx := logger.DB(ctx, g.db).Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := tx.Create(card).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := g.insert(tx, card, entries); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (g *giftCardRepo) GetByCode(ctx context.Context, code string) (*giftcard.Card, error) {
+	var card giftcard.Card
+	if err := g.scoped(ctx).First(&card, "gift_cards.code = ?", code).Error; err != nil {
+		return nil, err
+	}
+	return &card, nil
+}
+
+func (g *giftCardRepo) Post(ctx context.Context, card *giftcard.Card, entries []giftcard.Entry) error {
+	if !giftcard.Balanced(entries) {
+		return giftcard.ErrUnbalanced
+	}
+	balance := card.Balance + giftcard.CardAmount(entries)
+	if balance < 0 {
+		return giftcard.ErrInsufficientBalance
+	}
+
+	tx := logger.DB(ctx, g.db).Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	scoped := tx.Where("gift_cards.tenant_id = ?", tenant.FromContext(ctx))
+	res := scoped.Model(&giftcard.Card{}).Where("id = ? AND version = ?", card.ID, card.Version).
+		Updates(map[string]interface{}{
+			"balance": balance,
+			"version": card.Version + 1,
+		})
+	if res.Error != nil {
+		tx.Rollback()
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		err := scoped.Select("id").First(&giftcard.Card{}, card.ID).Error
+		tx.Rollback()
+		if err != nil {
+			return err
+		}
+		return repositories.ErrStale
+	}
+	if err := g.insert(tx, card, entries); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	return g.scoped(ctx).First(card, card.ID).Error
+}
+
+func (g *giftCardRepo) ListEntries(ctx context.Context, cardID uint) ([]*giftcard.Entry, error) {
+	var entries []*giftcard.Entry
+	if err := repositories.Scoped(ctx, g.db, "gift_card_entries").
+		Where("card_id = ?", cardID).Order("id").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (g *giftCardRepo) scoped(ctx context.Context) *gorm.DB {
+	return repositories.Scoped(ctx, g.db, "gift_cards")
+}
+
+// insert adds the entries to the ledger of card within tx
+func (g *giftCardRepo) insert(tx *gorm.DB, card *giftcard.Card, entries []giftcard.Entry) error {
+	for i := range entries {
+		e := entries[i]
+		e.TenantID = card.TenantID
+		e.CardID = card.ID
+		if err := tx.Create(&e).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
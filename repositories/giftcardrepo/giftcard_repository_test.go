@@ -0,0 +1,72 @@
+package giftcardrepo
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"testing"
+
+	"github.com/deepinbytes/go_voucher/domain/giftcard"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupDB() (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatalf("can't create sqlmock: %s", err)
+	}
+
+	gormDB, gerr := gorm.Open("postgres", db)
+	if gerr != nil {
+		log.Fatalf("can't open gorm connection: %s", err)
+	}
+	gormDB.LogMode(true)
+	return gormDB, mock
+}
+
+func TestPost(t *testing.T) {
+	gormDB, mock := setupDB()
+	defer gormDB.Close()
+
+	entries := []giftcard.Entry{
+		{TxID: "t1", Kind: giftcard.KindDebit, Account: giftcard.AccountCard, Amount: -500, Currency: "EUR"},
+		{TxID: "t1", Kind: giftcard.KindDebit, Account: giftcard.AccountOrders, Amount: 500, Currency: "EUR"},
+	}
+
+	t.Run("Rolls back a stale card", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.
+			ExpectExec(
+				regexp.QuoteMeta(
+					`UPDATE "gift_cards" SET "balance" = $1, "updated_at" = $2, "version" = $3 WHERE "gift_cards"."deleted_at" IS NULL AND ((gift_cards.tenant_id = $4) AND (id = $5 AND version = $6))`)).
+			WithArgs(1500, sqlmock.AnyArg(), 4, tenant.DefaultID, 7, 3).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.
+			ExpectQuery(
+				regexp.QuoteMeta(
+					`SELECT id FROM "gift_cards"  WHERE "gift_cards"."deleted_at" IS NULL AND ((gift_cards.tenant_id = $1) AND ("gift_cards"."id" = 7)) ORDER BY "gift_cards"."id" ASC LIMIT 1`)).
+			WithArgs(tenant.DefaultID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectRollback()
+
+		card := &giftcard.Card{Model: gorm.Model{ID: 7}, Balance: 2000, Version: 3}
+		err := NewGiftCardRepo(gormDB).Post(context.Background(), card, entries)
+
+		assert.Equal(t, repositories.ErrStale, err)
+		assert.Equal(t, int64(2000), card.Balance)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Checks the entries first", func(t *testing.T) {
+		card := &giftcard.Card{Model: gorm.Model{ID: 7}, Balance: 400, Version: 3}
+		assert.Equal(t, giftcard.ErrInsufficientBalance, NewGiftCardRepo(gormDB).Post(context.Background(), card, entries))
+		assert.Equal(t, giftcard.ErrUnbalanced, NewGiftCardRepo(gormDB).Post(context.Background(), card, entries[:1]))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,135 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/apikey"
+	"github.com/deepinbytes/go_voucher/domain/giftcard"
+	"github.com/deepinbytes/go_voucher/domain/notification"
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/offline"
+	"github.com/deepinbytes/go_voucher/domain/privacy"
+	"github.com/deepinbytes/go_voucher/domain/referral"
+	"github.com/deepinbytes/go_voucher/domain/stats"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/domain/trigger"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ErrUniqueViolation is returned when a write would break a unique index
+var ErrUniqueViolation = errors.New("duplicate key value violates unique constraint")
+
+// DB is an in-memory database shared by the memory repositories. Rows are
+// stored by value so callers never hold references into the tables.
+// Repositories must hold the embedded lock while touching the tables.
+type DB struct {
+	sync.RWMutex
+	// Tenants starts with the default tenant, every other table is scoped
+	// to a tenant by the repositories
+	Tenants  map[uint]tenant.Tenant
+	Users    map[uint]user.User
+	Offers   map[uint]offer.Offer
+	Vouchers map[uint]voucher.Voucher
+	// PrivacyRecords log data subject requests
+	PrivacyRecords map[uint]privacy.Record
+	// DailyStats is the daily rollup of the vouchers
+	DailyStats []stats.Daily
+	// GiftCards and their ledger
+	GiftCards       map[uint]giftcard.Card
+	GiftCardEntries map[uint]giftcard.Entry
+	// ReferralCodes of the users and the Referrals made with them
+	ReferralCodes map[uint]referral.Code
+	Referrals     map[uint]referral.Referral
+	// Triggers and the TriggerIssuances they made
+	Triggers         map[uint]trigger.Trigger
+	TriggerIssuances map[uint]trigger.Issuance
+	// NotificationTemplates and the NotificationDeliveries of vouchers
+	NotificationTemplates  map[uint]notification.Template
+	NotificationDeliveries map[uint]notification.Delivery
+	// OfflineRedemptions uploaded by terminals
+	OfflineRedemptions map[uint]offline.Redemption
+	// APIKeys of the tenants, hashed
+	APIKeys map[uint]apikey.Key
+
+	seq map[string]uint
+	now func() time.Time
+}
+
+// New will instantiate a DB empty but for the default tenant
+func New() *DB {
+	now := time.Now()
+	return &DB{
+		Tenants: map[uint]tenant.Tenant{
+			tenant.DefaultID: {
+				Model: gorm.Model{ID: tenant.DefaultID, CreatedAt: now, UpdatedAt: now},
+				Name:  "Default",
+				Slug:  tenant.DefaultSlug,
+			},
+		},
+		Users:    make(map[uint]user.User),
+		Offers:   make(map[uint]offer.Offer),
+		Vouchers: make(map[uint]voucher.Voucher),
+
+		PrivacyRecords: make(map[uint]privacy.Record),
+
+		GiftCards:       make(map[uint]giftcard.Card),
+		GiftCardEntries: make(map[uint]giftcard.Entry),
+		ReferralCodes:   make(map[uint]referral.Code),
+		Referrals:       make(map[uint]referral.Referral),
+
+		Triggers:         make(map[uint]trigger.Trigger),
+		TriggerIssuances: make(map[uint]trigger.Issuance),
+
+		NotificationTemplates:  make(map[uint]notification.Template),
+		NotificationDeliveries: make(map[uint]notification.Delivery),
+
+		OfflineRedemptions: make(map[uint]offline.Redemption),
+		APIKeys:            make(map[uint]apikey.Key),
+
+		seq: map[string]uint{"tenants": tenant.DefaultID},
+		now: time.Now,
+	}
+}
+
+// NextID returns the next primary key of table, above any ID already used
+func (db *DB) NextID(table string, used func(id uint) bool) uint {
+	for {
+		db.seq[table]++
+		if !used(db.seq[table]) {
+			return db.seq[table]
+		}
+	}
+}
+
+// Now returns the time stamped on created and updated rows
+func (db *DB) Now() time.Time {
+	return db.now()
+}
+
+// Unique returns ErrUniqueViolation for index when taken is true
+func Unique(index string, taken bool) error {
+	if taken {
+		return fmt.Errorf("%w %q", ErrUniqueViolation, index)
+	}
+	return nil
+}
+
+// Page returns the part of rows, a slice sorted by ID, after the row with
+// ID after and at most limit long. id returns the ID of row i.
+func Page(rows interface{}, after uint, limit int, id func(i int) uint) interface{} {
+	v := reflect.ValueOf(rows)
+	start := sort.Search(v.Len(), func(i int) bool { return id(i) > after })
+	end := v.Len()
+	if limit >= 0 && start+limit < end {
+		end = start + limit
+	}
+	return v.Slice(start, end).Interface()
+}
@@ -0,0 +1,211 @@
+package notificationrepo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/notification"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+
+	"github.com/jinzhu/gorm"
+)
+
+type memoryNotificationRepo struct {
+	db *memdb.DB
+}
+
+// NewMemoryNotificationRepo will instantiate Notification Repository
+// backed by memdb
+func NewMemoryNotificationRepo(db *memdb.DB) Repo {
+	return &memoryNotificationRepo{
+		db: db,
+	}
+}
+
+func (m *memoryNotificationRepo) CreateTemplate(ctx context.Context, t *notification.Template) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	t.TenantID = tenant.FromContext(ctx)
+	for id, other := range m.db.NotificationTemplates {
+		if id == t.ID {
+			return memdb.Unique("notification_templates_pkey", true)
+		}
+		if other.TenantID == t.TenantID && other.OfferID == t.OfferID &&
+			other.Channel == t.Channel && other.Locale == t.Locale {
+			return memdb.Unique("uix_notification_templates_key", true)
+		}
+	}
+	if t.ID == 0 {
+		t.ID = m.db.NextID("notification_templates", func(id uint) bool { _, ok := m.db.NotificationTemplates[id]; return ok })
+	}
+	now := m.db.Now()
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = now
+	}
+	t.UpdatedAt = now
+	m.db.NotificationTemplates[t.ID] = *t
+	return nil
+}
+
+func (m *memoryNotificationRepo) GetTemplate(ctx context.Context, id uint) (*notification.Template, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	t, ok := m.db.NotificationTemplates[id]
+	if !ok || t.TenantID != tenant.FromContext(ctx) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &t, nil
+}
+
+func (m *memoryNotificationRepo) ListTemplates(ctx context.Context) ([]*notification.Template, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	ts := []*notification.Template{}
+	for _, t := range m.db.NotificationTemplates {
+		t := t
+		if t.TenantID == tenant.FromContext(ctx) {
+			ts = append(ts, &t)
+		}
+	}
+	sort.Slice(ts, func(i, j int) bool { return ts[i].ID < ts[j].ID })
+	return ts, nil
+}
+
+func (m *memoryNotificationRepo) UpdateTemplate(ctx context.Context, t *notification.Template) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	stored, ok := m.db.NotificationTemplates[t.ID]
+	if !ok || stored.TenantID != tenant.FromContext(ctx) {
+		return gorm.ErrRecordNotFound
+	}
+	stored.Subject = t.Subject
+	stored.Body = t.Body
+	stored.UpdatedAt = m.db.Now()
+	m.db.NotificationTemplates[t.ID] = stored
+	*t = stored
+	return nil
+}
+
+func (m *memoryNotificationRepo) DeleteTemplate(ctx context.Context, id uint) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	t, ok := m.db.NotificationTemplates[id]
+	if !ok || t.TenantID != tenant.FromContext(ctx) {
+		return gorm.ErrRecordNotFound
+	}
+	delete(m.db.NotificationTemplates, id)
+	return nil
+}
+
+func (m *memoryNotificationRepo) CreateDelivery(ctx context.Context, d *notification.Delivery) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	d.TenantID = tenant.FromContext(ctx)
+	for id, other := range m.db.NotificationDeliveries {
+		if id == d.ID {
+			return memdb.Unique("notification_deliveries_pkey", true)
+		}
+		if other.TenantID == d.TenantID && other.VoucherID == d.VoucherID && other.Channel == d.Channel {
+			return memdb.Unique("uix_notification_deliveries_key", true)
+		}
+	}
+	if d.ID == 0 {
+		d.ID = m.db.NextID("notification_deliveries", func(id uint) bool { _, ok := m.db.NotificationDeliveries[id]; return ok })
+	}
+	now := m.db.Now()
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = now
+	}
+	d.UpdatedAt = now
+	m.db.NotificationDeliveries[d.ID] = *d
+	return nil
+}
+
+func (m *memoryNotificationRepo) ListDeliveries(ctx context.Context, voucherID uint) ([]*notification.Delivery, error) {
+	ds := m.deliveries(ctx, func(d *notification.Delivery) bool { return d.VoucherID == voucherID })
+	sort.Slice(ds, func(i, j int) bool { return ds[i].ID < ds[j].ID })
+	return ds, nil
+}
+
+func (m *memoryNotificationRepo) ListDeliveriesByUser(ctx context.Context, userID uint) ([]*notification.Delivery, error) {
+	ds := m.deliveries(ctx, func(d *notification.Delivery) bool { return d.UserID == userID })
+	sort.Slice(ds, func(i, j int) bool { return ds[i].ID < ds[j].ID })
+	return ds, nil
+}
+
+func (m *memoryNotificationRepo) ListDue(ctx context.Context, now time.Time, limit int) ([]*notification.Delivery, error) {
+	ds := m.deliveries(ctx, func(d *notification.Delivery) bool {
+		return d.Status == notification.StatusPending && !d.NextAttemptAt.After(now)
+	})
+	sort.Slice(ds, func(i, j int) bool {
+		if !ds[i].NextAttemptAt.Equal(ds[j].NextAttemptAt) {
+			return ds[i].NextAttemptAt.Before(ds[j].NextAttemptAt)
+		}
+		return ds[i].ID < ds[j].ID
+	})
+	if len(ds) > limit {
+		ds = ds[:limit]
+	}
+	return ds, nil
+}
+
+func (m *memoryNotificationRepo) Claim(ctx context.Context, d *notification.Delivery, until time.Time) (bool, error) {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	stored, ok := m.db.NotificationDeliveries[d.ID]
+	if !ok || stored.TenantID != tenant.FromContext(ctx) ||
+		stored.Status != notification.StatusPending || stored.Attempts != d.Attempts {
+		return false, nil
+	}
+	stored.Attempts++
+	stored.NextAttemptAt = until
+	stored.UpdatedAt = m.db.Now()
+	m.db.NotificationDeliveries[d.ID] = stored
+	d.Attempts = stored.Attempts
+	d.NextAttemptAt = until
+	return true, nil
+}
+
+func (m *memoryNotificationRepo) UpdateDelivery(ctx context.Context, d *notification.Delivery) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	stored, ok := m.db.NotificationDeliveries[d.ID]
+	if !ok || stored.TenantID != tenant.FromContext(ctx) {
+		return gorm.ErrRecordNotFound
+	}
+	stored.Status = d.Status
+	stored.LastError = d.LastError
+	stored.NextAttemptAt = d.NextAttemptAt
+	stored.SentAt = d.SentAt
+	stored.UpdatedAt = m.db.Now()
+	m.db.NotificationDeliveries[d.ID] = stored
+	return nil
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (m *memoryNotificationRepo) deliveries(ctx context.Context, keep func(*notification.Delivery) bool) []*notification.Delivery {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	ds := []*notification.Delivery{}
+	for _, d := range m.db.NotificationDeliveries {
+		d := d
+		if d.TenantID == tenant.FromContext(ctx) && keep(&d) {
+			ds = append(ds, &d)
+		}
+	}
+	return ds
+}
@@ -0,0 +1,172 @@
+package notificationrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/notification"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Repo interface
+type Repo interface {
+	CreateTemplate(ctx context.Context, t *notification.Template) error
+	GetTemplate(ctx context.Context, id uint) (*notification.Template, error)
+	ListTemplates(ctx context.Context) ([]*notification.Template, error)
+	// UpdateTemplate saves the subject and body of t
+	UpdateTemplate(ctx context.Context, t *notification.Template) error
+	DeleteTemplate(ctx context.Context, id uint) error
+	// CreateDelivery fails with a unique violation when the voucher already
+	// has a delivery on the channel
+	CreateDelivery(ctx context.Context, d *notification.Delivery) error
+	ListDeliveries(ctx context.Context, voucherID uint) ([]*notification.Delivery, error)
+	// ListDeliveriesByUser lists the deliveries to the user, ordered by ID
+	ListDeliveriesByUser(ctx context.Context, userID uint) ([]*notification.Delivery, error)
+	// ListDue lists at most limit pending deliveries due at now, the
+	// longest due first
+	ListDue(ctx context.Context, now time.Time, limit int) ([]*notification.Delivery, error)
+	// Claim counts an attempt at d and moves its next attempt to until,
+	// where it is retried should the attempt never report back. It returns
+	// false when another attempt was counted since d was read.
+	Claim(ctx context.Context, d *notification.Delivery, until time.Time) (bool, error)
+	// UpdateDelivery saves the status, error, next attempt and sent time
+	// of d
+	UpdateDelivery(ctx context.Context, d *notification.Delivery) error
+}
+
+type notificationRepo struct {
+	db *gorm.DB
+}
+
+// NewNotificationRepo will instantiate Notification Repository
+func NewNotificationRepo(db *gorm.DB) Repo {
+	return &notificationRepo{
+		db: db,
+	}
+}
+
+func (nr *notificationRepo) CreateTemplate(ctx context.Context, t *notification.Template) error {
+	t.TenantID = tenant.FromContext(ctx)
+	return logger.DB(ctx, nr.db).Create(t).Error
+}
+
+func (nr *notificationRepo) GetTemplate(ctx context.Context, id uint) (*notification.Template, error) {
+	var t notification.Template
+	if err := nr.templates(ctx).First(&t, id).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (nr *notificationRepo) ListTemplates(ctx context.Context) ([]*notification.Template, error) {
+	var ts []*notification.Template
+	if err := nr.templates(ctx).Order("id").Find(&ts).Error; err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+func (nr *notificationRepo) UpdateTemplate(ctx context.Context, t *notification.Template) error {
+	res := nr.templates(ctx).Model(&notification.Template{}).Where("id = ?", t.ID).Updates(map[string]interface{}{
+		"subject":    t.Subject,
+		"body":       t.Body,
+		"updated_at": gorm.NowFunc(),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nr.templates(ctx).First(t, t.ID).Error
+}
+
+func (nr *notificationRepo) DeleteTemplate(ctx context.Context, id uint) error {
+	res := nr.templates(ctx).Delete(&notification.Template{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (nr *notificationRepo) CreateDelivery(ctx context.Context, d *notification.Delivery) error {
+	d.TenantID = tenant.FromContext(ctx)
+	return logger.DB(ctx, nr.db).Create(d).Error
+}
+
+func (nr *notificationRepo) ListDeliveries(ctx context.Context, voucherID uint) ([]*notification.Delivery, error) {
+	var ds []*notification.Delivery
+	if err := nr.deliveries(ctx).Where("voucher_id = ?", voucherID).Order("id").Find(&ds).Error; err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+func (nr *notificationRepo) ListDeliveriesByUser(ctx context.Context, userID uint) ([]*notification.Delivery, error) {
+	var ds []*notification.Delivery
+	if err := nr.deliveries(ctx).Where("user_id = ?", userID).Order("id").Find(&ds).Error; err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+func (nr *notificationRepo) ListDue(ctx context.Context, now time.Time, limit int) ([]*notification.Delivery, error) {
+	var ds []*notification.Delivery
+	if err := nr.deliveries(ctx).Where("status = ? AND next_attempt_at <= ?", notification.StatusPending, now).
+		Order("next_attempt_at, id").Limit(limit).Find(&ds).Error; err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+func (nr *notificationRepo) Claim(ctx context.Context, d *notification.Delivery, until time.Time) (bool, error) {
+	res := nr.deliveries(ctx).Model(&notification.Delivery{}).
+		Where("id = ? AND status = ? AND attempts = ?", d.ID, notification.StatusPending, d.Attempts).
+		Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": until,
+			"updated_at":      gorm.NowFunc(),
+		})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, res.Error
+	}
+	d.Attempts++
+	d.NextAttemptAt = until
+	return true, nil
+}
+
+func (nr *notificationRepo) UpdateDelivery(ctx context.Context, d *notification.Delivery) error {
+	res := nr.deliveries(ctx).Model(&notification.Delivery{}).Where("id = ?", d.ID).Updates(map[string]interface{}{
+		"status":          d.Status,
+		"last_error":      d.LastError,
+		"next_attempt_at": d.NextAttemptAt,
+		"sent_at":         d.SentAt,
+		"updated_at":      gorm.NowFunc(),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (nr *notificationRepo) templates(ctx context.Context) *gorm.DB {
+	return repositories.Scoped(ctx, nr.db, "notification_templates")
+}
+
+func (nr *notificationRepo) deliveries(ctx context.Context) *gorm.DB {
+	return repositories.Scoped(ctx, nr.db, "notification_deliveries")
+}
@@ -0,0 +1,71 @@
+package notificationrepo
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/notification"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupDB() (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatalf("can't create sqlmock: %s", err)
+	}
+
+	gormDB, gerr := gorm.Open("postgres", db)
+	if gerr != nil {
+		log.Fatalf("can't open gorm connection: %s", err)
+	}
+	gormDB.LogMode(true)
+	return gormDB, mock
+}
+
+func TestClaim(t *testing.T) {
+	gormDB, mock := setupDB()
+	defer gormDB.Close()
+	until := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	update := regexp.QuoteMeta(`UPDATE "notification_deliveries" SET "attempts" = attempts + 1, "next_attempt_at" = $1, "updated_at" = $2  WHERE (notification_deliveries.tenant_id = $3) AND (id = $4 AND status = $5 AND attempts = $6)`)
+
+	t.Run("Counts the attempt of the delivery", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(update).
+			WithArgs(until, sqlmock.AnyArg(), tenant.DefaultID, 7, notification.StatusPending, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		d := &notification.Delivery{ID: 7, Attempts: 2}
+		claimed, err := NewNotificationRepo(gormDB).Claim(context.Background(), d, until)
+
+		assert.Nil(t, err)
+		assert.True(t, claimed)
+		assert.Equal(t, 3, d.Attempts)
+		assert.Equal(t, until, d.NextAttemptAt)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Loses to another attempt", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(update).
+			WithArgs(until, sqlmock.AnyArg(), tenant.DefaultID, 7, notification.StatusPending, 2).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		d := &notification.Delivery{ID: 7, Attempts: 2}
+		claimed, err := NewNotificationRepo(gormDB).Claim(context.Background(), d, until)
+
+		assert.Nil(t, err)
+		assert.False(t, claimed)
+		assert.Equal(t, 2, d.Attempts)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,229 @@
+package offerrepo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+
+	"github.com/jinzhu/gorm"
+)
+
+type memoryOfferRepo struct {
+	db *memdb.DB
+}
+
+// NewMemoryOfferRepo will instantiate Offer Repository backed by memdb
+func NewMemoryOfferRepo(db *memdb.DB) Repo {
+	return &memoryOfferRepo{
+		db: db,
+	}
+}
+
+func (m *memoryOfferRepo) GetByID(ctx context.Context, id uint) (*offer.Offer, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	o, ok := m.db.Offers[id]
+	if !ok || o.TenantID != tenant.FromContext(ctx) || o.DeletedAt != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &o, nil
+}
+
+func (m *memoryOfferRepo) GetByName(ctx context.Context, name string) (*offer.Offer, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	for _, o := range m.db.Offers {
+		if o.Name == name && o.TenantID == tenant.FromContext(ctx) && o.DeletedAt == nil {
+			return &o, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryOfferRepo) Create(ctx context.Context, o *offer.Offer) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	o.TenantID = tenant.FromContext(ctx)
+	if err := m.checkUnique(o); err != nil {
+		return err
+	}
+	if _, ok := m.db.Offers[o.ID]; ok {
+		return memdb.Unique("offers_pkey", true)
+	}
+	if o.ID == 0 {
+		o.ID = m.db.NextID("offers", func(id uint) bool { _, ok := m.db.Offers[id]; return ok })
+	}
+	now := m.db.Now()
+	if o.CreatedAt.IsZero() {
+		o.CreatedAt = now
+	}
+	if o.Version == 0 {
+		o.Version = 1
+	}
+	o.UpdatedAt = now
+	m.db.Offers[o.ID] = *o
+	return nil
+}
+
+func (m *memoryOfferRepo) Update(ctx context.Context, o *offer.Offer) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	o.TenantID = tenant.FromContext(ctx)
+	if err := m.checkUnique(o); err != nil {
+		return err
+	}
+	if stored, ok := m.db.Offers[o.ID]; ok && stored.TenantID != o.TenantID {
+		return memdb.Unique("offers_pkey", true)
+	}
+	now := m.db.Now()
+	if o.ID == 0 {
+		o.ID = m.db.NextID("offers", func(id uint) bool { _, ok := m.db.Offers[id]; return ok })
+	}
+	if o.CreatedAt.IsZero() {
+		o.CreatedAt = now
+	}
+	o.Version++
+	o.UpdatedAt = now
+	m.db.Offers[o.ID] = *o
+	return nil
+}
+
+func (m *memoryOfferRepo) ListAll(ctx context.Context) ([]*offer.Offer, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	offers := make([]*offer.Offer, 0, len(m.db.Offers))
+	for _, o := range m.db.Offers {
+		if o.TenantID != tenant.FromContext(ctx) || o.DeletedAt != nil {
+			continue
+		}
+		o := o
+		offers = append(offers, &o)
+	}
+	sort.Slice(offers, func(i, j int) bool { return offers[i].ID < offers[j].ID })
+	return offers, nil
+}
+
+func (m *memoryOfferRepo) GetByIDs(ctx context.Context, ids []uint) ([]*offer.Offer, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	var offers []*offer.Offer
+	for _, id := range ids {
+		if o, ok := m.db.Offers[id]; ok && o.TenantID == tenant.FromContext(ctx) && o.DeletedAt == nil {
+			offers = append(offers, &o)
+		}
+	}
+	return offers, nil
+}
+
+func (m *memoryOfferRepo) ListPage(ctx context.Context, after uint, limit int) ([]*offer.Offer, error) {
+	offers, err := m.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return memdb.Page(offers, after, limit, func(i int) uint { return offers[i].ID }).([]*offer.Offer), nil
+}
+
+func (m *memoryOfferRepo) UpdateIfVersion(ctx context.Context, o *offer.Offer, version uint) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	stored, ok := m.db.Offers[o.ID]
+	if !ok || stored.TenantID != tenant.FromContext(ctx) || stored.DeletedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+	if stored.Version != version {
+		return repositories.ErrStale
+	}
+	o.TenantID = tenant.FromContext(ctx)
+	if err := m.checkUnique(o); err != nil {
+		return err
+	}
+	stored.Name = o.Name
+	stored.DiscountPercentage = o.DiscountPercentage
+	stored.DiscountAmount = o.DiscountAmount
+	stored.DiscountCurrency = o.DiscountCurrency
+	stored.DiscountAmounts = o.DiscountAmounts
+	stored.Stackable = o.Stackable
+	stored.ExclusivityGroup = o.ExclusivityGroup
+	stored.Priority = o.Priority
+	stored.Version = version + 1
+	stored.UpdatedAt = m.db.Now()
+	m.db.Offers[o.ID] = stored
+	*o = stored
+	return nil
+}
+
+func (m *memoryOfferRepo) Delete(ctx context.Context, id uint, at time.Time) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	o, ok := m.db.Offers[id]
+	if !ok || o.TenantID != tenant.FromContext(ctx) || o.DeletedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+	o.DeletedAt = &at
+	o.Version++
+	o.UpdatedAt = m.db.Now()
+	m.db.Offers[id] = o
+	return nil
+}
+
+func (m *memoryOfferRepo) GetDeleted(ctx context.Context, id uint) (*offer.Offer, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	o, ok := m.db.Offers[id]
+	if !ok || o.TenantID != tenant.FromContext(ctx) || o.DeletedAt == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &o, nil
+}
+
+func (m *memoryOfferRepo) Restore(ctx context.Context, id uint) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	o, ok := m.db.Offers[id]
+	if !ok || o.TenantID != tenant.FromContext(ctx) || o.DeletedAt == nil {
+		return gorm.ErrRecordNotFound
+	}
+	o.DeletedAt = nil
+	o.Version++
+	o.UpdatedAt = m.db.Now()
+	m.db.Offers[id] = o
+	return nil
+}
+
+func (m *memoryOfferRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	var n int64
+	for id, o := range m.db.Offers {
+		if o.TenantID == tenant.FromContext(ctx) && o.DeletedAt != nil && o.DeletedAt.Before(before) {
+			delete(m.db.Offers, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *memoryOfferRepo) checkUnique(o *offer.Offer) error {
+	for id, other := range m.db.Offers {
+		if id != o.ID && other.TenantID == o.TenantID && other.Name == o.Name {
+			return memdb.Unique("offers.tenant_id, offers.name", true)
+		}
+	}
+	return nil
+}
@@ -1,17 +1,36 @@
 package offerrepo
 
 import (
+	"context"
+	"github.com/deepinbytes/go_voucher/common/logger"
 	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories"
+	"time"
 
 	"github.com/jinzhu/gorm"
 )
 
 // Repo interface
 type Repo interface {
-	GetByID(id uint) (*offer.Offer, error)
-	GetByName(name string) (*offer.Offer, error)
-	Create(offer *offer.Offer) error
-	Update(offer *offer.Offer) error
+	GetByID(ctx context.Context, id uint) (*offer.Offer, error)
+	GetByName(ctx context.Context, name string) (*offer.Offer, error)
+	Create(ctx context.Context, offer *offer.Offer) error
+	Update(ctx context.Context, offer *offer.Offer) error
+	ListAll(ctx context.Context) ([]*offer.Offer, error)
+	GetByIDs(ctx context.Context, ids []uint) ([]*offer.Offer, error)
+	ListPage(ctx context.Context, after uint, limit int) ([]*offer.Offer, error)
+	// UpdateIfVersion updates the offer only while it is still at version,
+	// it returns repositories.ErrStale otherwise
+	UpdateIfVersion(ctx context.Context, offer *offer.Offer, version uint) error
+	// Delete soft deletes the offer at the given time
+	Delete(ctx context.Context, id uint, at time.Time) error
+	// GetDeleted returns the offer only while it is soft deleted
+	GetDeleted(ctx context.Context, id uint) (*offer.Offer, error)
+	// Restore undoes the soft delete of the offer
+	Restore(ctx context.Context, id uint) error
+	// Purge hard deletes the offers soft deleted before the given time
+	Purge(ctx context.Context, before time.Time) (int64, error)
 }
 
 type offerRepo struct {
@@ -25,26 +44,137 @@ func NewOfferRepo(db *gorm.DB) Repo {
 	}
 }
 
-func (u *offerRepo) GetByID(id uint) (*offer.Offer, error) {
+func (u *offerRepo) GetByID(ctx context.Context, id uint) (*offer.Offer, error) {
 	var offer offer.Offer
-	if err := u.db.First(&offer, id).Error; err != nil {
+	if err := u.scoped(ctx).First(&offer, id).Error; err != nil {
 		return nil, err
 	}
 	return &offer, nil
 }
 
-func (u *offerRepo) GetByName(name string) (*offer.Offer, error) {
+func (u *offerRepo) GetByName(ctx context.Context, name string) (*offer.Offer, error) {
 	var offer offer.Offer
-	if err := u.db.Where("name = ?", name).First(&offer).Error; err != nil {
+	if err := u.scoped(ctx).Where("name = ?", name).First(&offer).Error; err != nil {
 		return nil, err
 	}
 	return &offer, nil
 }
 
-func (u *offerRepo) Create(offer *offer.Offer) error {
-	return u.db.Create(offer).Error
+func (u *offerRepo) Create(ctx context.Context, offer *offer.Offer) error {
+	if offer.Version == 0 {
+		offer.Version = 1
+	}
+	offer.TenantID = tenant.FromContext(ctx)
+	return logger.DB(ctx, u.db).Create(offer).Error
+}
+
+func (u *offerRepo) Update(ctx context.Context, offer *offer.Offer) error {
+	offer.Version++
+	offer.TenantID = tenant.FromContext(ctx)
+	if err := u.scoped(ctx).Save(offer).Error; err != nil {
+		offer.Version--
+		return err
+	}
+	return nil
+}
+
+func (u *offerRepo) UpdateIfVersion(ctx context.Context, o *offer.Offer, version uint) error {
+	db := u.scoped(ctx)
+	res := db.Model(o).Where("version = ?", version).Updates(map[string]interface{}{
+		"name":                o.Name,
+		"discount_percentage": o.DiscountPercentage,
+		"discount_amount":     o.DiscountAmount,
+		"discount_currency":   o.DiscountCurrency,
+		"discount_amounts":    o.DiscountAmounts,
+		"stackable":           o.Stackable,
+		"exclusivity_group":   o.ExclusivityGroup,
+		"priority":            o.Priority,
+		"version":             version + 1,
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		if err := db.Select("id").First(&offer.Offer{}, o.ID).Error; err != nil {
+			return err
+		}
+		return repositories.ErrStale
+	}
+	o.Version = version + 1
+	return nil
+}
+
+func (u *offerRepo) Delete(ctx context.Context, id uint, at time.Time) error {
+	res := u.scoped(ctx).Model(&offer.Offer{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"deleted_at": at,
+		"version":    gorm.Expr("version + 1"),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
-func (u *offerRepo) Update(offer *offer.Offer) error {
-	return u.db.Save(offer).Error
+func (u *offerRepo) GetDeleted(ctx context.Context, id uint) (*offer.Offer, error) {
+	var o offer.Offer
+	if err := u.scoped(ctx).Unscoped().
+		Where("id = ? AND deleted_at IS NOT NULL", id).First(&o).Error; err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (u *offerRepo) Restore(ctx context.Context, id uint) error {
+	res := u.scoped(ctx).Unscoped().Model(&offer.Offer{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).Updates(map[string]interface{}{
+		"deleted_at": nil,
+		"version":    gorm.Expr("version + 1"),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (u *offerRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res := u.scoped(ctx).Unscoped().Where("deleted_at < ?", before).Delete(&offer.Offer{})
+	return res.RowsAffected, res.Error
+}
+
+func (u *offerRepo) ListAll(ctx context.Context) ([]*offer.Offer, error) {
+	var offers []*offer.Offer
+	if err := u.scoped(ctx).Order("id").Find(&offers).Error; err != nil {
+		return nil, err
+	}
+	return offers, nil
+}
+
+func (u *offerRepo) GetByIDs(ctx context.Context, ids []uint) ([]*offer.Offer, error) {
+	var offers []*offer.Offer
+	if err := u.scoped(ctx).Where("id IN (?)", ids).Find(&offers).Error; err != nil {
+		return nil, err
+	}
+	return offers, nil
+}
+
+func (u *offerRepo) ListPage(ctx context.Context, after uint, limit int) ([]*offer.Offer, error) {
+	var offers []*offer.Offer
+	if err := u.scoped(ctx).Where("id > ?", after).Order("id").Limit(limit).Find(&offers).Error; err != nil {
+		return nil, err
+	}
+	return offers, nil
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (u *offerRepo) scoped(ctx context.Context) *gorm.DB {
+	return repositories.Scoped(ctx, u.db, "offers")
 }
@@ -1,9 +1,11 @@
 package offerrepo
 
 import (
+	"context"
 	"database/sql/driver"
 	"errors"
 	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
 	"log"
 	"regexp"
 	"testing"
@@ -50,12 +52,12 @@ func TestGetByID(t *testing.T) {
 		mock.
 			ExpectQuery(
 				regexp.QuoteMeta(
-					`SELECT * FROM "offers"  WHERE "offers"."deleted_at" IS NULL AND (("offers"."id" = 100)) ORDER BY "offers"."id" ASC LIMIT 1 `)).
+					`SELECT * FROM "offers"  WHERE "offers"."deleted_at" IS NULL AND ((offers.tenant_id = $1) AND ("offers"."id" = 100)) ORDER BY "offers"."id" ASC LIMIT 1 `)).
 			WillReturnRows(
 				sqlmock.NewRows([]string{"name"}).
 					AddRow("TEST"))
 
-		result, err := u.GetByID(100)
+		result, err := u.GetByID(context.Background(), 100)
 
 		assert.EqualValues(t, expected, result)
 		assert.Nil(t, err)
@@ -68,10 +70,10 @@ func TestGetByID(t *testing.T) {
 
 		mock.
 			ExpectQuery(
-				regexp.QuoteMeta(`SELECT * FROM "offers" WHERE "offers"."deleted_at" IS NULL AND (("offers"."id" = 100)) ORDER BY "offers"."id" ASC LIMIT 1`)).
+				regexp.QuoteMeta(`SELECT * FROM "offers" WHERE "offers"."deleted_at" IS NULL AND ((offers.tenant_id = $1) AND ("offers"."id" = 100)) ORDER BY "offers"."id" ASC LIMIT 1`)).
 			WillReturnError(expected)
 
-		result, err := u.GetByID(100)
+		result, err := u.GetByID(context.Background(), 100)
 
 		assert.EqualValues(t, expected, err)
 		assert.Nil(t, result)
@@ -85,11 +87,11 @@ func TestGetByID(t *testing.T) {
 		mock.
 			ExpectQuery(
 				regexp.QuoteMeta(
-					`SELECT * FROM "offers" WHERE "offers"."deleted_at" IS NULL AND (("offers"."id" = 100)) ORDER BY "offers"."id" ASC LIMIT 1`)).
+					`SELECT * FROM "offers" WHERE "offers"."deleted_at" IS NULL AND ((offers.tenant_id = $1) AND ("offers"."id" = 100)) ORDER BY "offers"."id" ASC LIMIT 1`)).
 			WillReturnRows(
 				sqlmock.NewRows([]string{}))
 
-		result, err := u.GetByID(100)
+		result, err := u.GetByID(context.Background(), 100)
 
 		assert.EqualValues(t, expected, err)
 		assert.Nil(t, result)
@@ -106,16 +108,16 @@ func TestGetByName(t *testing.T) {
 		}
 
 		u := NewOfferRepo(gormDB)
-		sqlStr := `SELECT * FROM "offers" WHERE "offers"."deleted_at" IS NULL AND ((name = $1)) ORDER BY "offers"."id" ASC LIMIT 1`
+		sqlStr := `SELECT * FROM "offers" WHERE "offers"."deleted_at" IS NULL AND ((offers.tenant_id = $1) AND (name = $2)) ORDER BY "offers"."id" ASC LIMIT 1`
 
 		mock.
 			ExpectQuery(regexp.QuoteMeta(sqlStr)).
-			WithArgs("TEST").
+			WithArgs(tenant.DefaultID, "TEST").
 			WillReturnRows(
 				sqlmock.NewRows([]string{"name"}).
 					AddRow("TEST"))
 
-		result, err := u.GetByName("TEST")
+		result, err := u.GetByName(context.Background(), "TEST")
 
 		assert.EqualValues(t, expected, result)
 		assert.Nil(t, err)
@@ -125,14 +127,14 @@ func TestGetByName(t *testing.T) {
 		expected := errors.New("Nop")
 
 		u := NewOfferRepo(gormDB)
-		sqlStr := `SELECT * FROM "offers" WHERE "offers"."deleted_at" IS NULL AND ((name = $1)) ORDER BY "offers"."id" ASC LIMIT 1`
+		sqlStr := `SELECT * FROM "offers" WHERE "offers"."deleted_at" IS NULL AND ((offers.tenant_id = $1) AND (name = $2)) ORDER BY "offers"."id" ASC LIMIT 1`
 
 		mock.
 			ExpectQuery(regexp.QuoteMeta(sqlStr)).
-			WithArgs("TEST").
+			WithArgs(tenant.DefaultID, "TEST").
 			WillReturnError(expected)
 
-		result, err := u.GetByName("TEST")
+		result, err := u.GetByName(context.Background(), "TEST")
 
 		assert.EqualValues(t, expected, err)
 		assert.Nil(t, result)
@@ -142,15 +144,15 @@ func TestGetByName(t *testing.T) {
 		expected := errors.New("record not found")
 
 		u := NewOfferRepo(gormDB)
-		sqlStr := `SELECT * FROM "offers" WHERE "offers"."deleted_at" IS NULL AND ((name = $1)) ORDER BY "offers"."id" ASC LIMIT 1`
+		sqlStr := `SELECT * FROM "offers" WHERE "offers"."deleted_at" IS NULL AND ((offers.tenant_id = $1) AND (name = $2)) ORDER BY "offers"."id" ASC LIMIT 1`
 
 		mock.
 			ExpectQuery(regexp.QuoteMeta(sqlStr)).
-			WithArgs("TEST").
+			WithArgs(tenant.DefaultID, "TEST").
 			WillReturnRows(
 				sqlmock.NewRows([]string{}))
 
-		result, err := u.GetByName("TEST")
+		result, err := u.GetByName(context.Background(), "TEST")
 
 		assert.EqualValues(t, expected, err)
 		assert.Nil(t, result)
@@ -174,15 +176,15 @@ func TestCreate(t *testing.T) {
 		mock.
 			ExpectQuery(
 				regexp.QuoteMeta(
-					`INSERT INTO "offers" ("created_at","updated_at","deleted_at","name","discount_percentage") VALUES ($1,$2,$3,$4,$5) RETURNING "offers"."id`)).
-			WithArgs(AnyTime{}, AnyTime{}, nil, "TEST", 24).
+					`INSERT INTO "offers" ("created_at","updated_at","deleted_at","tenant_id","name","discount_percentage","version") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "offers"."id`)).
+			WithArgs(AnyTime{}, AnyTime{}, nil, tenant.DefaultID, "TEST", 24, 1).
 			WillReturnRows(
 				sqlmock.NewRows([]string{"id"}).
 					AddRow(1))
 
 		mock.ExpectCommit()
 
-		err := u.Create(offer)
+		err := u.Create(context.Background(), offer)
 		assert.Nil(t, err)
 	})
 
@@ -200,13 +202,13 @@ func TestCreate(t *testing.T) {
 		mock.
 			ExpectQuery(
 				regexp.QuoteMeta(
-					`INSERT INTO "offers" ("created_at","updated_at","deleted_at","name","discount_percentage") VALUES ($1,$2,$3,$4,$5) RETURNING "offers"."id`)).
-			WithArgs(AnyTime{}, AnyTime{}, nil, "TEST", 24).
+					`INSERT INTO "offers" ("created_at","updated_at","deleted_at","tenant_id","name","discount_percentage","version") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "offers"."id`)).
+			WithArgs(AnyTime{}, AnyTime{}, nil, tenant.DefaultID, "TEST", 24, 1).
 			WillReturnError(exp)
 
 		mock.ExpectCommit()
 
-		err := u.Create(offer)
+		err := u.Create(context.Background(), offer)
 		assert.NotNil(t, err)
 		assert.EqualValues(t, exp, err)
 	})
@@ -229,15 +231,15 @@ func TestUpdate(t *testing.T) {
 		mock.
 			ExpectQuery(
 				regexp.QuoteMeta(
-					`INSERT INTO "offers" ("created_at","updated_at","deleted_at","name","discount_percentage") VALUES ($1,$2,$3,$4,$5) RETURNING "offers"."id`)).
-			WithArgs(AnyTime{}, AnyTime{}, nil, "TEST", 24).
+					`INSERT INTO "offers" ("created_at","updated_at","deleted_at","tenant_id","name","discount_percentage","version") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "offers"."id`)).
+			WithArgs(AnyTime{}, AnyTime{}, nil, tenant.DefaultID, "TEST", 24, 1).
 			WillReturnRows(
 				sqlmock.NewRows([]string{"id"}).
 					AddRow(1))
 
 		mock.ExpectCommit()
 
-		err := u.Update(offer)
+		err := u.Update(context.Background(), offer)
 		assert.Nil(t, err)
 	})
 
@@ -255,13 +257,13 @@ func TestUpdate(t *testing.T) {
 		mock.
 			ExpectQuery(
 				regexp.QuoteMeta(
-					`INSERT INTO "offers" ("created_at","updated_at","deleted_at","name","discount_percentage") VALUES ($1,$2,$3,$4,$5) RETURNING "offers"."id`)).
-			WithArgs(AnyTime{}, AnyTime{}, nil, "TEST", 24).
+					`INSERT INTO "offers" ("created_at","updated_at","deleted_at","tenant_id","name","discount_percentage","version") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "offers"."id`)).
+			WithArgs(AnyTime{}, AnyTime{}, nil, tenant.DefaultID, "TEST", 24, 1).
 			WillReturnError(exp)
 
 		mock.ExpectCommit()
 
-		err := u.Update(offer)
+		err := u.Update(context.Background(), offer)
 		assert.NotNil(t, err)
 		assert.EqualValues(t, exp, err)
 	})
@@ -0,0 +1,88 @@
+package offlinerepo
+
+import (
+	"context"
+	"sort"
+
+	"github.com/deepinbytes/go_voucher/domain/offline"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+
+	"github.com/jinzhu/gorm"
+)
+
+type memoryOfflineRepo struct {
+	db *memdb.DB
+}
+
+// NewMemoryOfflineRepo will instantiate Offline Redemption Repository
+// backed by memdb
+func NewMemoryOfflineRepo(db *memdb.DB) Repo {
+	return &memoryOfflineRepo{
+		db: db,
+	}
+}
+
+func (m *memoryOfflineRepo) Create(ctx context.Context, r *offline.Redemption) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	r.TenantID = tenant.FromContext(ctx)
+	for id, other := range m.db.OfflineRedemptions {
+		if id == r.ID {
+			return memdb.Unique("offline_redemptions_pkey", true)
+		}
+		if other.TenantID == r.TenantID && other.Terminal == r.Terminal && other.Reference == r.Reference {
+			return memdb.Unique("uix_offline_redemptions_key", true)
+		}
+	}
+	if r.ID == 0 {
+		r.ID = m.db.NextID("offline_redemptions", func(id uint) bool { _, ok := m.db.OfflineRedemptions[id]; return ok })
+	}
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = m.db.Now()
+	}
+	m.db.OfflineRedemptions[r.ID] = *r
+	return nil
+}
+
+func (m *memoryOfflineRepo) Update(ctx context.Context, r *offline.Redemption) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	stored, ok := m.db.OfflineRedemptions[r.ID]
+	if !ok || stored.TenantID != tenant.FromContext(ctx) {
+		return nil
+	}
+	stored.Code, stored.VoucherID, stored.FirstUsedAt = r.Code, r.VoucherID, r.FirstUsedAt
+	stored.Status, stored.Reason = r.Status, r.Reason
+	m.db.OfflineRedemptions[r.ID] = stored
+	return nil
+}
+
+func (m *memoryOfflineRepo) Get(ctx context.Context, terminal, reference string) (*offline.Redemption, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	for _, r := range m.db.OfflineRedemptions {
+		if r.TenantID == tenant.FromContext(ctx) && r.Terminal == terminal && r.Reference == reference {
+			return &r, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryOfflineRepo) List(ctx context.Context, status string, after uint, limit int) ([]*offline.Redemption, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	rs := []*offline.Redemption{}
+	for _, r := range m.db.OfflineRedemptions {
+		r := r
+		if r.TenantID == tenant.FromContext(ctx) && (status == "" || r.Status == status) {
+			rs = append(rs, &r)
+		}
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].ID < rs[j].ID })
+	return memdb.Page(rs, after, limit, func(i int) uint { return rs[i].ID }).([]*offline.Redemption), nil
+}
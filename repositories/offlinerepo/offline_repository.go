@@ -0,0 +1,79 @@
+package offlinerepo
+
+import (
+	"context"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/offline"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Repo interface
+type Repo interface {
+	// Create fails with a unique violation when the terminal already
+	// uploaded the reference
+	Create(ctx context.Context, r *offline.Redemption) error
+	// Update saves the outcome of r: its code, voucher, status and reason
+	Update(ctx context.Context, r *offline.Redemption) error
+	Get(ctx context.Context, terminal, reference string) (*offline.Redemption, error)
+	// List pages the redemptions of status, of all when it is empty, after
+	// the redemption ID after
+	List(ctx context.Context, status string, after uint, limit int) ([]*offline.Redemption, error)
+}
+
+type offlineRepo struct {
+	db *gorm.DB
+}
+
+// NewOfflineRepo will instantiate Offline Redemption Repository
+func NewOfflineRepo(db *gorm.DB) Repo {
+	return &offlineRepo{
+		db: db,
+	}
+}
+
+func (or *offlineRepo) Create(ctx context.Context, r *offline.Redemption) error {
+	r.TenantID = tenant.FromContext(ctx)
+	return logger.DB(ctx, or.db).Create(r).Error
+}
+
+func (or *offlineRepo) Update(ctx context.Context, r *offline.Redemption) error {
+	return or.scoped(ctx).Model(&offline.Redemption{}).Where("id = ?", r.ID).Updates(map[string]interface{}{
+		"code":          r.Code,
+		"voucher_id":    r.VoucherID,
+		"status":        r.Status,
+		"reason":        r.Reason,
+		"first_used_at": r.FirstUsedAt,
+	}).Error
+}
+
+func (or *offlineRepo) Get(ctx context.Context, terminal, reference string) (*offline.Redemption, error) {
+	var r offline.Redemption
+	if err := or.scoped(ctx).First(&r, "terminal = ? AND reference = ?", terminal, reference).Error; err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (or *offlineRepo) List(ctx context.Context, status string, after uint, limit int) ([]*offline.Redemption, error) {
+	db := or.scoped(ctx).Where("id > ?", after)
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	var rs []*offline.Redemption
+	if err := db.Order("id").Limit(limit).Find(&rs).Error; err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (or *offlineRepo) scoped(ctx context.Context) *gorm.DB {
+	return repositories.Scoped(ctx, or.db, "offline_redemptions")
+}
@@ -0,0 +1,54 @@
+package privacyrepo
+
+import (
+	"context"
+	"sort"
+
+	"github.com/deepinbytes/go_voucher/domain/privacy"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+)
+
+type memoryPrivacyRepo struct {
+	db *memdb.DB
+}
+
+// NewMemoryPrivacyRepo will instantiate Privacy Repository backed by memdb
+func NewMemoryPrivacyRepo(db *memdb.DB) Repo {
+	return &memoryPrivacyRepo{
+		db: db,
+	}
+}
+
+func (m *memoryPrivacyRepo) Create(ctx context.Context, r *privacy.Record) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	if _, ok := m.db.PrivacyRecords[r.ID]; ok {
+		return memdb.Unique("privacy_records_pkey", true)
+	}
+	if r.ID == 0 {
+		r.ID = m.db.NextID("privacy_records", func(id uint) bool { _, ok := m.db.PrivacyRecords[id]; return ok })
+	}
+	r.TenantID = tenant.FromContext(ctx)
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = m.db.Now()
+	}
+	m.db.PrivacyRecords[r.ID] = *r
+	return nil
+}
+
+func (m *memoryPrivacyRepo) ListByUser(ctx context.Context, userID uint) ([]*privacy.Record, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	var records []*privacy.Record
+	for _, r := range m.db.PrivacyRecords {
+		if r.UserID == userID && r.TenantID == tenant.FromContext(ctx) {
+			r := r
+			records = append(records, &r)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return records, nil
+}
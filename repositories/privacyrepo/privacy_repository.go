@@ -0,0 +1,51 @@
+package privacyrepo
+
+import (
+	"context"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/privacy"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Repo interface
+type Repo interface {
+	Create(ctx context.Context, record *privacy.Record) error
+	// ListByUser returns the records of the user, oldest first
+	ListByUser(ctx context.Context, userID uint) ([]*privacy.Record, error)
+}
+
+type privacyRepo struct {
+	db *gorm.DB
+}
+
+// NewPrivacyRepo will instantiate Privacy Repository
+func NewPrivacyRepo(db *gorm.DB) Repo {
+	return &privacyRepo{
+		db: db,
+	}
+}
+
+func (p *privacyRepo) Create(ctx context.Context, record *privacy.Record) error {
+	record.TenantID = tenant.FromContext(ctx)
+	return logger.DB(ctx, p.db).Create(record).Error
+}
+
+func (p *privacyRepo) ListByUser(ctx context.Context, userID uint) ([]*privacy.Record, error) {
+	var records []*privacy.Record
+	if err := p.scoped(ctx).Where("user_id = ?", userID).Order("id").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (p *privacyRepo) scoped(ctx context.Context) *gorm.DB {
+	return repositories.Scoped(ctx, p.db, "privacy_records")
+}
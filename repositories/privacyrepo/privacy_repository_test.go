@@ -0,0 +1,58 @@
+package privacyrepo
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/privacy"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupDB() (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatalf("can't create sqlmock: %s", err)
+	}
+
+	gormDB, gerr := gorm.Open("postgres", db)
+	if gerr != nil {
+		log.Fatalf("can't open gorm connection: %s", err)
+	}
+	gormDB.LogMode(true)
+	return gormDB, mock
+}
+
+func TestListByUser(t *testing.T) {
+	gormDB, mock := setupDB()
+	defer gormDB.Close()
+
+	t.Run("Lists the records of the user", func(t *testing.T) {
+		at := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+		mock.
+			ExpectQuery(
+				regexp.QuoteMeta(
+					`SELECT * FROM "privacy_records"  WHERE (privacy_records.tenant_id = $1) AND (user_id = $2) ORDER BY "id"`)).
+			WithArgs(tenant.DefaultID, 7).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "user_id", "action", "created_at"}).
+					AddRow(1, 7, privacy.ActionExport, at).
+					AddRow(4, 7, privacy.ActionErasure, at))
+
+		records, err := NewPrivacyRepo(gormDB).ListByUser(context.Background(), 7)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []*privacy.Record{
+			{ID: 1, UserID: 7, Action: privacy.ActionExport, CreatedAt: at},
+			{ID: 4, UserID: 7, Action: privacy.ActionErasure, CreatedAt: at},
+		}, records)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
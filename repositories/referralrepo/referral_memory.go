@@ -0,0 +1,176 @@
+package referralrepo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/referral"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+
+	"github.com/jinzhu/gorm"
+)
+
+type memoryReferralRepo struct {
+	db *memdb.DB
+}
+
+// NewMemoryReferralRepo will instantiate Referral Repository backed by memdb
+func NewMemoryReferralRepo(db *memdb.DB) Repo {
+	return &memoryReferralRepo{
+		db: db,
+	}
+}
+
+func (m *memoryReferralRepo) CreateCode(ctx context.Context, code *referral.Code) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	code.TenantID = tenant.FromContext(ctx)
+	for id, other := range m.db.ReferralCodes {
+		if id == code.ID {
+			return memdb.Unique("referral_codes_pkey", true)
+		}
+		if other.TenantID != code.TenantID {
+			continue
+		}
+		if other.Code == code.Code {
+			return memdb.Unique("referral_codes.tenant_id, referral_codes.code", true)
+		}
+		if other.UserID == code.UserID {
+			return memdb.Unique("referral_codes.tenant_id, referral_codes.user_id", true)
+		}
+	}
+	if code.ID == 0 {
+		code.ID = m.db.NextID("referral_codes", func(id uint) bool { _, ok := m.db.ReferralCodes[id]; return ok })
+	}
+	if code.CreatedAt.IsZero() {
+		code.CreatedAt = m.db.Now()
+	}
+	m.db.ReferralCodes[code.ID] = *code
+	return nil
+}
+
+func (m *memoryReferralRepo) GetCode(ctx context.Context, userID uint) (*referral.Code, error) {
+	return m.findCode(ctx, func(c *referral.Code) bool { return c.UserID == userID })
+}
+
+func (m *memoryReferralRepo) GetByCode(ctx context.Context, code string) (*referral.Code, error) {
+	return m.findCode(ctx, func(c *referral.Code) bool { return c.Code == code })
+}
+
+func (m *memoryReferralRepo) Create(ctx context.Context, r *referral.Referral) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	r.TenantID = tenant.FromContext(ctx)
+	for id, other := range m.db.Referrals {
+		if id == r.ID {
+			return memdb.Unique("referrals_pkey", true)
+		}
+		if other.TenantID == r.TenantID && other.RefereeID == r.RefereeID {
+			return memdb.Unique("referrals.tenant_id, referrals.referee_id", true)
+		}
+	}
+	if r.ID == 0 {
+		r.ID = m.db.NextID("referrals", func(id uint) bool { _, ok := m.db.Referrals[id]; return ok })
+	}
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = m.db.Now()
+	}
+	m.db.Referrals[r.ID] = *r
+	return nil
+}
+
+func (m *memoryReferralRepo) GetByReferee(ctx context.Context, refereeID uint) (*referral.Referral, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	for _, r := range m.db.Referrals {
+		if r.RefereeID == refereeID && r.TenantID == tenant.FromContext(ctx) {
+			return &r, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryReferralRepo) ListByUser(ctx context.Context, userID uint) ([]*referral.Referral, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	var rs []*referral.Referral
+	for _, r := range m.db.Referrals {
+		if r.TenantID == tenant.FromContext(ctx) && (r.ReferrerID == userID || r.RefereeID == userID) {
+			r := r
+			rs = append(rs, &r)
+		}
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].ID < rs[j].ID })
+	return rs, nil
+}
+
+func (m *memoryReferralRepo) CountByDomain(ctx context.Context, referrerID uint, domain string, since time.Time) (int64, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	var n int64
+	for _, r := range m.db.Referrals {
+		if r.TenantID == tenant.FromContext(ctx) && r.ReferrerID == referrerID &&
+			r.RefereeDomain == domain && !r.CreatedAt.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *memoryReferralRepo) UpdateIfStatus(ctx context.Context, r *referral.Referral, status string) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	stored, ok := m.db.Referrals[r.ID]
+	if !ok || stored.TenantID != tenant.FromContext(ctx) {
+		return gorm.ErrRecordNotFound
+	}
+	if stored.Status != status {
+		return repositories.ErrStale
+	}
+	stored.Status = r.Status
+	stored.Reason = r.Reason
+	stored.ReferrerVoucherID = r.ReferrerVoucherID
+	stored.RefereeVoucherID = r.RefereeVoucherID
+	stored.RewardedAt = r.RewardedAt
+	m.db.Referrals[r.ID] = stored
+	return nil
+}
+
+func (m *memoryReferralRepo) Stats(ctx context.Context, referrerID uint) (*referral.Stats, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	st := &referral.Stats{Reasons: map[string]int64{}}
+	for _, r := range m.db.Referrals {
+		if r.TenantID != tenant.FromContext(ctx) || referrerID != 0 && r.ReferrerID != referrerID {
+			continue
+		}
+		st.Add(r.Status, r.Reason, 1)
+	}
+	return st, nil
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (m *memoryReferralRepo) findCode(ctx context.Context, match func(c *referral.Code) bool) (*referral.Code, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	for _, c := range m.db.ReferralCodes {
+		if c.TenantID == tenant.FromContext(ctx) && match(&c) {
+			return &c, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
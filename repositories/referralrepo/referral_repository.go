@@ -0,0 +1,152 @@
+package referralrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/referral"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Repo interface
+type Repo interface {
+	// CreateCode fails with a unique violation when the code is taken or
+	// the user already has one
+	CreateCode(ctx context.Context, code *referral.Code) error
+	GetCode(ctx context.Context, userID uint) (*referral.Code, error)
+	GetByCode(ctx context.Context, code string) (*referral.Code, error)
+	Create(ctx context.Context, r *referral.Referral) error
+	GetByReferee(ctx context.Context, refereeID uint) (*referral.Referral, error)
+	// ListByUser lists the referrals the user made or was referred by,
+	// ordered by ID
+	ListByUser(ctx context.Context, userID uint) ([]*referral.Referral, error)
+	// CountByDomain counts the referrals of the referrer created since the
+	// given time whose referee has an email of domain, rejected ones too
+	CountByDomain(ctx context.Context, referrerID uint, domain string, since time.Time) (int64, error)
+	// UpdateIfStatus saves the status, reason, rewards and reward time of
+	// r only while it is still at status
+	UpdateIfStatus(ctx context.Context, r *referral.Referral, status string) error
+	// Stats sums up the referrals of the referrer, of all when it is 0
+	Stats(ctx context.Context, referrerID uint) (*referral.Stats, error)
+}
+
+type referralRepo struct {
+	db *gorm.DB
+}
+
+// NewReferralRepo will instantiate Referral Repository
+func NewReferralRepo(db *gorm.DB) Repo {
+	return &referralRepo{
+		db: db,
+	}
+}
+
+func (rr *referralRepo) CreateCode(ctx context.Context, code *referral.Code) error {
+	code.TenantID = tenant.FromContext(ctx)
+	return logger.DB(ctx, rr.db).Create(code).Error
+}
+
+func (rr *referralRepo) GetCode(ctx context.Context, userID uint) (*referral.Code, error) {
+	var code referral.Code
+	if err := rr.codes(ctx).First(&code, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (rr *referralRepo) GetByCode(ctx context.Context, c string) (*referral.Code, error) {
+	var code referral.Code
+	if err := rr.codes(ctx).First(&code, "code = ?", c).Error; err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (rr *referralRepo) Create(ctx context.Context, r *referral.Referral) error {
+	r.TenantID = tenant.FromContext(ctx)
+	return logger.DB(ctx, rr.db).Create(r).Error
+}
+
+func (rr *referralRepo) GetByReferee(ctx context.Context, refereeID uint) (*referral.Referral, error) {
+	var r referral.Referral
+	if err := rr.referrals(ctx).First(&r, "referee_id = ?", refereeID).Error; err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (rr *referralRepo) ListByUser(ctx context.Context, userID uint) ([]*referral.Referral, error) {
+	var rs []*referral.Referral
+	if err := rr.referrals(ctx).Where("referrer_id = ? OR referee_id = ?", userID, userID).Order("id").Find(&rs).Error; err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+func (rr *referralRepo) CountByDomain(ctx context.Context, referrerID uint, domain string, since time.Time) (int64, error) {
+	var n int64
+	err := rr.referrals(ctx).Model(&referral.Referral{}).
+		Where("referrer_id = ? AND referee_domain = ? AND created_at >= ?", referrerID, domain, since).
+		Count(&n).Error
+	return n, err
+}
+
+func (rr *referralRepo) UpdateIfStatus(ctx context.Context, r *referral.Referral, status string) error {
+	db := rr.referrals(ctx)
+	res := db.Model(&referral.Referral{}).Where("id = ? AND status = ?", r.ID, status).Updates(map[string]interface{}{
+		"status":              r.Status,
+		"reason":              r.Reason,
+		"referrer_voucher_id": r.ReferrerVoucherID,
+		"referee_voucher_id":  r.RefereeVoucherID,
+		"rewarded_at":         r.RewardedAt,
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		if err := db.Select("id").First(&referral.Referral{}, r.ID).Error; err != nil {
+			return err
+		}
+		return repositories.ErrStale
+	}
+	return nil
+}
+
+func (rr *referralRepo) Stats(ctx context.Context, referrerID uint) (*referral.Stats, error) {
+	db := rr.referrals(ctx).Model(&referral.Referral{})
+	if referrerID != 0 {
+		db = db.Where("referrer_id = ?", referrerID)
+	}
+	rows, err := db.Select("status, reason, count(*)").Group("status, reason").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	st := &referral.Stats{Reasons: map[string]int64{}}
+	for rows.Next() {
+		var status, reason string
+		var n int64
+		if err := rows.Scan(&status, &reason, &n); err != nil {
+			return nil, err
+		}
+		st.Add(status, reason, n)
+	}
+	return st, rows.Err()
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (rr *referralRepo) codes(ctx context.Context) *gorm.DB {
+	return repositories.Scoped(ctx, rr.db, "referral_codes")
+}
+
+func (rr *referralRepo) referrals(ctx context.Context) *gorm.DB {
+	return repositories.Scoped(ctx, rr.db, "referrals")
+}
@@ -0,0 +1,57 @@
+package referralrepo
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"testing"
+
+	"github.com/deepinbytes/go_voucher/domain/referral"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupDB() (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatalf("can't create sqlmock: %s", err)
+	}
+
+	gormDB, gerr := gorm.Open("postgres", db)
+	if gerr != nil {
+		log.Fatalf("can't open gorm connection: %s", err)
+	}
+	gormDB.LogMode(true)
+	return gormDB, mock
+}
+
+func TestStats(t *testing.T) {
+	gormDB, mock := setupDB()
+	defer gormDB.Close()
+
+	t.Run("Sums up the referrals of a referrer", func(t *testing.T) {
+		mock.
+			ExpectQuery(
+				regexp.QuoteMeta(
+					`SELECT status, reason, count(*) FROM "referrals"  WHERE (referrals.tenant_id = $1) AND (referrer_id = $2) GROUP BY status, reason`)).
+			WithArgs(tenant.DefaultID, 7).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"status", "reason", "count"}).
+					AddRow(referral.StatusPending, "", 3).
+					AddRow(referral.StatusRewarded, "", 2).
+					AddRow(referral.StatusRejected, referral.ReasonDomainThrottled, 1))
+
+		st, err := NewReferralRepo(gormDB).Stats(context.Background(), 7)
+
+		assert.Nil(t, err)
+		assert.Equal(t, &referral.Stats{
+			Total: 6, Pending: 3, Rewarded: 2, Rejected: 1, VouchersIssued: 4,
+			Reasons: map[string]int64{referral.ReasonDomainThrottled: 1},
+		}, st)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,1393 @@
+// Package repotest is the conformance suite every storage backend of the
+// repositories must pass.
+package repotest
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/apikey"
+	"github.com/deepinbytes/go_voucher/domain/giftcard"
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/domain/notification"
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/offline"
+	"github.com/deepinbytes/go_voucher/domain/privacy"
+	"github.com/deepinbytes/go_voucher/domain/referral"
+	"github.com/deepinbytes/go_voucher/domain/stats"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/domain/trigger"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories"
+	"github.com/deepinbytes/go_voucher/repositories/apikeyrepo"
+	"github.com/deepinbytes/go_voucher/repositories/giftcardrepo"
+	"github.com/deepinbytes/go_voucher/repositories/notificationrepo"
+	"github.com/deepinbytes/go_voucher/repositories/offerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/offlinerepo"
+	"github.com/deepinbytes/go_voucher/repositories/privacyrepo"
+	"github.com/deepinbytes/go_voucher/repositories/referralrepo"
+	"github.com/deepinbytes/go_voucher/repositories/statsrepo"
+	"github.com/deepinbytes/go_voucher/repositories/tenantrepo"
+	"github.com/deepinbytes/go_voucher/repositories/triggerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/userrepo"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Repos groups the repositories of one backend, sharing the same storage
+type Repos struct {
+	Users     userrepo.Repo
+	Offers    offerrepo.Repo
+	Vouchers  voucherrepo.Repo
+	Privacy   privacyrepo.Repo
+	Stats     statsrepo.Repo
+	Tenants   tenantrepo.Repo
+	GiftCards giftcardrepo.Repo
+	Referrals referralrepo.Repo
+	Triggers  triggerrepo.Repo
+
+	Notifications notificationrepo.Repo
+	Offline       offlinerepo.Repo
+	APIKeys       apikeyrepo.Repo
+}
+
+// Open returns empty repositories and a func releasing them
+type Open func(t *testing.T) (Repos, func())
+
+// Run runs the whole suite, every subtest gets fresh repositories from open
+func Run(t *testing.T, open Open) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, r Repos)
+	}{
+		{"Users", testUsers},
+		{"UniqueEmail", testUniqueEmail},
+		{"UserVouchers", testUserVouchers},
+		{"Birthdays", testBirthdays},
+		{"Offers", testOffers},
+		{"UniqueOfferName", testUniqueOfferName},
+		{"Vouchers", testVouchers},
+		{"MarkUsed", testMarkUsed},
+		{"UniqueCode", testUniqueCode},
+		{"CountExpiredByOffer", testCountExpiredByOffer},
+		{"Pages", testPages},
+		{"ListVouchers", testListVouchers},
+		{"Versions", testVersions},
+		{"DeleteOffer", testDeleteOffer},
+		{"RestoreOffer", testRestoreOffer},
+		{"RevokeVouchers", testRevokeVouchers},
+		{"DeleteUser", testDeleteUser},
+		{"Purge", testPurge},
+		{"PrivacyRecords", testPrivacyRecords},
+		{"OfferStats", testOfferStats},
+		{"Series", testSeries},
+		{"DailyStats", testDailyStats},
+		{"FixedDiscountStats", testFixedDiscountStats},
+		{"Tenants", testTenants},
+		{"APIKeys", testAPIKeys},
+		{"TenantScoping", testTenantScoping},
+		{"GiftCards", testGiftCards},
+		{"Referrals", testReferrals},
+		{"Triggers", testTriggers},
+		{"NotificationTemplates", testNotificationTemplates},
+		{"Deliveries", testDeliveries},
+		{"OfflineRedemptions", testOfflineRedemptions},
+		{"Concurrency", testConcurrency},
+		{"ConcurrentRedeem", testConcurrentRedeem},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r, closeFn := open(t)
+			defer closeFn()
+			tt.fn(t, r)
+		})
+	}
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+var ctx = context.Background()
+
+func testUsers(t *testing.T, r Repos) {
+	alice := &user.User{FirstName: "Alice", LastName: "Doe", Email: "alice@cc.cc"}
+	require.Nil(t, r.Users.Create(ctx, alice))
+	assert.NotZero(t, alice.ID)
+	assert.False(t, alice.CreatedAt.IsZero())
+
+	got, err := r.Users.GetByID(ctx, alice.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "Alice", got.FirstName)
+	assert.Equal(t, "alice@cc.cc", got.Email)
+
+	got.LastName = "Smith"
+	require.Nil(t, r.Users.Update(ctx, got))
+	got, err = r.Users.GetByEmail(ctx, "alice@cc.cc")
+	require.Nil(t, err)
+	assert.Equal(t, alice.ID, got.ID)
+	assert.Equal(t, "Smith", got.LastName)
+
+	bob := &user.User{Email: "bob@cc.cc"}
+	require.Nil(t, r.Users.Create(ctx, bob))
+	users, err := r.Users.ListAll(ctx)
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []uint{alice.ID, bob.ID}, userIDs(users))
+
+	_, err = r.Users.GetByID(ctx, bob.ID+100)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	_, err = r.Users.GetByEmail(ctx, "nobody@cc.cc")
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+}
+
+func testUniqueEmail(t *testing.T, r Repos) {
+	require.Nil(t, r.Users.Create(ctx, &user.User{Email: "alice@cc.cc"}))
+	assert.NotNil(t, r.Users.Create(ctx, &user.User{Email: "alice@cc.cc"}))
+
+	bob := &user.User{Email: "bob@cc.cc"}
+	require.Nil(t, r.Users.Create(ctx, bob))
+	bob.Email = "alice@cc.cc"
+	assert.NotNil(t, r.Users.Update(ctx, bob))
+
+	users, err := r.Users.ListAll(ctx)
+	require.Nil(t, err)
+	assert.Len(t, users, 2)
+}
+
+func testUserVouchers(t *testing.T, r Repos) {
+	o := &offer.Offer{Name: "Summer", DiscountPercentage: 10}
+	require.Nil(t, r.Offers.Create(ctx, o))
+	alice := &user.User{Email: "alice@cc.cc"}
+	require.Nil(t, r.Users.Create(ctx, alice))
+	bob := &user.User{Email: "bob@cc.cc"}
+	require.Nil(t, r.Users.Create(ctx, bob))
+
+	expire := time.Now().Add(time.Hour)
+	for _, v := range []*voucher.Voucher{
+		{Code: "AAAA0001", OfferID: o.ID, UserID: alice.ID, ExpireTime: expire},
+		{Code: "AAAA0002", OfferID: o.ID, UserID: alice.ID, ExpireTime: expire, IsUsed: true},
+		{Code: "AAAA0003", OfferID: o.ID, UserID: alice.ID, ExpireTime: expire},
+		{Code: "BBBB0001", OfferID: o.ID, UserID: bob.ID, ExpireTime: expire},
+	} {
+		require.Nil(t, r.Vouchers.Create(ctx, v))
+	}
+
+	got, err := r.Users.GetByEmail(ctx, "alice@cc.cc")
+	require.Nil(t, err)
+	codes := make([]string, 0, len(got.Voucher))
+	for _, v := range got.Voucher {
+		codes = append(codes, v.Code)
+		if assert.NotNil(t, v.Offer) {
+			assert.Equal(t, "Summer", v.Offer.Name)
+		}
+	}
+	sort.Strings(codes)
+	assert.Equal(t, []string{"AAAA0001", "AAAA0003"}, codes)
+}
+
+func testBirthdays(t *testing.T, r Repos) {
+	var ids []uint
+	for _, u := range []*user.User{
+		{Email: "alice@cc.cc", Birthday: "1990-02-28"},
+		{Email: "bob@cc.cc", Birthday: "1992-02-29"},
+		{Email: "carol@cc.cc", Birthday: "1985-02-28"},
+		{Email: "dave@cc.cc", Birthday: "1990-03-28"},
+		{Email: "erin@cc.cc"},
+	} {
+		require.Nil(t, r.Users.Create(ctx, u))
+		ids = append(ids, u.ID)
+	}
+	deleted := &user.User{Email: "frank@cc.cc", Birthday: "1980-02-28"}
+	require.Nil(t, r.Users.Create(ctx, deleted))
+	require.Nil(t, r.Users.Delete(ctx, deleted.ID, time.Now()))
+
+	// February 29 birthdays fall on the 28th outside leap years
+	got, err := r.Users.ListByBirthday(ctx, time.Date(2021, 2, 28, 0, 0, 0, 0, time.UTC), 0, 10)
+	require.Nil(t, err)
+	assert.Equal(t, []uint{ids[0], ids[1], ids[2]}, userIDs(got))
+
+	got, err = r.Users.ListByBirthday(ctx, time.Date(2020, 2, 28, 0, 0, 0, 0, time.UTC), 0, 10)
+	require.Nil(t, err)
+	assert.Equal(t, []uint{ids[0], ids[2]}, userIDs(got))
+
+	got, err = r.Users.ListByBirthday(ctx, time.Date(2021, 2, 28, 0, 0, 0, 0, time.UTC), ids[0], 1)
+	require.Nil(t, err)
+	assert.Equal(t, []uint{ids[1]}, userIDs(got))
+
+	got, err = r.Users.ListByBirthday(ctx, time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC), 0, 10)
+	require.Nil(t, err)
+	assert.Empty(t, got)
+}
+
+func testOffers(t *testing.T, r Repos) {
+	o := &offer.Offer{Name: "Summer", DiscountPercentage: 10}
+	require.Nil(t, r.Offers.Create(ctx, o))
+	assert.NotZero(t, o.ID)
+
+	got, err := r.Offers.GetByID(ctx, o.ID)
+	require.Nil(t, err)
+	assert.Equal(t, uint(10), got.DiscountPercentage)
+
+	got.DiscountPercentage = 25
+	require.Nil(t, r.Offers.Update(ctx, got))
+	got, err = r.Offers.GetByName(ctx, "Summer")
+	require.Nil(t, err)
+	assert.Equal(t, o.ID, got.ID)
+	assert.Equal(t, uint(25), got.DiscountPercentage)
+
+	winter := &offer.Offer{Name: "Winter"}
+	require.Nil(t, r.Offers.Create(ctx, winter))
+	offers, err := r.Offers.ListAll(ctx)
+	require.Nil(t, err)
+	if assert.Len(t, offers, 2) {
+		assert.Equal(t, o.ID, offers[0].ID)
+		assert.Equal(t, winter.ID, offers[1].ID)
+	}
+
+	_, err = r.Offers.GetByID(ctx, winter.ID+100)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	_, err = r.Offers.GetByName(ctx, "Autumn")
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+}
+
+func testUniqueOfferName(t *testing.T, r Repos) {
+	require.Nil(t, r.Offers.Create(ctx, &offer.Offer{Name: "Summer"}))
+	assert.NotNil(t, r.Offers.Create(ctx, &offer.Offer{Name: "Summer"}))
+
+	winter := &offer.Offer{Name: "Winter"}
+	require.Nil(t, r.Offers.Create(ctx, winter))
+	winter.Name = "Summer"
+	assert.NotNil(t, r.Offers.Update(ctx, winter))
+}
+
+func testVouchers(t *testing.T, r Repos) {
+	v := &voucher.Voucher{Code: "ABCD1234", OfferID: 1, UserID: 2, ExpireTime: time.Now().Add(time.Hour)}
+	require.Nil(t, r.Vouchers.Create(ctx, v))
+	assert.NotZero(t, v.ID)
+
+	got, err := r.Vouchers.UseCode(ctx, "ABCD1234")
+	require.Nil(t, err)
+	assert.Equal(t, v.ID, got.ID)
+	assert.False(t, got.IsUsed)
+
+	got.IsUsed = true
+	got.UsedAt = time.Now()
+	require.Nil(t, r.Vouchers.Update(ctx, got))
+	got, err = r.Vouchers.GetByID(ctx, v.ID)
+	require.Nil(t, err)
+	assert.True(t, got.IsUsed)
+	assert.Equal(t, uint(1), got.OfferID)
+	assert.Equal(t, uint(2), got.UserID)
+
+	_, err = r.Vouchers.GetByID(ctx, v.ID+100)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	_, err = r.Vouchers.UseCode(ctx, "NOPE0000")
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+}
+
+func testMarkUsed(t *testing.T, r Repos) {
+	v := &voucher.Voucher{Code: "ABCD1234", OfferID: 1, ExpireTime: time.Now().Add(time.Hour)}
+	require.Nil(t, r.Vouchers.Create(ctx, v))
+	at := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	marked, err := r.Vouchers.MarkUsed(ctx, v.ID, at, money.New(450, "GBP"))
+	require.Nil(t, err)
+	assert.True(t, marked)
+	got, err := r.Vouchers.GetByID(ctx, v.ID)
+	require.Nil(t, err)
+	assert.True(t, got.IsUsed)
+	assert.True(t, at.Equal(got.UsedAt), "got %v", got.UsedAt)
+	assert.Equal(t, int64(450), got.DiscountAmount)
+	assert.Equal(t, "GBP", got.DiscountCurrency)
+
+	// used once only, and never when revoked or of another tenant
+	marked, err = r.Vouchers.MarkUsed(ctx, v.ID, at.Add(time.Hour), money.Money{})
+	require.Nil(t, err)
+	assert.False(t, marked)
+	got, err = r.Vouchers.GetByID(ctx, v.ID)
+	require.Nil(t, err)
+	assert.True(t, at.Equal(got.UsedAt), "got %v", got.UsedAt)
+
+	revoked := &voucher.Voucher{Code: "EFGH5678", OfferID: 2}
+	require.Nil(t, r.Vouchers.Create(ctx, revoked))
+	_, err = r.Vouchers.RevokeUnusedByOffer(ctx, 2, at)
+	require.Nil(t, err)
+	marked, err = r.Vouchers.MarkUsed(ctx, revoked.ID, at, money.Money{})
+	require.Nil(t, err)
+	assert.False(t, marked)
+
+	other := &voucher.Voucher{Code: "IJKL9012", OfferID: 1}
+	require.Nil(t, r.Vouchers.Create(ctx, other))
+	marked, err = r.Vouchers.MarkUsed(tenant.NewContext(ctx, 2), other.ID, at, money.Money{})
+	require.Nil(t, err)
+	assert.False(t, marked)
+
+	// reversed once only, and never of another tenant
+	reversed, err := r.Vouchers.MarkUnused(tenant.NewContext(ctx, 2), v.ID)
+	require.Nil(t, err)
+	assert.False(t, reversed)
+	reversed, err = r.Vouchers.MarkUnused(ctx, v.ID)
+	require.Nil(t, err)
+	assert.True(t, reversed)
+	got, err = r.Vouchers.GetByID(ctx, v.ID)
+	require.Nil(t, err)
+	assert.False(t, got.IsUsed)
+	assert.Zero(t, got.DiscountAmount)
+	assert.Empty(t, got.DiscountCurrency)
+	reversed, err = r.Vouchers.MarkUnused(ctx, v.ID)
+	require.Nil(t, err)
+	assert.False(t, reversed)
+}
+
+func testUniqueCode(t *testing.T, r Repos) {
+	require.Nil(t, r.Vouchers.Create(ctx, &voucher.Voucher{Code: "ABCD1234"}))
+	assert.NotNil(t, r.Vouchers.Create(ctx, &voucher.Voucher{Code: "ABCD1234"}))
+
+	other := &voucher.Voucher{Code: "EFGH5678"}
+	require.Nil(t, r.Vouchers.Create(ctx, other))
+	other.Code = "ABCD1234"
+	assert.NotNil(t, r.Vouchers.Update(ctx, other))
+}
+
+func testCountExpiredByOffer(t *testing.T, r Repos) {
+	now := time.Now()
+	for _, v := range []*voucher.Voucher{
+		{Code: "A1", OfferID: 1, ExpireTime: now.Add(-time.Hour)},
+		{Code: "A2", OfferID: 1, ExpireTime: now.Add(-time.Minute)},
+		{Code: "A3", OfferID: 1, ExpireTime: now.Add(-time.Hour), IsUsed: true},
+		{Code: "A4", OfferID: 1, ExpireTime: now.Add(time.Hour)},
+		{Code: "B1", OfferID: 2, ExpireTime: now.Add(-time.Hour)},
+	} {
+		require.Nil(t, r.Vouchers.Create(ctx, v))
+	}
+
+	counts, err := r.Vouchers.CountExpiredByOffer(ctx, now)
+	require.Nil(t, err)
+	assert.Equal(t, map[uint]int64{1: 2, 2: 1}, counts)
+}
+
+func testPages(t *testing.T, r Repos) {
+	var users []*user.User
+	var offers []*offer.Offer
+	for _, name := range []string{"a", "b", "c", "d"} {
+		u := &user.User{Email: name + "@cc.cc"}
+		require.Nil(t, r.Users.Create(ctx, u))
+		users = append(users, u)
+		o := &offer.Offer{Name: name}
+		require.Nil(t, r.Offers.Create(ctx, o))
+		offers = append(offers, o)
+	}
+
+	page, err := r.Users.ListPage(ctx, 0, 3)
+	require.Nil(t, err)
+	assert.Equal(t, []uint{users[0].ID, users[1].ID, users[2].ID}, userIDs(page))
+	page, err = r.Users.ListPage(ctx, users[2].ID, 3)
+	require.Nil(t, err)
+	assert.Equal(t, []uint{users[3].ID}, userIDs(page))
+
+	byID, err := r.Users.GetByIDs(ctx, []uint{users[3].ID, users[1].ID, users[3].ID + 100})
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []uint{users[1].ID, users[3].ID}, userIDs(byID))
+
+	offerPage, err := r.Offers.ListPage(ctx, offers[0].ID, 2)
+	require.Nil(t, err)
+	if assert.Len(t, offerPage, 2) {
+		assert.Equal(t, offers[1].ID, offerPage[0].ID)
+		assert.Equal(t, offers[2].ID, offerPage[1].ID)
+	}
+	offersByID, err := r.Offers.GetByIDs(ctx, []uint{offers[2].ID})
+	require.Nil(t, err)
+	if assert.Len(t, offersByID, 1) {
+		assert.Equal(t, "c", offersByID[0].Name)
+	}
+}
+
+func testListVouchers(t *testing.T, r Repos) {
+	var ids []uint
+	for i, v := range []*voucher.Voucher{
+		{Code: "A1", OfferID: 1, UserID: 1},
+		{Code: "A2", OfferID: 1, UserID: 2, IsUsed: true},
+		{Code: "B1", OfferID: 2, UserID: 1},
+		{Code: "A3", OfferID: 1, UserID: 1, ExpireTime: time.Now().Add(time.Hour)},
+		{Code: "B2", OfferID: 2, UserID: 2},
+		{Code: "C1", OfferID: 3, UserID: 1},
+	} {
+		require.Nil(t, r.Vouchers.Create(ctx, v), "voucher %d", i)
+		ids = append(ids, v.ID)
+	}
+	codes := func(vouchers []*voucher.Voucher) []string {
+		out := make([]string, 0, len(vouchers))
+		for _, v := range vouchers {
+			out = append(out, v.Code)
+		}
+		return out
+	}
+
+	all, err := r.Vouchers.ListByOffers(ctx, []uint{1, 2}, voucherrepo.ListOptions{})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"A1", "A2", "A3", "B1", "B2"}, codes(all))
+
+	firstTwo, err := r.Vouchers.ListByOffers(ctx, []uint{1, 2}, voucherrepo.ListOptions{Limit: 1})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"A1", "B1"}, codes(firstTwo))
+
+	after, err := r.Vouchers.ListByOffers(ctx, []uint{1, 2}, voucherrepo.ListOptions{After: ids[0], Limit: 2})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"A2", "A3", "B1", "B2"}, codes(after))
+
+	unused := false
+	byUser, err := r.Vouchers.ListByUsers(ctx, []uint{2}, voucherrepo.ListOptions{IsUsed: &unused})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"B2"}, codes(byUser))
+
+	expired, valid := true, false
+	active, err := r.Vouchers.ListByUsers(ctx, []uint{1},
+		voucherrepo.ListOptions{IsUsed: &unused, Expired: &valid, Now: time.Now()})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"A3"}, codes(active))
+
+	gone, err := r.Vouchers.ListByUsers(ctx, []uint{1},
+		voucherrepo.ListOptions{Expired: &expired, Now: time.Now(), OfferID: 2})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"B1"}, codes(gone))
+}
+
+// testConcurrency creates the same emails from many goroutines, exactly one
+// create per email must win
+func testVersions(t *testing.T, r Repos) {
+	o := &offer.Offer{Name: "Summer", DiscountPercentage: 10}
+	require.Nil(t, r.Offers.Create(ctx, o))
+	assert.Equal(t, uint(1), o.Version)
+
+	require.Nil(t, r.Offers.Update(ctx, o))
+	assert.Equal(t, uint(2), o.Version)
+
+	stale := *o
+	o.DiscountPercentage = 20
+	o.Stackable, o.ExclusivityGroup, o.Priority = true, "seasonal", 2
+	o.DiscountAmount, o.DiscountCurrency, o.DiscountAmounts = 500, "EUR", money.Amounts{"GBP": 450}
+	require.Nil(t, r.Offers.UpdateIfVersion(ctx, o, 2))
+	assert.Equal(t, uint(3), o.Version)
+	stale.DiscountPercentage = 30
+	assert.Equal(t, repositories.ErrStale, r.Offers.UpdateIfVersion(ctx, &stale, 2))
+
+	got, err := r.Offers.GetByID(ctx, o.ID)
+	require.Nil(t, err)
+	assert.Equal(t, uint(20), got.DiscountPercentage)
+	assert.Equal(t, uint(3), got.Version)
+	assert.True(t, got.Stackable)
+	assert.Equal(t, "seasonal", got.ExclusivityGroup)
+	assert.Equal(t, 2, got.Priority)
+	assert.Equal(t, money.Amounts{"GBP": 450}, got.DiscountAmounts)
+	assert.Equal(t, "EUR", got.DiscountCurrency)
+
+	winter := &offer.Offer{Name: "Winter"}
+	require.Nil(t, r.Offers.Create(ctx, winter))
+	winter.Name = "Summer"
+	assert.NotNil(t, r.Offers.UpdateIfVersion(ctx, winter, 1))
+	err = r.Offers.UpdateIfVersion(ctx, &offer.Offer{Model: gorm.Model{ID: 100}, Name: "Autumn"}, 1)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+
+	u := &user.User{Email: "alice@cc.cc"}
+	require.Nil(t, r.Users.Create(ctx, u))
+	assert.Equal(t, uint(1), u.Version)
+	u.FirstName = "Alice"
+	require.Nil(t, r.Users.UpdateIfVersion(ctx, u, 1))
+	assert.Equal(t, uint(2), u.Version)
+	assert.Equal(t, repositories.ErrStale, r.Users.UpdateIfVersion(ctx, u, 1))
+
+	gotUser, err := r.Users.GetByID(ctx, u.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "Alice", gotUser.FirstName)
+	assert.Equal(t, uint(2), gotUser.Version)
+}
+
+func testDeleteOffer(t *testing.T, r Repos) {
+	o := &offer.Offer{Name: "Summer"}
+	require.Nil(t, r.Offers.Create(ctx, o))
+
+	require.Nil(t, r.Offers.Delete(ctx, o.ID, time.Now()))
+
+	_, err := r.Offers.GetByID(ctx, o.ID)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	offers, err := r.Offers.ListAll(ctx)
+	require.Nil(t, err)
+	assert.Empty(t, offers)
+	err = r.Offers.Delete(ctx, o.ID, time.Now())
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+}
+
+func testRestoreOffer(t *testing.T, r Repos) {
+	o := &offer.Offer{Name: "Summer"}
+	require.Nil(t, r.Offers.Create(ctx, o))
+	_, err := r.Offers.GetDeleted(ctx, o.ID)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	err = r.Offers.Restore(ctx, o.ID)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+
+	// the precision every backend keeps
+	at := time.Now().UTC().Truncate(time.Microsecond)
+	require.Nil(t, r.Offers.Delete(ctx, o.ID, at))
+	deleted, err := r.Offers.GetDeleted(ctx, o.ID)
+	require.Nil(t, err)
+	require.NotNil(t, deleted.DeletedAt)
+	assert.True(t, at.Equal(*deleted.DeletedAt), "deleted at %v, want %v", deleted.DeletedAt, at)
+
+	require.Nil(t, r.Offers.Restore(ctx, o.ID))
+	got, err := r.Offers.GetByID(ctx, o.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "Summer", got.Name)
+	assert.Equal(t, uint(3), got.Version)
+}
+
+func testRevokeVouchers(t *testing.T, r Repos) {
+	alice := &user.User{Email: "alice@cc.cc"}
+	require.Nil(t, r.Users.Create(ctx, alice))
+	summer := &offer.Offer{Name: "Summer"}
+	require.Nil(t, r.Offers.Create(ctx, summer))
+	winter := &offer.Offer{Name: "Winter"}
+	require.Nil(t, r.Offers.Create(ctx, winter))
+	used := &voucher.Voucher{Code: "USED", OfferID: summer.ID, UserID: alice.ID, IsUsed: true}
+	unused := &voucher.Voucher{Code: "UNUSED", OfferID: summer.ID, UserID: alice.ID}
+	other := &voucher.Voucher{Code: "OTHER", OfferID: winter.ID, UserID: alice.ID}
+	for _, v := range []*voucher.Voucher{used, unused, other} {
+		require.Nil(t, r.Vouchers.Create(ctx, v))
+	}
+
+	at := time.Now().UTC().Truncate(time.Microsecond)
+	n, err := r.Vouchers.RevokeUnusedByOffer(ctx, summer.ID, at)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = r.Vouchers.UseCode(ctx, "UNUSED")
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	_, err = r.Vouchers.UseCode(ctx, "USED")
+	assert.Nil(t, err)
+	for _, limit := range []int{0, 10} {
+		vs, err := r.Vouchers.ListByUsers(ctx, []uint{alice.ID}, voucherrepo.ListOptions{Limit: limit})
+		require.Nil(t, err)
+		assert.Len(t, vs, 2)
+		vs, err = r.Vouchers.ListByUsers(ctx, []uint{alice.ID}, voucherrepo.ListOptions{Limit: limit, Deleted: true})
+		require.Nil(t, err)
+		if assert.Len(t, vs, 3) {
+			assert.Equal(t, "UNUSED", vs[1].Code)
+			assert.NotNil(t, vs[1].DeletedAt)
+		}
+	}
+
+	// revoked again by the user they are kept at the time of the offer
+	later := at.Add(time.Second)
+	n, err = r.Vouchers.RevokeUnusedByUser(ctx, alice.ID, later)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+	n, err = r.Vouchers.RestoreByUser(ctx, alice.ID, later)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = r.Vouchers.UseCode(ctx, "OTHER")
+	assert.Nil(t, err)
+	_, err = r.Vouchers.UseCode(ctx, "UNUSED")
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+
+	n, err = r.Vouchers.RestoreByOffer(ctx, summer.ID, at)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = r.Vouchers.UseCode(ctx, "UNUSED")
+	assert.Nil(t, err)
+}
+
+func testDeleteUser(t *testing.T, r Repos) {
+	alice := &user.User{FirstName: "Alice", LastName: "Doe", Email: "alice@cc.cc", Birthday: "1990-05-17",
+		Phone: "+4915112345678", Locale: "de"}
+	require.Nil(t, r.Users.Create(ctx, alice))
+
+	require.Nil(t, r.Users.Delete(ctx, alice.ID, time.Now()))
+
+	_, err := r.Users.GetByID(ctx, alice.ID)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	_, err = r.Users.GetByEmail(ctx, "alice@cc.cc")
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	err = r.Users.Delete(ctx, alice.ID, time.Now())
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+
+	// the email is free again
+	require.Nil(t, r.Users.Create(ctx, &user.User{Email: "alice@cc.cc"}))
+
+	require.Nil(t, r.Users.Restore(ctx, alice.ID))
+	got, err := r.Users.GetByID(ctx, alice.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "", got.FirstName)
+	assert.Equal(t, "", got.LastName)
+	assert.Equal(t, user.AnonymousEmail(alice.ID), got.Email)
+	assert.Equal(t, "", got.Birthday)
+	assert.Equal(t, "", got.Phone)
+	assert.Equal(t, "", got.Locale)
+	assert.Equal(t, uint(3), got.Version)
+}
+
+func testPurge(t *testing.T, r Repos) {
+	cutoff := time.Now().UTC()
+	before, after := cutoff.Add(-time.Hour), cutoff.Add(time.Hour)
+
+	old := &offer.Offer{Name: "Old"}
+	recent := &offer.Offer{Name: "Recent"}
+	live := &offer.Offer{Name: "Live"}
+	for _, o := range []*offer.Offer{old, recent, live} {
+		require.Nil(t, r.Offers.Create(ctx, o))
+	}
+	require.Nil(t, r.Offers.Delete(ctx, old.ID, before))
+	require.Nil(t, r.Offers.Delete(ctx, recent.ID, after))
+
+	alice := &user.User{Email: "alice@cc.cc"}
+	bob := &user.User{Email: "bob@cc.cc"}
+	require.Nil(t, r.Users.Create(ctx, alice))
+	require.Nil(t, r.Users.Create(ctx, bob))
+	require.Nil(t, r.Users.Delete(ctx, alice.ID, before))
+
+	used := &voucher.Voucher{Code: "USED", OfferID: old.ID, UserID: alice.ID, IsUsed: true}
+	unused := &voucher.Voucher{Code: "UNUSED", OfferID: old.ID, UserID: alice.ID}
+	require.Nil(t, r.Vouchers.Create(ctx, used))
+	require.Nil(t, r.Vouchers.Create(ctx, unused))
+	_, err := r.Vouchers.RevokeUnusedByOffer(ctx, old.ID, before)
+	require.Nil(t, err)
+
+	n, err := r.Offers.Purge(ctx, cutoff)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+	n, err = r.Users.Purge(ctx, cutoff)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+	n, err = r.Vouchers.Purge(ctx, cutoff)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+
+	_, err = r.Offers.GetDeleted(ctx, old.ID)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	_, err = r.Offers.GetDeleted(ctx, recent.ID)
+	assert.Nil(t, err)
+	_, err = r.Users.GetDeleted(ctx, alice.ID)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	_, err = r.Users.GetByID(ctx, bob.ID)
+	assert.Nil(t, err)
+	// the redemption history outlives the purge
+	got, err := r.Vouchers.UseCode(ctx, "USED")
+	require.Nil(t, err)
+	assert.Equal(t, alice.ID, got.UserID)
+}
+
+func testConcurrency(t *testing.T, r Repos) {
+	const workers, emails = 8, 5
+	errs := make(chan error, workers*emails)
+	done := make(chan struct{})
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := 0; i < emails; i++ {
+				email := string(rune('a'+i)) + "@cc.cc"
+				errs <- r.Users.Create(ctx, &user.User{Email: email})
+			}
+			done <- struct{}{}
+		}()
+	}
+	for w := 0; w < workers; w++ {
+		<-done
+	}
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		if err == nil {
+			created++
+		}
+	}
+	assert.Equal(t, emails, created)
+
+	users, err := r.Users.ListAll(ctx)
+	require.Nil(t, err)
+	assert.Len(t, users, emails)
+}
+
+// testConcurrentRedeem races redemptions of one voucher, as online ones
+// and offline reconciliations do, only one of them may use it
+func testConcurrentRedeem(t *testing.T, r Repos) {
+	const workers = 8
+	v := &voucher.Voucher{Code: "ABCD1234", OfferID: 1, ExpireTime: time.Now().Add(time.Hour)}
+	require.Nil(t, r.Vouchers.Create(ctx, v))
+	at := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	results := make(chan bool, workers)
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			marked, err := r.Vouchers.MarkUsed(ctx, v.ID, at.Add(time.Duration(w)*time.Minute), money.Money{})
+			assert.Nil(t, err)
+			results <- marked
+		}(w)
+	}
+	marked := 0
+	for w := 0; w < workers; w++ {
+		if <-results {
+			marked++
+		}
+	}
+	assert.Equal(t, 1, marked)
+
+	got, err := r.Vouchers.GetByID(ctx, v.ID)
+	require.Nil(t, err)
+	assert.True(t, got.IsUsed)
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			reversed, err := r.Vouchers.MarkUnused(ctx, v.ID)
+			assert.Nil(t, err)
+			results <- reversed
+		}()
+	}
+	reversed := 0
+	for w := 0; w < workers; w++ {
+		if <-results {
+			reversed++
+		}
+	}
+	assert.Equal(t, 1, reversed)
+}
+
+func userIDs(users []*user.User) []uint {
+	ids := make([]uint, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+	return ids
+}
+
+func testPrivacyRecords(t *testing.T, r Repos) {
+	export := &privacy.Record{UserID: 1, Action: privacy.ActionExport}
+	require.Nil(t, r.Privacy.Create(ctx, export))
+	assert.NotZero(t, export.ID)
+	assert.False(t, export.CreatedAt.IsZero())
+	require.Nil(t, r.Privacy.Create(ctx, &privacy.Record{UserID: 2, Action: privacy.ActionExport}))
+	require.Nil(t, r.Privacy.Create(ctx, &privacy.Record{UserID: 1, Action: privacy.ActionErasure}))
+
+	records, err := r.Privacy.ListByUser(ctx, 1)
+	require.Nil(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, privacy.ActionExport, records[0].Action)
+	assert.Equal(t, privacy.ActionErasure, records[1].Action)
+
+	records, err = r.Privacy.ListByUser(ctx, 3)
+	require.Nil(t, err)
+	assert.Empty(t, records)
+}
+
+// seedStats issues four vouchers of a 20% offer on 2020-06-01: redeemed
+// after 1h and 3h, one expired and one still valid, plus a voucher of
+// another offer
+func seedStats(t *testing.T, r Repos) (*offer.Offer, time.Time) {
+	day := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	o := &offer.Offer{Name: "Stats", DiscountPercentage: 20}
+	other := &offer.Offer{Name: "Other", DiscountPercentage: 5}
+	require.Nil(t, r.Offers.Create(ctx, o))
+	require.Nil(t, r.Offers.Create(ctx, other))
+
+	at := func(h time.Duration) time.Time { return day.Add(h * time.Hour) }
+	for _, v := range []*voucher.Voucher{
+		{Code: "STATS1", OfferID: o.ID, UserID: 1, IsUsed: true, UsedAt: at(2), ExpireTime: at(48)},
+		{Code: "STATS2", OfferID: o.ID, UserID: 2, IsUsed: true, UsedAt: at(4), ExpireTime: at(48)},
+		{Code: "STATS3", OfferID: o.ID, UserID: 3, ExpireTime: at(12)},
+		{Code: "STATS4", OfferID: o.ID, UserID: 4, ExpireTime: at(48)},
+		{Code: "OTHER1", OfferID: other.ID, UserID: 1, IsUsed: true, UsedAt: at(3), ExpireTime: at(48)},
+	} {
+		v.Model.CreatedAt = at(1)
+		require.Nil(t, r.Vouchers.Create(ctx, v))
+	}
+	return o, day
+}
+
+func testOfferStats(t *testing.T, r Repos) {
+	o, day := seedStats(t, r)
+
+	st, err := r.Vouchers.OfferStats(ctx, o.ID, day.Add(24*time.Hour))
+	require.Nil(t, err)
+	assert.Equal(t, &stats.Offer{
+		OfferID:        o.ID,
+		Issued:         4,
+		Redeemed:       2,
+		Expired:        1,
+		RedemptionRate: 0.5,
+		TimeToRedeem:   stats.Percentiles{P50: 3600, P90: 3 * 3600, P99: 3 * 3600},
+		DiscountGiven:  40,
+	}, st)
+
+	st, err = r.Vouchers.OfferStats(ctx, 999, day)
+	require.Nil(t, err)
+	assert.Equal(t, &stats.Offer{OfferID: 999}, st)
+}
+
+func testSeries(t *testing.T, r Repos) {
+	o, day := seedStats(t, r)
+
+	series, err := r.Vouchers.Series(ctx, o.ID, time.Hour, day, day.Add(24*time.Hour))
+	require.Nil(t, err)
+	assert.Equal(t, []stats.Point{
+		{Start: day.Add(time.Hour), Issued: 4},
+		{Start: day.Add(2 * time.Hour), Redeemed: 1},
+		{Start: day.Add(4 * time.Hour), Redeemed: 1},
+	}, series)
+
+	series, err = r.Vouchers.Series(ctx, o.ID, 24*time.Hour, day, day.Add(3*time.Hour))
+	require.Nil(t, err)
+	assert.Equal(t, []stats.Point{{Start: day, Issued: 4, Redeemed: 1}}, series)
+}
+
+func testDailyStats(t *testing.T, r Repos) {
+	o, day := seedStats(t, r)
+
+	daily, err := r.Vouchers.DailyByOffer(ctx, day)
+	require.Nil(t, err)
+	require.Len(t, daily, 2)
+	assert.Equal(t, stats.Daily{Day: day, OfferID: o.ID, TenantID: tenant.DefaultID, Issued: 4, Redeemed: 2, Expired: 1, DiscountGiven: 40}, *daily[0])
+	assert.Equal(t, int64(5), daily[1].DiscountGiven)
+
+	// refreshing a day replaces its rollup
+	require.Nil(t, r.Stats.ReplaceDay(ctx, day, daily))
+	require.Nil(t, r.Stats.ReplaceDay(ctx, day, daily[:1]))
+	next := day.Add(24 * time.Hour)
+	require.Nil(t, r.Stats.ReplaceDay(ctx, next, []*stats.Daily{{Day: next, OfferID: o.ID, Issued: 1}}))
+
+	rollup, err := r.Stats.ListDaily(ctx, 0, day, next.Add(24*time.Hour))
+	require.Nil(t, err)
+	require.Len(t, rollup, 2)
+	assert.True(t, rollup[0].Day.Equal(day))
+	assert.Equal(t, int64(2), rollup[0].Redeemed)
+	assert.True(t, rollup[1].Day.Equal(next))
+
+	rollup, err = r.Stats.ListDaily(ctx, o.ID+1, day, next)
+	require.Nil(t, err)
+	assert.Empty(t, rollup)
+}
+
+// testFixedDiscountStats sums the discounts recorded on redemption by
+// currency, those redeemed without an order total at the amount of the offer
+func testFixedDiscountStats(t *testing.T, r Repos) {
+	day := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	o := &offer.Offer{Name: "Fiver", DiscountAmount: 500, DiscountCurrency: "EUR", DiscountAmounts: money.Amounts{"GBP": 450}}
+	require.Nil(t, r.Offers.Create(ctx, o))
+	for _, v := range []*voucher.Voucher{
+		{Code: "FIVER1", OfferID: o.ID, UserID: 1, IsUsed: true, UsedAt: day.Add(2 * time.Hour), ExpireTime: day.Add(48 * time.Hour)},
+		{Code: "FIVER2", OfferID: o.ID, UserID: 2, IsUsed: true, UsedAt: day.Add(3 * time.Hour), ExpireTime: day.Add(48 * time.Hour),
+			DiscountAmount: 450, DiscountCurrency: "GBP"},
+		// capped by the order
+		{Code: "FIVER3", OfferID: o.ID, UserID: 3, IsUsed: true, UsedAt: day.Add(4 * time.Hour), ExpireTime: day.Add(48 * time.Hour),
+			DiscountAmount: 300, DiscountCurrency: "GBP"},
+		{Code: "FIVER4", OfferID: o.ID, UserID: 4, ExpireTime: day.Add(48 * time.Hour)},
+	} {
+		v.Model.CreatedAt = day.Add(time.Hour)
+		require.Nil(t, r.Vouchers.Create(ctx, v))
+	}
+	expected := money.Amounts{"EUR": 500, "GBP": 750}
+
+	st, err := r.Vouchers.OfferStats(ctx, o.ID, day.Add(24*time.Hour))
+	require.Nil(t, err)
+	assert.Equal(t, int64(3), st.Redeemed)
+	assert.Zero(t, st.DiscountGiven)
+	assert.Equal(t, expected, st.DiscountAmounts)
+
+	daily, err := r.Vouchers.DailyByOffer(ctx, day)
+	require.Nil(t, err)
+	require.Len(t, daily, 1)
+	assert.Equal(t, expected, daily[0].DiscountAmounts)
+
+	require.Nil(t, r.Stats.ReplaceDay(ctx, day, daily))
+	rollup, err := r.Stats.ListDaily(ctx, o.ID, day, day.Add(24*time.Hour))
+	require.Nil(t, err)
+	require.Len(t, rollup, 1)
+	assert.Equal(t, expected, rollup[0].DiscountAmounts)
+
+	// nothing redeemed, no amounts
+	daily, err = r.Vouchers.DailyByOffer(ctx, day.Add(-24*time.Hour))
+	require.Nil(t, err)
+	assert.Empty(t, daily)
+	st, err = r.Vouchers.OfferStats(ctx, 999, day)
+	require.Nil(t, err)
+	assert.Empty(t, st.DiscountAmounts)
+}
+
+func testTenants(t *testing.T, r Repos) {
+	acme := &tenant.Tenant{Name: "Acme", Slug: "acme", APIKey: "acme-key"}
+	require.Nil(t, r.Tenants.Create(ctx, acme))
+	assert.NotZero(t, acme.ID)
+	assert.NotNil(t, r.Tenants.Create(ctx, &tenant.Tenant{Name: "Acme 2", Slug: "acme"}))
+	// keys live with the API keys, tenants without a legacy key don't clash
+	require.Nil(t, r.Tenants.Create(ctx, &tenant.Tenant{Name: "Acme 2", Slug: "acme2"}))
+
+	got, err := r.Tenants.GetBySlug(ctx, "acme")
+	require.Nil(t, err)
+	assert.Equal(t, acme.ID, got.ID)
+	assert.Empty(t, got.APIKey, "plain keys are not stored")
+	got, err = r.Tenants.GetByID(ctx, acme.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "Acme", got.Name)
+
+	_, err = r.Tenants.GetBySlug(ctx, "globex")
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	tenants, err := r.Tenants.ListAll(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, acme.ID, tenants[len(tenants)-2].ID)
+}
+
+func testAPIKeys(t *testing.T, r Repos) {
+	first := &apikey.Key{TenantID: 2, Name: "ci", Prefix: "0a1b2c3d", Hash: strings.Repeat("a", 64), Plain: "0a1b2c3d"}
+	second := &apikey.Key{TenantID: 2, Name: "ci", Prefix: "4e5f6a7b", Hash: strings.Repeat("b", 64)}
+	other := &apikey.Key{TenantID: 3, Name: "pos", Prefix: "8c9d0e1f", Hash: strings.Repeat("c", 64)}
+	for _, k := range []*apikey.Key{first, second, other} {
+		require.Nil(t, r.APIKeys.Create(ctx, k))
+	}
+	assert.NotNil(t, r.APIKeys.Create(ctx, &apikey.Key{TenantID: 3, Name: "dup", Prefix: "x", Hash: first.Hash}))
+
+	got, err := r.APIKeys.GetByHash(ctx, second.Hash)
+	require.Nil(t, err)
+	assert.Equal(t, second.ID, got.ID)
+	assert.Equal(t, uint(2), got.TenantID)
+	assert.Nil(t, got.ExpiresAt)
+	_, err = r.APIKeys.GetByHash(ctx, strings.Repeat("d", 64))
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+
+	got, err = r.APIKeys.Get(ctx, 2, first.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "0a1b2c3d", got.Prefix)
+	assert.Empty(t, got.Plain)
+	_, err = r.APIKeys.Get(ctx, 3, first.ID)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+
+	at := time.Date(2021, 3, 1, 8, 0, 0, 0, time.UTC)
+	require.Nil(t, r.APIKeys.Expire(ctx, got, at))
+	assert.True(t, got.ExpiresAt.Equal(at))
+	ks, err := r.APIKeys.List(ctx, 2)
+	require.Nil(t, err)
+	require.Len(t, ks, 2)
+	assert.Equal(t, first.ID, ks[0].ID)
+	assert.True(t, ks[0].ExpiresAt.Equal(at))
+	assert.False(t, ks[0].Active(at))
+	assert.True(t, ks[1].Active(at))
+}
+
+// testTenantScoping gives two tenants the same offer name, email and code,
+// neither sees or touches the rows of the other
+func testTenantScoping(t *testing.T, r Repos) {
+	ctxA, ctxB := tenant.NewContext(ctx, 2), tenant.NewContext(ctx, 3)
+	seed := func(ctx context.Context) (*offer.Offer, *user.User, *voucher.Voucher) {
+		o := &offer.Offer{Name: "Summer", DiscountPercentage: 10}
+		require.Nil(t, r.Offers.Create(ctx, o))
+		u := &user.User{Email: "alice@cc.cc"}
+		require.Nil(t, r.Users.Create(ctx, u))
+		v := &voucher.Voucher{Code: "SUMMER01", OfferID: o.ID, UserID: u.ID, ExpireTime: time.Now().Add(time.Hour)}
+		require.Nil(t, r.Vouchers.Create(ctx, v))
+		require.Nil(t, r.Privacy.Create(ctx, &privacy.Record{UserID: u.ID, Action: privacy.ActionExport}))
+		return o, u, v
+	}
+	offerA, userA, voucherA := seed(ctxA)
+	offerB, userB, voucherB := seed(ctxB)
+
+	got, err := r.Offers.GetByName(ctxA, "Summer")
+	require.Nil(t, err)
+	assert.Equal(t, offerA.ID, got.ID)
+	gotUser, err := r.Users.GetByEmail(ctxB, "alice@cc.cc")
+	require.Nil(t, err)
+	assert.Equal(t, userB.ID, gotUser.ID)
+	gotVoucher, err := r.Vouchers.UseCode(ctxB, "SUMMER01")
+	require.Nil(t, err)
+	assert.Equal(t, voucherB.ID, gotVoucher.ID)
+
+	_, err = r.Offers.GetByID(ctxB, offerA.ID)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	_, err = r.Users.GetByID(ctxB, userA.ID)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	_, err = r.Vouchers.GetByID(ctxB, voucherA.ID)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	offers, err := r.Offers.GetByIDs(ctxB, []uint{offerA.ID, offerB.ID})
+	require.Nil(t, err)
+	if assert.Len(t, offers, 1) {
+		assert.Equal(t, offerB.ID, offers[0].ID)
+	}
+	users, err := r.Users.ListAll(ctxA)
+	require.Nil(t, err)
+	assert.Equal(t, []uint{userA.ID}, userIDs(users))
+	vouchers, err := r.Vouchers.ListByUsers(ctxB, []uint{userA.ID}, voucherrepo.ListOptions{})
+	require.Nil(t, err)
+	assert.Empty(t, vouchers)
+	records, err := r.Privacy.ListByUser(ctxB, userA.ID)
+	require.Nil(t, err)
+	assert.Empty(t, records)
+	st, err := r.Vouchers.OfferStats(ctxB, offerA.ID, time.Now())
+	require.Nil(t, err)
+	assert.Zero(t, st.Issued)
+
+	stale := *offerA
+	stale.Name = "Winter"
+	err = r.Offers.UpdateIfVersion(ctxB, &stale, offerA.Version)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	err = r.Offers.Delete(ctxB, offerA.ID, time.Now())
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	n, err := r.Vouchers.RevokeUnusedByOffer(ctxB, offerA.ID, time.Now())
+	require.Nil(t, err)
+	assert.Zero(t, n)
+
+	require.Nil(t, r.Users.Delete(ctxA, userA.ID, time.Now().Add(-time.Hour)))
+	n, err = r.Users.Purge(ctxB, time.Now())
+	require.Nil(t, err)
+	assert.Zero(t, n)
+	n, err = r.Users.Purge(ctxA, time.Now())
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+// testGiftCards issues a card, debits and tops it up, and checks the
+// ledger balances per transaction and adds up to the balance
+func testGiftCards(t *testing.T, r Repos) {
+	tx := func(id, kind, other string, amount int64, ref string) []giftcard.Entry {
+		return []giftcard.Entry{
+			{TxID: id, Kind: kind, Account: giftcard.AccountCard, Amount: amount, Currency: "EUR", Reference: ref},
+			{TxID: id, Kind: kind, Account: other, Amount: -amount, Currency: "EUR", Reference: ref},
+		}
+	}
+	card := &giftcard.Card{Code: "GIFT0001", Currency: "EUR", InitialBalance: 5000}
+	require.Nil(t, r.GiftCards.Create(ctx, card, tx("t1", giftcard.KindIssue, giftcard.AccountFunding, 5000, "")))
+	assert.NotZero(t, card.ID)
+	assert.Equal(t, int64(5000), card.Balance)
+	assert.NotNil(t, r.GiftCards.Create(ctx, &giftcard.Card{Code: "GIFT0001", Currency: "EUR"},
+		tx("t0", giftcard.KindIssue, giftcard.AccountFunding, 100, "")))
+
+	got, err := r.GiftCards.GetByCode(ctx, "GIFT0001")
+	require.Nil(t, err)
+	assert.Equal(t, uint(1), got.Version)
+	stale := *got
+
+	require.Nil(t, r.GiftCards.Post(ctx, got, tx("t2", giftcard.KindDebit, giftcard.AccountOrders, -1200, "order-1")))
+	assert.Equal(t, int64(3800), got.Balance)
+	assert.Equal(t, uint(2), got.Version)
+	assert.Equal(t, repositories.ErrStale,
+		r.GiftCards.Post(ctx, &stale, tx("t3", giftcard.KindDebit, giftcard.AccountOrders, -100, "order-2")))
+	assert.Equal(t, giftcard.ErrInsufficientBalance,
+		r.GiftCards.Post(ctx, got, tx("t3", giftcard.KindDebit, giftcard.AccountOrders, -3801, "order-2")))
+	unbalanced := tx("t3", giftcard.KindTopUp, giftcard.AccountFunding, 100, "")[:1]
+	assert.Equal(t, giftcard.ErrUnbalanced, r.GiftCards.Post(ctx, got, unbalanced))
+	require.Nil(t, r.GiftCards.Post(ctx, got, tx("t4", giftcard.KindTopUp, giftcard.AccountFunding, 700, "")))
+
+	got, err = r.GiftCards.GetByCode(ctx, "GIFT0001")
+	require.Nil(t, err)
+	assert.Equal(t, int64(4500), got.Balance)
+	entries, err := r.GiftCards.ListEntries(ctx, got.ID)
+	require.Nil(t, err)
+	require.Len(t, entries, 6)
+	sums := map[string]int64{}
+	var onCard int64
+	for _, e := range entries {
+		sums[e.TxID] += e.Amount
+		if e.Account == giftcard.AccountCard {
+			onCard += e.Amount
+		}
+	}
+	assert.Equal(t, map[string]int64{"t1": 0, "t2": 0, "t4": 0}, sums)
+	assert.Equal(t, got.Balance, onCard)
+	assert.Equal(t, "order-1", entries[2].Reference)
+
+	other := tenant.NewContext(ctx, 2)
+	_, err = r.GiftCards.GetByCode(other, "GIFT0001")
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	err = r.GiftCards.Post(other, got, tx("t5", giftcard.KindTopUp, giftcard.AccountFunding, 100, ""))
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	entries, err = r.GiftCards.ListEntries(other, got.ID)
+	require.Nil(t, err)
+	assert.Empty(t, entries)
+}
+
+func testReferrals(t *testing.T, r Repos) {
+	code := &referral.Code{UserID: 1, Code: "REF00001"}
+	require.Nil(t, r.Referrals.CreateCode(ctx, code))
+	assert.NotNil(t, r.Referrals.CreateCode(ctx, &referral.Code{UserID: 2, Code: "REF00001"}))
+	assert.NotNil(t, r.Referrals.CreateCode(ctx, &referral.Code{UserID: 1, Code: "REF00002"}))
+	require.Nil(t, r.Referrals.CreateCode(tenant.NewContext(ctx, 2), &referral.Code{UserID: 1, Code: "REF00001"}))
+
+	got, err := r.Referrals.GetByCode(ctx, "REF00001")
+	require.Nil(t, err)
+	assert.Equal(t, uint(1), got.UserID)
+	got, err = r.Referrals.GetCode(ctx, 1)
+	require.Nil(t, err)
+	assert.Equal(t, "REF00001", got.Code)
+	_, err = r.Referrals.GetCode(ctx, 2)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+
+	since := time.Now().Add(-time.Hour)
+	for i, domain := range []string{"cc.cc", "cc.cc", "dd.dd"} {
+		ref := &referral.Referral{ReferrerID: 1, RefereeID: uint(10 + i), RefereeDomain: domain, Status: referral.StatusPending}
+		require.Nil(t, r.Referrals.Create(ctx, ref))
+	}
+	assert.NotNil(t, r.Referrals.Create(ctx, &referral.Referral{ReferrerID: 2, RefereeID: 10, Status: referral.StatusPending}))
+	require.Nil(t, r.Referrals.Create(ctx, &referral.Referral{ReferrerID: 2, RefereeID: 20, RefereeDomain: "cc.cc",
+		Status: referral.StatusRejected, Reason: referral.ReasonSelfReferral}))
+	n, err := r.Referrals.CountByDomain(ctx, 1, "cc.cc", since)
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+	refs, err := r.Referrals.ListByUser(ctx, 10)
+	require.Nil(t, err)
+	if assert.Len(t, refs, 1) {
+		assert.Equal(t, uint(1), refs[0].ReferrerID)
+	}
+	refs, err = r.Referrals.ListByUser(ctx, 1)
+	require.Nil(t, err)
+	assert.Len(t, refs, 3)
+	refs, err = r.Referrals.ListByUser(tenant.NewContext(ctx, 2), 1)
+	require.Nil(t, err)
+	assert.Empty(t, refs)
+	n, err = r.Referrals.CountByDomain(ctx, 1, "cc.cc", time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	assert.Zero(t, n)
+
+	ref, err := r.Referrals.GetByReferee(ctx, 10)
+	require.Nil(t, err)
+	stale := *ref
+	at := time.Now()
+	ref.Status, ref.ReferrerVoucherID, ref.RefereeVoucherID, ref.RewardedAt = referral.StatusRewarded, 5, 6, &at
+	require.Nil(t, r.Referrals.UpdateIfStatus(ctx, ref, referral.StatusPending))
+	stale.Status = referral.StatusRejected
+	assert.Equal(t, repositories.ErrStale, r.Referrals.UpdateIfStatus(ctx, &stale, referral.StatusPending))
+	got2, err := r.Referrals.GetByReferee(ctx, 10)
+	require.Nil(t, err)
+	assert.Equal(t, referral.StatusRewarded, got2.Status)
+	assert.Equal(t, uint(6), got2.RefereeVoucherID)
+	require.NotNil(t, got2.RewardedAt)
+
+	st, err := r.Referrals.Stats(ctx, 0)
+	require.Nil(t, err)
+	assert.Equal(t, &referral.Stats{Total: 4, Pending: 2, Rewarded: 1, Rejected: 1, VouchersIssued: 2,
+		Reasons: map[string]int64{referral.ReasonSelfReferral: 1}}, st)
+	st, err = r.Referrals.Stats(ctx, 1)
+	require.Nil(t, err)
+	assert.Equal(t, int64(3), st.Total)
+	st, err = r.Referrals.Stats(tenant.NewContext(ctx, 2), 0)
+	require.Nil(t, err)
+	assert.Zero(t, st.Total)
+}
+
+func testTriggers(t *testing.T, r Repos) {
+	welcome := &trigger.Trigger{Event: trigger.EventUserRegistered, OfferID: 1, ValidityDays: 14, Active: true}
+	birthday := &trigger.Trigger{Event: trigger.EventBirthday, OfferID: 2, ValidityDays: 7, Active: true}
+	paused := &trigger.Trigger{Event: trigger.EventUserRegistered, OfferID: 3, ValidityDays: 1}
+	for _, tr := range []*trigger.Trigger{welcome, birthday, paused} {
+		require.Nil(t, r.Triggers.Create(ctx, tr))
+	}
+	require.Nil(t, r.Triggers.Create(tenant.NewContext(ctx, 2), &trigger.Trigger{
+		Event: trigger.EventUserRegistered, OfferID: 4, ValidityDays: 1, Active: true}))
+
+	all, err := r.Triggers.List(ctx)
+	require.Nil(t, err)
+	assert.Len(t, all, 3)
+	active, err := r.Triggers.ListActive(ctx, trigger.EventUserRegistered)
+	require.Nil(t, err)
+	if assert.Len(t, active, 1) {
+		assert.Equal(t, welcome.ID, active[0].ID)
+	}
+
+	paused.Active, paused.ValidityDays = true, 30
+	require.Nil(t, r.Triggers.Update(ctx, paused))
+	got, err := r.Triggers.GetByID(ctx, paused.ID)
+	require.Nil(t, err)
+	assert.True(t, got.Active)
+	assert.Equal(t, 30, got.ValidityDays)
+	assert.Equal(t, trigger.EventUserRegistered, got.Event)
+
+	require.Nil(t, r.Triggers.Delete(ctx, paused.ID))
+	_, err = r.Triggers.GetByID(ctx, paused.ID)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	err = r.Triggers.Delete(ctx, paused.ID)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	err = r.Triggers.Update(ctx, paused)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+
+	i := &trigger.Issuance{TriggerID: birthday.ID, UserID: 1, Occurrence: "2020"}
+	require.Nil(t, r.Triggers.CreateIssuance(ctx, i))
+	assert.NotNil(t, r.Triggers.CreateIssuance(ctx, &trigger.Issuance{TriggerID: birthday.ID, UserID: 1, Occurrence: "2020"}))
+	require.Nil(t, r.Triggers.CreateIssuance(ctx, &trigger.Issuance{TriggerID: birthday.ID, UserID: 1, Occurrence: "2021"}))
+	i.VoucherID = 9
+	require.Nil(t, r.Triggers.SetVoucher(ctx, i))
+	gotI, err := r.Triggers.GetIssuance(ctx, birthday.ID, 1, "2020")
+	require.Nil(t, err)
+	assert.Equal(t, uint(9), gotI.VoucherID)
+
+	require.Nil(t, r.Triggers.DeleteIssuance(ctx, i.ID))
+	_, err = r.Triggers.GetIssuance(ctx, birthday.ID, 1, "2020")
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	require.Nil(t, r.Triggers.CreateIssuance(ctx, &trigger.Issuance{TriggerID: birthday.ID, UserID: 1, Occurrence: "2020"}))
+}
+
+func testNotificationTemplates(t *testing.T, r Repos) {
+	fallback := &notification.Template{Channel: notification.ChannelEmail, Locale: "en", Subject: "Your voucher", Body: "{{.Code}}"}
+	own := &notification.Template{OfferID: 1, Channel: notification.ChannelEmail, Locale: "en", Subject: "Spring", Body: "{{.Code}}"}
+	for _, tpl := range []*notification.Template{fallback, own} {
+		require.Nil(t, r.Notifications.CreateTemplate(ctx, tpl))
+	}
+	assert.NotNil(t, r.Notifications.CreateTemplate(ctx, &notification.Template{
+		OfferID: 1, Channel: notification.ChannelEmail, Locale: "en", Body: "again"}))
+	require.Nil(t, r.Notifications.CreateTemplate(ctx, &notification.Template{
+		OfferID: 1, Channel: notification.ChannelSMS, Locale: "en", Body: "{{.Code}}"}))
+	require.Nil(t, r.Notifications.CreateTemplate(tenant.NewContext(ctx, 2), &notification.Template{
+		OfferID: 1, Channel: notification.ChannelEmail, Locale: "en", Body: "{{.Code}}"}))
+
+	all, err := r.Notifications.ListTemplates(ctx)
+	require.Nil(t, err)
+	if assert.Len(t, all, 3) {
+		assert.Equal(t, fallback.ID, all[0].ID)
+	}
+
+	own.Subject, own.Body, own.Locale = "Summer", "{{.Offer}}", "de"
+	require.Nil(t, r.Notifications.UpdateTemplate(ctx, own))
+	got, err := r.Notifications.GetTemplate(ctx, own.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "Summer", got.Subject)
+	assert.Equal(t, "{{.Offer}}", got.Body)
+	assert.Equal(t, "en", got.Locale)
+
+	require.Nil(t, r.Notifications.DeleteTemplate(ctx, own.ID))
+	_, err = r.Notifications.GetTemplate(ctx, own.ID)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	err = r.Notifications.DeleteTemplate(ctx, own.ID)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	err = r.Notifications.UpdateTemplate(ctx, own)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+}
+
+func testDeliveries(t *testing.T, r Repos) {
+	now := time.Now().UTC().Truncate(time.Second)
+	email := &notification.Delivery{VoucherID: 1, UserID: 1, Channel: notification.ChannelEmail,
+		Status: notification.StatusPending, NextAttemptAt: now.Add(-time.Minute)}
+	sms := &notification.Delivery{VoucherID: 1, UserID: 1, Channel: notification.ChannelSMS,
+		Status: notification.StatusPending, NextAttemptAt: now.Add(-time.Hour)}
+	later := &notification.Delivery{VoucherID: 2, UserID: 2, Channel: notification.ChannelEmail,
+		Status: notification.StatusPending, NextAttemptAt: now.Add(time.Hour)}
+	for _, d := range []*notification.Delivery{email, sms, later} {
+		require.Nil(t, r.Notifications.CreateDelivery(ctx, d))
+	}
+	assert.NotNil(t, r.Notifications.CreateDelivery(ctx, &notification.Delivery{VoucherID: 1, UserID: 1,
+		Channel: notification.ChannelEmail, Status: notification.StatusPending, NextAttemptAt: now}))
+	require.Nil(t, r.Notifications.CreateDelivery(tenant.NewContext(ctx, 2), &notification.Delivery{VoucherID: 1,
+		UserID: 1, Channel: notification.ChannelEmail, Status: notification.StatusPending, NextAttemptAt: now}))
+
+	due, err := r.Notifications.ListDue(ctx, now, 10)
+	require.Nil(t, err)
+	if assert.Len(t, due, 2) {
+		assert.Equal(t, sms.ID, due[0].ID)
+		assert.Equal(t, email.ID, due[1].ID)
+	}
+	due, err = r.Notifications.ListDue(ctx, now, 1)
+	require.Nil(t, err)
+	assert.Len(t, due, 1)
+
+	// a claim moves the delivery out of the due ones, a second claim of the
+	// same attempt loses
+	stale := *email
+	claimed, err := r.Notifications.Claim(ctx, email, now.Add(time.Minute))
+	require.Nil(t, err)
+	assert.True(t, claimed)
+	assert.Equal(t, 1, email.Attempts)
+	claimed, err = r.Notifications.Claim(ctx, &stale, now.Add(time.Minute))
+	require.Nil(t, err)
+	assert.False(t, claimed)
+	due, err = r.Notifications.ListDue(ctx, now, 10)
+	require.Nil(t, err)
+	assert.Len(t, due, 1)
+
+	sent := now.Add(time.Second)
+	email.Status, email.SentAt = notification.StatusSent, &sent
+	require.Nil(t, r.Notifications.UpdateDelivery(ctx, email))
+	sms.Status, sms.LastError = notification.StatusSkipped, "no phone number"
+	require.Nil(t, r.Notifications.UpdateDelivery(ctx, sms))
+	claimed, err = r.Notifications.Claim(ctx, sms, now)
+	require.Nil(t, err)
+	assert.False(t, claimed, "only pending deliveries are claimed")
+
+	ds, err := r.Notifications.ListDeliveries(ctx, 1)
+	require.Nil(t, err)
+	if assert.Len(t, ds, 2) {
+		assert.Equal(t, notification.StatusSent, ds[0].Status)
+		assert.Equal(t, 1, ds[0].Attempts)
+		if assert.NotNil(t, ds[0].SentAt) {
+			assert.True(t, sent.Equal(*ds[0].SentAt))
+		}
+		assert.Equal(t, notification.StatusSkipped, ds[1].Status)
+		assert.Equal(t, "no phone number", ds[1].LastError)
+	}
+	due, err = r.Notifications.ListDue(ctx, now.Add(2*time.Hour), 10)
+	require.Nil(t, err)
+	if assert.Len(t, due, 1) {
+		assert.Equal(t, later.ID, due[0].ID)
+	}
+	ds, err = r.Notifications.ListDeliveriesByUser(ctx, 1)
+	require.Nil(t, err)
+	if assert.Len(t, ds, 2) {
+		assert.Equal(t, email.ID, ds[0].ID)
+		assert.Equal(t, sms.ID, ds[1].ID)
+	}
+	ds, err = r.Notifications.ListDeliveriesByUser(ctx, 2)
+	require.Nil(t, err)
+	if assert.Len(t, ds, 1) {
+		assert.Equal(t, later.ID, ds[0].ID)
+	}
+}
+
+func testOfflineRedemptions(t *testing.T, r Repos) {
+	at := time.Now().UTC().Truncate(time.Second)
+	accepted := &offline.Redemption{Terminal: "till-1", Reference: "r1", Code: "A", VoucherID: 1,
+		RedeemedAt: at, Status: offline.StatusAccepted}
+	double := &offline.Redemption{Terminal: "till-2", Reference: "r1", Code: "A", VoucherID: 1,
+		RedeemedAt: at.Add(time.Minute), Status: offline.StatusDoubleUse, FirstUsedAt: &at}
+	rejected := &offline.Redemption{Terminal: "till-1", Reference: "r2", RedeemedAt: at,
+		Status: offline.StatusRejected, Reason: offline.ReasonInvalidToken}
+	for _, o := range []*offline.Redemption{accepted, double, rejected} {
+		require.Nil(t, r.Offline.Create(ctx, o))
+	}
+	assert.NotNil(t, r.Offline.Create(ctx, &offline.Redemption{Terminal: "till-1", Reference: "r1",
+		RedeemedAt: at, Status: offline.StatusRejected}))
+	require.Nil(t, r.Offline.Create(tenant.NewContext(ctx, 2), &offline.Redemption{Terminal: "till-1",
+		Reference: "r1", RedeemedAt: at, Status: offline.StatusAccepted}))
+
+	got, err := r.Offline.Get(ctx, "till-2", "r1")
+	require.Nil(t, err)
+	assert.Equal(t, double.ID, got.ID)
+	assert.Equal(t, offline.StatusDoubleUse, got.Status)
+	if assert.NotNil(t, got.FirstUsedAt) {
+		assert.True(t, at.Equal(*got.FirstUsedAt))
+	}
+	_, err = r.Offline.Get(ctx, "till-2", "r2")
+	assert.True(t, gorm.IsRecordNotFoundError(err))
+
+	// claimed pending, then its outcome saved
+	pending := &offline.Redemption{Terminal: "till-3", Reference: "r1", RedeemedAt: at, Status: offline.StatusPending}
+	require.Nil(t, r.Offline.Create(ctx, pending))
+	pending.Code, pending.VoucherID, pending.Status = "B", 2, offline.StatusAccepted
+	require.Nil(t, r.Offline.Update(ctx, pending))
+	require.Nil(t, r.Offline.Update(tenant.NewContext(ctx, 2), &offline.Redemption{ID: pending.ID,
+		Status: offline.StatusRejected}))
+	got, err = r.Offline.Get(ctx, "till-3", "r1")
+	require.Nil(t, err)
+	assert.Equal(t, offline.StatusAccepted, got.Status, "updated by its tenant only")
+	assert.Equal(t, "B", got.Code)
+	assert.Equal(t, uint(2), got.VoucherID)
+
+	all, err := r.Offline.List(ctx, "", 0, 10)
+	require.Nil(t, err)
+	assert.Len(t, all, 4)
+	page, err := r.Offline.List(ctx, "", accepted.ID, 1)
+	require.Nil(t, err)
+	if assert.Len(t, page, 1) {
+		assert.Equal(t, double.ID, page[0].ID)
+	}
+	doubles, err := r.Offline.List(ctx, offline.StatusDoubleUse, 0, 10)
+	require.Nil(t, err)
+	if assert.Len(t, doubles, 1) {
+		assert.Equal(t, "till-2", doubles[0].Terminal)
+	}
+}
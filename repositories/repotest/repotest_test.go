@@ -0,0 +1,131 @@
+package repotest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/deepinbytes/go_voucher/domain/apikey"
+	"github.com/deepinbytes/go_voucher/domain/giftcard"
+	"github.com/deepinbytes/go_voucher/domain/notification"
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/offline"
+	"github.com/deepinbytes/go_voucher/domain/privacy"
+	"github.com/deepinbytes/go_voucher/domain/referral"
+	"github.com/deepinbytes/go_voucher/domain/stats"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/domain/trigger"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/apikeyrepo"
+	"github.com/deepinbytes/go_voucher/repositories/giftcardrepo"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+	"github.com/deepinbytes/go_voucher/repositories/notificationrepo"
+	"github.com/deepinbytes/go_voucher/repositories/offerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/offlinerepo"
+	"github.com/deepinbytes/go_voucher/repositories/privacyrepo"
+	"github.com/deepinbytes/go_voucher/repositories/referralrepo"
+	"github.com/deepinbytes/go_voucher/repositories/statsrepo"
+	"github.com/deepinbytes/go_voucher/repositories/tenantrepo"
+	"github.com/deepinbytes/go_voucher/repositories/triggerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/userrepo"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite" // For SQLite setup
+	_ "github.com/lib/pq"                      // For Postgres setup
+)
+
+func TestMemory(t *testing.T) {
+	Run(t, func(t *testing.T) (Repos, func()) {
+		db := memdb.New()
+		return Repos{
+			Users:    userrepo.NewMemoryUserRepo(db),
+			Offers:   offerrepo.NewMemoryOfferRepo(db),
+			Vouchers: voucherrepo.NewMemoryVoucherRepo(db),
+			Privacy:  privacyrepo.NewMemoryPrivacyRepo(db),
+			Stats:    statsrepo.NewMemoryStatsRepo(db),
+			Tenants:  tenantrepo.NewMemoryTenantRepo(db),
+
+			GiftCards: giftcardrepo.NewMemoryGiftCardRepo(db),
+			Referrals: referralrepo.NewMemoryReferralRepo(db),
+			Triggers:  triggerrepo.NewMemoryTriggerRepo(db),
+
+			Notifications: notificationrepo.NewMemoryNotificationRepo(db),
+			Offline:       offlinerepo.NewMemoryOfflineRepo(db),
+			APIKeys:       apikeyrepo.NewMemoryAPIKeyRepo(db),
+		}, func() {}
+	})
+}
+
+func TestSQLite(t *testing.T) {
+	// go-sqlite3 is a stub without cgo
+	probe, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Skip(err)
+	}
+	probe.Close()
+
+	Run(t, func(t *testing.T) (Repos, func()) {
+		dir, err := ioutil.TempDir("", "repotest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		db := openGorm(t, "sqlite3", filepath.Join(dir, "test.db"))
+		return gormRepos(db), func() {
+			db.Close()
+			os.RemoveAll(dir)
+		}
+	})
+}
+
+// TestPostgres runs against the database in TEST_DATABASE_URL, its tables
+// are dropped before every subtest
+func TestPostgres(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	Run(t, func(t *testing.T) (Repos, func()) {
+		db := openGorm(t, "postgres", url)
+		return gormRepos(db), func() { db.Close() }
+	})
+}
+
+func openGorm(t *testing.T, dialect, args string) *gorm.DB {
+	db, err := gorm.Open(dialect, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DropTableIfExists(&user.User{}, &offer.Offer{}, &voucher.Voucher{}, &privacy.Record{}, &stats.Daily{},
+		&tenant.Tenant{}, &giftcard.Card{}, &giftcard.Entry{}, &referral.Code{}, &referral.Referral{},
+		&trigger.Trigger{}, &trigger.Issuance{}, &notification.Template{}, &notification.Delivery{},
+		&offline.Redemption{}, &apikey.Key{})
+	if err := db.AutoMigrate(&user.User{}, &offer.Offer{}, &voucher.Voucher{}, &privacy.Record{}, &stats.Daily{},
+		&tenant.Tenant{}, &giftcard.Card{}, &giftcard.Entry{}, &referral.Code{}, &referral.Referral{},
+		&trigger.Trigger{}, &trigger.Issuance{}, &notification.Template{}, &notification.Delivery{},
+		&offline.Redemption{}, &apikey.Key{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func gormRepos(db *gorm.DB) Repos {
+	return Repos{
+		Users:    userrepo.NewUserRepo(db),
+		Offers:   offerrepo.NewOfferRepo(db),
+		Vouchers: voucherrepo.NewVoucherRepo(db),
+		Privacy:  privacyrepo.NewPrivacyRepo(db),
+		Stats:    statsrepo.NewStatsRepo(db),
+		Tenants:  tenantrepo.NewTenantRepo(db),
+
+		GiftCards: giftcardrepo.NewGiftCardRepo(db),
+		Referrals: referralrepo.NewReferralRepo(db),
+		Triggers:  triggerrepo.NewTriggerRepo(db),
+
+		Notifications: notificationrepo.NewNotificationRepo(db),
+		Offline:       offlinerepo.NewOfflineRepo(db),
+		APIKeys:       apikeyrepo.NewAPIKeyRepo(db),
+	}
+}
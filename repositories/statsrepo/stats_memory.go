@@ -0,0 +1,62 @@
+package statsrepo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/stats"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+)
+
+type memoryStatsRepo struct {
+	db *memdb.DB
+}
+
+// NewMemoryStatsRepo will instantiate Stats Repository backed by memdb
+func NewMemoryStatsRepo(db *memdb.DB) Repo {
+	return &memoryStatsRepo{
+		db: db,
+	}
+}
+
+func (m *memoryStatsRepo) ReplaceDay(ctx context.Context, day time.Time, rows []*stats.Daily) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	tenantID := tenant.FromContext(ctx)
+	kept := m.db.DailyStats[:0]
+	for _, d := range m.db.DailyStats {
+		if !d.Day.Equal(day) || d.TenantID != tenantID {
+			kept = append(kept, d)
+		}
+	}
+	for _, row := range rows {
+		row.TenantID = tenantID
+		kept = append(kept, *row)
+	}
+	m.db.DailyStats = kept
+	return nil
+}
+
+func (m *memoryStatsRepo) ListDaily(ctx context.Context, offerID uint, from, to time.Time) ([]*stats.Daily, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	var daily []*stats.Daily
+	for _, d := range m.db.DailyStats {
+		if d.TenantID != tenant.FromContext(ctx) || d.Day.Before(from) || !d.Day.Before(to) || (offerID != 0 && d.OfferID != offerID) {
+			continue
+		}
+		d := d
+		daily = append(daily, &d)
+	}
+	sort.Slice(daily, func(i, j int) bool {
+		if !daily[i].Day.Equal(daily[j].Day) {
+			return daily[i].Day.Before(daily[j].Day)
+		}
+		return daily[i].OfferID < daily[j].OfferID
+	})
+	return daily, nil
+}
@@ -0,0 +1,65 @@
+package statsrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/stats"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Repo stores the daily rollup of the vouchers
+type Repo interface {
+	// ReplaceDay swaps the rollup of the UTC day starting at day for rows
+	ReplaceDay(ctx context.Context, day time.Time, rows []*stats.Daily) error
+	// ListDaily returns the rollup of the days in [from, to) by day then
+	// offer, of a single offer or of all of them with offerID 0
+	ListDaily(ctx context.Context, offerID uint, from, to time.Time) ([]*stats.Daily, error)
+}
+
+type statsRepo struct {
+	db *gorm.DB
+}
+
+// NewStatsRepo will instantiate Stats Repository
+func NewStatsRepo(db *gorm.DB) Repo {
+	return &statsRepo{
+		db: db,
+	}
+}
+
+func (s *statsRepo) ReplaceDay(ctx context.Context, day time.Time, rows []*stats.Daily) error {
+	tenantID := tenant.FromContext(ctx)
+	tx := logger.DB(ctx, s.db).Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := tx.Where("day = ? AND tenant_id = ?", day, tenantID).Delete(&stats.Daily{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, row := range rows {
+		row.TenantID = tenantID
+		if err := tx.Create(row).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+func (s *statsRepo) ListDaily(ctx context.Context, offerID uint, from, to time.Time) ([]*stats.Daily, error) {
+	db := repositories.Scoped(ctx, s.db, "voucher_daily_stats").Where("day >= ? AND day < ?", from, to)
+	if offerID != 0 {
+		db = db.Where("offer_id = ?", offerID)
+	}
+	var daily []*stats.Daily
+	if err := db.Order("day, offer_id").Find(&daily).Error; err != nil {
+		return nil, err
+	}
+	return daily, nil
+}
@@ -0,0 +1,17 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Scoped returns db limited to the rows of table owned by the tenant of
+// ctx. Every query of the gorm repositories on tenant owned tables starts
+// from it, raw SQL has to filter on tenant_id itself.
+func Scoped(ctx context.Context, db *gorm.DB, table string) *gorm.DB {
+	return logger.DB(ctx, db).Where(table+".tenant_id = ?", tenant.FromContext(ctx))
+}
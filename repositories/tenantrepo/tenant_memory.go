@@ -0,0 +1,84 @@
+package tenantrepo
+
+import (
+	"context"
+	"sort"
+
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+
+	"github.com/jinzhu/gorm"
+)
+
+type memoryTenantRepo struct {
+	db *memdb.DB
+}
+
+// NewMemoryTenantRepo will instantiate Tenant Repository backed by memdb
+func NewMemoryTenantRepo(db *memdb.DB) Repo {
+	return &memoryTenantRepo{
+		db: db,
+	}
+}
+
+func (m *memoryTenantRepo) Create(ctx context.Context, t *tenant.Tenant) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	for id, other := range m.db.Tenants {
+		if id == t.ID {
+			return memdb.Unique("tenants_pkey", true)
+		}
+		if other.Slug == t.Slug {
+			return memdb.Unique("tenants.slug", true)
+		}
+	}
+	if t.ID == 0 {
+		t.ID = m.db.NextID("tenants", func(id uint) bool { _, ok := m.db.Tenants[id]; return ok })
+	}
+	now := m.db.Now()
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = now
+	}
+	t.UpdatedAt = now
+	stored := *t
+	stored.APIKey = ""
+	m.db.Tenants[t.ID] = stored
+	return nil
+}
+
+func (m *memoryTenantRepo) GetByID(ctx context.Context, id uint) (*tenant.Tenant, error) {
+	return m.find(func(t *tenant.Tenant) bool { return t.ID == id })
+}
+
+func (m *memoryTenantRepo) GetBySlug(ctx context.Context, slug string) (*tenant.Tenant, error) {
+	return m.find(func(t *tenant.Tenant) bool { return t.Slug == slug })
+}
+
+func (m *memoryTenantRepo) ListAll(ctx context.Context) ([]*tenant.Tenant, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	tenants := make([]*tenant.Tenant, 0, len(m.db.Tenants))
+	for _, t := range m.db.Tenants {
+		if t.DeletedAt != nil {
+			continue
+		}
+		t := t
+		tenants = append(tenants, &t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants, nil
+}
+
+func (m *memoryTenantRepo) find(match func(t *tenant.Tenant) bool) (*tenant.Tenant, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	for _, t := range m.db.Tenants {
+		if t.DeletedAt == nil && match(&t) {
+			return &t, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
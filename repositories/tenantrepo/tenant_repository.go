@@ -0,0 +1,58 @@
+package tenantrepo
+
+import (
+	"context"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Repo interface. Tenants are not scoped to the tenant of the context,
+// they are what it names.
+type Repo interface {
+	Create(ctx context.Context, tenant *tenant.Tenant) error
+	GetByID(ctx context.Context, id uint) (*tenant.Tenant, error)
+	GetBySlug(ctx context.Context, slug string) (*tenant.Tenant, error)
+	ListAll(ctx context.Context) ([]*tenant.Tenant, error)
+}
+
+type tenantRepo struct {
+	db *gorm.DB
+}
+
+// NewTenantRepo will instantiate Tenant Repository
+func NewTenantRepo(db *gorm.DB) Repo {
+	return &tenantRepo{
+		db: db,
+	}
+}
+
+func (r *tenantRepo) Create(ctx context.Context, t *tenant.Tenant) error {
+	return logger.DB(ctx, r.db).Create(t).Error
+}
+
+func (r *tenantRepo) GetByID(ctx context.Context, id uint) (*tenant.Tenant, error) {
+	var t tenant.Tenant
+	if err := logger.DB(ctx, r.db).First(&t, id).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *tenantRepo) GetBySlug(ctx context.Context, slug string) (*tenant.Tenant, error) {
+	var t tenant.Tenant
+	if err := logger.DB(ctx, r.db).Where("slug = ?", slug).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *tenantRepo) ListAll(ctx context.Context) ([]*tenant.Tenant, error) {
+	var tenants []*tenant.Tenant
+	if err := logger.DB(ctx, r.db).Order("id").Find(&tenants).Error; err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
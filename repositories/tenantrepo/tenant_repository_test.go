@@ -0,0 +1,60 @@
+package tenantrepo
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupDB() (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatalf("can't create sqlmock: %s", err)
+	}
+
+	gormDB, gerr := gorm.Open("postgres", db)
+	if gerr != nil {
+		log.Fatalf("can't open gorm connection: %s", err)
+	}
+	gormDB.LogMode(true)
+	return gormDB, mock
+}
+
+func TestGetBySlug(t *testing.T) {
+	gormDB, mock := setupDB()
+	defer gormDB.Close()
+
+	sqlStr := `SELECT * FROM "tenants" WHERE "tenants"."deleted_at" IS NULL AND ((slug = $1)) ORDER BY "tenants"."id" ASC LIMIT 1`
+
+	t.Run("Finds the tenant of the slug", func(t *testing.T) {
+		mock.
+			ExpectQuery(regexp.QuoteMeta(sqlStr)).
+			WithArgs("acme").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "slug"}).AddRow(2, "acme"))
+
+		got, err := NewTenantRepo(gormDB).GetBySlug(context.Background(), "acme")
+
+		assert.Nil(t, err)
+		assert.Equal(t, uint(2), got.ID)
+		assert.Equal(t, "acme", got.Slug)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown slug", func(t *testing.T) {
+		mock.
+			ExpectQuery(regexp.QuoteMeta(sqlStr)).
+			WithArgs("nope").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "slug"}))
+
+		_, err := NewTenantRepo(gormDB).GetBySlug(context.Background(), "nope")
+
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,171 @@
+package triggerrepo
+
+import (
+	"context"
+	"sort"
+
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/domain/trigger"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+
+	"github.com/jinzhu/gorm"
+)
+
+type memoryTriggerRepo struct {
+	db *memdb.DB
+}
+
+// NewMemoryTriggerRepo will instantiate Trigger Repository backed by memdb
+func NewMemoryTriggerRepo(db *memdb.DB) Repo {
+	return &memoryTriggerRepo{
+		db: db,
+	}
+}
+
+func (m *memoryTriggerRepo) Create(ctx context.Context, t *trigger.Trigger) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	_, taken := m.db.Triggers[t.ID]
+	if err := memdb.Unique("triggers_pkey", t.ID != 0 && taken); err != nil {
+		return err
+	}
+	t.TenantID = tenant.FromContext(ctx)
+	if t.ID == 0 {
+		t.ID = m.db.NextID("triggers", func(id uint) bool { _, ok := m.db.Triggers[id]; return ok })
+	}
+	now := m.db.Now()
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = now
+	}
+	t.UpdatedAt = now
+	m.db.Triggers[t.ID] = *t
+	return nil
+}
+
+func (m *memoryTriggerRepo) GetByID(ctx context.Context, id uint) (*trigger.Trigger, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	t, ok := m.db.Triggers[id]
+	if !ok || t.TenantID != tenant.FromContext(ctx) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &t, nil
+}
+
+func (m *memoryTriggerRepo) List(ctx context.Context) ([]*trigger.Trigger, error) {
+	return m.list(ctx, func(*trigger.Trigger) bool { return true }), nil
+}
+
+func (m *memoryTriggerRepo) ListActive(ctx context.Context, event string) ([]*trigger.Trigger, error) {
+	return m.list(ctx, func(t *trigger.Trigger) bool { return t.Active && t.Event == event }), nil
+}
+
+func (m *memoryTriggerRepo) Update(ctx context.Context, t *trigger.Trigger) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	stored, ok := m.db.Triggers[t.ID]
+	if !ok || stored.TenantID != tenant.FromContext(ctx) {
+		return gorm.ErrRecordNotFound
+	}
+	stored.OfferID = t.OfferID
+	stored.ValidityDays = t.ValidityDays
+	stored.Active = t.Active
+	stored.UpdatedAt = m.db.Now()
+	m.db.Triggers[t.ID] = stored
+	*t = stored
+	return nil
+}
+
+func (m *memoryTriggerRepo) Delete(ctx context.Context, id uint) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	t, ok := m.db.Triggers[id]
+	if !ok || t.TenantID != tenant.FromContext(ctx) {
+		return gorm.ErrRecordNotFound
+	}
+	delete(m.db.Triggers, id)
+	return nil
+}
+
+func (m *memoryTriggerRepo) CreateIssuance(ctx context.Context, i *trigger.Issuance) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	i.TenantID = tenant.FromContext(ctx)
+	for id, other := range m.db.TriggerIssuances {
+		if id == i.ID {
+			return memdb.Unique("trigger_issuances_pkey", true)
+		}
+		if other.TenantID == i.TenantID && other.TriggerID == i.TriggerID &&
+			other.UserID == i.UserID && other.Occurrence == i.Occurrence {
+			return memdb.Unique("uix_trigger_issuances_key", true)
+		}
+	}
+	if i.ID == 0 {
+		i.ID = m.db.NextID("trigger_issuances", func(id uint) bool { _, ok := m.db.TriggerIssuances[id]; return ok })
+	}
+	if i.CreatedAt.IsZero() {
+		i.CreatedAt = m.db.Now()
+	}
+	m.db.TriggerIssuances[i.ID] = *i
+	return nil
+}
+
+func (m *memoryTriggerRepo) GetIssuance(ctx context.Context, triggerID, userID uint, occurrence string) (*trigger.Issuance, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	for _, i := range m.db.TriggerIssuances {
+		if i.TenantID == tenant.FromContext(ctx) && i.TriggerID == triggerID &&
+			i.UserID == userID && i.Occurrence == occurrence {
+			return &i, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryTriggerRepo) SetVoucher(ctx context.Context, i *trigger.Issuance) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	stored, ok := m.db.TriggerIssuances[i.ID]
+	if !ok || stored.TenantID != tenant.FromContext(ctx) {
+		return gorm.ErrRecordNotFound
+	}
+	stored.VoucherID = i.VoucherID
+	m.db.TriggerIssuances[i.ID] = stored
+	return nil
+}
+
+func (m *memoryTriggerRepo) DeleteIssuance(ctx context.Context, id uint) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	if i, ok := m.db.TriggerIssuances[id]; ok && i.TenantID == tenant.FromContext(ctx) {
+		delete(m.db.TriggerIssuances, id)
+	}
+	return nil
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (m *memoryTriggerRepo) list(ctx context.Context, keep func(*trigger.Trigger) bool) []*trigger.Trigger {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	ts := []*trigger.Trigger{}
+	for _, t := range m.db.Triggers {
+		t := t
+		if t.TenantID == tenant.FromContext(ctx) && keep(&t) {
+			ts = append(ts, &t)
+		}
+	}
+	sort.Slice(ts, func(i, j int) bool { return ts[i].ID < ts[j].ID })
+	return ts
+}
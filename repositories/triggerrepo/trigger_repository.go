@@ -0,0 +1,141 @@
+package triggerrepo
+
+import (
+	"context"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/domain/trigger"
+	"github.com/deepinbytes/go_voucher/repositories"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Repo interface
+type Repo interface {
+	Create(ctx context.Context, t *trigger.Trigger) error
+	GetByID(ctx context.Context, id uint) (*trigger.Trigger, error)
+	List(ctx context.Context) ([]*trigger.Trigger, error)
+	// ListActive lists the active triggers of event
+	ListActive(ctx context.Context, event string) ([]*trigger.Trigger, error)
+	// Update saves the offer, validity and state of t
+	Update(ctx context.Context, t *trigger.Trigger) error
+	// Delete deletes the trigger, its issuances are kept
+	Delete(ctx context.Context, id uint) error
+	// CreateIssuance fails with a unique violation when the trigger already
+	// issued to the user for the occurrence
+	CreateIssuance(ctx context.Context, i *trigger.Issuance) error
+	GetIssuance(ctx context.Context, triggerID, userID uint, occurrence string) (*trigger.Issuance, error)
+	// SetVoucher saves the voucher issued for i
+	SetVoucher(ctx context.Context, i *trigger.Issuance) error
+	DeleteIssuance(ctx context.Context, id uint) error
+}
+
+type triggerRepo struct {
+	db *gorm.DB
+}
+
+// NewTriggerRepo will instantiate Trigger Repository
+func NewTriggerRepo(db *gorm.DB) Repo {
+	return &triggerRepo{
+		db: db,
+	}
+}
+
+func (tr *triggerRepo) Create(ctx context.Context, t *trigger.Trigger) error {
+	t.TenantID = tenant.FromContext(ctx)
+	return logger.DB(ctx, tr.db).Create(t).Error
+}
+
+func (tr *triggerRepo) GetByID(ctx context.Context, id uint) (*trigger.Trigger, error) {
+	var t trigger.Trigger
+	if err := tr.triggers(ctx).First(&t, id).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (tr *triggerRepo) List(ctx context.Context) ([]*trigger.Trigger, error) {
+	var ts []*trigger.Trigger
+	if err := tr.triggers(ctx).Order("id").Find(&ts).Error; err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+func (tr *triggerRepo) ListActive(ctx context.Context, event string) ([]*trigger.Trigger, error) {
+	var ts []*trigger.Trigger
+	if err := tr.triggers(ctx).Where("event = ? AND active = ?", event, true).
+		Order("id").Find(&ts).Error; err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+func (tr *triggerRepo) Update(ctx context.Context, t *trigger.Trigger) error {
+	res := tr.triggers(ctx).Model(&trigger.Trigger{}).Where("id = ?", t.ID).Updates(map[string]interface{}{
+		"offer_id":      t.OfferID,
+		"validity_days": t.ValidityDays,
+		"active":        t.Active,
+		"updated_at":    gorm.NowFunc(),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return tr.triggers(ctx).First(t, t.ID).Error
+}
+
+func (tr *triggerRepo) Delete(ctx context.Context, id uint) error {
+	res := tr.triggers(ctx).Delete(&trigger.Trigger{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (tr *triggerRepo) CreateIssuance(ctx context.Context, i *trigger.Issuance) error {
+	i.TenantID = tenant.FromContext(ctx)
+	return logger.DB(ctx, tr.db).Create(i).Error
+}
+
+func (tr *triggerRepo) GetIssuance(ctx context.Context, triggerID, userID uint, occurrence string) (*trigger.Issuance, error) {
+	var i trigger.Issuance
+	if err := tr.issuances(ctx).First(&i, "trigger_id = ? AND user_id = ? AND occurrence = ?",
+		triggerID, userID, occurrence).Error; err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+func (tr *triggerRepo) SetVoucher(ctx context.Context, i *trigger.Issuance) error {
+	res := tr.issuances(ctx).Model(&trigger.Issuance{}).Where("id = ?", i.ID).Update("voucher_id", i.VoucherID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (tr *triggerRepo) DeleteIssuance(ctx context.Context, id uint) error {
+	return tr.issuances(ctx).Delete(&trigger.Issuance{}, id).Error
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (tr *triggerRepo) triggers(ctx context.Context) *gorm.DB {
+	return repositories.Scoped(ctx, tr.db, "triggers")
+}
+
+func (tr *triggerRepo) issuances(ctx context.Context) *gorm.DB {
+	return repositories.Scoped(ctx, tr.db, "trigger_issuances")
+}
@@ -0,0 +1,55 @@
+package triggerrepo
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"testing"
+
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/domain/trigger"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupDB() (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatalf("can't create sqlmock: %s", err)
+	}
+
+	gormDB, gerr := gorm.Open("postgres", db)
+	if gerr != nil {
+		log.Fatalf("can't open gorm connection: %s", err)
+	}
+	gormDB.LogMode(true)
+	return gormDB, mock
+}
+
+func TestListActive(t *testing.T) {
+	gormDB, mock := setupDB()
+	defer gormDB.Close()
+
+	t.Run("Lists the active triggers of an event", func(t *testing.T) {
+		mock.
+			ExpectQuery(
+				regexp.QuoteMeta(
+					`SELECT * FROM "triggers"  WHERE (triggers.tenant_id = $1) AND (event = $2 AND active = $3) ORDER BY "id"`)).
+			WithArgs(tenant.DefaultID, trigger.EventBirthday, true).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "event", "offer_id", "validity_days", "active"}).
+					AddRow(3, trigger.EventBirthday, 5, 7, true))
+
+		ts, err := NewTriggerRepo(gormDB).ListActive(context.Background(), trigger.EventBirthday)
+
+		assert.Nil(t, err)
+		if assert.Len(t, ts, 1) {
+			assert.Equal(t, uint(5), ts[0].OfferID)
+			assert.Equal(t, 7, ts[0].ValidityDays)
+		}
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,277 @@
+package userrepo
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+
+	"github.com/jinzhu/gorm"
+)
+
+type memoryUserRepo struct {
+	db *memdb.DB
+}
+
+// NewMemoryUserRepo will instantiate User Repository backed by memdb
+func NewMemoryUserRepo(db *memdb.DB) Repo {
+	return &memoryUserRepo{
+		db: db,
+	}
+}
+
+func (m *memoryUserRepo) GetByID(ctx context.Context, id uint) (*user.User, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	u, ok := m.db.Users[id]
+	if !ok || u.TenantID != tenant.FromContext(ctx) || u.DeletedAt != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &u, nil
+}
+
+func (m *memoryUserRepo) GetByEmail(ctx context.Context, email string) (*user.User, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	for _, u := range m.db.Users {
+		if u.Email != email || u.TenantID != tenant.FromContext(ctx) || u.DeletedAt != nil {
+			continue
+		}
+		u.Voucher = m.unusedVouchers(u.ID)
+		return &u, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryUserRepo) Create(ctx context.Context, u *user.User) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	u.TenantID = tenant.FromContext(ctx)
+	if err := m.checkUnique(u); err != nil {
+		return err
+	}
+	if _, ok := m.db.Users[u.ID]; ok {
+		return memdb.Unique("users_pkey", true)
+	}
+	if u.ID == 0 {
+		u.ID = m.db.NextID("users", func(id uint) bool { _, ok := m.db.Users[id]; return ok })
+	}
+	now := m.db.Now()
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = now
+	}
+	if u.Version == 0 {
+		u.Version = 1
+	}
+	u.UpdatedAt = now
+	m.store(*u)
+	return nil
+}
+
+func (m *memoryUserRepo) Update(ctx context.Context, u *user.User) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	u.TenantID = tenant.FromContext(ctx)
+	if err := m.checkUnique(u); err != nil {
+		return err
+	}
+	if stored, ok := m.db.Users[u.ID]; ok && stored.TenantID != u.TenantID {
+		return memdb.Unique("users_pkey", true)
+	}
+	now := m.db.Now()
+	if u.ID == 0 {
+		u.ID = m.db.NextID("users", func(id uint) bool { _, ok := m.db.Users[id]; return ok })
+	}
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = now
+	}
+	u.Version++
+	u.UpdatedAt = now
+	m.store(*u)
+	return nil
+}
+
+func (m *memoryUserRepo) ListAll(ctx context.Context) ([]*user.User, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	users := make([]*user.User, 0, len(m.db.Users))
+	for _, u := range m.db.Users {
+		if u.TenantID != tenant.FromContext(ctx) || u.DeletedAt != nil {
+			continue
+		}
+		u := u
+		users = append(users, &u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (m *memoryUserRepo) GetByIDs(ctx context.Context, ids []uint) ([]*user.User, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	var users []*user.User
+	for _, id := range ids {
+		if u, ok := m.db.Users[id]; ok && u.TenantID == tenant.FromContext(ctx) && u.DeletedAt == nil {
+			users = append(users, &u)
+		}
+	}
+	return users, nil
+}
+
+func (m *memoryUserRepo) ListPage(ctx context.Context, after uint, limit int) ([]*user.User, error) {
+	users, err := m.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return memdb.Page(users, after, limit, func(i int) uint { return users[i].ID }).([]*user.User), nil
+}
+
+func (m *memoryUserRepo) ListByBirthday(ctx context.Context, day time.Time, after uint, limit int) ([]*user.User, error) {
+	users, err := m.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	suffixes := user.BirthdaySuffixes(day)
+	var born []*user.User
+	for _, u := range users {
+		for _, suffix := range suffixes {
+			if strings.HasSuffix(u.Birthday, suffix) {
+				born = append(born, u)
+				break
+			}
+		}
+	}
+	return memdb.Page(born, after, limit, func(i int) uint { return born[i].ID }).([]*user.User), nil
+}
+
+func (m *memoryUserRepo) UpdateIfVersion(ctx context.Context, u *user.User, version uint) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	stored, ok := m.db.Users[u.ID]
+	if !ok || stored.TenantID != tenant.FromContext(ctx) || stored.DeletedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+	if stored.Version != version {
+		return repositories.ErrStale
+	}
+	u.TenantID = stored.TenantID
+	if err := m.checkUnique(u); err != nil {
+		return err
+	}
+	stored.FirstName = u.FirstName
+	stored.LastName = u.LastName
+	stored.Email = u.Email
+	stored.Birthday = u.Birthday
+	stored.Phone = u.Phone
+	stored.Locale = u.Locale
+	stored.Version = version + 1
+	stored.UpdatedAt = m.db.Now()
+	m.store(stored)
+	u.Version = stored.Version
+	u.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+func (m *memoryUserRepo) Delete(ctx context.Context, id uint, at time.Time) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	u, ok := m.db.Users[id]
+	if !ok || u.TenantID != tenant.FromContext(ctx) || u.DeletedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+	u.FirstName = ""
+	u.LastName = ""
+	u.Email = user.AnonymousEmail(id)
+	u.Birthday = ""
+	u.Phone = ""
+	u.Locale = ""
+	u.DeletedAt = &at
+	u.Version++
+	u.UpdatedAt = m.db.Now()
+	m.store(u)
+	return nil
+}
+
+func (m *memoryUserRepo) GetDeleted(ctx context.Context, id uint) (*user.User, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	u, ok := m.db.Users[id]
+	if !ok || u.TenantID != tenant.FromContext(ctx) || u.DeletedAt == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &u, nil
+}
+
+func (m *memoryUserRepo) Restore(ctx context.Context, id uint) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	u, ok := m.db.Users[id]
+	if !ok || u.TenantID != tenant.FromContext(ctx) || u.DeletedAt == nil {
+		return gorm.ErrRecordNotFound
+	}
+	u.DeletedAt = nil
+	u.Version++
+	u.UpdatedAt = m.db.Now()
+	m.store(u)
+	return nil
+}
+
+func (m *memoryUserRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	var n int64
+	for id, u := range m.db.Users {
+		if u.TenantID == tenant.FromContext(ctx) && u.DeletedAt != nil && u.DeletedAt.Before(before) {
+			delete(m.db.Users, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *memoryUserRepo) store(u user.User) {
+	u.Voucher = nil
+	m.db.Users[u.ID] = u
+}
+
+func (m *memoryUserRepo) checkUnique(u *user.User) error {
+	for id, other := range m.db.Users {
+		if id != u.ID && other.TenantID == u.TenantID && other.Email == u.Email {
+			return memdb.Unique("users.tenant_id, users.email", true)
+		}
+	}
+	return nil
+}
+
+// unusedVouchers mirrors the preload of GetByEmail in the gorm repository
+func (m *memoryUserRepo) unusedVouchers(userID uint) []voucher.Voucher {
+	var out []voucher.Voucher
+	for _, v := range m.db.Vouchers {
+		if v.UserID != userID || v.IsUsed || v.DeletedAt != nil {
+			continue
+		}
+		if o, ok := m.db.Offers[v.OfferID]; ok && o.DeletedAt == nil {
+			v.Offer = &o
+		}
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
@@ -1,27 +1,49 @@
 package userrepo
 
 import (
+	"context"
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
 	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/repositories"
+	"strings"
+	"time"
 
 	"github.com/jinzhu/gorm"
 )
 
 // Repo interface
 type Repo interface {
-	GetByID(id uint) (*user.User, error)
-	GetByEmail(email string) (*user.User, error)
-	Create(user *user.User) error
-	Update(user *user.User) error
-	ListAll() ([]*user.User, error)
+	GetByID(ctx context.Context, id uint) (*user.User, error)
+	GetByEmail(ctx context.Context, email string) (*user.User, error)
+	Create(ctx context.Context, user *user.User) error
+	Update(ctx context.Context, user *user.User) error
+	ListAll(ctx context.Context) ([]*user.User, error)
+	GetByIDs(ctx context.Context, ids []uint) ([]*user.User, error)
+	ListPage(ctx context.Context, after uint, limit int) ([]*user.User, error)
+	// ListByBirthday pages through the users whose birthday falls on day
+	ListByBirthday(ctx context.Context, day time.Time, after uint, limit int) ([]*user.User, error)
+	// UpdateIfVersion updates the user only while it is still at version,
+	// it returns repositories.ErrStale otherwise
+	UpdateIfVersion(ctx context.Context, user *user.User, version uint) error
+	// Delete anonymizes the user and soft deletes it at the given time, its
+	// vouchers are left as they are
+	Delete(ctx context.Context, id uint, at time.Time) error
+	// GetDeleted returns the user only while it is soft deleted
+	GetDeleted(ctx context.Context, id uint) (*user.User, error)
+	// Restore undoes the soft delete of the user, not its anonymization
+	Restore(ctx context.Context, id uint) error
+	// Purge hard deletes the users soft deleted before the given time
+	Purge(ctx context.Context, before time.Time) (int64, error)
 }
 
 type userRepo struct {
 	db *gorm.DB
 }
 
-func (u *userRepo) ListAll() ([]*user.User, error) {
+func (u *userRepo) ListAll(ctx context.Context) ([]*user.User, error) {
 	var users []*user.User
-	if err := u.db.Find(&users).Error; err != nil {
+	if err := u.scoped(ctx).Find(&users).Error; err != nil {
 		return nil, err
 	}
 	return users, nil
@@ -34,27 +56,151 @@ func NewUserRepo(db *gorm.DB) Repo {
 	}
 }
 
-func (u *userRepo) GetByID(id uint) (*user.User, error) {
+func (u *userRepo) GetByID(ctx context.Context, id uint) (*user.User, error) {
 	var user user.User
-	if err := u.db.First(&user, id).Error; err != nil {
+	if err := u.scoped(ctx).First(&user, id).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func (u *userRepo) GetByEmail(email string) (*user.User, error) {
+func (u *userRepo) GetByEmail(ctx context.Context, email string) (*user.User, error) {
 	var user user.User
-	if err := u.db.Preload("Voucher", "is_used = ?", "false").Preload("Voucher.Offer").
+	if err := u.scoped(ctx).Preload("Voucher", "is_used = ?", false).Preload("Voucher.Offer").
 		Where("email = ?", email).First(&user).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func (u *userRepo) Create(user *user.User) error {
-	return u.db.Create(user).Error
+func (u *userRepo) Create(ctx context.Context, user *user.User) error {
+	if user.Version == 0 {
+		user.Version = 1
+	}
+	user.TenantID = tenant.FromContext(ctx)
+	return logger.DB(ctx, u.db).Create(user).Error
+}
+
+func (u *userRepo) Update(ctx context.Context, user *user.User) error {
+	user.Version++
+	user.TenantID = tenant.FromContext(ctx)
+	if err := u.scoped(ctx).Save(user).Error; err != nil {
+		user.Version--
+		return err
+	}
+	return nil
+}
+
+func (u *userRepo) UpdateIfVersion(ctx context.Context, usr *user.User, version uint) error {
+	db := u.scoped(ctx)
+	res := db.Model(usr).Where("version = ?", version).Updates(map[string]interface{}{
+		"first_name": usr.FirstName,
+		"last_name":  usr.LastName,
+		"email":      usr.Email,
+		"birthday":   usr.Birthday,
+		"phone":      usr.Phone,
+		"locale":     usr.Locale,
+		"version":    version + 1,
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		if err := db.Select("id").First(&user.User{}, usr.ID).Error; err != nil {
+			return err
+		}
+		return repositories.ErrStale
+	}
+	usr.Version = version + 1
+	return nil
+}
+
+func (u *userRepo) GetByIDs(ctx context.Context, ids []uint) ([]*user.User, error) {
+	var users []*user.User
+	if err := u.scoped(ctx).Where("id IN (?)", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (u *userRepo) ListPage(ctx context.Context, after uint, limit int) ([]*user.User, error) {
+	var users []*user.User
+	if err := u.scoped(ctx).Where("id > ?", after).Order("id").Limit(limit).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (u *userRepo) ListByBirthday(ctx context.Context, day time.Time, after uint, limit int) ([]*user.User, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	for _, suffix := range user.BirthdaySuffixes(day) {
+		where = append(where, "birthday LIKE ?")
+		args = append(args, "%"+suffix)
+	}
+	var users []*user.User
+	if err := u.scoped(ctx).Where(strings.Join(where, " OR "), args...).Where("id > ?", after).
+		Order("id").Limit(limit).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
 }
 
-func (u *userRepo) Update(user *user.User) error {
-	return u.db.Save(user).Error
+func (u *userRepo) Delete(ctx context.Context, id uint, at time.Time) error {
+	res := u.scoped(ctx).Model(&user.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"first_name": "",
+		"last_name":  "",
+		"email":      user.AnonymousEmail(id),
+		"birthday":   "",
+		"phone":      "",
+		"locale":     "",
+		"deleted_at": at,
+		"version":    gorm.Expr("version + 1"),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (u *userRepo) GetDeleted(ctx context.Context, id uint) (*user.User, error) {
+	var usr user.User
+	if err := u.scoped(ctx).Unscoped().
+		Where("id = ? AND deleted_at IS NOT NULL", id).First(&usr).Error; err != nil {
+		return nil, err
+	}
+	return &usr, nil
+}
+
+func (u *userRepo) Restore(ctx context.Context, id uint) error {
+	res := u.scoped(ctx).Unscoped().Model(&user.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).Updates(map[string]interface{}{
+		"deleted_at": nil,
+		"version":    gorm.Expr("version + 1"),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (u *userRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res := u.scoped(ctx).Unscoped().Where("deleted_at < ?", before).Delete(&user.User{})
+	return res.RowsAffected, res.Error
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (u *userRepo) scoped(ctx context.Context) *gorm.DB {
+	return repositories.Scoped(ctx, u.db, "users")
 }
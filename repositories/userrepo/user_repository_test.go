@@ -1,8 +1,10 @@
 package userrepo
 
 import (
+	"context"
 	"database/sql/driver"
 	"errors"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
 	"github.com/deepinbytes/go_voucher/domain/user"
 	"log"
 	"regexp"
@@ -50,12 +52,12 @@ func TestGetByID(t *testing.T) {
 		mock.
 			ExpectQuery(
 				regexp.QuoteMeta(
-					`SELECT * FROM "users" WHERE "users"."deleted_at" IS NULL AND (("users"."id" = 100)) ORDER BY "users"."id" ASC LIMIT 1`)).
+					`SELECT * FROM "users" WHERE "users"."deleted_at" IS NULL AND ((users.tenant_id = $1) AND ("users"."id" = 100)) ORDER BY "users"."id" ASC LIMIT 1`)).
 			WillReturnRows(
 				sqlmock.NewRows([]string{"email"}).
 					AddRow("alice@cc.cc"))
 
-		result, err := u.GetByID(100)
+		result, err := u.GetByID(context.Background(), 100)
 
 		assert.EqualValues(t, expected, result)
 		assert.Nil(t, err)
@@ -68,10 +70,10 @@ func TestGetByID(t *testing.T) {
 
 		mock.
 			ExpectQuery(
-				regexp.QuoteMeta(`SELECT * FROM "users" WHERE "users"."deleted_at" IS NULL AND (("users"."id" = 100)) ORDER BY "users"."id" ASC LIMIT 1`)).
+				regexp.QuoteMeta(`SELECT * FROM "users" WHERE "users"."deleted_at" IS NULL AND ((users.tenant_id = $1) AND ("users"."id" = 100)) ORDER BY "users"."id" ASC LIMIT 1`)).
 			WillReturnError(expected)
 
-		result, err := u.GetByID(100)
+		result, err := u.GetByID(context.Background(), 100)
 
 		assert.EqualValues(t, expected, err)
 		assert.Nil(t, result)
@@ -85,11 +87,11 @@ func TestGetByID(t *testing.T) {
 		mock.
 			ExpectQuery(
 				regexp.QuoteMeta(
-					`SELECT * FROM "users" WHERE "users"."deleted_at" IS NULL AND (("users"."id" = 100)) ORDER BY "users"."id" ASC LIMIT 1`)).
+					`SELECT * FROM "users" WHERE "users"."deleted_at" IS NULL AND ((users.tenant_id = $1) AND ("users"."id" = 100)) ORDER BY "users"."id" ASC LIMIT 1`)).
 			WillReturnRows(
 				sqlmock.NewRows([]string{}))
 
-		result, err := u.GetByID(100)
+		result, err := u.GetByID(context.Background(), 100)
 
 		assert.EqualValues(t, expected, err)
 		assert.Nil(t, result)
@@ -106,16 +108,16 @@ func TestGetByEmail(t *testing.T) {
 		}
 
 		u := NewUserRepo(gormDB)
-		sqlStr := `SELECT * FROM "users" WHERE "users"."deleted_at" IS NULL AND ((email = $1)) ORDER BY "users"."id" ASC LIMIT 1`
+		sqlStr := `SELECT * FROM "users" WHERE "users"."deleted_at" IS NULL AND ((users.tenant_id = $1) AND (email = $2)) ORDER BY "users"."id" ASC LIMIT 1`
 
 		mock.
 			ExpectQuery(regexp.QuoteMeta(sqlStr)).
-			WithArgs("alice@cc.cc").
+			WithArgs(tenant.DefaultID, "alice@cc.cc").
 			WillReturnRows(
 				sqlmock.NewRows([]string{"email"}).
 					AddRow("alice@cc.cc"))
 
-		result, err := u.GetByEmail("alice@cc.cc")
+		result, err := u.GetByEmail(context.Background(), "alice@cc.cc")
 
 		assert.EqualValues(t, expected, result)
 		assert.Nil(t, err)
@@ -125,14 +127,14 @@ func TestGetByEmail(t *testing.T) {
 		expected := errors.New("Nop")
 
 		u := NewUserRepo(gormDB)
-		sqlStr := `SELECT * FROM "users" WHERE "users"."deleted_at" IS NULL AND ((email = $1)) ORDER BY "users"."id" ASC LIMIT 1`
+		sqlStr := `SELECT * FROM "users" WHERE "users"."deleted_at" IS NULL AND ((users.tenant_id = $1) AND (email = $2)) ORDER BY "users"."id" ASC LIMIT 1`
 
 		mock.
 			ExpectQuery(regexp.QuoteMeta(sqlStr)).
-			WithArgs("alice@cc.cc").
+			WithArgs(tenant.DefaultID, "alice@cc.cc").
 			WillReturnError(expected)
 
-		result, err := u.GetByEmail("alice@cc.cc")
+		result, err := u.GetByEmail(context.Background(), "alice@cc.cc")
 
 		assert.EqualValues(t, expected, err)
 		assert.Nil(t, result)
@@ -142,15 +144,15 @@ func TestGetByEmail(t *testing.T) {
 		expected := errors.New("record not found")
 
 		u := NewUserRepo(gormDB)
-		sqlStr := `SELECT * FROM "users" WHERE "users"."deleted_at" IS NULL AND ((email = $1)) ORDER BY "users"."id" ASC LIMIT 1`
+		sqlStr := `SELECT * FROM "users" WHERE "users"."deleted_at" IS NULL AND ((users.tenant_id = $1) AND (email = $2)) ORDER BY "users"."id" ASC LIMIT 1`
 
 		mock.
 			ExpectQuery(regexp.QuoteMeta(sqlStr)).
-			WithArgs("alice@cc.cc").
+			WithArgs(tenant.DefaultID, "alice@cc.cc").
 			WillReturnRows(
 				sqlmock.NewRows([]string{}))
 
-		result, err := u.GetByEmail("alice@cc.cc")
+		result, err := u.GetByEmail(context.Background(), "alice@cc.cc")
 
 		assert.EqualValues(t, expected, err)
 		assert.Nil(t, result)
@@ -173,15 +175,15 @@ func TestCreate(t *testing.T) {
 		mock.
 			ExpectQuery(
 				regexp.QuoteMeta(
-					`INSERT INTO "users" ("created_at","updated_at","deleted_at","first_name","last_name","email") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "users"."id"`)).
-			WithArgs(AnyTime{}, AnyTime{}, nil, "", "", "alice@cc.cc").
+					`INSERT INTO "users" ("created_at","updated_at","deleted_at","tenant_id","first_name","last_name","email","version","birthday","phone","locale") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING "users"."id"`)).
+			WithArgs(AnyTime{}, AnyTime{}, nil, tenant.DefaultID, "", "", "alice@cc.cc", 1, "", "", "").
 			WillReturnRows(
 				sqlmock.NewRows([]string{"id"}).
 					AddRow(1))
 
 		mock.ExpectCommit()
 
-		err := u.Create(user)
+		err := u.Create(context.Background(), user)
 		assert.Nil(t, err)
 	})
 
@@ -198,13 +200,13 @@ func TestCreate(t *testing.T) {
 		mock.
 			ExpectQuery(
 				regexp.QuoteMeta(
-					`INSERT INTO "users" ("created_at","updated_at","deleted_at","first_name","last_name","email") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "users"."id"`)).
-			WithArgs(AnyTime{}, AnyTime{}, nil, "", "", "alice@cc.cc").
+					`INSERT INTO "users" ("created_at","updated_at","deleted_at","tenant_id","first_name","last_name","email","version","birthday","phone","locale") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING "users"."id"`)).
+			WithArgs(AnyTime{}, AnyTime{}, nil, tenant.DefaultID, "", "", "alice@cc.cc", 1, "", "", "").
 			WillReturnError(exp)
 
 		mock.ExpectCommit()
 
-		err := u.Create(user)
+		err := u.Create(context.Background(), user)
 		assert.NotNil(t, err)
 		assert.EqualValues(t, exp, err)
 	})
@@ -226,15 +228,15 @@ func TestUpdate(t *testing.T) {
 		mock.
 			ExpectQuery(
 				regexp.QuoteMeta(
-					`INSERT INTO "users" ("created_at","updated_at","deleted_at","first_name","last_name","email") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "users"."id"`)).
-			WithArgs(AnyTime{}, AnyTime{}, nil, "", "", "alice@cc.cc").
+					`INSERT INTO "users" ("created_at","updated_at","deleted_at","tenant_id","first_name","last_name","email","version","birthday","phone","locale") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING "users"."id"`)).
+			WithArgs(AnyTime{}, AnyTime{}, nil, tenant.DefaultID, "", "", "alice@cc.cc", 1, "", "", "").
 			WillReturnRows(
 				sqlmock.NewRows([]string{"id"}).
 					AddRow(1))
 
 		mock.ExpectCommit()
 
-		err := u.Update(user)
+		err := u.Update(context.Background(), user)
 		assert.Nil(t, err)
 	})
 
@@ -251,13 +253,13 @@ func TestUpdate(t *testing.T) {
 		mock.
 			ExpectQuery(
 				regexp.QuoteMeta(
-					`INSERT INTO "users" ("created_at","updated_at","deleted_at","first_name","last_name","email") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "users"."id"`)).
-			WithArgs(AnyTime{}, AnyTime{}, nil, "", "", "alice@cc.cc").
+					`INSERT INTO "users" ("created_at","updated_at","deleted_at","tenant_id","first_name","last_name","email","version","birthday","phone","locale") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING "users"."id"`)).
+			WithArgs(AnyTime{}, AnyTime{}, nil, tenant.DefaultID, "", "", "alice@cc.cc", 1, "", "", "").
 			WillReturnError(exp)
 
 		mock.ExpectCommit()
 
-		err := u.Update(user)
+		err := u.Update(context.Background(), user)
 		assert.NotNil(t, err)
 		assert.EqualValues(t, exp, err)
 	})
@@ -0,0 +1,389 @@
+package voucherrepo
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/stats"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+
+	"github.com/jinzhu/gorm"
+)
+
+type memoryVoucherRepo struct {
+	db *memdb.DB
+}
+
+// NewMemoryVoucherRepo will instantiate Voucher Repository backed by memdb
+func NewMemoryVoucherRepo(db *memdb.DB) Repo {
+	return &memoryVoucherRepo{
+		db: db,
+	}
+}
+
+func (m *memoryVoucherRepo) GetByID(ctx context.Context, id uint) (*voucher.Voucher, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	v, ok := m.db.Vouchers[id]
+	if !ok || v.TenantID != tenant.FromContext(ctx) || v.DeletedAt != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &v, nil
+}
+
+func (m *memoryVoucherRepo) UseCode(ctx context.Context, code string) (*voucher.Voucher, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	for _, v := range m.db.Vouchers {
+		if v.Code == code && v.TenantID == tenant.FromContext(ctx) && v.DeletedAt == nil {
+			return &v, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryVoucherRepo) Create(ctx context.Context, v *voucher.Voucher) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	v.TenantID = tenant.FromContext(ctx)
+	if err := m.checkUnique(v); err != nil {
+		return err
+	}
+	if _, ok := m.db.Vouchers[v.ID]; ok {
+		return memdb.Unique("vouchers_pkey", true)
+	}
+	if v.ID == 0 {
+		v.ID = m.db.NextID("vouchers", func(id uint) bool { _, ok := m.db.Vouchers[id]; return ok })
+	}
+	now := m.db.Now()
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = now
+	}
+	v.UpdatedAt = now
+	m.store(*v)
+	return nil
+}
+
+func (m *memoryVoucherRepo) Update(ctx context.Context, v *voucher.Voucher) error {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	v.TenantID = tenant.FromContext(ctx)
+	if err := m.checkUnique(v); err != nil {
+		return err
+	}
+	if stored, ok := m.db.Vouchers[v.ID]; ok && stored.TenantID != v.TenantID {
+		return memdb.Unique("vouchers_pkey", true)
+	}
+	now := m.db.Now()
+	if v.ID == 0 {
+		v.ID = m.db.NextID("vouchers", func(id uint) bool { _, ok := m.db.Vouchers[id]; return ok })
+	}
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = now
+	}
+	v.UpdatedAt = now
+	m.store(*v)
+	return nil
+}
+
+func (m *memoryVoucherRepo) MarkUsed(ctx context.Context, id uint, at time.Time, discount money.Money) (bool, error) {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	v, ok := m.db.Vouchers[id]
+	if !ok || v.TenantID != tenant.FromContext(ctx) || v.DeletedAt != nil || v.IsUsed {
+		return false, nil
+	}
+	v.IsUsed, v.UsedAt = true, at
+	v.DiscountAmount, v.DiscountCurrency = discount.Amount, discount.Currency
+	v.UpdatedAt = m.db.Now()
+	m.store(v)
+	return true, nil
+}
+
+func (m *memoryVoucherRepo) MarkUnused(ctx context.Context, id uint) (bool, error) {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	v, ok := m.db.Vouchers[id]
+	if !ok || v.TenantID != tenant.FromContext(ctx) || v.DeletedAt != nil || !v.IsUsed {
+		return false, nil
+	}
+	v.IsUsed, v.UsedAt = false, time.Time{}
+	v.DiscountAmount, v.DiscountCurrency = 0, ""
+	v.UpdatedAt = m.db.Now()
+	m.store(v)
+	return true, nil
+}
+
+func (m *memoryVoucherRepo) CountExpiredByOffer(ctx context.Context, now time.Time) (map[uint]int64, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	counts := make(map[uint]int64)
+	for _, v := range m.db.Vouchers {
+		if v.TenantID == tenant.FromContext(ctx) && !v.IsUsed && v.DeletedAt == nil && v.ExpireTime.Before(now) {
+			counts[v.OfferID]++
+		}
+	}
+	return counts, nil
+}
+
+func (m *memoryVoucherRepo) ListByOffers(ctx context.Context, offerIDs []uint, opts ListOptions) ([]*voucher.Voucher, error) {
+	return m.listBy(tenant.FromContext(ctx), offerIDs, opts, func(v *voucher.Voucher) uint { return v.OfferID }), nil
+}
+
+func (m *memoryVoucherRepo) ListByUsers(ctx context.Context, userIDs []uint, opts ListOptions) ([]*voucher.Voucher, error) {
+	return m.listBy(tenant.FromContext(ctx), userIDs, opts, func(v *voucher.Voucher) uint { return v.UserID }), nil
+}
+
+func (m *memoryVoucherRepo) RevokeUnusedByOffer(ctx context.Context, offerID uint, at time.Time) (int64, error) {
+	return m.revokeUnusedBy(tenant.FromContext(ctx), offerID, at, func(v *voucher.Voucher) uint { return v.OfferID }), nil
+}
+
+func (m *memoryVoucherRepo) RevokeUnusedByUser(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	return m.revokeUnusedBy(tenant.FromContext(ctx), userID, at, func(v *voucher.Voucher) uint { return v.UserID }), nil
+}
+
+func (m *memoryVoucherRepo) RestoreByOffer(ctx context.Context, offerID uint, at time.Time) (int64, error) {
+	return m.restoreBy(tenant.FromContext(ctx), offerID, at, func(v *voucher.Voucher) uint { return v.OfferID }), nil
+}
+
+func (m *memoryVoucherRepo) RestoreByUser(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	return m.restoreBy(tenant.FromContext(ctx), userID, at, func(v *voucher.Voucher) uint { return v.UserID }), nil
+}
+
+func (m *memoryVoucherRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	var n int64
+	for id, v := range m.db.Vouchers {
+		if v.TenantID == tenant.FromContext(ctx) && v.DeletedAt != nil && v.DeletedAt.Before(before) {
+			delete(m.db.Vouchers, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *memoryVoucherRepo) OfferStats(ctx context.Context, offerID uint, now time.Time) (*stats.Offer, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	st := &stats.Offer{OfferID: offerID}
+	var secs []int64
+	for _, v := range m.db.Vouchers {
+		if v.OfferID != offerID || v.TenantID != tenant.FromContext(ctx) || v.DeletedAt != nil {
+			continue
+		}
+		st.Issued++
+		switch {
+		case v.IsUsed:
+			st.Redeemed++
+			o := m.db.Offers[v.OfferID]
+			st.DiscountGiven += int64(o.DiscountPercentage)
+			st.DiscountAmounts = addDiscount(st.DiscountAmounts, &v, &o)
+			secs = append(secs, v.UsedAt.Unix()-v.CreatedAt.Unix())
+		case v.ExpireTime.Before(now):
+			st.Expired++
+		}
+	}
+	if st.Issued > 0 {
+		st.RedemptionRate = float64(st.Redeemed) / float64(st.Issued)
+	}
+	sort.Slice(secs, func(i, j int) bool { return secs[i] < secs[j] })
+	rank := func(q float64) float64 {
+		if len(secs) == 0 {
+			return 0
+		}
+		return float64(secs[int(math.Ceil(q*float64(len(secs))))-1])
+	}
+	st.TimeToRedeem = stats.Percentiles{P50: rank(0.5), P90: rank(0.9), P99: rank(0.99)}
+	return st, nil
+}
+
+func (m *memoryVoucherRepo) Series(ctx context.Context, offerID uint, bucket time.Duration, from, to time.Time) ([]stats.Point, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	size := int64(bucket / time.Second)
+	points := make(map[int64]*stats.Point)
+	point := func(t time.Time) *stats.Point {
+		at := t.Unix() / size * size
+		p, ok := points[at]
+		if !ok {
+			p = &stats.Point{Start: time.Unix(at, 0).UTC()}
+			points[at] = p
+		}
+		return p
+	}
+	within := func(t time.Time) bool { return !t.Before(from) && t.Before(to) }
+	for _, v := range m.db.Vouchers {
+		if v.OfferID != offerID || v.TenantID != tenant.FromContext(ctx) || v.DeletedAt != nil {
+			continue
+		}
+		if within(v.CreatedAt) {
+			point(v.CreatedAt).Issued++
+		}
+		if v.IsUsed && within(v.UsedAt) {
+			point(v.UsedAt).Redeemed++
+		}
+	}
+	return sortPoints(points), nil
+}
+
+func (m *memoryVoucherRepo) DailyByOffer(ctx context.Context, day time.Time) ([]*stats.Daily, error) {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	end := day.Add(24 * time.Hour)
+	within := func(t time.Time) bool { return !t.Before(day) && t.Before(end) }
+	byOffer := make(map[uint]*stats.Daily)
+	daily := func(offerID uint) *stats.Daily {
+		d, ok := byOffer[offerID]
+		if !ok {
+			d = &stats.Daily{Day: day, OfferID: offerID, TenantID: tenant.FromContext(ctx)}
+			byOffer[offerID] = d
+		}
+		return d
+	}
+	for _, v := range m.db.Vouchers {
+		if v.TenantID != tenant.FromContext(ctx) || v.DeletedAt != nil {
+			continue
+		}
+		if within(v.CreatedAt) {
+			daily(v.OfferID).Issued++
+		}
+		if v.IsUsed && within(v.UsedAt) {
+			d := daily(v.OfferID)
+			d.Redeemed++
+			o := m.db.Offers[v.OfferID]
+			d.DiscountGiven += int64(o.DiscountPercentage)
+			d.DiscountAmounts = addDiscount(d.DiscountAmounts, &v, &o)
+		}
+		if !v.IsUsed && within(v.ExpireTime) {
+			daily(v.OfferID).Expired++
+		}
+	}
+
+	result := make([]*stats.Daily, 0, len(byOffer))
+	for _, d := range byOffer {
+		result = append(result, d)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].OfferID < result[j].OfferID })
+	return result, nil
+}
+
+func (m *memoryVoucherRepo) revokeUnusedBy(tenantID, id uint, at time.Time, parent func(v *voucher.Voucher) uint) int64 {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	var n int64
+	for _, v := range m.db.Vouchers {
+		if parent(&v) != id || v.TenantID != tenantID || v.IsUsed || v.DeletedAt != nil {
+			continue
+		}
+		v.DeletedAt = &at
+		v.UpdatedAt = m.db.Now()
+		m.store(v)
+		n++
+	}
+	return n
+}
+
+func (m *memoryVoucherRepo) restoreBy(tenantID, id uint, at time.Time, parent func(v *voucher.Voucher) uint) int64 {
+	m.db.Lock()
+	defer m.db.Unlock()
+
+	var n int64
+	for _, v := range m.db.Vouchers {
+		if parent(&v) != id || v.TenantID != tenantID || v.DeletedAt == nil || !v.DeletedAt.Equal(at) {
+			continue
+		}
+		v.DeletedAt = nil
+		v.UpdatedAt = m.db.Now()
+		m.store(v)
+		n++
+	}
+	return n
+}
+
+func (m *memoryVoucherRepo) listBy(tenantID uint, ids []uint, opts ListOptions, parent func(v *voucher.Voucher) uint) []*voucher.Voucher {
+	m.db.RLock()
+	defer m.db.RUnlock()
+
+	wanted := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var vouchers []*voucher.Voucher
+	for _, v := range m.db.Vouchers {
+		v := v
+		if !wanted[parent(&v)] || v.TenantID != tenantID || v.ID <= opts.After || (!opts.Deleted && v.DeletedAt != nil) ||
+			(opts.IsUsed != nil && v.IsUsed != *opts.IsUsed) ||
+			(opts.Expired != nil && v.ExpireTime.Before(opts.Now) != *opts.Expired) ||
+			(opts.OfferID != 0 && v.OfferID != opts.OfferID) {
+			continue
+		}
+		vouchers = append(vouchers, &v)
+	}
+	sort.Slice(vouchers, func(i, j int) bool {
+		pi, pj := parent(vouchers[i]), parent(vouchers[j])
+		return pi < pj || (pi == pj && vouchers[i].ID < vouchers[j].ID)
+	})
+	if opts.Limit <= 0 {
+		return vouchers
+	}
+
+	paged := vouchers[:0]
+	count := make(map[uint]int)
+	for _, v := range vouchers {
+		if count[parent(v)] < opts.Limit {
+			count[parent(v)]++
+			paged = append(paged, v)
+		}
+	}
+	return paged
+}
+
+func (m *memoryVoucherRepo) store(v voucher.Voucher) {
+	v.Offer = nil
+	m.db.Vouchers[v.ID] = v
+}
+
+func (m *memoryVoucherRepo) checkUnique(v *voucher.Voucher) error {
+	for id, other := range m.db.Vouchers {
+		if id != v.ID && other.TenantID == v.TenantID && other.Code == v.Code {
+			return memdb.Unique("vouchers.tenant_id, vouchers.code", true)
+		}
+	}
+	return nil
+}
+
+// addDiscount adds the discount of the used voucher v of offer o to
+// amounts, as the SQL repository sums them
+func addDiscount(amounts money.Amounts, v *voucher.Voucher, o *offer.Offer) money.Amounts {
+	currency, amount := v.DiscountCurrency, v.DiscountAmount
+	if currency == "" {
+		currency, amount = o.DiscountCurrency, o.DiscountAmount
+	}
+	if currency == "" || amount == 0 {
+		return amounts
+	}
+	if amounts == nil {
+		amounts = money.Amounts{}
+	}
+	amounts[currency] += amount
+	return amounts
+}
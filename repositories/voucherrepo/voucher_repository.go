@@ -1,16 +1,73 @@
 package voucherrepo
 
 import (
+	"context"
+	"fmt"
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/domain/stats"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
 	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories"
 	"github.com/jinzhu/gorm"
+	"math"
+	"sort"
+	"time"
 )
 
 // Repo interface
 type Repo interface {
-	GetByID(id uint) (*voucher.Voucher, error)
-	UseCode(name string) (*voucher.Voucher, error)
-	Create(voucher *voucher.Voucher) error
-	Update(voucher *voucher.Voucher) error
+	GetByID(ctx context.Context, id uint) (*voucher.Voucher, error)
+	UseCode(ctx context.Context, name string) (*voucher.Voucher, error)
+	Create(ctx context.Context, voucher *voucher.Voucher) error
+	Update(ctx context.Context, voucher *voucher.Voucher) error
+	// MarkUsed marks the voucher used at the given time, with the discount
+	// it applied, unless it already is, and reports whether it did, so
+	// concurrent redemptions use it once
+	MarkUsed(ctx context.Context, id uint, at time.Time, discount money.Money) (bool, error)
+	// MarkUnused undoes the redemption of the voucher unless it is not
+	// used, and reports whether it did, so a redemption is reversed once
+	MarkUnused(ctx context.Context, id uint) (bool, error)
+	CountExpiredByOffer(ctx context.Context, now time.Time) (map[uint]int64, error)
+	ListByOffers(ctx context.Context, offerIDs []uint, opts ListOptions) ([]*voucher.Voucher, error)
+	ListByUsers(ctx context.Context, userIDs []uint, opts ListOptions) ([]*voucher.Voucher, error)
+	// RevokeUnusedByOffer soft deletes the unused vouchers of the offer at
+	// the given time, used ones are kept as the redemption history
+	RevokeUnusedByOffer(ctx context.Context, offerID uint, at time.Time) (int64, error)
+	RevokeUnusedByUser(ctx context.Context, userID uint, at time.Time) (int64, error)
+	// RestoreByOffer undoes the soft delete of the vouchers of the offer
+	// deleted at exactly the given time, i.e. revoked along with it
+	RestoreByOffer(ctx context.Context, offerID uint, at time.Time) (int64, error)
+	RestoreByUser(ctx context.Context, userID uint, at time.Time) (int64, error)
+	// Purge hard deletes the vouchers soft deleted before the given time
+	Purge(ctx context.Context, before time.Time) (int64, error)
+	// OfferStats sums up the vouchers of the offer, those unused past their
+	// expiry at now count as expired
+	OfferStats(ctx context.Context, offerID uint, now time.Time) (*stats.Offer, error)
+	// Series counts the vouchers of the offer issued and redeemed per
+	// bucket in [from, to). Empty buckets are left out.
+	Series(ctx context.Context, offerID uint, bucket time.Duration, from, to time.Time) ([]stats.Point, error)
+	// DailyByOffer rolls up the vouchers of every offer over the UTC day
+	// starting at day, offers without activity are left out
+	DailyByOffer(ctx context.Context, day time.Time) ([]*stats.Daily, error)
+}
+
+// ListOptions pages the vouchers of several offers or users at once, each
+// ordered by ID
+type ListOptions struct {
+	// After skips vouchers up to and including this ID
+	After uint
+	// Limit is the maximum number of vouchers per offer or user, 0 for all
+	Limit int
+	// IsUsed, when set, only lists used or unused vouchers
+	IsUsed *bool
+	// Expired, when set, only lists vouchers expired or not at Now
+	Expired *bool
+	Now     time.Time
+	// OfferID, when set, only lists vouchers of this offer
+	OfferID uint
+	// Deleted also lists the revoked vouchers
+	Deleted bool
 }
 
 type voucherRepo struct {
@@ -24,26 +81,348 @@ func NewVoucherRepo(db *gorm.DB) Repo {
 	}
 }
 
-func (u *voucherRepo) GetByID(id uint) (*voucher.Voucher, error) {
+func (u *voucherRepo) GetByID(ctx context.Context, id uint) (*voucher.Voucher, error) {
 	var voucher voucher.Voucher
-	if err := u.db.First(&voucher, id).Error; err != nil {
+	if err := u.scoped(ctx).First(&voucher, id).Error; err != nil {
 		return nil, err
 	}
 	return &voucher, nil
 }
 
-func (u *voucherRepo) UseCode(name string) (*voucher.Voucher, error) {
+func (u *voucherRepo) UseCode(ctx context.Context, name string) (*voucher.Voucher, error) {
 	var v voucher.Voucher
-	if err := u.db.First(&v, "vouchers.code = ?", name).Error; err != nil {
+	if err := u.scoped(ctx).First(&v, "vouchers.code = ?", name).Error; err != nil {
 		return nil, err
 	}
 	return &v, nil
 }
 
-func (u *voucherRepo) Create(voucher *voucher.Voucher) error {
-	return u.db.Create(voucher).Error
+func (u *voucherRepo) Create(ctx context.Context, voucher *voucher.Voucher) error {
+	voucher.TenantID = tenant.FromContext(ctx)
+	return logger.DB(ctx, u.db).Create(voucher).Error
+}
+
+func (u *voucherRepo) Update(ctx context.Context, voucher *voucher.Voucher) error {
+	voucher.TenantID = tenant.FromContext(ctx)
+	return u.scoped(ctx).Save(voucher).Error
+}
+
+func (u *voucherRepo) MarkUsed(ctx context.Context, id uint, at time.Time, discount money.Money) (bool, error) {
+	res := u.scoped(ctx).Model(&voucher.Voucher{}).
+		Where("id = ? AND is_used = ?", id, false).
+		Updates(map[string]interface{}{
+			"is_used":           true,
+			"used_at":           at,
+			"discount_amount":   discount.Amount,
+			"discount_currency": discount.Currency,
+		})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (u *voucherRepo) MarkUnused(ctx context.Context, id uint) (bool, error) {
+	res := u.scoped(ctx).Model(&voucher.Voucher{}).
+		Where("id = ? AND is_used = ?", id, true).
+		Updates(map[string]interface{}{
+			"is_used":           false,
+			"used_at":           time.Time{},
+			"discount_amount":   0,
+			"discount_currency": "",
+		})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (u *voucherRepo) CountExpiredByOffer(ctx context.Context, now time.Time) (map[uint]int64, error) {
+	rows, err := u.scoped(ctx).Model(&voucher.Voucher{}).
+		Select("offer_id, count(*)").
+		Where("is_used = ? AND expire_time < ?", false, now).
+		Group("offer_id").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[uint]int64)
+	for rows.Next() {
+		var offerID uint
+		var n int64
+		if err := rows.Scan(&offerID, &n); err != nil {
+			return nil, err
+		}
+		counts[offerID] = n
+	}
+	return counts, rows.Err()
+}
+
+func (u *voucherRepo) ListByOffers(ctx context.Context, offerIDs []uint, opts ListOptions) ([]*voucher.Voucher, error) {
+	return u.listBy(ctx, "offer_id", offerIDs, opts)
+}
+
+func (u *voucherRepo) ListByUsers(ctx context.Context, userIDs []uint, opts ListOptions) ([]*voucher.Voucher, error) {
+	return u.listBy(ctx, "user_id", userIDs, opts)
+}
+
+func (u *voucherRepo) RevokeUnusedByOffer(ctx context.Context, offerID uint, at time.Time) (int64, error) {
+	return u.revokeUnusedBy(ctx, "offer_id", offerID, at)
+}
+
+func (u *voucherRepo) RevokeUnusedByUser(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	return u.revokeUnusedBy(ctx, "user_id", userID, at)
+}
+
+func (u *voucherRepo) RestoreByOffer(ctx context.Context, offerID uint, at time.Time) (int64, error) {
+	return u.restoreBy(ctx, "offer_id", offerID, at)
+}
+
+func (u *voucherRepo) RestoreByUser(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	return u.restoreBy(ctx, "user_id", userID, at)
+}
+
+func (u *voucherRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res := u.scoped(ctx).Unscoped().Where("deleted_at < ?", before).Delete(&voucher.Voucher{})
+	return res.RowsAffected, res.Error
+}
+
+func (u *voucherRepo) OfferStats(ctx context.Context, offerID uint, now time.Time) (*stats.Offer, error) {
+	st := &stats.Offer{OfferID: offerID}
+	tenantID := tenant.FromContext(ctx)
+	db := logger.DB(ctx, u.db)
+	err := db.Raw(`SELECT COUNT(*),
+			COALESCE(SUM(CASE WHEN vouchers.is_used THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN NOT vouchers.is_used AND vouchers.expire_time < ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN vouchers.is_used THEN offers.discount_percentage ELSE 0 END), 0)
+		FROM vouchers LEFT JOIN offers ON offers.id = vouchers.offer_id
+		WHERE vouchers.offer_id = ? AND vouchers.tenant_id = ? AND vouchers.deleted_at IS NULL`, now, offerID, tenantID).
+		Row().Scan(&st.Issued, &st.Redeemed, &st.Expired, &st.DiscountGiven)
+	if err != nil {
+		return nil, err
+	}
+	amounts, err := u.discountAmounts(ctx, "vouchers.offer_id = ?", offerID)
+	if err != nil {
+		return nil, err
+	}
+	st.DiscountAmounts = amounts[offerID]
+	if st.Issued > 0 {
+		st.RedemptionRate = float64(st.Redeemed) / float64(st.Issued)
+	}
+
+	// nearest rank percentiles, each one row of the sorted redemption times
+	secs := u.epoch("used_at") + " - " + u.epoch("created_at")
+	for _, p := range []struct {
+		q   float64
+		dst *float64
+	}{{0.5, &st.TimeToRedeem.P50}, {0.9, &st.TimeToRedeem.P90}, {0.99, &st.TimeToRedeem.P99}} {
+		if st.Redeemed == 0 {
+			break
+		}
+		offset := int64(math.Ceil(p.q*float64(st.Redeemed))) - 1
+		err := db.Raw(`SELECT `+secs+` AS secs FROM vouchers
+			WHERE offer_id = ? AND tenant_id = ? AND is_used = ? AND deleted_at IS NULL
+			ORDER BY secs LIMIT 1 OFFSET ?`, offerID, tenantID, true, offset).Row().Scan(p.dst)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+func (u *voucherRepo) Series(ctx context.Context, offerID uint, bucket time.Duration, from, to time.Time) ([]stats.Point, error) {
+	size := int64(bucket / time.Second)
+	points := make(map[int64]*stats.Point)
+	for _, q := range []struct {
+		column string
+		used   string
+		count  func(p *stats.Point) *int64
+	}{
+		{"created_at", "", func(p *stats.Point) *int64 { return &p.Issued }},
+		{"used_at", " AND is_used = ?", func(p *stats.Point) *int64 { return &p.Redeemed }},
+	} {
+		start := fmt.Sprintf("(%s / %d) * %d", u.epoch(q.column), size, size)
+		args := []interface{}{offerID, tenant.FromContext(ctx), from, to}
+		if q.used != "" {
+			args = append(args, true)
+		}
+		rows, err := logger.DB(ctx, u.db).Raw(`SELECT `+start+` AS bucket, COUNT(*) FROM vouchers
+			WHERE offer_id = ? AND tenant_id = ? AND `+q.column+` >= ? AND `+q.column+` < ? AND deleted_at IS NULL`+q.used+`
+			GROUP BY bucket`, args...).Rows()
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var at, n int64
+			if err := rows.Scan(&at, &n); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			p, ok := points[at]
+			if !ok {
+				p = &stats.Point{Start: time.Unix(at, 0).UTC()}
+				points[at] = p
+			}
+			*q.count(p) = n
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sortPoints(points), nil
+}
+
+func (u *voucherRepo) DailyByOffer(ctx context.Context, day time.Time) ([]*stats.Daily, error) {
+	from, to := day, day.Add(24*time.Hour)
+	rows, err := logger.DB(ctx, u.db).Raw(`SELECT vouchers.offer_id,
+			COALESCE(SUM(CASE WHEN vouchers.created_at >= ? AND vouchers.created_at < ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN vouchers.is_used AND vouchers.used_at >= ? AND vouchers.used_at < ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN NOT vouchers.is_used AND vouchers.expire_time >= ? AND vouchers.expire_time < ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN vouchers.is_used AND vouchers.used_at >= ? AND vouchers.used_at < ? THEN offers.discount_percentage ELSE 0 END), 0)
+		FROM vouchers LEFT JOIN offers ON offers.id = vouchers.offer_id
+		WHERE vouchers.tenant_id = ? AND vouchers.deleted_at IS NULL AND (
+			(vouchers.created_at >= ? AND vouchers.created_at < ?) OR
+			(vouchers.is_used AND vouchers.used_at >= ? AND vouchers.used_at < ?) OR
+			(NOT vouchers.is_used AND vouchers.expire_time >= ? AND vouchers.expire_time < ?))
+		GROUP BY vouchers.offer_id ORDER BY vouchers.offer_id`,
+		from, to, from, to, from, to, from, to, tenant.FromContext(ctx), from, to, from, to, from, to).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var daily []*stats.Daily
+	for rows.Next() {
+		d := &stats.Daily{Day: day, TenantID: tenant.FromContext(ctx)}
+		if err := rows.Scan(&d.OfferID, &d.Issued, &d.Redeemed, &d.Expired, &d.DiscountGiven); err != nil {
+			return nil, err
+		}
+		daily = append(daily, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	amounts, err := u.discountAmounts(ctx, "vouchers.used_at >= ? AND vouchers.used_at < ?", from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range daily {
+		d.DiscountAmounts = amounts[d.OfferID]
+	}
+	return daily, nil
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (u *voucherRepo) scoped(ctx context.Context) *gorm.DB {
+	return repositories.Scoped(ctx, u.db, "vouchers")
+}
+
+// discountAmounts sums by offer and currency the discounts of the used
+// vouchers matching where. Those redeemed without an order total count at
+// the amount of their offer, nothing for percentages.
+func (u *voucherRepo) discountAmounts(ctx context.Context, where string, args ...interface{}) (map[uint]money.Amounts, error) {
+	rows, err := logger.DB(ctx, u.db).Raw(`SELECT vouchers.offer_id,
+			CASE WHEN vouchers.discount_currency <> '' THEN vouchers.discount_currency
+				ELSE COALESCE(offers.discount_currency, '') END AS currency,
+			SUM(CASE WHEN vouchers.discount_currency <> '' THEN vouchers.discount_amount
+				ELSE COALESCE(offers.discount_amount, 0) END)
+		FROM vouchers LEFT JOIN offers ON offers.id = vouchers.offer_id
+		WHERE vouchers.tenant_id = ? AND vouchers.deleted_at IS NULL AND vouchers.is_used AND `+where+`
+		GROUP BY vouchers.offer_id, currency`, append([]interface{}{tenant.FromContext(ctx)}, args...)...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	amounts := make(map[uint]money.Amounts)
+	for rows.Next() {
+		var offerID uint
+		var currency string
+		var amount int64
+		if err := rows.Scan(&offerID, &currency, &amount); err != nil {
+			return nil, err
+		}
+		if currency == "" || amount == 0 {
+			continue
+		}
+		if amounts[offerID] == nil {
+			amounts[offerID] = money.Amounts{}
+		}
+		amounts[offerID][currency] += amount
+	}
+	return amounts, rows.Err()
+}
+
+// epoch is the SQL expression of column in whole seconds since the epoch
+func (u *voucherRepo) epoch(column string) string {
+	if u.db.Dialect().GetName() == "sqlite3" {
+		return "CAST(strftime('%s', " + column + ") AS INTEGER)"
+	}
+	return "CAST(EXTRACT(EPOCH FROM " + column + ") AS BIGINT)"
+}
+
+func sortPoints(points map[int64]*stats.Point) []stats.Point {
+	series := make([]stats.Point, 0, len(points))
+	for _, p := range points {
+		series = append(series, *p)
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Start.Before(series[j].Start) })
+	return series
+}
+
+// listBy lists vouchers whose column is one of ids. The per parent limit
+// is applied with a window function so every parent gets its own page in
+// a single query.
+func (u *voucherRepo) listBy(ctx context.Context, column string, ids []uint, opts ListOptions) ([]*voucher.Voucher, error) {
+	where := column + " IN (?) AND tenant_id = ? AND id > ?"
+	args := []interface{}{ids, tenant.FromContext(ctx), opts.After}
+	if !opts.Deleted {
+		where += " AND deleted_at IS NULL"
+	}
+	if opts.IsUsed != nil {
+		where += " AND is_used = ?"
+		args = append(args, *opts.IsUsed)
+	}
+	if opts.Expired != nil {
+		if *opts.Expired {
+			where += " AND expire_time < ?"
+		} else {
+			where += " AND expire_time >= ?"
+		}
+		args = append(args, opts.Now)
+	}
+	if opts.OfferID != 0 {
+		where += " AND offer_id = ?"
+		args = append(args, opts.OfferID)
+	}
+
+	var vouchers []*voucher.Voucher
+	db := logger.DB(ctx, u.db).Unscoped()
+	if opts.Limit <= 0 {
+		db = db.Where(where, args...).Order(column + ", id").Find(&vouchers)
+	} else {
+		db = db.Raw(`SELECT * FROM (
+			SELECT vouchers.*, ROW_NUMBER() OVER (PARTITION BY `+column+` ORDER BY id) AS row_num
+			FROM vouchers WHERE `+where+`
+		) paged WHERE row_num <= ? ORDER BY `+column+`, id`, append(args, opts.Limit)...).Scan(&vouchers)
+	}
+	if err := db.Error; err != nil {
+		return nil, err
+	}
+	return vouchers, nil
+}
+
+func (u *voucherRepo) revokeUnusedBy(ctx context.Context, column string, id uint, at time.Time) (int64, error) {
+	res := u.scoped(ctx).Model(&voucher.Voucher{}).
+		Where(column+" = ? AND is_used = ?", id, false).
+		Updates(map[string]interface{}{"deleted_at": at})
+	return res.RowsAffected, res.Error
 }
 
-func (u *voucherRepo) Update(voucher *voucher.Voucher) error {
-	return u.db.Save(voucher).Error
+func (u *voucherRepo) restoreBy(ctx context.Context, column string, id uint, at time.Time) (int64, error) {
+	res := u.scoped(ctx).Unscoped().Model(&voucher.Voucher{}).
+		Where(column+" = ? AND deleted_at = ?", id, at).
+		Updates(map[string]interface{}{"deleted_at": nil})
+	return res.RowsAffected, res.Error
 }
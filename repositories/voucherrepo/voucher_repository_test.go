@@ -1,8 +1,11 @@
 package voucherrepo
 
 import (
+	"context"
 	"database/sql/driver"
 	"errors"
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
 	"github.com/deepinbytes/go_voucher/domain/voucher"
 	"log"
 	"regexp"
@@ -50,15 +53,15 @@ func TestRedeem(t *testing.T) {
 		mock.
 			ExpectQuery(
 				regexp.QuoteMeta(
-					`SELECT * FROM "vouchers" WHERE "vouchers"."deleted_at" IS NULL AND ((vouchers.code = $1)) ORDER BY "vouchers"."id" ASC LIMIT 1`)).
-			WithArgs("aliceSDS").
+					`SELECT * FROM "vouchers" WHERE "vouchers"."deleted_at" IS NULL AND ((vouchers.tenant_id = $1) AND (vouchers.code = $2)) ORDER BY "vouchers"."id" ASC LIMIT 1`)).
+			WithArgs(tenant.DefaultID, "aliceSDS").
 			WillReturnRows(
 				sqlmock.NewRows([]string{"code"}).
 					AddRow("aliceSDS"))
 
 		mock.ExpectCommit()
 
-		result, err := u.UseCode("aliceSDS")
+		result, err := u.UseCode(context.Background(), "aliceSDS")
 		assert.EqualValues(t, expected, result)
 		assert.Nil(t, err)
 	})
@@ -76,13 +79,13 @@ func TestRedeemFail(t *testing.T) {
 		mock.
 			ExpectQuery(
 				regexp.QuoteMeta(
-					`SELECT * FROM "vouchers" WHERE "vouchers"."deleted_at" IS NULL AND ((vouchers.code = $1)) ORDER BY "vouchers"."id" ASC LIMIT 1`)).
-			WithArgs("aliceSDS").
+					`SELECT * FROM "vouchers" WHERE "vouchers"."deleted_at" IS NULL AND ((vouchers.tenant_id = $1) AND (vouchers.code = $2)) ORDER BY "vouchers"."id" ASC LIMIT 1`)).
+			WithArgs(tenant.DefaultID, "aliceSDS").
 			WillReturnError(exp)
 
 		mock.ExpectCommit()
 
-		_, err := u.UseCode("aliceSDS")
+		_, err := u.UseCode(context.Background(), "aliceSDS")
 		assert.NotNil(t, err)
 		assert.EqualValues(t, exp, err)
 
@@ -110,15 +113,15 @@ func TestCreate(t *testing.T) {
 		mock.
 			ExpectQuery(
 				regexp.QuoteMeta(
-					`INSERT INTO "vouchers" ("created_at","updated_at","deleted_at","used_at","code","offer_id","user_id","expire_time") VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING "vouchers"."id"`)).
-			WithArgs(AnyTime{}, AnyTime{}, nil, AnyTime{}, "aliceSDS", 1, 1, AnyTime{}).
+					`INSERT INTO "vouchers" ("created_at","updated_at","deleted_at","tenant_id","used_at","code","offer_id","user_id","expire_time") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING "vouchers"."id"`)).
+			WithArgs(AnyTime{}, AnyTime{}, nil, tenant.DefaultID, AnyTime{}, "aliceSDS", 1, 1, AnyTime{}).
 			WillReturnRows(
 				sqlmock.NewRows([]string{"id"}).
 					AddRow(1))
 
 		mock.ExpectCommit()
 
-		err := u.Create(v)
+		err := u.Create(context.Background(), v)
 		assert.Nil(t, err)
 	})
 
@@ -140,14 +143,83 @@ func TestCreate(t *testing.T) {
 		mock.
 			ExpectQuery(
 				regexp.QuoteMeta(
-					`INSERT INTO "vouchers" ("created_at","updated_at","deleted_at","used_at","code","offer_id","user_id","expire_time") VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING "vouchers"."id"`)).
-			WithArgs(AnyTime{}, AnyTime{}, nil, AnyTime{}, "aliceSDS", 1, 1, AnyTime{}).
+					`INSERT INTO "vouchers" ("created_at","updated_at","deleted_at","tenant_id","used_at","code","offer_id","user_id","expire_time") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING "vouchers"."id"`)).
+			WithArgs(AnyTime{}, AnyTime{}, nil, tenant.DefaultID, AnyTime{}, "aliceSDS", 1, 1, AnyTime{}).
 			WillReturnError(exp)
 
 		mock.ExpectCommit()
 
-		err := u.Create(v)
+		err := u.Create(context.Background(), v)
 		assert.NotNil(t, err)
 		assert.EqualValues(t, exp, err)
 	})
 }
+
+func TestCountExpiredByOffer(t *testing.T) {
+	gormDB, mock := setupDB()
+	defer gormDB.Close()
+
+	t.Run("Count expired vouchers", func(t *testing.T) {
+		now := time.Now()
+		u := NewVoucherRepo(gormDB)
+
+		mock.
+			ExpectQuery(
+				regexp.QuoteMeta(
+					`SELECT offer_id, count(*) FROM "vouchers" WHERE "vouchers"."deleted_at" IS NULL AND ((vouchers.tenant_id = $1) AND (is_used = $2 AND expire_time < $3)) GROUP BY offer_id`)).
+			WithArgs(tenant.DefaultID, false, now).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"offer_id", "count"}).
+					AddRow(1, 3).
+					AddRow(2, 5))
+
+		result, err := u.CountExpiredByOffer(context.Background(), now)
+		assert.Nil(t, err)
+		assert.EqualValues(t, map[uint]int64{1: 3, 2: 5}, result)
+	})
+}
+
+func TestMarkUsed(t *testing.T) {
+	gormDB, mock := setupDB()
+	defer gormDB.Close()
+	at := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	update := regexp.QuoteMeta(`UPDATE "vouchers" SET "discount_amount" = $1, "discount_currency" = $2, "is_used" = $3, "updated_at" = $4, "used_at" = $5  WHERE "vouchers"."deleted_at" IS NULL AND ((vouchers.tenant_id = $6) AND (id = $7 AND is_used = $8))`)
+
+	for name, rows := range map[string]int64{"Marks an unused voucher": 1, "Leaves a used voucher": 0} {
+		t.Run(name, func(t *testing.T) {
+			mock.ExpectBegin()
+			mock.ExpectExec(update).
+				WithArgs(500, "EUR", true, AnyTime{}, at, tenant.DefaultID, 7, false).
+				WillReturnResult(sqlmock.NewResult(0, rows))
+			mock.ExpectCommit()
+
+			marked, err := NewVoucherRepo(gormDB).MarkUsed(context.Background(), 7, at, money.New(500, "EUR"))
+
+			assert.Nil(t, err)
+			assert.Equal(t, rows == 1, marked)
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMarkUnused(t *testing.T) {
+	gormDB, mock := setupDB()
+	defer gormDB.Close()
+	update := regexp.QuoteMeta(`UPDATE "vouchers" SET "discount_amount" = $1, "discount_currency" = $2, "is_used" = $3, "updated_at" = $4, "used_at" = $5  WHERE "vouchers"."deleted_at" IS NULL AND ((vouchers.tenant_id = $6) AND (id = $7 AND is_used = $8))`)
+
+	for name, rows := range map[string]int64{"Reverses a used voucher": 1, "Leaves an unused voucher": 0} {
+		t.Run(name, func(t *testing.T) {
+			mock.ExpectBegin()
+			mock.ExpectExec(update).
+				WithArgs(0, "", false, AnyTime{}, AnyTime{}, tenant.DefaultID, 7, true).
+				WillReturnResult(sqlmock.NewResult(0, rows))
+			mock.ExpectCommit()
+
+			reversed, err := NewVoucherRepo(gormDB).MarkUnused(context.Background(), 7)
+
+			assert.Nil(t, err)
+			assert.Equal(t, rows == 1, reversed)
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
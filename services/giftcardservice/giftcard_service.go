@@ -0,0 +1,213 @@
+package giftcardservice
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/giftcard"
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/repositories"
+	"github.com/deepinbytes/go_voucher/repositories/giftcardrepo"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+)
+
+var (
+	// ErrAmount is returned for amounts that are not positive
+	ErrAmount = errors.New("amount must be positive")
+	// ErrCurrencyMismatch is returned for amounts in another currency than
+	// the card
+	ErrCurrencyMismatch = errors.New("currency does not match the gift card")
+	// ErrRefund is returned when a refund exceeds what was debited for the
+	// order and not refunded yet
+	ErrRefund = errors.New("refund exceeds what the order was debited")
+)
+
+const (
+	// codeLength is longer than voucher codes, a card code is all it takes
+	// to spend its balance
+	codeLength = 16
+	// maxAttempts bounds the retries of a transaction racing another one
+	// on the same card
+	maxAttempts = 3
+)
+
+// GiftCardService manages stored-value cards. Every change of balance is a
+// ledger transaction moving the amount between the card and the funding
+// or orders account.
+type GiftCardService interface {
+	// Issue creates a card holding amount
+	Issue(ctx context.Context, amount money.Money) (*giftcard.Card, error)
+	GetByCode(ctx context.Context, code string) (*giftcard.Card, error)
+	// Debit takes amount off the card for the order reference, at most
+	// its balance
+	Debit(ctx context.Context, code string, amount money.Money, reference string) (*giftcard.Card, error)
+	// TopUp adds amount to the card
+	TopUp(ctx context.Context, code string, amount money.Money) (*giftcard.Card, error)
+	// Refund gives amount back to the card, at most what was debited for
+	// the order reference and not refunded yet
+	Refund(ctx context.Context, code string, amount money.Money, reference string) (*giftcard.Card, error)
+	// Ledger returns the entries of the card, oldest first
+	Ledger(ctx context.Context, code string) ([]*giftcard.Entry, error)
+}
+
+type giftCardService struct {
+	Repo giftcardrepo.Repo
+}
+
+// NewGiftCardService will instantiate Gift Card Service
+func NewGiftCardService(
+	repo giftcardrepo.Repo,
+) GiftCardService {
+
+	return &giftCardService{
+		Repo: repo,
+	}
+}
+
+func (gs *giftCardService) Issue(ctx context.Context, amount money.Money) (*giftcard.Card, error) {
+	if _, err := money.Lookup(amount.Currency); err != nil {
+		return nil, err
+	}
+	if amount.Amount <= 0 {
+		return nil, ErrAmount
+	}
+	card := &giftcard.Card{
+		Code:           voucherservice.NewCode(codeLength),
+		Currency:       amount.Currency,
+		InitialBalance: amount.Amount,
+	}
+	if err := gs.Repo.Create(ctx, card, transaction(giftcard.KindIssue, amount, "")); err != nil {
+		return nil, err
+	}
+	logger.FromContext(ctx).Info("gift card issued", logger.Fields{
+		"gift_card_id": card.ID,
+		"amount":       amount.String(),
+	})
+	return card, nil
+}
+
+func (gs *giftCardService) GetByCode(ctx context.Context, code string) (*giftcard.Card, error) {
+	if code == "" {
+		return nil, errors.New("code is required")
+	}
+	return gs.Repo.GetByCode(ctx, code)
+}
+
+func (gs *giftCardService) Debit(ctx context.Context, code string, amount money.Money, reference string) (*giftcard.Card, error) {
+	if reference == "" {
+		return nil, errors.New("reference is required")
+	}
+	return gs.post(ctx, code, giftcard.KindDebit, amount, reference)
+}
+
+func (gs *giftCardService) TopUp(ctx context.Context, code string, amount money.Money) (*giftcard.Card, error) {
+	return gs.post(ctx, code, giftcard.KindTopUp, amount, "")
+}
+
+func (gs *giftCardService) Refund(ctx context.Context, code string, amount money.Money, reference string) (*giftcard.Card, error) {
+	if reference == "" {
+		return nil, errors.New("reference is required")
+	}
+	return gs.post(ctx, code, giftcard.KindRefund, amount, reference)
+}
+
+func (gs *giftCardService) Ledger(ctx context.Context, code string) ([]*giftcard.Entry, error) {
+	card, err := gs.GetByCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	return gs.Repo.ListEntries(ctx, card.ID)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// post checks and posts a transaction of kind on the card, again from the
+// current card when another transaction got there first
+func (gs *giftCardService) post(ctx context.Context, code, kind string, amount money.Money, reference string) (*giftcard.Card, error) {
+	if amount.Amount <= 0 {
+		return nil, ErrAmount
+	}
+	for attempt := 1; ; attempt++ {
+		card, err := gs.GetByCode(ctx, code)
+		if err != nil {
+			return nil, err
+		}
+		if err := gs.check(ctx, card, kind, amount, reference); err != nil {
+			return nil, err
+		}
+		err = gs.Repo.Post(ctx, card, transaction(kind, amount, reference))
+		if err == repositories.ErrStale && attempt < maxAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		logger.FromContext(ctx).Info("gift card transaction posted", logger.Fields{
+			"gift_card_id": card.ID,
+			"kind":         kind,
+			"amount":       amount.String(),
+			"reference":    reference,
+			"balance":      card.Balance,
+		})
+		return card, nil
+	}
+}
+
+// check tells why the transaction cannot be posted on card, nil when it can
+func (gs *giftCardService) check(ctx context.Context, card *giftcard.Card, kind string, amount money.Money, reference string) error {
+	if amount.Currency != card.Currency {
+		return ErrCurrencyMismatch
+	}
+	switch kind {
+	case giftcard.KindDebit:
+		if amount.Amount > card.Balance {
+			return giftcard.ErrInsufficientBalance
+		}
+	case giftcard.KindRefund:
+		entries, err := gs.Repo.ListEntries(ctx, card.ID)
+		if err != nil {
+			return err
+		}
+		// debits of the order are negative on the card, refunds positive
+		var refundable int64
+		for _, e := range entries {
+			if e.Account == giftcard.AccountCard && e.Reference == reference {
+				refundable -= e.Amount
+			}
+		}
+		if amount.Amount > refundable {
+			return ErrRefund
+		}
+	}
+	return nil
+}
+
+// transaction is the pair of entries moving amount onto the card, or off
+// it for debits, from the account matching kind
+func transaction(kind string, amount money.Money, reference string) []giftcard.Entry {
+	onCard, other := amount.Amount, giftcard.AccountFunding
+	switch kind {
+	case giftcard.KindDebit:
+		onCard, other = -amount.Amount, giftcard.AccountOrders
+	case giftcard.KindRefund:
+		other = giftcard.AccountOrders
+	}
+	txID := newTxID()
+	return []giftcard.Entry{
+		{TxID: txID, Kind: kind, Account: giftcard.AccountCard, Amount: onCard, Currency: amount.Currency, Reference: reference},
+		{TxID: txID, Kind: kind, Account: other, Amount: -onCard, Currency: amount.Currency, Reference: reference},
+	}
+}
+
+func newTxID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
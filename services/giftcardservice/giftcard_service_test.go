@@ -0,0 +1,154 @@
+package giftcardservice
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/deepinbytes/go_voucher/domain/giftcard"
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/repositories"
+	"github.com/deepinbytes/go_voucher/repositories/giftcardrepo"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+func setup(t *testing.T, balance int64) (GiftCardService, *giftcard.Card) {
+	svc := NewGiftCardService(giftcardrepo.NewMemoryGiftCardRepo(memdb.New()))
+	card, err := svc.Issue(ctx, money.New(balance, "EUR"))
+	require.Nil(t, err)
+	return svc, card
+}
+
+func eur(amount int64) money.Money {
+	return money.New(amount, "EUR")
+}
+
+// assertLedger checks every transaction of the card balances and its card
+// entries add up to the balance
+func assertLedger(t *testing.T, svc GiftCardService, code string) {
+	card, err := svc.GetByCode(ctx, code)
+	require.Nil(t, err)
+	entries, err := svc.Ledger(ctx, code)
+	require.Nil(t, err)
+	txs := map[string][]giftcard.Entry{}
+	for _, e := range entries {
+		txs[e.TxID] = append(txs[e.TxID], *e)
+	}
+	for id, tx := range txs {
+		assert.True(t, giftcard.Balanced(tx), "transaction %s", id)
+	}
+	var all []giftcard.Entry
+	for _, e := range entries {
+		all = append(all, *e)
+	}
+	assert.Equal(t, card.Balance, giftcard.CardAmount(all))
+}
+
+func TestIssue(t *testing.T) {
+	svc, card := setup(t, 5000)
+	assert.Len(t, card.Code, codeLength)
+	assert.Equal(t, int64(5000), card.Balance)
+	assert.Equal(t, int64(5000), card.InitialBalance)
+	assertLedger(t, svc, card.Code)
+
+	_, err := svc.Issue(ctx, money.New(100, "JPY"))
+	assert.Equal(t, money.ErrCurrency, err)
+	_, err = svc.Issue(ctx, eur(0))
+	assert.Equal(t, ErrAmount, err)
+}
+
+func TestDebit(t *testing.T) {
+	t.Run("Debits across orders", func(t *testing.T) {
+		svc, card := setup(t, 5000)
+
+		got, err := svc.Debit(ctx, card.Code, eur(1999), "order-1")
+		require.Nil(t, err)
+		assert.Equal(t, int64(3001), got.Balance)
+		got, err = svc.Debit(ctx, card.Code, eur(3001), "order-2")
+		require.Nil(t, err)
+		assert.Zero(t, got.Balance)
+
+		_, err = svc.Debit(ctx, card.Code, eur(1), "order-3")
+		assert.Equal(t, giftcard.ErrInsufficientBalance, err)
+		assertLedger(t, svc, card.Code)
+	})
+
+	t.Run("Bad debits", func(t *testing.T) {
+		svc, card := setup(t, 5000)
+
+		_, err := svc.Debit(ctx, card.Code, money.New(100, "GBP"), "order-1")
+		assert.Equal(t, ErrCurrencyMismatch, err)
+		_, err = svc.Debit(ctx, card.Code, eur(-1), "order-1")
+		assert.Equal(t, ErrAmount, err)
+		_, err = svc.Debit(ctx, card.Code, eur(100), "")
+		assert.EqualError(t, err, "reference is required")
+		_, err = svc.Debit(ctx, "NOPE", eur(100), "order-1")
+		assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+	})
+
+	t.Run("Concurrent debits never overdraw", func(t *testing.T) {
+		svc, card := setup(t, 5000)
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		debited := int64(0)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := svc.Debit(ctx, card.Code, eur(1000), "order-1")
+				if err == nil {
+					mu.Lock()
+					debited += 1000
+					mu.Unlock()
+					return
+				}
+				assert.Contains(t, []error{giftcard.ErrInsufficientBalance, repositories.ErrStale}, err)
+			}()
+		}
+		wg.Wait()
+
+		got, err := svc.GetByCode(ctx, card.Code)
+		require.Nil(t, err)
+		assert.Equal(t, 5000-debited, got.Balance)
+		assert.True(t, got.Balance >= 0)
+		assertLedger(t, svc, card.Code)
+	})
+}
+
+func TestTopUpAndRefund(t *testing.T) {
+	svc, card := setup(t, 5000)
+	_, err := svc.Debit(ctx, card.Code, eur(3000), "order-1")
+	require.Nil(t, err)
+
+	got, err := svc.TopUp(ctx, card.Code, eur(1000))
+	require.Nil(t, err)
+	assert.Equal(t, int64(3000), got.Balance)
+
+	got, err = svc.Refund(ctx, card.Code, eur(2000), "order-1")
+	require.Nil(t, err)
+	assert.Equal(t, int64(5000), got.Balance)
+	_, err = svc.Refund(ctx, card.Code, eur(1001), "order-1")
+	assert.Equal(t, ErrRefund, err)
+	_, err = svc.Refund(ctx, card.Code, eur(100), "order-2")
+	assert.Equal(t, ErrRefund, err)
+	got, err = svc.Refund(ctx, card.Code, eur(1000), "order-1")
+	require.Nil(t, err)
+	assert.Equal(t, int64(6000), got.Balance)
+
+	entries, err := svc.Ledger(ctx, card.Code)
+	require.Nil(t, err)
+	require.Len(t, entries, 10)
+	assert.Equal(t, giftcard.KindIssue, entries[0].Kind)
+	assert.Equal(t, giftcard.AccountFunding, entries[1].Account)
+	assert.Equal(t, giftcard.KindRefund, entries[9].Kind)
+	assert.Equal(t, giftcard.AccountOrders, entries[9].Account)
+	assert.Equal(t, int64(-1000), entries[9].Amount)
+	assertLedger(t, svc, card.Code)
+}
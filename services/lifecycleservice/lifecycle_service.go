@@ -0,0 +1,132 @@
+package lifecycleservice
+
+import (
+	"context"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/userservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+)
+
+// LifecycleService deletes, restores and purges offers and users together
+// with the vouchers that depend on them
+type LifecycleService interface {
+	DeleteOffer(ctx context.Context, id uint) error
+	RestoreOffer(ctx context.Context, id uint) (*offer.Offer, error)
+	DeleteUser(ctx context.Context, id uint) error
+	RestoreUser(ctx context.Context, id uint) (*user.User, error)
+	Purge(ctx context.Context, before time.Time) (Purged, error)
+}
+
+// Purged counts the rows a purge removed for good
+type Purged struct {
+	Offers   int64
+	Users    int64
+	Vouchers int64
+}
+
+type lifecycleService struct {
+	offers   offerservice.OfferService
+	users    userservice.UserService
+	vouchers voucherservice.VoucherService
+	now      func() time.Time
+}
+
+// NewLifecycleService will instantiate Lifecycle Service
+func NewLifecycleService(
+	offers offerservice.OfferService,
+	users userservice.UserService,
+	vouchers voucherservice.VoucherService,
+) LifecycleService {
+
+	return &lifecycleService{
+		offers:   offers,
+		users:    users,
+		vouchers: vouchers,
+		now:      time.Now,
+	}
+}
+
+// DeleteOffer soft deletes the offer and revokes its unused vouchers, the
+// used ones are kept as the redemption history. Vouchers left behind by a
+// failed revoke cannot be redeemed anyway as their offer is gone.
+func (ls *lifecycleService) DeleteOffer(ctx context.Context, id uint) error {
+	at := ls.deletedAt()
+	if err := ls.offers.Delete(ctx, id, at); err != nil {
+		return err
+	}
+	_, err := ls.vouchers.RevokeUnusedByOffer(ctx, id, at)
+	return err
+}
+
+// RestoreOffer undoes DeleteOffer, including the revoke of the vouchers
+func (ls *lifecycleService) RestoreOffer(ctx context.Context, id uint) (*offer.Offer, error) {
+	deleted, err := ls.offers.GetDeleted(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := ls.offers.Restore(ctx, id); err != nil {
+		return nil, err
+	}
+	if _, err := ls.vouchers.RestoreByOffer(ctx, id, *deleted.DeletedAt); err != nil {
+		return nil, err
+	}
+	return ls.offers.GetByID(ctx, id)
+}
+
+// DeleteUser anonymizes and soft deletes the user and revokes its unused
+// vouchers. Its redeemed vouchers keep pointing at it.
+func (ls *lifecycleService) DeleteUser(ctx context.Context, id uint) error {
+	at := ls.deletedAt()
+	if err := ls.users.Delete(ctx, id, at); err != nil {
+		return err
+	}
+	_, err := ls.vouchers.RevokeUnusedByUser(ctx, id, at)
+	return err
+}
+
+// RestoreUser undoes DeleteUser but for the anonymization, which cannot be
+// undone. The account comes back without a name and with a placeholder
+// email to replace.
+func (ls *lifecycleService) RestoreUser(ctx context.Context, id uint) (*user.User, error) {
+	deleted, err := ls.users.GetDeleted(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := ls.users.Restore(ctx, id); err != nil {
+		return nil, err
+	}
+	if _, err := ls.vouchers.RestoreByUser(ctx, id, *deleted.DeletedAt); err != nil {
+		return nil, err
+	}
+	return ls.users.GetByID(ctx, id)
+}
+
+// Purge hard deletes the offers, users and vouchers soft deleted before the
+// given time. Redeemed vouchers are never soft deleted so they outlive it.
+func (ls *lifecycleService) Purge(ctx context.Context, before time.Time) (Purged, error) {
+	var p Purged
+	var err error
+	if p.Vouchers, err = ls.vouchers.Purge(ctx, before); err != nil {
+		return p, err
+	}
+	if p.Offers, err = ls.offers.Purge(ctx, before); err != nil {
+		return p, err
+	}
+	p.Users, err = ls.users.Purge(ctx, before)
+	return p, err
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// deletedAt is the time stamped on a row and the vouchers revoked with it,
+// restoring matches them on it so it is kept at the precision of every
+// backend
+func (ls *lifecycleService) deletedAt() time.Time {
+	return ls.now().UTC().Truncate(time.Microsecond)
+}
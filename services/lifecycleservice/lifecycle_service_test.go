@@ -0,0 +1,151 @@
+package lifecycleservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+	"github.com/deepinbytes/go_voucher/repositories/offerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/userrepo"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/userservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+type fixture struct {
+	svc      *lifecycleService
+	offers   offerservice.OfferService
+	users    userservice.UserService
+	vouchers voucherservice.VoucherService
+
+	alice  *user.User
+	summer *offer.Offer
+	used   *voucher.Voucher
+	unused *voucher.Voucher
+}
+
+// setup seeds an offer with a used and an unused voucher of alice
+func setup(t *testing.T) *fixture {
+	db := memdb.New()
+	f := &fixture{
+		offers:   offerservice.NewOfferService(offerrepo.NewMemoryOfferRepo(db)),
+		users:    userservice.NewUserService(userrepo.NewMemoryUserRepo(db)),
+		vouchers: voucherservice.NewVoucherService(voucherrepo.NewMemoryVoucherRepo(db)),
+	}
+	f.svc = NewLifecycleService(f.offers, f.users, f.vouchers).(*lifecycleService)
+
+	f.alice = &user.User{FirstName: "Alice", LastName: "Doe", Email: "alice@cc.cc"}
+	require.Nil(t, f.users.Create(ctx, f.alice))
+	f.summer = &offer.Offer{Name: "Summer", DiscountPercentage: 10}
+	require.Nil(t, f.offers.Create(ctx, f.summer))
+	f.used = &voucher.Voucher{Code: "USED0001", OfferID: f.summer.ID, UserID: f.alice.ID, IsUsed: true}
+	f.unused = &voucher.Voucher{Code: "UNUSED01", OfferID: f.summer.ID, UserID: f.alice.ID}
+	require.Nil(t, f.vouchers.Create(ctx, f.used))
+	require.Nil(t, f.vouchers.Create(ctx, f.unused))
+	return f
+}
+
+func (f *fixture) found(code string) bool {
+	_, err := f.vouchers.UseCode(ctx, code)
+	return err == nil
+}
+
+func TestDeleteOffer(t *testing.T) {
+	t.Run("Revokes the unused vouchers only", func(t *testing.T) {
+		f := setup(t)
+
+		require.Nil(t, f.svc.DeleteOffer(ctx, f.summer.ID))
+
+		_, err := f.offers.GetByID(ctx, f.summer.ID)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+		assert.False(t, f.found("UNUSED01"))
+		assert.True(t, f.found("USED0001"))
+	})
+
+	t.Run("Restore brings the vouchers back", func(t *testing.T) {
+		f := setup(t)
+		require.Nil(t, f.svc.DeleteOffer(ctx, f.summer.ID))
+
+		o, err := f.svc.RestoreOffer(ctx, f.summer.ID)
+
+		require.Nil(t, err)
+		assert.Equal(t, "Summer", o.Name)
+		assert.True(t, f.found("UNUSED01"))
+	})
+
+	t.Run("Fails for unknown offers", func(t *testing.T) {
+		f := setup(t)
+
+		assert.True(t, gorm.IsRecordNotFoundError(f.svc.DeleteOffer(ctx, 42)))
+		_, err := f.svc.RestoreOffer(ctx, f.summer.ID)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+	})
+}
+
+func TestDeleteUser(t *testing.T) {
+	t.Run("Anonymizes and keeps the redemption history", func(t *testing.T) {
+		f := setup(t)
+
+		require.Nil(t, f.svc.DeleteUser(ctx, f.alice.ID))
+
+		_, err := f.users.GetByEmail(ctx, "alice@cc.cc")
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+		deleted, err := f.users.GetDeleted(ctx, f.alice.ID)
+		require.Nil(t, err)
+		assert.Equal(t, "", deleted.FirstName)
+		assert.Equal(t, user.AnonymousEmail(f.alice.ID), deleted.Email)
+		assert.False(t, f.found("UNUSED01"))
+		assert.True(t, f.found("USED0001"))
+	})
+
+	t.Run("Restore keeps the anonymization", func(t *testing.T) {
+		f := setup(t)
+		require.Nil(t, f.svc.DeleteUser(ctx, f.alice.ID))
+
+		u, err := f.svc.RestoreUser(ctx, f.alice.ID)
+
+		require.Nil(t, err)
+		assert.Equal(t, user.AnonymousEmail(f.alice.ID), u.Email)
+		assert.True(t, f.found("UNUSED01"))
+	})
+
+	t.Run("Restoring the user leaves vouchers of deleted offers", func(t *testing.T) {
+		f := setup(t)
+		require.Nil(t, f.svc.DeleteOffer(ctx, f.summer.ID))
+		f.svc.now = func() time.Time { return time.Now().Add(time.Second) }
+		require.Nil(t, f.svc.DeleteUser(ctx, f.alice.ID))
+
+		_, err := f.svc.RestoreUser(ctx, f.alice.ID)
+
+		require.Nil(t, err)
+		assert.False(t, f.found("UNUSED01"))
+	})
+}
+
+func TestPurge(t *testing.T) {
+	f := setup(t)
+	require.Nil(t, f.svc.DeleteOffer(ctx, f.summer.ID))
+	require.Nil(t, f.svc.DeleteUser(ctx, f.alice.ID))
+
+	p, err := f.svc.Purge(ctx, time.Now().Add(-time.Hour))
+	require.Nil(t, err)
+	assert.Equal(t, Purged{}, p)
+
+	p, err = f.svc.Purge(ctx, time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	assert.Equal(t, Purged{Offers: 1, Users: 1, Vouchers: 1}, p)
+	_, err = f.svc.RestoreOffer(ctx, f.summer.ID)
+	assert.True(t, gorm.IsRecordNotFoundError(err))
+	assert.True(t, f.found("USED0001"))
+}
@@ -0,0 +1,385 @@
+package notificationservice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/common/metrics"
+	"github.com/deepinbytes/go_voucher/common/notifications"
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/domain/notification"
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/notificationrepo"
+	"github.com/deepinbytes/go_voucher/repositories/userrepo"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrChannel is returned for templates of an unknown channel
+	ErrChannel = fmt.Errorf("channel must be one of %s", strings.Join(notification.Channels, ", "))
+	// ErrTemplate is returned, wrapped, for templates that do not render
+	ErrTemplate = errors.New("invalid template")
+	// ErrTemplateTaken is returned when the offer already has a template
+	// for the channel and locale
+	ErrTemplateTaken = errors.New("template exists for this offer, channel and locale")
+)
+
+const (
+	// deliverPage is the number of deliveries sent at once
+	deliverPage = 100
+	// maxError bounds the error kept on a delivery
+	maxError = 512
+)
+
+// Policy tells how deliveries are retried and which locale users without
+// one get
+type Policy struct {
+	// MaxAttempts is the number of attempts after which a delivery fails
+	MaxAttempts int
+	// RetryBase is the wait after the first failed attempt, it doubles
+	// after every further one
+	RetryBase time.Duration
+	// Locale is the locale of users without one
+	Locale string
+}
+
+// NotificationService sends the vouchers issued to their users. Every
+// voucher gets one delivery per channel, sent by Deliver and retried on
+// failure. Messages are rendered from the template of the offer, channel
+// and locale of the user, falling back to the tenant's template for every
+// offer and then to built-in ones.
+type NotificationService interface {
+	CreateTemplate(ctx context.Context, t *notification.Template) error
+	GetTemplate(ctx context.Context, id uint) (*notification.Template, error)
+	ListTemplates(ctx context.Context) ([]*notification.Template, error)
+	// UpdateTemplate saves the subject and body of t
+	UpdateTemplate(ctx context.Context, t *notification.Template) error
+	DeleteTemplate(ctx context.Context, id uint) error
+	// Issued queues the deliveries of v, it is meant as a
+	// voucherservice.IssueHook
+	Issued(ctx context.Context, v *voucher.Voucher) error
+	// Deliver sends the deliveries due and returns how many were sent
+	Deliver(ctx context.Context) (int, error)
+	Deliveries(ctx context.Context, voucherID uint) ([]*notification.Delivery, error)
+}
+
+type notificationService struct {
+	repo     notificationrepo.Repo
+	users    userrepo.Repo
+	offers   offerservice.OfferService
+	vouchers voucherrepo.Repo
+	notifier notifications.Channels
+	policy   Policy
+	now      func() time.Time
+}
+
+// NewNotificationService will instantiate Notification Service sending
+// over the channels of notifier. It reads vouchers and users from the
+// repositories, as it hooks into the voucher service.
+func NewNotificationService(
+	repo notificationrepo.Repo,
+	users userrepo.Repo,
+	offers offerservice.OfferService,
+	vouchers voucherrepo.Repo,
+	notifier notifications.Channels,
+	policy Policy,
+) NotificationService {
+
+	return &notificationService{
+		repo:     repo,
+		users:    users,
+		offers:   offers,
+		vouchers: vouchers,
+		notifier: notifier,
+		policy:   policy,
+		now:      time.Now,
+	}
+}
+
+func (ns *notificationService) CreateTemplate(ctx context.Context, t *notification.Template) error {
+	if err := ns.validate(ctx, t); err != nil {
+		return err
+	}
+	templates, err := ns.repo.ListTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	for _, other := range templates {
+		if other.OfferID == t.OfferID && other.Channel == t.Channel && other.Locale == t.Locale {
+			return ErrTemplateTaken
+		}
+	}
+	return ns.repo.CreateTemplate(ctx, t)
+}
+
+func (ns *notificationService) GetTemplate(ctx context.Context, id uint) (*notification.Template, error) {
+	if id == 0 {
+		return nil, errors.New("id param is required")
+	}
+	return ns.repo.GetTemplate(ctx, id)
+}
+
+func (ns *notificationService) ListTemplates(ctx context.Context) ([]*notification.Template, error) {
+	return ns.repo.ListTemplates(ctx)
+}
+
+func (ns *notificationService) UpdateTemplate(ctx context.Context, t *notification.Template) error {
+	if err := ns.validate(ctx, t); err != nil {
+		return err
+	}
+	return ns.repo.UpdateTemplate(ctx, t)
+}
+
+func (ns *notificationService) DeleteTemplate(ctx context.Context, id uint) error {
+	return ns.repo.DeleteTemplate(ctx, id)
+}
+
+// Issued queues nothing for vouchers without a user
+func (ns *notificationService) Issued(ctx context.Context, v *voucher.Voucher) error {
+	if v.UserID == 0 {
+		return nil
+	}
+	for _, channel := range ns.notifier.Names() {
+		d := &notification.Delivery{
+			VoucherID:     v.ID,
+			UserID:        v.UserID,
+			Channel:       channel,
+			Status:        notification.StatusPending,
+			NextAttemptAt: ns.now(),
+		}
+		if err := ns.repo.CreateDelivery(ctx, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deliver stops at the first error of the repositories, failed sends are
+// recorded on their delivery and retried later
+func (ns *notificationService) Deliver(ctx context.Context) (int, error) {
+	sent := 0
+	for {
+		due, err := ns.repo.ListDue(ctx, ns.now(), deliverPage)
+		if err != nil || len(due) == 0 {
+			return sent, err
+		}
+		templates, err := ns.repo.ListTemplates(ctx)
+		if err != nil {
+			return sent, err
+		}
+		for _, d := range due {
+			ok, err := ns.send(ctx, d, templates)
+			if err != nil {
+				return sent, err
+			}
+			if ok {
+				sent++
+			}
+		}
+		if len(due) < deliverPage {
+			return sent, nil
+		}
+	}
+}
+
+func (ns *notificationService) Deliveries(ctx context.Context, voucherID uint) ([]*notification.Delivery, error) {
+	return ns.repo.ListDeliveries(ctx, voucherID)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (ns *notificationService) validate(ctx context.Context, t *notification.Template) error {
+	known := false
+	for _, c := range notification.Channels {
+		known = known || c == t.Channel
+	}
+	if !known {
+		return ErrChannel
+	}
+	t.Locale = notification.NormalizeLocale(t.Locale)
+	if t.Locale == "" {
+		return errors.New("locale is required")
+	}
+	if t.Channel == notification.ChannelSMS {
+		t.Subject = ""
+	} else if strings.TrimSpace(t.Subject) == "" {
+		return errors.New("subject is required")
+	}
+	if strings.TrimSpace(t.Body) == "" {
+		return errors.New("body is required")
+	}
+	if t.OfferID != 0 {
+		if _, err := ns.offers.GetByID(ctx, t.OfferID); err != nil {
+			return err
+		}
+	}
+	// rendered once so fields that do not exist fail now rather than on
+	// every delivery
+	sample := notification.Data{FirstName: "Alice", LastName: "Doe", Code: "ABCD1234", Offer: "Offer",
+		Discount: "10%", Expires: "2020-12-31"}
+	if _, _, err := render(t, sample); err != nil {
+		return fmt.Errorf("%w: %v", ErrTemplate, err)
+	}
+	return nil
+}
+
+// send makes an attempt at d unless another one was made since it was
+// listed, and tells whether it was sent. Only errors saving d are returned.
+func (ns *notificationService) send(ctx context.Context, d *notification.Delivery, templates []*notification.Template) (bool, error) {
+	claimed, err := ns.repo.Claim(ctx, d, ns.now().Add(ns.backoff(d.Attempts+1)))
+	if err != nil || !claimed {
+		return false, err
+	}
+	log := logger.FromContext(ctx)
+	fields := logger.Fields{"delivery_id": d.ID, "voucher_id": d.VoucherID, "channel": d.Channel, "attempt": d.Attempts}
+
+	m, skip, err := ns.message(ctx, d, templates)
+	if err == nil && skip == "" {
+		err = ns.notifier.Send(ctx, m)
+	}
+	result := "sent"
+	switch {
+	case skip != "":
+		d.Status, d.LastError = notification.StatusSkipped, skip
+		result = notification.StatusSkipped
+		fields["reason"] = skip
+		log.Info("notification skipped", fields)
+	case err == nil:
+		at := ns.now()
+		d.Status, d.LastError, d.SentAt = notification.StatusSent, "", &at
+		log.Info("notification sent", fields)
+	default:
+		d.LastError = err.Error()
+		if len(d.LastError) > maxError {
+			d.LastError = d.LastError[:maxError]
+		}
+		fields["error"] = err
+		if d.Attempts >= ns.policy.MaxAttempts {
+			d.Status = notification.StatusFailed
+			result = notification.StatusFailed
+			log.Error("notification failed", fields)
+		} else {
+			result = "retry"
+			fields["retry_at"] = d.NextAttemptAt
+			log.Warn("notification attempt failed", fields)
+		}
+	}
+	metrics.Notifications.WithLabelValues(d.Channel, result).Inc()
+	return d.Status == notification.StatusSent, ns.repo.UpdateDelivery(ctx, d)
+}
+
+// message renders the message of d. It returns why d is skipped instead
+// when there is nothing to send or no one to send it to.
+func (ns *notificationService) message(ctx context.Context, d *notification.Delivery, templates []*notification.Template) (notifications.Message, string, error) {
+	var m notifications.Message
+	v, err := ns.vouchers.GetByID(ctx, d.VoucherID)
+	if gorm.IsRecordNotFoundError(err) {
+		return m, "voucher revoked", nil
+	}
+	if err != nil {
+		return m, "", err
+	}
+	if v.IsUsed {
+		return m, "voucher used", nil
+	}
+	if ns.now().After(v.ExpireTime) {
+		return m, "voucher expired", nil
+	}
+	u, err := ns.users.GetByID(ctx, d.UserID)
+	if gorm.IsRecordNotFoundError(err) {
+		return m, "user deleted", nil
+	}
+	if err != nil {
+		return m, "", err
+	}
+	m.Channel, m.To = d.Channel, u.Email
+	if d.Channel == notification.ChannelSMS {
+		m.To = u.Phone
+	}
+	if m.To == "" {
+		return m, "no " + d.Channel + " address", nil
+	}
+	o, err := ns.offers.GetByID(ctx, v.OfferID)
+	if gorm.IsRecordNotFoundError(err) {
+		return m, "offer deleted", nil
+	}
+	if err != nil {
+		return m, "", err
+	}
+
+	t := ns.template(templates, o.ID, d.Channel, u.Locale)
+	m.Subject, m.Body, err = render(t, data(v, u, o))
+	return m, "", err
+}
+
+// template picks the template for a user of locale, trying each locale of
+// notification.Locales in turn: first the template of the offer, then the
+// tenant's one for every offer, then the built-in one
+func (ns *notificationService) template(templates []*notification.Template, offerID uint, channel, locale string) *notification.Template {
+	for _, l := range notification.Locales(locale, ns.policy.Locale) {
+		for _, owner := range []uint{offerID, 0} {
+			for _, t := range templates {
+				if t.OfferID == owner && t.Channel == channel && t.Locale == l {
+					return t
+				}
+			}
+		}
+		if t, ok := builtin[channel][l]; ok {
+			return t
+		}
+	}
+	return builtin[channel][defaultLocale]
+}
+
+// backoff is the wait before retrying the nth attempt
+func (ns *notificationService) backoff(n int) time.Duration {
+	if n > 20 {
+		n = 20
+	}
+	return ns.policy.RetryBase << uint(n-1)
+}
+
+func data(v *voucher.Voucher, u *user.User, o *offer.Offer) notification.Data {
+	discount := fmt.Sprintf("%d%%", o.DiscountPercentage)
+	if o.Fixed() {
+		discount = money.New(o.DiscountAmount, o.DiscountCurrency).String()
+	}
+	return notification.Data{
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Code:      v.Code,
+		Offer:     o.Name,
+		Discount:  discount,
+		Expires:   v.ExpireTime.UTC().Format(user.DateLayout),
+	}
+}
+
+func render(t *notification.Template, d notification.Data) (string, string, error) {
+	var subject, body bytes.Buffer
+	for _, part := range []struct {
+		text string
+		out  *bytes.Buffer
+	}{{t.Subject, &subject}, {t.Body, &body}} {
+		tpl, err := template.New("").Parse(part.text)
+		if err != nil {
+			return "", "", err
+		}
+		if err := tpl.Execute(part.out, d); err != nil {
+			return "", "", err
+		}
+	}
+	return subject.String(), body.String(), nil
+}
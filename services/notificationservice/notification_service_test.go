@@ -0,0 +1,308 @@
+package notificationservice
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/notifications"
+	"github.com/deepinbytes/go_voucher/common/notifications/notificationstest"
+	"github.com/deepinbytes/go_voucher/domain/notification"
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+	"github.com/deepinbytes/go_voucher/repositories/notificationrepo"
+	"github.com/deepinbytes/go_voucher/repositories/offerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/userrepo"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+// recorder is the SMS notifier of the tests
+type recorder struct {
+	mu   sync.Mutex
+	sent []notifications.Message
+}
+
+func (r *recorder) Send(ctx context.Context, m notifications.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, m)
+	return nil
+}
+
+type fixture struct {
+	svc      *notificationService
+	smtp     *notificationstest.FakeSMTP
+	sms      *recorder
+	users    userrepo.Repo
+	offers   offerservice.OfferService
+	vouchers voucherservice.VoucherService
+	now      time.Time
+	offer    *offer.Offer
+}
+
+// setup hooks the notification service into the voucher service, sending
+// email through a FakeSMTP
+func setup(t *testing.T) *fixture {
+	srv, err := notificationstest.NewFakeSMTP("", "")
+	require.Nil(t, err)
+	t.Cleanup(srv.Close)
+
+	db := memdb.New()
+	f := &fixture{
+		smtp:   srv,
+		sms:    &recorder{},
+		users:  userrepo.NewMemoryUserRepo(db),
+		offers: offerservice.NewOfferService(offerrepo.NewMemoryOfferRepo(db)),
+		now:    time.Date(2021, 3, 1, 8, 0, 0, 0, time.UTC),
+		offer:  &offer.Offer{Name: "Spring", DiscountPercentage: 20},
+	}
+	voucherRepo := voucherrepo.NewMemoryVoucherRepo(db)
+	f.svc = NewNotificationService(notificationrepo.NewMemoryNotificationRepo(db), f.users, f.offers, voucherRepo,
+		notifications.Channels{
+			notification.ChannelEmail: notifications.NewSMTP(srv.Addr(), "vouchers@example.com", "", "", time.Second),
+			notification.ChannelSMS:   f.sms,
+		}, Policy{MaxAttempts: 3, RetryBase: time.Minute, Locale: "en"}).(*notificationService)
+	f.svc.now = func() time.Time { return f.now }
+	f.vouchers = voucherservice.NewVoucherService(voucherRepo, f.svc.Issued)
+	require.Nil(t, f.offers.Create(ctx, f.offer))
+	return f
+}
+
+func (f *fixture) issue(t *testing.T, u *user.User) *voucher.Voucher {
+	if u.ID == 0 {
+		require.Nil(t, f.users.Create(ctx, u))
+	}
+	v, err := f.vouchers.Issue(ctx, f.offer.ID, u.ID, f.now.Add(30*24*time.Hour))
+	require.Nil(t, err)
+	return v
+}
+
+func (f *fixture) deliveries(t *testing.T, v *voucher.Voucher) map[string]*notification.Delivery {
+	ds, err := f.svc.Deliveries(ctx, v.ID)
+	require.Nil(t, err)
+	byChannel := make(map[string]*notification.Delivery)
+	for _, d := range ds {
+		byChannel[d.Channel] = d
+	}
+	return byChannel
+}
+
+// mail parses the ith mail the FakeSMTP got into its subject and body
+func (f *fixture) mail(t *testing.T, i int) (string, string) {
+	mails := f.smtp.Mails()
+	require.True(t, len(mails) > i, "got %d mails", len(mails))
+	msg, err := mail.ReadMessage(strings.NewReader(mails[i].Data))
+	require.Nil(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.Nil(t, err)
+	body, err := ioutil.ReadAll(quotedprintable.NewReader(msg.Body))
+	require.Nil(t, err)
+	return subject, strings.TrimSuffix(strings.Replace(string(body), "\r\n", "\n", -1), "\n")
+}
+
+func TestDeliver(t *testing.T) {
+	f := setup(t)
+	alice := &user.User{FirstName: "Alice", Email: "alice@cc.cc", Phone: "+4915112345678"}
+	bob := &user.User{Email: "bob@cc.cc"}
+	va, vb := f.issue(t, alice), f.issue(t, bob)
+
+	sent, err := f.svc.Deliver(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, 3, sent)
+
+	mails := f.smtp.Mails()
+	require.Len(t, mails, 2)
+	assert.Equal(t, []string{"alice@cc.cc"}, mails[0].To)
+	subject, body := f.mail(t, 0)
+	assert.Equal(t, "Your voucher for Spring", subject)
+	assert.Equal(t, "Hello Alice,\n\nyour voucher code "+va.Code+" gives you 20% off Spring. It is valid until 2021-03-31.", body)
+	_, body = f.mail(t, 1)
+	assert.True(t, strings.HasPrefix(body, "Hello,\n"), body)
+	assert.Equal(t, []notifications.Message{{Channel: "sms", To: "+4915112345678",
+		Body: va.Code + ": 20% off Spring, valid until 2021-03-31"}}, f.sms.sent)
+
+	da := f.deliveries(t, va)
+	assert.Equal(t, notification.StatusSent, da["email"].Status)
+	assert.Equal(t, 1, da["email"].Attempts)
+	if assert.NotNil(t, da["email"].SentAt) {
+		assert.Equal(t, f.now, *da["email"].SentAt)
+	}
+	db := f.deliveries(t, vb)
+	assert.Equal(t, notification.StatusSkipped, db["sms"].Status)
+	assert.Equal(t, "no sms address", db["sms"].LastError)
+
+	// everything is sent once
+	sent, err = f.svc.Deliver(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, 0, sent)
+	assert.Len(t, f.smtp.Mails(), 2)
+}
+
+func TestRetries(t *testing.T) {
+	f := setup(t)
+	v := f.issue(t, &user.User{Email: "alice@cc.cc"})
+
+	f.smtp.Fail(2)
+	sent, err := f.svc.Deliver(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, 0, sent)
+	d := f.deliveries(t, v)["email"]
+	assert.Equal(t, notification.StatusPending, d.Status)
+	assert.Equal(t, 1, d.Attempts)
+	assert.Contains(t, d.LastError, "451")
+	assert.Equal(t, f.now.Add(time.Minute), d.NextAttemptAt)
+
+	// not due before the backoff is over, which doubles
+	f.now = f.now.Add(30 * time.Second)
+	sent, err = f.svc.Deliver(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, 0, sent)
+	assert.Equal(t, 1, f.deliveries(t, v)["email"].Attempts)
+
+	f.now = f.now.Add(30 * time.Second)
+	_, err = f.svc.Deliver(ctx)
+	require.Nil(t, err)
+	d = f.deliveries(t, v)["email"]
+	assert.Equal(t, 2, d.Attempts)
+	assert.Equal(t, f.now.Add(2*time.Minute), d.NextAttemptAt)
+
+	f.now = f.now.Add(2 * time.Minute)
+	sent, err = f.svc.Deliver(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, 1, sent)
+	d = f.deliveries(t, v)["email"]
+	assert.Equal(t, notification.StatusSent, d.Status)
+	assert.Equal(t, 3, d.Attempts)
+	assert.Equal(t, "", d.LastError)
+	assert.Len(t, f.smtp.Mails(), 1)
+
+	// given up after MaxAttempts
+	v = f.issue(t, &user.User{Email: "bob@cc.cc"})
+	f.smtp.Fail(3)
+	for i := 0; i < 3; i++ {
+		_, err = f.svc.Deliver(ctx)
+		require.Nil(t, err)
+		f.now = f.now.Add(time.Hour)
+	}
+	d = f.deliveries(t, v)["email"]
+	assert.Equal(t, notification.StatusFailed, d.Status)
+	assert.Equal(t, 3, d.Attempts)
+	_, err = f.svc.Deliver(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, 3, f.deliveries(t, v)["email"].Attempts)
+}
+
+func TestSkips(t *testing.T) {
+	f := setup(t)
+	used := f.issue(t, &user.User{Email: "alice@cc.cc"})
+	used.IsUsed = true
+	require.Nil(t, f.vouchers.Update(ctx, used))
+	bob := &user.User{Email: "bob@cc.cc"}
+	deleted := f.issue(t, bob)
+	require.Nil(t, f.users.Delete(ctx, bob.ID, f.now))
+	carol := &user.User{Email: "carol@cc.cc"}
+	revoked := f.issue(t, carol)
+	_, err := f.vouchers.RevokeUnusedByUser(ctx, carol.ID, f.now)
+	require.Nil(t, err)
+
+	sent, err := f.svc.Deliver(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, 0, sent)
+	assert.Empty(t, f.smtp.Mails())
+	for v, reason := range map[*voucher.Voucher]string{used: "voucher used", deleted: "user deleted", revoked: "voucher revoked"} {
+		d := f.deliveries(t, v)["email"]
+		assert.Equal(t, notification.StatusSkipped, d.Status)
+		assert.Equal(t, reason, d.LastError)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	f := setup(t)
+	require.Nil(t, f.svc.CreateTemplate(ctx, &notification.Template{
+		OfferID: f.offer.ID, Channel: notification.ChannelEmail, Locale: "de",
+		Subject: "Frühling!", Body: "{{.Code}} für {{.Offer}}"}))
+	require.Nil(t, f.svc.CreateTemplate(ctx, &notification.Template{
+		Channel: notification.ChannelEmail, Locale: "en", Subject: "Hi {{.FirstName}}", Body: "{{.Code}}"}))
+	require.Nil(t, f.svc.CreateTemplate(ctx, &notification.Template{
+		Channel: notification.ChannelSMS, Locale: "FR", Subject: "dropped", Body: "Code {{.Code}}"}))
+
+	swiss := f.issue(t, &user.User{Email: "anna@cc.cc", Locale: "de-ch"})
+	english := f.issue(t, &user.User{FirstName: "Alice", Email: "alice@cc.cc"})
+	french := f.issue(t, &user.User{Email: "jean@cc.cc", Phone: "+33612345678", Locale: "fr"})
+	_, err := f.svc.Deliver(ctx)
+	require.Nil(t, err)
+
+	subject, body := f.mail(t, 0)
+	assert.Equal(t, "Frühling!", subject)
+	assert.Equal(t, swiss.Code+" für Spring", body)
+	subject, body = f.mail(t, 1)
+	assert.Equal(t, "Hi Alice", subject)
+	assert.Equal(t, english.Code, body)
+	// no French email template but the built-in one
+	subject, _ = f.mail(t, 2)
+	assert.Equal(t, "Votre bon pour Spring", subject)
+	if assert.Len(t, f.sms.sent, 1) {
+		assert.Equal(t, "Code "+french.Code, f.sms.sent[0].Body)
+	}
+
+	templates, err := f.svc.ListTemplates(ctx)
+	require.Nil(t, err)
+	require.Len(t, templates, 3)
+	assert.Equal(t, "fr", templates[2].Locale)
+	assert.Equal(t, "", templates[2].Subject)
+
+	t.Run("Validates", func(t *testing.T) {
+		for _, tc := range []struct {
+			tpl  notification.Template
+			want string
+		}{
+			{notification.Template{Channel: "fax", Locale: "en", Body: "x"}, ErrChannel.Error()},
+			{notification.Template{Channel: "email", Body: "x", Subject: "x"}, "locale is required"},
+			{notification.Template{Channel: "email", Locale: "en", Body: "x"}, "subject is required"},
+			{notification.Template{Channel: "sms", Locale: "en"}, "body is required"},
+			{notification.Template{Channel: "sms", Locale: "en", Body: "{{.Code"}, "invalid template"},
+			{notification.Template{Channel: "sms", Locale: "en", Body: "{{.Password}}"}, "invalid template"},
+			{notification.Template{OfferID: 404, Channel: "sms", Locale: "en", Body: "x"}, "record not found"},
+		} {
+			err := f.svc.CreateTemplate(ctx, &tc.tpl)
+			if assert.NotNil(t, err, tc.want) {
+				assert.Contains(t, err.Error(), tc.want)
+			}
+		}
+		err := f.svc.CreateTemplate(ctx, &notification.Template{Channel: "sms", Locale: "fr", Body: "x"})
+		assert.Equal(t, ErrTemplateTaken, err)
+		err = f.svc.CreateTemplate(ctx, &notification.Template{Channel: "sms", Locale: "en", Body: "{{.Nope}}"})
+		assert.True(t, errors.Is(err, ErrTemplate), "got %v", err)
+	})
+
+	t.Run("Updates the subject and body", func(t *testing.T) {
+		tpl := templates[1]
+		tpl.Body = "{{.Code}} until {{.Expires}}"
+		require.Nil(t, f.svc.UpdateTemplate(ctx, tpl))
+		got, err := f.svc.GetTemplate(ctx, tpl.ID)
+		require.Nil(t, err)
+		assert.Equal(t, "{{.Code}} until {{.Expires}}", got.Body)
+
+		tpl.Body = "{{"
+		assert.True(t, errors.Is(f.svc.UpdateTemplate(ctx, tpl), ErrTemplate))
+		require.Nil(t, f.svc.DeleteTemplate(ctx, tpl.ID))
+		_, err = f.svc.GetTemplate(ctx, tpl.ID)
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,37 @@
+package notificationservice
+
+import "github.com/deepinbytes/go_voucher/domain/notification"
+
+// defaultLocale is the locale of the built-in templates used when no other
+// one matches
+const defaultLocale = "en"
+
+// builtin are the templates of the vouchers of tenants that set none, by
+// channel and locale
+var builtin = map[string]map[string]*notification.Template{
+	notification.ChannelEmail: {
+		"en": {
+			Subject: "Your voucher for {{.Offer}}",
+			Body: "Hello{{with .FirstName}} {{.}}{{end}},\n\n" +
+				"your voucher code {{.Code}} gives you {{.Discount}} off {{.Offer}}. " +
+				"It is valid until {{.Expires}}.\n",
+		},
+		"de": {
+			Subject: "Dein Gutschein für {{.Offer}}",
+			Body: "Hallo{{with .FirstName}} {{.}}{{end}},\n\n" +
+				"mit dem Gutscheincode {{.Code}} erhältst du {{.Discount}} Rabatt auf {{.Offer}}. " +
+				"Er ist gültig bis {{.Expires}}.\n",
+		},
+		"fr": {
+			Subject: "Votre bon pour {{.Offer}}",
+			Body: "Bonjour{{with .FirstName}} {{.}}{{end}},\n\n" +
+				"votre code {{.Code}} vous donne {{.Discount}} de réduction sur {{.Offer}}. " +
+				"Il est valable jusqu'au {{.Expires}}.\n",
+		},
+	},
+	notification.ChannelSMS: {
+		"en": {Body: "{{.Code}}: {{.Discount}} off {{.Offer}}, valid until {{.Expires}}"},
+		"de": {Body: "{{.Code}}: {{.Discount}} Rabatt auf {{.Offer}}, gültig bis {{.Expires}}"},
+		"fr": {Body: "{{.Code}} : {{.Discount}} de réduction sur {{.Offer}}, valable jusqu'au {{.Expires}}"},
+	},
+}
@@ -0,0 +1,103 @@
+package offerservice
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/cache"
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+)
+
+type cachedOfferService struct {
+	OfferService
+	cache *cache.Cache
+}
+
+// NewCachedOfferService will instantiate an Offer Service caching the reads
+// of next and invalidating them on Update, UpdateIfVersion, Delete and
+// Restore
+func NewCachedOfferService(next OfferService, c *cache.Cache) OfferService {
+	return &cachedOfferService{
+		OfferService: next,
+		cache:        c,
+	}
+}
+
+func (cs *cachedOfferService) GetByID(ctx context.Context, id uint) (*offer.Offer, error) {
+	if id == 0 {
+		return cs.OfferService.GetByID(ctx, id)
+	}
+	var o offer.Offer
+	err := cs.cache.Fetch(ctx, idKey(ctx, id), &o, func() (interface{}, error) {
+		return cs.OfferService.GetByID(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// GetByName caches the id of the offer by name and the offer itself by id,
+// so Update only has to invalidate the id. An entry left under the name of
+// a renamed offer is detected by comparing names and dropped.
+func (cs *cachedOfferService) GetByName(ctx context.Context, name string) (*offer.Offer, error) {
+	if name == "" {
+		return cs.OfferService.GetByName(ctx, name)
+	}
+	var id uint
+	err := cs.cache.Fetch(ctx, nameKey(ctx, name), &id, func() (interface{}, error) {
+		o, err := cs.OfferService.GetByName(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return o.ID, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	o, err := cs.GetByID(ctx, id)
+	if err != nil || o.Name != name {
+		cs.cache.Invalidate(ctx, nameKey(ctx, name))
+		return cs.OfferService.GetByName(ctx, name)
+	}
+	return o, nil
+}
+
+func (cs *cachedOfferService) Update(ctx context.Context, offer *offer.Offer) error {
+	err := cs.OfferService.Update(ctx, offer)
+	cs.cache.Invalidate(ctx, idKey(ctx, offer.ID))
+	return err
+}
+
+func (cs *cachedOfferService) UpdateIfVersion(ctx context.Context, offer *offer.Offer, version uint) error {
+	err := cs.OfferService.UpdateIfVersion(ctx, offer, version)
+	cs.cache.Invalidate(ctx, idKey(ctx, offer.ID))
+	return err
+}
+
+func (cs *cachedOfferService) Delete(ctx context.Context, id uint, at time.Time) error {
+	err := cs.OfferService.Delete(ctx, id, at)
+	cs.cache.Invalidate(ctx, idKey(ctx, id))
+	return err
+}
+
+func (cs *cachedOfferService) Restore(ctx context.Context, id uint) error {
+	err := cs.OfferService.Restore(ctx, id)
+	cs.cache.Invalidate(ctx, idKey(ctx, id))
+	return err
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// keys carry the tenant so a cached row never leaks into another tenant
+func idKey(ctx context.Context, id uint) string {
+	return fmt.Sprintf("offer:%d:id:%d", tenant.FromContext(ctx), id)
+}
+
+func nameKey(ctx context.Context, name string) string {
+	return fmt.Sprintf("offer:%d:name:%s", tenant.FromContext(ctx), name)
+}
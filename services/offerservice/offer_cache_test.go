@@ -0,0 +1,120 @@
+package offerservice
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/cache"
+	"github.com/deepinbytes/go_voucher/domain/offer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newCachedService(repo *repoMock) OfferService {
+	return NewCachedOfferService(NewOfferService(repo), cache.New("offers", cache.NewLRU(10), time.Minute))
+}
+
+func TestCachedGetByID(t *testing.T) {
+	t.Run("Reads the repository once", func(t *testing.T) {
+		expected := &offer.Offer{Name: "Test"}
+		expected.ID = testID10
+
+		offerRepo := new(repoMock)
+		s := newCachedService(offerRepo)
+		offerRepo.On("GetByID", testID10).Return(expected, nil).Once()
+
+		s.GetByID(context.Background(), testID10)
+		result, err := s.GetByID(context.Background(), testID10)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Test", result.Name)
+		offerRepo.AssertNumberOfCalls(t, "GetByID", 1)
+	})
+
+	t.Run("Does not cache errors", func(t *testing.T) {
+		expected := errors.New("record not found")
+
+		offerRepo := new(repoMock)
+		s := newCachedService(offerRepo)
+		offerRepo.On("GetByID", testID10).Return(&offer.Offer{}, expected)
+
+		s.GetByID(context.Background(), testID10)
+		result, err := s.GetByID(context.Background(), testID10)
+
+		assert.Nil(t, result)
+		assert.Equal(t, expected, err)
+		offerRepo.AssertNumberOfCalls(t, "GetByID", 2)
+	})
+}
+
+func TestCachedUpdate(t *testing.T) {
+	t.Run("Invalidates the offer read by id and name", func(t *testing.T) {
+		old := &offer.Offer{Name: testName, DiscountPercentage: 10}
+		old.ID = testID10
+		updated := &offer.Offer{Name: testName, DiscountPercentage: 20}
+		updated.ID = testID10
+
+		offerRepo := new(repoMock)
+		s := newCachedService(offerRepo)
+		offerRepo.On("GetByID", testID10).Return(old, nil).Once()
+		offerRepo.On("GetByName", testName).Return(old, nil).Once()
+		offerRepo.On("Update", updated).Return(nil)
+		offerRepo.On("GetByID", testID10).Return(updated, nil).Once()
+
+		s.GetByID(context.Background(), testID10)
+		s.GetByName(context.Background(), testName)
+		assert.Nil(t, s.Update(context.Background(), updated))
+		byID, _ := s.GetByID(context.Background(), testID10)
+		byName, _ := s.GetByName(context.Background(), testName)
+
+		assert.Equal(t, uint(20), byID.DiscountPercentage)
+		assert.Equal(t, uint(20), byName.DiscountPercentage)
+		offerRepo.AssertNumberOfCalls(t, "GetByName", 1)
+	})
+
+	t.Run("Drops entries under the old name after a rename", func(t *testing.T) {
+		old := &offer.Offer{Name: testName}
+		old.ID = testID10
+		renamed := &offer.Offer{Name: "renamed"}
+		renamed.ID = testID10
+		notFound := errors.New("record not found")
+
+		offerRepo := new(repoMock)
+		s := newCachedService(offerRepo)
+		offerRepo.On("GetByName", testName).Return(old, nil).Once()
+		offerRepo.On("GetByID", testID10).Return(old, nil).Once()
+		offerRepo.On("Update", renamed).Return(nil)
+		offerRepo.On("GetByID", testID10).Return(renamed, nil)
+		offerRepo.On("GetByName", testName).Return(&offer.Offer{}, notFound)
+
+		s.GetByName(context.Background(), testName)
+		s.Update(context.Background(), renamed)
+		result, err := s.GetByName(context.Background(), testName)
+
+		assert.Nil(t, result)
+		assert.Equal(t, notFound, err)
+	})
+}
+
+func TestCachedDelete(t *testing.T) {
+	t.Run("Invalidates the deleted offer", func(t *testing.T) {
+		o := &offer.Offer{Name: testName}
+		o.ID = testID10
+
+		offerRepo := new(repoMock)
+		s := newCachedService(offerRepo)
+		offerRepo.On("GetByID", testID10).Return(o, nil).Once()
+		offerRepo.On("Delete", testID10, mock.Anything).Return(nil)
+		offerRepo.On("GetByID", testID10).Return(&offer.Offer{}, errors.New("record not found")).Once()
+
+		s.GetByID(context.Background(), testID10)
+		assert.Nil(t, s.Delete(context.Background(), testID10, time.Now()))
+		result, err := s.GetByID(context.Background(), testID10)
+
+		assert.Nil(t, result)
+		assert.EqualError(t, err, "record not found")
+	})
+}
@@ -1,8 +1,10 @@
 package offerservice
 
 import (
+	"context"
 	"github.com/deepinbytes/go_voucher/domain/offer"
 	"github.com/stretchr/testify/mock"
+	"time"
 )
 
 var (
@@ -15,22 +17,62 @@ type repoMock struct {
 	mock.Mock
 }
 
-func (repo *repoMock) GetByID(id uint) (*offer.Offer, error) {
+func (repo *repoMock) GetByID(ctx context.Context, id uint) (*offer.Offer, error) {
 	args := repo.Called(id)
 	return args.Get(0).(*offer.Offer), args.Error(1)
 }
 
-func (repo *repoMock) GetByName(email string) (*offer.Offer, error) {
+func (repo *repoMock) GetByName(ctx context.Context, email string) (*offer.Offer, error) {
 	args := repo.Called(email)
 	return args.Get(0).(*offer.Offer), args.Error(1)
 }
 
-func (repo *repoMock) Create(offer *offer.Offer) error {
+func (repo *repoMock) Create(ctx context.Context, offer *offer.Offer) error {
 	args := repo.Called(offer)
 	return args.Error(0)
 }
 
-func (repo *repoMock) Update(offer *offer.Offer) error {
+func (repo *repoMock) Update(ctx context.Context, offer *offer.Offer) error {
 	args := repo.Called(offer)
 	return args.Error(0)
 }
+
+func (repo *repoMock) ListAll(ctx context.Context) ([]*offer.Offer, error) {
+	args := repo.Called()
+	return args.Get(0).([]*offer.Offer), args.Error(1)
+}
+
+func (repo *repoMock) GetByIDs(ctx context.Context, ids []uint) ([]*offer.Offer, error) {
+	args := repo.Called(ids)
+	return args.Get(0).([]*offer.Offer), args.Error(1)
+}
+
+func (repo *repoMock) ListPage(ctx context.Context, after uint, limit int) ([]*offer.Offer, error) {
+	args := repo.Called(after, limit)
+	return args.Get(0).([]*offer.Offer), args.Error(1)
+}
+
+func (repo *repoMock) UpdateIfVersion(ctx context.Context, offer *offer.Offer, version uint) error {
+	args := repo.Called(offer, version)
+	return args.Error(0)
+}
+
+func (repo *repoMock) Delete(ctx context.Context, id uint, at time.Time) error {
+	args := repo.Called(id, at)
+	return args.Error(0)
+}
+
+func (repo *repoMock) GetDeleted(ctx context.Context, id uint) (*offer.Offer, error) {
+	args := repo.Called(id)
+	return args.Get(0).(*offer.Offer), args.Error(1)
+}
+
+func (repo *repoMock) Restore(ctx context.Context, id uint) error {
+	args := repo.Called(id)
+	return args.Error(0)
+}
+
+func (repo *repoMock) Purge(ctx context.Context, before time.Time) (int64, error) {
+	args := repo.Called(before)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -1,17 +1,36 @@
 package offerservice
 
 import (
+	"context"
 	"errors"
+	"github.com/deepinbytes/go_voucher/domain/money"
 	"github.com/deepinbytes/go_voucher/domain/offer"
 	"github.com/deepinbytes/go_voucher/repositories/offerrepo"
+	"time"
+)
+
+var (
+	// ErrDiscount is returned for offers with both kinds of discount
+	ErrDiscount = errors.New("discount_percentage and discount_amount exclude each other")
+	// ErrAmounts is returned for discount amounts without a discount_amount
+	// to convert from, or below one minor unit
+	ErrAmounts = errors.New("discount_amounts need a discount_amount and must be positive")
 )
 
 // OfferService interface
 type OfferService interface {
-	GetByID(id uint) (*offer.Offer, error)
-	GetByName(name string) (*offer.Offer, error)
-	Create(*offer.Offer) error
-	Update(*offer.Offer) error
+	GetByID(ctx context.Context, id uint) (*offer.Offer, error)
+	GetByName(ctx context.Context, name string) (*offer.Offer, error)
+	Create(ctx context.Context, offer *offer.Offer) error
+	Update(ctx context.Context, offer *offer.Offer) error
+	ListAll(ctx context.Context) ([]*offer.Offer, error)
+	GetByIDs(ctx context.Context, ids []uint) ([]*offer.Offer, error)
+	ListPage(ctx context.Context, after uint, limit int) ([]*offer.Offer, error)
+	UpdateIfVersion(ctx context.Context, offer *offer.Offer, version uint) error
+	Delete(ctx context.Context, id uint, at time.Time) error
+	GetDeleted(ctx context.Context, id uint) (*offer.Offer, error)
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, before time.Time) (int64, error)
 }
 
 type offerService struct {
@@ -28,32 +47,112 @@ func NewOfferService(
 	}
 }
 
-func (os *offerService) GetByID(id uint) (*offer.Offer, error) {
+func (os *offerService) GetByID(ctx context.Context, id uint) (*offer.Offer, error) {
 	if id == 0 {
 		return nil, errors.New("id param is required")
 	}
-	offer, err := os.Repo.GetByID(id)
+	offer, err := os.Repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	return offer, nil
 }
 
-func (os *offerService) GetByName(name string) (*offer.Offer, error) {
+func (os *offerService) GetByName(ctx context.Context, name string) (*offer.Offer, error) {
 	if name == "" {
 		return nil, errors.New("Name(string) is required")
 	}
-	user, err := os.Repo.GetByName(name)
+	user, err := os.Repo.GetByName(ctx, name)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-func (os *offerService) Create(offer *offer.Offer) error {
-	return os.Repo.Create(offer)
+func (os *offerService) Create(ctx context.Context, offer *offer.Offer) error {
+	if err := checkDiscount(offer); err != nil {
+		return err
+	}
+	return os.Repo.Create(ctx, offer)
+}
+
+func (os *offerService) Update(ctx context.Context, offer *offer.Offer) error {
+	if err := checkDiscount(offer); err != nil {
+		return err
+	}
+	return os.Repo.Update(ctx, offer)
+}
+
+func (os *offerService) ListAll(ctx context.Context) ([]*offer.Offer, error) {
+	return os.Repo.ListAll(ctx)
+}
+
+func (os *offerService) GetByIDs(ctx context.Context, ids []uint) ([]*offer.Offer, error) {
+	return os.Repo.GetByIDs(ctx, ids)
+}
+
+func (os *offerService) ListPage(ctx context.Context, after uint, limit int) ([]*offer.Offer, error) {
+	return os.Repo.ListPage(ctx, after, limit)
 }
 
-func (os *offerService) Update(offer *offer.Offer) error {
-	return os.Repo.Update(offer)
+func (os *offerService) UpdateIfVersion(ctx context.Context, offer *offer.Offer, version uint) error {
+	if err := checkDiscount(offer); err != nil {
+		return err
+	}
+	return os.Repo.UpdateIfVersion(ctx, offer, version)
+}
+
+func (os *offerService) Delete(ctx context.Context, id uint, at time.Time) error {
+	if id == 0 {
+		return errors.New("id param is required")
+	}
+	return os.Repo.Delete(ctx, id, at)
+}
+
+func (os *offerService) GetDeleted(ctx context.Context, id uint) (*offer.Offer, error) {
+	if id == 0 {
+		return nil, errors.New("id param is required")
+	}
+	return os.Repo.GetDeleted(ctx, id)
+}
+
+func (os *offerService) Restore(ctx context.Context, id uint) error {
+	if id == 0 {
+		return errors.New("id param is required")
+	}
+	return os.Repo.Restore(ctx, id)
+}
+
+func (os *offerService) Purge(ctx context.Context, before time.Time) (int64, error) {
+	return os.Repo.Purge(ctx, before)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// checkDiscount validates the fixed discount of o, percentages are
+// checked by callers as before fixed discounts
+func checkDiscount(o *offer.Offer) error {
+	if !o.Fixed() {
+		if len(o.DiscountAmounts) > 0 {
+			return ErrAmounts
+		}
+		return nil
+	}
+	if o.DiscountPercentage > 0 {
+		return ErrDiscount
+	}
+	if _, err := money.Lookup(o.DiscountCurrency); err != nil {
+		return err
+	}
+	for code, amount := range o.DiscountAmounts {
+		if _, err := money.Lookup(code); err != nil {
+			return err
+		}
+		if amount <= 0 {
+			return ErrAmounts
+		}
+	}
+	return nil
 }
@@ -1,7 +1,9 @@
 package offerservice
 
 import (
+	"context"
 	"errors"
+	"github.com/deepinbytes/go_voucher/domain/money"
 	"github.com/deepinbytes/go_voucher/domain/offer"
 	"testing"
 
@@ -18,7 +20,7 @@ func TestGetByID(t *testing.T) {
 		u := NewOfferService(offerRepo)
 		offerRepo.On("GetByID", testID100).Return(expected, nil)
 
-		result, _ := u.GetByID(testID100)
+		result, _ := u.GetByID(context.Background(), testID100)
 
 		assert.EqualValues(t, expected, result)
 	})
@@ -29,7 +31,7 @@ func TestGetByID(t *testing.T) {
 		offerRepo := new(repoMock)
 		u := NewOfferService(offerRepo)
 
-		result, err := u.GetByID(0)
+		result, err := u.GetByID(context.Background(), 0)
 
 		assert.Nil(t, result)
 		assert.EqualValues(t, expected, err)
@@ -42,7 +44,7 @@ func TestGetByID(t *testing.T) {
 		u := NewOfferService(offerRepo)
 		offerRepo.On("GetByID", testID10).Return(&offer.Offer{}, expected)
 
-		result, err := u.GetByID(testID10)
+		result, err := u.GetByID(context.Background(), testID10)
 
 		assert.Nil(t, result)
 		assert.EqualValues(t, expected, err)
@@ -60,7 +62,7 @@ func TestGetByEmail(t *testing.T) {
 		u := NewOfferService(offerRepo)
 		offerRepo.On("GetByName", testName).Return(expected, nil)
 
-		result, _ := u.GetByName(testName)
+		result, _ := u.GetByName(context.Background(), testName)
 
 		assert.EqualValues(t, expected, result)
 	})
@@ -72,7 +74,7 @@ func TestGetByEmail(t *testing.T) {
 
 		u := NewOfferService(offerRepo)
 
-		result, err := u.GetByName("")
+		result, err := u.GetByName(context.Background(), "")
 
 		assert.Nil(t, result)
 		assert.EqualValues(t, expected, err)
@@ -86,7 +88,7 @@ func TestGetByEmail(t *testing.T) {
 		u := NewOfferService(offerRepo)
 		offerRepo.On("GetByName", testName).Return(&offer.Offer{}, expected)
 
-		result, err := u.GetByName(testName)
+		result, err := u.GetByName(context.Background(), testName)
 
 		assert.Nil(t, result)
 		assert.EqualValues(t, expected, err)
@@ -104,7 +106,7 @@ func TestCreate(t *testing.T) {
 		u := NewOfferService(offerRepo)
 		offerRepo.On("Create", offer).Return(nil)
 
-		result := u.Create(offer)
+		result := u.Create(context.Background(), offer)
 
 		assert.Nil(t, result)
 	})
@@ -120,10 +122,40 @@ func TestCreate(t *testing.T) {
 		u := NewOfferService(offerRepo)
 
 		offerRepo.On("Create", offer).Return(err)
-		result := u.Create(offer)
+		result := u.Create(context.Background(), offer)
 
 		assert.EqualValues(t, result, err)
 	})
+
+	t.Run("Create a fixed discount offer", func(t *testing.T) {
+		offer := &offer.Offer{
+			Name:             "test",
+			DiscountAmount:   500,
+			DiscountCurrency: "EUR",
+			DiscountAmounts:  money.Amounts{"GBP": 450},
+		}
+
+		offerRepo := new(repoMock)
+
+		u := NewOfferService(offerRepo)
+		offerRepo.On("Create", offer).Return(nil)
+
+		assert.Nil(t, u.Create(context.Background(), offer))
+	})
+
+	t.Run("Invalid discounts", func(t *testing.T) {
+		u := NewOfferService(new(repoMock))
+
+		for expected, o := range map[error]*offer.Offer{
+			ErrDiscount:       {DiscountPercentage: 10, DiscountAmount: 500, DiscountCurrency: "EUR"},
+			ErrAmounts:        {DiscountPercentage: 10, DiscountAmounts: money.Amounts{"GBP": 450}},
+			money.ErrCurrency: {DiscountAmount: 500, DiscountCurrency: "JPY"},
+		} {
+			assert.Equal(t, expected, u.Create(context.Background(), o))
+		}
+		o := &offer.Offer{DiscountAmount: 500, DiscountCurrency: "EUR", DiscountAmounts: money.Amounts{"GBP": 0}}
+		assert.Equal(t, ErrAmounts, u.Create(context.Background(), o))
+	})
 }
 
 func TestUpdate(t *testing.T) {
@@ -137,7 +169,7 @@ func TestUpdate(t *testing.T) {
 		u := NewOfferService(offerRepo)
 		offerRepo.On("Update", usr).Return(nil)
 
-		result := u.Update(usr)
+		result := u.Update(context.Background(), usr)
 
 		assert.Nil(t, result)
 	})
@@ -153,7 +185,7 @@ func TestUpdate(t *testing.T) {
 		u := NewOfferService(offerRepo)
 		offerRepo.On("Update", usr).Return(err)
 
-		result := u.Update(usr)
+		result := u.Update(context.Background(), usr)
 
 		assert.EqualValues(t, result, err)
 	})
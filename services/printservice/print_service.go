@@ -0,0 +1,192 @@
+package printservice
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/barcodes"
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/tokenservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+)
+
+// sheetQRSize is the size of the QR codes on sheets, in pixels
+const sheetQRSize = 160
+
+// Sheet is a page of the vouchers of an offer laid out for printing
+type Sheet struct {
+	Offer    *offer.Offer
+	Discount string
+	Labels   []*Label
+	// Last is the ID of the last voucher, the next page starts after it
+	Last uint
+}
+
+// Label is a voucher on a sheet
+type Label struct {
+	Code    string
+	Expires string
+	// QR is the QR code of the payload as a data URL
+	QR template.URL
+}
+
+// PrintService renders voucher codes for in-store use. The payload of a
+// code is the code itself or, signed, the token of the voucher, so scanners
+// holding the verification key can tell genuine codes offline.
+type PrintService interface {
+	// QR renders the payload of a code as a PNG of at most size pixels
+	// square
+	QR(ctx context.Context, code string, size int, signed bool) ([]byte, error)
+	// Barcode renders the payload of a code as a Code 128 or EAN PNG of at
+	// most width by height pixels
+	Barcode(ctx context.Context, code, format string, width, height int, signed bool) ([]byte, error)
+	// Sheet lays out a page of the vouchers of an offer that are neither
+	// used nor expired, limit after the voucher ID after
+	Sheet(ctx context.Context, offerID uint, after uint, limit int, signed bool) (*Sheet, error)
+}
+
+type printService struct {
+	vouchers voucherservice.VoucherService
+	offers   offerservice.OfferService
+	tokens   tokenservice.TokenService
+	now      func() time.Time
+}
+
+// NewPrintService will instantiate Print Service, signed payloads are the
+// tokens of tokens
+func NewPrintService(
+	vouchers voucherservice.VoucherService,
+	offers offerservice.OfferService,
+	tokens tokenservice.TokenService,
+) PrintService {
+
+	return &printService{
+		vouchers: vouchers,
+		offers:   offers,
+		tokens:   tokens,
+		now:      time.Now,
+	}
+}
+
+func (ps *printService) QR(ctx context.Context, code string, size int, signed bool) ([]byte, error) {
+	payload, err := ps.payload(ctx, code, signed)
+	if err != nil {
+		return nil, err
+	}
+	return barcodes.QR(payload, size)
+}
+
+func (ps *printService) Barcode(ctx context.Context, code, format string, width, height int, signed bool) ([]byte, error) {
+	payload, err := ps.payload(ctx, code, signed)
+	if err != nil {
+		return nil, err
+	}
+	return barcodes.Linear(format, payload, width, height)
+}
+
+func (ps *printService) Sheet(ctx context.Context, offerID uint, after uint, limit int, signed bool) (*Sheet, error) {
+	// Key fails without a signer, before any voucher is read
+	if signed {
+		if _, err := ps.tokens.Key(); err != nil {
+			return nil, err
+		}
+	}
+	o, err := ps.offers.GetByID(ctx, offerID)
+	if err != nil {
+		return nil, err
+	}
+	no := false
+	vs, err := ps.vouchers.ListByOffers(ctx, []uint{offerID}, voucherrepo.ListOptions{
+		After: after, Limit: limit, IsUsed: &no, Expired: &no, Now: ps.now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sheet := &Sheet{Offer: o, Discount: fmt.Sprintf("%d%%", o.DiscountPercentage)}
+	if o.Fixed() {
+		sheet.Discount = money.New(o.DiscountAmount, o.DiscountCurrency).String()
+	}
+	for _, v := range vs {
+		payload, err := ps.sign(ctx, v, signed)
+		if err != nil {
+			return nil, err
+		}
+		png, err := barcodes.QR(payload, sheetQRSize)
+		if err != nil {
+			return nil, err
+		}
+		sheet.Labels = append(sheet.Labels, &Label{
+			Code:    v.Code,
+			Expires: v.ExpireTime.UTC().Format("2006-01-02"),
+			QR:      template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png)),
+		})
+		sheet.Last = v.ID
+	}
+	return sheet, nil
+}
+
+// WriteHTML writes the sheet as an HTML page that prints on A4
+func (s *Sheet) WriteHTML(w io.Writer) error {
+	return sheetTemplate.Execute(w, s)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// payload is the payload of the voucher with code, revoked vouchers have
+// none
+func (ps *printService) payload(ctx context.Context, code string, signed bool) (string, error) {
+	v, err := ps.vouchers.UseCode(ctx, code)
+	if err != nil {
+		return "", err
+	}
+	return ps.sign(ctx, v, signed)
+}
+
+func (ps *printService) sign(ctx context.Context, v *voucher.Voucher, signed bool) (string, error) {
+	if !signed {
+		return v.Code, nil
+	}
+	return ps.tokens.Sign(ctx, v)
+}
+
+var sheetTemplate = template.Must(template.New("sheet").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Offer.Name}}</title>
+<style>
+@page { size: A4; margin: 10mm; }
+body { font-family: sans-serif; margin: 0; }
+.labels { display: flex; flex-wrap: wrap; }
+.label { box-sizing: border-box; width: 33.33%; padding: 4mm; border: 1px dashed #999; text-align: center; break-inside: avoid; page-break-inside: avoid; }
+.label img { width: 40mm; height: 40mm; image-rendering: pixelated; }
+.offer { font-weight: bold; }
+.code { font-family: monospace; font-size: 14pt; letter-spacing: 1px; }
+.expires { font-size: 9pt; color: #555; }
+</style>
+</head>
+<body>
+<div class="labels">
+{{- range .Labels}}
+<div class="label">
+<div class="offer">{{$.Offer.Name}} &middot; {{$.Discount}}</div>
+<img src="{{.QR}}" alt="{{.Code}}">
+<div class="code">{{.Code}}</div>
+<div class="expires">valid until {{.Expires}}</div>
+</div>
+{{- end}}
+</div>
+</body>
+</html>
+`))
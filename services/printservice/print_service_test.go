@@ -0,0 +1,146 @@
+package printservice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/barcodes"
+	"github.com/deepinbytes/go_voucher/common/keys"
+	"github.com/deepinbytes/go_voucher/common/signing"
+	"github.com/deepinbytes/go_voucher/common/verify"
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+	"github.com/deepinbytes/go_voucher/repositories/offerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/offlinerepo"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/tokenservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	ctx = context.Background()
+	now = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+)
+
+// setup gives an offer three printable vouchers, a used one and an expired
+// one
+func setup(t *testing.T, ring *keys.Ring) (*printService, *offer.Offer) {
+	db := memdb.New()
+	offers := offerservice.NewOfferService(offerrepo.NewMemoryOfferRepo(db))
+	vouchers := voucherservice.NewVoucherService(voucherrepo.NewMemoryVoucherRepo(db))
+	tokens := tokenservice.NewTokenService(vouchers, offlinerepo.NewMemoryOfflineRepo(db), ring)
+	svc := NewPrintService(vouchers, offers, tokens).(*printService)
+	svc.now = func() time.Time { return now }
+
+	o := &offer.Offer{Name: "Summer <Sale>", DiscountPercentage: 20}
+	require.Nil(t, offers.Create(ctx, o))
+	for _, v := range []*voucher.Voucher{
+		{Code: "ACTIVE01", ExpireTime: now.Add(50 * time.Hour)},
+		{Code: "ACTIVE02", ExpireTime: now.Add(time.Hour)},
+		{Code: "USED0001", IsUsed: true, UsedAt: now.Add(-time.Hour), ExpireTime: now.Add(time.Hour)},
+		{Code: "EXPIRED1", ExpireTime: now.Add(-time.Hour)},
+		{Code: "ACTIVE03", ExpireTime: now.Add(time.Hour)},
+	} {
+		v.OfferID = o.ID
+		require.Nil(t, vouchers.Create(ctx, v))
+	}
+	return svc, o
+}
+
+func signer(t *testing.T) signing.Signer {
+	s, err := signing.NewHMAC([]byte(strings.Repeat("k", signing.MinKeySize)))
+	require.Nil(t, err)
+	return s
+}
+
+func ring(t *testing.T) *keys.Ring {
+	r, err := keys.NewRing(&keys.Key{ID: keys.DefaultID, Signer: signer(t)})
+	require.Nil(t, err)
+	return r
+}
+
+// token is the token of ACTIVE01
+func token(t *testing.T, o *offer.Offer) string {
+	return verify.Issue(signer(t), &verify.Claims{Tenant: 1, Code: "ACTIVE01", Offer: o.ID,
+		Expires: now.Add(50 * time.Hour).Unix(), KeyID: keys.DefaultID})
+}
+
+func TestCodes(t *testing.T) {
+	svc, o := setup(t, ring(t))
+
+	t.Run("Renders the payload", func(t *testing.T) {
+		b, err := svc.QR(ctx, "ACTIVE01", 200, false)
+		require.Nil(t, err)
+		want, err := barcodes.QR("ACTIVE01", 200)
+		require.Nil(t, err)
+		assert.Equal(t, want, b)
+
+		b, err = svc.Barcode(ctx, "ACTIVE01", barcodes.Code128, 400, 80, false)
+		require.Nil(t, err)
+		_, err = png.Decode(bytes.NewReader(b))
+		assert.Nil(t, err)
+	})
+
+	t.Run("Encodes the token", func(t *testing.T) {
+		b, err := svc.QR(ctx, "ACTIVE01", 200, true)
+		require.Nil(t, err)
+		want, err := barcodes.QR(token(t, o), 200)
+		require.Nil(t, err)
+		assert.Equal(t, want, b)
+	})
+
+	t.Run("Fails for unknown codes and formats", func(t *testing.T) {
+		_, err := svc.QR(ctx, "NOPE0001", 200, false)
+		assert.NotNil(t, err)
+		_, err = svc.Barcode(ctx, "ACTIVE01", barcodes.EAN, 400, 80, false)
+		assert.True(t, errors.Is(err, barcodes.ErrEncode), "got %v", err)
+	})
+
+	t.Run("Requires a signer to sign", func(t *testing.T) {
+		unsigned, _ := setup(t, nil)
+		_, err := unsigned.QR(ctx, "ACTIVE01", 200, true)
+		assert.Equal(t, tokenservice.ErrNoSigner, err)
+		_, err = unsigned.Sheet(ctx, 1, 0, 10, true)
+		assert.Equal(t, tokenservice.ErrNoSigner, err)
+	})
+}
+
+func TestSheet(t *testing.T) {
+	svc, o := setup(t, ring(t))
+
+	sheet, err := svc.Sheet(ctx, o.ID, 0, 2, true)
+	require.Nil(t, err)
+	assert.Equal(t, "20%", sheet.Discount)
+	require.Len(t, sheet.Labels, 2)
+	assert.Equal(t, "ACTIVE01", sheet.Labels[0].Code)
+	assert.Equal(t, "2020-06-03", sheet.Labels[0].Expires)
+	assert.Equal(t, uint(2), sheet.Last)
+	want, err := barcodes.QR(token(t, o), sheetQRSize)
+	require.Nil(t, err)
+	assert.Equal(t, len("data:image/png;base64,")+(len(want)+2)/3*4, len(sheet.Labels[0].QR))
+
+	// used and expired vouchers are left out
+	sheet, err = svc.Sheet(ctx, o.ID, sheet.Last, 2, false)
+	require.Nil(t, err)
+	require.Len(t, sheet.Labels, 1)
+	assert.Equal(t, "ACTIVE03", sheet.Labels[0].Code)
+
+	var html bytes.Buffer
+	require.Nil(t, sheet.WriteHTML(&html))
+	assert.Contains(t, html.String(), "<title>Summer &lt;Sale&gt;</title>")
+	assert.Contains(t, html.String(), `<img src="data:image/png;base64,`)
+	assert.Contains(t, html.String(), `<div class="code">ACTIVE03</div>`)
+
+	_, err = svc.Sheet(ctx, 404, 0, 2, false)
+	assert.NotNil(t, err)
+}
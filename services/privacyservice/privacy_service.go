@@ -0,0 +1,243 @@
+package privacyservice
+
+import (
+	"context"
+	"errors"
+
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/privacy"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/notificationrepo"
+	"github.com/deepinbytes/go_voucher/repositories/privacyrepo"
+	"github.com/deepinbytes/go_voucher/repositories/referralrepo"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/services/lifecycleservice"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/userservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/jinzhu/gorm"
+)
+
+// PrivacyService answers the data subject requests of users, every one of
+// them is recorded for compliance
+type PrivacyService interface {
+	// Export bundles the personal data, vouchers, revoked ones too,
+	// redemptions, referrals and notification deliveries of the user
+	Export(ctx context.Context, userID uint) (*privacy.Export, error)
+	// Erase pseudonymizes the user and revokes its unused vouchers. Its
+	// redemptions are kept, no longer tied to a person, so the voucher
+	// statistics of the offers stay the same.
+	Erase(ctx context.Context, userID uint) (*privacy.Record, error)
+	// Records lists the requests made for the user, erased or not
+	Records(ctx context.Context, userID uint) ([]*privacy.Record, error)
+}
+
+type privacyService struct {
+	users         userservice.UserService
+	offers        offerservice.OfferService
+	vouchers      voucherservice.VoucherService
+	lifecycle     lifecycleservice.LifecycleService
+	records       privacyrepo.Repo
+	referrals     referralrepo.Repo
+	notifications notificationrepo.Repo
+}
+
+// NewPrivacyService will instantiate Privacy Service
+func NewPrivacyService(
+	users userservice.UserService,
+	offers offerservice.OfferService,
+	vouchers voucherservice.VoucherService,
+	lifecycle lifecycleservice.LifecycleService,
+	records privacyrepo.Repo,
+	referrals referralrepo.Repo,
+	notifications notificationrepo.Repo,
+) PrivacyService {
+
+	return &privacyService{
+		users:         users,
+		offers:        offers,
+		vouchers:      vouchers,
+		lifecycle:     lifecycle,
+		records:       records,
+		referrals:     referrals,
+		notifications: notifications,
+	}
+}
+
+func (ps *privacyService) Export(ctx context.Context, userID uint) (*privacy.Export, error) {
+	u, err := ps.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	vouchers, err := ps.vouchers.ListByUsers(ctx, []uint{userID}, voucherrepo.ListOptions{Deleted: true})
+	if err != nil {
+		return nil, err
+	}
+	offers, err := ps.offersOf(ctx, vouchers)
+	if err != nil {
+		return nil, err
+	}
+	code, err := ps.referrals.GetCode(ctx, userID)
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return nil, err
+	}
+	referrals, err := ps.referrals.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := ps.notifications.ListDeliveriesByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the export is recorded first so the bundle lists it too
+	record := &privacy.Record{UserID: userID, Action: privacy.ActionExport}
+	if err := ps.records.Create(ctx, record); err != nil {
+		return nil, err
+	}
+	records, err := ps.records.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &privacy.Export{
+		ExportedAt:  record.CreatedAt,
+		Profile:     profile(u),
+		Vouchers:    []privacy.Voucher{},
+		Redemptions: []privacy.Redemption{},
+		Referrals:   []privacy.Referral{},
+		Deliveries:  []privacy.Delivery{},
+		Requests:    make([]privacy.Record, len(records)),
+	}
+	if code != nil {
+		export.ReferralCode = code.Code
+	}
+	codes := make(map[uint]string, len(vouchers))
+	for _, v := range vouchers {
+		codes[v.ID] = v.Code
+		o := offers[v.OfferID]
+		export.Vouchers = append(export.Vouchers, privacy.Voucher{
+			Code:               v.Code,
+			OfferID:            v.OfferID,
+			OfferName:          o.Name,
+			DiscountPercentage: o.DiscountPercentage,
+			IssuedAt:           v.CreatedAt,
+			ExpireTime:         v.ExpireTime,
+			IsUsed:             v.IsUsed,
+			RevokedAt:          v.DeletedAt,
+		})
+		if v.IsUsed {
+			export.Redemptions = append(export.Redemptions, privacy.Redemption{
+				Code:      v.Code,
+				OfferID:   v.OfferID,
+				OfferName: o.Name,
+				UsedAt:    v.UsedAt,
+			})
+		}
+	}
+	for _, r := range referrals {
+		role := privacy.RoleReferee
+		if r.ReferrerID == userID {
+			role = privacy.RoleReferrer
+		}
+		export.Referrals = append(export.Referrals, privacy.Referral{
+			Role:       role,
+			Status:     r.Status,
+			CreatedAt:  r.CreatedAt,
+			RewardedAt: r.RewardedAt,
+		})
+	}
+	for _, d := range deliveries {
+		export.Deliveries = append(export.Deliveries, privacy.Delivery{
+			Code:      codes[d.VoucherID],
+			Channel:   d.Channel,
+			Status:    d.Status,
+			CreatedAt: d.CreatedAt,
+			SentAt:    d.SentAt,
+		})
+	}
+	for i, r := range records {
+		export.Requests[i] = *r
+	}
+	return export, nil
+}
+
+func (ps *privacyService) Erase(ctx context.Context, userID uint) (*privacy.Record, error) {
+	if userID == 0 {
+		return nil, errors.New("id param is required")
+	}
+	if err := ps.lifecycle.DeleteUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	record := &privacy.Record{UserID: userID, Action: privacy.ActionErasure}
+	if err := ps.records.Create(ctx, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (ps *privacyService) Records(ctx context.Context, userID uint) ([]*privacy.Record, error) {
+	if userID == 0 {
+		return nil, errors.New("id param is required")
+	}
+	return ps.records.ListByUser(ctx, userID)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// offersOf returns the offers of the vouchers by ID, deleted ones too as
+// they revoke their vouchers. Purged offers are missing, their vouchers are
+// exported without the offer details.
+func (ps *privacyService) offersOf(ctx context.Context, vouchers []*voucher.Voucher) (map[uint]offer.Offer, error) {
+	byID := make(map[uint]offer.Offer)
+	if len(vouchers) == 0 {
+		return byID, nil
+	}
+	var ids []uint
+	seen := make(map[uint]bool)
+	for _, v := range vouchers {
+		if !seen[v.OfferID] {
+			seen[v.OfferID] = true
+			ids = append(ids, v.OfferID)
+		}
+	}
+	offers, err := ps.offers.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range offers {
+		byID[o.ID] = *o
+	}
+	for _, id := range ids {
+		if _, ok := byID[id]; ok {
+			continue
+		}
+		o, err := ps.offers.GetDeleted(ctx, id)
+		if gorm.IsRecordNotFoundError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		byID[id] = *o
+	}
+	return byID, nil
+}
+
+func profile(u *user.User) privacy.Profile {
+	return privacy.Profile{
+		ID:        u.ID,
+		Email:     u.Email,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Birthday:  u.Birthday,
+		Phone:     u.Phone,
+		Locale:    u.Locale,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
+}
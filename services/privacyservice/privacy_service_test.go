@@ -0,0 +1,183 @@
+package privacyservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/notification"
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/privacy"
+	"github.com/deepinbytes/go_voucher/domain/referral"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+	"github.com/deepinbytes/go_voucher/repositories/notificationrepo"
+	"github.com/deepinbytes/go_voucher/repositories/offerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/privacyrepo"
+	"github.com/deepinbytes/go_voucher/repositories/referralrepo"
+	"github.com/deepinbytes/go_voucher/repositories/userrepo"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/services/lifecycleservice"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/userservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+type fixture struct {
+	svc           PrivacyService
+	users         userservice.UserService
+	vouchers      voucherservice.VoucherService
+	lifecycle     lifecycleservice.LifecycleService
+	referrals     referralrepo.Repo
+	notifications notificationrepo.Repo
+	alice         *user.User
+	summer        *offer.Offer
+}
+
+// setup seeds alice with a redeemed and an unused voucher of one offer
+func setup(t *testing.T) *fixture {
+	db := memdb.New()
+	users := userservice.NewUserService(userrepo.NewMemoryUserRepo(db))
+	offers := offerservice.NewOfferService(offerrepo.NewMemoryOfferRepo(db))
+	vouchers := voucherservice.NewVoucherService(voucherrepo.NewMemoryVoucherRepo(db))
+	f := &fixture{
+		users:         users,
+		vouchers:      vouchers,
+		lifecycle:     lifecycleservice.NewLifecycleService(offers, users, vouchers),
+		referrals:     referralrepo.NewMemoryReferralRepo(db),
+		notifications: notificationrepo.NewMemoryNotificationRepo(db),
+	}
+	f.svc = NewPrivacyService(users, offers, vouchers, f.lifecycle, privacyrepo.NewMemoryPrivacyRepo(db),
+		f.referrals, f.notifications)
+
+	f.alice = &user.User{FirstName: "Alice", LastName: "Doe", Email: "alice@cc.cc", Birthday: "1990-05-17",
+		Phone: "+4915112345678"}
+	require.Nil(t, users.Create(ctx, f.alice))
+	summer := &offer.Offer{Name: "Summer", DiscountPercentage: 10}
+	require.Nil(t, offers.Create(ctx, summer))
+	f.summer = summer
+	usedAt := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	require.Nil(t, vouchers.Create(ctx, &voucher.Voucher{
+		Code: "USED0001", OfferID: summer.ID, UserID: f.alice.ID, IsUsed: true, UsedAt: usedAt}))
+	require.Nil(t, vouchers.Create(ctx, &voucher.Voucher{
+		Code: "UNUSED01", OfferID: summer.ID, UserID: f.alice.ID}))
+	return f
+}
+
+func TestExport(t *testing.T) {
+	t.Run("Bundles the personal data", func(t *testing.T) {
+		f := setup(t)
+
+		export, err := f.svc.Export(ctx, f.alice.ID)
+
+		require.Nil(t, err)
+		assert.Equal(t, "alice@cc.cc", export.Profile.Email)
+		assert.Equal(t, "1990-05-17", export.Profile.Birthday)
+		assert.Equal(t, "+4915112345678", export.Profile.Phone)
+		assert.Equal(t, "Alice", export.Profile.FirstName)
+		require.Len(t, export.Vouchers, 2)
+		assert.Equal(t, "Summer", export.Vouchers[0].OfferName)
+		assert.Equal(t, uint(10), export.Vouchers[0].DiscountPercentage)
+		require.Len(t, export.Redemptions, 1)
+		assert.Equal(t, "USED0001", export.Redemptions[0].Code)
+		require.Len(t, export.Requests, 1)
+		assert.Equal(t, privacy.ActionExport, export.Requests[0].Action)
+	})
+
+	t.Run("Includes revoked vouchers, referrals and deliveries", func(t *testing.T) {
+		f := setup(t)
+		unused, err := f.vouchers.UseCode(ctx, "UNUSED01")
+		require.Nil(t, err)
+		require.Nil(t, f.notifications.CreateDelivery(ctx, &notification.Delivery{VoucherID: unused.ID, UserID: f.alice.ID,
+			Channel: notification.ChannelEmail, Status: notification.StatusSent}))
+		require.Nil(t, f.referrals.CreateCode(ctx, &referral.Code{UserID: f.alice.ID, Code: "ALICE1"}))
+		require.Nil(t, f.referrals.Create(ctx, &referral.Referral{ReferrerID: f.alice.ID, RefereeID: 42,
+			Status: referral.StatusPending}))
+		require.Nil(t, f.lifecycle.DeleteOffer(ctx, f.summer.ID))
+
+		export, err := f.svc.Export(ctx, f.alice.ID)
+
+		require.Nil(t, err)
+		require.Len(t, export.Vouchers, 2)
+		revoked := export.Vouchers[1]
+		assert.Equal(t, "UNUSED01", revoked.Code)
+		assert.NotNil(t, revoked.RevokedAt)
+		assert.Equal(t, "Summer", revoked.OfferName)
+		assert.Nil(t, export.Vouchers[0].RevokedAt)
+		assert.Equal(t, "ALICE1", export.ReferralCode)
+		if assert.Len(t, export.Referrals, 1) {
+			assert.Equal(t, privacy.RoleReferrer, export.Referrals[0].Role)
+			assert.Equal(t, referral.StatusPending, export.Referrals[0].Status)
+		}
+		if assert.Len(t, export.Deliveries, 1) {
+			assert.Equal(t, "UNUSED01", export.Deliveries[0].Code)
+			assert.Equal(t, notification.StatusSent, export.Deliveries[0].Status)
+		}
+	})
+
+	t.Run("Fails for unknown users without a record", func(t *testing.T) {
+		f := setup(t)
+
+		_, err := f.svc.Export(ctx, 42)
+
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+		records, err := f.svc.Records(ctx, 42)
+		require.Nil(t, err)
+		assert.Empty(t, records)
+	})
+}
+
+func TestErase(t *testing.T) {
+	t.Run("Pseudonymizes and keeps the redemptions", func(t *testing.T) {
+		f := setup(t)
+
+		record, err := f.svc.Erase(ctx, f.alice.ID)
+
+		require.Nil(t, err)
+		assert.Equal(t, privacy.ActionErasure, record.Action)
+		_, err = f.users.GetByEmail(ctx, "alice@cc.cc")
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+		erased, err := f.users.GetDeleted(ctx, f.alice.ID)
+		require.Nil(t, err)
+		assert.Equal(t, user.AnonymousEmail(f.alice.ID), erased.Email)
+		assert.Equal(t, "", erased.LastName)
+		assert.Equal(t, "", erased.Birthday)
+		assert.Equal(t, "", erased.Phone)
+
+		used, err := f.vouchers.UseCode(ctx, "USED0001")
+		require.Nil(t, err)
+		assert.Equal(t, f.alice.ID, used.UserID)
+		_, err = f.vouchers.UseCode(ctx, "UNUSED01")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Records outlive the user", func(t *testing.T) {
+		f := setup(t)
+		_, err := f.svc.Export(ctx, f.alice.ID)
+		require.Nil(t, err)
+		_, err = f.svc.Erase(ctx, f.alice.ID)
+		require.Nil(t, err)
+
+		records, err := f.svc.Records(ctx, f.alice.ID)
+
+		require.Nil(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, privacy.ActionExport, records[0].Action)
+		assert.Equal(t, privacy.ActionErasure, records[1].Action)
+	})
+
+	t.Run("Fails for unknown users", func(t *testing.T) {
+		f := setup(t)
+
+		_, err := f.svc.Erase(ctx, 42)
+
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+	})
+}
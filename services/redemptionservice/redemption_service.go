@@ -0,0 +1,302 @@
+package redemptionservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/common/rates"
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/redemption"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/userservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ErrMaxDiscount is returned for caps above redemption.MaxDiscount
+var ErrMaxDiscount = fmt.Errorf("max discount must be at most %d", redemption.MaxDiscount)
+
+// RedemptionService redeems several vouchers for one order
+type RedemptionService interface {
+	// Apply redeems the codes of req that combine, by priority, and tells
+	// why the others were rejected. Nothing is redeemed on a dry run.
+	Apply(ctx context.Context, req redemption.Request) (*redemption.Result, error)
+}
+
+type redemptionService struct {
+	users    userservice.UserService
+	offers   offerservice.OfferService
+	vouchers voucherservice.VoucherService
+	rates    rates.RateProvider
+	now      func() time.Time
+}
+
+// NewRedemptionService will instantiate Redemption Service. Fixed
+// discounts are converted to currencies they have no amount in with
+// rateProvider, they are rejected in those currencies when it is nil.
+func NewRedemptionService(
+	users userservice.UserService,
+	offers offerservice.OfferService,
+	vouchers voucherservice.VoucherService,
+	rateProvider rates.RateProvider,
+) RedemptionService {
+
+	return &redemptionService{
+		users:    users,
+		offers:   offers,
+		vouchers: vouchers,
+		rates:    rateProvider,
+		now:      time.Now,
+	}
+}
+
+// candidate is a code that can be redeemed on its own
+type candidate struct {
+	voucher *voucher.Voucher
+	offer   *offer.Offer
+	owner   *user.User
+	// amount is the discount on the order total, when there is one
+	amount int64
+}
+
+// Apply checks every code first, then walks the valid ones by priority,
+// higher discounts first on a tie, and applies each that combines with
+// those applied before it. Discounts add up to the cap, the last one
+// applied may only count in part.
+func (rs *redemptionService) Apply(ctx context.Context, req redemption.Request) (*redemption.Result, error) {
+	if len(req.Codes) == 0 {
+		return nil, errors.New("codes is required")
+	}
+	if req.Email == "" {
+		return nil, errors.New("email is required")
+	}
+	if req.MaxDiscount > redemption.MaxDiscount {
+		return nil, ErrMaxDiscount
+	}
+	res := &redemption.Result{
+		Applied:     []redemption.Applied{},
+		Rejected:    []redemption.Rejected{},
+		MaxDiscount: req.MaxDiscount,
+		DryRun:      req.DryRun,
+	}
+	if res.MaxDiscount == 0 {
+		res.MaxDiscount = redemption.MaxDiscount
+	}
+	// with an order total the cap is an amount too
+	var capAmount int64
+	if total := req.OrderTotal; total != nil {
+		capped, err := money.Percent(*total, res.MaxDiscount)
+		if err != nil {
+			return nil, err
+		}
+		capAmount = capped.Amount
+		res.DiscountAmount = &money.Money{Currency: total.Currency}
+	}
+
+	candidates, err := rs.candidates(ctx, req, res)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.offer.Priority != b.offer.Priority {
+			return a.offer.Priority > b.offer.Priority
+		}
+		if a.amount != b.amount {
+			return a.amount > b.amount
+		}
+		return a.offer.DiscountPercentage > b.offer.DiscountPercentage
+	})
+
+	var applied []candidate
+	for _, c := range candidates {
+		reason, msg := rs.conflict(c, applied)
+		if reason == "" && (res.TotalDiscount >= res.MaxDiscount || req.OrderTotal != nil && res.DiscountAmount.Amount >= capAmount) {
+			reason, msg = redemption.ReasonCapReached, fmt.Sprintf("the discount is already at its cap of %d%%", res.MaxDiscount)
+		}
+		if reason != "" {
+			res.Rejected = append(res.Rejected, redemption.Rejected{Code: c.voucher.Code, Reason: reason, Message: msg})
+			continue
+		}
+		a := redemption.Applied{
+			Code:               c.voucher.Code,
+			VoucherID:          c.voucher.ID,
+			OfferID:            c.offer.ID,
+			Offer:              c.offer.Name,
+			Priority:           c.offer.Priority,
+			DiscountPercentage: c.offer.DiscountPercentage,
+			Discount:           c.offer.DiscountPercentage,
+		}
+		if left := res.MaxDiscount - res.TotalDiscount; a.Discount > left {
+			a.Discount = left
+		}
+		res.TotalDiscount += a.Discount
+		if req.OrderTotal != nil {
+			amount := c.amount
+			if left := capAmount - res.DiscountAmount.Amount; amount > left {
+				amount = left
+			}
+			res.DiscountAmount.Amount += amount
+			a.Amount = &money.Money{Amount: amount, Currency: req.OrderTotal.Currency}
+			// recorded on redeem, for the stats of the offer
+			c.voucher.DiscountAmount, c.voucher.DiscountCurrency = amount, req.OrderTotal.Currency
+		}
+		applied = append(applied, c)
+		res.Applied = append(res.Applied, a)
+	}
+	if total := req.OrderTotal; total != nil {
+		res.Payable = &money.Money{Amount: total.Amount - res.DiscountAmount.Amount, Currency: total.Currency}
+	}
+
+	if !req.DryRun {
+		if err := rs.redeem(ctx, applied, req.Email); err != nil {
+			return nil, err
+		}
+	}
+	logger.FromContext(ctx).Info("vouchers applied", logger.Fields{
+		"applied":        len(res.Applied),
+		"rejected":       len(res.Rejected),
+		"total_discount": res.TotalDiscount,
+		"dry_run":        req.DryRun,
+	})
+	return res, nil
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// candidates looks the codes up and keeps those that could be redeemed on
+// their own, the others are added to res as rejected
+func (rs *redemptionService) candidates(ctx context.Context, req redemption.Request, res *redemption.Result) ([]candidate, error) {
+	reject := func(code, reason, msg string) {
+		res.Rejected = append(res.Rejected, redemption.Rejected{Code: code, Reason: reason, Message: msg})
+	}
+	now := rs.now()
+	seen := map[string]bool{}
+	var out []candidate
+	for _, code := range req.Codes {
+		if seen[code] {
+			reject(code, redemption.ReasonDuplicate, "code given more than once")
+			continue
+		}
+		seen[code] = true
+
+		v, err := rs.vouchers.UseCode(ctx, code)
+		if gorm.IsRecordNotFoundError(err) {
+			reject(code, redemption.ReasonNotFound, "voucher not found")
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		o, err := rs.offers.GetByID(ctx, v.OfferID)
+		if gorm.IsRecordNotFoundError(err) {
+			reject(code, redemption.ReasonOfferUnavailable, "Offer not available anymore")
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		owner, err := rs.users.GetByID(ctx, v.UserID)
+		if gorm.IsRecordNotFoundError(err) {
+			reject(code, redemption.ReasonWrongUser, voucherservice.ErrWrongUser.Error())
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := voucherservice.Check(v, owner, req.Email, now); err != nil {
+			reject(code, voucherservice.Reason(err), err.Error())
+			continue
+		}
+		c := candidate{voucher: v, offer: o, owner: owner}
+		switch {
+		case req.OrderTotal != nil:
+			amount, err := rs.amount(ctx, o, *req.OrderTotal)
+			if errors.Is(err, rates.ErrNoRate) {
+				reject(code, redemption.ReasonCurrency, fmt.Sprintf("offer %q has no discount in %s", o.Name, req.OrderTotal.Currency))
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			c.amount = amount.Amount
+		case o.Fixed():
+			reject(code, redemption.ReasonOrderTotal, "fixed discounts need the order total")
+			continue
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// amount is the discount of o on total: a percentage of it, the amount
+// of o in its currency, or the amount of o converted to it
+func (rs *redemptionService) amount(ctx context.Context, o *offer.Offer, total money.Money) (money.Money, error) {
+	if !o.Fixed() {
+		return money.Percent(total, o.DiscountPercentage)
+	}
+	if amount, ok := o.DiscountAmounts[total.Currency]; ok {
+		return money.New(amount, total.Currency), nil
+	}
+	base := money.New(o.DiscountAmount, o.DiscountCurrency)
+	if base.Currency == total.Currency {
+		return base, nil
+	}
+	if rs.rates == nil {
+		return money.Money{}, rates.ErrNoRate
+	}
+	rate, err := rs.rates.Rate(ctx, base.Currency, total.Currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return money.Convert(base, total.Currency, rate)
+}
+
+// conflict tells why c cannot join the codes applied so far, an empty
+// reason when it can
+func (rs *redemptionService) conflict(c candidate, applied []candidate) (string, string) {
+	for _, a := range applied {
+		switch {
+		case !a.offer.Stackable:
+			return redemption.ReasonNotStackable, fmt.Sprintf("%s does not stack with other vouchers", a.voucher.Code)
+		case !c.offer.Stackable:
+			return redemption.ReasonNotStackable, fmt.Sprintf("offer %q does not stack with other vouchers", c.offer.Name)
+		case a.offer.ID == c.offer.ID:
+			return redemption.ReasonExclusive, fmt.Sprintf("%s already applies offer %q", a.voucher.Code, c.offer.Name)
+		case c.offer.ExclusivityGroup != "" && a.offer.ExclusivityGroup == c.offer.ExclusivityGroup:
+			return redemption.ReasonExclusive, fmt.Sprintf("%s already applies an offer of group %q", a.voucher.Code, c.offer.ExclusivityGroup)
+		}
+	}
+	return "", ""
+}
+
+// redeem redeems the applied codes, all or none: a failure reverses those
+// redeemed before it
+func (rs *redemptionService) redeem(ctx context.Context, applied []candidate, email string) error {
+	for i, c := range applied {
+		err := rs.vouchers.Redeem(ctx, c.voucher, c.owner, email)
+		if err == nil {
+			continue
+		}
+		for _, done := range applied[:i] {
+			if rerr := rs.vouchers.Reverse(ctx, done.voucher); rerr != nil {
+				logger.FromContext(ctx).Error("reversing redemption failed", logger.Fields{
+					"voucher_id": done.voucher.ID,
+					"error":      rerr,
+				})
+			}
+		}
+		return err
+	}
+	return nil
+}
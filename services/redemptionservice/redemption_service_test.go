@@ -0,0 +1,221 @@
+package redemptionservice
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/rates"
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/redemption"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+	"github.com/deepinbytes/go_voucher/repositories/offerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/userrepo"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/userservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+type fixture struct {
+	svc      RedemptionService
+	vouchers voucherservice.VoucherService
+}
+
+// setup gives alice a voucher of every offer, named after the offer, and
+// bob a voucher of the first one
+func setup(t *testing.T, offers ...*offer.Offer) *fixture {
+	db := memdb.New()
+	users := userservice.NewUserService(userrepo.NewMemoryUserRepo(db))
+	offerSvc := offerservice.NewOfferService(offerrepo.NewMemoryOfferRepo(db))
+	vouchers := voucherservice.NewVoucherService(voucherrepo.NewMemoryVoucherRepo(db))
+
+	alice := &user.User{Email: "alice@cc.cc"}
+	bob := &user.User{Email: "bob@cc.cc"}
+	require.Nil(t, users.Create(ctx, alice))
+	require.Nil(t, users.Create(ctx, bob))
+	expire := time.Now().Add(time.Hour)
+	for _, o := range offers {
+		require.Nil(t, offerSvc.Create(ctx, o))
+		require.Nil(t, vouchers.Create(ctx, &voucher.Voucher{Code: o.Name, OfferID: o.ID, UserID: alice.ID, ExpireTime: expire}))
+	}
+	require.Nil(t, vouchers.Create(ctx, &voucher.Voucher{Code: "BOB1", OfferID: offers[0].ID, UserID: bob.ID, ExpireTime: expire}))
+	require.Nil(t, vouchers.Create(ctx, &voucher.Voucher{Code: "OLD1", OfferID: offers[0].ID, UserID: alice.ID, ExpireTime: time.Now().Add(-time.Hour)}))
+	table, err := rates.ParseTable(strings.NewReader(`{"base": "EUR", "rates": {"GBP": 0.85}}`))
+	require.Nil(t, err)
+	return &fixture{svc: NewRedemptionService(users, offerSvc, vouchers, table), vouchers: vouchers}
+}
+
+func (f *fixture) apply(t *testing.T, req redemption.Request) *redemption.Result {
+	req.Email = "alice@cc.cc"
+	res, err := f.svc.Apply(ctx, req)
+	require.Nil(t, err)
+	return res
+}
+
+func applied(res *redemption.Result) []string {
+	out := []string{}
+	for _, a := range res.Applied {
+		out = append(out, a.Code)
+	}
+	return out
+}
+
+func rejected(res *redemption.Result) map[string]string {
+	out := map[string]string{}
+	for _, r := range res.Rejected {
+		out[r.Code] = r.Reason
+	}
+	return out
+}
+
+func TestApply(t *testing.T) {
+	t.Run("Stacks by priority up to the cap", func(t *testing.T) {
+		f := setup(t,
+			&offer.Offer{Name: "LOW1", DiscountPercentage: 30, Stackable: true},
+			&offer.Offer{Name: "HIGH", DiscountPercentage: 20, Stackable: true, Priority: 5},
+			&offer.Offer{Name: "BIG1", DiscountPercentage: 40, Stackable: true},
+		)
+
+		res := f.apply(t, redemption.Request{Codes: []string{"LOW1", "HIGH", "BIG1"}, MaxDiscount: 75})
+
+		assert.Equal(t, []string{"HIGH", "BIG1", "LOW1"}, applied(res))
+		assert.Equal(t, uint(15), res.Applied[2].Discount)
+		assert.Equal(t, uint(75), res.TotalDiscount)
+		v, err := f.vouchers.UseCode(ctx, "LOW1")
+		require.Nil(t, err)
+		assert.True(t, v.IsUsed)
+	})
+
+	t.Run("Rejects once the cap is reached", func(t *testing.T) {
+		f := setup(t,
+			&offer.Offer{Name: "HALF", DiscountPercentage: 50, Stackable: true},
+			&offer.Offer{Name: "MORE", DiscountPercentage: 10, Stackable: true},
+		)
+
+		res := f.apply(t, redemption.Request{Codes: []string{"HALF", "MORE"}, MaxDiscount: 50})
+
+		assert.Equal(t, []string{"HALF"}, applied(res))
+		assert.Equal(t, map[string]string{"MORE": redemption.ReasonCapReached}, rejected(res))
+	})
+
+	t.Run("Non stackable offers apply alone", func(t *testing.T) {
+		f := setup(t,
+			&offer.Offer{Name: "SOLO", DiscountPercentage: 25, Priority: 1},
+			&offer.Offer{Name: "PAIR", DiscountPercentage: 10, Stackable: true},
+		)
+
+		res := f.apply(t, redemption.Request{Codes: []string{"PAIR", "SOLO"}})
+		assert.Equal(t, []string{"SOLO"}, applied(res))
+		assert.Equal(t, map[string]string{"PAIR": redemption.ReasonNotStackable}, rejected(res))
+	})
+
+	t.Run("One offer per exclusivity group", func(t *testing.T) {
+		f := setup(t,
+			&offer.Offer{Name: "SPRG", DiscountPercentage: 10, Stackable: true, ExclusivityGroup: "season"},
+			&offer.Offer{Name: "SUMR", DiscountPercentage: 15, Stackable: true, ExclusivityGroup: "season"},
+			&offer.Offer{Name: "SHIP", DiscountPercentage: 5, Stackable: true},
+		)
+
+		res := f.apply(t, redemption.Request{Codes: []string{"SPRG", "SUMR", "SHIP"}})
+		assert.Equal(t, []string{"SUMR", "SHIP"}, applied(res))
+		assert.Equal(t, map[string]string{"SPRG": redemption.ReasonExclusive}, rejected(res))
+	})
+
+	t.Run("Rejects invalid codes", func(t *testing.T) {
+		f := setup(t, &offer.Offer{Name: "GOOD", DiscountPercentage: 10, Stackable: true})
+
+		res := f.apply(t, redemption.Request{Codes: []string{"GOOD", "GOOD", "NOPE", "BOB1", "OLD1"}})
+		assert.Equal(t, []string{"GOOD"}, applied(res))
+		assert.Equal(t, map[string]string{
+			"GOOD": redemption.ReasonDuplicate,
+			"NOPE": redemption.ReasonNotFound,
+			"BOB1": redemption.ReasonWrongUser,
+			"OLD1": redemption.ReasonExpired,
+		}, rejected(res))
+
+		res = f.apply(t, redemption.Request{Codes: []string{"GOOD"}})
+		assert.Equal(t, map[string]string{"GOOD": redemption.ReasonUsed}, rejected(res))
+	})
+
+	t.Run("Works out amounts of the order total", func(t *testing.T) {
+		f := setup(t,
+			&offer.Offer{Name: "PCT1", DiscountPercentage: 15, Stackable: true},
+			&offer.Offer{Name: "EUR1", DiscountAmount: 500, DiscountCurrency: "EUR", Stackable: true, Priority: 1},
+			&offer.Offer{Name: "GBP1", DiscountAmount: 300, DiscountCurrency: "EUR", DiscountAmounts: money.Amounts{"GBP": 250}, Stackable: true},
+			&offer.Offer{Name: "USD1", DiscountAmount: 300, DiscountCurrency: "USD", Stackable: true},
+		)
+		total := money.New(4999, "GBP")
+
+		res := f.apply(t, redemption.Request{Codes: []string{"PCT1", "EUR1", "GBP1", "USD1"}, OrderTotal: &total, DryRun: true})
+
+		// 5 EUR at 0.85 is 4.25 GBP, 15% of 49.99 GBP is 7.4985 GBP
+		assert.Equal(t, []string{"EUR1", "PCT1", "GBP1"}, applied(res))
+		assert.Equal(t, money.New(425, "GBP"), *res.Applied[0].Amount)
+		assert.Equal(t, money.New(750, "GBP"), *res.Applied[1].Amount)
+		assert.Equal(t, money.New(250, "GBP"), *res.Applied[2].Amount)
+		assert.Equal(t, money.New(1425, "GBP"), *res.DiscountAmount)
+		assert.Equal(t, money.New(3574, "GBP"), *res.Payable)
+		assert.Equal(t, map[string]string{"USD1": redemption.ReasonCurrency}, rejected(res))
+	})
+
+	t.Run("Caps amounts", func(t *testing.T) {
+		f := setup(t,
+			&offer.Offer{Name: "BIG1", DiscountAmount: 2000, DiscountCurrency: "EUR", Stackable: true},
+			&offer.Offer{Name: "BIG2", DiscountAmount: 2000, DiscountCurrency: "EUR", Stackable: true},
+		)
+		total := money.New(3000, "EUR")
+
+		res := f.apply(t, redemption.Request{Codes: []string{"BIG1", "BIG2"}, OrderTotal: &total, MaxDiscount: 50})
+
+		assert.Equal(t, []string{"BIG1"}, applied(res))
+		assert.Equal(t, money.New(1500, "EUR"), *res.DiscountAmount)
+		assert.Equal(t, map[string]string{"BIG2": redemption.ReasonCapReached}, rejected(res))
+		// the stats sum the amount applied, not the one of the offer
+		v, err := f.vouchers.UseCode(ctx, "BIG1")
+		require.Nil(t, err)
+		assert.Equal(t, int64(1500), v.DiscountAmount)
+		assert.Equal(t, "EUR", v.DiscountCurrency)
+	})
+
+	t.Run("Fixed discounts need the order total", func(t *testing.T) {
+		f := setup(t, &offer.Offer{Name: "EUR1", DiscountAmount: 500, DiscountCurrency: "EUR"})
+
+		res := f.apply(t, redemption.Request{Codes: []string{"EUR1"}})
+		assert.Equal(t, map[string]string{"EUR1": redemption.ReasonOrderTotal}, rejected(res))
+	})
+
+	t.Run("Dry run redeems nothing", func(t *testing.T) {
+		f := setup(t, &offer.Offer{Name: "TRY1", DiscountPercentage: 10})
+
+		res := f.apply(t, redemption.Request{Codes: []string{"TRY1"}, DryRun: true})
+		assert.Equal(t, []string{"TRY1"}, applied(res))
+		assert.True(t, res.DryRun)
+		v, err := f.vouchers.UseCode(ctx, "TRY1")
+		require.Nil(t, err)
+		assert.False(t, v.IsUsed)
+	})
+
+	t.Run("Bad requests", func(t *testing.T) {
+		f := setup(t, &offer.Offer{Name: "TRY1", DiscountPercentage: 10})
+
+		_, err := f.svc.Apply(ctx, redemption.Request{Email: "alice@cc.cc"})
+		assert.EqualError(t, err, "codes is required")
+		_, err = f.svc.Apply(ctx, redemption.Request{Codes: []string{"TRY1"}})
+		assert.EqualError(t, err, "email is required")
+		_, err = f.svc.Apply(ctx, redemption.Request{Codes: []string{"TRY1"}, Email: "alice@cc.cc", MaxDiscount: 101})
+		assert.Equal(t, ErrMaxDiscount, err)
+		total := money.New(100, "JPY")
+		_, err = f.svc.Apply(ctx, redemption.Request{Codes: []string{"TRY1"}, Email: "alice@cc.cc", OrderTotal: &total})
+		assert.Equal(t, money.ErrCurrency, err)
+	})
+}
@@ -0,0 +1,246 @@
+package referralservice
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/referral"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/repositories"
+	"github.com/deepinbytes/go_voucher/repositories/referralrepo"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/userservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ErrCode is returned for referral codes of no user
+var ErrCode = errors.New("referral code not found")
+
+const (
+	codeLength = 8
+	// codeAttempts bounds the new codes tried when one is taken
+	codeAttempts = 3
+)
+
+// Policy sets how referrals are rewarded and throttled
+type Policy struct {
+	// Offer names the offer both sides get a voucher of once the referee
+	// redeems a first voucher. Empty leaves referrals pending.
+	Offer string
+	// Validity is how long the reward vouchers are valid for
+	Validity time.Duration
+	// DomainLimit is the number of referees of one email domain a referrer
+	// may bring within DomainWindow, the next ones are rejected
+	DomainLimit  int
+	DomainWindow time.Duration
+}
+
+// ReferralService gives users a referral code and rewards the referrer and
+// the referee, a user registered with the code, once the referee redeems a
+// first voucher
+type ReferralService interface {
+	// Code returns the referral code of the user, created on first use
+	Code(ctx context.Context, userID uint) (*referral.Code, error)
+	// Lookup returns the referral code, ErrCode when there is none
+	Lookup(ctx context.Context, code string) (*referral.Code, error)
+	// Refer records that referee registered with code. A referral failing
+	// the fraud checks is recorded as rejected rather than returned as an
+	// error.
+	Refer(ctx context.Context, referee *user.User, code *referral.Code) (*referral.Referral, error)
+	// Redeemed rewards the pending referral of the user, if any
+	Redeemed(ctx context.Context, userID uint) error
+	// Stats sums up the referrals of the referrer, of all when it is 0
+	Stats(ctx context.Context, referrerID uint) (*referral.Stats, error)
+}
+
+type referralService struct {
+	repo     referralrepo.Repo
+	users    userservice.UserService
+	offers   offerservice.OfferService
+	vouchers voucherservice.VoucherService
+	policy   Policy
+	now      func() time.Time
+}
+
+// NewReferralService will instantiate Referral Service. vouchers issues the
+// rewards, it must not be one returned by NewRewardingVoucherService.
+func NewReferralService(
+	repo referralrepo.Repo,
+	users userservice.UserService,
+	offers offerservice.OfferService,
+	vouchers voucherservice.VoucherService,
+	policy Policy,
+) ReferralService {
+
+	return &referralService{
+		repo:     repo,
+		users:    users,
+		offers:   offers,
+		vouchers: vouchers,
+		policy:   policy,
+		now:      time.Now,
+	}
+}
+
+// Code tries new codes while they are taken, unless the user got one
+// concurrently
+func (rs *referralService) Code(ctx context.Context, userID uint) (*referral.Code, error) {
+	code, err := rs.repo.GetCode(ctx, userID)
+	if err == nil || !gorm.IsRecordNotFoundError(err) {
+		return code, err
+	}
+	if _, err := rs.users.GetByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	for attempt := 1; ; attempt++ {
+		code = &referral.Code{UserID: userID, Code: voucherservice.NewCode(codeLength)}
+		err = rs.repo.CreateCode(ctx, code)
+		if err == nil {
+			return code, nil
+		}
+		if existing, gerr := rs.repo.GetCode(ctx, userID); gerr == nil {
+			return existing, nil
+		}
+		if attempt == codeAttempts {
+			return nil, err
+		}
+	}
+}
+
+func (rs *referralService) Lookup(ctx context.Context, code string) (*referral.Code, error) {
+	if code == "" {
+		return nil, errors.New("referral code is required")
+	}
+	c, err := rs.repo.GetByCode(ctx, code)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, ErrCode
+	}
+	return c, err
+}
+
+func (rs *referralService) Refer(ctx context.Context, referee *user.User, code *referral.Code) (*referral.Referral, error) {
+	r := &referral.Referral{
+		ReferrerID:    code.UserID,
+		RefereeID:     referee.ID,
+		RefereeDomain: referral.Domain(referee.Email),
+		Status:        referral.StatusPending,
+		// the domain window is worked out with the same clock
+		CreatedAt: rs.now(),
+	}
+	reason, err := rs.fraud(ctx, r, referee)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		r.Status, r.Reason = referral.StatusRejected, reason
+	}
+	if err := rs.repo.Create(ctx, r); err != nil {
+		return nil, err
+	}
+	logger.FromContext(ctx).Info("referral recorded", logger.Fields{
+		"referral_id": r.ID,
+		"referrer_id": r.ReferrerID,
+		"referee_id":  r.RefereeID,
+		"status":      r.Status,
+		"reason":      r.Reason,
+	})
+	return r, nil
+}
+
+// Redeemed claims the referral by moving it to rewarded before issuing the
+// vouchers, so concurrent redemptions reward it once. When issuing fails
+// it goes back to pending, keeping the vouchers already issued, and the
+// next redemption tries again.
+func (rs *referralService) Redeemed(ctx context.Context, userID uint) error {
+	if rs.policy.Offer == "" {
+		return nil
+	}
+	r, err := rs.repo.GetByReferee(ctx, userID)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if r.Status != referral.StatusPending {
+		return nil
+	}
+	o, err := rs.offers.GetByName(ctx, rs.policy.Offer)
+	if err != nil {
+		return err
+	}
+
+	now := rs.now()
+	r.Status, r.RewardedAt = referral.StatusRewarded, &now
+	if err := rs.repo.UpdateIfStatus(ctx, r, referral.StatusPending); err != nil {
+		if err == repositories.ErrStale {
+			return nil
+		}
+		return err
+	}
+	expire := now.Add(rs.policy.Validity)
+	for _, side := range []struct {
+		userID    uint
+		voucherID *uint
+	}{{r.ReferrerID, &r.ReferrerVoucherID}, {r.RefereeID, &r.RefereeVoucherID}} {
+		if *side.voucherID != 0 {
+			continue
+		}
+		v, err := rs.vouchers.Issue(ctx, o.ID, side.userID, expire)
+		if err != nil {
+			r.Status, r.RewardedAt = referral.StatusPending, nil
+			if rerr := rs.repo.UpdateIfStatus(ctx, r, referral.StatusRewarded); rerr != nil {
+				logger.FromContext(ctx).Error("releasing referral failed", logger.Fields{
+					"referral_id": r.ID,
+					"error":       rerr,
+				})
+			}
+			return err
+		}
+		*side.voucherID = v.ID
+	}
+	if err := rs.repo.UpdateIfStatus(ctx, r, referral.StatusRewarded); err != nil {
+		return err
+	}
+	logger.FromContext(ctx).Info("referral rewarded", logger.Fields{
+		"referral_id": r.ID,
+		"referrer_id": r.ReferrerID,
+		"referee_id":  r.RefereeID,
+		"offer_id":    o.ID,
+	})
+	return nil
+}
+
+func (rs *referralService) Stats(ctx context.Context, referrerID uint) (*referral.Stats, error) {
+	return rs.repo.Stats(ctx, referrerID)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// fraud tells why r should be rejected, an empty reason when it should not
+func (rs *referralService) fraud(ctx context.Context, r *referral.Referral, referee *user.User) (string, error) {
+	if r.ReferrerID == r.RefereeID {
+		return referral.ReasonSelfReferral, nil
+	}
+	referrer, err := rs.users.GetByID(ctx, r.ReferrerID)
+	if err != nil {
+		return "", err
+	}
+	if referral.Canonical(referrer.Email) == referral.Canonical(referee.Email) {
+		return referral.ReasonSelfReferral, nil
+	}
+	n, err := rs.repo.CountByDomain(ctx, r.ReferrerID, r.RefereeDomain, rs.now().Add(-rs.policy.DomainWindow))
+	if err != nil {
+		return "", err
+	}
+	if n >= int64(rs.policy.DomainLimit) {
+		return referral.ReasonDomainThrottled, nil
+	}
+	return "", nil
+}
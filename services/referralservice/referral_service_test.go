@@ -0,0 +1,263 @@
+package referralservice
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/referral"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+	"github.com/deepinbytes/go_voucher/repositories/offerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/referralrepo"
+	"github.com/deepinbytes/go_voucher/repositories/userrepo"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/userservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	ctx = context.Background()
+	now = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+)
+
+type fixture struct {
+	svc      *referralService
+	users    userservice.UserService
+	vouchers voucherservice.VoucherService
+	offer    *offer.Offer
+	alice    *user.User
+	code     *referral.Code
+}
+
+// setup gives alice a referral code and creates the reward offer
+func setup(t *testing.T) *fixture {
+	db := memdb.New()
+	users := userservice.NewUserService(userrepo.NewMemoryUserRepo(db))
+	offers := offerservice.NewOfferService(offerrepo.NewMemoryOfferRepo(db))
+	vouchers := voucherservice.NewVoucherService(voucherrepo.NewMemoryVoucherRepo(db))
+	svc := NewReferralService(referralrepo.NewMemoryReferralRepo(db), users, offers, vouchers, Policy{
+		Offer:        "Referral",
+		Validity:     24 * time.Hour,
+		DomainLimit:  2,
+		DomainWindow: time.Hour,
+	}).(*referralService)
+	svc.now = func() time.Time { return now }
+
+	o := &offer.Offer{Name: "Referral", DiscountPercentage: 10}
+	require.Nil(t, offers.Create(ctx, o))
+	alice := &user.User{Email: "alice@gmail.com"}
+	require.Nil(t, users.Create(ctx, alice))
+	code, err := svc.Code(ctx, alice.ID)
+	require.Nil(t, err)
+	return &fixture{svc: svc, users: users, vouchers: vouchers, offer: o, alice: alice, code: code}
+}
+
+func (f *fixture) register(t *testing.T, email string) (*user.User, *referral.Referral) {
+	u := &user.User{Email: email}
+	require.Nil(t, f.users.Create(ctx, u))
+	r, err := f.svc.Refer(ctx, u, f.code)
+	require.Nil(t, err)
+	return u, r
+}
+
+func TestCode(t *testing.T) {
+	f := setup(t)
+	assert.Len(t, f.code.Code, codeLength)
+
+	again, err := f.svc.Code(ctx, f.alice.ID)
+	require.Nil(t, err)
+	assert.Equal(t, f.code.Code, again.Code)
+
+	found, err := f.svc.Lookup(ctx, f.code.Code)
+	require.Nil(t, err)
+	assert.Equal(t, f.alice.ID, found.UserID)
+
+	_, err = f.svc.Lookup(ctx, "UNKNOWN1")
+	assert.Equal(t, ErrCode, err)
+
+	_, err = f.svc.Code(ctx, 404)
+	assert.NotNil(t, err)
+}
+
+func TestRefer(t *testing.T) {
+	t.Run("Records a pending referral", func(t *testing.T) {
+		f := setup(t)
+		bob, r := f.register(t, "bob@example.com")
+
+		assert.Equal(t, referral.StatusPending, r.Status)
+		assert.Equal(t, f.alice.ID, r.ReferrerID)
+		assert.Equal(t, bob.ID, r.RefereeID)
+		assert.Equal(t, "example.com", r.RefereeDomain)
+	})
+
+	t.Run("Rejects self referrals", func(t *testing.T) {
+		f := setup(t)
+		_, r := f.register(t, "A.lice+promo@googlemail.com")
+		assert.Equal(t, referral.StatusRejected, r.Status)
+		assert.Equal(t, referral.ReasonSelfReferral, r.Reason)
+
+		r, err := f.svc.Refer(ctx, f.alice, f.code)
+		require.Nil(t, err)
+		assert.Equal(t, referral.ReasonSelfReferral, r.Reason)
+	})
+
+	t.Run("Throttles referees of one domain", func(t *testing.T) {
+		f := setup(t)
+		_, r1 := f.register(t, "bob@corp.com")
+		_, r2 := f.register(t, "carol@corp.com")
+		_, r3 := f.register(t, "dave@corp.com")
+		_, other := f.register(t, "erin@example.com")
+
+		assert.Equal(t, referral.StatusPending, r1.Status)
+		assert.Equal(t, referral.StatusPending, r2.Status)
+		assert.Equal(t, referral.StatusRejected, r3.Status)
+		assert.Equal(t, referral.ReasonDomainThrottled, r3.Reason)
+		assert.Equal(t, referral.StatusPending, other.Status)
+
+		f.svc.now = func() time.Time { return now.Add(2 * time.Hour) }
+		_, r4 := f.register(t, "frank@corp.com")
+		assert.Equal(t, referral.StatusPending, r4.Status)
+
+		stats, err := f.svc.Stats(ctx, f.alice.ID)
+		require.Nil(t, err)
+		assert.Equal(t, int64(5), stats.Total)
+		assert.Equal(t, int64(4), stats.Pending)
+		assert.Equal(t, map[string]int64{referral.ReasonDomainThrottled: 1}, stats.Reasons)
+	})
+}
+
+func TestRedeemed(t *testing.T) {
+	t.Run("Rewards both sides once", func(t *testing.T) {
+		f := setup(t)
+		bob, r := f.register(t, "bob@example.com")
+
+		require.Nil(t, f.svc.Redeemed(ctx, bob.ID))
+		require.Nil(t, f.svc.Redeemed(ctx, bob.ID))
+
+		r, err := f.svc.repo.GetByReferee(ctx, bob.ID)
+		require.Nil(t, err)
+		assert.Equal(t, referral.StatusRewarded, r.Status)
+		assert.Equal(t, now, *r.RewardedAt)
+		for id, owner := range map[uint]uint{r.ReferrerVoucherID: f.alice.ID, r.RefereeVoucherID: bob.ID} {
+			v, err := f.vouchers.GetByID(ctx, id)
+			require.Nil(t, err)
+			assert.Equal(t, owner, v.UserID)
+			assert.Equal(t, f.offer.ID, v.OfferID)
+			assert.Equal(t, now.Add(24*time.Hour), v.ExpireTime)
+		}
+		vs, err := f.vouchers.ListByOffers(ctx, []uint{f.offer.ID}, voucherrepo.ListOptions{})
+		require.Nil(t, err)
+		assert.Len(t, vs, 2)
+
+		stats, err := f.svc.Stats(ctx, 0)
+		require.Nil(t, err)
+		assert.Equal(t, int64(1), stats.Rewarded)
+		assert.Equal(t, int64(2), stats.VouchersIssued)
+	})
+
+	t.Run("Rewards concurrent redemptions once", func(t *testing.T) {
+		f := setup(t)
+		bob, _ := f.register(t, "bob@example.com")
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.Nil(t, f.svc.Redeemed(ctx, bob.ID))
+			}()
+		}
+		wg.Wait()
+
+		vs, err := f.vouchers.ListByOffers(ctx, []uint{f.offer.ID}, voucherrepo.ListOptions{})
+		require.Nil(t, err)
+		assert.Len(t, vs, 2)
+	})
+
+	t.Run("Skips rejected referrals and users not referred", func(t *testing.T) {
+		f := setup(t)
+		self, _ := f.register(t, "alice+2@gmail.com")
+
+		assert.Nil(t, f.svc.Redeemed(ctx, self.ID))
+		assert.Nil(t, f.svc.Redeemed(ctx, f.alice.ID))
+
+		vs, err := f.vouchers.ListByOffers(ctx, []uint{f.offer.ID}, voucherrepo.ListOptions{})
+		require.Nil(t, err)
+		assert.Empty(t, vs)
+	})
+
+	t.Run("Leaves referrals pending without an offer", func(t *testing.T) {
+		f := setup(t)
+		bob, _ := f.register(t, "bob@example.com")
+		f.svc.policy.Offer = ""
+
+		require.Nil(t, f.svc.Redeemed(ctx, bob.ID))
+
+		r, err := f.svc.repo.GetByReferee(ctx, bob.ID)
+		require.Nil(t, err)
+		assert.Equal(t, referral.StatusPending, r.Status)
+	})
+
+	t.Run("Releases the referral when issuing fails", func(t *testing.T) {
+		f := setup(t)
+		bob, _ := f.register(t, "bob@example.com")
+		f.svc.vouchers = &failingIssue{VoucherService: f.vouchers, after: 1}
+
+		assert.NotNil(t, f.svc.Redeemed(ctx, bob.ID))
+
+		r, err := f.svc.repo.GetByReferee(ctx, bob.ID)
+		require.Nil(t, err)
+		assert.Equal(t, referral.StatusPending, r.Status)
+		assert.Nil(t, r.RewardedAt)
+		assert.NotZero(t, r.ReferrerVoucherID)
+		assert.Zero(t, r.RefereeVoucherID)
+
+		f.svc.vouchers = f.vouchers
+		require.Nil(t, f.svc.Redeemed(ctx, bob.ID))
+
+		vs, err := f.vouchers.ListByOffers(ctx, []uint{f.offer.ID}, voucherrepo.ListOptions{})
+		require.Nil(t, err)
+		assert.Len(t, vs, 2)
+	})
+}
+
+func TestRewardingVoucherService(t *testing.T) {
+	f := setup(t)
+	bob, _ := f.register(t, "bob@example.com")
+	v, err := f.vouchers.Issue(ctx, f.offer.ID, bob.ID, time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	svc := NewRewardingVoucherService(f.vouchers, f.svc)
+
+	assert.NotNil(t, svc.Redeem(ctx, v, bob, "eve@example.com"))
+	r, err := f.svc.repo.GetByReferee(ctx, bob.ID)
+	require.Nil(t, err)
+	assert.Equal(t, referral.StatusPending, r.Status)
+
+	require.Nil(t, svc.Redeem(ctx, v, bob, bob.Email))
+	r, err = f.svc.repo.GetByReferee(ctx, bob.ID)
+	require.Nil(t, err)
+	assert.Equal(t, referral.StatusRewarded, r.Status)
+}
+
+// failingIssue fails to issue vouchers once it issued after of them
+type failingIssue struct {
+	voucherservice.VoucherService
+	after int
+}
+
+func (fi *failingIssue) Issue(ctx context.Context, offerID, userID uint, expireTime time.Time) (*voucher.Voucher, error) {
+	if fi.after == 0 {
+		return nil, errors.New("issuing failed")
+	}
+	fi.after--
+	return fi.VoucherService.Issue(ctx, offerID, userID, expireTime)
+}
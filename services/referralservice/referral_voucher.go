@@ -0,0 +1,55 @@
+package referralservice
+
+import (
+	"context"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+)
+
+type rewardingVoucherService struct {
+	voucherservice.VoucherService
+	referrals ReferralService
+}
+
+// NewRewardingVoucherService will instantiate a Voucher Service rewarding
+// the referral of the owner once next redeemed a voucher. A failed reward
+// is logged, it does not fail the redemption.
+func NewRewardingVoucherService(next voucherservice.VoucherService, referrals ReferralService) voucherservice.VoucherService {
+	return &rewardingVoucherService{
+		VoucherService: next,
+		referrals:      referrals,
+	}
+}
+
+func (rv *rewardingVoucherService) Redeem(ctx context.Context, v *voucher.Voucher, owner *user.User, email string) error {
+	if err := rv.VoucherService.Redeem(ctx, v, owner, email); err != nil {
+		return err
+	}
+	rv.reward(ctx, v)
+	return nil
+}
+
+func (rv *rewardingVoucherService) RedeemAt(ctx context.Context, v *voucher.Voucher, at time.Time) error {
+	if err := rv.VoucherService.RedeemAt(ctx, v, at); err != nil {
+		return err
+	}
+	rv.reward(ctx, v)
+	return nil
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (rv *rewardingVoucherService) reward(ctx context.Context, v *voucher.Voucher) {
+	if err := rv.referrals.Redeemed(ctx, v.UserID); err != nil {
+		logger.FromContext(ctx).Error("rewarding referral failed", logger.Fields{
+			"user_id": v.UserID,
+			"error":   err,
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package statsservice
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/stats"
+	"github.com/deepinbytes/go_voucher/repositories/statsrepo"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+)
+
+// MaxPoints bounds the buckets of a series
+const MaxPoints = 1000
+
+var (
+	// ErrBucket is returned for buckets other than hour and day
+	ErrBucket = errors.New("bucket must be hour or day")
+	// ErrRange is returned when from is not before to or the range holds
+	// more than MaxPoints buckets
+	ErrRange = errors.New("range must be non empty and hold at most 1000 buckets")
+)
+
+// StatsService reports how offers perform
+type StatsService interface {
+	// OfferStats sums up the vouchers of the offer
+	OfferStats(ctx context.Context, offerID uint) (*stats.Offer, error)
+	// Series counts the vouchers of the offer issued and redeemed per
+	// bucket in [from, to), empty buckets included. from is rounded down to
+	// the start of its bucket.
+	Series(ctx context.Context, offerID uint, bucket string, from, to time.Time) ([]stats.Point, error)
+	// Daily returns the rollup of the days in [from, to), of one offer or
+	// of all of them with offerID 0
+	Daily(ctx context.Context, offerID uint, from, to time.Time) ([]*stats.Daily, error)
+	// Refresh recomputes the rollup of the UTC days in [from, to)
+	Refresh(ctx context.Context, from, to time.Time) error
+}
+
+type statsService struct {
+	offers   offerservice.OfferService
+	vouchers voucherrepo.Repo
+	rollup   statsrepo.Repo
+	now      func() time.Time
+}
+
+// NewStatsService will instantiate Stats Service
+func NewStatsService(
+	offers offerservice.OfferService,
+	vouchers voucherrepo.Repo,
+	rollup statsrepo.Repo,
+) StatsService {
+
+	return &statsService{
+		offers:   offers,
+		vouchers: vouchers,
+		rollup:   rollup,
+		now:      time.Now,
+	}
+}
+
+func (ss *statsService) OfferStats(ctx context.Context, offerID uint) (*stats.Offer, error) {
+	if _, err := ss.offers.GetByID(ctx, offerID); err != nil {
+		return nil, err
+	}
+	return ss.vouchers.OfferStats(ctx, offerID, ss.now())
+}
+
+func (ss *statsService) Series(ctx context.Context, offerID uint, bucket string, from, to time.Time) ([]stats.Point, error) {
+	size := stats.BucketSize(bucket)
+	if size == 0 {
+		return nil, ErrBucket
+	}
+	start := time.Unix(from.Unix()/int64(size/time.Second)*int64(size/time.Second), 0).UTC()
+	if !from.Before(to) || to.Sub(start) > MaxPoints*size {
+		return nil, ErrRange
+	}
+	if _, err := ss.offers.GetByID(ctx, offerID); err != nil {
+		return nil, err
+	}
+	points, err := ss.vouchers.Series(ctx, offerID, size, start, to)
+	if err != nil {
+		return nil, err
+	}
+
+	series := []stats.Point{}
+	for at := start; at.Before(to); at = at.Add(size) {
+		p := stats.Point{Start: at}
+		if len(points) > 0 && points[0].Start.Equal(at) {
+			p, points = points[0], points[1:]
+		}
+		series = append(series, p)
+	}
+	return series, nil
+}
+
+func (ss *statsService) Daily(ctx context.Context, offerID uint, from, to time.Time) ([]*stats.Daily, error) {
+	if !from.Before(to) || to.Sub(from) > MaxPoints*24*time.Hour {
+		return nil, ErrRange
+	}
+	return ss.rollup.ListDaily(ctx, offerID, stats.Day(from), to)
+}
+
+func (ss *statsService) Refresh(ctx context.Context, from, to time.Time) error {
+	for day := stats.Day(from); day.Before(to); day = day.Add(24 * time.Hour) {
+		daily, err := ss.vouchers.DailyByOffer(ctx, day)
+		if err != nil {
+			return err
+		}
+		if err := ss.rollup.ReplaceDay(ctx, day, daily); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package statsservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/stats"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+	"github.com/deepinbytes/go_voucher/repositories/offerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/statsrepo"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	ctx = context.Background()
+	day = time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// setup seeds an offer with a voucher issued at 1am and redeemed at 3am
+func setup(t *testing.T) (*statsService, *offer.Offer) {
+	db := memdb.New()
+	offers := offerservice.NewOfferService(offerrepo.NewMemoryOfferRepo(db))
+	vouchers := voucherrepo.NewMemoryVoucherRepo(db)
+	svc := NewStatsService(offers, vouchers, statsrepo.NewMemoryStatsRepo(db)).(*statsService)
+	svc.now = func() time.Time { return day.Add(24 * time.Hour) }
+
+	o := &offer.Offer{Name: "Summer", DiscountPercentage: 10}
+	require.Nil(t, offers.Create(ctx, o))
+	v := &voucher.Voucher{Code: "SUMMER01", OfferID: o.ID, IsUsed: true,
+		UsedAt: day.Add(3 * time.Hour), ExpireTime: day.Add(48 * time.Hour)}
+	v.CreatedAt = day.Add(time.Hour)
+	require.Nil(t, vouchers.Create(ctx, v))
+	return svc, o
+}
+
+func TestOfferStats(t *testing.T) {
+	svc, o := setup(t)
+
+	st, err := svc.OfferStats(ctx, o.ID)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), st.Redeemed)
+	assert.Equal(t, float64(1), st.RedemptionRate)
+	assert.Equal(t, float64(2*3600), st.TimeToRedeem.P50)
+
+	_, err = svc.OfferStats(ctx, 42)
+	assert.True(t, gorm.IsRecordNotFoundError(err))
+
+	t.Run("Sums fixed discounts in the currency of the offer", func(t *testing.T) {
+		fixed := &offer.Offer{Name: "Fiver", DiscountAmount: 500, DiscountCurrency: "GBP"}
+		require.Nil(t, svc.offers.Create(ctx, fixed))
+		v := &voucher.Voucher{Code: "FIVER01", OfferID: fixed.ID, IsUsed: true,
+			UsedAt: day.Add(3 * time.Hour), ExpireTime: day.Add(48 * time.Hour)}
+		require.Nil(t, svc.vouchers.Create(ctx, v))
+
+		st, err := svc.OfferStats(ctx, fixed.ID)
+		require.Nil(t, err)
+		assert.Zero(t, st.DiscountGiven)
+		assert.Equal(t, money.Amounts{"GBP": 500}, st.DiscountAmounts)
+	})
+}
+
+func TestSeries(t *testing.T) {
+	svc, o := setup(t)
+
+	t.Run("Fills empty buckets", func(t *testing.T) {
+		series, err := svc.Series(ctx, o.ID, stats.BucketHour, day.Add(30*time.Minute), day.Add(4*time.Hour))
+
+		require.Nil(t, err)
+		assert.Equal(t, []stats.Point{
+			{Start: day},
+			{Start: day.Add(time.Hour), Issued: 1},
+			{Start: day.Add(2 * time.Hour)},
+			{Start: day.Add(3 * time.Hour), Redeemed: 1},
+		}, series)
+	})
+
+	t.Run("Rejects bad buckets and ranges", func(t *testing.T) {
+		_, err := svc.Series(ctx, o.ID, "week", day, day.Add(time.Hour))
+		assert.Equal(t, ErrBucket, err)
+
+		_, err = svc.Series(ctx, o.ID, stats.BucketDay, day, day)
+		assert.Equal(t, ErrRange, err)
+
+		_, err = svc.Series(ctx, o.ID, stats.BucketHour, day, day.Add((MaxPoints+1)*time.Hour))
+		assert.Equal(t, ErrRange, err)
+	})
+}
+
+func TestRefresh(t *testing.T) {
+	svc, o := setup(t)
+
+	require.Nil(t, svc.Refresh(ctx, day.Add(time.Hour), day.Add(48*time.Hour)))
+
+	daily, err := svc.Daily(ctx, o.ID, day, day.Add(48*time.Hour))
+	require.Nil(t, err)
+	assert.Equal(t, []*stats.Daily{
+		{Day: day, OfferID: o.ID, TenantID: tenant.DefaultID, Issued: 1, Redeemed: 1, DiscountGiven: 10},
+	}, daily)
+}
@@ -0,0 +1,262 @@
+package tenantservice
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/keys"
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/apikey"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories/apikeyrepo"
+	"github.com/deepinbytes/go_voucher/repositories/tenantrepo"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrUnknownTenant is returned for slugs of no tenant
+	ErrUnknownTenant = errors.New("tenant not found")
+	// ErrInvalidAPIKey is returned for API keys of no tenant
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrAPIKeyRequired is returned for tenants named without their API
+	// key, only the default tenant is used without one
+	ErrAPIKeyRequired = errors.New("API key required")
+	// ErrTenantMismatch is returned when the API key and the slug name two
+	// different tenants
+	ErrTenantMismatch = errors.New("API key belongs to another tenant")
+	// ErrSlug is returned for slugs that cannot be a subdomain
+	ErrSlug = errors.New("slug must be a subdomain: lowercase letters, digits and inner hyphens, at most 63")
+	// ErrSlugTaken is returned when another tenant has the slug
+	ErrSlugTaken = errors.New("slug is taken")
+	// ErrKeyExpired is returned when rotating a key that no longer
+	// authenticates
+	ErrKeyExpired = errors.New("API key expired")
+)
+
+const (
+	// firstKeyName names the key a tenant is created with
+	firstKeyName = "initial"
+	// adminKeyName names the key the default tenant is bootstrapped with
+	adminKeyName = "admin"
+)
+
+// KeyPolicy is the rotation schedule of API keys
+type KeyPolicy struct {
+	// TTL is how long new keys authenticate, 0 for ever
+	TTL time.Duration
+	// Grace is how long a rotated key keeps authenticating next to the
+	// new one, so clients can switch over
+	Grace time.Duration
+}
+
+// slugPattern is a DNS label
+var slugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// TenantService manages the tenants and tells which one a call is for
+type TenantService interface {
+	// Create registers a tenant with a new API key, set in t.APIKey
+	Create(ctx context.Context, t *tenant.Tenant) error
+	List(ctx context.Context) ([]*tenant.Tenant, error)
+	// Resolve returns the tenant of apiKey, with the key in KeyID. slug
+	// only has to match it. Without a key only the default tenant is
+	// returned, with KeyID 0.
+	Resolve(ctx context.Context, slug, apiKey string) (*tenant.Tenant, error)
+	// BootstrapAdminKey stores key as the API key of the default tenant
+	// unless it already has keys, so tenants can be administered from the
+	// first start on
+	BootstrapAdminKey(ctx context.Context, key string) error
+	// CreateKey issues an API key of the tenant of ctx, the key itself is
+	// only returned now
+	CreateKey(ctx context.Context, name string) (*apikey.Key, error)
+	// ListKeys returns the API keys of the tenant of ctx, expired ones too
+	ListKeys(ctx context.Context) ([]*apikey.Key, error)
+	// RotateKey issues a key replacing the key id, which keeps
+	// authenticating for the grace period of the policy
+	RotateKey(ctx context.Context, id uint) (*apikey.Key, error)
+	// RetireKey stops the key id from authenticating at once
+	RetireKey(ctx context.Context, id uint) (*apikey.Key, error)
+}
+
+type tenantService struct {
+	Repo    tenantrepo.Repo
+	APIKeys apikeyrepo.Repo
+	policy  KeyPolicy
+	now     func() time.Time
+}
+
+// NewTenantService will instantiate Tenant Service
+func NewTenantService(repo tenantrepo.Repo, apiKeys apikeyrepo.Repo, policy KeyPolicy) TenantService {
+	return &tenantService{
+		Repo:    repo,
+		APIKeys: apiKeys,
+		policy:  policy,
+		now:     time.Now,
+	}
+}
+
+func (ts *tenantService) Create(ctx context.Context, t *tenant.Tenant) error {
+	if t.Name == "" {
+		return errors.New("name is required")
+	}
+	if !slugPattern.MatchString(t.Slug) || t.Slug == tenant.DefaultSlug {
+		return ErrSlug
+	}
+	if _, err := ts.Repo.GetBySlug(ctx, t.Slug); err == nil {
+		return ErrSlugTaken
+	} else if !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+	if err := ts.Repo.Create(ctx, t); err != nil {
+		return err
+	}
+	k, err := ts.issue(ctx, t.ID, firstKeyName)
+	if err != nil {
+		return err
+	}
+	t.APIKey = k.Plain
+	return nil
+}
+
+func (ts *tenantService) List(ctx context.Context) ([]*tenant.Tenant, error) {
+	return ts.Repo.ListAll(ctx)
+}
+
+// Resolve does not look the default tenant up, it exists from the first
+// migration on
+func (ts *tenantService) Resolve(ctx context.Context, slug, apiKey string) (*tenant.Tenant, error) {
+	switch {
+	case apiKey != "":
+		k, err := ts.APIKeys.GetByHash(ctx, keys.Hash(apiKey))
+		if gorm.IsRecordNotFoundError(err) || err == nil && !k.Active(ts.now()) {
+			return nil, ErrInvalidAPIKey
+		}
+		if err != nil {
+			return nil, err
+		}
+		t := defaultTenant()
+		if k.TenantID != tenant.DefaultID {
+			if t, err = ts.Repo.GetByID(ctx, k.TenantID); err != nil {
+				return nil, err
+			}
+		}
+		if slug != "" && slug != t.Slug {
+			return nil, ErrTenantMismatch
+		}
+		t.KeyID = k.ID
+		return t, nil
+	case slug != "" && slug != tenant.DefaultSlug:
+		if _, err := ts.Repo.GetBySlug(ctx, slug); gorm.IsRecordNotFoundError(err) {
+			return nil, ErrUnknownTenant
+		} else if err != nil {
+			return nil, err
+		}
+		return nil, ErrAPIKeyRequired
+	}
+	return defaultTenant(), nil
+}
+
+func (ts *tenantService) BootstrapAdminKey(ctx context.Context, key string) error {
+	if key == "" {
+		return nil
+	}
+	existing, err := ts.APIKeys.List(ctx, tenant.DefaultID)
+	if err != nil || len(existing) > 0 {
+		return err
+	}
+	k := &apikey.Key{
+		TenantID: tenant.DefaultID,
+		Name:     adminKeyName,
+		Prefix:   keys.Prefix(key),
+		Hash:     keys.Hash(key),
+	}
+	if err := ts.APIKeys.Create(ctx, k); err != nil {
+		return err
+	}
+	logger.FromContext(ctx).Info("default tenant API key stored", logger.Fields{"key_id": k.ID, "prefix": k.Prefix})
+	return nil
+}
+
+func (ts *tenantService) CreateKey(ctx context.Context, name string) (*apikey.Key, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	return ts.issue(ctx, tenant.FromContext(ctx), name)
+}
+
+func (ts *tenantService) ListKeys(ctx context.Context) ([]*apikey.Key, error) {
+	return ts.APIKeys.List(ctx, tenant.FromContext(ctx))
+}
+
+func (ts *tenantService) RotateKey(ctx context.Context, id uint) (*apikey.Key, error) {
+	old, err := ts.APIKeys.Get(ctx, tenant.FromContext(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	now := ts.now()
+	if !old.Active(now) {
+		return nil, ErrKeyExpired
+	}
+	k, err := ts.issue(ctx, old.TenantID, old.Name)
+	if err != nil {
+		return nil, err
+	}
+	if graceEnd := now.Add(ts.policy.Grace); old.ExpiresAt == nil || graceEnd.Before(*old.ExpiresAt) {
+		if err := ts.APIKeys.Expire(ctx, old, graceEnd); err != nil {
+			return nil, err
+		}
+	}
+	logger.FromContext(ctx).Info("API key rotated", logger.Fields{
+		"key_id": old.ID, "prefix": old.Prefix, "new_key_id": k.ID, "expires_at": old.ExpiresAt,
+	})
+	return k, nil
+}
+
+func (ts *tenantService) RetireKey(ctx context.Context, id uint) (*apikey.Key, error) {
+	k, err := ts.APIKeys.Get(ctx, tenant.FromContext(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	if now := ts.now(); k.Active(now) {
+		if err := ts.APIKeys.Expire(ctx, k, now); err != nil {
+			return nil, err
+		}
+		logger.FromContext(ctx).Info("API key retired", logger.Fields{"key_id": k.ID, "prefix": k.Prefix})
+	}
+	return k, nil
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func defaultTenant() *tenant.Tenant {
+	return &tenant.Tenant{Model: gorm.Model{ID: tenant.DefaultID}, Name: "Default", Slug: tenant.DefaultSlug}
+}
+
+// issue creates a key of the tenant, only its hash is stored
+func (ts *tenantService) issue(ctx context.Context, tenantID uint, name string) (*apikey.Key, error) {
+	plain, err := keys.NewAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	k := &apikey.Key{
+		TenantID: tenantID,
+		Name:     name,
+		Prefix:   keys.Prefix(plain),
+		Hash:     keys.Hash(plain),
+		Plain:    plain,
+	}
+	if ts.policy.TTL > 0 {
+		expires := ts.now().Add(ts.policy.TTL)
+		k.ExpiresAt = &expires
+	}
+	if err := ts.APIKeys.Create(ctx, k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
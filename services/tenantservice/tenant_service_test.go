@@ -0,0 +1,176 @@
+package tenantservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/keys"
+	"github.com/deepinbytes/go_voucher/domain/apikey"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/repositories/apikeyrepo"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+	"github.com/deepinbytes/go_voucher/repositories/tenantrepo"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+var now = time.Date(2021, 3, 1, 8, 0, 0, 0, time.UTC)
+
+func setup(t *testing.T) (*tenantService, *tenant.Tenant) {
+	db := memdb.New()
+	svc := NewTenantService(tenantrepo.NewMemoryTenantRepo(db), apikeyrepo.NewMemoryAPIKeyRepo(db),
+		KeyPolicy{Grace: time.Hour}).(*tenantService)
+	svc.now = func() time.Time { return now }
+	acme := &tenant.Tenant{Name: "Acme", Slug: "acme"}
+	require.Nil(t, svc.Create(ctx, acme))
+	return svc, acme
+}
+
+func TestCreate(t *testing.T) {
+	svc, acme := setup(t)
+
+	assert.Len(t, acme.APIKey, 48)
+	ks, err := svc.APIKeys.List(ctx, acme.ID)
+	require.Nil(t, err)
+	require.Len(t, ks, 1)
+	assert.Equal(t, "initial", ks[0].Name)
+	assert.Equal(t, keys.Hash(acme.APIKey), ks[0].Hash, "only the hash is stored")
+	assert.Empty(t, ks[0].Plain)
+	for _, slug := range []string{"", "Acme", "-acme", "acme-", "a.b", tenant.DefaultSlug} {
+		assert.Equal(t, ErrSlug, svc.Create(ctx, &tenant.Tenant{Name: "Other", Slug: slug}), slug)
+	}
+	assert.EqualError(t, svc.Create(ctx, &tenant.Tenant{Slug: "globex"}), "name is required")
+	assert.Equal(t, ErrSlugTaken, svc.Create(ctx, &tenant.Tenant{Name: "Acme 2", Slug: "acme"}))
+
+	tenants, err := svc.List(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, []string{tenant.DefaultSlug, "acme"}, []string{tenants[0].Slug, tenants[1].Slug})
+}
+
+func TestResolve(t *testing.T) {
+	svc, acme := setup(t)
+	ks, err := svc.APIKeys.List(ctx, acme.ID)
+	require.Nil(t, err)
+
+	for _, tt := range []struct {
+		name, slug, key string
+		id, keyID       uint
+		err             error
+	}{
+		{"Default without either", "", "", tenant.DefaultID, 0, nil},
+		{"Default by slug", tenant.DefaultSlug, "", tenant.DefaultID, 0, nil},
+		{"Slug without key", "acme", "", 0, 0, ErrAPIKeyRequired},
+		{"By key", "", acme.APIKey, acme.ID, ks[0].ID, nil},
+		{"By key and slug", "acme", acme.APIKey, acme.ID, ks[0].ID, nil},
+		{"Unknown slug", "globex", "", 0, 0, ErrUnknownTenant},
+		{"Unknown key", "acme", "nope", 0, 0, ErrInvalidAPIKey},
+		{"Key of another tenant", tenant.DefaultSlug, acme.APIKey, 0, 0, ErrTenantMismatch},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.Resolve(ctx, tt.slug, tt.key)
+
+			assert.Equal(t, tt.err, err)
+			if tt.err == nil {
+				assert.Equal(t, tt.id, got.ID)
+				assert.Equal(t, tt.keyID, got.KeyID)
+			}
+		})
+	}
+}
+
+func TestBootstrapAdminKey(t *testing.T) {
+	svc, _ := setup(t)
+	const admin = "0123456789abcdef0123456789abcdef"
+
+	require.Nil(t, svc.BootstrapAdminKey(ctx, ""))
+	_, err := svc.Resolve(ctx, "", admin)
+	assert.Equal(t, ErrInvalidAPIKey, err)
+
+	require.Nil(t, svc.BootstrapAdminKey(ctx, admin))
+	got, err := svc.Resolve(ctx, "", admin)
+	require.Nil(t, err)
+	assert.Equal(t, tenant.DefaultID, got.ID)
+	assert.NotZero(t, got.KeyID)
+
+	// once the default tenant has a key, the configured one is not stored
+	// again, e.g. after it was rotated
+	require.Nil(t, svc.BootstrapAdminKey(ctx, "fedcba9876543210fedcba9876543210"))
+	_, err = svc.Resolve(ctx, "", "fedcba9876543210fedcba9876543210")
+	assert.Equal(t, ErrInvalidAPIKey, err)
+}
+
+func TestKeys(t *testing.T) {
+	svc, acme := setup(t)
+	acmeCtx := tenant.NewContext(ctx, acme.ID)
+	resolves := func(key string) bool {
+		got, err := svc.Resolve(ctx, "", key)
+		return err == nil && got.ID == acme.ID
+	}
+
+	ci, err := svc.CreateKey(acmeCtx, " ci ")
+	require.Nil(t, err)
+	assert.Equal(t, "ci", ci.Name)
+	assert.Equal(t, ci.Plain[:8], ci.Prefix)
+	assert.Nil(t, ci.ExpiresAt)
+	assert.True(t, resolves(ci.Plain))
+	_, err = svc.CreateKey(acmeCtx, "")
+	assert.EqualError(t, err, "name is required")
+
+	var next *apikey.Key
+	t.Run("Rotates with a grace period", func(t *testing.T) {
+		next, err = svc.RotateKey(acmeCtx, ci.ID)
+		require.Nil(t, err)
+		assert.Equal(t, "ci", next.Name)
+		assert.NotEqual(t, ci.Plain, next.Plain)
+		assert.True(t, resolves(ci.Plain), "the old key works during the grace period")
+		assert.True(t, resolves(next.Plain))
+
+		old, err := svc.APIKeys.Get(ctx, acme.ID, ci.ID)
+		require.Nil(t, err)
+		assert.True(t, old.ExpiresAt.Equal(now.Add(time.Hour)))
+
+		defer func() { svc.now = func() time.Time { return now } }()
+		svc.now = func() time.Time { return now.Add(time.Hour) }
+		assert.False(t, resolves(ci.Plain))
+		assert.True(t, resolves(next.Plain))
+		_, err = svc.RotateKey(acmeCtx, ci.ID)
+		assert.Equal(t, ErrKeyExpired, err)
+	})
+
+	t.Run("Retires at once", func(t *testing.T) {
+		require.True(t, resolves(next.Plain))
+		k, err := svc.RetireKey(acmeCtx, next.ID)
+		require.Nil(t, err)
+		assert.True(t, k.ExpiresAt.Equal(now))
+		assert.False(t, resolves(next.Plain))
+
+		ks, err := svc.ListKeys(acmeCtx)
+		require.Nil(t, err)
+		assert.Len(t, ks, 3)
+	})
+
+	t.Run("Keeps keys to their tenant", func(t *testing.T) {
+		_, err := svc.RotateKey(ctx, ci.ID)
+		assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+		_, err = svc.RetireKey(ctx, ci.ID)
+		assert.True(t, gorm.IsRecordNotFoundError(err), "got %v", err)
+		ks, err := svc.ListKeys(ctx)
+		require.Nil(t, err)
+		assert.Empty(t, ks)
+	})
+
+	t.Run("Expires keys after their TTL", func(t *testing.T) {
+		svc.policy.TTL = 90 * 24 * time.Hour
+		defer func() { svc.policy.TTL = 0 }()
+
+		k, err := svc.CreateKey(acmeCtx, "pos")
+		require.Nil(t, err)
+		assert.True(t, k.ExpiresAt.Equal(now.Add(90*24*time.Hour)))
+	})
+}
@@ -0,0 +1,308 @@
+package tokenservice
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/keys"
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/common/verify"
+	"github.com/deepinbytes/go_voucher/domain/offline"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/offlinerepo"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/jinzhu/gorm"
+)
+
+// clockSkew is how far ahead of the server clock a terminal may be before
+// its redemptions are rejected
+const clockSkew = 5 * time.Minute
+
+// ErrNoSigner is returned when a token is asked for without a signing key
+var ErrNoSigner = errors.New("signing is not configured")
+
+// Verification is the outcome of checking a token
+type Verification struct {
+	Valid bool `json:"valid"`
+	// Reason tells why a token is not valid, one of the offline reasons
+	Reason string `json:"reason,omitempty"`
+	// Claims are set for genuine tokens, expired ones too
+	Claims *verify.Claims `json:"claims,omitempty"`
+}
+
+// Key is what terminals need to check tokens offline
+type Key struct {
+	// ID is the kid of the claims of the tokens signed with the key
+	ID        string `json:"id"`
+	Algorithm string `json:"algorithm"`
+	// PublicKey is the base64 Ed25519 public key, HMAC keys are secret
+	// and shared with terminals out of band
+	PublicKey string `json:"public_key,omitempty"`
+	// Status is pending, active, verifying or retired
+	Status     string     `json:"status"`
+	ActivateAt *time.Time `json:"activate_at,omitempty"`
+	RetireAt   *time.Time `json:"retire_at,omitempty"`
+}
+
+// TokenService issues signed voucher tokens that terminals check without
+// the database, and reconciles the redemptions they accepted offline once
+// they are back online
+type TokenService interface {
+	// Sign returns the token of v
+	Sign(ctx context.Context, v *voucher.Voucher) (string, error)
+	// Issue returns the token of the voucher with code, ErrUsed or
+	// ErrExpired when it can no longer be redeemed
+	Issue(ctx context.Context, code string) (string, error)
+	// Verify checks token as a terminal would, without the database
+	Verify(ctx context.Context, token string) (*Verification, error)
+	// Key returns the key signing the tokens
+	Key() (*Key, error)
+	// Keys returns every key, terminals trust the ones not retired
+	Keys() ([]*Key, error)
+	// Reconcile redeems the uploads of terminal at the time it accepted
+	// them and records the outcome of each, in order. Uploading a
+	// reference again returns its first outcome.
+	Reconcile(ctx context.Context, terminal string, uploads []offline.Upload) ([]*offline.Redemption, error)
+	// List pages the offline redemptions of status, of all when it is
+	// empty
+	List(ctx context.Context, status string, after uint, limit int) ([]*offline.Redemption, error)
+}
+
+type tokenService struct {
+	vouchers voucherservice.VoucherService
+	repo     offlinerepo.Repo
+	ring     *keys.Ring
+	now      func() time.Time
+}
+
+// NewTokenService will instantiate Token Service, ring may be nil when
+// tokens are not used
+func NewTokenService(
+	vouchers voucherservice.VoucherService,
+	repo offlinerepo.Repo,
+	ring *keys.Ring,
+) TokenService {
+
+	return &tokenService{
+		vouchers: vouchers,
+		repo:     repo,
+		ring:     ring,
+		now:      time.Now,
+	}
+}
+
+func (ts *tokenService) Sign(ctx context.Context, v *voucher.Voucher) (string, error) {
+	if ts.ring == nil {
+		return "", ErrNoSigner
+	}
+	k, err := ts.ring.Active(ts.now())
+	if err != nil {
+		return "", err
+	}
+	return verify.Issue(k.Signer, &verify.Claims{
+		Tenant:  tenant.FromContext(ctx),
+		Code:    v.Code,
+		Offer:   v.OfferID,
+		User:    v.UserID,
+		Expires: v.ExpireTime.Unix(),
+		KeyID:   k.ID,
+	}), nil
+}
+
+func (ts *tokenService) Issue(ctx context.Context, code string) (string, error) {
+	if ts.ring == nil {
+		return "", ErrNoSigner
+	}
+	v, err := ts.vouchers.UseCode(ctx, code)
+	if err != nil {
+		return "", err
+	}
+	if v.IsUsed {
+		return "", voucherservice.ErrUsed
+	}
+	if !ts.now().Before(v.ExpireTime) {
+		return "", voucherservice.ErrExpired
+	}
+	return ts.Sign(ctx, v)
+}
+
+func (ts *tokenService) Verify(ctx context.Context, token string) (*Verification, error) {
+	if ts.ring == nil {
+		return nil, ErrNoSigner
+	}
+	claims, reason := ts.check(ctx, token, ts.now())
+	return &Verification{Valid: reason == "", Reason: reason, Claims: claims}, nil
+}
+
+func (ts *tokenService) Key() (*Key, error) {
+	if ts.ring == nil {
+		return nil, ErrNoSigner
+	}
+	now := ts.now()
+	k, err := ts.ring.Active(now)
+	if err != nil {
+		return nil, err
+	}
+	return ts.key(k, now), nil
+}
+
+func (ts *tokenService) Keys() ([]*Key, error) {
+	if ts.ring == nil {
+		return nil, ErrNoSigner
+	}
+	now := ts.now()
+	var out []*Key
+	for _, k := range ts.ring.Keys() {
+		out = append(out, ts.key(k, now))
+	}
+	return out, nil
+}
+
+func (ts *tokenService) Reconcile(ctx context.Context, terminal string, uploads []offline.Upload) ([]*offline.Redemption, error) {
+	if ts.ring == nil {
+		return nil, ErrNoSigner
+	}
+	rs := make([]*offline.Redemption, 0, len(uploads))
+	for _, u := range uploads {
+		r, err := ts.reconcile(ctx, terminal, u)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+func (ts *tokenService) List(ctx context.Context, status string, after uint, limit int) ([]*offline.Redemption, error) {
+	return ts.repo.List(ctx, status, after, limit)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// key describes k at now
+func (ts *tokenService) key(k *keys.Key, now time.Time) *Key {
+	key := &Key{ID: k.ID, Algorithm: k.Signer.Algorithm(), Status: ts.ring.Status(k, now)}
+	if pub := k.Signer.PublicKey(); pub != nil {
+		key.PublicKey = base64.StdEncoding.EncodeToString(pub)
+	}
+	if !k.ActivateAt.IsZero() {
+		at := k.ActivateAt
+		key.ActivateAt = &at
+	}
+	if !k.RetireAt.IsZero() {
+		at := k.RetireAt
+		key.RetireAt = &at
+	}
+	return key
+}
+
+// check returns the claims of token and why it is not valid at now, an
+// empty reason when it is
+func (ts *tokenService) check(ctx context.Context, token string, now time.Time) (*verify.Claims, string) {
+	claims, err := verify.Check(ts.ring, token, now)
+	switch {
+	case err == verify.ErrToken:
+		return nil, offline.ReasonInvalidToken
+	case claims.Tenant != tenant.FromContext(ctx):
+		// the claims of other tenants are none of the caller's business
+		return nil, offline.ReasonOtherTenant
+	case err == verify.ErrExpired:
+		return claims, offline.ReasonExpired
+	}
+	return claims, ""
+}
+
+func (ts *tokenService) reconcile(ctx context.Context, terminal string, u offline.Upload) (*offline.Redemption, error) {
+	r, claimed, err := ts.claim(ctx, terminal, u)
+	if err != nil || r.Status != offline.StatusPending {
+		return r, err
+	}
+	if err := ts.redeem(ctx, r, u.Token, !claimed); err != nil {
+		return nil, err
+	}
+	if err := ts.repo.Update(ctx, r); err != nil {
+		return nil, err
+	}
+	logger.FromContext(ctx).Info("offline redemption reconciled", logger.Fields{
+		"terminal":   terminal,
+		"reference":  r.Reference,
+		"voucher_id": r.VoucherID,
+		"status":     r.Status,
+		"reason":     r.Reason,
+	})
+	return r, nil
+}
+
+// claim returns the redemption of the reference of the terminal, created
+// pending, and claimed true, when it was not uploaded before. It is
+// claimed before its voucher is redeemed, so an upload failing in between
+// leaves a pending redemption for the next upload to finish rather than a
+// used voucher without one.
+func (ts *tokenService) claim(ctx context.Context, terminal string, u offline.Upload) (*offline.Redemption, bool, error) {
+	r, err := ts.repo.Get(ctx, terminal, u.Reference)
+	if err == nil || !gorm.IsRecordNotFoundError(err) {
+		return r, false, err
+	}
+
+	r = &offline.Redemption{Terminal: terminal, Reference: u.Reference, RedeemedAt: u.RedeemedAt.UTC(),
+		Status: offline.StatusPending}
+	if err := ts.repo.Create(ctx, r); err != nil {
+		// the same reference uploaded concurrently
+		if existing, gerr := ts.repo.Get(ctx, terminal, u.Reference); gerr == nil {
+			return existing, false, nil
+		}
+		return nil, false, err
+	}
+	return r, true, nil
+}
+
+// redeem sets the status of r, redeeming its voucher when the token was
+// valid at the redemption time. A resumed redemption may have redeemed the
+// voucher already, a voucher used at its very time counts as its own.
+func (ts *tokenService) redeem(ctx context.Context, r *offline.Redemption, token string, resumed bool) error {
+	claims, reason := ts.check(ctx, token, r.RedeemedAt)
+	if claims != nil {
+		r.Code = claims.Code
+	}
+	if r.RedeemedAt.After(ts.now().Add(clockSkew)) {
+		reason = offline.ReasonFuture
+	}
+	if reason != "" {
+		r.Status, r.Reason = offline.StatusRejected, reason
+		return nil
+	}
+
+	v, err := ts.vouchers.UseCode(ctx, claims.Code)
+	if gorm.IsRecordNotFoundError(err) || err == nil && v.OfferID != claims.Offer {
+		r.Status, r.Reason = offline.StatusRejected, offline.ReasonRevoked
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	r.VoucherID = v.ID
+
+	switch err := ts.vouchers.RedeemAt(ctx, v, r.RedeemedAt); err {
+	case nil:
+		r.Status = offline.StatusAccepted
+	case voucherservice.ErrUsed:
+		used, err := ts.vouchers.UseCode(ctx, claims.Code)
+		if err == nil && resumed && used.UsedAt.Equal(r.RedeemedAt) {
+			r.Status = offline.StatusAccepted
+			return nil
+		}
+		r.Status = offline.StatusDoubleUse
+		if err == nil && used.IsUsed {
+			r.FirstUsedAt = &used.UsedAt
+		}
+	default:
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+package tokenservice
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/keys"
+	"github.com/deepinbytes/go_voucher/common/signing"
+	"github.com/deepinbytes/go_voucher/common/verify"
+	"github.com/deepinbytes/go_voucher/domain/offline"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+	"github.com/deepinbytes/go_voucher/repositories/offlinerepo"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+type fixture struct {
+	svc      *tokenService
+	signer   signing.Signer
+	ring     *keys.Ring
+	vouchers voucherrepo.Repo
+	now      time.Time
+}
+
+func setup(t *testing.T) *fixture {
+	signer, err := signing.NewEd25519([]byte(strings.Repeat("s", ed25519.SeedSize)))
+	require.Nil(t, err)
+	ring, err := keys.NewRing(&keys.Key{ID: keys.DefaultID, Signer: signer})
+	require.Nil(t, err)
+
+	db := memdb.New()
+	f := &fixture{
+		signer:   signer,
+		ring:     ring,
+		vouchers: voucherrepo.NewMemoryVoucherRepo(db),
+		now:      time.Date(2021, 3, 1, 8, 0, 0, 0, time.UTC),
+	}
+	f.svc = NewTokenService(voucherservice.NewVoucherService(f.vouchers),
+		offlinerepo.NewMemoryOfflineRepo(db), ring).(*tokenService)
+	f.svc.now = func() time.Time { return f.now }
+	return f
+}
+
+// failingRepo fails the next fails updates of its offline redemptions
+type failingRepo struct {
+	offlinerepo.Repo
+	fails int
+}
+
+func (r *failingRepo) Update(ctx context.Context, o *offline.Redemption) error {
+	if r.fails > 0 {
+		r.fails--
+		return errors.New("connection reset")
+	}
+	return r.Repo.Update(ctx, o)
+}
+
+func (f *fixture) voucher(t *testing.T, code string, offerID uint, expires time.Time) *voucher.Voucher {
+	v := &voucher.Voucher{Code: code, OfferID: offerID, UserID: 3, ExpireTime: expires}
+	require.Nil(t, f.vouchers.Create(ctx, v))
+	return v
+}
+
+func TestIssue(t *testing.T) {
+	f := setup(t)
+	f.voucher(t, "SPRING20", 7, f.now.Add(time.Hour))
+
+	t.Run("Issues a token of the voucher", func(t *testing.T) {
+		token, err := f.svc.Issue(ctx, "SPRING20")
+		require.Nil(t, err)
+
+		claims, err := verify.Check(f.ring, token, f.now)
+		assert.Nil(t, err)
+		assert.Equal(t, &verify.Claims{Tenant: tenant.DefaultID, Code: "SPRING20", Offer: 7, User: 3,
+			Expires: f.now.Add(time.Hour).Unix(), KeyID: keys.DefaultID}, claims)
+	})
+
+	t.Run("Refuses vouchers that can no longer be redeemed", func(t *testing.T) {
+		used := f.voucher(t, "USED", 7, f.now.Add(time.Hour))
+		require.Nil(t, f.svc.vouchers.RedeemAt(ctx, used, f.now))
+		f.voucher(t, "EXPIRED", 7, f.now)
+
+		_, err := f.svc.Issue(ctx, "USED")
+		assert.Equal(t, voucherservice.ErrUsed, err)
+		_, err = f.svc.Issue(ctx, "EXPIRED")
+		assert.Equal(t, voucherservice.ErrExpired, err)
+	})
+
+	t.Run("Requires a signer", func(t *testing.T) {
+		f.svc.ring = nil
+		defer func() { f.svc.ring = f.ring }()
+
+		_, err := f.svc.Issue(ctx, "SPRING20")
+		assert.Equal(t, ErrNoSigner, err)
+		_, err = f.svc.Key()
+		assert.Equal(t, ErrNoSigner, err)
+		_, err = f.svc.Keys()
+		assert.Equal(t, ErrNoSigner, err)
+	})
+}
+
+func TestVerify(t *testing.T) {
+	f := setup(t)
+	v := f.voucher(t, "SPRING20", 7, f.now.Add(time.Hour))
+	token, err := f.svc.Sign(ctx, v)
+	require.Nil(t, err)
+
+	cases := []struct {
+		name   string
+		ctx    context.Context
+		token  string
+		at     time.Time
+		reason string
+		claims bool
+	}{
+		{"valid", ctx, token, f.now, "", true},
+		{"expired", ctx, token, f.now.Add(time.Hour), offline.ReasonExpired, true},
+		{"other tenant", tenant.NewContext(ctx, 2), token, f.now, offline.ReasonOtherTenant, false},
+		{"forged", ctx, token[:len(token)-2], f.now, offline.ReasonInvalidToken, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f.now = tc.at
+			res, err := f.svc.Verify(tc.ctx, tc.token)
+
+			require.Nil(t, err)
+			assert.Equal(t, tc.reason == "", res.Valid)
+			assert.Equal(t, tc.reason, res.Reason)
+			assert.Equal(t, tc.claims, res.Claims != nil)
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	f := setup(t)
+
+	key, err := f.svc.Key()
+	require.Nil(t, err)
+	assert.Equal(t, &Key{ID: keys.DefaultID, Algorithm: "Ed25519", Status: keys.StatusActive,
+		PublicKey: base64.StdEncoding.EncodeToString(f.signer.PublicKey())}, key)
+
+	t.Run("Rotates keys on schedule", func(t *testing.T) {
+		v := f.voucher(t, "SPRING20", 7, f.now.Add(72*time.Hour))
+		before, err := f.svc.Sign(ctx, v)
+		require.Nil(t, err)
+
+		hmac, err := signing.NewHMAC([]byte(strings.Repeat("k", signing.MinKeySize)))
+		require.Nil(t, err)
+		activate, retire := f.now.Add(24*time.Hour), f.now.Add(48*time.Hour)
+		f.svc.ring, err = keys.NewRing(
+			&keys.Key{ID: keys.DefaultID, Signer: f.signer, RetireAt: retire},
+			&keys.Key{ID: "2021-03", Signer: hmac, ActivateAt: activate})
+		require.Nil(t, err)
+		defer func() { f.svc.ring, f.now = f.ring, time.Date(2021, 3, 1, 8, 0, 0, 0, time.UTC) }()
+
+		all, err := f.svc.Keys()
+		require.Nil(t, err)
+		require.Len(t, all, 2)
+		assert.Equal(t, keys.StatusActive, all[0].Status)
+		assert.Equal(t, &retire, all[0].RetireAt)
+		assert.Equal(t, &Key{ID: "2021-03", Algorithm: "HS256", Status: keys.StatusPending, ActivateAt: &activate}, all[1])
+
+		f.now = activate
+		key, err := f.svc.Key()
+		require.Nil(t, err)
+		assert.Equal(t, "2021-03", key.ID)
+		after, err := f.svc.Sign(ctx, v)
+		require.Nil(t, err)
+
+		for _, token := range []string{before, after} {
+			res, err := f.svc.Verify(ctx, token)
+			require.Nil(t, err)
+			assert.True(t, res.Valid, token)
+		}
+
+		f.now = retire
+		res, err := f.svc.Verify(ctx, before)
+		require.Nil(t, err)
+		assert.Equal(t, offline.ReasonInvalidToken, res.Reason)
+		res, err = f.svc.Verify(ctx, after)
+		require.Nil(t, err)
+		assert.True(t, res.Valid)
+	})
+}
+
+func TestReconcile(t *testing.T) {
+	f := setup(t)
+	sign := func(v *voucher.Voucher) string {
+		token, err := f.svc.Sign(ctx, v)
+		require.Nil(t, err)
+		return token
+	}
+	spring := f.voucher(t, "SPRING20", 7, f.now.Add(time.Hour))
+	expired := f.voucher(t, "EXPIRED", 7, f.now.Add(-time.Minute))
+	revoked := f.voucher(t, "REVOKED", 8, f.now.Add(time.Hour))
+	springToken, revokedToken := sign(spring), sign(revoked)
+	_, err := f.vouchers.RevokeUnusedByOffer(ctx, 8, f.now)
+	require.Nil(t, err)
+
+	at := f.now.Add(-time.Hour)
+	expiredToken := sign(expired)
+	rs, err := f.svc.Reconcile(ctx, "till-1", []offline.Upload{
+		{Reference: "r1", Token: springToken, RedeemedAt: at},
+		// expired since, it was valid when the terminal redeemed it
+		{Reference: "r2", Token: expiredToken, RedeemedAt: at},
+		{Reference: "r3", Token: expiredToken, RedeemedAt: f.now},
+		{Reference: "r4", Token: revokedToken, RedeemedAt: at},
+		{Reference: "r5", Token: "SPRING20", RedeemedAt: at},
+		{Reference: "r6", Token: springToken, RedeemedAt: f.now.Add(time.Hour)},
+	})
+	require.Nil(t, err)
+	require.Len(t, rs, 6)
+
+	t.Run("Redeems the voucher at the time of the terminal", func(t *testing.T) {
+		for _, r := range rs[:2] {
+			assert.Equal(t, offline.StatusAccepted, r.Status, r.Reference)
+		}
+		assert.Equal(t, spring.ID, rs[0].VoucherID)
+		assert.Equal(t, "SPRING20", rs[0].Code)
+
+		v, err := f.vouchers.GetByID(ctx, spring.ID)
+		require.Nil(t, err)
+		assert.True(t, v.IsUsed)
+		assert.True(t, at.Equal(v.UsedAt))
+	})
+
+	t.Run("Rejects tokens that were not valid", func(t *testing.T) {
+		reasons := []string{offline.ReasonExpired, offline.ReasonRevoked, offline.ReasonInvalidToken, offline.ReasonFuture}
+		for i, reason := range reasons {
+			r := rs[i+2]
+			assert.Equal(t, offline.StatusRejected, r.Status, r.Reference)
+			assert.Equal(t, reason, r.Reason, r.Reference)
+		}
+	})
+
+	t.Run("Detects double use", func(t *testing.T) {
+		rs, err := f.svc.Reconcile(ctx, "till-2", []offline.Upload{
+			{Reference: "r1", Token: springToken, RedeemedAt: at.Add(time.Minute)},
+		})
+		require.Nil(t, err)
+
+		assert.Equal(t, offline.StatusDoubleUse, rs[0].Status)
+		if assert.NotNil(t, rs[0].FirstUsedAt) {
+			assert.True(t, at.Equal(*rs[0].FirstUsedAt))
+		}
+		doubles, err := f.svc.List(ctx, offline.StatusDoubleUse, 0, 10)
+		require.Nil(t, err)
+		assert.Len(t, doubles, 1)
+	})
+
+	t.Run("Returns the first outcome of a reference", func(t *testing.T) {
+		again, err := f.svc.Reconcile(ctx, "till-1", []offline.Upload{
+			{Reference: "r1", Token: springToken, RedeemedAt: at},
+		})
+		require.Nil(t, err)
+
+		assert.Equal(t, rs[0].ID, again[0].ID)
+		assert.Equal(t, offline.StatusAccepted, again[0].Status)
+	})
+
+	t.Run("Finishes a redemption that failed half way", func(t *testing.T) {
+		autumn := f.voucher(t, "AUTUMN10", 7, f.now.Add(time.Hour))
+		repo := f.svc.repo
+		f.svc.repo = &failingRepo{Repo: repo, fails: 1}
+		defer func() { f.svc.repo = repo }()
+		upload := []offline.Upload{{Reference: "r7", Token: sign(autumn), RedeemedAt: at}}
+
+		_, err := f.svc.Reconcile(ctx, "till-1", upload)
+		require.NotNil(t, err)
+		pending, err := f.svc.List(ctx, offline.StatusPending, 0, 10)
+		require.Nil(t, err)
+		assert.Len(t, pending, 1, "the voucher is used, its redemption is kept")
+
+		again, err := f.svc.Reconcile(ctx, "till-1", upload)
+		require.Nil(t, err)
+		assert.Equal(t, offline.StatusAccepted, again[0].Status, "not a double use of its own")
+		assert.Equal(t, autumn.ID, again[0].VoucherID)
+		pending, err = f.svc.List(ctx, offline.StatusPending, 0, 10)
+		require.Nil(t, err)
+		assert.Empty(t, pending)
+	})
+}
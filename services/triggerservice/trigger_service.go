@@ -0,0 +1,227 @@
+package triggerservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/domain/trigger"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/repositories/triggerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/userrepo"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrEvent is returned for triggers of an unknown event
+	ErrEvent = fmt.Errorf("event must be one of %s", strings.Join(trigger.Events, ", "))
+	// ErrValidity is returned for triggers issuing vouchers valid no day
+	ErrValidity = errors.New("validity_days must be positive")
+)
+
+// birthdayPage is the number of users whose birthday is worked out at once
+const birthdayPage = 100
+
+// TriggerService manages the triggers issuing vouchers on events and fires
+// them. A trigger issues at most one voucher per user and occurrence, so
+// firing it again is harmless.
+type TriggerService interface {
+	Create(ctx context.Context, t *trigger.Trigger) error
+	GetByID(ctx context.Context, id uint) (*trigger.Trigger, error)
+	List(ctx context.Context) ([]*trigger.Trigger, error)
+	Update(ctx context.Context, t *trigger.Trigger) error
+	Delete(ctx context.Context, id uint) error
+	// UserCreated fires the user_registered triggers for u, it is meant as
+	// a userservice.CreateHook
+	UserCreated(ctx context.Context, u *user.User) error
+	// Birthdays fires the birthday triggers for the users whose birthday
+	// falls on day and returns the number of vouchers issued
+	Birthdays(ctx context.Context, day time.Time) (int, error)
+}
+
+type triggerService struct {
+	repo     triggerrepo.Repo
+	users    userrepo.Repo
+	offers   offerservice.OfferService
+	vouchers voucherservice.VoucherService
+	now      func() time.Time
+}
+
+// NewTriggerService will instantiate Trigger Service. It reads users from
+// the repository, as it hooks into the user service.
+func NewTriggerService(
+	repo triggerrepo.Repo,
+	users userrepo.Repo,
+	offers offerservice.OfferService,
+	vouchers voucherservice.VoucherService,
+) TriggerService {
+
+	return &triggerService{
+		repo:     repo,
+		users:    users,
+		offers:   offers,
+		vouchers: vouchers,
+		now:      time.Now,
+	}
+}
+
+func (ts *triggerService) Create(ctx context.Context, t *trigger.Trigger) error {
+	if err := ts.validate(ctx, t); err != nil {
+		return err
+	}
+	return ts.repo.Create(ctx, t)
+}
+
+func (ts *triggerService) GetByID(ctx context.Context, id uint) (*trigger.Trigger, error) {
+	if id == 0 {
+		return nil, errors.New("id param is required")
+	}
+	return ts.repo.GetByID(ctx, id)
+}
+
+func (ts *triggerService) List(ctx context.Context) ([]*trigger.Trigger, error) {
+	return ts.repo.List(ctx)
+}
+
+func (ts *triggerService) Update(ctx context.Context, t *trigger.Trigger) error {
+	if err := ts.validate(ctx, t); err != nil {
+		return err
+	}
+	return ts.repo.Update(ctx, t)
+}
+
+func (ts *triggerService) Delete(ctx context.Context, id uint) error {
+	return ts.repo.Delete(ctx, id)
+}
+
+func (ts *triggerService) UserCreated(ctx context.Context, u *user.User) error {
+	triggers, err := ts.active(ctx, trigger.EventUserRegistered)
+	if err != nil {
+		return err
+	}
+	occurrence := trigger.Occurrence(trigger.EventUserRegistered, ts.now())
+	var first error
+	for _, t := range triggers {
+		if _, err := ts.fire(ctx, t, u, occurrence); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Birthdays goes on past the users it fails to issue to and returns the
+// first error
+func (ts *triggerService) Birthdays(ctx context.Context, day time.Time) (int, error) {
+	triggers, err := ts.active(ctx, trigger.EventBirthday)
+	if err != nil || len(triggers) == 0 {
+		return 0, err
+	}
+	occurrence := trigger.Occurrence(trigger.EventBirthday, day)
+	issued := 0
+	var first error
+	for after := uint(0); ; {
+		users, err := ts.users.ListByBirthday(ctx, day, after, birthdayPage)
+		if err != nil {
+			return issued, err
+		}
+		for _, u := range users {
+			for _, t := range triggers {
+				ok, err := ts.fire(ctx, t, u, occurrence)
+				if ok {
+					issued++
+				}
+				if err != nil && first == nil {
+					first = err
+				}
+			}
+		}
+		if len(users) < birthdayPage {
+			return issued, first
+		}
+		after = users[len(users)-1].ID
+	}
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+func (ts *triggerService) validate(ctx context.Context, t *trigger.Trigger) error {
+	known := false
+	for _, e := range trigger.Events {
+		known = known || e == t.Event
+	}
+	if !known {
+		return ErrEvent
+	}
+	if t.ValidityDays <= 0 {
+		return ErrValidity
+	}
+	_, err := ts.offers.GetByID(ctx, t.OfferID)
+	return err
+}
+
+// active lists the active triggers of event whose offer is still there, a
+// trigger of a deleted offer issues nothing
+func (ts *triggerService) active(ctx context.Context, event string) ([]*trigger.Trigger, error) {
+	triggers, err := ts.repo.ListActive(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	out := triggers[:0]
+	for _, t := range triggers {
+		_, err := ts.offers.GetByID(ctx, t.OfferID)
+		if gorm.IsRecordNotFoundError(err) {
+			logger.FromContext(ctx).Warn("trigger offer not found", logger.Fields{
+				"trigger_id": t.ID,
+				"offer_id":   t.OfferID,
+			})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// fire issues the voucher of t to u unless it was for occurrence already,
+// and tells whether it did. The issuance is recorded first so concurrent
+// runs issue once, and dropped when issuing fails so a later run retries.
+func (ts *triggerService) fire(ctx context.Context, t *trigger.Trigger, u *user.User, occurrence string) (bool, error) {
+	i := &trigger.Issuance{TriggerID: t.ID, UserID: u.ID, Occurrence: occurrence}
+	if err := ts.repo.CreateIssuance(ctx, i); err != nil {
+		if _, gerr := ts.repo.GetIssuance(ctx, t.ID, u.ID, occurrence); gerr == nil {
+			return false, nil
+		}
+		return false, err
+	}
+	expire := ts.now().Add(time.Duration(t.ValidityDays) * 24 * time.Hour)
+	v, err := ts.vouchers.Issue(ctx, t.OfferID, u.ID, expire)
+	if err != nil {
+		if derr := ts.repo.DeleteIssuance(ctx, i.ID); derr != nil {
+			logger.FromContext(ctx).Error("releasing trigger issuance failed", logger.Fields{
+				"trigger_id": t.ID,
+				"user_id":    u.ID,
+				"error":      derr,
+			})
+		}
+		return false, err
+	}
+	i.VoucherID = v.ID
+	logger.FromContext(ctx).Info("trigger voucher issued", logger.Fields{
+		"trigger_id": t.ID,
+		"event":      t.Event,
+		"user_id":    u.ID,
+		"voucher_id": v.ID,
+		"occurrence": occurrence,
+	})
+	return true, ts.repo.SetVoucher(ctx, i)
+}
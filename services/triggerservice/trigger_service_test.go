@@ -0,0 +1,207 @@
+package triggerservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/trigger"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+	"github.com/deepinbytes/go_voucher/repositories/offerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/triggerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/userrepo"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/userservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	ctx = context.Background()
+	now = time.Date(2021, 5, 17, 8, 0, 0, 0, time.UTC)
+)
+
+type fixture struct {
+	svc      *triggerService
+	users    userservice.UserService
+	offers   offerservice.OfferService
+	vouchers voucherservice.VoucherService
+	welcome  *offer.Offer
+	birthday *offer.Offer
+}
+
+// setup hooks the trigger service into the user service and creates an
+// offer for each event
+func setup(t *testing.T) *fixture {
+	db := memdb.New()
+	userRepo := userrepo.NewMemoryUserRepo(db)
+	offers := offerservice.NewOfferService(offerrepo.NewMemoryOfferRepo(db))
+	vouchers := voucherservice.NewVoucherService(voucherrepo.NewMemoryVoucherRepo(db))
+	svc := NewTriggerService(triggerrepo.NewMemoryTriggerRepo(db), userRepo, offers, vouchers).(*triggerService)
+	svc.now = func() time.Time { return now }
+
+	f := &fixture{
+		svc:      svc,
+		users:    userservice.NewUserService(userRepo, svc.UserCreated),
+		offers:   offers,
+		vouchers: vouchers,
+		welcome:  &offer.Offer{Name: "Welcome", DiscountPercentage: 10},
+		birthday: &offer.Offer{Name: "Birthday", DiscountPercentage: 20},
+	}
+	require.Nil(t, offers.Create(ctx, f.welcome))
+	require.Nil(t, offers.Create(ctx, f.birthday))
+	return f
+}
+
+func (f *fixture) vouchersOf(t *testing.T, o *offer.Offer) []*voucher.Voucher {
+	vs, err := f.vouchers.ListByOffers(ctx, []uint{o.ID}, voucherrepo.ListOptions{})
+	require.Nil(t, err)
+	return vs
+}
+
+func TestCreate(t *testing.T) {
+	f := setup(t)
+
+	tr := &trigger.Trigger{Event: trigger.EventUserRegistered, OfferID: f.welcome.ID, ValidityDays: 14, Active: true}
+	require.Nil(t, f.svc.Create(ctx, tr))
+	assert.NotZero(t, tr.ID)
+
+	assert.Equal(t, ErrEvent, f.svc.Create(ctx, &trigger.Trigger{Event: "login", OfferID: f.welcome.ID, ValidityDays: 1}))
+	assert.Equal(t, ErrValidity, f.svc.Create(ctx, &trigger.Trigger{Event: trigger.EventBirthday, OfferID: f.welcome.ID}))
+	assert.NotNil(t, f.svc.Create(ctx, &trigger.Trigger{Event: trigger.EventBirthday, OfferID: 404, ValidityDays: 1}))
+
+	tr.ValidityDays = 0
+	assert.Equal(t, ErrValidity, f.svc.Update(ctx, tr))
+}
+
+func TestUserCreated(t *testing.T) {
+	t.Run("Issues a welcome voucher on registration", func(t *testing.T) {
+		f := setup(t)
+		require.Nil(t, f.svc.Create(ctx, &trigger.Trigger{
+			Event: trigger.EventUserRegistered, OfferID: f.welcome.ID, ValidityDays: 14, Active: true}))
+		require.Nil(t, f.svc.Create(ctx, &trigger.Trigger{
+			Event: trigger.EventUserRegistered, OfferID: f.birthday.ID, ValidityDays: 14}))
+
+		alice := &user.User{Email: "alice@cc.cc"}
+		require.Nil(t, f.users.Create(ctx, alice))
+
+		vs := f.vouchersOf(t, f.welcome)
+		if assert.Len(t, vs, 1) {
+			assert.Equal(t, alice.ID, vs[0].UserID)
+			assert.Equal(t, now.Add(14*24*time.Hour), vs[0].ExpireTime)
+		}
+		assert.Empty(t, f.vouchersOf(t, f.birthday))
+
+		// firing again issues nothing more
+		require.Nil(t, f.svc.UserCreated(ctx, alice))
+		assert.Len(t, f.vouchersOf(t, f.welcome), 1)
+	})
+
+	t.Run("Skips triggers of a deleted offer", func(t *testing.T) {
+		f := setup(t)
+		require.Nil(t, f.svc.Create(ctx, &trigger.Trigger{
+			Event: trigger.EventUserRegistered, OfferID: f.welcome.ID, ValidityDays: 14, Active: true}))
+		require.Nil(t, f.offers.Delete(ctx, f.welcome.ID, now))
+
+		alice := &user.User{Email: "alice@cc.cc"}
+		require.Nil(t, f.svc.UserCreated(ctx, alice))
+		assert.Empty(t, f.vouchersOf(t, f.welcome))
+	})
+
+	t.Run("Retries once issuing failed", func(t *testing.T) {
+		f := setup(t)
+		require.Nil(t, f.svc.Create(ctx, &trigger.Trigger{
+			Event: trigger.EventUserRegistered, OfferID: f.welcome.ID, ValidityDays: 14, Active: true}))
+		f.svc.vouchers = failingIssue{f.vouchers}
+
+		alice := &user.User{Email: "alice@cc.cc"}
+		require.Nil(t, f.users.Create(ctx, alice))
+		assert.Empty(t, f.vouchersOf(t, f.welcome))
+
+		f.svc.vouchers = f.vouchers
+		require.Nil(t, f.svc.UserCreated(ctx, alice))
+		assert.Len(t, f.vouchersOf(t, f.welcome), 1)
+	})
+}
+
+func TestBirthdays(t *testing.T) {
+	t.Run("Issues once a year", func(t *testing.T) {
+		f := setup(t)
+		require.Nil(t, f.svc.Create(ctx, &trigger.Trigger{
+			Event: trigger.EventBirthday, OfferID: f.birthday.ID, ValidityDays: 7, Active: true}))
+		var born []*user.User
+		for i, birthday := range []string{"1990-05-17", "1985-05-17", "1990-05-18", ""} {
+			u := &user.User{Email: fmt.Sprintf("user%d@cc.cc", i), Birthday: birthday}
+			require.Nil(t, f.users.Create(ctx, u))
+			born = append(born, u)
+		}
+
+		issued, err := f.svc.Birthdays(ctx, now)
+		require.Nil(t, err)
+		assert.Equal(t, 2, issued)
+		issued, err = f.svc.Birthdays(ctx, now)
+		require.Nil(t, err)
+		assert.Zero(t, issued)
+
+		vs := f.vouchersOf(t, f.birthday)
+		owners := []uint{}
+		for _, v := range vs {
+			owners = append(owners, v.UserID)
+		}
+		assert.ElementsMatch(t, []uint{born[0].ID, born[1].ID}, owners)
+
+		issued, err = f.svc.Birthdays(ctx, now.AddDate(1, 0, 0))
+		require.Nil(t, err)
+		assert.Equal(t, 2, issued)
+	})
+
+	t.Run("Pages through the users", func(t *testing.T) {
+		f := setup(t)
+		require.Nil(t, f.svc.Create(ctx, &trigger.Trigger{
+			Event: trigger.EventBirthday, OfferID: f.birthday.ID, ValidityDays: 7, Active: true}))
+		for i := 0; i < birthdayPage+5; i++ {
+			require.Nil(t, f.users.Create(ctx, &user.User{Email: fmt.Sprintf("user%d@cc.cc", i), Birthday: "2000-05-17"}))
+		}
+
+		issued, err := f.svc.Birthdays(ctx, now)
+		require.Nil(t, err)
+		assert.Equal(t, birthdayPage+5, issued)
+	})
+
+	t.Run("Concurrent runs issue once", func(t *testing.T) {
+		f := setup(t)
+		require.Nil(t, f.svc.Create(ctx, &trigger.Trigger{
+			Event: trigger.EventBirthday, OfferID: f.birthday.ID, ValidityDays: 7, Active: true}))
+		require.Nil(t, f.users.Create(ctx, &user.User{Email: "alice@cc.cc", Birthday: "1990-05-17"}))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := f.svc.Birthdays(ctx, now)
+				assert.Nil(t, err)
+			}()
+		}
+		wg.Wait()
+		assert.Len(t, f.vouchersOf(t, f.birthday), 1)
+	})
+}
+
+// failingIssue fails to issue any voucher
+type failingIssue struct {
+	voucherservice.VoucherService
+}
+
+func (failingIssue) Issue(ctx context.Context, offerID, userID uint, expireTime time.Time) (*voucher.Voucher, error) {
+	return nil, errors.New("issuing failed")
+}
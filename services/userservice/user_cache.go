@@ -0,0 +1,74 @@
+package userservice
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/cache"
+	"github.com/deepinbytes/go_voucher/domain/tenant"
+	"github.com/deepinbytes/go_voucher/domain/user"
+)
+
+type cachedUserService struct {
+	UserService
+	cache *cache.Cache
+}
+
+// NewCachedUserService will instantiate a User Service caching GetByID of
+// next and invalidating it on Update, UpdateIfVersion, Delete and Restore.
+// GetByEmail is not cached as it loads the vouchers of the user, which
+// change without going through this service.
+func NewCachedUserService(next UserService, c *cache.Cache) UserService {
+	return &cachedUserService{
+		UserService: next,
+		cache:       c,
+	}
+}
+
+func (cs *cachedUserService) GetByID(ctx context.Context, id uint) (*user.User, error) {
+	if id == 0 {
+		return cs.UserService.GetByID(ctx, id)
+	}
+	var u user.User
+	err := cs.cache.Fetch(ctx, idKey(ctx, id), &u, func() (interface{}, error) {
+		return cs.UserService.GetByID(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (cs *cachedUserService) Update(ctx context.Context, user *user.User) error {
+	err := cs.UserService.Update(ctx, user)
+	cs.cache.Invalidate(ctx, idKey(ctx, user.ID))
+	return err
+}
+
+func (cs *cachedUserService) UpdateIfVersion(ctx context.Context, user *user.User, version uint) error {
+	err := cs.UserService.UpdateIfVersion(ctx, user, version)
+	cs.cache.Invalidate(ctx, idKey(ctx, user.ID))
+	return err
+}
+
+func (cs *cachedUserService) Delete(ctx context.Context, id uint, at time.Time) error {
+	err := cs.UserService.Delete(ctx, id, at)
+	cs.cache.Invalidate(ctx, idKey(ctx, id))
+	return err
+}
+
+func (cs *cachedUserService) Restore(ctx context.Context, id uint) error {
+	err := cs.UserService.Restore(ctx, id)
+	cs.cache.Invalidate(ctx, idKey(ctx, id))
+	return err
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// keys carry the tenant so a cached row never leaks into another tenant
+func idKey(ctx context.Context, id uint) string {
+	return fmt.Sprintf("user:%d:id:%d", tenant.FromContext(ctx), id)
+}
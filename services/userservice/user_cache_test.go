@@ -0,0 +1,50 @@
+package userservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/common/cache"
+	"github.com/deepinbytes/go_voucher/domain/user"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedUserService(t *testing.T) {
+	t.Run("Caches GetByID until Update", func(t *testing.T) {
+		old := &user.User{Email: testEmail, FirstName: "Old"}
+		old.ID = testID10
+		updated := &user.User{Email: testEmail, FirstName: "New"}
+		updated.ID = testID10
+
+		userRepo := new(repoMock)
+		s := NewCachedUserService(NewUserService(userRepo), cache.New("users", cache.NewLRU(10), time.Minute))
+		userRepo.On("GetByID", testID10).Return(old, nil).Once()
+		userRepo.On("Update", updated).Return(nil)
+		userRepo.On("GetByID", testID10).Return(updated, nil).Once()
+
+		first, _ := s.GetByID(context.Background(), testID10)
+		cached, _ := s.GetByID(context.Background(), testID10)
+		assert.Nil(t, s.Update(context.Background(), updated))
+		result, _ := s.GetByID(context.Background(), testID10)
+
+		assert.Equal(t, "Old", first.FirstName)
+		assert.Equal(t, "Old", cached.FirstName)
+		assert.Equal(t, "New", result.FirstName)
+		userRepo.AssertNumberOfCalls(t, "GetByID", 2)
+	})
+
+	t.Run("Does not cache GetByEmail", func(t *testing.T) {
+		expected := &user.User{Email: testEmail}
+
+		userRepo := new(repoMock)
+		s := NewCachedUserService(NewUserService(userRepo), cache.New("users", cache.NewLRU(10), time.Minute))
+		userRepo.On("GetByEmail", testEmail).Return(expected, nil)
+
+		s.GetByEmail(context.Background(), testEmail)
+		s.GetByEmail(context.Background(), testEmail)
+
+		userRepo.AssertNumberOfCalls(t, "GetByEmail", 2)
+	})
+}
@@ -1,69 +1,137 @@
 package userservice
 
 import (
+	"context"
 	"errors"
+	"github.com/deepinbytes/go_voucher/common/logger"
 	"github.com/deepinbytes/go_voucher/domain/user"
 
 	"github.com/deepinbytes/go_voucher/repositories/userrepo"
+	"time"
 )
 
 // UserService interface
 type UserService interface {
-	GetByID(id uint) (*user.User, error)
-	GetByEmail(email string) (*user.User, error)
-	ListAll() ([]*user.User, error)
-	Create(*user.User) error
-	Update(*user.User) error
+	GetByID(ctx context.Context, id uint) (*user.User, error)
+	GetByEmail(ctx context.Context, email string) (*user.User, error)
+	ListAll(ctx context.Context) ([]*user.User, error)
+	Create(ctx context.Context, user *user.User) error
+	Update(ctx context.Context, user *user.User) error
+	GetByIDs(ctx context.Context, ids []uint) ([]*user.User, error)
+	ListPage(ctx context.Context, after uint, limit int) ([]*user.User, error)
+	UpdateIfVersion(ctx context.Context, user *user.User, version uint) error
+	Delete(ctx context.Context, id uint, at time.Time) error
+	GetDeleted(ctx context.Context, id uint) (*user.User, error)
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, before time.Time) (int64, error)
 }
 
+// CreateHook runs once a user is created, e.g. to issue it a welcome
+// voucher
+type CreateHook func(ctx context.Context, u *user.User) error
+
 type userService struct {
-	Repo userrepo.Repo
+	Repo  userrepo.Repo
+	hooks []CreateHook
 }
 
-func (us *userService) ListAll() ([]*user.User, error) {
-	users, err := us.Repo.ListAll()
+func (us *userService) ListAll(ctx context.Context) ([]*user.User, error) {
+	users, err := us.Repo.ListAll(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return users, nil
 }
 
-// NewUserService will instantiate User Service
+// NewUserService will instantiate User Service running hooks after every
+// user created
 func NewUserService(
 	repo userrepo.Repo,
+	hooks ...CreateHook,
 ) UserService {
 
 	return &userService{
-		Repo: repo,
+		Repo:  repo,
+		hooks: hooks,
 	}
 }
 
-func (us *userService) GetByID(id uint) (*user.User, error) {
+func (us *userService) GetByID(ctx context.Context, id uint) (*user.User, error) {
 	if id == 0 {
 		return nil, errors.New("id param is required")
 	}
-	user, err := us.Repo.GetByID(id)
+	user, err := us.Repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-func (us *userService) GetByEmail(email string) (*user.User, error) {
+func (us *userService) GetByEmail(ctx context.Context, email string) (*user.User, error) {
 	if email == "" {
 		return nil, errors.New("email(string) is required")
 	}
-	user, err := us.Repo.GetByEmail(email)
+	user, err := us.Repo.GetByEmail(ctx, email)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-func (us *userService) Create(user *user.User) error {
-	return us.Repo.Create(user)
+// Create runs the hooks once the user is created. The user exists by then,
+// so a failing hook is logged rather than returned.
+func (us *userService) Create(ctx context.Context, user *user.User) error {
+	if err := us.Repo.Create(ctx, user); err != nil {
+		return err
+	}
+	for _, hook := range us.hooks {
+		if err := hook(ctx, user); err != nil {
+			logger.FromContext(ctx).Error("user created hook failed", logger.Fields{
+				"user_id": user.ID,
+				"error":   err,
+			})
+		}
+	}
+	return nil
+}
+
+func (us *userService) Update(ctx context.Context, user *user.User) error {
+	return us.Repo.Update(ctx, user)
+}
+
+func (us *userService) GetByIDs(ctx context.Context, ids []uint) ([]*user.User, error) {
+	return us.Repo.GetByIDs(ctx, ids)
+}
+
+func (us *userService) ListPage(ctx context.Context, after uint, limit int) ([]*user.User, error) {
+	return us.Repo.ListPage(ctx, after, limit)
+}
+
+func (us *userService) UpdateIfVersion(ctx context.Context, user *user.User, version uint) error {
+	return us.Repo.UpdateIfVersion(ctx, user, version)
+}
+
+func (us *userService) Delete(ctx context.Context, id uint, at time.Time) error {
+	if id == 0 {
+		return errors.New("id param is required")
+	}
+	return us.Repo.Delete(ctx, id, at)
+}
+
+func (us *userService) GetDeleted(ctx context.Context, id uint) (*user.User, error) {
+	if id == 0 {
+		return nil, errors.New("id param is required")
+	}
+	return us.Repo.GetDeleted(ctx, id)
+}
+
+func (us *userService) Restore(ctx context.Context, id uint) error {
+	if id == 0 {
+		return errors.New("id param is required")
+	}
+	return us.Repo.Restore(ctx, id)
 }
 
-func (us *userService) Update(user *user.User) error {
-	return us.Repo.Update(user)
+func (us *userService) Purge(ctx context.Context, before time.Time) (int64, error) {
+	return us.Repo.Purge(ctx, before)
 }
@@ -1,8 +1,10 @@
 package userservice
 
 import (
+	"context"
 	"github.com/deepinbytes/go_voucher/domain/user"
 	"github.com/stretchr/testify/mock"
+	"time"
 )
 
 var (
@@ -15,27 +17,67 @@ type repoMock struct {
 	mock.Mock
 }
 
-func (repo *repoMock) ListAll() ([]*user.User, error) {
+func (repo *repoMock) ListAll(ctx context.Context) ([]*user.User, error) {
 	args := repo.Called()
 	return args.Get(0).([]*user.User), args.Error(1)
 }
 
-func (repo *repoMock) GetByID(id uint) (*user.User, error) {
+func (repo *repoMock) GetByID(ctx context.Context, id uint) (*user.User, error) {
 	args := repo.Called(id)
 	return args.Get(0).(*user.User), args.Error(1)
 }
 
-func (repo *repoMock) GetByEmail(email string) (*user.User, error) {
+func (repo *repoMock) GetByEmail(ctx context.Context, email string) (*user.User, error) {
 	args := repo.Called(email)
 	return args.Get(0).(*user.User), args.Error(1)
 }
 
-func (repo *repoMock) Create(user *user.User) error {
+func (repo *repoMock) Create(ctx context.Context, user *user.User) error {
 	args := repo.Called(user)
 	return args.Error(0)
 }
 
-func (repo *repoMock) Update(user *user.User) error {
+func (repo *repoMock) Update(ctx context.Context, user *user.User) error {
 	args := repo.Called(user)
 	return args.Error(0)
 }
+
+func (repo *repoMock) GetByIDs(ctx context.Context, ids []uint) ([]*user.User, error) {
+	args := repo.Called(ids)
+	return args.Get(0).([]*user.User), args.Error(1)
+}
+
+func (repo *repoMock) ListPage(ctx context.Context, after uint, limit int) ([]*user.User, error) {
+	args := repo.Called(after, limit)
+	return args.Get(0).([]*user.User), args.Error(1)
+}
+
+func (repo *repoMock) ListByBirthday(ctx context.Context, day time.Time, after uint, limit int) ([]*user.User, error) {
+	args := repo.Called(day, after, limit)
+	return args.Get(0).([]*user.User), args.Error(1)
+}
+
+func (repo *repoMock) UpdateIfVersion(ctx context.Context, user *user.User, version uint) error {
+	args := repo.Called(user, version)
+	return args.Error(0)
+}
+
+func (repo *repoMock) Delete(ctx context.Context, id uint, at time.Time) error {
+	args := repo.Called(id, at)
+	return args.Error(0)
+}
+
+func (repo *repoMock) GetDeleted(ctx context.Context, id uint) (*user.User, error) {
+	args := repo.Called(id)
+	return args.Get(0).(*user.User), args.Error(1)
+}
+
+func (repo *repoMock) Restore(ctx context.Context, id uint) error {
+	args := repo.Called(id)
+	return args.Error(0)
+}
+
+func (repo *repoMock) Purge(ctx context.Context, before time.Time) (int64, error) {
+	args := repo.Called(before)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -1,6 +1,7 @@
 package userservice
 
 import (
+	"context"
 	"errors"
 	"github.com/deepinbytes/go_voucher/domain/user"
 	"testing"
@@ -19,7 +20,7 @@ func TestGetByID(t *testing.T) {
 		u := NewUserService(userRepo)
 		userRepo.On("GetByID", testID100).Return(expected, nil)
 
-		result, _ := u.GetByID(testID100)
+		result, _ := u.GetByID(context.Background(), testID100)
 
 		assert.EqualValues(t, expected, result)
 	})
@@ -30,7 +31,7 @@ func TestGetByID(t *testing.T) {
 		userRepo := new(repoMock)
 		u := NewUserService(userRepo)
 
-		result, err := u.GetByID(0)
+		result, err := u.GetByID(context.Background(), 0)
 
 		assert.Nil(t, result)
 		assert.EqualValues(t, expected, err)
@@ -43,7 +44,7 @@ func TestGetByID(t *testing.T) {
 		u := NewUserService(userRepo)
 		userRepo.On("GetByID", testID10).Return(&user.User{}, expected)
 
-		result, err := u.GetByID(testID10)
+		result, err := u.GetByID(context.Background(), testID10)
 
 		assert.Nil(t, result)
 		assert.EqualValues(t, expected, err)
@@ -62,7 +63,7 @@ func TestGetByEmail(t *testing.T) {
 		u := NewUserService(userRepo)
 		userRepo.On("GetByEmail", testEmail).Return(expected, nil)
 
-		result, _ := u.GetByEmail(testEmail)
+		result, _ := u.GetByEmail(context.Background(), testEmail)
 
 		assert.EqualValues(t, expected, result)
 	})
@@ -74,7 +75,7 @@ func TestGetByEmail(t *testing.T) {
 
 		u := NewUserService(userRepo)
 
-		result, err := u.GetByEmail("")
+		result, err := u.GetByEmail(context.Background(), "")
 
 		assert.Nil(t, result)
 		assert.EqualValues(t, expected, err)
@@ -88,7 +89,7 @@ func TestGetByEmail(t *testing.T) {
 		u := NewUserService(userRepo)
 		userRepo.On("GetByEmail", testEmail).Return(&user.User{}, expected)
 
-		result, err := u.GetByEmail(testEmail)
+		result, err := u.GetByEmail(context.Background(), testEmail)
 
 		assert.Nil(t, result)
 		assert.EqualValues(t, expected, err)
@@ -106,7 +107,7 @@ func TestCreate(t *testing.T) {
 		u := NewUserService(userRepo)
 		userRepo.On("Create", usr).Return(nil)
 
-		result := u.Create(usr)
+		result := u.Create(context.Background(), usr)
 
 		assert.Nil(t, result)
 	})
@@ -122,10 +123,36 @@ func TestCreate(t *testing.T) {
 		u := NewUserService(userRepo)
 
 		userRepo.On("Create", usr).Return(err)
-		result := u.Create(usr)
+		result := u.Create(context.Background(), usr)
 
 		assert.EqualValues(t, result, err)
 	})
+
+	t.Run("Runs the hooks once created", func(t *testing.T) {
+		usr := &user.User{
+			Email: "alice@cc.cc",
+		}
+		var hooked []*user.User
+		hook := func(ctx context.Context, u *user.User) error {
+			hooked = append(hooked, u)
+			return errors.New("oops")
+		}
+
+		userRepo := new(repoMock)
+
+		u := NewUserService(userRepo, hook, hook)
+		userRepo.On("Create", usr).Return(nil)
+
+		result := u.Create(context.Background(), usr)
+
+		assert.Nil(t, result)
+		assert.Equal(t, []*user.User{usr, usr}, hooked)
+
+		failing := new(repoMock)
+		failing.On("Create", usr).Return(errors.New("oops"))
+		assert.NotNil(t, NewUserService(failing, hook).Create(context.Background(), usr))
+		assert.Len(t, hooked, 2)
+	})
 }
 
 func TestUpdate(t *testing.T) {
@@ -139,7 +166,7 @@ func TestUpdate(t *testing.T) {
 		u := NewUserService(userRepo)
 		userRepo.On("Update", usr).Return(nil)
 
-		result := u.Update(usr)
+		result := u.Update(context.Background(), usr)
 
 		assert.Nil(t, result)
 	})
@@ -155,7 +182,7 @@ func TestUpdate(t *testing.T) {
 		u := NewUserService(userRepo)
 		userRepo.On("Update", usr).Return(err)
 
-		result := u.Update(usr)
+		result := u.Update(context.Background(), usr)
 
 		assert.EqualValues(t, result, err)
 	})
@@ -1,59 +1,339 @@
 package voucherservice
 
 import (
+	"context"
+	"crypto/rand"
 	"errors"
+	"github.com/deepinbytes/go_voucher/common/logger"
+	"github.com/deepinbytes/go_voucher/common/metrics"
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/domain/user"
 	"github.com/deepinbytes/go_voucher/domain/voucher"
 	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/jinzhu/gorm"
+	"github.com/prometheus/client_golang/prometheus"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrWrongUser is returned when a code is redeemed by someone else
+	ErrWrongUser = errors.New("code not valid for this user")
+	// ErrUsed is returned when a code has already been redeemed
+	ErrUsed = errors.New("voucher already used")
+	// ErrExpired is returned when a code is redeemed after its expiry time
+	ErrExpired = errors.New("voucher expired")
+	// ErrNotUsed is returned when reversing a voucher that was not redeemed
+	ErrNotUsed = errors.New("voucher not used")
 )
 
 // voucherService interface
 type VoucherService interface {
-	GetByID(id uint) (*voucher.Voucher, error)
-	UseCode(code string) (*voucher.Voucher, error)
-	Create(*voucher.Voucher) error
-	Update(*voucher.Voucher) error
+	GetByID(ctx context.Context, id uint) (*voucher.Voucher, error)
+	UseCode(ctx context.Context, code string) (*voucher.Voucher, error)
+	Redeem(ctx context.Context, v *voucher.Voucher, owner *user.User, email string) error
+	RedeemAt(ctx context.Context, v *voucher.Voucher, at time.Time) error
+	Reverse(ctx context.Context, v *voucher.Voucher) error
+	Issue(ctx context.Context, offerID, userID uint, expireTime time.Time) (*voucher.Voucher, error)
+	Generate(ctx context.Context, offerID uint, users []*user.User, expireTime time.Time) (int, error)
+	ExpiredByOffer(ctx context.Context) (map[uint]int64, error)
+	ListByOffers(ctx context.Context, offerIDs []uint, opts voucherrepo.ListOptions) ([]*voucher.Voucher, error)
+	ListByUsers(ctx context.Context, userIDs []uint, opts voucherrepo.ListOptions) ([]*voucher.Voucher, error)
+	Create(ctx context.Context, voucher *voucher.Voucher) error
+	Update(ctx context.Context, voucher *voucher.Voucher) error
+	RevokeUnusedByOffer(ctx context.Context, offerID uint, at time.Time) (int64, error)
+	RevokeUnusedByUser(ctx context.Context, userID uint, at time.Time) (int64, error)
+	RestoreByOffer(ctx context.Context, offerID uint, at time.Time) (int64, error)
+	RestoreByUser(ctx context.Context, userID uint, at time.Time) (int64, error)
+	Purge(ctx context.Context, before time.Time) (int64, error)
 }
 
+// IssueHook runs once a voucher is created, e.g. to send it to its user
+type IssueHook func(ctx context.Context, v *voucher.Voucher) error
+
 type voucherService struct {
-	Repo voucherrepo.Repo
+	Repo  voucherrepo.Repo
+	hooks []IssueHook
 }
 
-// NewVoucherService will instantiate Voucher Service
+// NewVoucherService will instantiate Voucher Service running hooks after
+// every voucher created
 func NewVoucherService(
 	repo voucherrepo.Repo,
+	hooks ...IssueHook,
 ) VoucherService {
 
 	return &voucherService{
-		Repo: repo,
+		Repo:  repo,
+		hooks: hooks,
 	}
 }
 
-func (vs *voucherService) GetByID(id uint) (*voucher.Voucher, error) {
+func (vs *voucherService) GetByID(ctx context.Context, id uint) (*voucher.Voucher, error) {
 	if id == 0 {
 		return nil, errors.New("id param is required")
 	}
-	voucher, err := vs.Repo.GetByID(id)
+	voucher, err := vs.Repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	return voucher, nil
 }
 
-func (vs *voucherService) UseCode(code string) (*voucher.Voucher, error) {
+func (vs *voucherService) UseCode(ctx context.Context, code string) (*voucher.Voucher, error) {
 	if code == "" {
 		return nil, errors.New("Code(string) is required")
 	}
-	voucher, err := vs.Repo.UseCode(code)
+	voucher, err := vs.Repo.UseCode(ctx, code)
 	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			redemptionFailed(ctx, "not_found", nil)
+		} else {
+			redemptionFailed(ctx, "error", nil)
+		}
 		return nil, err
 	}
 	return voucher, nil
 }
 
-func (vs *voucherService) Create(voucher *voucher.Voucher) error {
-	return vs.Repo.Create(voucher)
+// Redeem marks v as used by owner after checking it was issued to email and
+// is neither used nor expired. Emails are compared regardless of case, as
+// users registered before emails were lower-cased may have mixed case ones.
+// It returns ErrUsed when v was used meanwhile, so a code redeemed
+// concurrently, online or by an offline reconciliation, is used once. The
+// discount set on v, if any, is recorded as the one applied to the order.
+func (vs *voucherService) Redeem(ctx context.Context, v *voucher.Voucher, owner *user.User, email string) error {
+	now := time.Now()
+	if err := Check(v, owner, email, now); err != nil {
+		redemptionFailed(ctx, Reason(err), v)
+		return err
+	}
+	return vs.markUsed(ctx, v, now)
+}
+
+// Check tells why v cannot be redeemed by owner, known by email, at now:
+// ErrWrongUser, ErrUsed or ErrExpired. It is nil when v can be redeemed.
+func Check(v *voucher.Voucher, owner *user.User, email string, now time.Time) error {
+	if !strings.EqualFold(owner.Email, email) {
+		return ErrWrongUser
+	}
+	if v.IsUsed {
+		return ErrUsed
+	}
+	if now.After(v.ExpireTime) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// RedeemAt records a redemption accepted elsewhere at the given time, e.g.
+// by a terminal while offline, so it skips the checks of Redeem. It returns
+// ErrUsed when v was used meanwhile, however recent the copy of v is.
+func (vs *voucherService) RedeemAt(ctx context.Context, v *voucher.Voucher, at time.Time) error {
+	return vs.markUsed(ctx, v, at)
+}
+
+// Reason is the short name of a Check error, as used in metrics
+func Reason(err error) string {
+	switch err {
+	case ErrWrongUser:
+		return "wrong_user"
+	case ErrUsed:
+		return "used"
+	case ErrExpired:
+		return "expired"
+	}
+	return "error"
+}
+
+// Guessed tells whether err, returned by UseCode or Redeem, rejects a code
+// the way guessing codes would, i.e. it is unknown, someone else's, used or
+// expired. Those count towards the redeem lockout.
+func Guessed(err error) bool {
+	switch err {
+	case ErrWrongUser, ErrUsed, ErrExpired:
+		return true
+	}
+	return err != nil && gorm.IsRecordNotFoundError(err)
+}
+
+// Reverse undoes the redemption of v, e.g. when the order it was used for
+// is cancelled. It returns ErrNotUsed when v is not used in the repository,
+// however recent the copy of v is, so concurrent reversals undo it once.
+func (vs *voucherService) Reverse(ctx context.Context, v *voucher.Voucher) error {
+	reversed, err := vs.Repo.MarkUnused(ctx, v.ID)
+	if err != nil {
+		return err
+	}
+	if !reversed {
+		return ErrNotUsed
+	}
+	v.IsUsed = false
+	v.UsedAt = time.Time{}
+	v.DiscountAmount, v.DiscountCurrency = 0, ""
+	metrics.VouchersReversed.WithLabelValues(offerLabel(v.OfferID)).Inc()
+	logger.FromContext(ctx).Info("voucher redemption reversed", logger.Fields{
+		"voucher_id": v.ID,
+		"offer_id":   v.OfferID,
+		"user_id":    v.UserID,
+	})
+	return nil
+}
+
+// Issue creates a voucher of the offer for the user with a new code
+func (vs *voucherService) Issue(ctx context.Context, offerID, userID uint, expireTime time.Time) (*voucher.Voucher, error) {
+	v := &voucher.Voucher{
+		Code:       NewCode(codeLength),
+		OfferID:    offerID,
+		UserID:     userID,
+		ExpireTime: expireTime,
+	}
+	if err := vs.Create(ctx, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Generate issues a voucher of the offer to every user and returns how many
+// were created
+func (vs *voucherService) Generate(ctx context.Context, offerID uint, users []*user.User, expireTime time.Time) (int, error) {
+	timer := prometheus.NewTimer(metrics.GenerationDuration)
+	created := 0
+	defer func() {
+		d := timer.ObserveDuration()
+		logger.FromContext(ctx).Info("vouchers generated", logger.Fields{
+			"offer_id":    offerID,
+			"created":     created,
+			"users":       len(users),
+			"duration_ms": float64(d) / float64(time.Millisecond),
+		})
+	}()
+
+	for _, u := range users {
+		if _, err := vs.Issue(ctx, offerID, u.ID, expireTime); err != nil {
+			return created, err
+		}
+		created++
+	}
+	return created, nil
+}
+
+func (vs *voucherService) ExpiredByOffer(ctx context.Context) (map[uint]int64, error) {
+	return vs.Repo.CountExpiredByOffer(ctx, time.Now())
+}
+
+func (vs *voucherService) ListByOffers(ctx context.Context, offerIDs []uint, opts voucherrepo.ListOptions) ([]*voucher.Voucher, error) {
+	return vs.Repo.ListByOffers(ctx, offerIDs, opts)
+}
+
+func (vs *voucherService) ListByUsers(ctx context.Context, userIDs []uint, opts voucherrepo.ListOptions) ([]*voucher.Voucher, error) {
+	return vs.Repo.ListByUsers(ctx, userIDs, opts)
+}
+
+// Create runs the hooks once the voucher is created. Issue and Generate
+// create through it, so hooks see every voucher. The voucher exists by
+// then, so a failing hook is logged rather than returned.
+func (vs *voucherService) Create(ctx context.Context, voucher *voucher.Voucher) error {
+	if err := vs.Repo.Create(ctx, voucher); err != nil {
+		return err
+	}
+	metrics.VouchersIssued.WithLabelValues(offerLabel(voucher.OfferID)).Inc()
+	for _, hook := range vs.hooks {
+		if err := hook(ctx, voucher); err != nil {
+			logger.FromContext(ctx).Error("voucher issued hook failed", logger.Fields{
+				"voucher_id": voucher.ID,
+				"error":      err,
+			})
+		}
+	}
+	return nil
+}
+
+func (vs *voucherService) Update(ctx context.Context, voucher *voucher.Voucher) error {
+	return vs.Repo.Update(ctx, voucher)
+}
+
+func (vs *voucherService) RevokeUnusedByOffer(ctx context.Context, offerID uint, at time.Time) (int64, error) {
+	return vs.Repo.RevokeUnusedByOffer(ctx, offerID, at)
+}
+
+func (vs *voucherService) RevokeUnusedByUser(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	return vs.Repo.RevokeUnusedByUser(ctx, userID, at)
+}
+
+func (vs *voucherService) RestoreByOffer(ctx context.Context, offerID uint, at time.Time) (int64, error) {
+	return vs.Repo.RestoreByOffer(ctx, offerID, at)
+}
+
+func (vs *voucherService) RestoreByUser(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	return vs.Repo.RestoreByUser(ctx, userID, at)
+}
+
+func (vs *voucherService) Purge(ctx context.Context, before time.Time) (int64, error) {
+	return vs.Repo.Purge(ctx, before)
+}
+
+// NewCode returns a random code of length n drawn from a CSPRNG so codes
+// cannot be predicted from earlier ones
+func NewCode(n int) string {
+	max := big.NewInt(int64(len(letters)))
+	b := make([]rune, n)
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+		b[i] = letters[idx.Int64()]
+	}
+	return string(b)
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+const codeLength = 8
+
+var letters = []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890")
+
+// markUsed marks v used at the given time unless it already is in the
+// repository
+func (vs *voucherService) markUsed(ctx context.Context, v *voucher.Voucher, at time.Time) error {
+	marked, err := vs.Repo.MarkUsed(ctx, v.ID, at, money.New(v.DiscountAmount, v.DiscountCurrency))
+	if err != nil {
+		redemptionFailed(ctx, "error", v)
+		return err
+	}
+	if !marked {
+		redemptionFailed(ctx, Reason(ErrUsed), v)
+		return ErrUsed
+	}
+
+	v.UsedAt = at
+	v.IsUsed = true
+	metrics.VouchersRedeemed.WithLabelValues(offerLabel(v.OfferID)).Inc()
+	logger.FromContext(ctx).Info("voucher redeemed", logger.Fields{
+		"voucher_id": v.ID,
+		"offer_id":   v.OfferID,
+		"user_id":    v.UserID,
+		"used_at":    at,
+	})
+	return nil
+}
+
+func redemptionFailed(ctx context.Context, reason string, v *voucher.Voucher) {
+	metrics.RedemptionFailures.WithLabelValues(reason).Inc()
+
+	f := logger.Fields{"reason": reason}
+	if v != nil {
+		f["voucher_id"] = v.ID
+		f["offer_id"] = v.OfferID
+	}
+	logger.FromContext(ctx).Info("redemption rejected", f)
 }
 
-func (vs *voucherService) Update(voucher *voucher.Voucher) error {
-	return vs.Repo.Update(voucher)
+func offerLabel(offerID uint) string {
+	return strconv.FormatUint(uint64(offerID), 10)
 }
@@ -1,8 +1,13 @@
 package voucherservice
 
 import (
+	"context"
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/domain/stats"
 	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
 	"github.com/stretchr/testify/mock"
+	"time"
 )
 
 var (
@@ -15,22 +20,87 @@ type repoMock struct {
 	mock.Mock
 }
 
-func (repo *repoMock) GetByID(id uint) (*voucher.Voucher, error) {
+func (repo *repoMock) GetByID(ctx context.Context, id uint) (*voucher.Voucher, error) {
 	args := repo.Called(id)
 	return args.Get(0).(*voucher.Voucher), args.Error(1)
 }
 
-func (repo *repoMock) UseCode(code string) (*voucher.Voucher, error) {
+func (repo *repoMock) UseCode(ctx context.Context, code string) (*voucher.Voucher, error) {
 	args := repo.Called(code)
 	return args.Get(0).(*voucher.Voucher), args.Error(1)
 }
 
-func (repo *repoMock) Create(voucher *voucher.Voucher) error {
+func (repo *repoMock) Create(ctx context.Context, voucher *voucher.Voucher) error {
 	args := repo.Called(voucher)
 	return args.Error(0)
 }
 
-func (repo *repoMock) Update(voucher *voucher.Voucher) error {
+func (repo *repoMock) Update(ctx context.Context, voucher *voucher.Voucher) error {
 	args := repo.Called(voucher)
 	return args.Error(0)
 }
+
+func (repo *repoMock) CountExpiredByOffer(ctx context.Context, now time.Time) (map[uint]int64, error) {
+	args := repo.Called(now)
+	return args.Get(0).(map[uint]int64), args.Error(1)
+}
+
+func (repo *repoMock) ListByOffers(ctx context.Context, offerIDs []uint, opts voucherrepo.ListOptions) ([]*voucher.Voucher, error) {
+	args := repo.Called(offerIDs, opts)
+	return args.Get(0).([]*voucher.Voucher), args.Error(1)
+}
+
+func (repo *repoMock) ListByUsers(ctx context.Context, userIDs []uint, opts voucherrepo.ListOptions) ([]*voucher.Voucher, error) {
+	args := repo.Called(userIDs, opts)
+	return args.Get(0).([]*voucher.Voucher), args.Error(1)
+}
+
+func (repo *repoMock) RevokeUnusedByOffer(ctx context.Context, offerID uint, at time.Time) (int64, error) {
+	args := repo.Called(offerID, at)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (repo *repoMock) RevokeUnusedByUser(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	args := repo.Called(userID, at)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (repo *repoMock) RestoreByOffer(ctx context.Context, offerID uint, at time.Time) (int64, error) {
+	args := repo.Called(offerID, at)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (repo *repoMock) RestoreByUser(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	args := repo.Called(userID, at)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (repo *repoMock) Purge(ctx context.Context, before time.Time) (int64, error) {
+	args := repo.Called(before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (repo *repoMock) OfferStats(ctx context.Context, offerID uint, now time.Time) (*stats.Offer, error) {
+	args := repo.Called(offerID, now)
+	return args.Get(0).(*stats.Offer), args.Error(1)
+}
+
+func (repo *repoMock) Series(ctx context.Context, offerID uint, bucket time.Duration, from, to time.Time) ([]stats.Point, error) {
+	args := repo.Called(offerID, bucket, from, to)
+	return args.Get(0).([]stats.Point), args.Error(1)
+}
+
+func (repo *repoMock) DailyByOffer(ctx context.Context, day time.Time) ([]*stats.Daily, error) {
+	args := repo.Called(day)
+	return args.Get(0).([]*stats.Daily), args.Error(1)
+}
+
+func (repo *repoMock) MarkUnused(ctx context.Context, id uint) (bool, error) {
+	args := repo.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (repo *repoMock) MarkUsed(ctx context.Context, id uint, at time.Time, discount money.Money) (bool, error) {
+	args := repo.Called(id, at, discount)
+	return args.Bool(0), args.Error(1)
+}
@@ -1,11 +1,19 @@
 package voucherservice
 
 import (
+	"context"
 	"errors"
+	"github.com/deepinbytes/go_voucher/common/metrics"
+	"github.com/deepinbytes/go_voucher/domain/money"
+	"github.com/deepinbytes/go_voucher/domain/user"
 	"github.com/deepinbytes/go_voucher/domain/voucher"
 	"testing"
+	"time"
 
+	"github.com/jinzhu/gorm"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestGetByID(t *testing.T) {
@@ -18,7 +26,7 @@ func TestGetByID(t *testing.T) {
 		u := NewVoucherService(voucherRepo)
 		voucherRepo.On("GetByID", testID100).Return(expected, nil)
 
-		result, _ := u.GetByID(testID100)
+		result, _ := u.GetByID(context.Background(), testID100)
 
 		assert.EqualValues(t, expected, result)
 	})
@@ -29,7 +37,7 @@ func TestGetByID(t *testing.T) {
 		voucherRepo := new(repoMock)
 		u := NewVoucherService(voucherRepo)
 
-		result, err := u.GetByID(0)
+		result, err := u.GetByID(context.Background(), 0)
 
 		assert.Nil(t, result)
 		assert.EqualValues(t, expected, err)
@@ -42,7 +50,7 @@ func TestGetByID(t *testing.T) {
 		u := NewVoucherService(voucherRepo)
 		voucherRepo.On("GetByID", testID10).Return(&voucher.Voucher{}, expected)
 
-		result, err := u.GetByID(testID10)
+		result, err := u.GetByID(context.Background(), testID10)
 
 		assert.Nil(t, result)
 		assert.EqualValues(t, expected, err)
@@ -60,7 +68,7 @@ func TestRedeemCode(t *testing.T) {
 		u := NewVoucherService(voucherRepo)
 		voucherRepo.On("UseCode", testName).Return(expected, nil)
 
-		result, _ := u.UseCode(testName)
+		result, _ := u.UseCode(context.Background(), testName)
 
 		assert.EqualValues(t, expected, result)
 	})
@@ -72,7 +80,7 @@ func TestRedeemCode(t *testing.T) {
 
 		u := NewVoucherService(voucherRepo)
 
-		result, err := u.UseCode("")
+		result, err := u.UseCode(context.Background(), "")
 
 		assert.Nil(t, result)
 		assert.EqualValues(t, expected, err)
@@ -86,7 +94,7 @@ func TestRedeemCode(t *testing.T) {
 		u := NewVoucherService(voucherRepo)
 		voucherRepo.On("UseCode", testName).Return(&voucher.Voucher{}, expected)
 
-		result, err := u.UseCode(testName)
+		result, err := u.UseCode(context.Background(), testName)
 
 		assert.Nil(t, result)
 		assert.EqualValues(t, expected, err)
@@ -104,7 +112,7 @@ func TestCreate(t *testing.T) {
 		u := NewVoucherService(voucherRepo)
 		voucherRepo.On("Create", offer).Return(nil)
 
-		result := u.Create(offer)
+		result := u.Create(context.Background(), offer)
 
 		assert.Nil(t, result)
 	})
@@ -120,10 +128,29 @@ func TestCreate(t *testing.T) {
 		u := NewVoucherService(voucherRepo)
 
 		voucherRepo.On("Create", offer).Return(err)
-		result := u.Create(offer)
+		result := u.Create(context.Background(), offer)
 
 		assert.EqualValues(t, result, err)
 	})
+
+	t.Run("Runs the hooks of every voucher issued", func(t *testing.T) {
+		var hooked []uint
+		hook := func(ctx context.Context, v *voucher.Voucher) error {
+			hooked = append(hooked, v.UserID)
+			return errors.New("oops")
+		}
+		users := []*user.User{{Model: gorm.Model{ID: 1}}, {Model: gorm.Model{ID: 2}}}
+
+		voucherRepo := new(repoMock)
+		u := NewVoucherService(voucherRepo, hook)
+		voucherRepo.On("Create", mock.AnythingOfType("*voucher.Voucher")).Return(nil)
+
+		n, err := u.Generate(context.Background(), 3, users, time.Now())
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, []uint{1, 2}, hooked)
+	})
 }
 
 func TestUpdate(t *testing.T) {
@@ -137,7 +164,7 @@ func TestUpdate(t *testing.T) {
 		u := NewVoucherService(voucherRepo)
 		voucherRepo.On("Update", usr).Return(nil)
 
-		result := u.Update(usr)
+		result := u.Update(context.Background(), usr)
 
 		assert.Nil(t, result)
 	})
@@ -153,8 +180,177 @@ func TestUpdate(t *testing.T) {
 		u := NewVoucherService(voucherRepo)
 		voucherRepo.On("Update", usr).Return(err)
 
-		result := u.Update(usr)
+		result := u.Update(context.Background(), usr)
 
 		assert.EqualValues(t, result, err)
 	})
 }
+
+func TestRedeem(t *testing.T) {
+	alice := &user.User{Email: "alice@cc.cc"}
+
+	t.Run("Redeem a voucher", func(t *testing.T) {
+		v := &voucher.Voucher{Model: gorm.Model{ID: testID10}, Code: "Test", OfferID: 7, ExpireTime: time.Now().Add(time.Hour)}
+		before := testutil.ToFloat64(metrics.VouchersRedeemed.WithLabelValues("7"))
+
+		voucherRepo := new(repoMock)
+		u := NewVoucherService(voucherRepo)
+		voucherRepo.On("MarkUsed", testID10, mock.AnythingOfType("time.Time"), money.Money{}).Return(true, nil)
+
+		err := u.Redeem(context.Background(), v, alice, "alice@cc.cc")
+
+		assert.Nil(t, err)
+		assert.True(t, v.IsUsed)
+		assert.False(t, v.UsedAt.IsZero())
+		assert.Equal(t, before+1, testutil.ToFloat64(metrics.VouchersRedeemed.WithLabelValues("7")))
+		voucherRepo.AssertNotCalled(t, "Update", v)
+	})
+
+	t.Run("Reject a voucher redeemed meanwhile", func(t *testing.T) {
+		v := &voucher.Voucher{Model: gorm.Model{ID: testID10}, Code: "Test", OfferID: 7, ExpireTime: time.Now().Add(time.Hour)}
+
+		voucherRepo := new(repoMock)
+		u := NewVoucherService(voucherRepo)
+		voucherRepo.On("MarkUsed", testID10, mock.AnythingOfType("time.Time"), money.Money{}).Return(false, nil)
+
+		err := u.Redeem(context.Background(), v, alice, "alice@cc.cc")
+
+		assert.EqualValues(t, ErrUsed, err)
+		assert.False(t, v.IsUsed)
+	})
+
+	t.Run("Reject a voucher", func(t *testing.T) {
+		cases := []struct {
+			name    string
+			voucher *voucher.Voucher
+			email   string
+			reason  string
+			err     error
+		}{
+			{"wrong user", &voucher.Voucher{ExpireTime: time.Now().Add(time.Hour)}, "bob@cc.cc", "wrong_user", ErrWrongUser},
+			{"used", &voucher.Voucher{IsUsed: true}, "alice@cc.cc", "used", ErrUsed},
+			{"expired", &voucher.Voucher{ExpireTime: time.Now().Add(-time.Hour)}, "alice@cc.cc", "expired", ErrExpired},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				before := testutil.ToFloat64(metrics.RedemptionFailures.WithLabelValues(tc.reason))
+
+				voucherRepo := new(repoMock)
+				u := NewVoucherService(voucherRepo)
+
+				err := u.Redeem(context.Background(), tc.voucher, alice, tc.email)
+
+				assert.EqualValues(t, tc.err, err)
+				assert.Equal(t, before+1, testutil.ToFloat64(metrics.RedemptionFailures.WithLabelValues(tc.reason)))
+				voucherRepo.AssertNotCalled(t, "MarkUsed", mock.Anything, mock.Anything, mock.Anything)
+			})
+		}
+	})
+}
+
+func TestRedeemAt(t *testing.T) {
+	at := time.Now().Add(-time.Hour)
+
+	t.Run("Redeem a voucher at the given time", func(t *testing.T) {
+		v := &voucher.Voucher{Model: gorm.Model{ID: testID10}, OfferID: 7}
+
+		voucherRepo := new(repoMock)
+		u := NewVoucherService(voucherRepo)
+		voucherRepo.On("MarkUsed", testID10, at, money.Money{}).Return(true, nil)
+
+		err := u.RedeemAt(context.Background(), v, at)
+
+		assert.Nil(t, err)
+		assert.True(t, v.IsUsed)
+		assert.Equal(t, at, v.UsedAt)
+	})
+
+	t.Run("Reject a voucher used meanwhile", func(t *testing.T) {
+		v := &voucher.Voucher{Model: gorm.Model{ID: testID10}, OfferID: 7}
+		before := testutil.ToFloat64(metrics.RedemptionFailures.WithLabelValues("used"))
+
+		voucherRepo := new(repoMock)
+		u := NewVoucherService(voucherRepo)
+		voucherRepo.On("MarkUsed", testID10, at, money.Money{}).Return(false, nil)
+
+		err := u.RedeemAt(context.Background(), v, at)
+
+		assert.EqualValues(t, ErrUsed, err)
+		assert.False(t, v.IsUsed)
+		assert.Equal(t, before+1, testutil.ToFloat64(metrics.RedemptionFailures.WithLabelValues("used")))
+	})
+}
+
+func TestGenerate(t *testing.T) {
+	t.Run("Generate a voucher per user", func(t *testing.T) {
+		users := []*user.User{{Model: gorm.Model{ID: 1}}, {Model: gorm.Model{ID: 2}}}
+		expire := time.Now().Add(time.Hour)
+		before := testutil.ToFloat64(metrics.VouchersIssued.WithLabelValues("3"))
+
+		voucherRepo := new(repoMock)
+		u := NewVoucherService(voucherRepo)
+		voucherRepo.On("Create", mock.AnythingOfType("*voucher.Voucher")).Return(nil)
+
+		n, err := u.Generate(context.Background(), 3, users, expire)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, before+2, testutil.ToFloat64(metrics.VouchersIssued.WithLabelValues("3")))
+		for i, call := range voucherRepo.Calls {
+			v := call.Arguments.Get(0).(*voucher.Voucher)
+			assert.Equal(t, users[i].ID, v.UserID)
+			assert.Equal(t, uint(3), v.OfferID)
+			assert.Equal(t, expire, v.ExpireTime)
+			assert.Len(t, v.Code, codeLength)
+		}
+	})
+
+	t.Run("Stops on the first error", func(t *testing.T) {
+		expected := errors.New("oops")
+		users := []*user.User{{Model: gorm.Model{ID: 1}}, {Model: gorm.Model{ID: 2}}}
+
+		voucherRepo := new(repoMock)
+		u := NewVoucherService(voucherRepo)
+		voucherRepo.On("Create", mock.AnythingOfType("*voucher.Voucher")).Return(expected)
+
+		n, err := u.Generate(context.Background(), 3, users, time.Now())
+
+		assert.EqualValues(t, expected, err)
+		assert.Equal(t, 0, n)
+		voucherRepo.AssertNumberOfCalls(t, "Create", 1)
+	})
+}
+
+func TestReverse(t *testing.T) {
+	t.Run("Reverse a redemption", func(t *testing.T) {
+		v := &voucher.Voucher{Model: gorm.Model{ID: testID10}, OfferID: 7, IsUsed: true, UsedAt: time.Now()}
+		before := testutil.ToFloat64(metrics.VouchersReversed.WithLabelValues("7"))
+
+		voucherRepo := new(repoMock)
+		u := NewVoucherService(voucherRepo)
+		voucherRepo.On("MarkUnused", testID10).Return(true, nil)
+
+		err := u.Reverse(context.Background(), v)
+
+		assert.Nil(t, err)
+		assert.False(t, v.IsUsed)
+		assert.True(t, v.UsedAt.IsZero())
+		assert.Equal(t, before+1, testutil.ToFloat64(metrics.VouchersReversed.WithLabelValues("7")))
+	})
+
+	t.Run("Reject a voucher not used in the repository", func(t *testing.T) {
+		// a stale copy still used, reversed meanwhile
+		v := &voucher.Voucher{Model: gorm.Model{ID: testID10}, OfferID: 7, IsUsed: true}
+		before := testutil.ToFloat64(metrics.VouchersReversed.WithLabelValues("7"))
+
+		voucherRepo := new(repoMock)
+		u := NewVoucherService(voucherRepo)
+		voucherRepo.On("MarkUnused", testID10).Return(false, nil)
+
+		err := u.Reverse(context.Background(), v)
+
+		assert.EqualValues(t, ErrNotUsed, err)
+		assert.True(t, v.IsUsed)
+		assert.Equal(t, before, testutil.ToFloat64(metrics.VouchersReversed.WithLabelValues("7")))
+	})
+}
@@ -0,0 +1,201 @@
+package walletservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/domain/wallet"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/userservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+)
+
+// defaultLimit is the page size of every group without Filter.Limit
+const defaultLimit = 20
+
+// ErrStatus is returned for statuses other than those of wallet.Statuses
+var ErrStatus = errors.New("status must be active, used or expired")
+
+// Filter narrows down a wallet
+type Filter struct {
+	// Status only lists the group of this status, all groups when empty
+	Status string
+	// OfferID only lists the vouchers of this offer, all when 0
+	OfferID uint
+	// After skips vouchers up to and including this ID, with Status only
+	// as every group pages on its own
+	After uint
+	// Limit is the page size of every group, defaultLimit when 0
+	Limit int
+}
+
+// WalletService shows users their vouchers
+type WalletService interface {
+	Get(ctx context.Context, userID uint, filter Filter) (*wallet.Wallet, error)
+}
+
+type walletService struct {
+	users    userservice.UserService
+	offers   offerservice.OfferService
+	vouchers voucherservice.VoucherService
+	now      func() time.Time
+}
+
+// NewWalletService will instantiate Wallet Service
+func NewWalletService(
+	users userservice.UserService,
+	offers offerservice.OfferService,
+	vouchers voucherservice.VoucherService,
+) WalletService {
+
+	return &walletService{
+		users:    users,
+		offers:   offers,
+		vouchers: vouchers,
+		now:      time.Now,
+	}
+}
+
+func (ws *walletService) Get(ctx context.Context, userID uint, filter Filter) (*wallet.Wallet, error) {
+	statuses := wallet.Statuses
+	if filter.Status != "" {
+		if _, err := listOptions(filter.Status, filter, time.Time{}); err != nil {
+			return nil, err
+		}
+		statuses = []string{filter.Status}
+	} else {
+		filter.After = 0
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultLimit
+	}
+	if _, err := ws.users.GetByID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	now := ws.now()
+	w := &wallet.Wallet{UserID: userID}
+	var groups []*wallet.Group
+	for _, status := range statuses {
+		opts, err := listOptions(status, filter, now)
+		if err != nil {
+			return nil, err
+		}
+		vouchers, err := ws.vouchers.ListByUsers(ctx, []uint{userID}, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		g := &wallet.Group{Vouchers: []wallet.Item{}}
+		if len(vouchers) > filter.Limit {
+			vouchers = vouchers[:filter.Limit]
+			g.NextAfter = vouchers[len(vouchers)-1].ID
+		}
+		for _, v := range vouchers {
+			g.Vouchers = append(g.Vouchers, item(v, status, now))
+		}
+		switch status {
+		case wallet.StatusActive:
+			w.Active = g
+		case wallet.StatusUsed:
+			w.Used = g
+		case wallet.StatusExpired:
+			w.Expired = g
+		}
+		groups = append(groups, g)
+	}
+
+	if err := ws.addOffers(ctx, groups); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+/*******************************/
+//       PRIVATE METHODS
+/*******************************/
+
+// addOffers fills in the offer details of the vouchers of groups
+func (ws *walletService) addOffers(ctx context.Context, groups []*wallet.Group) error {
+	var ids []uint
+	seen := make(map[uint]bool)
+	for _, g := range groups {
+		for _, it := range g.Vouchers {
+			if !seen[it.Offer.ID] {
+				seen[it.Offer.ID] = true
+				ids = append(ids, it.Offer.ID)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	offers, err := ws.offers.GetByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	details := make(map[uint]*wallet.Offer, len(offers))
+	for _, o := range offers {
+		details[o.ID] = &wallet.Offer{
+			ID:                 o.ID,
+			Name:               o.Name,
+			DiscountPercentage: o.DiscountPercentage,
+			Discount:           fmt.Sprintf("%d%% off", o.DiscountPercentage),
+		}
+	}
+	for _, g := range groups {
+		for i := range g.Vouchers {
+			g.Vouchers[i].Offer = details[g.Vouchers[i].Offer.ID]
+		}
+	}
+	return nil
+}
+
+// listOptions lists a page of the vouchers of status, one more than the
+// limit to tell whether there is a next page
+func listOptions(status string, filter Filter, now time.Time) (voucherrepo.ListOptions, error) {
+	used, expired := false, false
+	opts := voucherrepo.ListOptions{
+		After:   filter.After,
+		Limit:   filter.Limit + 1,
+		IsUsed:  &used,
+		Now:     now,
+		OfferID: filter.OfferID,
+	}
+	switch status {
+	case wallet.StatusActive:
+		opts.Expired = &expired
+	case wallet.StatusUsed:
+		used = true
+	case wallet.StatusExpired:
+		expired = true
+		opts.Expired = &expired
+	default:
+		return opts, ErrStatus
+	}
+	return opts, nil
+}
+
+func item(v *voucher.Voucher, status string, now time.Time) wallet.Item {
+	it := wallet.Item{
+		ID:         v.ID,
+		Code:       v.Code,
+		Status:     status,
+		Offer:      &wallet.Offer{ID: v.OfferID},
+		IssuedAt:   v.CreatedAt,
+		ExpireTime: v.ExpireTime,
+	}
+	if v.IsUsed {
+		usedAt := v.UsedAt
+		it.UsedAt = &usedAt
+	}
+	if status == wallet.StatusActive {
+		days := int(v.ExpireTime.Sub(now) / (24 * time.Hour))
+		it.DaysToExpiry = &days
+	}
+	return it
+}
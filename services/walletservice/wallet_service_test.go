@@ -0,0 +1,114 @@
+package walletservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deepinbytes/go_voucher/domain/offer"
+	"github.com/deepinbytes/go_voucher/domain/user"
+	"github.com/deepinbytes/go_voucher/domain/voucher"
+	"github.com/deepinbytes/go_voucher/domain/wallet"
+	"github.com/deepinbytes/go_voucher/repositories/memdb"
+	"github.com/deepinbytes/go_voucher/repositories/offerrepo"
+	"github.com/deepinbytes/go_voucher/repositories/userrepo"
+	"github.com/deepinbytes/go_voucher/repositories/voucherrepo"
+	"github.com/deepinbytes/go_voucher/services/offerservice"
+	"github.com/deepinbytes/go_voucher/services/userservice"
+	"github.com/deepinbytes/go_voucher/services/voucherservice"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	ctx = context.Background()
+	now = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+)
+
+// setup gives alice two active vouchers of different offers, a used one
+// and an expired one
+func setup(t *testing.T) (*walletService, *user.User, []*offer.Offer) {
+	db := memdb.New()
+	users := userservice.NewUserService(userrepo.NewMemoryUserRepo(db))
+	offers := offerservice.NewOfferService(offerrepo.NewMemoryOfferRepo(db))
+	vouchers := voucherservice.NewVoucherService(voucherrepo.NewMemoryVoucherRepo(db))
+	svc := NewWalletService(users, offers, vouchers).(*walletService)
+	svc.now = func() time.Time { return now }
+
+	alice := &user.User{Email: "alice@cc.cc"}
+	require.Nil(t, users.Create(ctx, alice))
+	summer := &offer.Offer{Name: "Summer", DiscountPercentage: 20}
+	winter := &offer.Offer{Name: "Winter", DiscountPercentage: 5}
+	require.Nil(t, offers.Create(ctx, summer))
+	require.Nil(t, offers.Create(ctx, winter))
+	for _, v := range []*voucher.Voucher{
+		{Code: "ACTIVE01", OfferID: summer.ID, ExpireTime: now.Add(50 * time.Hour)},
+		{Code: "ACTIVE02", OfferID: winter.ID, ExpireTime: now.Add(time.Hour)},
+		{Code: "USED0001", OfferID: summer.ID, IsUsed: true, UsedAt: now.Add(-time.Hour)},
+		{Code: "EXPIRED1", OfferID: summer.ID, ExpireTime: now.Add(-time.Hour)},
+	} {
+		v.UserID = alice.ID
+		require.Nil(t, vouchers.Create(ctx, v))
+	}
+	return svc, alice, []*offer.Offer{summer, winter}
+}
+
+func codes(g *wallet.Group) []string {
+	out := []string{}
+	for _, it := range g.Vouchers {
+		out = append(out, it.Code)
+	}
+	return out
+}
+
+func TestGet(t *testing.T) {
+	t.Run("Groups by status", func(t *testing.T) {
+		svc, alice, _ := setup(t)
+
+		w, err := svc.Get(ctx, alice.ID, Filter{})
+
+		require.Nil(t, err)
+		assert.Equal(t, []string{"ACTIVE01", "ACTIVE02"}, codes(w.Active))
+		assert.Equal(t, []string{"USED0001"}, codes(w.Used))
+		assert.Equal(t, []string{"EXPIRED1"}, codes(w.Expired))
+
+		active := w.Active.Vouchers[0]
+		assert.Equal(t, "20% off", active.Offer.Discount)
+		assert.Equal(t, 2, *active.DaysToExpiry)
+		assert.Equal(t, 0, *w.Active.Vouchers[1].DaysToExpiry)
+		assert.NotNil(t, w.Used.Vouchers[0].UsedAt)
+		assert.Nil(t, w.Expired.Vouchers[0].DaysToExpiry)
+	})
+
+	t.Run("Pages and filters a status", func(t *testing.T) {
+		svc, alice, offers := setup(t)
+
+		w, err := svc.Get(ctx, alice.ID, Filter{Status: wallet.StatusActive, Limit: 1})
+		require.Nil(t, err)
+		assert.Nil(t, w.Used)
+		assert.Equal(t, []string{"ACTIVE01"}, codes(w.Active))
+		assert.NotZero(t, w.Active.NextAfter)
+
+		w, err = svc.Get(ctx, alice.ID, Filter{Status: wallet.StatusActive, Limit: 1, After: w.Active.NextAfter})
+		require.Nil(t, err)
+		assert.Equal(t, []string{"ACTIVE02"}, codes(w.Active))
+		assert.Zero(t, w.Active.NextAfter)
+
+		w, err = svc.Get(ctx, alice.ID, Filter{OfferID: offers[1].ID})
+		require.Nil(t, err)
+		assert.Equal(t, []string{"ACTIVE02"}, codes(w.Active))
+		assert.Empty(t, codes(w.Used))
+	})
+
+	t.Run("Rejects unknown users and statuses", func(t *testing.T) {
+		svc, alice, _ := setup(t)
+
+		_, err := svc.Get(ctx, 42, Filter{})
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+
+		_, err = svc.Get(ctx, alice.ID, Filter{Status: "lost"})
+		assert.Equal(t, ErrStatus, err)
+	})
+}